@@ -0,0 +1,210 @@
+// Package alert sends notifications, when the decrypt service crosses a
+// threshold, for example when too many votes of a poll are invalid.
+//
+// An Alerter is a decrypt.Monitor. It compares each decryption with the
+// thresholds and sends an Alert to all notifiers. Notifiers are implemented
+// for webhooks and SMTP.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Rules, that can trigger an alert.
+const (
+	// RuleInvalidVotes is triggered, when the ratio of invalid votes of a poll
+	// is above the threshold.
+	RuleInvalidVotes = "invalid_votes"
+
+	// RuleLongStop is triggered, when the decryption of a poll takes longer
+	// then the threshold.
+	RuleLongStop = "long_stop"
+
+	// RuleStoreErrors is triggered, when the number of store calls in a row,
+	// that failed, reaches the threshold.
+	RuleStoreErrors = "store_errors"
+)
+
+// notifyTimeout is the maximum time for sending one alert to one notifier.
+const notifyTimeout = 30 * time.Second
+
+// Alert is one notification.
+type Alert struct {
+	Time    time.Time `json:"time"`
+	Rule    string    `json:"rule"`
+	PollID  string    `json:"poll_id,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Notifier sends alerts, for example as webhook or email.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Thresholds configure, when alerts are sent. A zero value disables the
+// rule.
+type Thresholds struct {
+	// InvalidRatio is the ratio of invalid votes of a poll between 0 and 1.
+	InvalidRatio float64
+
+	// StopDuration is the maximum time for decrypting the votes of a poll.
+	StopDuration time.Duration
+
+	// StoreErrors is the number of failed store calls in a row.
+	StoreErrors int
+}
+
+// Alerter checks the thresholds and sends alerts to the notifiers.
+type Alerter struct {
+	thresholds Thresholds
+	notifiers  []Notifier
+	now        func() time.Time
+
+	mu      sync.Mutex
+	running map[string]time.Time
+	alerted map[string]bool
+	wg      sync.WaitGroup
+}
+
+// Option for New().
+type Option func(*Alerter)
+
+// WithClock sets the source of the time. Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(a *Alerter) {
+		a.now = now
+	}
+}
+
+// New initializes an Alerter.
+func New(thresholds Thresholds, notifiers []Notifier, options ...Option) *Alerter {
+	a := &Alerter{
+		thresholds: thresholds,
+		notifiers:  notifiers,
+		now:        time.Now,
+		running:    make(map[string]time.Time),
+		alerted:    make(map[string]bool),
+	}
+
+	for _, o := range options {
+		o(a)
+	}
+
+	return a
+}
+
+// StopStarted starts the timer for the decryption of a poll.
+func (a *Alerter) StopStarted(pollID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.running[pollID] = a.now()
+	delete(a.alerted, pollID)
+}
+
+// StopFinished checks the invalid votes and the duration of the decryption of
+// a poll.
+func (a *Alerter) StopFinished(pollID string, votes, invalid int, err error) {
+	a.mu.Lock()
+	started, ok := a.running[pollID]
+	alerted := a.alerted[pollID]
+	delete(a.running, pollID)
+	delete(a.alerted, pollID)
+	a.mu.Unlock()
+
+	if ok && !alerted && a.thresholds.StopDuration > 0 {
+		if duration := a.now().Sub(started); duration > a.thresholds.StopDuration {
+			a.send(RuleLongStop, pollID, fmt.Sprintf("decrypting %d votes took %s", votes, duration.Round(time.Second)))
+		}
+	}
+
+	if err != nil || votes == 0 || a.thresholds.InvalidRatio <= 0 {
+		return
+	}
+
+	if ratio := float64(invalid) / float64(votes); ratio > a.thresholds.InvalidRatio {
+		a.send(RuleInvalidVotes, pollID, fmt.Sprintf("%d of %d votes (%.1f%%) are invalid", invalid, votes, ratio*100))
+	}
+}
+
+// StoreFailed is the hook for breaker.WithFailureHook(). It sends an alert,
+// when the number of failed calls in a row reaches the threshold.
+func (a *Alerter) StoreFailed(failures int, err error) {
+	if a.thresholds.StoreErrors > 0 && failures == a.thresholds.StoreErrors {
+		a.send(RuleStoreErrors, "", fmt.Sprintf("%d store calls in a row failed: %v", failures, err))
+	}
+}
+
+// Run checks every interval, if a running decryption takes longer then the
+// threshold, until ctx is done. Without Run(), a long decryption is only
+// alerted, after it finished.
+func (a *Alerter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.checkRunning()
+		}
+	}
+}
+
+func (a *Alerter) checkRunning() {
+	if a.thresholds.StopDuration <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	var long []string
+	now := a.now()
+	for pollID, started := range a.running {
+		if !a.alerted[pollID] && now.Sub(started) > a.thresholds.StopDuration {
+			a.alerted[pollID] = true
+			long = append(long, pollID)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, pollID := range long {
+		a.send(RuleLongStop, pollID, fmt.Sprintf("decryption is running for more then %s", a.thresholds.StopDuration))
+	}
+}
+
+// send sends an alert to all notifiers in the background, so a slow notifier
+// does not delay the decryption.
+func (a *Alerter) send(rule, pollID, message string) {
+	alert := Alert{
+		Time:    a.now().UTC(),
+		Rule:    rule,
+		PollID:  pollID,
+		Message: message,
+	}
+
+	log.Printf("Alert %s: %s %s", rule, pollID, message)
+
+	for _, notifier := range a.notifiers {
+		a.wg.Add(1)
+		go func(notifier Notifier) {
+			defer a.wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+
+			if err := notifier.Notify(ctx, alert); err != nil {
+				log.Printf("Error: sending alert %s: %v", rule, err)
+			}
+		}(notifier)
+	}
+}
+
+// Wait blocks, until all alerts were sent.
+func (a *Alerter) Wait() {
+	a.wg.Wait()
+}
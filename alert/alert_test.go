@@ -0,0 +1,160 @@
+package alert_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/alert"
+)
+
+type notifierMock struct {
+	mu     sync.Mutex
+	alerts []alert.Alert
+}
+
+func (n *notifierMock) Notify(ctx context.Context, a alert.Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+func (n *notifierMock) rules() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var rules []string
+	for _, a := range n.alerts {
+		rules = append(rules, a.Rule+":"+a.PollID)
+	}
+	return rules
+}
+
+func TestAlerter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	thresholds := alert.Thresholds{
+		InvalidRatio: 0.1,
+		StopDuration: time.Minute,
+		StoreErrors:  3,
+	}
+
+	t.Run("invalid votes", func(t *testing.T) {
+		notifier := new(notifierMock)
+		a := alert.New(thresholds, []alert.Notifier{notifier}, alert.WithClock(func() time.Time { return now }))
+
+		a.StopStarted("test/1")
+		a.StopFinished("test/1", 100, 10, nil)
+		a.StopStarted("test/2")
+		a.StopFinished("test/2", 100, 11, nil)
+		a.StopStarted("test/3")
+		a.StopFinished("test/3", 100, 50, errors.New("store is down"))
+		a.Wait()
+
+		if got := notifier.rules(); len(got) != 1 || got[0] != "invalid_votes:test/2" {
+			t.Errorf("got alerts %v, expected one for test/2", got)
+		}
+	})
+
+	t.Run("long stop", func(t *testing.T) {
+		notifier := new(notifierMock)
+		clock := now
+		a := alert.New(thresholds, []alert.Notifier{notifier}, alert.WithClock(func() time.Time { return clock }))
+
+		a.StopStarted("test/1")
+		clock = clock.Add(2 * time.Minute)
+		a.StopFinished("test/1", 1, 0, nil)
+
+		a.StopStarted("test/2")
+		clock = clock.Add(30 * time.Second)
+		a.StopFinished("test/2", 1, 0, nil)
+		a.Wait()
+
+		if got := notifier.rules(); len(got) != 1 || got[0] != "long_stop:test/1" {
+			t.Errorf("got alerts %v, expected one for test/1", got)
+		}
+	})
+
+	t.Run("running stop", func(t *testing.T) {
+		notifier := new(notifierMock)
+		var mu sync.Mutex
+		clock := now
+		a := alert.New(thresholds, []alert.Notifier{notifier}, alert.WithClock(func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return clock
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go a.Run(ctx, time.Millisecond)
+
+		a.StopStarted("test/1")
+		mu.Lock()
+		clock = clock.Add(2 * time.Minute)
+		mu.Unlock()
+
+		for i := 0; len(notifier.rules()) == 0; i++ {
+			if i > 1000 {
+				t.Fatalf("no alert for the running stop")
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		a.StopFinished("test/1", 1, 0, nil)
+		cancel()
+		a.Wait()
+
+		if got := notifier.rules(); len(got) != 1 || got[0] != "long_stop:test/1" {
+			t.Errorf("got alerts %v, expected one alert for test/1", got)
+		}
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		notifier := new(notifierMock)
+		a := alert.New(thresholds, []alert.Notifier{notifier})
+
+		for i := 1; i <= 5; i++ {
+			a.StoreFailed(i, errors.New("store is down"))
+		}
+		a.Wait()
+
+		if got := notifier.rules(); len(got) != 1 || got[0] != "store_errors:" {
+			t.Errorf("got alerts %v, expected one store error alert", got)
+		}
+	})
+}
+
+func TestWebhook(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	err := alert.NewWebhook(srv.URL).Notify(context.Background(), alert.Alert{
+		Time:    time.Now(),
+		Rule:    alert.RuleInvalidVotes,
+		PollID:  "test/1",
+		Message: "11 of 100 votes are invalid",
+	})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if body["rule"] != "invalid_votes" || body["poll_id"] != "test/1" {
+		t.Errorf("got body %v, expected the fields of the alert", body)
+	}
+
+	if expect := "vote-decrypt alert invalid_votes for poll test/1: 11 of 100 votes are invalid"; body["text"] != expect {
+		t.Errorf("got text %q, expected %q", body["text"], expect)
+	}
+}
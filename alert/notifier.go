@@ -0,0 +1,120 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Webhook sends each alert as json in a POST request to a url.
+//
+// The body contains the fields of the Alert and the field `text` with a
+// summary, so the webhooks of Slack or Mattermost can be used directly.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook initializes a Webhook.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: notifyTimeout},
+	}
+}
+
+// Notify sends the alert.
+func (w *Webhook) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(struct {
+		Alert
+		Text string `json:"text"`
+	}{alert, summary(alert)})
+	if err != nil {
+		return fmt.Errorf("encoding alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTP sends each alert as email.
+type SMTP struct {
+	addr     string
+	from     string
+	to       []string
+	username string
+	password string
+}
+
+// NewSMTP initializes a SMTP notifier. addr is HOST:PORT of the mail server.
+// If username is not empty, the notifier authenticates with PLAIN auth, that
+// needs TLS, unless the server is on localhost.
+func NewSMTP(addr, from string, to []string, username, password string) *SMTP {
+	return &SMTP{
+		addr:     addr,
+		from:     from,
+		to:       to,
+		username: username,
+		password: password,
+	}
+}
+
+// Notify sends the alert. The context is not used, since net/smtp does not
+// support it.
+func (s *SMTP) Notify(ctx context.Context, alert Alert) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, err := net.SplitHostPort(s.addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address `%s`: %w", s.addr, err)
+		}
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+
+	if err := smtp.SendMail(s.addr, auth, s.from, s.to, s.message(alert)); err != nil {
+		return fmt.Errorf("sending mail: %w", err)
+	}
+	return nil
+}
+
+// message returns the email with headers.
+func (s *SMTP) message(alert Alert) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&buf, "Subject: vote-decrypt alert: %s\r\n", alert.Rule)
+	fmt.Fprintf(&buf, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&buf, "\r\n%s\r\n", summary(alert))
+	return buf.Bytes()
+}
+
+// summary returns the alert as one line of text.
+func summary(alert Alert) string {
+	if alert.PollID == "" {
+		return fmt.Sprintf("vote-decrypt alert %s: %s", alert.Rule, alert.Message)
+	}
+	return fmt.Sprintf("vote-decrypt alert %s for poll %s: %s", alert.Rule, alert.PollID, alert.Message)
+}
@@ -0,0 +1,403 @@
+// Package archive implements a self-contained archive of the result of a poll.
+//
+// An archive contains the public main key, the signed result envelope, the
+// public poll key and an excerpt of the audit log. It can be checked years
+// later without the service or its store. The format is described in
+// FORMAT.md, which is also part of each archive.
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/audit"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/tsa"
+)
+
+// Version is the version of the archive format, that Write creates.
+const Version = 1
+
+// formatName is the value of the field "format" of the manifest.
+const formatName = "vote-decrypt-archive"
+
+// Format is the description of the archive format.
+//
+//go:embed FORMAT.md
+var Format []byte
+
+// Names of the files in the archive.
+const (
+	fileFormat      = "FORMAT.md"
+	fileManifest    = "manifest.json"
+	fileMainKey     = "public_main_key"
+	fileEnvelope    = "envelope.json"
+	filePollKey     = "poll_key"
+	filePollKeySig  = "poll_key.sig"
+	fileAuditLog    = "audit.jsonl"
+	fileAuditLogSig = "audit.jsonl.sig"
+)
+
+// maxArchiveSize is the maximum size of an archive and of each of its files.
+const maxArchiveSize = 1 << 30
+
+// maxLineSize is the maximum size of one line of the audit log. It is the
+// same as in package audit.
+const maxLineSize = 1 << 20
+
+// Archive is the content of an archive.
+type Archive struct {
+	// Created is the time, the archive was created.
+	Created time.Time
+
+	PublicMainKey []byte
+	Envelope      grpc.ResultEnvelope
+
+	// PollKey and PollKeySig are the public poll key and its signature. They
+	// are empty, if the archive has no poll key.
+	PollKey    []byte
+	PollKeySig []byte
+
+	// AuditLog and AuditLogSig are the exported audit entries of the poll and
+	// their signature. They are empty, if the archive has no audit log.
+	AuditLog    []byte
+	AuditLogSig []byte
+}
+
+// manifest is the content of manifest.json.
+type manifest struct {
+	Format  string            `json:"format"`
+	Version int               `json:"version"`
+	PollID  string            `json:"poll_id"`
+	Created time.Time         `json:"created"`
+	Files   map[string]string `json:"files"`
+}
+
+// Write writes the archive as zip file.
+func (a Archive) Write(w io.Writer) error {
+	envelope, err := json.Marshal(a.Envelope)
+	if err != nil {
+		return fmt.Errorf("encoding envelope: %w", err)
+	}
+
+	files := map[string][]byte{
+		fileFormat:   Format,
+		fileMainKey:  encodeValue(a.PublicMainKey),
+		fileEnvelope: envelope,
+	}
+
+	if len(a.PollKey) > 0 {
+		files[filePollKey] = encodeValue(a.PollKey)
+		files[filePollKeySig] = encodeValue(a.PollKeySig)
+	}
+
+	if len(a.AuditLog) > 0 {
+		files[fileAuditLog] = a.AuditLog
+		files[fileAuditLogSig] = encodeValue(a.AuditLogSig)
+	}
+
+	m := manifest{
+		Format:  formatName,
+		Version: Version,
+		PollID:  a.Envelope.ID,
+		Created: a.Created.UTC(),
+		Files:   make(map[string]string, len(files)),
+	}
+
+	names := make([]string, 0, len(files))
+	for name, content := range files {
+		hash := sha256.Sum256(content)
+		m.Files[name] = hex.EncodeToString(hash[:])
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	encodedManifest, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+	for _, name := range append([]string{fileManifest}, names...) {
+		content := encodedManifest
+		if name != fileManifest {
+			content = files[name]
+		}
+
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: m.Created})
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", name, err)
+		}
+
+		if _, err := fw.Write(content); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("closing zip file: %w", err)
+	}
+	return nil
+}
+
+// Read reads an archive and checks its manifest. It does not check any
+// signature. Call Verify() for this.
+func Read(r io.ReaderAt, size int64) (Archive, error) {
+	if size > maxArchiveSize {
+		return Archive{}, fmt.Errorf("archive has %d bytes, only %d are allowed", size, maxArchiveSize)
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Archive{}, fmt.Errorf("opening zip file: %w", err)
+	}
+
+	files := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		if _, ok := files[f.Name]; ok {
+			return Archive{}, fmt.Errorf("file %s is more then once in the archive", f.Name)
+		}
+
+		content, err := readFile(f)
+		if err != nil {
+			return Archive{}, fmt.Errorf("reading %s: %w", f.Name, err)
+		}
+		files[f.Name] = content
+	}
+
+	rawManifest, ok := files[fileManifest]
+	if !ok {
+		return Archive{}, fmt.Errorf("archive has no %s", fileManifest)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(rawManifest, &m); err != nil {
+		return Archive{}, fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	if m.Format != formatName {
+		return Archive{}, fmt.Errorf("unknown format %q", m.Format)
+	}
+
+	if m.Version < 1 || m.Version > Version {
+		return Archive{}, fmt.Errorf("unsupported archive version %d, only version %d is supported", m.Version, Version)
+	}
+
+	for name, content := range files {
+		if name == fileManifest {
+			continue
+		}
+
+		expected, ok := m.Files[name]
+		if !ok {
+			return Archive{}, fmt.Errorf("file %s is not in the manifest", name)
+		}
+
+		hash := sha256.Sum256(content)
+		if hex.EncodeToString(hash[:]) != expected {
+			return Archive{}, fmt.Errorf("file %s does not match the hash of the manifest", name)
+		}
+	}
+
+	for name := range m.Files {
+		if _, ok := files[name]; !ok {
+			return Archive{}, fmt.Errorf("file %s of the manifest is missing", name)
+		}
+	}
+
+	a := Archive{Created: m.Created}
+
+	for _, name := range []string{fileMainKey, fileEnvelope} {
+		if _, ok := files[name]; !ok {
+			return Archive{}, fmt.Errorf("archive has no %s", name)
+		}
+	}
+
+	if a.PublicMainKey, err = decodeValue(files[fileMainKey]); err != nil {
+		return Archive{}, fmt.Errorf("decoding %s: %w", fileMainKey, err)
+	}
+
+	if err := json.Unmarshal(files[fileEnvelope], &a.Envelope); err != nil {
+		return Archive{}, fmt.Errorf("decoding %s: %w", fileEnvelope, err)
+	}
+
+	if a.Envelope.ID != m.PollID {
+		return Archive{}, fmt.Errorf("archive is for poll %s, but the envelope is for poll %s", m.PollID, a.Envelope.ID)
+	}
+
+	for _, pair := range []struct {
+		name, sigName string
+		value, sig    *[]byte
+		encoded       bool
+	}{
+		{filePollKey, filePollKeySig, &a.PollKey, &a.PollKeySig, true},
+		{fileAuditLog, fileAuditLogSig, &a.AuditLog, &a.AuditLogSig, false},
+	} {
+		value, hasValue := files[pair.name]
+		sig, hasSig := files[pair.sigName]
+		if hasValue != hasSig {
+			return Archive{}, fmt.Errorf("archive needs %s and %s or none of them", pair.name, pair.sigName)
+		}
+
+		if !hasValue {
+			continue
+		}
+
+		if pair.encoded {
+			if value, err = decodeValue(value); err != nil {
+				return Archive{}, fmt.Errorf("decoding %s: %w", pair.name, err)
+			}
+		}
+		*pair.value = value
+
+		if *pair.sig, err = decodeValue(sig); err != nil {
+			return Archive{}, fmt.Errorf("decoding %s: %w", pair.sigName, err)
+		}
+	}
+
+	return a, nil
+}
+
+// Report is the result of Verify.
+type Report struct {
+	PollID string
+
+	// ResultHash is the fingerprint of the signed content. See
+	// decrypt.Fingerprint().
+	ResultHash string
+
+	Result decrypt.Result
+
+	// Timestamp is the time of the timestamp token of the envelope. Zero, if
+	// the envelope has no timestamp.
+	Timestamp time.Time
+
+	// AuditEntries is the number of entries of the audit log.
+	AuditEntries int
+}
+
+// Verify checks all signatures of the archive with its public main key.
+//
+// It checks the signature of the envelope, the signed content, the timestamp
+// token, the signature of the poll key and of the audit log. It does not check
+// that the public main key is the key of the service or the signature of the
+// time stamping authority. The caller has to compare PublicMainKey with a
+// trusted key.
+func (a Archive) Verify() (Report, error) {
+	e := a.Envelope
+	if err := e.Verify(a.PublicMainKey); err != nil {
+		return Report{}, fmt.Errorf("envelope: %w", err)
+	}
+
+	result, err := decrypt.ParseResult(e.Content)
+	if err != nil {
+		return Report{}, fmt.Errorf("invalid content: %w", err)
+	}
+
+	if result.ID != e.ID {
+		return Report{}, fmt.Errorf("envelope is for poll %s, but the signed content is for poll %s", e.ID, result.ID)
+	}
+
+	report := Report{
+		PollID:     e.ID,
+		ResultHash: decrypt.Fingerprint(e.Content),
+		Result:     result,
+	}
+
+	if len(e.Timestamp) > 0 {
+		report.Timestamp, err = tsa.Verify(e.Timestamp, e.Signature)
+		if err != nil {
+			return Report{}, fmt.Errorf("invalid timestamp: %w", err)
+		}
+	}
+
+	if len(a.PollKey) > 0 {
+		// The poll key is signed by the same key as the result. Verify()
+		// checked the delegation to the poll or meeting signing key.
+		signingKey := a.PublicMainKey
+		if len(e.PollSigningKey) > 0 {
+			signingKey = e.PollSigningKey
+		}
+
+		if !crypto.Verify(signingKey, a.PollKey, a.PollKeySig) {
+			return Report{}, fmt.Errorf("invalid signature of the poll key")
+		}
+	}
+
+	if len(a.AuditLog) > 0 {
+		if !crypto.Verify(a.PublicMainKey, a.AuditLog, a.AuditLogSig) {
+			return Report{}, fmt.Errorf("invalid signature of the audit log")
+		}
+
+		report.AuditEntries, err = auditEntries(a.AuditLog, e.ID)
+		if err != nil {
+			return Report{}, fmt.Errorf("audit log: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// auditEntries checks, that all entries of the audit log are for the poll and
+// returns their number.
+func auditEntries(log []byte, pollID string) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	scanner.Buffer(nil, maxLineSize)
+
+	var count int
+	for scanner.Scan() {
+		count++
+
+		var entry audit.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return 0, fmt.Errorf("decoding line %d: %w", count, err)
+		}
+
+		if entry.PollID != pollID {
+			return 0, fmt.Errorf("line %d is for poll %q, not for poll %s", count, entry.PollID, pollID)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading audit log: %w", err)
+	}
+	return count, nil
+}
+
+// readFile reads a file of the zip archive.
+func readFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxArchiveSize {
+		return nil, fmt.Errorf("file has %d bytes, only %d are allowed", f.UncompressedSize64, maxArchiveSize)
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(io.LimitReader(r, maxArchiveSize))
+}
+
+// encodeValue encodes a key or signature file.
+func encodeValue(value []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(value) + "\n")
+}
+
+// decodeValue decodes a key or signature file.
+func decodeValue(encoded []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+}
@@ -0,0 +1,234 @@
+package archive_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"io"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/archive"
+	"github.com/OpenSlides/vote-decrypt/audit"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/store"
+)
+
+// newArchive stops a poll and returns its archive.
+func newArchive(t *testing.T) archive.Archive {
+	t.Helper()
+	ctx := context.Background()
+
+	cr := crypto.New(make([]byte, 32), rand.Reader, nil)
+	auditLog := audit.New(path.Join(t.TempDir(), "audit.log"))
+	d := decrypt.New(cr, store.New(t.TempDir()), decrypt.WithAuditLog(auditLog))
+
+	pubKey, _, err := d.Start(ctx, "1")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	vote, err := crypto.Encrypt(rand.Reader, ecdh.X25519(), pubKey, []byte(`"Y"`))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	content, signature, err := d.Stop(ctx, "1", [][]byte{vote})
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	status, err := d.Status(ctx, "1")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+
+	auditContent, auditSig, err := d.ExportAuditLog(ctx, "1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("export audit log: %v", err)
+	}
+
+	return archive.Archive{
+		Created:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		PublicMainKey: cr.PublicMainKey(),
+		Envelope:      grpc.ResultEnvelope{ID: "1", Content: content, Signature: signature},
+		PollKey:       status.PubKey,
+		PollKeySig:    status.PubKeySig,
+		AuditLog:      auditContent,
+		AuditLogSig:   auditSig,
+	}
+}
+
+// roundtrip writes and reads the archive.
+func roundtrip(t *testing.T, a archive.Archive) (archive.Archive, error) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return archive.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+// rewrite writes the archive and changes the files with modify.
+func rewrite(t *testing.T, a archive.Archive, modify func(name string, content []byte) []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reading zip: %v", err)
+	}
+
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	for _, f := range zr.File {
+		r, _ := f.Open()
+		content, _ := io.ReadAll(r)
+		r.Close()
+
+		content = modify(f.Name, content)
+		if content == nil {
+			continue
+		}
+
+		fw, _ := zw.Create(f.Name)
+		fw.Write(content)
+	}
+	zw.Close()
+	return out.Bytes()
+}
+
+func TestArchive(t *testing.T) {
+	a := newArchive(t)
+
+	got, err := roundtrip(t, a)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if !got.Created.Equal(a.Created) || !bytes.Equal(got.PublicMainKey, a.PublicMainKey) || !bytes.Equal(got.AuditLog, a.AuditLog) || !bytes.Equal(got.PollKey, a.PollKey) {
+		t.Errorf("archive changed after roundtrip")
+	}
+
+	report, err := got.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if report.PollID != "1" || len(report.Result.Votes) != 1 || report.ResultHash != decrypt.Fingerprint(a.Envelope.Content) {
+		t.Errorf("got report %v", report)
+	}
+
+	if lines := bytes.Count(a.AuditLog, []byte("\n")); report.AuditEntries != lines || lines == 0 {
+		t.Errorf("got %d audit entries, expected %d", report.AuditEntries, lines)
+	}
+
+	t.Run("without optional files", func(t *testing.T) {
+		a := a
+		a.PollKey, a.PollKeySig, a.AuditLog, a.AuditLogSig = nil, nil, nil, nil
+
+		got, err := roundtrip(t, a)
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+
+		if _, err := got.Verify(); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+	})
+
+	for _, tt := range []struct {
+		name   string
+		modify func(archive.Archive) archive.Archive
+	}{
+		{
+			"other main key",
+			func(a archive.Archive) archive.Archive {
+				a.PublicMainKey = crypto.New(bytes.Repeat([]byte{1}, 32), rand.Reader, nil).PublicMainKey()
+				return a
+			},
+		},
+		{
+			"invalid poll key signature",
+			func(a archive.Archive) archive.Archive {
+				a.PollKeySig = a.AuditLogSig
+				return a
+			},
+		},
+		{
+			"changed audit log",
+			func(a archive.Archive) archive.Archive {
+				a.AuditLog = a.AuditLog[1:]
+				return a
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := roundtrip(t, tt.modify(a))
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+
+			if _, err := got.Verify(); err == nil {
+				t.Errorf("Verify did not return an error")
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(name string, content []byte) []byte
+		errMsg string
+	}{
+		{
+			"changed file",
+			func(name string, content []byte) []byte {
+				if name == "envelope.json" {
+					return append(content, ' ')
+				}
+				return content
+			},
+			"does not match",
+		},
+		{
+			"missing file",
+			func(name string, content []byte) []byte {
+				if name == "poll_key.sig" {
+					return nil
+				}
+				return content
+			},
+			"missing",
+		},
+		{
+			"unknown version",
+			func(name string, content []byte) []byte {
+				if name == "manifest.json" {
+					return bytes.Replace(content, []byte(`"version":1`), []byte(`"version":2`), 1)
+				}
+				return content
+			},
+			"unsupported archive version 2",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			content := rewrite(t, a, tt.modify)
+
+			_, err := archive.Read(bytes.NewReader(content), int64(len(content)))
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Read returned `%v`, expected an error with `%s`", err, tt.errMsg)
+			}
+		})
+	}
+}
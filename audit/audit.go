@@ -0,0 +1,206 @@
+// Package audit implements an append only log for security relevant events of
+// the decrypt service.
+//
+// Each event is written as one json object per line. Each line contains the
+// hash of the line before, so a removed or modified line breaks the chain. See
+// Verify().
+//
+// The entries can be mirrored to syslog or journald with WithSink().
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is one event in the audit log.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	PollID  string    `json:"poll_id,omitempty"`
+	Message string    `json:"message,omitempty"`
+
+	// Prev is the hex encoded sha256 hash of the line before without the
+	// newline. It is empty for the first line.
+	Prev string `json:"prev,omitempty"`
+}
+
+// maxLineSize is the maximum size of one line of the audit log.
+const maxLineSize = 1 << 20
+
+// File is an audit log that appends the events to a file.
+type File struct {
+	mu sync.Mutex
+
+	path  string
+	now   func() time.Time
+	sinks []Sink
+}
+
+// Option for New().
+type Option func(*File)
+
+// WithClock sets the source of the time of the events. Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(f *File) {
+		f.now = now
+	}
+}
+
+// New initializes an audit log that writes to the file at path.
+//
+// The file is created on the first event.
+func New(path string, options ...Option) *File {
+	f := &File{
+		path: path,
+		now:  time.Now,
+	}
+
+	for _, o := range options {
+		o(f)
+	}
+
+	return f
+}
+
+// Record appends an event to the audit log.
+//
+// The file is synced to disk before the function returns.
+func (f *File) Record(event, pollID, message string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+
+	defer func() {
+		if cErr := file.Close(); err == nil && cErr != nil {
+			err = fmt.Errorf("closing audit log: %w", cErr)
+		}
+	}()
+
+	prev, err := lastLine(file)
+	if err != nil {
+		return fmt.Errorf("reading last entry: %w", err)
+	}
+
+	entry := Entry{
+		Time:    f.now().UTC(),
+		Event:   event,
+		PollID:  pollID,
+		Message: message,
+	}
+	if prev != nil {
+		entry.Prev = lineHash(prev)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding entry: %w", err)
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing entry: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("syncing audit log: %w", err)
+	}
+
+	for _, sink := range f.sinks {
+		if err := sink.Send(entry); err != nil {
+			log.Printf("Error: mirroring audit event %s: %v", event, err)
+		}
+	}
+
+	return nil
+}
+
+// lastLine returns the last line of the file without the newline. It returns
+// nil, if the file is empty.
+func lastLine(file *os.File) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		return nil, nil
+	}
+
+	start := size - maxLineSize - 1
+	if start < 0 {
+		start = 0
+	}
+
+	tail := make([]byte, size-start)
+	if _, err := file.ReadAt(tail, start); err != nil {
+		return nil, err
+	}
+
+	tail = bytes.TrimSuffix(tail, []byte("\n"))
+	if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+		return tail[i+1:], nil
+	}
+
+	if start > 0 {
+		return nil, fmt.Errorf("last line is longer then %d bytes", maxLineSize)
+	}
+	return tail, nil
+}
+
+// lineHash returns the value of Entry.Prev for the line.
+func lineHash(line []byte) string {
+	hash := sha256.Sum256(line)
+	return hex.EncodeToString(hash[:])
+}
+
+// Events returns the names of all events of a poll in the order, they were
+// recorded.
+//
+// If the file does not exist, no events were recorded.
+func (f *File) Events(pollID string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var events []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxLineSize)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("decoding entry: %w", err)
+		}
+
+		if entry.PollID == pollID {
+			events = append(events, entry.Event)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+
+	return events, nil
+}
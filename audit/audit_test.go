@@ -0,0 +1,203 @@
+package audit_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/audit"
+)
+
+func TestRecord(t *testing.T) {
+	logFile := path.Join(t.TempDir(), "audit.log")
+	a := audit.New(logFile)
+
+	if err := a.Record("start", "test/1", ""); err != nil {
+		t.Fatalf("first record: %v", err)
+	}
+
+	if err := a.Record("stop", "test/1", "3 votes"); err != nil {
+		t.Fatalf("second record: %v", err)
+	}
+
+	f, err := os.Open(logFile)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var entries []audit.Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry audit.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("decoding line `%s`: %v", scanner.Bytes(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, expected 2", len(entries))
+	}
+
+	if entries[1].Event != "stop" || entries[1].PollID != "test/1" || entries[1].Message != "3 votes" {
+		t.Errorf("got entry %v, expected stop event for test/1", entries[1])
+	}
+}
+
+func TestRecordClock(t *testing.T) {
+	logFile := path.Join(t.TempDir(), "audit.log")
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a := audit.New(logFile, audit.WithClock(func() time.Time { return at }))
+
+	if err := a.Record("start", "test/1", ""); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("reading audit log: %v", err)
+	}
+
+	var entry audit.Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("decoding entry: %v", err)
+	}
+
+	if !entry.Time.Equal(at) {
+		t.Errorf("entry has time %s, expected %s", entry.Time, at)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	a := audit.New(path.Join(t.TempDir(), "audit.log"))
+
+	events, err := a.Events("test/1")
+	if err != nil {
+		t.Fatalf("Events without file: %v", err)
+	}
+
+	if len(events) != 0 {
+		t.Errorf("got events %v without file, expected none", events)
+	}
+
+	for _, record := range [][2]string{{"start", "test/1"}, {"start", "test/2"}, {"stop", "test/1"}} {
+		if err := a.Record(record[0], record[1], ""); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	events, err = a.Events("test/1")
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+
+	if len(events) != 2 || events[0] != "start" || events[1] != "stop" {
+		t.Errorf("got events %v, expected [start stop]", events)
+	}
+}
+
+func TestExport(t *testing.T) {
+	logFile := path.Join(t.TempDir(), "audit.log")
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a := audit.New(logFile, audit.WithClock(func() time.Time { return at }))
+
+	for _, record := range [][2]string{{"start", "test/1"}, {"start", "test/2"}, {"stop", "test/1"}, {"clear", "test/1"}} {
+		if err := a.Record(record[0], record[1], ""); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+		at = at.Add(time.Hour)
+	}
+
+	full, err := a.Export("", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("reading audit log: %v", err)
+	}
+
+	if string(full) != string(data) {
+		t.Errorf("full export differs from the file:\n%s\n%s", full, data)
+	}
+
+	t.Run("time range", func(t *testing.T) {
+		from := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+		part, err := a.Export("", from, from.Add(2*time.Hour))
+		if err != nil {
+			t.Fatalf("Export: %v", err)
+		}
+
+		if lines := strings.Split(strings.TrimSpace(string(part)), "\n"); len(lines) != 2 || !strings.Contains(lines[0], `"test/2"`) {
+			t.Errorf("got export %s, expected the second and third entry", part)
+		}
+
+		if err := audit.Verify(part); err != nil {
+			t.Errorf("Verify of time range: %v", err)
+		}
+	})
+
+	t.Run("poll", func(t *testing.T) {
+		part, err := a.Export("test/2", time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatalf("Export: %v", err)
+		}
+
+		if lines := strings.Split(strings.TrimSpace(string(part)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"start"`) {
+			t.Errorf("got export %s, expected the start event of test/2", part)
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	logFile := path.Join(t.TempDir(), "audit.log")
+	legacy := `{"time":"2024-05-01T12:00:00Z","event":"start","poll_id":"test/1"}` + "\n"
+	if err := os.WriteFile(logFile, []byte(legacy), 0600); err != nil {
+		t.Fatalf("writing legacy log: %v", err)
+	}
+
+	a := audit.New(logFile)
+	for _, event := range []string{"stop", "clear", "wipe"} {
+		if err := a.Record(event, "test/1", ""); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("reading audit log: %v", err)
+	}
+
+	if err := audit.Verify(data); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+
+	t.Run("removed line", func(t *testing.T) {
+		removed := strings.Join(append(lines[:2:2], lines[3:]...), "")
+		if err := audit.Verify([]byte(removed)); err == nil {
+			t.Errorf("Verify accepted a log with a removed line")
+		}
+	})
+
+	t.Run("modified line", func(t *testing.T) {
+		modified := strings.Replace(string(data), `"event":"clear"`, `"event":"start"`, 1)
+		if err := audit.Verify([]byte(modified)); err == nil {
+			t.Errorf("Verify accepted a log with a modified line")
+		}
+	})
+
+	t.Run("line without hash", func(t *testing.T) {
+		appended := string(data) + legacy
+		if err := audit.Verify([]byte(appended)); err == nil {
+			t.Errorf("Verify accepted a line without hash after the chain started")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Export returns the lines of the audit log, that belong to the poll and were
+// recorded between from (inclusive) and to (exclusive). An empty pollID and
+// zero times match all lines.
+//
+// The lines are returned unchanged, so the hash chain of an export without a
+// poll id can be checked with Verify().
+func (f *File) Export(pollID string, from, to time.Time) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var buf bytes.Buffer
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxLineSize)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("decoding entry: %w", err)
+		}
+
+		if pollID != "" && entry.PollID != pollID {
+			continue
+		}
+
+		if !from.IsZero() && entry.Time.Before(from) {
+			continue
+		}
+
+		if !to.IsZero() && !entry.Time.Before(to) {
+			continue
+		}
+
+		buf.Write(scanner.Bytes())
+		buf.WriteByte('\n')
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Verify checks the hash chain of an audit log or of a part of it, that was
+// exported without a poll id.
+//
+// The first line can not be checked, because the line before is not part of
+// the log. Lines without a hash at the beginning of the log were written by
+// an older version. They are accepted, but they are not protected. After the
+// first line with a hash, all lines need the correct hash.
+func Verify(log []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	scanner.Buffer(nil, maxLineSize)
+
+	var prev []byte
+	chained := false
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Bytes()
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("decoding line %d: %w", number, err)
+		}
+
+		if entry.Prev != "" {
+			if prev != nil && entry.Prev != lineHash(prev) {
+				return fmt.Errorf("line %d does not follow line %d", number, number-1)
+			}
+			chained = true
+		} else if chained {
+			return fmt.Errorf("line %d has no hash of line %d", number, number-1)
+		}
+
+		prev = append(prev[:0], line...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading audit log: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink receives a copy of each entry, after it was written to the file.
+//
+// Sinks mirror the audit log to other systems like a SIEM. The file stays the
+// authoritative log. An error of a sink is logged, but does not fail the event.
+type Sink interface {
+	Send(entry Entry) error
+}
+
+// WithSink adds a sink, that receives all entries of the file.
+func WithSink(sink Sink) Option {
+	return func(f *File) {
+		f.sinks = append(f.sinks, sink)
+	}
+}
+
+const (
+	// appName is the name of the service in syslog and journald.
+	appName = "vote-decrypt"
+
+	// facilityAuthPriv is the syslog facility for security messages.
+	facilityAuthPriv = 10
+
+	// severityNotice is the syslog severity of all audit events.
+	severityNotice = 5
+
+	// syslogSDID is the id of the structured data element of the syslog
+	// messages. 32473 is the private enterprise number for examples from RFC
+	// 5612.
+	syslogSDID = "audit@32473"
+)
+
+// Syslog is a sink, that sends the entries to a syslog server in the format of
+// RFC 5424.
+//
+// The fields of the entry are sent as structured data. The message is the json
+// encoded entry like in the file.
+type Syslog struct {
+	mu sync.Mutex
+
+	network  string
+	addr     string
+	hostname string
+	conn     net.Conn
+}
+
+// NewSyslog initializes a syslog sink.
+//
+// The address has the form udp://HOST:PORT, tcp://HOST:PORT or
+// unix:///PATH. Messages over tcp are framed with octet counting (RFC 6587).
+// The connection is opened on the first entry and opened again after an
+// error.
+func NewSyslog(address string) (*Syslog, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("parsing syslog address: %w", err)
+	}
+
+	var network, addr string
+	switch u.Scheme {
+	case "udp", "tcp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("syslog address `%s` has no host", address)
+		}
+		network, addr = u.Scheme, u.Host
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf("syslog address `%s` has no path", address)
+		}
+		network, addr = "unixgram", u.Path
+	default:
+		return nil, fmt.Errorf("unknown syslog network `%s`, expected udp, tcp or unix", u.Scheme)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &Syslog{
+		network:  network,
+		addr:     addr,
+		hostname: hostname,
+	}, nil
+}
+
+// Send sends one entry to the syslog server.
+func (s *Syslog) Send(entry Entry) error {
+	message, err := syslogMessage(entry, s.hostname, os.Getpid())
+	if err != nil {
+		return err
+	}
+
+	if s.network == "tcp" {
+		message = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(message); err != nil {
+		// The server could have closed the connection. Try once with a new
+		// one.
+		if err := s.write(message); err != nil {
+			return fmt.Errorf("sending to syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Syslog) write(message []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	if _, err := s.conn.Write(message); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection to the syslog server.
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// syslogMessage returns the entry as RFC 5424 message.
+func syslogMessage(entry Entry, hostname string, pid int) ([]byte, error) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("encoding entry: %w", err)
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	for _, param := range [][2]string{
+		{"event", entry.Event},
+		{"poll_id", entry.PollID},
+		{"message", entry.Message},
+		{"prev", entry.Prev},
+	} {
+		if param[1] == "" {
+			continue
+		}
+		fmt.Fprintf(&sd, " %s=\"%s\"", param[0], sdEscaper.Replace(param[1]))
+	}
+	sd.WriteString("]")
+
+	header := fmt.Sprintf(
+		"<%d>1 %s %s %s %d %s",
+		facilityAuthPriv*8+severityNotice,
+		entry.Time.UTC().Format(time.RFC3339Nano),
+		hostname,
+		appName,
+		pid,
+		syslogMsgID(entry.Event),
+	)
+
+	return []byte(header + " " + sd.String() + " " + string(line)), nil
+}
+
+// sdEscaper escapes the characters, that are not allowed in a value of the
+// structured data.
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogMsgID returns the event as MSGID. It can contain up to 32 printable
+// ascii characters without space.
+func syslogMsgID(event string) string {
+	id := []byte(event)
+	if len(id) > 32 {
+		id = id[:32]
+	}
+
+	for i, c := range id {
+		if c <= ' ' || c > '~' {
+			id[i] = '_'
+		}
+	}
+
+	if len(id) == 0 {
+		return "-"
+	}
+	return string(id)
+}
+
+// DefaultJournaldSocket is the socket of the native protocol of journald.
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// Journald is a sink, that sends the entries to the systemd journal with the
+// native journal protocol.
+//
+// The fields of the entry are sent as journal fields with the prefix
+// VOTE_DECRYPT_, for example VOTE_DECRYPT_POLL_ID.
+type Journald struct {
+	socket string
+}
+
+// NewJournald initializes a journald sink, that writes to the unix socket.
+// Use DefaultJournaldSocket for the journal of the host.
+func NewJournald(socket string) *Journald {
+	return &Journald{socket: socket}
+}
+
+// Send sends one entry to the journal.
+func (j *Journald) Send(entry Entry) error {
+	conn, err := net.Dial("unixgram", j.socket)
+	if err != nil {
+		return fmt.Errorf("connecting to journald: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(journalMessage(entry)); err != nil {
+		return fmt.Errorf("sending to journald: %w", err)
+	}
+	return nil
+}
+
+// journalMessage returns the entry in the native journal protocol.
+func journalMessage(entry Entry) []byte {
+	text := entry.Event
+	if entry.PollID != "" {
+		text += " " + entry.PollID
+	}
+	if entry.Message != "" {
+		text += ": " + entry.Message
+	}
+
+	var buf bytes.Buffer
+	for _, field := range [][2]string{
+		{"MESSAGE", text},
+		{"PRIORITY", fmt.Sprint(severityNotice)},
+		{"SYSLOG_FACILITY", fmt.Sprint(facilityAuthPriv)},
+		{"SYSLOG_IDENTIFIER", appName},
+		{"VOTE_DECRYPT_TIME", entry.Time.UTC().Format(time.RFC3339Nano)},
+		{"VOTE_DECRYPT_EVENT", entry.Event},
+		{"VOTE_DECRYPT_POLL_ID", entry.PollID},
+		{"VOTE_DECRYPT_MESSAGE", entry.Message},
+		{"VOTE_DECRYPT_PREV", entry.Prev},
+	} {
+		if field[1] == "" {
+			continue
+		}
+
+		if !strings.Contains(field[1], "\n") {
+			buf.WriteString(field[0] + "=" + field[1] + "\n")
+			continue
+		}
+
+		// Values with a newline are written with their size.
+		buf.WriteString(field[0] + "\n")
+		binary.Write(&buf, binary.LittleEndian, uint64(len(field[1])))
+		buf.WriteString(field[1] + "\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,141 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/audit"
+)
+
+type sinkMock struct {
+	entries []audit.Entry
+	err     error
+}
+
+func (s *sinkMock) Send(entry audit.Entry) error {
+	s.entries = append(s.entries, entry)
+	return s.err
+}
+
+func TestSink(t *testing.T) {
+	sink := &sinkMock{err: errors.New("sink is down")}
+	a := audit.New(path.Join(t.TempDir(), "audit.log"), audit.WithSink(sink))
+
+	for _, event := range []string{"start", "stop"} {
+		if err := a.Record(event, "test/1", ""); err != nil {
+			t.Fatalf("record %s with failing sink: %v", event, err)
+		}
+	}
+
+	if len(sink.entries) != 2 || sink.entries[1].Event != "stop" {
+		t.Fatalf("sink got %v, expected the start and stop event", sink.entries)
+	}
+
+	if sink.entries[1].Prev == "" {
+		t.Errorf("entry in the sink has no hash of the line before")
+	}
+
+	events, err := a.Events("test/1")
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+
+	if strings.Join(events, ",") != "start,stop" {
+		t.Errorf("got events %v in the file, expected start and stop", events)
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	syslog, err := audit.NewSyslog("udp://" + conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("NewSyslog: %v", err)
+	}
+	defer syslog.Close()
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a := audit.New(path.Join(t.TempDir(), "audit.log"), audit.WithClock(func() time.Time { return at }), audit.WithSink(syslog))
+
+	if err := a.Record("stop", "test/1", `3 "votes"`); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("reading syslog message: %v", err)
+	}
+	message := string(buf[:n])
+
+	prefix := "<85>1 2024-05-01T12:00:00Z "
+	if !strings.HasPrefix(message, prefix) {
+		t.Errorf("got message %s, expected prefix %s", message, prefix)
+	}
+
+	sd := ` stop [audit@32473 event="stop" poll_id="test/1" message="3 \"votes\""] `
+	if !strings.Contains(message, sd) {
+		t.Errorf("got message %s, expected structured data %s", message, sd)
+	}
+
+	var entry audit.Entry
+	if err := json.Unmarshal([]byte(message[strings.Index(message, "] ")+2:]), &entry); err != nil {
+		t.Fatalf("decoding json of message: %v", err)
+	}
+
+	if entry.PollID != "test/1" {
+		t.Errorf("got poll id %s in json, expected test/1", entry.PollID)
+	}
+
+	t.Run("invalid address", func(t *testing.T) {
+		for _, address := range []string{"", "udp://", "unix://", "http://localhost:514"} {
+			if _, err := audit.NewSyslog(address); err == nil {
+				t.Errorf("NewSyslog(%q) did not return an error", address)
+			}
+		}
+	})
+}
+
+func TestJournald(t *testing.T) {
+	socket := path.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenPacket("unixgram", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	a := audit.New(path.Join(t.TempDir(), "audit.log"), audit.WithSink(audit.NewJournald(socket)))
+
+	if err := a.Record("revoke", "test/1", "key\nleaked"); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("reading journal message: %v", err)
+	}
+	message := string(buf[:n])
+
+	for _, field := range []string{
+		"SYSLOG_IDENTIFIER=vote-decrypt\n",
+		"VOTE_DECRYPT_EVENT=revoke\n",
+		"VOTE_DECRYPT_POLL_ID=test/1\n",
+		"VOTE_DECRYPT_MESSAGE\n\x0a\x00\x00\x00\x00\x00\x00\x00key\nleaked\n",
+	} {
+		if !strings.Contains(message, field) {
+			t.Errorf("journal message %q does not contain %q", message, field)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package bench measures the throughput of the decrypt pipeline.
+//
+// Run generates synthetic encrypted votes for some polls and stops the polls
+// with the real decrypt component, crypto and store.
+//
+// LoadTest runs polls against a remote service, to measure the latency
+// including the network and the store of the service.
+package bench
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+)
+
+// Config is the configuration of a benchmark run.
+type Config struct {
+	// Votes is the number of votes per poll.
+	Votes int
+
+	// VoteSize is the size of one plaintext vote in bytes.
+	VoteSize int
+
+	// Polls is the number of polls, that are stopped one after another.
+	Polls int
+
+	// Workers is the number of decrypt workers. 0 means GOMAXPROCS.
+	Workers int
+
+	// AutoTune tunes the worker pool. Workers is the maximum number of workers.
+	AutoTune bool
+}
+
+// Result is the outcome of a benchmark run.
+type Result struct {
+	Votes    int           // Number of decrypted votes of all polls.
+	Duration time.Duration // Time for all stop calls.
+
+	Latency Latencies // Latencies of one stop call.
+
+	Allocs     uint64 // Number of heap allocations during all stop calls.
+	AllocBytes uint64 // Allocated bytes during all stop calls.
+}
+
+// VotesPerSecond returns the throughput.
+func (r Result) VotesPerSecond() float64 {
+	if r.Duration == 0 {
+		return 0
+	}
+	return float64(r.Votes) / r.Duration.Seconds()
+}
+
+// String returns a human readable report.
+func (r Result) String() string {
+	return fmt.Sprintf(
+		"votes: %d\nduration: %s\nthroughput: %.0f votes/s\nlatency per poll: %s\nallocations: %d (%d bytes, %d per vote)",
+		r.Votes,
+		r.Duration,
+		r.VotesPerSecond(),
+		r.Latency,
+		r.Allocs, r.AllocBytes, r.Allocs/uint64(max(r.Votes, 1)),
+	)
+}
+
+// Run runs the benchmark with the given store.
+//
+// The encryption of the votes is not measured.
+func Run(ctx context.Context, store decrypt.Store, config Config) (Result, error) {
+	if config.Votes < 1 || config.Polls < 1 {
+		return Result{}, fmt.Errorf("votes and polls have to be at least 1")
+	}
+
+	mainKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, mainKey); err != nil {
+		return Result{}, fmt.Errorf("creating main key: %w", err)
+	}
+
+	options := []decrypt.Option{
+		decrypt.WithAuditLog(discardAuditLog{}),
+	}
+	if config.Workers > 0 {
+		options = append(options, decrypt.WithDecryptWorkers(config.Workers))
+	}
+	if config.AutoTune {
+		options = append(options, decrypt.WithAutoTune())
+	}
+
+	d := decrypt.New(crypto.New(mainKey, rand.Reader, nil), store, options...)
+
+	runID := make([]byte, 4)
+	if _, err := io.ReadFull(rand.Reader, runID); err != nil {
+		return Result{}, fmt.Errorf("creating run id: %w", err)
+	}
+
+	polls := make(map[string][][]byte, config.Polls)
+	for i := 0; i < config.Polls; i++ {
+		pollID := fmt.Sprintf("bench/%x/%d", runID, i)
+		votes, err := createVotes(ctx, d, pollID, config.Votes, config.VoteSize)
+		if err != nil {
+			return Result{}, fmt.Errorf("creating votes for poll %s: %w", pollID, err)
+		}
+		polls[pollID] = votes
+	}
+
+	defer func() {
+		for pollID := range polls {
+			d.Clear(ctx, pollID)
+		}
+	}()
+
+	var memBefore, memAfter runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&memBefore)
+
+	latencies := make([]time.Duration, 0, config.Polls)
+	start := time.Now()
+	for pollID, votes := range polls {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+
+		stopStart := time.Now()
+		if _, _, err := d.Stop(ctx, pollID, votes); err != nil {
+			return Result{}, fmt.Errorf("stopping poll %s: %w", pollID, err)
+		}
+		latencies = append(latencies, time.Since(stopStart))
+	}
+	duration := time.Since(start)
+
+	runtime.ReadMemStats(&memAfter)
+
+	return Result{
+		Votes:      config.Votes * config.Polls,
+		Duration:   duration,
+		Latency:    newLatencies(latencies),
+		Allocs:     memAfter.Mallocs - memBefore.Mallocs,
+		AllocBytes: memAfter.TotalAlloc - memBefore.TotalAlloc,
+	}, nil
+}
+
+// createVotes starts a poll and encrypts synthetic votes with its public key.
+func createVotes(ctx context.Context, d *decrypt.Decrypt, pollID string, count, size int) ([][]byte, error) {
+	pubKey, _, err := d.Start(ctx, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("starting poll: %w", err)
+	}
+
+	return encryptVotes(pubKey, count, size)
+}
+
+// encryptVotes creates count synthetic votes of the given size encrypted with
+// the public poll key. A public key with 65 bytes is a P-256 key of a server in
+// fips mode.
+func encryptVotes(pubKey []byte, count, size int) ([][]byte, error) {
+	curve := ecdh.X25519()
+	if len(pubKey) == 65 {
+		curve = ecdh.P256()
+	}
+
+	// The votes have to be valid json, so use a json string of the given size.
+	plaintext := make([]byte, max(size, 2))
+	for i := range plaintext {
+		plaintext[i] = 'Y'
+	}
+	plaintext[0] = '"'
+	plaintext[len(plaintext)-1] = '"'
+
+	votes := make([][]byte, count)
+	for i := range votes {
+		encrypted, err := crypto.Encrypt(rand.Reader, curve, pubKey, plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("encrypting vote: %w", err)
+		}
+		votes[i] = encrypted
+	}
+	return votes, nil
+}
+
+// Latencies are the percentiles of measured durations.
+type Latencies struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+func newLatencies(values []time.Duration) Latencies {
+	if len(values) == 0 {
+		return Latencies{}
+	}
+
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return Latencies{
+		P50: percentile(sorted, 50),
+		P90: percentile(sorted, 90),
+		P99: percentile(sorted, 99),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+func (l Latencies) String() string {
+	return fmt.Sprintf("p50=%s p90=%s p99=%s max=%s", l.P50, l.P90, l.P99, l.Max)
+}
+
+// percentile returns the p-th percentile of sorted values.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	idx := (len(sorted)*p+99)/100 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+type discardAuditLog struct{}
+
+func (discardAuditLog) Record(event, pollID, message string) error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package bench_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/bench"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/store"
+)
+
+func TestRun(t *testing.T) {
+	backend := store.New(t.TempDir())
+
+	result, err := bench.Run(context.Background(), backend, bench.Config{
+		Votes:    20,
+		VoteSize: 10,
+		Polls:    3,
+		Workers:  2,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if result.Votes != 60 {
+		t.Errorf("got %d votes, expected 60", result.Votes)
+	}
+
+	if result.Latency.P50 > result.Latency.Max || result.Latency.Max > result.Duration {
+		t.Errorf("inconsistent latencies: %+v", result)
+	}
+
+	ids, err := backend.ListPolls()
+	if err != nil {
+		t.Fatalf("ListPolls: %v", err)
+	}
+
+	if len(ids) != 0 {
+		t.Errorf("store has polls %v after the benchmark", ids)
+	}
+}
+
+func TestLoadTest(t *testing.T) {
+	backend := store.New(t.TempDir())
+	cr := crypto.New(make([]byte, 32), rand.Reader, nil)
+	d := decrypt.New(cr, backend)
+
+	result, err := bench.LoadTest(context.Background(), d, cr.PublicMainKey(), bench.LoadConfig{
+		Polls:       6,
+		Concurrency: 3,
+		Votes:       10,
+		VoteSize:    10,
+	})
+	if err != nil {
+		t.Fatalf("LoadTest: %v", err)
+	}
+
+	if result.Errors != 0 {
+		t.Errorf("got %d errors: %v", result.Errors, result.FirstError)
+	}
+
+	if result.Polls != 6 {
+		t.Errorf("got %d polls, expected 6", result.Polls)
+	}
+
+	ids, err := backend.ListPolls()
+	if err != nil {
+		t.Fatalf("ListPolls: %v", err)
+	}
+
+	if len(ids) != 0 {
+		t.Errorf("store has polls %v after the load test", ids)
+	}
+}
+
+func TestLoadTestWrongMainKey(t *testing.T) {
+	d := decrypt.New(crypto.New(make([]byte, 32), rand.Reader, nil), store.New(t.TempDir()))
+	otherKey := crypto.New([]byte("00000000000000000000000000000000"), rand.Reader, nil).PublicMainKey()
+
+	result, err := bench.LoadTest(context.Background(), d, otherKey, bench.LoadConfig{
+		Polls: 2,
+		Votes: 1,
+	})
+	if err != nil {
+		t.Fatalf("LoadTest: %v", err)
+	}
+
+	if result.Errors != 2 || result.FirstError == nil {
+		t.Errorf("got %d errors, expected 2", result.Errors)
+	}
+}
+
+func TestMeasurePrimitives(t *testing.T) {
+	result, err := bench.MeasurePrimitives(time.Millisecond, 100)
+	if err != nil {
+		t.Fatalf("MeasurePrimitives: %v", err)
+	}
+
+	if len(result) != 3 {
+		t.Fatalf("got %d primitives, expected 3", len(result))
+	}
+
+	for _, primitive := range result {
+		if primitive.Operations == 0 || primitive.PerSecond() == 0 {
+			t.Errorf("primitive %s was not measured", primitive.Name)
+		}
+	}
+}
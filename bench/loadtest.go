@@ -0,0 +1,202 @@
+package bench
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+)
+
+// Service is a running vote-decrypt service. It is implemented by the grpc
+// client and by the decrypt component.
+type Service interface {
+	Start(ctx context.Context, pollID string, options ...decrypt.StartOption) (pubKey []byte, pubKeySig []byte, err error)
+	Stop(ctx context.Context, pollID string, voteList [][]byte, options ...decrypt.StopOption) (decryptedContent, signature []byte, err error)
+	Clear(ctx context.Context, pollID string) error
+}
+
+// LoadConfig is the configuration of a load test.
+type LoadConfig struct {
+	// Polls is the number of polls to run.
+	Polls int
+
+	// Concurrency is the number of polls that run at the same time.
+	Concurrency int
+
+	// Votes is the number of votes per poll.
+	Votes int
+
+	// VoteSize is the size of one plaintext vote in bytes.
+	VoteSize int
+}
+
+// LoadResult is the outcome of a load test.
+type LoadResult struct {
+	Polls    int           // Number of successful polls.
+	Errors   int           // Number of failed polls.
+	Duration time.Duration // Time for all polls.
+
+	Start      Latencies // Latencies of the start calls.
+	Stop       Latencies // Latencies of the stop calls.
+	Clear      Latencies // Latencies of the clear calls.
+	EndToEnd   Latencies // Latencies of a poll from start to clear without the encryption.
+	FirstError error     // The first error, if any.
+}
+
+// String returns a human readable report.
+func (r LoadResult) String() string {
+	report := fmt.Sprintf(
+		"polls: %d\nerrors: %d\nduration: %s\nstart: %s\nstop: %s\nclear: %s\nend to end: %s",
+		r.Polls,
+		r.Errors,
+		r.Duration,
+		r.Start,
+		r.Stop,
+		r.Clear,
+		r.EndToEnd,
+	)
+
+	if r.FirstError != nil {
+		report += fmt.Sprintf("\nfirst error: %v", r.FirstError)
+	}
+	return report
+}
+
+// pollTimes are the durations of the calls for one poll.
+type pollTimes struct {
+	start time.Duration
+	stop  time.Duration
+	clear time.Duration
+}
+
+// LoadTest runs polls against the service.
+//
+// Each poll is started, the votes are encrypted with the public poll key and
+// the poll is stopped and cleared. The signatures are verified with the public
+// main key of the service.
+func LoadTest(ctx context.Context, service Service, publicMainKey []byte, config LoadConfig) (LoadResult, error) {
+	if config.Polls < 1 || config.Votes < 1 {
+		return LoadResult{}, fmt.Errorf("votes and polls have to be at least 1")
+	}
+
+	concurrency := max(config.Concurrency, 1)
+
+	runID := make([]byte, 4)
+	if _, err := io.ReadFull(rand.Reader, runID); err != nil {
+		return LoadResult{}, fmt.Errorf("creating run id: %w", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		times    []pollTimes
+		errCount int
+		firstErr error
+	)
+
+	pollIDs := make(chan string)
+	go func() {
+		defer close(pollIDs)
+		for i := 0; i < config.Polls; i++ {
+			select {
+			case pollIDs <- fmt.Sprintf("loadtest/%x/%d", runID, i):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for pollID := range pollIDs {
+				t, err := runPoll(ctx, service, publicMainKey, pollID, config.Votes, config.VoteSize)
+
+				mu.Lock()
+				if err != nil {
+					errCount++
+					if firstErr == nil {
+						firstErr = fmt.Errorf("poll %s: %w", pollID, err)
+					}
+				} else {
+					times = append(times, t)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	duration := time.Since(start)
+
+	if err := ctx.Err(); err != nil {
+		return LoadResult{}, err
+	}
+
+	var startTimes, stopTimes, clearTimes, endToEnd []time.Duration
+	for _, t := range times {
+		startTimes = append(startTimes, t.start)
+		stopTimes = append(stopTimes, t.stop)
+		clearTimes = append(clearTimes, t.clear)
+		endToEnd = append(endToEnd, t.start+t.stop+t.clear)
+	}
+
+	return LoadResult{
+		Polls:      len(times),
+		Errors:     errCount,
+		Duration:   duration,
+		Start:      newLatencies(startTimes),
+		Stop:       newLatencies(stopTimes),
+		Clear:      newLatencies(clearTimes),
+		EndToEnd:   newLatencies(endToEnd),
+		FirstError: firstErr,
+	}, nil
+}
+
+// runPoll runs one poll and returns the durations of the calls.
+func runPoll(ctx context.Context, service Service, mainKey []byte, pollID string, voteCount, voteSize int) (pollTimes, error) {
+	var t pollTimes
+
+	startTime := time.Now()
+	pubKey, pubKeySig, err := service.Start(ctx, pollID)
+	if err != nil {
+		return pollTimes{}, fmt.Errorf("starting: %w", err)
+	}
+	t.start = time.Since(startTime)
+
+	if !crypto.Verify(mainKey, pubKey, pubKeySig) {
+		return pollTimes{}, fmt.Errorf("invalid signature of the poll key")
+	}
+
+	votes, err := encryptVotes(pubKey, voteCount, voteSize)
+	if err != nil {
+		return pollTimes{}, err
+	}
+
+	stopTime := time.Now()
+	content, signature, err := service.Stop(ctx, pollID, votes)
+	if err != nil {
+		return pollTimes{}, fmt.Errorf("stopping: %w", err)
+	}
+	t.stop = time.Since(stopTime)
+
+	if !crypto.Verify(mainKey, content, signature) {
+		return pollTimes{}, fmt.Errorf("invalid signature of the result")
+	}
+
+	clearTime := time.Now()
+	if err := service.Clear(ctx, pollID); err != nil {
+		return pollTimes{}, fmt.Errorf("clearing: %w", err)
+	}
+	t.clear = time.Since(clearTime)
+
+	return t, nil
+}
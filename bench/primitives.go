@@ -0,0 +1,128 @@
+package bench
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Primitive is the throughput of one cryptographic primitive.
+type Primitive struct {
+	Name       string
+	Operations int
+	Duration   time.Duration
+}
+
+// PerSecond returns the operations per second.
+func (p Primitive) PerSecond() float64 {
+	if p.Duration == 0 {
+		return 0
+	}
+	return float64(p.Operations) / p.Duration.Seconds()
+}
+
+// Primitives is the result of MeasurePrimitives().
+type Primitives []Primitive
+
+// String returns a human readable report.
+func (p Primitives) String() string {
+	var b strings.Builder
+	for i, primitive := range p {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%s: %.0f ops/s", primitive.Name, primitive.PerSecond())
+	}
+	return b.String()
+}
+
+// MeasurePrimitives measures the primitives, that are used to decrypt one
+// vote: the key agreement with X25519 and P-256 and opening a vote of voteSize
+// bytes with aes-256-gcm. Each primitive runs for about the given duration.
+func MeasurePrimitives(duration time.Duration, voteSize int) (Primitives, error) {
+	var result Primitives
+	for _, curve := range []struct {
+		name  string
+		curve ecdh.Curve
+	}{
+		{"x25519", ecdh.X25519()},
+		{"p-256", ecdh.P256()},
+	} {
+		primitive, err := measureECDH(curve.name, curve.curve, duration)
+		if err != nil {
+			return nil, fmt.Errorf("measuring %s: %w", curve.name, err)
+		}
+		result = append(result, primitive)
+	}
+
+	primitive, err := measureAESGCM(duration, voteSize)
+	if err != nil {
+		return nil, fmt.Errorf("measuring aes-256-gcm: %w", err)
+	}
+	return append(result, primitive), nil
+}
+
+func measureECDH(name string, curve ecdh.Curve, duration time.Duration) (Primitive, error) {
+	private, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return Primitive{}, fmt.Errorf("generating key: %w", err)
+	}
+
+	remote, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return Primitive{}, fmt.Errorf("generating key: %w", err)
+	}
+
+	return measure(name, duration, func() error {
+		_, err := private.ECDH(remote.PublicKey())
+		return err
+	})
+}
+
+func measureAESGCM(duration time.Duration, voteSize int) (Primitive, error) {
+	key := make([]byte, 32)
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(key); err != nil {
+		return Primitive{}, fmt.Errorf("creating key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return Primitive{}, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	mode, err := cipher.NewGCM(block)
+	if err != nil {
+		return Primitive{}, fmt.Errorf("creating gcm mode: %w", err)
+	}
+
+	sealed := mode.Seal(nil, nonce, make([]byte, voteSize), nil)
+	buf := make([]byte, 0, voteSize)
+
+	return measure(fmt.Sprintf("aes-256-gcm (%d bytes)", voteSize), duration, func() error {
+		_, err := mode.Open(buf[:0], nonce, sealed, nil)
+		return err
+	})
+}
+
+// measure calls f until the duration is over.
+func measure(name string, duration time.Duration, f func() error) (Primitive, error) {
+	primitive := Primitive{Name: name}
+	start := time.Now()
+	for primitive.Duration < duration {
+		// Check the time only every 100 calls, so it does not dominate fast
+		// primitives.
+		for i := 0; i < 100; i++ {
+			if err := f(); err != nil {
+				return Primitive{}, err
+			}
+		}
+		primitive.Operations += 100
+		primitive.Duration = time.Since(start)
+	}
+	return primitive, nil
+}
@@ -0,0 +1,160 @@
+// Package certificate wraps the public main key in X.509 certificates.
+//
+// A certificate for the main key can be self-signed or issued by a
+// certificate authority from a certificate request. If the decrypt service is
+// configured with a certificate chain, it sends the chain with the public main
+// key and the results. Consumers can then verify the results with the trust
+// store of their organization instead of a pinned public main key.
+package certificate
+
+import (
+	stdcrypto "crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+)
+
+// SelfSigned creates a self-signed certificate for the main key. It returns
+// the certificate in the DER form.
+func SelfSigned(key stdcrypto.Signer, commonName string, validity time.Duration) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("creating serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("creating certificate: %w", err)
+	}
+	return der, nil
+}
+
+// Request creates a certificate request for the main key, that can be sent to
+// a certificate authority. It returns the request in the DER form.
+func Request(key stdcrypto.Signer, commonName string) ([]byte, error) {
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName},
+	}, key)
+	if err != nil {
+		return nil, fmt.Errorf("creating certificate request: %w", err)
+	}
+	return der, nil
+}
+
+// ParsePEM returns the certificates of PEM encoded data in the DER form. The
+// first certificate has to be the certificate of the main key, the others are
+// the intermediate certificates of the chain.
+func ParsePEM(data []byte) ([][]byte, error) {
+	var chain [][]byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected pem block %s", block.Type)
+		}
+
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("parsing certificate %d: %w", len(chain)+1, err)
+		}
+		chain = append(chain, block.Bytes)
+	}
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return chain, nil
+}
+
+// EncodePEM returns DER encoded certificates in the PEM form.
+func EncodePEM(chain [][]byte) []byte {
+	var encoded []byte
+	for _, der := range chain {
+		encoded = append(encoded, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	return encoded
+}
+
+// PublicKey returns the key of a DER encoded certificate in the encoding of
+// the public main key.
+func PublicKey(der []byte) ([]byte, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+
+	return crypto.EncodePublicKey(cert.PublicKey)
+}
+
+// Check returns an error, if the first certificate of the chain is not a
+// certificate for the public main key.
+func Check(chain [][]byte, publicMainKey []byte) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("empty certificate chain")
+	}
+
+	key, err := PublicKey(chain[0])
+	if err != nil {
+		return err
+	}
+
+	if string(key) != string(publicMainKey) {
+		return fmt.Errorf("certificate is not for the public main key")
+	}
+	return nil
+}
+
+// Verify checks, that the chain is valid at the given time and issued by one
+// of the roots. It returns the public main key from the first certificate.
+//
+// If roots is nil, the trust store of the system is used.
+func Verify(chain [][]byte, roots *x509.CertPool, at time.Time) ([]byte, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("empty certificate chain")
+	}
+
+	certs := make([]*x509.Certificate, len(chain))
+	for i, der := range chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate %d: %w", i+1, err)
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, fmt.Errorf("verifying certificate chain: %w", err)
+	}
+
+	return crypto.EncodePublicKey(certs[0].PublicKey)
+}
@@ -0,0 +1,137 @@
+package certificate_test
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/certificate"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+)
+
+func TestSelfSigned(t *testing.T) {
+	mainKey := []byte("12345678901234567890123456789012")
+
+	for _, fips := range []bool{false, true} {
+		key, err := crypto.PrivateMainKey(mainKey, fips)
+		if err != nil {
+			t.Fatalf("PrivateMainKey: %v", err)
+		}
+
+		cr, err := localCrypto(mainKey, fips)
+		if err != nil {
+			t.Fatalf("initializing crypto: %v", err)
+		}
+
+		der, err := certificate.SelfSigned(key, "vote-decrypt", time.Hour)
+		if err != nil {
+			t.Fatalf("SelfSigned: %v", err)
+		}
+
+		chain, err := certificate.ParsePEM(certificate.EncodePEM([][]byte{der}))
+		if err != nil {
+			t.Fatalf("ParsePEM: %v", err)
+		}
+
+		if err := certificate.Check(chain, cr.PublicMainKey()); err != nil {
+			t.Errorf("Check with fips=%t: %v", fips, err)
+		}
+
+		if err := certificate.Check(chain, []byte("other key")); err == nil {
+			t.Errorf("Check with another key did not fail")
+		}
+
+		roots := x509.NewCertPool()
+		cert, _ := x509.ParseCertificate(der)
+		roots.AddCert(cert)
+
+		publicMainKey, err := certificate.Verify(chain, roots, time.Now())
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+
+		if string(publicMainKey) != string(cr.PublicMainKey()) {
+			t.Errorf("Verify returned key %x, expected %x", publicMainKey, cr.PublicMainKey())
+		}
+
+		if _, err := certificate.Verify(chain, roots, time.Now().Add(2*time.Hour)); err == nil {
+			t.Errorf("Verify of an expired certificate did not fail")
+		}
+	}
+}
+
+func TestRequest(t *testing.T) {
+	mainKey := []byte("12345678901234567890123456789012")
+	key, err := crypto.PrivateMainKey(mainKey, false)
+	if err != nil {
+		t.Fatalf("PrivateMainKey: %v", err)
+	}
+
+	caKey, err := crypto.PrivateMainKey([]byte("ca-key-ca-key-ca-key-ca-key-ca-k"), true)
+	if err != nil {
+		t.Fatalf("creating ca key: %v", err)
+	}
+
+	caDER, err := certificate.SelfSigned(caKey, "test ca", time.Hour)
+	if err != nil {
+		t.Fatalf("creating ca: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	requestDER, err := certificate.Request(key, "vote-decrypt")
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+
+	request, err := x509.ParseCertificateRequest(requestDER)
+	if err != nil {
+		t.Fatalf("parsing request: %v", err)
+	}
+
+	if err := request.CheckSignature(); err != nil {
+		t.Fatalf("signature of the request: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: request.Subject.CommonName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leaf, err := x509.CreateCertificate(rand.Reader, template, ca, request.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("issuing certificate: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	publicMainKey, err := certificate.Verify([][]byte{leaf}, roots, time.Now())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if string(publicMainKey) != string(crypto.New(mainKey, rand.Reader, nil).PublicMainKey()) {
+		t.Errorf("Verify returned another key")
+	}
+
+	if _, err := certificate.Verify([][]byte{leaf}, x509.NewCertPool(), time.Now()); err == nil {
+		t.Errorf("Verify without the ca did not fail")
+	}
+}
+
+func localCrypto(mainKey []byte, fips bool) (crypto.Crypto, error) {
+	if !fips {
+		return crypto.New(mainKey, rand.Reader, nil), nil
+	}
+
+	signer, err := crypto.NewECDSASigner(mainKey, rand.Reader)
+	if err != nil {
+		return crypto.Crypto{}, err
+	}
+	return crypto.NewWithSigner(signer, rand.Reader, nil), nil
+}
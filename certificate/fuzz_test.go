@@ -0,0 +1,41 @@
+package certificate_test
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/certificate"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+)
+
+// FuzzParsePEM checks, that the certificate file of the main key does not
+// panic. Run it with `go test ./certificate -fuzz FuzzParsePEM`.
+func FuzzParsePEM(f *testing.F) {
+	mainKey := []byte("12345678901234567890123456789012")
+	key, err := crypto.PrivateMainKey(mainKey, false)
+	if err != nil {
+		f.Fatalf("PrivateMainKey: %v", err)
+	}
+
+	der, err := certificate.SelfSigned(key, "vote-decrypt", time.Hour)
+	if err != nil {
+		f.Fatalf("SelfSigned: %v", err)
+	}
+
+	f.Add(certificate.EncodePEM([][]byte{der}))
+	f.Add([]byte("-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"))
+	f.Add([]byte{})
+
+	publicMainKey := crypto.New(mainKey, nil, nil).PublicMainKey()
+	roots := x509.NewCertPool()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		chain, err := certificate.ParsePEM(data)
+		if err != nil {
+			return
+		}
+
+		certificate.Check(chain, publicMainKey)
+		certificate.Verify(chain, roots, time.Now())
+	})
+}
@@ -0,0 +1,249 @@
+// Package chaos injects faults into the decrypt service.
+//
+// It is meant for chaos and soak tests, to exercise error paths that are
+// otherwise unreachable. It must not be used in production.
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+)
+
+// ErrInjected is the error returned by injected faults.
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Config configures the injected faults.
+type Config struct {
+	// StoreLatency is added to each store call.
+	StoreLatency time.Duration
+
+	// StoreErrorRate is the probability between 0 and 1, that a store call
+	// fails.
+	StoreErrorRate float64
+
+	// RandomErrorRate is the probability between 0 and 1, that a read from
+	// the random source fails.
+	RandomErrorRate float64
+
+	// Seed for the decision, which calls fail. 0 means a random seed.
+	Seed int64
+}
+
+// Chaos decides which calls fail.
+type Chaos struct {
+	config Config
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// New initializes a Chaos object.
+func New(config Config) *Chaos {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &Chaos{
+		config: config,
+		random: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// fail returns true with the probability rate.
+func (c *Chaos) fail(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.random.Float64() < rate
+}
+
+// storeCall is called before each call to the store.
+func (c *Chaos) storeCall(method string) error {
+	if c.config.StoreLatency > 0 {
+		time.Sleep(c.config.StoreLatency)
+	}
+
+	if c.fail(c.config.StoreErrorRate) {
+		return fmt.Errorf("store.%s: %w", method, ErrInjected)
+	}
+	return nil
+}
+
+// Random wraps a random source. Reads fail with the configured
+// RandomErrorRate.
+func (c *Chaos) Random(r io.Reader) io.Reader {
+	return randomReader{chaos: c, reader: r}
+}
+
+type randomReader struct {
+	chaos  *Chaos
+	reader io.Reader
+}
+
+func (r randomReader) Read(p []byte) (int, error) {
+	if r.chaos.fail(r.chaos.config.RandomErrorRate) {
+		return 0, fmt.Errorf("random: %w", ErrInjected)
+	}
+	return r.reader.Read(p)
+}
+
+// Store wraps a store. Each call is delayed by the configured StoreLatency and
+// fails with the configured StoreErrorRate.
+//
+// A failing call does not reach the wrapped store.
+func (c *Chaos) Store(store decrypt.Store) decrypt.Store {
+	return chaosStore{chaos: c, store: store}
+}
+
+type chaosStore struct {
+	chaos *Chaos
+	store decrypt.Store
+}
+
+func (s chaosStore) SaveKey(id string, key []byte) error {
+	if err := s.chaos.storeCall("SaveKey"); err != nil {
+		return err
+	}
+	return s.store.SaveKey(id, key)
+}
+
+func (s chaosStore) LoadKey(id string) ([]byte, error) {
+	if err := s.chaos.storeCall("LoadKey"); err != nil {
+		return nil, err
+	}
+	return s.store.LoadKey(id)
+}
+
+func (s chaosStore) ValidateSignature(id string, hash []byte) error {
+	if err := s.chaos.storeCall("ValidateSignature"); err != nil {
+		return err
+	}
+	return s.store.ValidateSignature(id, hash)
+}
+
+func (s chaosStore) LoadHash(id string) ([]byte, error) {
+	if err := s.chaos.storeCall("LoadHash"); err != nil {
+		return nil, err
+	}
+
+	hashStore, ok := s.store.(decrypt.HashStore)
+	if !ok {
+		return nil, fmt.Errorf("store can not load hashes: %w", errorcode.Unsupported)
+	}
+	return hashStore.LoadHash(id)
+}
+
+func (s chaosStore) SaveMeta(id string, meta []byte) error {
+	if err := s.chaos.storeCall("SaveMeta"); err != nil {
+		return err
+	}
+	return s.store.SaveMeta(id, meta)
+}
+
+func (s chaosStore) LoadMeta(id string) ([]byte, error) {
+	if err := s.chaos.storeCall("LoadMeta"); err != nil {
+		return nil, err
+	}
+	return s.store.LoadMeta(id)
+}
+
+func (s chaosStore) SaveCommitment(id string, leaves []byte) error {
+	if err := s.chaos.storeCall("SaveCommitment"); err != nil {
+		return err
+	}
+	return s.store.SaveCommitment(id, leaves)
+}
+
+func (s chaosStore) LoadCommitment(id string) ([]byte, error) {
+	if err := s.chaos.storeCall("LoadCommitment"); err != nil {
+		return nil, err
+	}
+	return s.store.LoadCommitment(id)
+}
+
+func (s chaosStore) ClearPoll(id string) error {
+	if err := s.chaos.storeCall("ClearPoll"); err != nil {
+		return err
+	}
+	return s.store.ClearPoll(id)
+}
+
+func (s chaosStore) ListPolls() ([]string, error) {
+	if err := s.chaos.storeCall("ListPolls"); err != nil {
+		return nil, err
+	}
+	return s.store.ListPolls()
+}
+
+func (s chaosStore) ScheduleClear(id string, at time.Time) error {
+	if err := s.chaos.storeCall("ScheduleClear"); err != nil {
+		return err
+	}
+	return s.store.ScheduleClear(id, at)
+}
+
+func (s chaosStore) ScheduledClears() (map[string]time.Time, error) {
+	if err := s.chaos.storeCall("ScheduledClears"); err != nil {
+		return nil, err
+	}
+	return s.store.ScheduledClears()
+}
+
+func (s chaosStore) SaveRevocation(revocation []byte) error {
+	if err := s.chaos.storeCall("SaveRevocation"); err != nil {
+		return err
+	}
+
+	revocationStore, ok := s.store.(decrypt.RevocationStore)
+	if !ok {
+		return fmt.Errorf("store can not save revocations: %w", errorcode.Unsupported)
+	}
+	return revocationStore.SaveRevocation(revocation)
+}
+
+func (s chaosStore) Revocations() ([][]byte, error) {
+	if err := s.chaos.storeCall("Revocations"); err != nil {
+		return nil, err
+	}
+
+	revocationStore, ok := s.store.(decrypt.RevocationStore)
+	if !ok {
+		return nil, nil
+	}
+	return revocationStore.Revocations()
+}
+
+func (s chaosStore) SaveReplacementKey(id string, key []byte) error {
+	if err := s.chaos.storeCall("SaveReplacementKey"); err != nil {
+		return err
+	}
+
+	rekeyStore, ok := s.store.(decrypt.RekeyStore)
+	if !ok {
+		return fmt.Errorf("store can not save replacement keys: %w", errorcode.Unsupported)
+	}
+	return rekeyStore.SaveReplacementKey(id, key)
+}
+
+func (s chaosStore) LoadReplacementKeys(id string) ([][]byte, error) {
+	if err := s.chaos.storeCall("LoadReplacementKeys"); err != nil {
+		return nil, err
+	}
+
+	rekeyStore, ok := s.store.(decrypt.RekeyStore)
+	if !ok {
+		return nil, nil
+	}
+	return rekeyStore.LoadReplacementKeys(id)
+}
@@ -0,0 +1,145 @@
+package chaos_test
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/chaos"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/store"
+)
+
+func TestStore(t *testing.T) {
+	t.Run("no errors", func(t *testing.T) {
+		s := chaos.New(chaos.Config{}).Store(store.New(t.TempDir()))
+
+		if err := s.SaveKey("test/1", []byte("key")); err != nil {
+			t.Fatalf("SaveKey: %v", err)
+		}
+
+		key, err := s.LoadKey("test/1")
+		if err != nil {
+			t.Fatalf("LoadKey: %v", err)
+		}
+
+		if string(key) != "key" {
+			t.Errorf("LoadKey returned %q, expected key", key)
+		}
+	})
+
+	t.Run("always errors", func(t *testing.T) {
+		backend := store.New(t.TempDir())
+		s := chaos.New(chaos.Config{StoreErrorRate: 1}).Store(backend)
+
+		if err := s.SaveKey("test/1", []byte("key")); !errors.Is(err, chaos.ErrInjected) {
+			t.Errorf("SaveKey returned `%v`, expected `%v`", err, chaos.ErrInjected)
+		}
+
+		if _, err := backend.LoadKey("test/1"); err == nil {
+			t.Errorf("failing call reached the store")
+		}
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		s := chaos.New(chaos.Config{StoreLatency: 10 * time.Millisecond}).Store(store.New(t.TempDir()))
+
+		start := time.Now()
+		s.ListPolls()
+
+		if d := time.Since(start); d < 10*time.Millisecond {
+			t.Errorf("call took %s, expected at least 10ms", d)
+		}
+	})
+}
+
+func TestRandom(t *testing.T) {
+	r := chaos.New(chaos.Config{RandomErrorRate: 1}).Random(rand.Reader)
+
+	if _, err := r.Read(make([]byte, 8)); !errors.Is(err, chaos.ErrInjected) {
+		t.Errorf("Read returned `%v`, expected `%v`", err, chaos.ErrInjected)
+	}
+}
+
+// TestRecovery runs polls with a failing store and random source. Each call is
+// retried until it succeeds.
+func TestRecovery(t *testing.T) {
+	c := chaos.New(chaos.Config{
+		StoreErrorRate:  0.3,
+		RandomErrorRate: 0.05,
+		Seed:            1,
+	})
+
+	random := c.Random(rand.Reader)
+	d := decrypt.New(
+		crypto.New(make([]byte, 32), random, nil),
+		c.Store(store.New(t.TempDir())),
+		decrypt.WithAuditLog(discardAuditLog{}),
+	)
+
+	ctx := context.Background()
+	for _, pollID := range []string{"test/1", "test/2", "test/3", "test/4", "test/5"} {
+		var pubKey []byte
+		retry(t, "start", func() (err error) {
+			pubKey, _, err = d.Start(ctx, pollID)
+			return err
+		})
+
+		var votes [][]byte
+		for _, vote := range []string{`"Y"`, `"N"`, `"A"`} {
+			encrypted, err := crypto.Encrypt(rand.Reader, ecdh.X25519(), pubKey, []byte(vote))
+			if err != nil {
+				t.Fatalf("encrypting vote: %v", err)
+			}
+			votes = append(votes, encrypted)
+		}
+
+		var content []byte
+		retry(t, "stop", func() (err error) {
+			content, _, err = d.Stop(ctx, pollID, append([][]byte(nil), votes...))
+			return err
+		})
+
+		var result struct {
+			Votes []string `json:"votes"`
+		}
+		if err := json.Unmarshal(content, &result); err != nil {
+			t.Fatalf("decoding result: %v", err)
+		}
+
+		if len(result.Votes) != 3 {
+			t.Errorf("poll %s has votes %v, expected 3 votes", pollID, result.Votes)
+		}
+
+		retry(t, "clear", func() error {
+			return d.Clear(ctx, pollID)
+		})
+	}
+}
+
+func retry(t *testing.T, name string, f func() error) {
+	t.Helper()
+
+	var err error
+	for i := 0; i < 100; i++ {
+		if err = f(); err == nil {
+			return
+		}
+
+		if !errors.Is(err, chaos.ErrInjected) {
+			t.Fatalf("%s returned an error, that was not injected: %v", name, err)
+		}
+	}
+	t.Fatalf("%s did not succeed after 100 tries: %v", name, err)
+}
+
+type discardAuditLog struct{}
+
+func (discardAuditLog) Record(event, pollID, message string) error {
+	return nil
+}
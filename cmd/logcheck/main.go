@@ -0,0 +1,263 @@
+// logcheck reports calls, that log raw byte slices in the packages with key
+// material or decrypted votes.
+//
+// It works like a vet check. It is called with package patterns, type checks
+// the packages with the export data from `go list` and prints each finding as
+// FILE:LINE:COLUMN: MESSAGE. The exit code is 1, if there are findings.
+//
+//	go run ./cmd/logcheck ./...
+//
+// Only the packages in -packages are checked. A byte slice in these packages
+// is probably a key or a vote. It has to be wrapped in redact.Secret or
+// redact.Plaintext or encoded, for example as fingerprint, before it is
+// logged.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultPackages are the packages, that handle key material or decrypted
+// votes. A package matches, if its import path below the module is one of
+// them or starts with one of them and a slash.
+const defaultPackages = "crypto,decrypt,store,kms,tpm"
+
+// loggingFuncs are the functions and methods, whose arguments end up in a log.
+// Errors are included, since they are logged by the grpc server.
+var loggingFuncs = map[string]bool{
+	"fmt.Print": true, "fmt.Printf": true, "fmt.Println": true,
+	"fmt.Fprint": true, "fmt.Fprintf": true, "fmt.Fprintln": true,
+	"fmt.Errorf": true,
+
+	"log.Print": true, "log.Printf": true, "log.Println": true,
+	"log.Fatal": true, "log.Fatalf": true, "log.Fatalln": true,
+	"log.Panic": true, "log.Panicf": true, "log.Panicln": true,
+	"(*log.Logger).Print": true, "(*log.Logger).Printf": true, "(*log.Logger).Println": true,
+	"(*log.Logger).Fatal": true, "(*log.Logger).Fatalf": true, "(*log.Logger).Fatalln": true,
+	"(*log.Logger).Panic": true, "(*log.Logger).Panicf": true, "(*log.Logger).Panicln": true,
+
+	"log/slog.Debug": true, "log/slog.Info": true, "log/slog.Warn": true, "log/slog.Error": true,
+	"log/slog.Log": true, "log/slog.Any": true,
+	"(*log/slog.Logger).Debug": true, "(*log/slog.Logger).Info": true, "(*log/slog.Logger).Warn": true,
+	"(*log/slog.Logger).Error": true, "(*log/slog.Logger).Log": true,
+}
+
+func main() {
+	packages := flag.String("packages", defaultPackages, "Comma separated packages below the module, that are checked.")
+	flag.Parse()
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	findings, err := Check(".", patterns, strings.Split(*packages, ","))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logcheck: %v\n", err)
+		os.Exit(2)
+	}
+
+	for _, finding := range findings {
+		fmt.Println(finding)
+	}
+
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
+
+// listedPackage is the output of `go list -json` for one package.
+type listedPackage struct {
+	ImportPath string
+	Dir        string
+	Export     string
+	GoFiles    []string
+	DepOnly    bool
+	Module     *struct{ Path string }
+}
+
+// Check checks the packages, that match the patterns, in the module at dir.
+func Check(dir string, patterns, packages []string) ([]string, error) {
+	listed, err := goList(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	exports := make(map[string]string)
+	for _, pkg := range listed {
+		exports[pkg.ImportPath] = pkg.Export
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		export, ok := exports[path]
+		if !ok || export == "" {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(export)
+	})
+
+	var findings []string
+	for _, pkg := range listed {
+		if pkg.DepOnly || pkg.Module == nil || !matches(pkg.ImportPath, pkg.Module.Path, packages) {
+			continue
+		}
+
+		pkgFindings, err := checkPackage(fset, imp, pkg)
+		if err != nil {
+			return nil, fmt.Errorf("checking %s: %w", pkg.ImportPath, err)
+		}
+		findings = append(findings, pkgFindings...)
+	}
+
+	sort.Strings(findings)
+	return findings, nil
+}
+
+func goList(dir string, patterns []string) ([]listedPackage, error) {
+	args := append([]string{"list", "-export", "-deps", "-json"}, patterns...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w: %s", err, stderr.String())
+	}
+
+	var listed []listedPackage
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		var pkg listedPackage
+		if err := decoder.Decode(&pkg); err != nil {
+			return nil, fmt.Errorf("decoding go list output: %w", err)
+		}
+		listed = append(listed, pkg)
+	}
+	return listed, nil
+}
+
+// matches returns true, if the package is one of the checked packages.
+func matches(importPath, modulePath string, packages []string) bool {
+	rel, ok := strings.CutPrefix(importPath, modulePath+"/")
+	if !ok {
+		return false
+	}
+
+	for _, pkg := range packages {
+		if rel == pkg || strings.HasPrefix(rel, pkg+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func checkPackage(fset *token.FileSet, imp types.Importer, pkg listedPackage) ([]string, error) {
+	var files []*ast.File
+	for _, name := range pkg.GoFiles {
+		file, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	config := types.Config{Importer: imp}
+	if _, err := config.Check(pkg.ImportPath, fset, files, info); err != nil {
+		return nil, err
+	}
+
+	var findings []string
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+
+			name := funcName(info, call)
+			if !loggingFuncs[name] {
+				return true
+			}
+
+			for _, arg := range call.Args {
+				if isByteSlice(info.Types[arg].Type) {
+					findings = append(findings, fmt.Sprintf(
+						"%s: %s with raw byte slice %s, wrap it in redact.Secret or redact.Plaintext",
+						fset.Position(arg.Pos()),
+						name,
+						types.ExprString(arg),
+					))
+				}
+			}
+			return true
+		})
+	}
+	return findings, nil
+}
+
+// funcName returns the full name of the called function, for example
+// `log.Printf` or `(*log.Logger).Printf`.
+func funcName(info *types.Info, call *ast.CallExpr) string {
+	var ident *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return ""
+	}
+
+	fn, ok := info.Uses[ident].(*types.Func)
+	if !ok {
+		return ""
+	}
+	return fn.FullName()
+}
+
+// isByteSlice returns true for []byte and types with []byte as underlying
+// type, that do not format themselves.
+func isByteSlice(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+
+	slice, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+
+	basic, ok := slice.Elem().Underlying().(*types.Basic)
+	if !ok || basic.Kind() != types.Byte {
+		return false
+	}
+
+	if named, ok := t.(*types.Named); ok {
+		for i := 0; i < named.NumMethods(); i++ {
+			if name := named.Method(i).Name(); name == "String" || name == "Format" {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+
+	logcheck "github.com/OpenSlides/vote-decrypt/cmd/logcheck"
+)
+
+func TestCheck(t *testing.T) {
+	findings, err := logcheck.Check("testdata", []string{"./bad"}, []string{"cmd/logcheck/testdata"})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings, expected 2: %v", len(findings), findings)
+	}
+
+	for i, expect := range []string{"bad.go:17:24: log.Printf with raw byte slice key", "bad.go:21:38: fmt.Errorf with raw byte slice key"} {
+		if !strings.Contains(findings[i], expect) {
+			t.Errorf("finding %d is `%s`, expected it to contain `%s`", i, findings[i], expect)
+		}
+	}
+}
+
+func TestCheckRepo(t *testing.T) {
+	findings, err := logcheck.Check("../..", []string{"./..."}, strings.Split("crypto,decrypt,store,kms,tpm", ","))
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	for _, finding := range findings {
+		t.Error(finding)
+	}
+}
@@ -0,0 +1,22 @@
+package bad
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/OpenSlides/vote-decrypt/redact"
+)
+
+type fingerprint []byte
+
+func (f fingerprint) String() string {
+	return fmt.Sprintf("%x", []byte(f)[:4])
+}
+
+func logKey(key []byte) error {
+	log.Printf("key: %x", key)
+	log.Printf("key: %s", redact.NewSecret(key))
+	log.Printf("key: %s", fingerprint(key))
+	log.Printf("key has %d bytes", len(key))
+	return fmt.Errorf("invalid key %v", key)
+}
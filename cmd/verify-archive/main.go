@@ -0,0 +1,198 @@
+// verify-archive verifies an archive of a poll, that was created with
+// `vote-decrypt archive`, without network access.
+//
+// It checks the manifest and all signatures of the archive and that the public
+// main key of the archive is the trusted key. It only needs the archive, so it
+// can check a result years after the election, when the service and its store
+// are gone.
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/archive"
+	"github.com/OpenSlides/vote-decrypt/certificate"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/alecthomas/kong"
+)
+
+var cli struct {
+	Archive       string   `arg:"" help:"Path of the archive." type:"existingfile"`
+	PublicMainKey string   `help:"Base64 encoded public main key of the vote-decrypt service."`
+	CA            string   `help:"Path of a PEM file with the trusted root certificates. Verifies the certificate chain of the envelope instead of the public main key." name:"ca"`
+	CoSigner      []string `help:"Base64 encoded public co-signing key of a trusted instance. Can be given more then once." name:"cosigner"`
+	CoSignQuorum  int      `help:"Number of trusted co-signers, that have to co-sign the result. 0 means all." name:"cosign-quorum"`
+	RekorKey      string   `help:"Path of the PEM encoded public key of the Rekor transparency log. The result has to be in this log." name:"rekor-key" type:"path"`
+	PrintVotes    bool     `help:"Print the decrypted votes."`
+}
+
+func main() {
+	kong.Parse(&cli, kong.Description("Verifies an archive of a poll from vote-decrypt."))
+
+	if err := run(os.Stdout); err != nil {
+		log.Printf("Error: %v", err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer) error {
+	if (cli.PublicMainKey == "") == (cli.CA == "") {
+		return fmt.Errorf("either --public-main-key or --ca has to be given")
+	}
+
+	content, err := os.ReadFile(cli.Archive)
+	if err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+
+	a, err := archive.Read(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return fmt.Errorf("invalid archive: %w", err)
+	}
+	fmt.Fprintln(w, "manifest: ok")
+
+	report, err := a.Verify()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "signatures: ok")
+
+	if err := verifyMainKey(a, report.Timestamp); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "main key: %s\n", decrypt.Fingerprint(a.PublicMainKey))
+
+	envelope := a.Envelope
+	if len(cli.CoSigner) > 0 {
+		trusted := make([][]byte, len(cli.CoSigner))
+		for i, encoded := range cli.CoSigner {
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return fmt.Errorf("decoding co-signing key %d: %w", i+1, err)
+			}
+			trusted[i] = key
+		}
+
+		quorum := cli.CoSignQuorum
+		if quorum <= 0 {
+			quorum = len(trusted)
+		}
+
+		if err := envelope.VerifyCoSignatures(trusted, quorum); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "co-signatures: ok")
+	}
+
+	if !report.Timestamp.IsZero() {
+		fmt.Fprintf(w, "timestamp: %s (signature of the authority not checked)\n", report.Timestamp.Format(time.RFC3339))
+	}
+
+	if cli.RekorKey != "" {
+		logKey, err := os.ReadFile(cli.RekorKey)
+		if err != nil {
+			return fmt.Errorf("reading rekor key: %w", err)
+		}
+
+		if err := envelope.VerifyTransparency(logKey); err != nil {
+			return err
+		}
+	}
+
+	if entry := envelope.Transparency; entry != nil {
+		checked := ""
+		if cli.RekorKey == "" {
+			checked = " (signature of the log not checked)"
+		}
+		fmt.Fprintf(w, "transparency log: entry %d at %s%s\n", entry.LogIndex, time.Unix(entry.IntegratedTime, 0).UTC().Format(time.RFC3339), checked)
+	}
+
+	if len(a.PollKey) > 0 {
+		fmt.Fprintf(w, "poll key: %s\n", decrypt.Fingerprint(a.PollKey))
+	}
+
+	if len(a.AuditLog) > 0 {
+		fmt.Fprintf(w, "audit log: %d entries\n", report.AuditEntries)
+	}
+
+	result := report.Result
+	fmt.Fprintf(w, "archived: %s\n", a.Created.Format(time.RFC3339))
+	fmt.Fprintf(w, "result hash: %s\n", report.ResultHash)
+	fmt.Fprintf(w, "poll: %s\n", report.PollID)
+	fmt.Fprintf(w, "votes: %d\n", len(result.Votes))
+
+	reasons := make([]string, 0, len(result.Invalid))
+	for reason := range result.Invalid {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "invalid: %d (%s)\n", result.Invalid[reason], reason)
+	}
+
+	if cli.PrintVotes {
+		for i, vote := range result.Votes {
+			var line bytes.Buffer
+			line.Write(vote)
+			if result.Weights != nil {
+				fmt.Fprintf(&line, " weight=%s", result.Weights[i])
+			}
+			fmt.Fprintln(w, line.String())
+		}
+	}
+
+	return nil
+}
+
+// verifyMainKey checks, that the public main key of the archive is the
+// trusted key or the key of a certificate chain, that was valid at the time of
+// the timestamp. Without a timestamp, the chain has to be valid now.
+func verifyMainKey(a archive.Archive, at time.Time) error {
+	if cli.CA == "" {
+		trusted, err := base64.StdEncoding.DecodeString(cli.PublicMainKey)
+		if err != nil {
+			return fmt.Errorf("decoding public main key: %w", err)
+		}
+
+		if !bytes.Equal(trusted, a.PublicMainKey) {
+			return fmt.Errorf("archive is signed with the main key %s, not with %s", decrypt.Fingerprint(a.PublicMainKey), decrypt.Fingerprint(trusted))
+		}
+		return nil
+	}
+
+	encoded, err := os.ReadFile(cli.CA)
+	if err != nil {
+		return fmt.Errorf("reading root certificates: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(encoded) {
+		return fmt.Errorf("no root certificate found in %s", cli.CA)
+	}
+
+	if len(a.Envelope.Certificates) == 0 {
+		return fmt.Errorf("envelope for poll %s has no certificate", a.Envelope.ID)
+	}
+
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	certKey, err := certificate.Verify(a.Envelope.Certificates, roots, at)
+	if err != nil {
+		return fmt.Errorf("certificate chain: %w", err)
+	}
+
+	if !bytes.Equal(certKey, a.PublicMainKey) {
+		return fmt.Errorf("certificate is for the main key %s, not for %s", decrypt.Fingerprint(certKey), decrypt.Fingerprint(a.PublicMainKey))
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+// vote-verify verifies the published result of a poll without network access.
+//
+// It checks the signature of a result envelope with the public main key of the
+// vote-decrypt service or with the certificate chain of the envelope and that
+// the signed content is a valid result for the poll of the envelope. It is meant for observers, that do not trust the
+// infrastructure of the election.
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/certificate"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/qr"
+	"github.com/OpenSlides/vote-decrypt/tsa"
+	"github.com/alecthomas/kong"
+)
+
+var cli struct {
+	Result        *os.File `arg:"" help:"Path of the result envelope in json format. Use - for stdin." default:"-"`
+	PublicMainKey string   `help:"Base64 encoded public main key of the vote-decrypt service."`
+	CA            string   `help:"Path of a PEM file with the trusted root certificates. Verifies the certificate chain of the envelope instead of the public main key." name:"ca"`
+	CoSigner      []string `help:"Base64 encoded public co-signing key of a trusted instance. Can be given more then once." name:"cosigner"`
+	CoSignQuorum  int      `help:"Number of trusted co-signers, that have to co-sign the result. 0 means all." name:"cosign-quorum"`
+	RekorKey      string   `help:"Path of the PEM encoded public key of the Rekor transparency log. The result has to be in this log." name:"rekor-key" type:"path"`
+	PrintVotes    bool     `help:"Print the decrypted votes."`
+	QR            bool     `help:"Print a QR code with the result hash." name:"qr"`
+	QRPNG         string   `help:"Write a QR code with the result hash as PNG image to the given path." name:"qr-png" type:"path"`
+}
+
+func main() {
+	kong.Parse(&cli, kong.Description("Verifies the signed result of a poll from vote-decrypt."))
+
+	if err := run(os.Stdout); err != nil {
+		log.Printf("Error: %v", err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer) error {
+	if (cli.PublicMainKey == "") == (cli.CA == "") {
+		return fmt.Errorf("either --public-main-key or --ca has to be given")
+	}
+
+	rawEnvelope, err := io.ReadAll(cli.Result)
+	if err != nil {
+		return fmt.Errorf("reading result: %w", err)
+	}
+
+	var envelope grpc.ResultEnvelope
+	if err := json.Unmarshal(rawEnvelope, &envelope); err != nil {
+		return fmt.Errorf("decoding result envelope: %w", err)
+	}
+
+	publicMainKey, err := verifySignature(envelope)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "signature: ok")
+	fmt.Fprintf(w, "main key: %s\n", decrypt.Fingerprint(publicMainKey))
+	switch {
+	case envelope.Meeting != "":
+		fmt.Fprintf(w, "meeting signing key: %s (meeting %s)\n", decrypt.Fingerprint(envelope.PollSigningKey), envelope.Meeting)
+	case len(envelope.PollSigningKey) > 0:
+		fmt.Fprintf(w, "poll signing key: %s\n", decrypt.Fingerprint(envelope.PollSigningKey))
+	}
+
+	if len(cli.CoSigner) > 0 {
+		if err := verifyCoSignatures(envelope); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "co-signatures: ok")
+	}
+
+	if len(envelope.Timestamp) > 0 {
+		at, err := tsa.Verify(envelope.Timestamp, envelope.Signature)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp: %w", err)
+		}
+		fmt.Fprintf(w, "timestamp: %s (signature of the authority not checked)\n", at.Format(time.RFC3339))
+	}
+
+	if err := printTransparency(w, envelope); err != nil {
+		return err
+	}
+
+	result, err := decrypt.ParseResult(envelope.Content)
+	if err != nil {
+		return fmt.Errorf("invalid content: %w", err)
+	}
+
+	// The poll id of the envelope is not signed. Only the id in the content
+	// is protected by the signature.
+	if result.ID != envelope.ID {
+		return fmt.Errorf("envelope is for poll %s, but the signed content is for poll %s", envelope.ID, result.ID)
+	}
+	fmt.Fprintln(w, "content: ok")
+
+	// The result hash has the form of a fingerprint, so it can be compared
+	// with the hash shown by other tools.
+	resultHash := decrypt.Fingerprint(envelope.Content)
+	fmt.Fprintf(w, "result hash: %s\n", resultHash)
+
+	fmt.Fprintf(w, "poll: %s\n", result.ID)
+	fmt.Fprintf(w, "votes: %d\n", len(result.Votes))
+	if result.Weights != nil {
+		fmt.Fprintln(w, "weighted: yes")
+	}
+	if result.NotBefore != nil {
+		fmt.Fprintf(w, "not before: %s\n", result.NotBefore.Format(time.RFC3339))
+	}
+	if result.CiphertextRoot != nil {
+		fmt.Fprintf(w, "ciphertext root: %s\n", base64.StdEncoding.EncodeToString(result.CiphertextRoot))
+	}
+
+	if result.Superseded > 0 {
+		fmt.Fprintf(w, "superseded: %d\n", result.Superseded)
+	}
+
+	reasons := make([]string, 0, len(result.Invalid))
+	for reason := range result.Invalid {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "invalid: %d (%s)\n", result.Invalid[reason], reason)
+	}
+
+	if cli.PrintVotes {
+		for i, vote := range result.Votes {
+			var line bytes.Buffer
+			line.Write(vote)
+			if result.Weights != nil {
+				fmt.Fprintf(&line, " weight=%s", result.Weights[i])
+			}
+			fmt.Fprintln(w, line.String())
+		}
+	}
+
+	return writeQR(w, resultHash)
+}
+
+// writeQR writes the result hash as QR code, if one of the qr flags is given.
+func writeQR(w io.Writer, resultHash string) error {
+	if !cli.QR && cli.QRPNG == "" {
+		return nil
+	}
+
+	code, err := qr.Encode([]byte(resultHash))
+	if err != nil {
+		return fmt.Errorf("creating qr code: %w", err)
+	}
+
+	if cli.QR {
+		fmt.Fprint(w, code.Terminal())
+	}
+
+	if cli.QRPNG != "" {
+		f, err := os.Create(cli.QRPNG)
+		if err != nil {
+			return fmt.Errorf("creating png file: %w", err)
+		}
+		defer f.Close()
+
+		if err := code.WritePNG(f, 8); err != nil {
+			return fmt.Errorf("writing png file: %w", err)
+		}
+		return f.Close()
+	}
+	return nil
+}
+
+// printTransparency prints the entry of the transparency log. Its inclusion
+// proof was checked with the signature. With --rekor-key, the signature of the
+// log is checked as well.
+func printTransparency(w io.Writer, envelope grpc.ResultEnvelope) error {
+	if cli.RekorKey != "" {
+		logKey, err := os.ReadFile(cli.RekorKey)
+		if err != nil {
+			return fmt.Errorf("reading rekor key: %w", err)
+		}
+
+		if err := envelope.VerifyTransparency(logKey); err != nil {
+			return err
+		}
+	}
+
+	if envelope.Transparency == nil {
+		return nil
+	}
+
+	entry := envelope.Transparency
+	at := time.Unix(entry.IntegratedTime, 0).UTC().Format(time.RFC3339)
+	if cli.RekorKey == "" {
+		fmt.Fprintf(w, "transparency log: entry %d at %s (signature of the log not checked)\n", entry.LogIndex, at)
+		return nil
+	}
+	fmt.Fprintf(w, "transparency log: entry %d at %s\n", entry.LogIndex, at)
+	return nil
+}
+
+// verifyCoSignatures checks, that the trusted co-signers signed the result.
+func verifyCoSignatures(envelope grpc.ResultEnvelope) error {
+	trusted := make([][]byte, len(cli.CoSigner))
+	for i, encoded := range cli.CoSigner {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("decoding co-signing key %d: %w", i+1, err)
+		}
+		trusted[i] = key
+	}
+
+	quorum := cli.CoSignQuorum
+	if quorum <= 0 {
+		quorum = len(trusted)
+	}
+	return envelope.VerifyCoSignatures(trusted, quorum)
+}
+
+// verifySignature checks the signature of the envelope with the public main key
+// or the certificate chain. It returns the public main key, that signed the
+// result.
+func verifySignature(envelope grpc.ResultEnvelope) ([]byte, error) {
+	if cli.CA != "" {
+		encoded, err := os.ReadFile(cli.CA)
+		if err != nil {
+			return nil, fmt.Errorf("reading root certificates: %w", err)
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(encoded) {
+			return nil, fmt.Errorf("no root certificate found in %s", cli.CA)
+		}
+
+		if err := envelope.VerifyCertificate(roots); err != nil {
+			return nil, err
+		}
+		return certificate.PublicKey(envelope.Certificates[0])
+	}
+
+	publicMainKey, err := base64.StdEncoding.DecodeString(cli.PublicMainKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding public main key: %w", err)
+	}
+
+	return publicMainKey, envelope.Verify(publicMainKey)
+}
@@ -0,0 +1,25 @@
+package crash
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// DisableCoreDumps makes sure, that the kernel does not write a core dump of
+// the process. A core dump contains the memory of the process and therefore
+// the main key and the poll keys.
+//
+// It sets the limit for core files to 0 and marks the process as not
+// dumpable. The second also prevents other processes of the same user from
+// attaching a debugger or reading /proc/PID/mem.
+func DisableCoreDumps() error {
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0}); err != nil {
+		return fmt.Errorf("setting core file limit: %w", err)
+	}
+
+	if err := unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0); err != nil {
+		return fmt.Errorf("marking process as not dumpable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package crash_test
+
+import (
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crash"
+	"golang.org/x/sys/unix"
+)
+
+func TestDisableCoreDumps(t *testing.T) {
+	if err := crash.DisableCoreDumps(); err != nil {
+		t.Fatalf("DisableCoreDumps: %v", err)
+	}
+
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_CORE, &limit); err != nil {
+		t.Fatalf("Getrlimit: %v", err)
+	}
+
+	if limit.Cur != 0 {
+		t.Errorf("core file limit is %d, expected 0", limit.Cur)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package crash
+
+import "errors"
+
+// DisableCoreDumps is only supported on linux.
+func DisableCoreDumps() error {
+	return errors.New("disabling core dumps is only supported on linux")
+}
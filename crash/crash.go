@@ -0,0 +1,226 @@
+// Package crash helps with the post-mortem analysis of a crash of the service.
+//
+// Core dumps are disabled, because they contain the keys. Instead, a Handler
+// writes a report with the stacks of all goroutines, the hash of the
+// configuration and the last entries of the audit log. The report contains
+// no key material: the stacks only show the functions and the machine words of
+// their arguments, not the content of byte slices, and the audit log does not
+// contain keys.
+package crash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/audit"
+	"github.com/OpenSlides/vote-decrypt/version"
+	"google.golang.org/grpc"
+)
+
+// maxStackSize is the maximum size of the goroutine dump.
+const maxStackSize = 8 << 20
+
+// Sealer encrypts a report. crypto.Sealer implements it.
+type Sealer interface {
+	Seal(data []byte) ([]byte, error)
+}
+
+// Report is the diagnostic information of a crash.
+type Report struct {
+	Time  time.Time    `json:"time"`
+	Build version.Info `json:"build"`
+
+	// Reason is the value of the panic.
+	Reason string `json:"reason"`
+
+	// ConfigHash is the hash of the effective configuration. See WithConfigHash().
+	ConfigHash string `json:"config_hash,omitempty"`
+
+	// AuditEntries are the last entries of the audit log.
+	AuditEntries []audit.Entry `json:"audit_entries,omitempty"`
+
+	// Goroutines are the stacks of all goroutines.
+	Goroutines string `json:"goroutines"`
+}
+
+// Option for New().
+type Option func(*Handler)
+
+// WithDir writes the reports to files in the directory. Without this option,
+// they are written to the log.
+func WithDir(dir string) Option {
+	return func(h *Handler) {
+		h.dir = dir
+	}
+}
+
+// WithSealer encrypts the report files.
+func WithSealer(sealer Sealer) Option {
+	return func(h *Handler) {
+		h.sealer = sealer
+	}
+}
+
+// WithConfigHash adds the hash of the configuration to the reports. It shows,
+// if the crashed instance used the expected configuration, without adding the
+// configuration itself.
+func WithConfigHash(hash string) Option {
+	return func(h *Handler) {
+		h.configHash = hash
+	}
+}
+
+// WithAuditEntries adds the entries of the recorder to the reports.
+func WithAuditEntries(recent *Recent) Option {
+	return func(h *Handler) {
+		h.recent = recent
+	}
+}
+
+// Handler writes a report, when a goroutine panics.
+type Handler struct {
+	dir        string
+	sealer     Sealer
+	configHash string
+	recent     *Recent
+}
+
+// New initializes a Handler.
+func New(options ...Option) *Handler {
+	var h Handler
+	for _, o := range options {
+		o(&h)
+	}
+	return &h
+}
+
+// Report creates a report for the panic value reason.
+func (h *Handler) Report(reason any) Report {
+	stack := make([]byte, maxStackSize)
+	stack = stack[:runtime.Stack(stack, true)]
+
+	report := Report{
+		Time:       time.Now().UTC(),
+		Build:      version.Get(),
+		Reason:     fmt.Sprintf("%v", reason),
+		ConfigHash: h.configHash,
+		Goroutines: string(stack),
+	}
+
+	if h.recent != nil {
+		report.AuditEntries = h.recent.Entries()
+	}
+	return report
+}
+
+// Write creates a report for the panic value reason and writes it. It returns
+// the path of the report file or an empty string, if the report was written
+// to the log.
+func (h *Handler) Write(reason any) (string, error) {
+	report, err := json.Marshal(h.Report(reason))
+	if err != nil {
+		return "", fmt.Errorf("encoding report: %w", err)
+	}
+
+	if h.dir == "" {
+		log.Printf("Crash report: %s", report)
+		return "", nil
+	}
+
+	name := fmt.Sprintf("crash-%s.json", time.Now().UTC().Format("20060102T150405.000000000Z"))
+	if h.sealer != nil {
+		report, err = h.sealer.Seal(report)
+		if err != nil {
+			return "", fmt.Errorf("sealing report: %w", err)
+		}
+		name += ".sealed"
+	}
+
+	path := filepath.Join(h.dir, name)
+	if err := os.WriteFile(path, report, 0600); err != nil {
+		return "", fmt.Errorf("writing report: %w", err)
+	}
+	return path, nil
+}
+
+// Recover writes a report, if the goroutine panics. It has to be called with
+// defer. The panic continues afterwards, so the process still crashes.
+//
+// Panics of other goroutines and fatal errors of the runtime can not be
+// handled. For them, the runtime prints the stacks to stderr.
+func (h *Handler) Recover() {
+	if reason := recover(); reason != nil {
+		h.handle(reason)
+		panic(reason)
+	}
+}
+
+// handle writes the report and logs errors.
+func (h *Handler) handle(reason any) {
+	path, err := h.Write(reason)
+	if err != nil {
+		log.Printf("Error: writing crash report: %v", err)
+		return
+	}
+
+	if path != "" {
+		log.Printf("Crash report written to %s", path)
+	}
+}
+
+// UnaryInterceptor returns a grpc interceptor, that writes a report, if a
+// request panics.
+func (h *Handler) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		defer h.Recover()
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor is like UnaryInterceptor() for streaming methods.
+func (h *Handler) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		defer h.Recover()
+		return handler(srv, ss)
+	}
+}
+
+// Recent keeps the last entries of the audit log in memory. It implements
+// audit.Sink.
+type Recent struct {
+	mu      sync.Mutex
+	size    int
+	entries []audit.Entry
+}
+
+// NewRecent initializes a Recent, that keeps size entries.
+func NewRecent(size int) *Recent {
+	return &Recent{size: size}
+}
+
+// Send adds an entry.
+func (r *Recent) Send(entry audit.Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entry)
+	if len(r.entries) > r.size {
+		r.entries = r.entries[len(r.entries)-r.size:]
+	}
+	return nil
+}
+
+// Entries returns the kept entries, oldest first.
+func (r *Recent) Entries() []audit.Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]audit.Entry(nil), r.entries...)
+}
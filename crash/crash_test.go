@@ -0,0 +1,95 @@
+package crash_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/audit"
+	"github.com/OpenSlides/vote-decrypt/crash"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+)
+
+func TestRecent(t *testing.T) {
+	recent := crash.NewRecent(2)
+	for _, event := range []string{"start", "stop", "clear"} {
+		recent.Send(audit.Entry{Event: event, PollID: "1"})
+	}
+
+	entries := recent.Entries()
+	if len(entries) != 2 || entries[0].Event != "stop" || entries[1].Event != "clear" {
+		t.Errorf("got entries %v, expected stop and clear", entries)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	recent := crash.NewRecent(10)
+	recent.Send(audit.Entry{Event: "start", PollID: "1"})
+
+	t.Run("recover", func(t *testing.T) {
+		dir := t.TempDir()
+		h := crash.New(crash.WithDir(dir), crash.WithConfigHash("abc"), crash.WithAuditEntries(recent))
+
+		func() {
+			defer func() {
+				if reason := recover(); reason != "broken" {
+					t.Errorf("got panic %v, expected the original panic", reason)
+				}
+			}()
+			defer h.Recover()
+			panic("broken")
+		}()
+
+		files, _ := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+		if len(files) != 1 {
+			t.Fatalf("got %d crash reports, expected 1", len(files))
+		}
+
+		content, _ := os.ReadFile(files[0])
+		var report crash.Report
+		if err := json.Unmarshal(content, &report); err != nil {
+			t.Fatalf("decoding report: %v", err)
+		}
+
+		if report.Reason != "broken" || report.ConfigHash != "abc" || len(report.AuditEntries) != 1 {
+			t.Errorf("got report with reason %q, config hash %q and %d audit entries", report.Reason, report.ConfigHash, len(report.AuditEntries))
+		}
+
+		if !strings.Contains(report.Goroutines, "TestHandler") {
+			t.Errorf("goroutine dump does not contain the test")
+		}
+	})
+
+	t.Run("sealed", func(t *testing.T) {
+		privateKey, _ := ecdh.X25519().GenerateKey(rand.Reader)
+		sealer, err := crypto.NewSealer(privateKey.PublicKey().Bytes(), rand.Reader)
+		if err != nil {
+			t.Fatalf("NewSealer: %v", err)
+		}
+
+		h := crash.New(crash.WithDir(t.TempDir()), crash.WithSealer(sealer))
+		path, err := h.Write("broken")
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+
+		if !strings.HasSuffix(path, ".json.sealed") {
+			t.Errorf("got path %s, expected a sealed report", path)
+		}
+
+		sealed, _ := os.ReadFile(path)
+		content, err := crypto.New(make([]byte, 32), rand.Reader, nil).Decrypt(privateKey.Bytes(), sealed)
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+
+		var report crash.Report
+		if err := json.Unmarshal(content, &report); err != nil || report.Reason != "broken" {
+			t.Errorf("got report %q, expected the reason broken: %v", content, err)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+)
+
+// Algorithm is the signature algorithm of a main key.
+type Algorithm string
+
+const (
+	// Ed25519 signs the message with ed25519. The public key has 32 bytes.
+	Ed25519 Algorithm = "ed25519"
+
+	// ECDSAP256SHA256 signs the sha256 hash of the message with ECDSA on
+	// P-256. The public key is the uncompressed point (65 bytes). The
+	// signature is r and s as 32 byte big endian values.
+	ECDSAP256SHA256 Algorithm = "ecdsa-p256-sha256"
+
+	// RSAPSSSHA256 signs the sha256 hash of the message with RSASSA-PSS. The
+	// salt has the size of the hash. The public key is encoded in the PKIX, ASN.1
+	// DER form and has at least 2048 bits.
+	RSAPSSSHA256 Algorithm = "rsa-pss-sha256"
+)
+
+// minRSABits is the smallest allowed size of a RSA key.
+const minRSABits = 2048
+
+// KeyAlgorithm returns the algorithm of a public main key. The algorithm is
+// detected by the encoding of the key.
+func KeyAlgorithm(pubKey []byte) (Algorithm, error) {
+	switch len(pubKey) {
+	case ed25519.PublicKeySize:
+		return Ed25519, nil
+
+	case 65:
+		return ECDSAP256SHA256, nil
+	}
+
+	if _, err := rsaPublicKey(pubKey); err != nil {
+		return "", err
+	}
+	return RSAPSSSHA256, nil
+}
+
+// Algorithm returns the signature algorithm of the main key.
+func (c Crypto) Algorithm() (Algorithm, error) {
+	return KeyAlgorithm(c.PublicMainKey())
+}
+
+// PrivateMainKey returns a main key from a file as crypto.Signer of the
+// standard library, for example to sign a certificate for the public main key.
+// With fips, it is the ECDSA P-256 key of NewECDSASigner(), otherwise the
+// ed25519 key of New().
+func PrivateMainKey(mainKey []byte, fips bool) (crypto.Signer, error) {
+	if fips {
+		key, _, err := ecdsaPrivateKey(mainKey)
+		if err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	if len(mainKey) != ed25519.SeedSize {
+		return nil, fmt.Errorf("main key has %d bytes, expected %d", len(mainKey), ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(mainKey), nil
+}
+
+// EncodePublicKey returns the public key of a standard library key in the
+// encoding of the public main key. It is the reverse of KeyAlgorithm().
+func EncodePublicKey(key crypto.PublicKey) ([]byte, error) {
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return key, nil
+
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported ecdsa curve %s", key.Curve.Params().Name)
+		}
+
+		encoded, err := key.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("invalid ecdsa key: %w", err)
+		}
+		return encoded.Bytes(), nil
+
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("encoding rsa key: %w", err)
+		}
+
+		if _, err := rsaPublicKey(der); err != nil {
+			return nil, err
+		}
+		return der, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// rsaPublicKey parses a RSA key in the PKIX, ASN.1 DER form.
+func rsaPublicKey(pubKey []byte) (*rsa.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("unknown public key with %d bytes: %w", len(pubKey), err)
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+
+	if rsaKey.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("rsa key has %d bits, expected at least %d", rsaKey.N.BitLen(), minRSABits)
+	}
+
+	return rsaKey, nil
+}
+
+// Verify checks that the signature was created with pubKey for the message.
+//
+// The algorithm is detected from the public key with KeyAlgorithm().
+//
+// This function is not needed or used by the decrypt service. It is only
+// implemented in this package for debugging and testing.
+func Verify(pubKey, message, signature []byte) bool {
+	algorithm, err := KeyAlgorithm(pubKey)
+	if err != nil {
+		return false
+	}
+	return VerifyAlgorithm(algorithm, pubKey, message, signature)
+}
+
+// VerifyAlgorithm works like Verify() but only accepts signatures of the given
+// algorithm. It should be used, if the algorithm is sent with the signature,
+// so a public key can not be used with another algorithm.
+func VerifyAlgorithm(algorithm Algorithm, pubKey, message, signature []byte) bool {
+	if keyAlgorithm, err := KeyAlgorithm(pubKey); err != nil || keyAlgorithm != algorithm {
+		return false
+	}
+
+	switch algorithm {
+	case Ed25519:
+		return ed25519.Verify(pubKey, message, signature)
+
+	case ECDSAP256SHA256:
+		// ecdh validates, that the point is on the curve.
+		if _, err := ecdh.P256().NewPublicKey(pubKey); err != nil || len(signature) != 64 {
+			return false
+		}
+
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pubKey[1:33]),
+			Y:     new(big.Int).SetBytes(pubKey[33:]),
+		}
+
+		hash := sha256.Sum256(message)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, hash[:], r, s)
+
+	case RSAPSSSHA256:
+		key, err := rsaPublicKey(pubKey)
+		if err != nil {
+			return false
+		}
+
+		hash := sha256.Sum256(message)
+		return rsa.VerifyPSS(key, crypto.SHA256, hash[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+
+	default:
+		return false
+	}
+}
@@ -52,3 +52,33 @@ func BenchmarkDecrypt_1Votes_Byte1000(b *testing.B)    { benchmarkDecrypt(b, 1,
 func BenchmarkDecrypt_10Votes_Byte1000(b *testing.B)   { benchmarkDecrypt(b, 10, 1_000) }
 func BenchmarkDecrypt_100Votes_Byte1000(b *testing.B)  { benchmarkDecrypt(b, 100, 1_000) }
 func BenchmarkDecrypt_1000Votes_Byte1000(b *testing.B) { benchmarkDecrypt(b, 1_000, 1_000) }
+
+func BenchmarkPollDecrypter_Byte100(b *testing.B) {
+	curve := ecdh.X25519()
+	cr := crypto.New(mockMainKey(), randomMock{}, curve)
+
+	privKey, err := curve.GenerateKey(randomMock{})
+	if err != nil {
+		b.Fatalf("creating private key: %v", err)
+	}
+
+	vote, err := crypto.Encrypt(randomMock{}, curve, privKey.PublicKey().Bytes(), make([]byte, 100))
+	if err != nil {
+		b.Fatalf("encrypting vote: %v", err)
+	}
+
+	decrypt, err := cr.PollDecrypter(privKey.Bytes(), "")
+	if err != nil {
+		b.Fatalf("creating decrypter: %v", err)
+	}
+
+	buf := make([]byte, 0, len(vote))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := decrypt(buf, vote); err != nil {
+			b.Errorf("decrypting: %v", err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Capabilities describes, which implementations of the primitives the
+// standard library uses on this machine.
+//
+// The votes are always encrypted with aes-256-gcm. Go uses a constant-time
+// assembly implementation of AES and GHASH, if the processor has AES and
+// carry-less multiplication instructions (AES-NI and PCLMULQDQ on amd64, the
+// Armv8 crypto extension on arm64). Otherwise, it falls back to a table based
+// implementation, that can leak the poll key through cache timings. This is the
+// case on processors without the crypto extension, for example the Raspberry Pi
+// 4. X25519, P-256 and ed25519 are constant-time on all architectures.
+type Capabilities struct {
+	Arch string
+
+	// HardwareAES is true, if AES uses processor instructions.
+	HardwareAES bool
+
+	// HardwareGCM is true, if GHASH uses carry-less multiplication
+	// instructions.
+	HardwareGCM bool
+
+	// X25519 and P256 are the names of the implementations of the curves.
+	X25519 string
+	P256   string
+
+	// FIPSModule is true, if the binary uses a validated module.
+	FIPSModule bool
+}
+
+// Caps returns the capabilities of this machine.
+func Caps() Capabilities {
+	caps := Capabilities{
+		Arch:       runtime.GOARCH,
+		X25519:     "constant-time (Go)",
+		P256:       "constant-time (Go)",
+		FIPSModule: FIPSModule(),
+	}
+
+	switch runtime.GOARCH {
+	case "amd64":
+		caps.HardwareAES = cpu.X86.HasAES && cpu.X86.HasSSE41 && cpu.X86.HasSSSE3
+		caps.HardwareGCM = caps.HardwareAES && cpu.X86.HasPCLMULQDQ
+		caps.X25519 = "constant-time (assembly)"
+		caps.P256 = "constant-time (assembly)"
+
+	case "arm64":
+		caps.HardwareAES = cpu.ARM64.HasAES
+		caps.HardwareGCM = caps.HardwareAES && cpu.ARM64.HasPMULL
+		caps.X25519 = "constant-time (assembly)"
+		caps.P256 = "constant-time (assembly)"
+
+	case "ppc64", "ppc64le":
+		caps.HardwareAES = true
+		caps.HardwareGCM = true
+		caps.P256 = "constant-time (assembly)"
+
+	case "s390x":
+		caps.HardwareAES = cpu.S390X.HasAES && cpu.S390X.HasAESCBC
+		caps.HardwareGCM = caps.HardwareAES && cpu.S390X.HasAESCTR && cpu.S390X.HasGHASH
+		caps.P256 = "constant-time (assembly)"
+	}
+
+	return caps
+}
+
+// CheckConstantTime returns an error, if aes-256-gcm is not constant-time on
+// this machine.
+func (c Capabilities) CheckConstantTime() error {
+	if !c.HardwareAES {
+		return fmt.Errorf("the %s processor has no AES instructions, aes-256-gcm is not constant-time", c.Arch)
+	}
+
+	if !c.HardwareGCM {
+		return fmt.Errorf("the %s processor has no carry-less multiplication instructions, GHASH is not constant-time", c.Arch)
+	}
+	return nil
+}
+
+// String returns a human readable report.
+func (c Capabilities) String() string {
+	return fmt.Sprintf(
+		"arch: %s\naes: %s\ngcm: %s\nx25519: %s\np-256: %s\nfips module: %t",
+		c.Arch,
+		implementation(c.HardwareAES),
+		implementation(c.HardwareGCM),
+		c.X25519,
+		c.P256,
+		c.FIPSModule,
+	)
+}
+
+func implementation(hardware bool) string {
+	if hardware {
+		return "constant-time (hardware)"
+	}
+	return "NOT constant-time (lookup tables)"
+}
@@ -0,0 +1,23 @@
+package crypto_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+)
+
+func TestCaps(t *testing.T) {
+	caps := crypto.Caps()
+	if caps.Arch != runtime.GOARCH {
+		t.Errorf("got arch %s, expected %s", caps.Arch, runtime.GOARCH)
+	}
+
+	if err := caps.CheckConstantTime(); (err == nil) != (caps.HardwareAES && caps.HardwareGCM) {
+		t.Errorf("CheckConstantTime returned %v for %+v", err, caps)
+	}
+
+	if err := (crypto.Capabilities{Arch: "arm64"}).CheckConstantTime(); err == nil {
+		t.Errorf("CheckConstantTime without hardware AES did not return an error")
+	}
+}
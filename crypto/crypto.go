@@ -7,7 +7,8 @@
 // living poll keys and decrypt single votes that where encrypted with this poll
 // key.
 //
-// This package uses x25519 for decryption and ed25519 for signing.
+// This package uses x25519 for decryption and ed25519 for signing. The signing
+// can be delegated to a Signer, for example a key management service.
 package crypto
 
 import (
@@ -26,11 +27,23 @@ const (
 	nonceSize = 12
 )
 
+// Signer creates signatures with the main key.
+//
+// The default signer uses a local ed25519 key. Other implementations can
+// delegate the signing to a key management service.
+type Signer interface {
+	// Public returns the public main key.
+	Public() []byte
+
+	// Sign returns the signature for the message.
+	Sign(message []byte) ([]byte, error)
+}
+
 // Crypto implements all cryptographic functions needed for the decrypt service.
 type Crypto struct {
-	mainKey ed25519.PrivateKey
-	random  io.Reader
-	curve   ecdh.Curve
+	signer Signer
+	random io.Reader
+	curve  ecdh.Curve
 }
 
 // New initializes a Crypto object with a main key and a random source.
@@ -39,20 +52,37 @@ type Crypto struct {
 //
 // curve is the ecdh curve to use. If set the nil, it uses x25519.
 func New(mainKey []byte, random io.Reader, curve ecdh.Curve) Crypto {
+	return NewWithSigner(ed25519Signer(ed25519.NewKeyFromSeed(mainKey)), random, curve)
+}
+
+// NewWithSigner initializes a Crypto object like New() but uses signer for all
+// signatures instead of a local main key.
+func NewWithSigner(signer Signer, random io.Reader, curve ecdh.Curve) Crypto {
 	if curve == nil {
 		curve = ecdh.X25519()
 	}
 
 	return Crypto{
-		mainKey: ed25519.NewKeyFromSeed(mainKey),
-		random:  random,
-		curve:   curve,
+		signer: signer,
+		random: random,
+		curve:  curve,
 	}
 }
 
+// ed25519Signer implements the Signer interface with a local ed25519 key.
+type ed25519Signer ed25519.PrivateKey
+
+func (s ed25519Signer) Public() []byte {
+	return ed25519.PrivateKey(s).Public().(ed25519.PublicKey)
+}
+
+func (s ed25519Signer) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(s), message), nil
+}
+
 // PublicMainKey returns the public key for the private main key.
 func (c Crypto) PublicMainKey() []byte {
-	return c.mainKey.Public().(ed25519.PublicKey)
+	return c.signer.Public()
 }
 
 // CreatePollKey creates a new keypair for a poll.
@@ -77,74 +107,64 @@ func (c Crypto) PublicPollKey(privateKey []byte) (pubKey []byte, pubKeySig []byt
 
 	pubKey = privKey.PublicKey().Bytes()
 
-	pubKeySig = ed25519.Sign(c.mainKey, pubKey)
+	pubKeySig, err = c.signer.Sign(pubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing public poll key: %w", err)
+	}
 
 	return pubKey, pubKeySig, nil
 }
 
 // Decrypt returned the plaintext from value using the key.
 //
-// ciphertext contains three values. The first 32 bytes is the public empheral
-// key from the client. The next 12 byte is the used nonce for aes-gcm. All
-// later bytes are the encrypted vote.
-//
-// This function uses x25519 as described in rfc 7748. It uses hkdf with sha256
-// for the key derivation.
+// It is the same as DecryptPoll() without a poll id. So it can not decrypt
+// votes in a format, that is bound to a poll.
 func (c Crypto) Decrypt(privateKey []byte, ciphertext []byte) ([]byte, error) {
-	if len(ciphertext) < 1 {
-		return nil, fmt.Errorf("invalid cipher")
-	}
-
-	pubKeySize := ciphertext[0]
-
-	if len(ciphertext) < int(pubKeySize)+1+nonceSize {
-		return nil, fmt.Errorf("invalid cipher")
-	}
-
-	ephemeralPublicKey, err := c.curve.NewPublicKey(ciphertext[1 : 1+pubKeySize])
-	if err != nil {
-		return nil, fmt.Errorf("invalid publick key in ciphertext: %w", err)
-	}
-
-	nonce := ciphertext[1+pubKeySize : 1+pubKeySize+nonceSize]
-
-	privKey, err := c.curve.NewPrivateKey(privateKey)
-	if err != nil {
-		return nil, fmt.Errorf("initializing private key: %w", err)
-	}
+	return c.DecryptPoll(privateKey, "", ciphertext)
+}
 
-	sharedSecred, err := privKey.ECDH(ephemeralPublicKey)
+// DecryptPoll returned the plaintext from value using the key.
+//
+// The format of the ciphertext is detected with DetectFormat(). Each vote is
+// decrypted with the decryptor of its format, so votes in different formats
+// can be mixed in one poll.
+//
+// pollID is only used for formats, that bind the vote to a poll. Decrypting
+// fails, if the vote was encrypted for another poll.
+func (c Crypto) DecryptPoll(privateKey []byte, pollID string, ciphertext []byte) ([]byte, error) {
+	decrypt, err := c.PollDecrypter(privateKey, pollID)
 	if err != nil {
-		return nil, fmt.Errorf("creating shared secred: %w", err)
-	}
-
-	hkdf := hkdf.New(sha256.New, sharedSecred, nil, nil)
-	key := make([]byte, 32)
-	if _, err := io.ReadFull(hkdf, key); err != nil {
-		return nil, fmt.Errorf("generate key with hkdf: %w", err)
+		return nil, err
 	}
+	return decrypt(nil, ciphertext)
+}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("creating aes chipher: %w", err)
+// splitECIES returns the ephemeral public key, the nonce and the encrypted
+// vote of a ciphertext body.
+//
+// The body contains four values. The first byte is the size of the public
+// empheral key from the client. Then the key itself. The next 12 byte is the
+// used nonce for aes-gcm. All later bytes are the encrypted vote.
+func splitECIES(ciphertext []byte) (ephemeral, nonce, sealed []byte, err error) {
+	if len(ciphertext) < 1 {
+		return nil, nil, nil, fmt.Errorf("invalid cipher")
 	}
 
-	mode, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("create gcm mode: %w", err)
-	}
+	// The size is converted to int, since 1+pubKeySize overflows as byte.
+	pubKeySize := int(ciphertext[0])
 
-	plaintext, err := mode.Open(nil, nonce, ciphertext[1+pubKeySize+nonceSize:], nil)
-	if err != nil {
-		return nil, fmt.Errorf("decrypting ciphertext: %w", err)
+	if len(ciphertext) < pubKeySize+1+nonceSize {
+		return nil, nil, nil, fmt.Errorf("invalid cipher")
 	}
 
-	return plaintext, nil
+	ephemeral = ciphertext[1 : 1+pubKeySize]
+	nonce = ciphertext[1+pubKeySize : 1+pubKeySize+nonceSize]
+	return ephemeral, nonce, ciphertext[1+pubKeySize+nonceSize:], nil
 }
 
 // Sign returns the signature for the given data.
-func (c Crypto) Sign(value []byte) []byte {
-	return ed25519.Sign(c.mainKey, value)
+func (c Crypto) Sign(value []byte) ([]byte, error) {
+	return c.signer.Sign(value)
 }
 
 // Encrypt creates a cyphertext from plaintext using the given public key.
@@ -158,6 +178,43 @@ func (c Crypto) Sign(value []byte) []byte {
 // It returns the created public key (32 byte) the noonce (12 byte) and the
 // encrypted value of the given plaintext.
 func Encrypt(random io.Reader, curve ecdh.Curve, publicPollKey []byte, plaintext []byte) ([]byte, error) {
+	return encryptECIES(random, curve, publicPollKey, plaintext, nil)
+}
+
+// EncryptFormat encrypts a vote like Encrypt() but creates a ciphertext in the
+// given format.
+//
+// It can not be used for formats, that bind the vote to a poll. Use
+// EncryptForPoll() for them.
+func EncryptFormat(random io.Reader, curve ecdh.Curve, format Format, publicPollKey []byte, plaintext []byte) ([]byte, error) {
+	return EncryptForPoll(random, curve, format, "", publicPollKey, plaintext)
+}
+
+// EncryptForPoll encrypts a vote like EncryptFormat(). If the format binds the
+// vote to a poll, like FormatV2, the vote can only be decrypted for pollID.
+func EncryptForPoll(random io.Reader, curve ecdh.Curve, format Format, pollID string, publicPollKey []byte, plaintext []byte) ([]byte, error) {
+	switch format {
+	case FormatLegacy:
+		return encryptECIES(random, curve, publicPollKey, plaintext, nil)
+
+	case FormatV1, FormatV2:
+		additionalData, err := associatedData(format, pollID)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := encryptECIES(random, curve, publicPollKey, plaintext, additionalData)
+		if err != nil {
+			return nil, err
+		}
+		return append(versionHeader(format), body...), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+}
+
+func encryptECIES(random io.Reader, curve ecdh.Curve, publicPollKey []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
 	ephemeralPrivateKey, err := curve.GenerateKey(random)
 	if err != nil {
 		return nil, fmt.Errorf("creating ephemeral private key: %w", err)
@@ -201,15 +258,7 @@ func Encrypt(random io.Reader, curve ecdh.Curve, publicPollKey []byte, plaintext
 		return nil, fmt.Errorf("create gcm mode: %w", err)
 	}
 
-	encrypted := mode.Seal(nil, nonce, plaintext, nil)
+	encrypted := mode.Seal(nil, nonce, plaintext, additionalData)
 
 	return append(cipherPrefix, encrypted...), nil
 }
-
-// Verify checks that the the signature was created with pubKey for the message.
-//
-// This function is not needed or used by the decrypt service. It is only
-// implemented in this package for debugging and testing.
-func Verify(pubKey, message, signature []byte) bool {
-	return ed25519.Verify(pubKey, message, signature)
-}
@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// NewECDSASigner returns a Signer, that uses the main key as ECDSA P-256
+// private key. The signatures are r and s as 32 byte big endian values and
+// can be checked with Verify().
+//
+// It is the local signer for the FIPS mode. The same main key results in a
+// different public main key then with New().
+func NewECDSASigner(mainKey []byte, random io.Reader) (Signer, error) {
+	key, public, err := ecdsaPrivateKey(mainKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return ecdsaSigner{key: key, public: public, random: random}, nil
+}
+
+// ecdsaPrivateKey returns the main key as ECDSA P-256 key and its public key
+// in the uncompressed form.
+func ecdsaPrivateKey(mainKey []byte) (*ecdsa.PrivateKey, []byte, error) {
+	ecdhKey, err := ecdh.P256().NewPrivateKey(mainKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("main key is not a valid P-256 private key: %w", err)
+	}
+
+	public := ecdhKey.PublicKey().Bytes()
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(mainKey),
+	}
+
+	return key, public, nil
+}
+
+type ecdsaSigner struct {
+	key    *ecdsa.PrivateKey
+	public []byte
+	random io.Reader
+}
+
+func (s ecdsaSigner) Public() []byte {
+	return s.public
+}
+
+func (s ecdsaSigner) Sign(message []byte) ([]byte, error) {
+	hash := sha256.Sum256(message)
+	r, sig, err := ecdsa.Sign(s.random, s.key, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("signing with ecdsa: %w", err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	return signature, nil
+}
+
+// CheckFIPS returns an error, if the object uses algorithms, that are not
+// approved by FIPS 140-3. The poll keys have to use P-256 and the main key has
+// to be an ECDSA P-256 or a RSA-PSS key.
+//
+// All vote formats use only ECDH, HKDF with SHA-256 and AES-GCM.
+func (c Crypto) CheckFIPS() error {
+	if c.curve != ecdh.P256() {
+		return fmt.Errorf("poll keys use %s, expected P-256", c.curve)
+	}
+
+	algorithm, err := c.Algorithm()
+	if err != nil {
+		return fmt.Errorf("main key: %w", err)
+	}
+
+	if algorithm != ECDSAP256SHA256 && algorithm != RSAPSSSHA256 {
+		return fmt.Errorf("main key uses %s, expected %s or %s", algorithm, ECDSAP256SHA256, RSAPSSSHA256)
+	}
+
+	return nil
+}
+
+// FIPSModule returns true, if the binary uses a FIPS 140-3 validated
+// cryptographic module. See `make build-fips`.
+func FIPSModule() bool {
+	return fipsModule()
+}
@@ -0,0 +1,14 @@
+//go:build boringcrypto
+
+package crypto
+
+import (
+	"crypto/boring"
+
+	// Restricts tls to FIPS approved settings.
+	_ "crypto/tls/fipsonly"
+)
+
+func fipsModule() bool {
+	return boring.Enabled()
+}
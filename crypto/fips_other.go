@@ -0,0 +1,7 @@
+//go:build !boringcrypto
+
+package crypto
+
+func fipsModule() bool {
+	return false
+}
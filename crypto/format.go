@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Format is the format of an encrypted vote.
+type Format int
+
+const (
+	// FormatLegacy is the original format without a version header. The first
+	// byte is the size of the public key of the client.
+	FormatLegacy Format = iota
+
+	// FormatV1 uses the same encryption as FormatLegacy but starts with a
+	// version header. The header is used as associated data for aes-gcm, so it
+	// can not be changed.
+	FormatV1
+
+	// FormatV2 is like FormatV1 but also uses the poll id as associated data.
+	// A vote encrypted for one poll can not be decrypted for another poll.
+	FormatV2
+)
+
+// formats are all known formats.
+var formats = []Format{FormatLegacy, FormatV1, FormatV2}
+
+// versionMarker is the first byte of a ciphertext with a version header. The
+// legacy format starts with the size of the public key, which is never 0.
+const versionMarker = 0x00
+
+// versionHeaderSize is the size of the version marker and the version byte.
+const versionHeaderSize = 2
+
+// String returns a readable name of the format.
+func (f Format) String() string {
+	switch f {
+	case FormatLegacy:
+		return "legacy"
+	case FormatV1:
+		return "v1"
+	case FormatV2:
+		return "v2"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(f))
+	}
+}
+
+// DetectFormat returns the format of an encrypted vote.
+//
+// A ciphertext that starts with the version marker has the version in the
+// second byte. All other ciphertexts are in the legacy format.
+func DetectFormat(ciphertext []byte) (Format, error) {
+	if len(ciphertext) < 1 {
+		return 0, errors.New("invalid cipher, no data")
+	}
+
+	if ciphertext[0] != versionMarker {
+		return FormatLegacy, nil
+	}
+
+	if len(ciphertext) < versionHeaderSize {
+		return 0, errors.New("invalid cipher, version header too short")
+	}
+
+	format := Format(ciphertext[1])
+	switch format {
+	case FormatV1, FormatV2:
+		return format, nil
+	default:
+		return 0, fmt.Errorf("unknown ciphertext version %d", ciphertext[1])
+	}
+}
+
+func versionHeader(format Format) []byte {
+	return []byte{versionMarker, byte(format)}
+}
+
+// associatedData returns the associated data for aes-gcm of a format with a
+// version header.
+func associatedData(format Format, pollID string) ([]byte, error) {
+	header := versionHeader(format)
+	if format != FormatV2 {
+		return header, nil
+	}
+
+	if pollID == "" {
+		return nil, fmt.Errorf("format %s needs a poll id", format)
+	}
+	return append(header, pollID...), nil
+}
+
+// eciesBody returns the body of a ciphertext without the version header and
+// the associated data for aes-gcm of its format.
+func eciesBody(pollID string, ciphertext []byte) (body, additionalData []byte, err error) {
+	format, err := DetectFormat(ciphertext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch format {
+	case FormatLegacy:
+		return ciphertext, nil, nil
+
+	case FormatV1, FormatV2:
+		additionalData, err := associatedData(format, pollID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ciphertext[versionHeaderSize:], additionalData, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported format %s", format)
+	}
+}
+
+// ParseFormat returns the format for a name returned by Format.String().
+func ParseFormat(name string) (Format, error) {
+	for _, format := range formats {
+		if format.String() == name {
+			return format, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown format %s", name)
+}
+
+// AcceptFormats returns a function that accepts only ciphertexts in one of the
+// given formats. It can be used with decrypt.WithVoteFilter().
+func AcceptFormats(formats ...Format) func(ciphertext []byte) error {
+	return func(ciphertext []byte) error {
+		format, err := DetectFormat(ciphertext)
+		if err != nil {
+			return fmt.Errorf("invalid format")
+		}
+
+		for _, accepted := range formats {
+			if format == accepted {
+				return nil
+			}
+		}
+		return fmt.Errorf("format %s not accepted", format)
+	}
+}
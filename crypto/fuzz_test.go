@@ -0,0 +1,162 @@
+package crypto_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+)
+
+// The fuzz targets only check, that the functions for external input do not
+// panic. Run them with `go test ./crypto -fuzz FuzzDecryptPoll`.
+
+func FuzzDecryptPoll(f *testing.F) {
+	for _, curve := range []ecdh.Curve{ecdh.X25519(), ecdh.P256()} {
+		key, err := curve.GenerateKey(rand.Reader)
+		if err != nil {
+			f.Fatalf("creating poll key: %v", err)
+		}
+
+		for _, format := range []crypto.Format{crypto.FormatLegacy, crypto.FormatV1, crypto.FormatV2} {
+			ciphertext, err := crypto.EncryptForPoll(rand.Reader, curve, format, "1/5", key.PublicKey().Bytes(), []byte(`"Y"`))
+			if err != nil {
+				f.Fatalf("encrypting: %v", err)
+			}
+			f.Add(key.Bytes(), "1/5", ciphertext)
+		}
+	}
+	f.Add([]byte{}, "", []byte{})
+	f.Add(make([]byte, 32), "", []byte{0})
+	f.Add(make([]byte, 32), "", []byte{0, 2})
+	f.Add(make([]byte, 32), "", []byte{255})
+
+	x25519 := crypto.New(mockMainKey(), rand.Reader, ecdh.X25519())
+	p256 := crypto.New(mockMainKey(), rand.Reader, ecdh.P256())
+	f.Fuzz(func(t *testing.T, pollKey []byte, pollID string, ciphertext []byte) {
+		x25519.DecryptPoll(pollKey, pollID, ciphertext)
+		p256.DecryptPoll(pollKey, pollID, ciphertext)
+		p256.PartialDecrypt(pollKey, pollID, ciphertext)
+		crypto.DetectFormat(ciphertext)
+	})
+}
+
+func FuzzCombine(f *testing.F) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		f.Fatalf("creating poll key: %v", err)
+	}
+	pubKey := key.PublicKey().Bytes()
+
+	ciphertext, err := crypto.EncryptForPoll(rand.Reader, ecdh.P256(), crypto.FormatV2, "1", pubKey, []byte("vote"))
+	if err != nil {
+		f.Fatalf("encrypting: %v", err)
+	}
+
+	c := crypto.New(mockMainKey(), rand.Reader, ecdh.P256())
+	share, proof, err := c.PartialDecrypt(key.Bytes(), "1", ciphertext)
+	if err != nil {
+		f.Fatalf("PartialDecrypt: %v", err)
+	}
+
+	f.Add(pubKey, "1", ciphertext, share, proof)
+	f.Add([]byte{}, "", []byte{}, []byte{}, []byte{})
+
+	f.Fuzz(func(t *testing.T, pubKey []byte, pollID string, ciphertext, share, proof []byte) {
+		crypto.VerifyShare(pubKey, pollID, ciphertext, share, proof)
+		crypto.Combine(pollID, ciphertext, [][]byte{share, pubKey})
+		crypto.CombinePublicKeys([][]byte{pubKey, share})
+	})
+}
+
+func FuzzVerifyRing(f *testing.F) {
+	var ring [][]byte
+	var privateKeys [][]byte
+	for i := 0; i < 2; i++ {
+		key, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			f.Fatalf("generating key: %v", err)
+		}
+		ring = append(ring, key.PublicKey().Bytes())
+		privateKeys = append(privateKeys, key.Bytes())
+	}
+
+	signed, err := crypto.RingSign(rand.Reader, ring, 1, privateKeys[1], "1", []byte("ciphertext"))
+	if err != nil {
+		f.Fatalf("RingSign: %v", err)
+	}
+
+	f.Add(ring[0], "1", signed)
+	f.Add([]byte{}, "", []byte{})
+
+	f.Fuzz(func(t *testing.T, key []byte, pollID string, vote []byte) {
+		crypto.VerifyRing([][]byte{ring[0], ring[1]}, pollID, vote)
+		crypto.VerifyRing([][]byte{key}, pollID, vote)
+	})
+}
+
+func FuzzToken(f *testing.F) {
+	c := crypto.New(mockMainKey(), rand.Reader, nil)
+	pollKey := []byte("pollKey-pollKey-pollKey-pollKey-")
+
+	request, err := crypto.NewTokenRequest(rand.Reader)
+	if err != nil {
+		f.Fatalf("NewTokenRequest: %v", err)
+	}
+
+	evaluated, proof, err := c.IssueToken(pollKey, request.Blinded)
+	if err != nil {
+		f.Fatalf("IssueToken: %v", err)
+	}
+
+	pubKey, _, err := c.PublicTokenKey(pollKey)
+	if err != nil {
+		f.Fatalf("PublicTokenKey: %v", err)
+	}
+
+	token, err := request.Finalize(pubKey, evaluated, proof)
+	if err != nil {
+		f.Fatalf("Finalize: %v", err)
+	}
+
+	f.Add(pollKey, request.Blinded, token)
+	f.Add([]byte{}, []byte{}, []byte{})
+
+	f.Fuzz(func(t *testing.T, pollKey, blinded, token []byte) {
+		c.IssueToken(pollKey, blinded)
+		c.ValidateToken(pollKey, token)
+		request.Finalize(blinded, token, pollKey)
+	})
+}
+
+func FuzzKeys(f *testing.F) {
+	c := crypto.New(mockMainKey(), rand.Reader, nil)
+	message := []byte("message")
+	sig, err := c.Sign(message)
+	if err != nil {
+		f.Fatalf("Sign: %v", err)
+	}
+
+	mainKey, err := crypto.EncodeMainKey(mockMainKey(), false)
+	if err != nil {
+		f.Fatalf("EncodeMainKey: %v", err)
+	}
+
+	pollKey, err := crypto.EncodePollKey(ecdh.X25519(), mockPollKey())
+	if err != nil {
+		f.Fatalf("EncodePollKey: %v", err)
+	}
+
+	f.Add(c.PublicMainKey(), sig)
+	f.Add(mainKey, sig)
+	f.Add(pollKey, sig)
+	f.Add([]byte{}, []byte{})
+
+	f.Fuzz(func(t *testing.T, key, sig []byte) {
+		crypto.DecodeMainKey(key)
+		crypto.DecodePollKey(key)
+		crypto.KeyAlgorithm(key)
+		crypto.Verify(key, message, sig)
+		crypto.VerifyDelegation(key, "1", sig, sig)
+	})
+}
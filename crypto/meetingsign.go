@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"fmt"
+)
+
+// A meeting signing key is an ed25519 key, that signs the artifacts of all
+// polls of one meeting instead of the main key. It is derived from a meeting
+// secret and the meeting id. The main key signs a delegation for the meeting
+// id and the public meeting signing key.
+//
+// A leaked meeting signing key or a dispute about one meeting does not affect
+// the results of other meetings. A verifier can revoke the delegation of one
+// meeting without distrusting the main key.
+
+const (
+	// meetingSigningKeyInfo is the hkdf info to derive the meeting signing key
+	// from the meeting secret.
+	meetingSigningKeyInfo = "vote-decrypt meeting signing key"
+
+	// meetingDelegationLabel is the prefix of MeetingDelegationMessage().
+	meetingDelegationLabel = "vote-decrypt meeting signing key delegation"
+
+	// MinMeetingSecretSize is the minimum size of a meeting secret.
+	MinMeetingSecretSize = 32
+)
+
+// MeetingSigningKey returns the public meeting signing key of a meeting and
+// the delegation, the signature of MeetingDelegationMessage() with the main
+// key.
+func (c Crypto) MeetingSigningKey(secret []byte, meetingID string) (pubKey, delegation []byte, err error) {
+	key, err := meetingSigningKey(secret, meetingID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubKey = key.Public().(ed25519.PublicKey)
+	delegation, err = c.signer.Sign(MeetingDelegationMessage(meetingID, pubKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing delegation: %w", err)
+	}
+	return pubKey, delegation, nil
+}
+
+// SignMeeting signs value with the meeting signing key of a meeting.
+func (c Crypto) SignMeeting(secret []byte, meetingID string, value []byte) ([]byte, error) {
+	key, err := meetingSigningKey(secret, meetingID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ed25519.Sign(key, value), nil
+}
+
+// MeetingDelegationMessage returns the message, that the main key signs for a
+// meeting signing key.
+//
+// It is the label `vote-decrypt meeting signing key delegation`, the meeting
+// id prefixed by its length as 8 byte big endian integer and the ed25519
+// public meeting signing key.
+func MeetingDelegationMessage(meetingID string, meetingSigningKey []byte) []byte {
+	return delegationMessage(meetingDelegationLabel, meetingID, meetingSigningKey)
+}
+
+// VerifyMeetingDelegation checks, that the main key delegated the signatures
+// of the meeting to the meeting signing key.
+func VerifyMeetingDelegation(publicMainKey []byte, meetingID string, meetingSigningKey, delegation []byte) bool {
+	if len(meetingSigningKey) != ed25519.PublicKeySize {
+		return false
+	}
+	return Verify(publicMainKey, MeetingDelegationMessage(meetingID, meetingSigningKey), delegation)
+}
+
+// meetingSigningKey derives the meeting signing key from the meeting secret
+// and the meeting id.
+func meetingSigningKey(secret []byte, meetingID string) (ed25519.PrivateKey, error) {
+	if len(secret) < MinMeetingSecretSize {
+		return nil, fmt.Errorf("meeting secret has %d bytes, at least %d are needed", len(secret), MinMeetingSecretSize)
+	}
+
+	if meetingID == "" {
+		return nil, fmt.Errorf("empty meeting id")
+	}
+
+	key, err := deriveSigningKey(secret, meetingID, meetingSigningKeyInfo)
+	if err != nil {
+		return nil, fmt.Errorf("deriving meeting signing key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/metrics"
+)
+
+// aeadAESGCM is the label of aes-256-gcm, that all formats use at the moment.
+// New formats with another AEAD need their own label value.
+const aeadAESGCM = "aes-256-gcm"
+
+var (
+	metricDecryptSeconds = metrics.NewHistogram(
+		"vote_decrypt_decrypt_seconds",
+		"Time for decrypting one vote by ciphertext format and AEAD.",
+		metrics.DurationBuckets,
+		"format", "aead",
+	)
+
+	metricDecryptFailures = metrics.NewCounter(
+		"vote_decrypt_decrypt_failures_total",
+		"Number of votes, that could not be decrypted, by ciphertext format and AEAD.",
+		"format", "aead",
+	)
+
+	metricDecryptBytes = metrics.NewCounter(
+		"vote_decrypt_decrypt_plaintext_bytes_total",
+		"Size of the decrypted votes by ciphertext format and AEAD.",
+		"format", "aead",
+	)
+)
+
+// observeDecrypt counts the decryption of one vote.
+//
+// Ciphertexts with an unknown format get the format label `unknown`, so the
+// number of label values stays small.
+func observeDecrypt(ciphertext []byte, plaintextSize int, duration time.Duration, err error) {
+	format := "unknown"
+	if f, fErr := DetectFormat(ciphertext); fErr == nil {
+		format = f.String()
+	}
+
+	metricDecryptSeconds.Observe(duration.Seconds(), format, aeadAESGCM)
+	if err != nil {
+		metricDecryptFailures.Inc(format, aeadAESGCM)
+		return
+	}
+	metricDecryptBytes.Add(float64(plaintextSize), format, aeadAESGCM)
+}
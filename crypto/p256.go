@@ -0,0 +1,218 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"filippo.io/nistec"
+)
+
+// The point arithmetic uses filippo.io/nistec, the constant time P-256
+// implementation of the standard library. Scalars are big.Int values modulo
+// p256Order. The arithmetic of the scalars is not constant time.
+
+// p256Order is the order of the P-256 group.
+var p256Order, _ = new(big.Int).SetString("ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551", 16)
+
+// dleqProofSize is the size of a proof from proveDLEQ(). It is the challenge
+// and the response as 32 byte big endian values.
+const dleqProofSize = 64
+
+// proveDLEQ creates a Chaum-Pedersen proof with the Fiat-Shamir heuristic,
+// that pub is scalar * G and result is scalar * base. All points are P-256
+// points in the uncompressed form. label is the domain separation of the
+// challenge.
+func proveDLEQ(random io.Reader, label string, scalar *big.Int, pub, base, result []byte) ([]byte, error) {
+	basePoint, err := parsePoint(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base point")
+	}
+
+	k, err := randomScalar(random)
+	if err != nil {
+		return nil, fmt.Errorf("creating proof nonce: %w", err)
+	}
+
+	a := marshalPoint(baseMult(k))
+	b := marshalPoint(pointMult(basePoint, k))
+	challenge := dleqChallenge(label, pub, base, result, a, b)
+
+	// response = k - challenge * scalar mod n
+	response := new(big.Int).Mul(challenge, scalar)
+	response.Sub(k, response)
+	response.Mod(response, p256Order)
+
+	proof := make([]byte, dleqProofSize)
+	challenge.FillBytes(proof[:32])
+	response.FillBytes(proof[32:])
+	return proof, nil
+}
+
+// verifyDLEQ checks a proof from proveDLEQ().
+func verifyDLEQ(label string, pub, base, result, proof []byte) error {
+	if len(proof) != dleqProofSize {
+		return fmt.Errorf("proof has %d bytes, expected %d", len(proof), dleqProofSize)
+	}
+
+	pubPoint, err := parsePoint(pub)
+	if err != nil {
+		return fmt.Errorf("invalid public key")
+	}
+
+	basePoint, err := parsePoint(base)
+	if err != nil {
+		return fmt.Errorf("invalid base point")
+	}
+
+	resultPoint, err := parsePoint(result)
+	if err != nil {
+		return fmt.Errorf("invalid point")
+	}
+
+	challenge := new(big.Int).SetBytes(proof[:32])
+	response := new(big.Int).SetBytes(proof[32:])
+	if challenge.Cmp(p256Order) >= 0 || response.Cmp(p256Order) >= 0 {
+		return fmt.Errorf("invalid proof")
+	}
+
+	// a = response * G + challenge * pub
+	// b = response * base + challenge * result
+	a := addPoints(baseMult(response), pointMult(pubPoint, challenge))
+	b := addPoints(pointMult(basePoint, response), pointMult(resultPoint, challenge))
+	if a == nil || b == nil {
+		return fmt.Errorf("invalid proof")
+	}
+
+	if dleqChallenge(label, pub, base, result, a, b).Cmp(challenge) != 0 {
+		return fmt.Errorf("invalid proof")
+	}
+	return nil
+}
+
+// dleqChallenge returns the challenge of a proof from proveDLEQ().
+func dleqChallenge(label string, values ...[]byte) *big.Int {
+	hash := sha256.New()
+	hash.Write([]byte(label))
+	for _, value := range values {
+		hash.Write(value)
+	}
+
+	challenge := new(big.Int).SetBytes(hash.Sum(nil))
+	return challenge.Mod(challenge, p256Order)
+}
+
+// sumPoints adds P-256 points in the uncompressed form.
+func sumPoints(points [][]byte) ([]byte, error) {
+	var sum *nistec.P256Point
+	for i, encoded := range points {
+		p, err := parsePoint(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("point %d is not on the curve", i+1)
+		}
+
+		if sum == nil {
+			sum = p
+			continue
+		}
+
+		sum.Add(sum, p)
+		if isInfinity(sum) {
+			return nil, fmt.Errorf("sum is the point at infinity")
+		}
+	}
+
+	if sum == nil {
+		return nil, nil
+	}
+	return marshalPoint(sum), nil
+}
+
+// parsePoint decodes a P-256 point in the uncompressed form. Other forms and
+// the point at infinity are rejected.
+func parsePoint(b []byte) (*nistec.P256Point, error) {
+	if len(b) != 65 {
+		return nil, errors.New("not an uncompressed P-256 point")
+	}
+	return nistec.NewP256Point().SetBytes(b)
+}
+
+// addPoints adds two P-256 points. It returns nil for the point at infinity.
+func addPoints(p1, p2 *nistec.P256Point) []byte {
+	sum := nistec.NewP256Point().Add(p1, p2)
+	if isInfinity(sum) {
+		return nil
+	}
+	return marshalPoint(sum)
+}
+
+// isInfinity returns true, if p is the point at infinity.
+func isInfinity(p *nistec.P256Point) bool {
+	return len(p.Bytes()) == 1
+}
+
+// marshalPoint returns the uncompressed form of a point.
+func marshalPoint(p *nistec.P256Point) []byte {
+	return p.Bytes()
+}
+
+// pointMult multiplies a P-256 point with a scalar. The scalar has to be
+// smaller then p256Order.
+func pointMult(p *nistec.P256Point, scalar *big.Int) *nistec.P256Point {
+	// ScalarMult only fails, if the scalar is not 32 bytes.
+	product, _ := nistec.NewP256Point().ScalarMult(p, scalarBytes(scalar))
+	return product
+}
+
+// baseMult multiplies the generator of P-256 with a scalar. The scalar has to
+// be smaller then p256Order.
+func baseMult(scalar *big.Int) *nistec.P256Point {
+	product, _ := nistec.NewP256Point().ScalarBaseMult(scalarBytes(scalar))
+	return product
+}
+
+// scalarBytes returns a scalar as 32 byte big endian value.
+func scalarBytes(scalar *big.Int) []byte {
+	return scalar.FillBytes(make([]byte, 32))
+}
+
+// randomScalar returns a random scalar between 1 and the order of P-256.
+func randomScalar(random io.Reader) (*big.Int, error) {
+	buf := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(random, buf); err != nil {
+			return nil, fmt.Errorf("read from random source: %w", err)
+		}
+
+		k := new(big.Int).SetBytes(buf)
+		if k.Sign() > 0 && k.Cmp(p256Order) < 0 {
+			return k, nil
+		}
+	}
+}
+
+// hashToPoint maps data to a P-256 point with try and increment. label is the
+// domain separation of the hash. The hash is used as x coordinate of a point
+// with an even y coordinate.
+//
+// It is not constant time, so data must not be secret.
+func hashToPoint(label string, data []byte) *nistec.P256Point {
+	compressed := make([]byte, 33)
+	compressed[0] = 2 // Even y coordinate.
+
+	for counter := 0; ; counter++ {
+		hash := sha256.New()
+		hash.Write([]byte(label))
+		hash.Write(data)
+		hash.Write([]byte{byte(counter >> 8), byte(counter)})
+		hash.Sum(compressed[1:1])
+
+		// SetBytes fails, if x is not smaller then the field prime or if
+		// there is no point with this x coordinate.
+		if p, err := nistec.NewP256Point().SetBytes(compressed); err == nil {
+			return p
+		}
+	}
+}
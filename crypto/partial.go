@@ -0,0 +1,131 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"fmt"
+	"math/big"
+)
+
+// The partial decryption lets many trustees decrypt the votes of a poll
+// together. Each trustee has its own P-256 poll key. The votes are encrypted
+// for the sum of the public poll keys of all trustees, see
+// CombinePublicKeys(). No trustee can decrypt a vote alone.
+//
+// For each vote, a trustee returns its decryption share: the ephemeral key of
+// the vote multiplied with its private poll key, and a proof, that the share
+// was created with the key of its public poll key. The sum of the shares of all
+// trustees is the shared secret of the ECIES encryption. Combine() adds the
+// shares and decrypts the vote.
+
+// shareProofLabel is the domain separation of the challenge of a share proof.
+const shareProofLabel = "vote-decrypt decryption share"
+
+// PartialDecrypt returns the decryption share of a vote and the proof for the
+// share. privateKey is the P-256 poll key of the trustee.
+//
+// pollID is only used to detect the format. The share does not depend on it,
+// but a vote in a format, that binds the vote to a poll, can only be combined
+// with the correct poll id.
+//
+// It returns an error, if the crypto object does not use P-256 poll keys.
+func (c Crypto) PartialDecrypt(privateKey []byte, pollID string, ciphertext []byte) (share, proof []byte, err error) {
+	if c.curve != ecdh.P256() {
+		return nil, nil, fmt.Errorf("partial decryption needs P-256 poll keys, not %s", c.curve)
+	}
+
+	privKey, err := ecdh.P256().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("initializing private key: %w", err)
+	}
+	pubKey := privKey.PublicKey().Bytes()
+
+	ephemeral, err := ephemeralKey(pollID, ciphertext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ephemeralPoint, err := parsePoint(ephemeral)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+
+	scalar := new(big.Int).SetBytes(privateKey)
+	share = marshalPoint(pointMult(ephemeralPoint, scalar))
+
+	proof, err = proveDLEQ(c.random, shareProofLabel, scalar, pubKey, ephemeral, share)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating proof: %w", err)
+	}
+	return share, proof, nil
+}
+
+// VerifyShare checks, that share is the decryption share of the vote for the
+// public P-256 poll key of a trustee.
+func VerifyShare(publicPollKey []byte, pollID string, ciphertext, share, proof []byte) error {
+	ephemeral, err := ephemeralKey(pollID, ciphertext)
+	if err != nil {
+		return err
+	}
+
+	return verifyDLEQ(shareProofLabel, publicPollKey, ephemeral, share, proof)
+}
+
+// Combine decrypts a vote with the decryption shares of all trustees.
+//
+// The shares are not verified. Use VerifyShare() for each share first.
+func Combine(pollID string, ciphertext []byte, shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no shares")
+	}
+
+	sum, err := sumPoints(shares)
+	if err != nil {
+		return nil, fmt.Errorf("invalid share: %w", err)
+	}
+
+	body, additionalData, err := eciesBody(pollID, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	_, nonce, sealed, err := splitECIES(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The shared secret of P-256 is the x coordinate of the point.
+	return openECIES(nil, sum[1:33], nonce, sealed, additionalData)
+}
+
+// CombinePublicKeys returns the public poll key, that belongs to the public
+// P-256 poll keys of all trustees. The votes have to be encrypted with it.
+func CombinePublicKeys(publicPollKeys [][]byte) ([]byte, error) {
+	if len(publicPollKeys) == 0 {
+		return nil, fmt.Errorf("no public poll keys")
+	}
+
+	sum, err := sumPoints(publicPollKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public poll key: %w", err)
+	}
+	return sum, nil
+}
+
+// ephemeralKey returns the ephemeral P-256 key of a vote in the uncompressed
+// form.
+func ephemeralKey(pollID string, ciphertext []byte) ([]byte, error) {
+	body, _, err := eciesBody(pollID, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeral, _, _, err := splitECIES(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ecdh.P256().NewPublicKey(ephemeral); err != nil {
+		return nil, fmt.Errorf("invalid publick key in ciphertext: %w", err)
+	}
+	return ephemeral, nil
+}
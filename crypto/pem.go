@@ -0,0 +1,166 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// rawKeySize is the size of a main key or a poll key in the raw format.
+const rawKeySize = 32
+
+// EncodeMainKey returns the main key as PKCS#8 private key in the PEM format.
+// With fips, it is the ECDSA P-256 key of NewECDSASigner(), otherwise the
+// ed25519 key of New().
+func EncodeMainKey(mainKey []byte, fips bool) ([]byte, error) {
+	key, err := PrivateMainKey(mainKey, fips)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("encoding main key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// DecodeMainKey returns the main key from the content of a main key file.
+//
+// The file can contain the 32 raw bytes of the key or a PKCS#8 ed25519 or ECDSA
+// P-256 private key in the PEM format, as created by EncodeMainKey().
+func DecodeMainKey(data []byte) ([]byte, error) {
+	key, err := decodePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case nil:
+		return rawKey(data)
+
+	case ed25519.PrivateKey:
+		return key.Seed(), nil
+
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported ecdsa curve %s", key.Curve.Params().Name)
+		}
+		return key.D.FillBytes(make([]byte, rawKeySize)), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported main key type %T", key)
+	}
+}
+
+// EncodePollKey returns a private poll key as PKCS#8 private key in the PEM
+// format. curve is the curve of the poll key. If nil, it is x25519.
+func EncodePollKey(curve ecdh.Curve, pollKey []byte) ([]byte, error) {
+	if curve == nil {
+		curve = ecdh.X25519()
+	}
+
+	key, err := curve.NewPrivateKey(pollKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid poll key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("encoding poll key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// DecodePollKey returns a private poll key from the content of a file.
+//
+// The file can contain the 32 raw bytes of the key or a PKCS#8 x25519 or P-256
+// private key in the PEM format, as created by EncodePollKey(). It can also be
+// used for the private escrow key.
+func DecodePollKey(data []byte) ([]byte, error) {
+	key, err := decodePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case nil:
+		return rawKey(data)
+
+	case *ecdh.PrivateKey:
+		return key.Bytes(), nil
+
+	case *ecdsa.PrivateKey:
+		ecdhKey, err := key.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("converting ecdsa key: %w", err)
+		}
+		return ecdhKey.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported poll key type %T", key)
+	}
+}
+
+// EncodePublicMainKey returns the public main key as PKIX public key in the
+// PEM format.
+func EncodePublicMainKey(pubKey []byte) ([]byte, error) {
+	algorithm, err := KeyAlgorithm(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	der := pubKey
+	switch algorithm {
+	case Ed25519:
+		der, err = x509.MarshalPKIXPublicKey(ed25519.PublicKey(pubKey))
+
+	case ECDSAP256SHA256:
+		var key *ecdh.PublicKey
+		key, err = ecdh.P256().NewPublicKey(pubKey)
+		if err == nil {
+			der, err = x509.MarshalPKIXPublicKey(key)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encoding public main key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// decodePrivateKey parses a PEM encoded PKCS#8 private key. It returns nil
+// without an error, if data is not PEM encoded.
+func decodePrivateKey(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+			return nil, fmt.Errorf("invalid pem data")
+		}
+		return nil, nil
+	}
+
+	if block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("unsupported pem block %s, expected PRIVATE KEY", block.Type)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	return key, nil
+}
+
+// rawKey returns the key of a file in the raw format. It is the first 32 bytes
+// of the file, so a trailing newline is ignored.
+func rawKey(data []byte) ([]byte, error) {
+	if len(data) < rawKeySize {
+		return nil, fmt.Errorf("key has %d bytes, expected %d", len(data), rawKeySize)
+	}
+	return data[:rawKeySize], nil
+}
@@ -0,0 +1,195 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sync"
+	"time"
+)
+
+// PollDecrypter returns a function, that decrypts the votes of one poll like
+// DecryptPoll() and appends the plaintext to dst.
+//
+// The private key and the associated data of the formats are prepared once
+// for all votes. The key derivation uses pooled buffers and the plaintext is
+// written to dst. So, if dst has enough capacity, decrypting a vote only
+// allocates the ephemeral public key, the shared secret and the aes-gcm state
+// inside the standard library, which has no API for buffers of the caller.
+//
+// The votes are decrypted with ecdh on the curve of the Crypto object and hkdf
+// with sha256 for the key derivation. The function can be called from many
+// goroutines at the same time.
+func (c Crypto) PollDecrypter(privateKey []byte, pollID string) (func(dst, ciphertext []byte) ([]byte, error), error) {
+	privKey, err := c.curve.NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("initializing private key: %w", err)
+	}
+
+	p := pollDecrypter{
+		curve:   c.curve,
+		key:     privKey,
+		pollID:  pollID,
+		adV1:    versionHeader(FormatV1),
+		hasAdV2: pollID != "",
+	}
+	if p.hasAdV2 {
+		p.adV2, _ = associatedData(FormatV2, pollID)
+	}
+
+	return p.decrypt, nil
+}
+
+// pollDecrypter is the state of PollDecrypter().
+type pollDecrypter struct {
+	curve   ecdh.Curve
+	key     *ecdh.PrivateKey
+	pollID  string
+	adV1    []byte
+	adV2    []byte
+	hasAdV2 bool
+}
+
+func (p pollDecrypter) decrypt(dst, ciphertext []byte) (plaintext []byte, err error) {
+	start := time.Now()
+	defer func() {
+		observeDecrypt(ciphertext, len(plaintext)-len(dst), time.Since(start), err)
+	}()
+
+	body, additionalData, err := p.body(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeral, nonce, sealed, err := splitECIES(body)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralPublicKey, err := p.curve.NewPublicKey(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publick key in ciphertext: %w", err)
+	}
+
+	sharedSecred, err := p.key.ECDH(ephemeralPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating shared secred: %w", err)
+	}
+
+	return openECIES(dst, sharedSecred, nonce, sealed, additionalData)
+}
+
+// body is like eciesBody() with the prepared associated data.
+func (p pollDecrypter) body(ciphertext []byte) ([]byte, []byte, error) {
+	format, err := DetectFormat(ciphertext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch format {
+	case FormatLegacy:
+		return ciphertext, nil, nil
+
+	case FormatV1:
+		return ciphertext[versionHeaderSize:], p.adV1, nil
+
+	case FormatV2:
+		if !p.hasAdV2 {
+			return nil, nil, fmt.Errorf("format %s needs a poll id", format)
+		}
+		return ciphertext[versionHeaderSize:], p.adV2, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported format %s", format)
+	}
+}
+
+// openECIES decrypts a vote with the shared secret of the ephemeral key and the
+// poll key. The plaintext is appended to dst.
+func openECIES(dst, sharedSecred, nonce, sealed, additionalData []byte) ([]byte, error) {
+	k := keyDerivers.Get().(*keyDeriver)
+	defer keyDerivers.Put(k)
+
+	block, err := aes.NewCipher(k.derive(sharedSecred))
+	clear(k.key[:])
+	if err != nil {
+		return nil, fmt.Errorf("creating aes chipher: %w", err)
+	}
+
+	mode, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm mode: %w", err)
+	}
+
+	plaintext, err := mode.Open(dst, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting ciphertext: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// hmacBlockSize is the block size of sha256.
+const hmacBlockSize = 64
+
+// keyDeriver holds the buffers for the key derivation. All buffers are fields,
+// because slices of local arrays, that are given to hash.Hash, escape to the
+// heap.
+type keyDeriver struct {
+	h       hash.Hash
+	pad     [hmacBlockSize]byte
+	sum     [sha256.Size]byte
+	prk     [sha256.Size]byte
+	key     [sha256.Size]byte
+	zeros   [sha256.Size]byte
+	counter [1]byte
+}
+
+var keyDerivers = sync.Pool{
+	New: func() any {
+		return &keyDeriver{h: sha256.New(), counter: [1]byte{1}}
+	},
+}
+
+// derive returns the 32 byte aes key for the shared secret. It is the same as
+// reading 32 bytes from hkdf.New(sha256.New, secret, nil, nil), but uses the
+// buffers of the keyDeriver instead of allocating a hmac for each vote.
+//
+// The key is valid until the next call.
+func (k *keyDeriver) derive(secret []byte) []byte {
+	// Extract without a salt uses a key of zeros.
+	k.hmac(k.prk[:0], k.zeros[:], secret)
+
+	// The first block of expand without info is the hmac of the counter 1.
+	k.hmac(k.key[:0], k.prk[:], k.counter[:])
+	clear(k.prk[:])
+	return k.key[:]
+}
+
+// hmac appends hmac-sha256 of message with key to dst. key has at most
+// hmacBlockSize bytes.
+func (k *keyDeriver) hmac(dst, key, message []byte) []byte {
+	k.setPad(key, 0x36)
+	k.h.Reset()
+	k.h.Write(k.pad[:])
+	k.h.Write(message)
+	inner := k.h.Sum(k.sum[:0])
+
+	k.setPad(key, 0x5c)
+	k.h.Reset()
+	k.h.Write(k.pad[:])
+	k.h.Write(inner)
+	return k.h.Sum(dst)
+}
+
+// setPad sets pad to the key padded with zeros and xored with value.
+func (k *keyDeriver) setPad(key []byte, value byte) {
+	clear(k.pad[:])
+	copy(k.pad[:], key)
+	for i := range k.pad {
+		k.pad[i] ^= value
+	}
+}
@@ -0,0 +1,27 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestDeriveKey(t *testing.T) {
+	for _, size := range []int{0, 32, 64, 65} {
+		secret := bytes.Repeat([]byte{byte(size)}, size)
+
+		expected := make([]byte, 32)
+		if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, nil), expected); err != nil {
+			t.Fatalf("hkdf: %v", err)
+		}
+
+		key := keyDerivers.Get().(*keyDeriver).derive(secret)
+
+		if !bytes.Equal(key, expected) {
+			t.Errorf("secret with %d bytes: got key %x, expected %x", size, key, expected)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package crypto_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+)
+
+func TestPollDecrypter(t *testing.T) {
+	cr := crypto.New(mockMainKey(), rand.Reader, nil)
+	pollKey, err := cr.CreatePollKey()
+	if err != nil {
+		t.Fatalf("CreatePollKey: %v", err)
+	}
+
+	pubKey, _, err := cr.PublicPollKey(pollKey)
+	if err != nil {
+		t.Fatalf("PublicPollKey: %v", err)
+	}
+
+	ciphertext, err := crypto.EncryptForPoll(rand.Reader, ecdh.X25519(), crypto.FormatV2, "poll/1", pubKey, []byte(`"Y"`))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	decrypt, err := cr.PollDecrypter(pollKey, "poll/1")
+	if err != nil {
+		t.Fatalf("PollDecrypter: %v", err)
+	}
+
+	buf := make([]byte, 0, len(ciphertext))
+	plaintext, err := decrypt(buf, ciphertext)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+
+	if string(plaintext) != `"Y"` || &plaintext[0] != &buf[:1][0] {
+		t.Errorf("got plaintext %q, expected it in the buffer", plaintext)
+	}
+
+	t.Run("other poll", func(t *testing.T) {
+		other, err := cr.PollDecrypter(pollKey, "poll/2")
+		if err != nil {
+			t.Fatalf("PollDecrypter: %v", err)
+		}
+
+		if _, err := other(nil, ciphertext); err == nil {
+			t.Errorf("decrypted a vote of another poll")
+		}
+	})
+
+	t.Run("no allocations beyond the standard library", func(t *testing.T) {
+		// The allocations, that the standard library needs for each vote.
+		stdlib := testing.AllocsPerRun(100, func() {
+			ephemeral, _ := ecdh.X25519().NewPublicKey(ciphertext[3:35])
+			private, _ := ecdh.X25519().NewPrivateKey(pollKey)
+			private.ECDH(ephemeral)
+			block, _ := aes.NewCipher(pollKey)
+			cipher.NewGCM(block)
+		})
+		private := testing.AllocsPerRun(100, func() {
+			ecdh.X25519().NewPrivateKey(pollKey)
+		})
+
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := decrypt(buf, ciphertext); err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+		})
+
+		if extra := allocs - (stdlib - private); extra > 0 {
+			t.Errorf("decrypting a vote needs %g allocations more than the standard library", extra)
+		}
+	})
+}
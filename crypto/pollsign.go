@@ -0,0 +1,111 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// A poll signing key is an ed25519 key, that signs the artifacts of one poll
+// instead of the main key. It is derived from the poll key and the poll id, so
+// it does not have to be stored and is deleted with the poll key. The main key
+// signs a delegation for the poll id and the public poll signing key. A
+// verifier checks the delegation with the public main key and the artifacts
+// with the public poll signing key.
+//
+// A leaked poll signing key can only be used for its poll. A verifier can
+// revoke the delegation of one poll without distrusting the main key.
+
+const (
+	// pollSigningKeyInfo is the hkdf info to derive the poll signing key from
+	// the poll key.
+	pollSigningKeyInfo = "vote-decrypt poll signing key"
+
+	// delegationLabel is the prefix of DelegationMessage().
+	delegationLabel = "vote-decrypt poll signing key delegation"
+)
+
+// PollSigningKey returns the public poll signing key of a poll and the
+// delegation, the signature of DelegationMessage() with the main key.
+func (c Crypto) PollSigningKey(pollKey []byte, pollID string) (pubKey, delegation []byte, err error) {
+	key, err := pollSigningKey(pollKey, pollID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubKey = key.Public().(ed25519.PublicKey)
+	delegation, err = c.signer.Sign(DelegationMessage(pollID, pubKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing delegation: %w", err)
+	}
+	return pubKey, delegation, nil
+}
+
+// SignPoll signs value with the poll signing key of a poll.
+func (c Crypto) SignPoll(pollKey []byte, pollID string, value []byte) ([]byte, error) {
+	key, err := pollSigningKey(pollKey, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ed25519.Sign(key, value), nil
+}
+
+// DelegationMessage returns the message, that the main key signs for a poll
+// signing key.
+//
+// It is the label `vote-decrypt poll signing key delegation`, the poll id
+// prefixed by its length as 8 byte big endian integer and the ed25519 public
+// poll signing key.
+func DelegationMessage(pollID string, pollSigningKey []byte) []byte {
+	return delegationMessage(delegationLabel, pollID, pollSigningKey)
+}
+
+// delegationMessage returns the label, the id prefixed by its length as 8 byte
+// big endian integer and the public key.
+func delegationMessage(label, id string, pubKey []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(label)
+	binary.Write(&buf, binary.BigEndian, uint64(len(id)))
+	buf.WriteString(id)
+	buf.Write(pubKey)
+	return buf.Bytes()
+}
+
+// VerifyDelegation checks, that the main key delegated the signatures of the
+// poll to the poll signing key.
+func VerifyDelegation(publicMainKey []byte, pollID string, pollSigningKey, delegation []byte) bool {
+	if len(pollSigningKey) != ed25519.PublicKeySize {
+		return false
+	}
+	return Verify(publicMainKey, DelegationMessage(pollID, pollSigningKey), delegation)
+}
+
+// pollSigningKey derives the poll signing key from the poll key and the poll
+// id.
+func pollSigningKey(pollKey []byte, pollID string) (ed25519.PrivateKey, error) {
+	if len(pollKey) == 0 {
+		return nil, fmt.Errorf("empty poll key")
+	}
+
+	key, err := deriveSigningKey(pollKey, pollID, pollSigningKeyInfo)
+	if err != nil {
+		return nil, fmt.Errorf("deriving poll signing key: %w", err)
+	}
+	return key, nil
+}
+
+// deriveSigningKey derives an ed25519 key with hkdf-sha256 from secret with
+// the id as salt.
+func deriveSigningKey(secret []byte, id string, info string) (ed25519.PrivateKey, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, []byte(id), []byte(info)), seed); err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,216 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+
+	"filippo.io/nistec"
+)
+
+// A ring signature proves, that a vote was signed by one of the keys of a ring,
+// for example the keys of all eligible voters, without telling which one. The
+// signatures are linkable signatures (LSAG) on P-256.
+//
+// Each signature contains a key image, the private key of the signer
+// multiplied with a point, that is derived from the poll id. A voter has the
+// same key image for all votes of a poll, so a second vote of the same voter
+// can be detected. The key images of different polls can not be linked.
+//
+// A signed vote is the signature followed by the ciphertext:
+// `key image (65 bytes) | c0 (32 bytes) | s_i (32 bytes for each key of the
+// ring) | ciphertext`
+
+const (
+	// ringLabel is the domain separation of the challenges of a ring
+	// signature.
+	ringLabel = "vote-decrypt ring"
+
+	// ringHashLabel is the domain separation for hashing the poll id to the
+	// base point of the key image.
+	ringHashLabel = "vote-decrypt ring key image"
+)
+
+// RingSignatureSize returns the size of a ring signature for a ring with size
+// keys.
+func RingSignatureSize(size int) int {
+	return 65 + 32 + 32*size
+}
+
+// RingSign signs a ciphertext with a linkable ring signature and returns the
+// signed vote.
+//
+// ring are the public P-256 keys of the ring in the uncompressed form. The
+// private key has to belong to the public key ring[index]. The ring has to be
+// the same ring, the poll was started with, in the same order.
+func RingSign(random io.Reader, ring [][]byte, index int, privateKey []byte, pollID string, ciphertext []byte) ([]byte, error) {
+	if index < 0 || index >= len(ring) {
+		return nil, fmt.Errorf("index %d is not in the ring of %d keys", index, len(ring))
+	}
+
+	keys, err := parseRing(ring)
+	if err != nil {
+		return nil, err
+	}
+
+	x := new(big.Int).SetBytes(privateKey)
+	if x.Sign() == 0 || x.Cmp(p256Order) >= 0 {
+		return nil, fmt.Errorf("invalid private key")
+	}
+
+	if !bytes.Equal(marshalPoint(baseMult(x)), ring[index]) {
+		return nil, fmt.Errorf("private key does not belong to key %d of the ring", index)
+	}
+
+	base := hashToPoint(ringHashLabel, []byte(pollID))
+	imagePoint := pointMult(base, x)
+	image := marshalPoint(imagePoint)
+	ringHash := hashRing(ring)
+
+	alpha, err := randomScalar(random)
+	if err != nil {
+		return nil, fmt.Errorf("creating signature nonce: %w", err)
+	}
+
+	n := len(ring)
+	challenges := make([]*big.Int, n)
+	responses := make([]*big.Int, n)
+
+	challenges[(index+1)%n] = ringChallenge(
+		ringHash, pollID, image, ciphertext,
+		marshalPoint(baseMult(alpha)),
+		marshalPoint(pointMult(base, alpha)),
+	)
+
+	for i := (index + 1) % n; i != index; i = (i + 1) % n {
+		responses[i], err = randomScalar(random)
+		if err != nil {
+			return nil, fmt.Errorf("creating signature response: %w", err)
+		}
+
+		a, b := ringCommitments(keys[i], base, imagePoint, responses[i], challenges[i])
+		if a == nil || b == nil {
+			return nil, fmt.Errorf("invalid commitment")
+		}
+		challenges[(i+1)%n] = ringChallenge(ringHash, pollID, image, ciphertext, a, b)
+	}
+
+	// s = alpha - c * x mod n
+	responses[index] = new(big.Int).Mul(challenges[index], x)
+	responses[index].Sub(alpha, responses[index])
+	responses[index].Mod(responses[index], p256Order)
+
+	signed := make([]byte, RingSignatureSize(n), RingSignatureSize(n)+len(ciphertext))
+	copy(signed, image)
+	challenges[0].FillBytes(signed[65:97])
+	for i, s := range responses {
+		s.FillBytes(signed[97+32*i : 97+32*(i+1)])
+	}
+	return append(signed, ciphertext...), nil
+}
+
+// VerifyRing checks the ring signature of a signed vote from RingSign(). It
+// returns the key image of the signer and the ciphertext.
+func (c Crypto) VerifyRing(ring [][]byte, pollID string, vote []byte) (keyImage, ciphertext []byte, err error) {
+	return VerifyRing(ring, pollID, vote)
+}
+
+// VerifyRing checks the ring signature of a signed vote from RingSign(). It
+// returns the key image of the signer and the ciphertext.
+func VerifyRing(ring [][]byte, pollID string, vote []byte) (keyImage, ciphertext []byte, err error) {
+	n := len(ring)
+	if n == 0 {
+		return nil, nil, fmt.Errorf("empty ring")
+	}
+
+	if len(vote) < RingSignatureSize(n) {
+		return nil, nil, fmt.Errorf("vote is too short for a ring of %d keys", n)
+	}
+
+	keys, err := parseRing(ring)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyImage = vote[:65]
+	ciphertext = vote[RingSignatureSize(n):]
+
+	imagePoint, err := parsePoint(keyImage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid key image")
+	}
+
+	readScalar := func(b []byte) (*big.Int, error) {
+		v := new(big.Int).SetBytes(b)
+		if v.Sign() == 0 || v.Cmp(p256Order) >= 0 {
+			return nil, fmt.Errorf("invalid signature")
+		}
+		return v, nil
+	}
+
+	c0, err := readScalar(vote[65:97])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	base := hashToPoint(ringHashLabel, []byte(pollID))
+	ringHash := hashRing(ring)
+	challenge := c0
+	for i := 0; i < n; i++ {
+		s, err := readScalar(vote[97+32*i : 97+32*(i+1)])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		a, b := ringCommitments(keys[i], base, imagePoint, s, challenge)
+		if a == nil || b == nil {
+			return nil, nil, fmt.Errorf("invalid signature")
+		}
+		challenge = ringChallenge(ringHash, pollID, keyImage, ciphertext, a, b)
+	}
+
+	if challenge.Cmp(c0) != 0 {
+		return nil, nil, fmt.Errorf("invalid signature")
+	}
+	return keyImage, ciphertext, nil
+}
+
+// ringCommitments returns s * G + c * key and s * base + c * image.
+func ringCommitments(key, base, image *nistec.P256Point, s, c *big.Int) (a, b []byte) {
+	a = addPoints(baseMult(s), pointMult(key, c))
+	b = addPoints(pointMult(base, s), pointMult(image, c))
+	return a, b
+}
+
+// ringChallenge returns the challenge for the next key of the ring.
+func ringChallenge(ringHash []byte, pollID string, image, ciphertext, a, b []byte) *big.Int {
+	return dleqChallenge(ringLabel, ringHash, []byte(pollID), image, ciphertext, a, b)
+}
+
+// hashRing returns the hash of all keys of a ring.
+func hashRing(ring [][]byte) []byte {
+	hash := sha256.New()
+	for _, key := range ring {
+		hash.Write(key)
+	}
+	return hash.Sum(nil)
+}
+
+// parseRing decodes the public keys of a ring.
+func parseRing(ring [][]byte) ([]*nistec.P256Point, error) {
+	keys := make([]*nistec.P256Point, len(ring))
+	for i, key := range ring {
+		if len(key) != 65 {
+			return nil, fmt.Errorf("key %d of the ring is not an uncompressed P-256 key", i)
+		}
+
+		p, err := parsePoint(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d of the ring is not a P-256 point", i)
+		}
+		keys[i] = p
+	}
+	return keys, nil
+}
@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"fmt"
+	"io"
+)
+
+// Sealer encrypts data to the x25519 or P-256 public key of an auditor or an
+// escrow agent. It implements decrypt.Sealer.
+//
+// The sealed data is a vote in FormatV1. It can be opened with Decrypt() and
+// the private key of the auditor.
+type Sealer struct {
+	random    io.Reader
+	curve     ecdh.Curve
+	publicKey []byte
+}
+
+// NewSealer initializes a Sealer. A public key with 32 bytes is a x25519 key,
+// a public key with 65 bytes an uncompressed P-256 key.
+func NewSealer(publicKey []byte, random io.Reader) (Sealer, error) {
+	curve := ecdh.X25519()
+	if len(publicKey) == 65 {
+		curve = ecdh.P256()
+	}
+
+	if _, err := curve.NewPublicKey(publicKey); err != nil {
+		return Sealer{}, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	return Sealer{
+		random:    random,
+		curve:     curve,
+		publicKey: publicKey,
+	}, nil
+}
+
+// Curve returns the curve of the public key.
+func (s Sealer) Curve() ecdh.Curve {
+	return s.curve
+}
+
+// Seal encrypts data to the public key.
+func (s Sealer) Seal(data []byte) ([]byte, error) {
+	return EncryptFormat(s.random, s.curve, FormatV1, s.publicKey, data)
+}
@@ -0,0 +1,110 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/OpenSlides/vote-decrypt/redact"
+)
+
+// randomSample is the number of bytes, that are read to check the random
+// source.
+const randomSample = 2500
+
+// maxOneBitsDeviation is the allowed difference of the number of one bits in
+// the random sample from the expected half. It is six standard deviations, so
+// a healthy source fails about once in 500 million checks.
+const maxOneBitsDeviation = 425
+
+// SelfTest checks, that the random source, the main key and the poll keys
+// work. It returns an error that names the first check, that failed.
+//
+// It signs a message with the main key and verifies the signature, creates a
+// poll key, verifies the signature of its public key and encrypts and decrypts
+// a vote in each format.
+func (c Crypto) SelfTest() error {
+	if err := checkRandom(c.random); err != nil {
+		return fmt.Errorf("random source: %w", err)
+	}
+
+	message := []byte("vote-decrypt self-test")
+	signature, err := c.Sign(message)
+	if err != nil {
+		return fmt.Errorf("main key: signing: %w", err)
+	}
+
+	if !Verify(c.PublicMainKey(), message, signature) {
+		return fmt.Errorf("main key: signature is not valid for the public main key")
+	}
+
+	if Verify(c.PublicMainKey(), []byte("vote-decrypt self-test modified"), signature) {
+		return fmt.Errorf("main key: signature is valid for another message")
+	}
+
+	pollKey, err := c.CreatePollKey()
+	if err != nil {
+		return fmt.Errorf("poll key: creating: %w", err)
+	}
+
+	pubKey, pubKeySig, err := c.PublicPollKey(pollKey)
+	if err != nil {
+		return fmt.Errorf("poll key: deriving public key: %w", err)
+	}
+
+	if !Verify(c.PublicMainKey(), pubKey, pubKeySig) {
+		return fmt.Errorf("poll key: signature of the public poll key is not valid")
+	}
+
+	const pollID = "self-test"
+	vote := []byte(`"Y"`)
+	for _, format := range formats {
+		ciphertext, err := EncryptForPoll(c.random, c.curve, format, pollID, pubKey, vote)
+		if err != nil {
+			return fmt.Errorf("vote in format %s: encrypting: %w", format, err)
+		}
+
+		plaintext, err := c.DecryptPoll(pollKey, pollID, ciphertext)
+		if err != nil {
+			return fmt.Errorf("vote in format %s: decrypting: %w", format, err)
+		}
+
+		if !bytes.Equal(plaintext, vote) {
+			return fmt.Errorf("vote in format %s: decrypted %s, expected %s", format, redact.NewPlaintext(plaintext), redact.NewPlaintext(vote))
+		}
+	}
+
+	return nil
+}
+
+// checkRandom reads a sample from the random source and checks, that it looks
+// random. It detects sources, that fail, return constant data or repeat
+// themselves.
+func checkRandom(random io.Reader) error {
+	sample := make([]byte, randomSample)
+	if _, err := io.ReadFull(random, sample); err != nil {
+		return fmt.Errorf("reading: %w", err)
+	}
+
+	next := make([]byte, 32)
+	if _, err := io.ReadFull(random, next); err != nil {
+		return fmt.Errorf("reading: %w", err)
+	}
+
+	if bytes.Equal(sample[:32], next) {
+		return fmt.Errorf("two reads returned the same bytes")
+	}
+
+	var ones int
+	for _, b := range sample {
+		ones += bits.OnesCount8(b)
+	}
+
+	expected := randomSample * 8 / 2
+	if ones < expected-maxOneBitsDeviation || ones > expected+maxOneBitsDeviation {
+		return fmt.Errorf("sample of %d bits has %d one bits, expected about %d", randomSample*8, ones, expected)
+	}
+
+	return nil
+}
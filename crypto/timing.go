@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// TimingBucket is a coarse class of the time, that the encryption of one vote
+// takes on a device.
+//
+// Only the bucket should be reported by a client, not the measured time. The
+// exact time could help to tell devices apart, the bucket only tells, if the
+// device is fast enough for more expensive formats.
+type TimingBucket string
+
+const (
+	// TimingFast is below 10ms.
+	TimingFast TimingBucket = "fast"
+
+	// TimingMedium is from 10ms to below 50ms.
+	TimingMedium TimingBucket = "medium"
+
+	// TimingSlow is from 50ms to below 200ms.
+	TimingSlow TimingBucket = "slow"
+
+	// TimingVerySlow is 200ms or more.
+	TimingVerySlow TimingBucket = "very-slow"
+)
+
+// BucketOf returns the bucket of a duration.
+func BucketOf(d time.Duration) TimingBucket {
+	switch {
+	case d < 10*time.Millisecond:
+		return TimingFast
+	case d < 50*time.Millisecond:
+		return TimingMedium
+	case d < 200*time.Millisecond:
+		return TimingSlow
+	default:
+		return TimingVerySlow
+	}
+}
+
+// MeasureEncryption encrypts a vote of voteSize bytes runs times with
+// EncryptForPoll() and returns the bucket of the median time.
+//
+// It is meant for the voter client, for example compiled to WASM, to decide if
+// a device can use more expensive formats. The arguments should be the same as
+// for the real vote, so the measurement includes the format and the curve of
+// the poll. The encrypted test votes are dropped.
+func MeasureEncryption(random io.Reader, curve ecdh.Curve, format Format, pollID string, publicPollKey []byte, voteSize int, runs int) (TimingBucket, error) {
+	if runs < 1 {
+		return "", fmt.Errorf("runs has to be at least 1, got %d", runs)
+	}
+
+	plaintext := make([]byte, voteSize)
+	durations := make([]time.Duration, runs)
+	for i := range durations {
+		start := time.Now()
+		if _, err := EncryptForPoll(random, curve, format, pollID, publicPollKey, plaintext); err != nil {
+			return "", fmt.Errorf("encrypting test vote: %w", err)
+		}
+		durations[i] = time.Since(start)
+	}
+
+	slices.Sort(durations)
+	return BucketOf(durations[runs/2]), nil
+}
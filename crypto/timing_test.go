@@ -0,0 +1,56 @@
+package crypto_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+)
+
+func TestBucketOf(t *testing.T) {
+	for _, tt := range []struct {
+		duration time.Duration
+		expect   crypto.TimingBucket
+	}{
+		{0, crypto.TimingFast},
+		{10*time.Millisecond - 1, crypto.TimingFast},
+		{10 * time.Millisecond, crypto.TimingMedium},
+		{50*time.Millisecond - 1, crypto.TimingMedium},
+		{50 * time.Millisecond, crypto.TimingSlow},
+		{200*time.Millisecond - 1, crypto.TimingSlow},
+		{200 * time.Millisecond, crypto.TimingVerySlow},
+		{time.Minute, crypto.TimingVerySlow},
+	} {
+		if got := crypto.BucketOf(tt.duration); got != tt.expect {
+			t.Errorf("BucketOf(%s) = %s, expected %s", tt.duration, got, tt.expect)
+		}
+	}
+}
+
+func TestMeasureEncryption(t *testing.T) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("creating poll key: %v", err)
+	}
+
+	bucket, err := crypto.MeasureEncryption(rand.Reader, ecdh.X25519(), crypto.FormatV2, "1/5", key.PublicKey().Bytes(), 100, 5)
+	if err != nil {
+		t.Fatalf("MeasureEncryption: %v", err)
+	}
+
+	switch bucket {
+	case crypto.TimingFast, crypto.TimingMedium, crypto.TimingSlow, crypto.TimingVerySlow:
+	default:
+		t.Errorf("got unknown bucket %q", bucket)
+	}
+
+	if _, err := crypto.MeasureEncryption(rand.Reader, ecdh.X25519(), crypto.FormatV2, "1/5", []byte("invalid"), 100, 5); err == nil {
+		t.Errorf("MeasureEncryption with an invalid key did not fail")
+	}
+
+	if _, err := crypto.MeasureEncryption(rand.Reader, ecdh.X25519(), crypto.FormatV2, "1/5", key.PublicKey().Bytes(), 100, 0); err == nil {
+		t.Errorf("MeasureEncryption without runs did not fail")
+	}
+}
@@ -0,0 +1,184 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Voting tokens decouple the eligibility of a voter from the content of the
+// vote. The tokens are created with an oblivious pseudorandom function on P-256
+// like in Privacy Pass.
+//
+// A voter creates a random token nonce and sends it blinded to the service
+// (NewTokenRequest()). The vote service checks, that the voter is eligible,
+// and forwards the request. The service evaluates the blinded nonce with the
+// token key of the poll (IssueToken()). The voter removes the blinding
+// (TokenRequest.Finalize()) and puts the token at the beginning of the
+// plaintext of its vote. When the poll is stopped, the service validates the
+// token of each vote (ValidateToken()). The service never saw the nonce before,
+// so it can not link the token to the request.
+//
+// The token key is derived from the poll key, so it does not have to be
+// stored. Its public key is signed with the main key. Each issued token has a
+// proof, that it was created with this key, so the service can not mark the
+// tokens of single voters with another key.
+
+const (
+	// tokenNonceSize is the size of the random nonce of a token.
+	tokenNonceSize = 32
+
+	// TokenSize is the size of a token at the beginning of the plaintext of a
+	// vote. It is the nonce and the evaluated nonce as uncompressed P-256
+	// point.
+	TokenSize = tokenNonceSize + 65
+
+	// tokenKeyInfo is the hkdf info to derive the token key from the poll key.
+	tokenKeyInfo = "vote-decrypt token key"
+
+	// tokenHashLabel is the domain separation for hashing a nonce to a point.
+	tokenHashLabel = "vote-decrypt token nonce"
+
+	// tokenProofLabel is the domain separation of the challenge of a token
+	// proof.
+	tokenProofLabel = "vote-decrypt token"
+)
+
+// PublicTokenKey returns the public token key of a poll and its signature
+// created with the main key.
+func (c Crypto) PublicTokenKey(pollKey []byte) (pubKey []byte, pubKeySig []byte, err error) {
+	key, err := tokenKey(pollKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubKey = publicTokenKey(key)
+	pubKeySig, err = c.signer.Sign(pubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing public token key: %w", err)
+	}
+	return pubKey, pubKeySig, nil
+}
+
+// IssueToken evaluates a blinded token nonce from NewTokenRequest() with the
+// token key of the poll. It returns the evaluated point and a proof, that it
+// was created with the public token key.
+func (c Crypto) IssueToken(pollKey []byte, blinded []byte) (evaluated, proof []byte, err error) {
+	key, err := tokenKey(pollKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blindedPoint, err := parsePoint(blinded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("blinded token is not a P-256 point")
+	}
+
+	evaluated = marshalPoint(pointMult(blindedPoint, key))
+	proof, err = proveDLEQ(c.random, tokenProofLabel, key, publicTokenKey(key), blinded, evaluated)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating proof: %w", err)
+	}
+	return evaluated, proof, nil
+}
+
+// ValidateToken returns an error, if the token was not issued with the token
+// key of the poll. token has to be the first TokenSize bytes of a plaintext.
+func (c Crypto) ValidateToken(pollKey []byte, token []byte) error {
+	if len(token) != TokenSize {
+		return fmt.Errorf("token has %d bytes, expected %d", len(token), TokenSize)
+	}
+
+	key, err := tokenKey(pollKey)
+	if err != nil {
+		return err
+	}
+
+	h := hashToPoint(tokenHashLabel, token[:tokenNonceSize])
+	expected := marshalPoint(pointMult(h, key))
+	if subtle.ConstantTimeCompare(expected, token[tokenNonceSize:]) != 1 {
+		return fmt.Errorf("invalid token")
+	}
+	return nil
+}
+
+// TokenSize returns the size of a token at the beginning of a plaintext.
+func (c Crypto) TokenSize() int {
+	return TokenSize
+}
+
+// TokenRequest is the request of a voter for a token. Blinded has to be sent
+// to the service. The nonce and the blinding factor have to be kept secret
+// until the token is used.
+type TokenRequest struct {
+	Blinded []byte
+
+	nonce []byte
+	blind *big.Int
+}
+
+// NewTokenRequest creates a random token nonce and blinds it.
+func NewTokenRequest(random io.Reader) (TokenRequest, error) {
+	nonce := make([]byte, tokenNonceSize)
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return TokenRequest{}, fmt.Errorf("read from random source: %w", err)
+	}
+
+	blind, err := randomScalar(random)
+	if err != nil {
+		return TokenRequest{}, fmt.Errorf("creating blinding factor: %w", err)
+	}
+
+	h := hashToPoint(tokenHashLabel, nonce)
+	return TokenRequest{
+		Blinded: marshalPoint(pointMult(h, blind)),
+		nonce:   nonce,
+		blind:   blind,
+	}, nil
+}
+
+// Finalize checks the proof of an issued token and removes the blinding. It
+// returns the token, that has to be put at the beginning of the plaintext of
+// the vote.
+//
+// publicTokenKey has to be the public token key of the poll. Its signature has
+// to be checked with the public main key.
+func (r TokenRequest) Finalize(publicTokenKey, evaluated, proof []byte) ([]byte, error) {
+	if err := verifyDLEQ(tokenProofLabel, publicTokenKey, r.Blinded, evaluated, proof); err != nil {
+		return nil, fmt.Errorf("token was not issued with the token key: %w", err)
+	}
+
+	evaluatedPoint, err := parsePoint(evaluated)
+	if err != nil {
+		return nil, fmt.Errorf("evaluated token is not a P-256 point")
+	}
+
+	unblind := new(big.Int).ModInverse(r.blind, p256Order)
+	return append(append([]byte{}, r.nonce...), marshalPoint(pointMult(evaluatedPoint, unblind))...), nil
+}
+
+// tokenKey derives the token key of a poll from the poll key.
+func tokenKey(pollKey []byte) (*big.Int, error) {
+	if len(pollKey) == 0 {
+		return nil, fmt.Errorf("empty poll key")
+	}
+
+	// 16 additional bytes make the bias of the reduction negligible.
+	buf := make([]byte, 48)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, pollKey, nil, []byte(tokenKeyInfo)), buf); err != nil {
+		return nil, fmt.Errorf("deriving token key: %w", err)
+	}
+
+	key := new(big.Int).SetBytes(buf)
+	key.Mod(key, new(big.Int).Sub(p256Order, big.NewInt(1)))
+	return key.Add(key, big.NewInt(1)), nil
+}
+
+// publicTokenKey returns the public key of a token key.
+func publicTokenKey(key *big.Int) []byte {
+	return marshalPoint(baseMult(key))
+}
@@ -5,20 +5,34 @@
 package decrypt
 
 import (
+	"bytes"
 	"context"
-	"crypto/rand"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math"
-	"math/big"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/merkle"
+	"github.com/OpenSlides/vote-decrypt/metrics"
+	"github.com/OpenSlides/vote-decrypt/spill"
+	"github.com/OpenSlides/vote-decrypt/version"
 )
 
 // Decrypt holds the internal state of the decrypt component.
@@ -27,10 +41,35 @@ type Decrypt struct {
 	store  Store
 
 	maxVotes          int // maximum votes per poll.
+	maxVoteSize       int // maximum size of one encrypted vote in bytes.
+	maxPollSize       int // maximum size of all encrypted votes of a poll in bytes.
+	maxPlaintextSize  int // maximum size of one decrypted vote in bytes.
+	oversizePolicy    OversizePolicy
 	decryptWorkers    int
-	random            io.Reader
-	listToContent     func(pollID string, decrypted [][]byte) ([]byte, error) // See WithListToContent()
-	decryptErrorValue []byte                                                  // Value to use if a vote can not be decrypted.
+	resultToContent   func(result Result) ([]byte, error) // See WithResultToContent()
+	decryptErrorValue []byte                              // Value to use if a vote can not be decrypted.
+	auditLog          AuditLog
+	removeMainKey     func() error       // See WithRemoveMainKey()
+	deletionDelay     time.Duration      // See WithDeletionDelay()
+	now               func() time.Time   // See WithClock()
+	readOnly          atomic.Bool        // See SetReadOnly()
+	attestor          Attestor           // See WithAttestor()
+	binaryHash        []byte             // See WithAttestor()
+	voteFilters       []VoteFilter       // See WithVoteFilter()
+	sealer            Sealer             // See WithSealer()
+	stopKey           ed25519.PublicKey  // See WithStopKey()
+	commitment        bool               // See WithCommitment()
+	idValidators      []IDValidator      // See WithIDValidator()
+	resultWriters     []ResultWriter     // See WithResultWriter()
+	budget            budget             // See WithMaxParallelStops() and WithMemoryBudget()
+	pollSigningKeys   bool               // See WithPollSigningKeys()
+	meetingSecret     []byte             // See WithMeetingSigningKeys()
+	coSigningKey      ed25519.PrivateKey // See WithCoSigningKey()
+	monitors          []Monitor          // See WithMonitor()
+	tuner             *tuner             // See WithAutoTune()
+	features          map[Feature]bool   // See WithFeatures()
+	spillDir          string             // See WithSpill()
+	spillSize         int                // See WithSpill()
 }
 
 // New returns the initialized decrypt component.
@@ -39,10 +78,14 @@ func New(crypto Crypto, store Store, options ...Option) *Decrypt {
 		crypto:            crypto,
 		store:             store,
 		decryptWorkers:    runtime.GOMAXPROCS(-1),
-		random:            rand.Reader,
 		maxVotes:          math.MaxInt,
-		listToContent:     jsonListToContent,
+		maxVoteSize:       math.MaxInt,
+		maxPollSize:       math.MaxInt,
+		maxPlaintextSize:  math.MaxInt,
+		resultToContent:   jsonResultToContent,
 		decryptErrorValue: []byte(`{"error":"encryption not valid"}`),
+		auditLog:          logAuditLog{},
+		now:               time.Now,
 	}
 
 	for _, o := range options {
@@ -64,12 +107,18 @@ func (d *Decrypt) PublicMainKey(ctx context.Context) []byte {
 // main key.
 //
 // If the method is called multiple times with the same pollID, it returns the
-// same public key. This is at least true until Clear() is called.
-func (d *Decrypt) Start(ctx context.Context, pollID string) (pubKey []byte, pubKeySig []byte, err error) {
+// same public key. This is at least true until Clear() is called. The options
+// are only used on the first call.
+func (d *Decrypt) Start(ctx context.Context, pollID string, options ...StartOption) (pubKey []byte, pubKeySig []byte, err error) {
 	if err := d.validateID(pollID); err != nil {
 		return nil, nil, fmt.Errorf("invalid poll id: %w", err)
 	}
 
+	var config StartConfig
+	for _, o := range options {
+		o(&config)
+	}
+
 	// TODO: Load Key and CreatePoll Key have probably be atomic.
 	pollKey, err := d.store.LoadKey(pollID)
 	if err != nil {
@@ -77,156 +126,3065 @@ func (d *Decrypt) Start(ctx context.Context, pollID string) (pubKey []byte, pubK
 			return nil, nil, fmt.Errorf("loading poll key: %w", err)
 		}
 
+		if d.readOnly.Load() {
+			return nil, nil, fmt.Errorf("can not create poll key: %w", errorcode.ReadOnly)
+		}
+
+		if err := d.checkStartConfig(config); err != nil {
+			return nil, nil, err
+		}
+
 		key, err := d.crypto.CreatePollKey()
 		if err != nil {
 			return nil, nil, fmt.Errorf("creating poll key: %w", err)
 		}
 
-		pollKey = key
-		if err := d.store.SaveKey(pollID, key); err != nil {
-			return nil, nil, fmt.Errorf("saving poll key: %w", err)
+		pollKey = key
+		if err := d.store.SaveKey(pollID, key); err != nil {
+			return nil, nil, fmt.Errorf("saving poll key: %w", err)
+		}
+
+		if err := d.saveConfig(pollID, config); err != nil {
+			return nil, nil, fmt.Errorf("saving poll config: %w", err)
+		}
+
+		message := "created poll key"
+		if config.NotBefore != nil {
+			message += " not_before=" + config.NotBefore.Format(time.RFC3339)
+		}
+		if config.Trustee {
+			message += " trustee"
+		}
+		if config.Tokens {
+			message += " tokens"
+		}
+		if len(config.Ring) > 0 {
+			message += fmt.Sprintf(" ring=%d", len(config.Ring))
+		}
+		if config.Revote == RevoteLast {
+			message += " revote=last"
+		}
+
+		if err := d.record(ctx, "start", pollID, message); err != nil {
+			return nil, nil, fmt.Errorf("writing audit log: %w", err)
+		}
+	}
+
+	if err := d.checkRevoked(pollID, pollKey); err != nil {
+		return nil, nil, err
+	}
+
+	pubKey, pubKeySig, err = d.publicPollKey(pollID, pollKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing pub key: %w", err)
+	}
+
+	// Log the pubKey as base64 as long as the backend does not support his
+	log.Printf("public poll key for poll %s is %s", pollID, base64.StdEncoding.EncodeToString(pubKey))
+	return pubKey, pubKeySig, nil
+}
+
+// ImportKey starts the poll with a private poll key, that was created outside
+// of the service, for example in an air-gapped key ceremony. Returns the public
+// poll key and its signature like Start().
+//
+// The key has to be valid for the crypto backend. Otherwise an error with
+// errorcode.Invalid is returned. If the poll already has a key, the method
+// returns the public key, if it is the same key, and an error with
+// errorcode.Exist otherwise. The options are the same as for Start().
+func (d *Decrypt) ImportKey(ctx context.Context, pollID string, pollKey []byte, options ...StartOption) (pubKey []byte, pubKeySig []byte, err error) {
+	if err := d.validateID(pollID); err != nil {
+		return nil, nil, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	if len(bytes.Trim(pollKey, "\x00")) == 0 {
+		return nil, nil, fmt.Errorf("empty poll key: %w", errorcode.Invalid)
+	}
+
+	var config StartConfig
+	for _, o := range options {
+		o(&config)
+	}
+
+	if err := d.checkStartConfig(config); err != nil {
+		return nil, nil, err
+	}
+
+	pubKey, pubKeySig, err = d.publicPollKey(pollID, pollKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid poll key: %w: %w", err, errorcode.Invalid)
+	}
+
+	if err := d.checkRevoked(pollID, pollKey); err != nil {
+		return nil, nil, err
+	}
+
+	existing, err := d.store.LoadKey(pollID)
+	if err == nil {
+		if subtle.ConstantTimeCompare(existing, pollKey) != 1 {
+			return nil, nil, fmt.Errorf("poll has a different key: %w", errorcode.Exist)
+		}
+		return pubKey, pubKeySig, nil
+	}
+
+	if !errors.Is(err, errorcode.NotExist) {
+		return nil, nil, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	if d.readOnly.Load() {
+		return nil, nil, fmt.Errorf("can not import poll key: %w", errorcode.ReadOnly)
+	}
+
+	if err := d.store.SaveKey(pollID, pollKey); err != nil {
+		return nil, nil, fmt.Errorf("saving poll key: %w", err)
+	}
+
+	if err := d.saveConfig(pollID, config); err != nil {
+		return nil, nil, fmt.Errorf("saving poll config: %w", err)
+	}
+
+	message := "imported poll key pub_key=" + base64.StdEncoding.EncodeToString(pubKey)
+	if config.NotBefore != nil {
+		message += " not_before=" + config.NotBefore.Format(time.RFC3339)
+	}
+	if config.Trustee {
+		message += " trustee"
+	}
+	if config.Tokens {
+		message += " tokens"
+	}
+	if len(config.Ring) > 0 {
+		message += fmt.Sprintf(" ring=%d", len(config.Ring))
+	}
+	if config.Revote == RevoteLast {
+		message += " revote=last"
+	}
+
+	if err := d.record(ctx, "import-key", pollID, message); err != nil {
+		return nil, nil, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	log.Printf("public poll key for poll %s is %s", pollID, base64.StdEncoding.EncodeToString(pubKey))
+	return pubKey, pubKeySig, nil
+}
+
+// Stop takes a list of ecrypted votes, decryptes them and returns them in a
+// pseudorandom order together with a signature.
+//
+// The order is derived from a secret seed and the encrypted votes. It does not
+// depend on the order of voteList. The seed is written sealed to the audit
+// log. See WithSealer().
+//
+// If the function is called multiple times with the same pollID and voteList,
+// it returns the same output. But if fails if it is called with different
+// votes.
+//
+// The poll is stopped, when the signature of the result is saved in the store.
+// If writing the commitment, the audit log or a result writer fails after
+// that, Stop() returns an error, but the poll stays stopped. Calling Stop()
+// again with the same votes repeats these steps.
+//
+// With WithWeights(), a weight can be attached to each vote. The weights are
+// part of the result in the same order as the votes.
+//
+// If the poll was started with WithNotBefore(), Stop() returns an error with
+// errorcode.Invalid before this time. The same error is returned, if the poll
+// key was revoked with Revoke().
+//
+// If the decrypt component was initialized with WithStopKey(), the request has
+// to be signed. Otherwise an error with errorcode.Forbidden is returned before
+// any work starts.
+//
+// TODO: This implementation is wrong. Not the output has to be hashed and saved, but the input.
+func (d *Decrypt) Stop(ctx context.Context, pollID string, voteList [][]byte, options ...StopOption) (decryptedContent, signature []byte, err error) {
+	return d.stopPrefetched(ctx, nil, pollID, voteList, options...)
+}
+
+// stop is Stop() with a worker pool, that can be shared by many polls. If
+// progress is not nil, it is called after each decrypted vote. If prefetched
+// is not nil, the data of the poll is taken from it instead of the store.
+func (d *Decrypt) stop(ctx context.Context, pool *workerPool, progress func(), prefetched *prefetch, pollID string, voteList [][]byte, options ...StopOption) (decryptedContent, signature []byte, err error) {
+	if d.readOnly.Load() {
+		return nil, nil, fmt.Errorf("can not stop poll: %w", errorcode.ReadOnly)
+	}
+
+	if err := d.validateID(pollID); err != nil {
+		return nil, nil, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	var stopConfig StopConfig
+	for _, o := range options {
+		o(&stopConfig)
+	}
+
+	if d.stopKey != nil {
+		message := TaggedStopRequestMessage(pollID, voteList, stopConfig.Weights, stopConfig.Tags)
+		if !ed25519.Verify(d.stopKey, message, stopConfig.Signature) {
+			return nil, nil, fmt.Errorf("invalid request signature: %w", errorcode.Forbidden)
+		}
+	}
+
+	if err := validateWeights(stopConfig.Weights, len(voteList)); err != nil {
+		return nil, nil, fmt.Errorf("invalid weights: %w", err)
+	}
+
+	if err := validateTags(stopConfig.Tags, len(voteList)); err != nil {
+		return nil, nil, fmt.Errorf("invalid tags: %w", err)
+	}
+
+	if stopConfig.Priority < MinPriority || stopConfig.Priority > MaxPriority {
+		return nil, nil, fmt.Errorf("priority %d is not between %d and %d: %w", stopConfig.Priority, MinPriority, MaxPriority, errorcode.Invalid)
+	}
+
+	var data pollData
+	if prefetched != nil {
+		data, err = prefetched.wait()
+	} else {
+		data, err = d.loadPoll(pollID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	pollKey, replacements, config := data.key, data.replacements, data.config
+
+	if config.Trustee {
+		return nil, nil, fmt.Errorf("poll was started for a trustee, use PartialDecrypt: %w", errorcode.Invalid)
+	}
+
+	if config.NotBefore != nil && d.now().Before(*config.NotBefore) {
+		return nil, nil, fmt.Errorf("poll can not be stopped before %s: %w", config.NotBefore.Format(time.RFC3339), errorcode.Invalid)
+	}
+
+	if err := d.checkLimits(voteList); err != nil {
+		return nil, nil, fmt.Errorf("checking limits: %w", err)
+	}
+
+	release, err := d.budget.acquire(ctx, stopConfig.Priority, runMemory(voteList))
+	if err != nil {
+		return nil, nil, fmt.Errorf("waiting for resources: %w", err)
+	}
+	defer release()
+
+	for _, m := range d.monitors {
+		m.StopStarted(pollID)
+	}
+
+	var codes []byte
+	if d.commitment {
+		codes = make([]byte, len(voteList)*sha256.Size)
+	}
+
+	decrypted, weights, invalid, superseded, err := d.decryptVotes(pool, progress, pollKey, replacements, pollID, voteList, stopConfig, config, codes)
+
+	var invalidCount int
+	for _, count := range invalid {
+		invalidCount += count
+	}
+	for _, m := range d.monitors {
+		m.StopFinished(pollID, len(voteList), invalidCount, err)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypting votes: %w", err)
+	}
+
+	var leaves [][]byte
+	var root []byte
+	if d.commitment {
+		leaves = commitmentLeaves(codes)
+		root = merkle.ParallelRoot(leaves, pool.workers)
+	}
+
+	// TODO: The content is assembled and signed in memory, also with
+	// WithSpill(). Spilling it during the assembly needs a streaming Stop and a
+	// signature over a hash of the content.
+	decryptedContent, err = d.resultToContent(Result{
+		ID:             pollID,
+		Votes:          decrypted,
+		Metadata:       config.Metadata,
+		Weights:        weights,
+		NotBefore:      config.NotBefore,
+		Invalid:        invalid,
+		Superseded:     superseded,
+		CiphertextRoot: root,
+		Election:       config.Election,
+		Features:       config.features(),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating content: %w", err)
+	}
+
+	signature, err = d.signPoll(pollID, pollKey, decryptedContent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing content: %w", err)
+	}
+
+	// This has to be the last step, that can reject the call, to protect
+	// agains timing attacks. All steps before it have to be run, even when the
+	// calll is doomed to fail in this step.
+	//
+	// The steps after it only run for an accepted call, so a rejected call
+	// does not write a commitment, an audit entry or a result. If one of them
+	// fails, the poll is already stopped. The error is returned and a
+	// repeated call with the same votes runs them again.
+	if err := d.store.ValidateSignature(pollID, signature); err != nil {
+		if errors.Is(err, errorcode.Invalid) {
+			return nil, nil, fmt.Errorf("stop was called with different parameters before")
+		}
+		return nil, nil, fmt.Errorf("validate signature: %w", err)
+	}
+
+	if d.commitment {
+		if err := d.store.SaveCommitment(pollID, bytes.Join(leaves, nil)); err != nil && !errors.Is(err, errorcode.Exist) {
+			return nil, nil, fmt.Errorf("saving commitment: %w", err)
+		}
+	}
+
+	if err := d.recordOrderSeed(ctx, pollID, pollKey); err != nil {
+		return nil, nil, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	if err := d.record(ctx, "stop", pollID, fmt.Sprintf("decrypted %d votes", len(voteList))); err != nil {
+		return nil, nil, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	for _, w := range d.resultWriters {
+		if err := writeResult(w, pollID, decrypted, weights, decryptedContent, signature); err != nil {
+			return nil, nil, fmt.Errorf("writing result: %w", err)
+		}
+	}
+
+	return decryptedContent, signature, nil
+}
+
+// pollData is the data of a poll from the store, that is needed to stop it.
+type pollData struct {
+	key          []byte
+	replacements [][]byte
+	config       StartConfig
+}
+
+// loadPoll loads the data of a poll from the store. It fails, if the poll key
+// was revoked.
+func (d *Decrypt) loadPoll(pollID string) (pollData, error) {
+	pollKey, err := d.store.LoadKey(pollID)
+	if err != nil {
+		return pollData{}, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	if err := d.checkRevoked(pollID, pollKey); err != nil {
+		return pollData{}, err
+	}
+
+	config, err := d.loadConfig(pollID)
+	if err != nil {
+		return pollData{}, fmt.Errorf("loading poll config: %w", err)
+	}
+
+	replacements, err := d.replacementKeys(pollID)
+	if err != nil {
+		return pollData{}, fmt.Errorf("loading replacement keys: %w", err)
+	}
+
+	return pollData{key: pollKey, replacements: replacements, config: config}, nil
+}
+
+// prefetch loads the data of a poll in the background, so the store latency
+// of the next poll of a multi-poll run is hidden behind the decryption of the
+// current one.
+type prefetch struct {
+	done chan struct{}
+	data pollData
+	err  error
+}
+
+// prefetch starts to load the data of the poll.
+func (d *Decrypt) prefetch(pollID string) *prefetch {
+	p := &prefetch{done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		p.data, p.err = d.loadPoll(pollID)
+	}()
+	return p
+}
+
+// wait returns the data of the poll, after it was loaded.
+func (p *prefetch) wait() (pollData, error) {
+	<-p.done
+	return p.data, p.err
+}
+
+// discard waits for the loading and drops the data, so the key is not kept in
+// memory by an unused prefetch.
+func (p *prefetch) discard() {
+	<-p.done
+	p.data = pollData{}
+}
+
+// writeResult passes the decrypted votes of a poll to a result writer.
+func writeResult(w ResultWriter, pollID string, votes [][]byte, weights []string, content, signature []byte) error {
+	for i, vote := range votes {
+		var weight string
+		if weights != nil {
+			weight = weights[i]
+		}
+
+		if err := w.WriteVote(pollID, vote, weight); err != nil {
+			return fmt.Errorf("vote %d: %w", i, err)
+		}
+	}
+
+	if err := w.Finish(pollID, content, signature); err != nil {
+		return fmt.Errorf("finish: %w", err)
+	}
+	return nil
+}
+
+// StopManyResult is the result of one poll of StopMany(). If Err is not nil,
+// the poll was not stopped.
+type StopManyResult struct {
+	ID        string
+	Content   []byte
+	Signature []byte
+	Err       error
+
+	// Spilled is the content, if it was written to an encrypted temporary
+	// file. See WithSpill(). Content is nil in this case. The caller has to
+	// close the file.
+	Spilled *spill.File
+}
+
+// Progress is the number of decrypted votes of one poll during StopMany().
+type Progress struct {
+	PollID    string
+	Decrypted int
+	Total     int
+}
+
+// StopMany stops many polls in one run, for example when a meeting closes all
+// its polls at the same time. The polls are stopped in parallel, but all votes
+// are decrypted by one pool of decrypt workers. So the call does not use more
+// workers then one Stop() call.
+//
+// Each poll is stopped like with Stop() and its options. The polls are
+// independent. If one fails, its error is returned in its result and the
+// other polls are stopped anyway. The results are in the order of polls.
+//
+// If progress is not nil, it is called after each decrypted vote. It is
+// called from different goroutines, but never at the same time.
+func (d *Decrypt) StopMany(ctx context.Context, polls []ElectionPoll, progress func(Progress)) ([]StopManyResult, error) {
+	seen := make(map[string]bool, len(polls))
+	for _, poll := range polls {
+		if seen[poll.ID] {
+			return nil, fmt.Errorf("poll %s is given more then once: %w", poll.ID, errorcode.Invalid)
+		}
+		seen[poll.ID] = true
+	}
+
+	votes := 0
+	for _, poll := range polls {
+		votes += len(poll.Votes)
+	}
+
+	pool := d.newPool(votes)
+	defer pool.close()
+
+	var mu sync.Mutex
+	results := make([]StopManyResult, len(polls))
+	var wg sync.WaitGroup
+	wg.Add(len(polls))
+	for i, poll := range polls {
+		go func() {
+			defer wg.Done()
+
+			var onVote func()
+			if progress != nil {
+				var decrypted int
+				onVote = func() {
+					mu.Lock()
+					defer mu.Unlock()
+					decrypted++
+					progress(Progress{PollID: poll.ID, Decrypted: decrypted, Total: len(poll.Votes)})
+				}
+			}
+
+			content, signature, err := d.stop(ctx, pool, onVote, nil, poll.ID, poll.Votes, poll.Options...)
+			results[i] = StopManyResult{ID: poll.ID, Content: content, Signature: signature, Err: err}
+			if err == nil && d.spillSize > 0 && len(content) >= d.spillSize {
+				results[i].Content, results[i].Spilled = d.spillContent(poll.ID, content)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// spillContent writes the content of a stopped poll to an encrypted temporary
+// file. If this fails, the content stays in memory.
+func (d *Decrypt) spillContent(pollID string, content []byte) ([]byte, *spill.File) {
+	f, err := spill.New(d.spillDir)
+	if err != nil {
+		log.Printf("Warning: keeping result of poll %s in memory: %v", pollID, err)
+		return content, nil
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		log.Printf("Warning: keeping result of poll %s in memory: %v", pollID, err)
+		return content, nil
+	}
+	return nil, f
+}
+
+// checkStartConfig returns an error with errorcode.Unsupported, if the crypto
+// backend does not support the options of a poll, or with errorcode.Invalid,
+// if the options do not fit together.
+func (d *Decrypt) checkStartConfig(config StartConfig) error {
+	for _, feature := range config.features() {
+		if !d.features[feature] {
+			return fmt.Errorf("experimental feature %s is not enabled: %w", feature, errorcode.Unsupported)
+		}
+	}
+
+	if _, ok := d.crypto.(PartialDecrypter); config.Trustee && !ok {
+		return fmt.Errorf("crypto backend does not support partial decryption: %w", errorcode.Unsupported)
+	}
+
+	if _, ok := d.crypto.(TokenIssuer); config.Tokens && !ok {
+		return fmt.Errorf("crypto backend does not support voting tokens: %w", errorcode.Unsupported)
+	}
+
+	if _, ok := d.crypto.(PollSigner); d.pollSigningKeys && !ok {
+		return fmt.Errorf("crypto backend does not support poll signing keys: %w", errorcode.Unsupported)
+	}
+
+	if _, ok := d.crypto.(MeetingSigner); d.meetingSecret != nil && !ok {
+		return fmt.Errorf("crypto backend does not support meeting signing keys: %w", errorcode.Unsupported)
+	}
+
+	if _, ok := d.crypto.(RingVerifier); len(config.Ring) > 0 && !ok {
+		return fmt.Errorf("crypto backend does not support ring signatures: %w", errorcode.Unsupported)
+	}
+
+	if config.Revote != RevoteInvalid && config.Revote != RevoteLast {
+		return fmt.Errorf("unknown revote policy %d: %w", config.Revote, errorcode.Invalid)
+	}
+
+	if len(config.Ring) > 0 && config.Trustee {
+		return fmt.Errorf("a trustee poll can not have a ring: %w", errorcode.Invalid)
+	}
+	return nil
+}
+
+// publicPollKey returns the public poll key and its signature. After Rekey(),
+// it is the public key of the last replacement key. With
+// WithPollSigningKeys() or WithMeetingSigningKeys(), the key is signed with
+// the poll or meeting signing key instead of the main key.
+func (d *Decrypt) publicPollKey(pollID string, pollKey []byte) (pubKey []byte, pubKeySig []byte, err error) {
+	current, err := d.currentKey(pollID, pollKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading replacement keys: %w", err)
+	}
+
+	pubKey, pubKeySig, err = d.crypto.PublicPollKey(current)
+	if err != nil || (!d.pollSigningKeys && d.meeting(pollID) == "") {
+		return pubKey, pubKeySig, err
+	}
+
+	pubKeySig, err = d.signPoll(pollID, pollKey, pubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pubKey, pubKeySig, nil
+}
+
+// signPoll signs an artifact of a poll. With WithMeetingSigningKeys(), it uses
+// the meeting signing key for polls of a meeting. With WithPollSigningKeys(),
+// it uses the poll signing key, otherwise the main key.
+func (d *Decrypt) signPoll(pollID string, pollKey []byte, value []byte) ([]byte, error) {
+	if meetingID := d.meeting(pollID); meetingID != "" {
+		signer, ok := d.crypto.(MeetingSigner)
+		if !ok {
+			return nil, fmt.Errorf("crypto backend does not support meeting signing keys: %w", errorcode.Unsupported)
+		}
+		return signer.SignMeeting(d.meetingSecret, meetingID, value)
+	}
+
+	if !d.pollSigningKeys {
+		return d.crypto.Sign(value)
+	}
+
+	signer, ok := d.crypto.(PollSigner)
+	if !ok {
+		return nil, fmt.Errorf("crypto backend does not support poll signing keys: %w", errorcode.Unsupported)
+	}
+	return signer.SignPoll(pollKey, pollID, value)
+}
+
+// PollSigningKey returns the public poll signing key of a started poll and the
+// delegation, its signature created with the main key over
+// crypto.DelegationMessage(). See WithPollSigningKeys().
+//
+// Returns an error with errorcode.Unsupported, if the decrypt component does
+// not use poll signing keys.
+func (d *Decrypt) PollSigningKey(ctx context.Context, pollID string) (pubKey, delegation []byte, err error) {
+	if err := d.validateID(pollID); err != nil {
+		return nil, nil, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	signer, ok := d.crypto.(PollSigner)
+	if !d.pollSigningKeys || !ok {
+		return nil, nil, fmt.Errorf("poll signing keys are not used: %w", errorcode.Unsupported)
+	}
+
+	if meetingID := d.meeting(pollID); meetingID != "" {
+		return nil, nil, fmt.Errorf("poll is signed with the signing key of meeting %s: %w", meetingID, errorcode.Unsupported)
+	}
+
+	pollKey, err := d.store.LoadKey(pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	pubKey, delegation, err = signer.PollSigningKey(pollKey, pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("deriving poll signing key: %w", err)
+	}
+	return pubKey, delegation, nil
+}
+
+// MeetingID returns the id of the meeting of a poll. It is the part of the poll
+// id before the first `/`, for example `meeting1` for `meeting1/42`. It is
+// empty for poll ids without `/`.
+func MeetingID(pollID string) string {
+	meetingID, _, found := strings.Cut(pollID, "/")
+	if !found {
+		return ""
+	}
+	return meetingID
+}
+
+// meeting returns the meeting of a poll, if the poll is signed with a meeting
+// signing key.
+func (d *Decrypt) meeting(pollID string) string {
+	if d.meetingSecret == nil {
+		return ""
+	}
+	return MeetingID(pollID)
+}
+
+// MeetingSigningKey returns the public meeting signing key of a meeting and the
+// delegation, its signature created with the main key over
+// crypto.MeetingDelegationMessage(). See WithMeetingSigningKeys().
+//
+// The meeting does not have to exist. Returns an error with
+// errorcode.Unsupported, if the decrypt component does not use meeting
+// signing keys.
+func (d *Decrypt) MeetingSigningKey(ctx context.Context, meetingID string) (pubKey, delegation []byte, err error) {
+	signer, ok := d.crypto.(MeetingSigner)
+	if d.meetingSecret == nil || !ok {
+		return nil, nil, fmt.Errorf("meeting signing keys are not used: %w", errorcode.Unsupported)
+	}
+
+	if meetingID == "" || strings.Contains(meetingID, "/") {
+		return nil, nil, fmt.Errorf("invalid meeting id %q: %w", meetingID, errorcode.Invalid)
+	}
+
+	pubKey, delegation, err = signer.MeetingSigningKey(d.meetingSecret, meetingID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("deriving meeting signing key: %w", err)
+	}
+	return pubKey, delegation, nil
+}
+
+// IssuedToken is a voting token from IssueToken(). The voter has to check the
+// proof with the public token key and the signature of the key with the
+// public main key.
+type IssuedToken struct {
+	Evaluated    []byte
+	Proof        []byte
+	PublicKey    []byte
+	PublicKeySig []byte
+}
+
+// IssueToken issues a voting token for a poll, that was started with
+// WithTokens(). blinded is the blinded token of the voter, for example from
+// crypto.NewTokenRequest().
+//
+// The caller is responsible to check, that the voter is eligible and gets only
+// one token. The service can not link the token in a vote to the call.
+//
+// Returns an error with errorcode.Invalid, if the poll does not use tokens.
+func (d *Decrypt) IssueToken(ctx context.Context, pollID string, blinded []byte) (IssuedToken, error) {
+	if err := d.validateID(pollID); err != nil {
+		return IssuedToken{}, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	issuer, ok := d.crypto.(TokenIssuer)
+	if !ok {
+		return IssuedToken{}, fmt.Errorf("crypto backend does not support voting tokens: %w", errorcode.Unsupported)
+	}
+
+	pollKey, err := d.store.LoadKey(pollID)
+	if err != nil {
+		return IssuedToken{}, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	if err := d.checkRevoked(pollID, pollKey); err != nil {
+		return IssuedToken{}, err
+	}
+
+	config, err := d.loadConfig(pollID)
+	if err != nil {
+		return IssuedToken{}, fmt.Errorf("loading poll config: %w", err)
+	}
+
+	if !config.Tokens {
+		return IssuedToken{}, fmt.Errorf("poll does not use tokens: %w", errorcode.Invalid)
+	}
+
+	evaluated, proof, err := issuer.IssueToken(pollKey, blinded)
+	if err != nil {
+		return IssuedToken{}, fmt.Errorf("issuing token: %v: %w", err, errorcode.Invalid)
+	}
+
+	pubKey, pubKeySig, err := issuer.PublicTokenKey(pollKey)
+	if err != nil {
+		return IssuedToken{}, fmt.Errorf("signing public token key: %w", err)
+	}
+
+	return IssuedToken{
+		Evaluated:    evaluated,
+		Proof:        proof,
+		PublicKey:    pubKey,
+		PublicKeySig: pubKeySig,
+	}, nil
+}
+
+// partialDecryptLabel is the prefix of the value, that is saved with
+// Store.ValidateSignature() by PartialDecrypt().
+const partialDecryptLabel = "vote-decrypt partial decryption"
+
+// DecryptionShare is the share of one vote from PartialDecrypt(). If Invalid
+// is not empty, the vote was rejected with this reason and Share and Proof are
+// empty.
+type DecryptionShare struct {
+	Share   []byte
+	Proof   []byte
+	Invalid string
+}
+
+// PartialDecrypt returns the decryption shares of the votes of a poll, that
+// was started with WithTrustee(). It is a building block for polls, that are
+// decrypted by many trustees together. Each trustee creates the shares with
+// its own poll key. The votes can only be decrypted with the shares of all
+// trustees, for example with crypto.Combine().
+//
+// The shares are in the order of voteList. The component, that combines them,
+// is responsible to hide the order of the decrypted votes.
+//
+// Like Stop(), the method fails, if it was called with different votes before.
+// A trustee poll can not be stopped. Votes, that are rejected by a vote filter
+// or that can not be partially decrypted, get an empty share. Of the options,
+// only WithRequestSignature() is used.
+//
+// Returns an error with errorcode.Unsupported, if the crypto backend does not
+// implement PartialDecrypter.
+func (d *Decrypt) PartialDecrypt(ctx context.Context, pollID string, voteList [][]byte, options ...StopOption) ([]DecryptionShare, error) {
+	if d.readOnly.Load() {
+		return nil, fmt.Errorf("can not decrypt poll: %w", errorcode.ReadOnly)
+	}
+
+	if err := d.validateID(pollID); err != nil {
+		return nil, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	decrypter, ok := d.crypto.(PartialDecrypter)
+	if !ok {
+		return nil, fmt.Errorf("crypto backend does not support partial decryption: %w", errorcode.Unsupported)
+	}
+
+	var stopConfig StopConfig
+	for _, o := range options {
+		o(&stopConfig)
+	}
+
+	if d.stopKey != nil {
+		message := StopRequestMessage(pollID, voteList, nil)
+		if !ed25519.Verify(d.stopKey, message, stopConfig.Signature) {
+			return nil, fmt.Errorf("invalid request signature: %w", errorcode.Forbidden)
+		}
+	}
+
+	pollKey, err := d.store.LoadKey(pollID)
+	if err != nil {
+		return nil, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	if err := d.checkRevoked(pollID, pollKey); err != nil {
+		return nil, err
+	}
+
+	config, err := d.loadConfig(pollID)
+	if err != nil {
+		return nil, fmt.Errorf("loading poll config: %w", err)
+	}
+
+	if !config.Trustee {
+		return nil, fmt.Errorf("poll was not started for a trustee: %w", errorcode.Invalid)
+	}
+
+	if config.NotBefore != nil && d.now().Before(*config.NotBefore) {
+		return nil, fmt.Errorf("poll can not be decrypted before %s: %w", config.NotBefore.Format(time.RFC3339), errorcode.Invalid)
+	}
+
+	if err := d.checkLimits(voteList); err != nil {
+		return nil, fmt.Errorf("checking limits: %w", err)
+	}
+
+	shares := make([]DecryptionShare, len(voteList))
+	for i, vote := range voteList {
+		if reason := d.filterVote(vote); reason != "" {
+			shares[i] = DecryptionShare{Invalid: reason}
+			continue
+		}
+
+		share, proof, err := decrypter.PartialDecrypt(pollKey, pollID, vote)
+		if err != nil {
+			shares[i] = DecryptionShare{Invalid: "invalid vote"}
+			continue
+		}
+		shares[i] = DecryptionShare{Share: share, Proof: proof}
+	}
+
+	hash := sha256.Sum256(append([]byte(partialDecryptLabel), StopRequestMessage(pollID, voteList, nil)...))
+	if err := d.store.ValidateSignature(pollID, hash[:]); err != nil {
+		if errors.Is(err, errorcode.Invalid) {
+			return nil, fmt.Errorf("poll was decrypted with different parameters before")
+		}
+		return nil, fmt.Errorf("validate signature: %w", err)
+	}
+
+	if err := d.record(ctx, "partial-decrypt", pollID, fmt.Sprintf("created shares for %d votes", len(voteList))); err != nil {
+		return nil, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	return shares, nil
+}
+
+// Replay decrypts the votes of a poll again with its private poll key and
+// returns the content, that Stop() created for them. It does not use the store
+// or the main key, so it can be used offline for a recount.
+//
+// startConfig has to contain the metadata and the earliest stop time, the poll
+// was started with. The options have to be the same as for the Stop() call.
+// The content is only the same, if the decrypt component uses the same vote
+// filters, plaintext limits and result format as the one that stopped the
+// poll.
+func (d *Decrypt) Replay(ctx context.Context, pollKey []byte, pollID string, voteList [][]byte, startConfig StartConfig, options ...StopOption) ([]byte, error) {
+	var stopConfig StopConfig
+	for _, o := range options {
+		o(&stopConfig)
+	}
+
+	if err := validateWeights(stopConfig.Weights, len(voteList)); err != nil {
+		return nil, fmt.Errorf("invalid weights: %w", err)
+	}
+
+	if err := validateTags(stopConfig.Tags, len(voteList)); err != nil {
+		return nil, fmt.Errorf("invalid tags: %w", err)
+	}
+
+	pool := d.newPool(len(voteList))
+	defer pool.close()
+
+	return d.replay(pool, pollKey, nil, pollID, voteList, stopConfig, startConfig)
+}
+
+// replay decrypts the votes and creates the content of the result without
+// signing or saving it.
+func (d *Decrypt) replay(pool *workerPool, pollKey []byte, replacements [][]byte, pollID string, voteList [][]byte, stopConfig StopConfig, startConfig StartConfig) ([]byte, error) {
+	var codes []byte
+	if d.commitment {
+		codes = make([]byte, len(voteList)*sha256.Size)
+	}
+
+	decrypted, weights, invalid, superseded, err := d.decryptVotes(pool, nil, pollKey, replacements, pollID, voteList, stopConfig, startConfig, codes)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting votes: %w", err)
+	}
+
+	var root []byte
+	if d.commitment {
+		root = merkle.ParallelRoot(commitmentLeaves(codes), pool.workers)
+	}
+
+	content, err := d.resultToContent(Result{
+		ID:             pollID,
+		Votes:          decrypted,
+		Metadata:       startConfig.Metadata,
+		Weights:        weights,
+		NotBefore:      startConfig.NotBefore,
+		Invalid:        invalid,
+		Superseded:     superseded,
+		CiphertextRoot: root,
+		Election:       startConfig.Election,
+		Features:       startConfig.features(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating content: %w", err)
+	}
+
+	return content, nil
+}
+
+// coSignatureLabel separates co-signatures from other signatures.
+const coSignatureLabel = "vote-decrypt result co-signature"
+
+// CoSignatureMessage returns the message, that a co-signer signs for the
+// result of a poll.
+//
+// It is the label `vote-decrypt result co-signature`, the length of the poll
+// id as 8 byte big endian integer, the poll id and the content.
+func CoSignatureMessage(pollID string, content []byte) []byte {
+	message := make([]byte, 0, len(coSignatureLabel)+8+len(pollID)+len(content))
+	message = append(message, coSignatureLabel...)
+	message = binary.BigEndian.AppendUint64(message, uint64(len(pollID)))
+	message = append(message, pollID...)
+	return append(message, content...)
+}
+
+// CoSignature is the signature of an instance over the result of a poll, that
+// was created by another instance. See Decrypt.CoSign().
+type CoSignature struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// VerifyCoSignature checks the co-signature of the content of a poll.
+func VerifyCoSignature(pollID string, content []byte, cosignature CoSignature) bool {
+	if len(cosignature.PubKey) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(cosignature.PubKey, CoSignatureMessage(pollID, content), cosignature.Signature)
+}
+
+// CoSign verifies the result of a poll, that was stopped by another instance,
+// and signs it with the co-signing key.
+//
+// The votes are decrypted again with the poll key from the store. The
+// decrypted content has to be the same as content, so the instance needs the
+// same poll keys, vote filters, limits and result format as the instance, that
+// stopped the poll. Usually, it is a standby. CoSign does not change the store
+// and also works in read only mode.
+//
+// The options have to be the same as for the Stop() call. It returns an error
+// with errorcode.Unsupported, if the decrypt component has no co-signing key,
+// and with errorcode.Invalid, if the content does not match the votes.
+func (d *Decrypt) CoSign(ctx context.Context, pollID string, voteList [][]byte, content []byte, options ...StopOption) (CoSignature, error) {
+	if d.coSigningKey == nil {
+		return CoSignature{}, fmt.Errorf("no co-signing key: %w", errorcode.Unsupported)
+	}
+
+	if err := d.validateID(pollID); err != nil {
+		return CoSignature{}, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	var stopConfig StopConfig
+	for _, o := range options {
+		o(&stopConfig)
+	}
+
+	if d.stopKey != nil {
+		message := TaggedStopRequestMessage(pollID, voteList, stopConfig.Weights, stopConfig.Tags)
+		if !ed25519.Verify(d.stopKey, message, stopConfig.Signature) {
+			return CoSignature{}, fmt.Errorf("invalid request signature: %w", errorcode.Forbidden)
+		}
+	}
+
+	if err := validateWeights(stopConfig.Weights, len(voteList)); err != nil {
+		return CoSignature{}, fmt.Errorf("invalid weights: %w", err)
+	}
+
+	if err := validateTags(stopConfig.Tags, len(voteList)); err != nil {
+		return CoSignature{}, fmt.Errorf("invalid tags: %w", err)
+	}
+
+	pollKey, err := d.store.LoadKey(pollID)
+	if err != nil {
+		return CoSignature{}, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	if err := d.checkRevoked(pollID, pollKey); err != nil {
+		return CoSignature{}, err
+	}
+
+	config, err := d.loadConfig(pollID)
+	if err != nil {
+		return CoSignature{}, fmt.Errorf("loading poll config: %w", err)
+	}
+
+	if config.Trustee {
+		return CoSignature{}, fmt.Errorf("poll was started for a trustee and has no result: %w", errorcode.Invalid)
+	}
+
+	if err := d.checkLimits(voteList); err != nil {
+		return CoSignature{}, fmt.Errorf("checking limits: %w", err)
+	}
+
+	release, err := d.budget.acquire(ctx, stopConfig.Priority, runMemory(voteList))
+	if err != nil {
+		return CoSignature{}, fmt.Errorf("waiting for resources: %w", err)
+	}
+	defer release()
+
+	replacements, err := d.replacementKeys(pollID)
+	if err != nil {
+		return CoSignature{}, fmt.Errorf("loading replacement keys: %w", err)
+	}
+
+	pool := d.newPool(len(voteList))
+	defer pool.close()
+
+	decryptedContent, err := d.replay(pool, pollKey, replacements, pollID, voteList, stopConfig, config)
+	if err != nil {
+		return CoSignature{}, err
+	}
+
+	if !bytes.Equal(decryptedContent, content) {
+		return CoSignature{}, fmt.Errorf("content is not the result of the votes: %w", errorcode.Invalid)
+	}
+
+	signature := ed25519.Sign(d.coSigningKey, CoSignatureMessage(pollID, content))
+
+	if err := d.record(ctx, "cosign", pollID, fmt.Sprintf("co-signed result of %d votes", len(voteList))); err != nil {
+		return CoSignature{}, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	return CoSignature{
+		PubKey:    d.coSigningKey.Public().(ed25519.PublicKey),
+		Signature: signature,
+	}, nil
+}
+
+// TrackingCode returns the tracking code of an encrypted vote. It is the
+// sha256 hash of the ciphertext. A voter can use it to fetch an
+// InclusionProof for the vote.
+func TrackingCode(vote []byte) []byte {
+	hash := sha256.Sum256(vote)
+	return hash[:]
+}
+
+// commitmentLeaves returns the sorted tracking codes of the votes. codes are
+// the tracking codes from decryptVotes(). They are sorted, so the tree does not
+// reveal the order, in which the votes were given.
+func commitmentLeaves(codes []byte) [][]byte {
+	leaves := make([][]byte, len(codes)/sha256.Size)
+	for i := range leaves {
+		leaves[i] = codes[i*sha256.Size : (i+1)*sha256.Size : (i+1)*sha256.Size]
+	}
+
+	sort.Slice(leaves, func(i, j int) bool {
+		return bytes.Compare(leaves[i], leaves[j]) < 0
+	})
+	return leaves
+}
+
+// InclusionProof proves, that a vote was part of the merkle tree, whose root
+// is the CiphertextRoot of the result of a poll.
+type InclusionProof struct {
+	Index int
+	Size  int
+	Path  [][]byte
+}
+
+// Verify checks, that the vote with the tracking code is included in the tree
+// with the root.
+func (p InclusionProof) Verify(root, trackingCode []byte) bool {
+	return merkle.Verify(root, trackingCode, p.Index, p.Size, p.Path)
+}
+
+// InclusionProof returns the proof, that the vote with the tracking code was
+// part of the stopped poll.
+//
+// Returns an error with errorcode.NotExist, if the poll was not stopped with
+// WithCommitment() or if no vote has the tracking code.
+func (d *Decrypt) InclusionProof(ctx context.Context, pollID string, trackingCode []byte) (InclusionProof, error) {
+	if err := d.validateID(pollID); err != nil {
+		return InclusionProof{}, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	raw, err := d.store.LoadCommitment(pollID)
+	if err != nil {
+		return InclusionProof{}, fmt.Errorf("loading commitment: %w", err)
+	}
+
+	if len(raw)%sha256.Size != 0 {
+		return InclusionProof{}, fmt.Errorf("commitment has invalid size %d", len(raw))
+	}
+
+	leaves := make([][]byte, len(raw)/sha256.Size)
+	for i := range leaves {
+		leaves[i] = raw[i*sha256.Size : (i+1)*sha256.Size]
+	}
+
+	index := sort.Search(len(leaves), func(i int) bool {
+		return bytes.Compare(leaves[i], trackingCode) >= 0
+	})
+	if index == len(leaves) || !bytes.Equal(leaves[index], trackingCode) {
+		return InclusionProof{}, fmt.Errorf("unknown tracking code: %w", errorcode.NotExist)
+	}
+
+	return InclusionProof{
+		Index: index,
+		Size:  len(leaves),
+		Path:  merkle.Proof(leaves, index),
+	}, nil
+}
+
+// stopRequestLabel is the prefix of StopRequestMessage().
+const stopRequestLabel = "vote-decrypt stop request"
+
+// StopRequestMessage returns the message, that the vote service has to sign
+// for a Stop() call, if the decrypt component uses WithStopKey().
+//
+// It is the label `vote-decrypt stop request` followed by the poll id, the
+// number of votes, each vote, the number of weights and each weight. Each
+// value is prefixed by its length as 8 byte big endian integer.
+func StopRequestMessage(pollID string, voteList [][]byte, weights []string) []byte {
+	return TaggedStopRequestMessage(pollID, voteList, weights, nil)
+}
+
+// TaggedStopRequestMessage is StopRequestMessage() for a Stop() call with
+// WithTags().
+//
+// If there are tags, the message of StopRequestMessage() is followed by the
+// number of tags and for each tag its pseudonym and its time as unix time in
+// milliseconds as 8 byte big endian integer.
+func TaggedStopRequestMessage(pollID string, voteList [][]byte, weights []string, tags []VoteTag) []byte {
+	var buf bytes.Buffer
+	writeValue := func(value []byte) {
+		binary.Write(&buf, binary.BigEndian, uint64(len(value)))
+		buf.Write(value)
+	}
+
+	writeValue([]byte(stopRequestLabel))
+	writeValue([]byte(pollID))
+
+	binary.Write(&buf, binary.BigEndian, uint64(len(voteList)))
+	for _, vote := range voteList {
+		writeValue(vote)
+	}
+
+	binary.Write(&buf, binary.BigEndian, uint64(len(weights)))
+	for _, weight := range weights {
+		writeValue([]byte(weight))
+	}
+
+	if tags != nil {
+		binary.Write(&buf, binary.BigEndian, uint64(len(tags)))
+		for _, tag := range tags {
+			writeValue([]byte(tag.Pseudonym))
+			binary.Write(&buf, binary.BigEndian, tag.Time.UnixMilli())
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// PollStatus is the status of a started poll.
+type PollStatus struct {
+	PubKey    []byte
+	PubKeySig []byte
+	Metadata  []byte
+
+	// Fingerprint of the public poll key. See Fingerprint().
+	Fingerprint string
+
+	// NotBefore is the earliest time, the poll can be stopped. Zero, if the
+	// poll was started without WithNotBefore().
+	NotBefore time.Time
+
+	// Election is the id of the election of the poll. Empty, if the poll does
+	// not belong to an election.
+	Election string
+
+	// Features are the experimental features, that the poll uses. See
+	// WithFeatures().
+	Features []Feature
+
+	// Meeting is the id of the meeting, if the poll is signed with a meeting
+	// signing key. MeetingSigningKey and MeetingDelegation are the public
+	// meeting signing key and its delegation. See WithMeetingSigningKeys().
+	Meeting           string
+	MeetingSigningKey []byte
+	MeetingDelegation []byte
+}
+
+// Status returns the public poll key, its signature and the metadata of a
+// started poll.
+//
+// Returns an error with errorcode.NotExist, if the poll was not started, and
+// errorcode.Invalid, if its key was revoked.
+func (d *Decrypt) Status(ctx context.Context, pollID string) (PollStatus, error) {
+	if err := d.validateID(pollID); err != nil {
+		return PollStatus{}, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	pollKey, err := d.store.LoadKey(pollID)
+	if err != nil {
+		return PollStatus{}, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	if err := d.checkRevoked(pollID, pollKey); err != nil {
+		return PollStatus{}, err
+	}
+
+	pubKey, pubKeySig, err := d.publicPollKey(pollID, pollKey)
+	if err != nil {
+		return PollStatus{}, fmt.Errorf("signing pub key: %w", err)
+	}
+
+	config, err := d.loadConfig(pollID)
+	if err != nil {
+		return PollStatus{}, fmt.Errorf("loading poll config: %w", err)
+	}
+
+	status := PollStatus{
+		PubKey:      pubKey,
+		PubKeySig:   pubKeySig,
+		Metadata:    config.Metadata,
+		Fingerprint: Fingerprint(pubKey),
+		Election:    config.Election,
+		Features:    config.features(),
+	}
+	if config.NotBefore != nil {
+		status.NotBefore = *config.NotBefore
+	}
+
+	if meetingID := d.meeting(pollID); meetingID != "" {
+		status.Meeting = meetingID
+		status.MeetingSigningKey, status.MeetingDelegation, err = d.MeetingSigningKey(ctx, meetingID)
+		if err != nil {
+			return PollStatus{}, fmt.Errorf("loading meeting signing key: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// PollPublicKey is the public key of one poll with its signature.
+type PollPublicKey struct {
+	ID          string `json:"id"`
+	PubKey      []byte `json:"pub_key"`
+	PubKeySig   []byte `json:"pub_sig"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// PublicKeys returns the public keys of all active polls sorted by the poll
+// id. Active are all polls with a key, that are not scheduled to be removed
+// and not revoked.
+//
+// It lets a client fill its cache without calling Status() for each poll.
+func (d *Decrypt) PublicKeys(ctx context.Context) ([]PollPublicKey, error) {
+	ids, err := d.store.ListPolls()
+	if err != nil {
+		return nil, fmt.Errorf("listing polls: %w", err)
+	}
+
+	scheduled, err := d.store.ScheduledClears()
+	if err != nil {
+		return nil, fmt.Errorf("loading scheduled clears: %w", err)
+	}
+
+	sort.Strings(ids)
+
+	keys := make([]PollPublicKey, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := scheduled[id]; ok {
+			continue
+		}
+
+		pollKey, err := d.store.LoadKey(id)
+		if err != nil {
+			if errors.Is(err, errorcode.NotExist) {
+				// The poll was removed after ListPolls().
+				continue
+			}
+			return nil, fmt.Errorf("loading poll key of %s: %w", id, err)
+		}
+
+		if err := d.checkRevoked(id, pollKey); err != nil {
+			if errors.Is(err, errorcode.Invalid) {
+				continue
+			}
+			return nil, fmt.Errorf("checking revocation of %s: %w", id, err)
+		}
+
+		pubKey, pubKeySig, err := d.publicPollKey(id, pollKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing pub key of %s: %w", id, err)
+		}
+
+		keys = append(keys, PollPublicKey{ID: id, PubKey: pubKey, PubKeySig: pubKeySig, Fingerprint: Fingerprint(pubKey)})
+	}
+
+	return keys, nil
+}
+
+// Clear stops a poll by removing the generated cryptographic key.
+//
+// If a deletion delay is set with WithDeletionDelay(), the poll is only
+// scheduled for removal. It is removed by ClearScheduled() after the delay.
+//
+// After the poll is removed, a signed DeletionCertificate is written to the
+// audit log.
+func (d *Decrypt) Clear(ctx context.Context, pollID string) error {
+	if d.readOnly.Load() {
+		return fmt.Errorf("can not clear poll: %w", errorcode.ReadOnly)
+	}
+
+	if err := d.validateID(pollID); err != nil {
+		return fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	if d.deletionDelay > 0 {
+		at := d.now().Add(d.deletionDelay)
+		if err := d.store.ScheduleClear(pollID, at); err != nil {
+			return fmt.Errorf("scheduling clear: %w", err)
+		}
+
+		if err := d.record(ctx, "clear-scheduled", pollID, "removal at "+at.UTC().Format(time.RFC3339)); err != nil {
+			return fmt.Errorf("writing audit log: %w", err)
+		}
+		return nil
+	}
+
+	return d.clearPoll(ctx, pollID)
+}
+
+// ClearScheduled removes all polls, where the scheduled removal time has
+// passed.
+func (d *Decrypt) ClearScheduled(ctx context.Context) error {
+	scheduled, err := d.store.ScheduledClears()
+	if err != nil {
+		return fmt.Errorf("loading scheduled clears: %w", err)
+	}
+
+	now := d.now()
+	for pollID, at := range scheduled {
+		if at.After(now) {
+			continue
+		}
+
+		if err := d.clearPoll(ctx, pollID); err != nil {
+			return fmt.Errorf("clearing poll %s: %w", pollID, err)
+		}
+	}
+
+	return nil
+}
+
+// RunScheduledClears calls ClearScheduled() every interval until ctx is done.
+//
+// Errors are written to the default logger.
+func (d *Decrypt) RunScheduledClears(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.ClearScheduled(ctx); err != nil {
+			log.Printf("Error: clearing scheduled polls: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeletionCertificate is signed with the main key after the data of a poll
+// was removed.
+type DeletionCertificate struct {
+	PollID    string    `json:"poll_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// clearPoll removes the data of a poll from the store and writes a signed
+// deletion certificate to the audit log.
+func (d *Decrypt) clearPoll(ctx context.Context, pollID string) error {
+	if err := d.store.ClearPoll(pollID); err != nil {
+		return fmt.Errorf("clearing poll from store: %w", err)
+	}
+
+	certificate, err := json.Marshal(DeletionCertificate{
+		PollID:    pollID,
+		DeletedAt: d.now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding deletion certificate: %w", err)
+	}
+
+	signature, err := d.crypto.Sign(certificate)
+	if err != nil {
+		return fmt.Errorf("signing deletion certificate: %w", err)
+	}
+
+	message := fmt.Sprintf("certificate=%s signature=%s", certificate, base64.StdEncoding.EncodeToString(signature))
+	if err := d.record(ctx, "clear", pollID, message); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
+
+// NoDecryptionStatement is the statement of a NoDecryptionCertificate.
+const NoDecryptionStatement = "no votes of the poll were decrypted"
+
+// StartElection starts many polls of one election, for example all ballots of
+// an assembly. Returns the public keys of the polls in the order of pollIDs.
+//
+// The election id is saved in the config of each poll and is part of its
+// signed result. The options are used for all polls. Like Start(), it can be
+// called again and only the first call creates the keys.
+func (d *Decrypt) StartElection(ctx context.Context, electionID string, pollIDs []string, options ...StartOption) ([]PollPublicKey, error) {
+	if err := d.validateID(electionID); err != nil {
+		return nil, fmt.Errorf("invalid election id: %w", err)
+	}
+
+	if len(pollIDs) == 0 {
+		return nil, fmt.Errorf("election has no polls: %w", errorcode.Invalid)
+	}
+
+	options = append(options, WithElection(electionID))
+
+	keys := make([]PollPublicKey, len(pollIDs))
+	for i, pollID := range pollIDs {
+		config, err := d.loadConfig(pollID)
+		if err != nil {
+			return nil, fmt.Errorf("loading config of poll %s: %w", pollID, err)
+		}
+
+		if config.Election != "" && config.Election != electionID {
+			return nil, fmt.Errorf("poll %s belongs to election %s: %w", pollID, config.Election, errorcode.Exist)
+		}
+
+		pubKey, pubKeySig, err := d.Start(ctx, pollID, options...)
+		if err != nil {
+			return nil, fmt.Errorf("starting poll %s: %w", pollID, err)
+		}
+		keys[i] = PollPublicKey{ID: pollID, PubKey: pubKey, PubKeySig: pubKeySig, Fingerprint: Fingerprint(pubKey)}
+	}
+
+	return keys, nil
+}
+
+// ElectionPolls returns the sorted ids of all polls of an election.
+func (d *Decrypt) ElectionPolls(ctx context.Context, electionID string) ([]string, error) {
+	ids, err := d.store.ListPolls()
+	if err != nil {
+		return nil, fmt.Errorf("listing polls: %w", err)
+	}
+
+	sort.Strings(ids)
+
+	var pollIDs []string
+	for _, id := range ids {
+		config, err := d.loadConfig(id)
+		if err != nil {
+			return nil, fmt.Errorf("loading config of poll %s: %w", id, err)
+		}
+
+		if config.Election == electionID {
+			pollIDs = append(pollIDs, id)
+		}
+	}
+	return pollIDs, nil
+}
+
+// stopPrefetched is like Stop(), but takes the data of the poll from
+// prefetched, if it is not nil.
+func (d *Decrypt) stopPrefetched(ctx context.Context, prefetched *prefetch, pollID string, voteList [][]byte, options ...StopOption) (decryptedContent, signature []byte, err error) {
+	pool := d.newPool(len(voteList))
+	defer pool.close()
+
+	return d.stop(ctx, pool, nil, prefetched, pollID, voteList, options...)
+}
+
+// ElectionPoll are the votes of one poll for StopElection() and StopMany().
+type ElectionPoll struct {
+	ID      string
+	Votes   [][]byte
+	Options []StopOption
+}
+
+// ElectionReport is the combined result of all polls of an election. It is
+// signed with the main key.
+type ElectionReport struct {
+	Election string           `json:"election"`
+	Polls    []ElectionResult `json:"polls"`
+}
+
+// ElectionResult is the result of one poll in an ElectionReport. Content and
+// Signature are the values, that Stop() returned for the poll.
+type ElectionResult struct {
+	ID        string `json:"id"`
+	Content   []byte `json:"content"`
+	Signature []byte `json:"signature"`
+}
+
+// StopElection stops all polls of an election and returns one report with
+// their results and a signature of the report.
+//
+// polls has to contain each poll of the election exactly once. Otherwise an
+// error with errorcode.Invalid is returned before any poll is stopped. If
+// stopping one poll fails, the polls before it are already stopped. Since
+// Stop() can be called again with the same votes, the whole call can be
+// repeated.
+//
+// The polls in the report are sorted by their id.
+func (d *Decrypt) StopElection(ctx context.Context, electionID string, polls []ElectionPoll) (report, signature []byte, err error) {
+	if d.readOnly.Load() {
+		return nil, nil, fmt.Errorf("can not stop election: %w", errorcode.ReadOnly)
+	}
+
+	if err := d.validateID(electionID); err != nil {
+		return nil, nil, fmt.Errorf("invalid election id: %w", err)
+	}
+
+	pollIDs, err := d.ElectionPolls(ctx, electionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing polls of election: %w", err)
+	}
+
+	if len(pollIDs) == 0 {
+		return nil, nil, fmt.Errorf("election %s has no polls: %w", electionID, errorcode.NotExist)
+	}
+
+	byID := make(map[string]ElectionPoll, len(polls))
+	for _, poll := range polls {
+		if _, ok := byID[poll.ID]; ok {
+			return nil, nil, fmt.Errorf("poll %s is given more then once: %w", poll.ID, errorcode.Invalid)
+		}
+		byID[poll.ID] = poll
+	}
+
+	if len(byID) != len(pollIDs) {
+		return nil, nil, fmt.Errorf("got %d polls, election has %d: %w", len(byID), len(pollIDs), errorcode.Invalid)
+	}
+
+	for _, pollID := range pollIDs {
+		if _, ok := byID[pollID]; !ok {
+			return nil, nil, fmt.Errorf("poll %s of the election is missing: %w", pollID, errorcode.Invalid)
+		}
+	}
+
+	// The polls are stopped one after the other. The data of the next poll
+	// is loaded from the store, while the current poll is decrypted.
+	results := make([]ElectionResult, len(pollIDs))
+	next := d.prefetch(pollIDs[0])
+	defer func() {
+		if next != nil {
+			next.discard()
+		}
+	}()
+
+	for i, pollID := range pollIDs {
+		current := next
+		next = nil
+		if i+1 < len(pollIDs) {
+			next = d.prefetch(pollIDs[i+1])
+		}
+
+		poll := byID[pollID]
+		content, signature, err := d.stopPrefetched(ctx, current, pollID, poll.Votes, poll.Options...)
+		current.discard()
+		if err != nil {
+			return nil, nil, fmt.Errorf("stopping poll %s: %w", pollID, err)
+		}
+		results[i] = ElectionResult{ID: pollID, Content: content, Signature: signature}
+	}
+
+	report, err = json.Marshal(ElectionReport{Election: electionID, Polls: results})
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding report: %w", err)
+	}
+
+	signature, err = d.crypto.Sign(report)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing report: %w", err)
+	}
+
+	if err := d.record(ctx, "stop-election", "", fmt.Sprintf("election=%s polls=%d", electionID, len(pollIDs))); err != nil {
+		return nil, nil, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	return report, signature, nil
+}
+
+// ClearElection calls Clear() for all polls of an election.
+func (d *Decrypt) ClearElection(ctx context.Context, electionID string) error {
+	if d.readOnly.Load() {
+		return fmt.Errorf("can not clear election: %w", errorcode.ReadOnly)
+	}
+
+	if err := d.validateID(electionID); err != nil {
+		return fmt.Errorf("invalid election id: %w", err)
+	}
+
+	pollIDs, err := d.ElectionPolls(ctx, electionID)
+	if err != nil {
+		return fmt.Errorf("listing polls of election: %w", err)
+	}
+
+	for _, pollID := range pollIDs {
+		if err := d.Clear(ctx, pollID); err != nil {
+			return fmt.Errorf("clearing poll %s: %w", pollID, err)
+		}
+	}
+	return nil
+}
+
+// NoDecryptionCertificate is signed with the main key while a poll is running.
+// It states, that the audit log contains no event, that decrypted the votes of
+// the poll or exported its key, until CheckedAt.
+type NoDecryptionCertificate struct {
+	PollID    string    `json:"poll_id"`
+	Statement string    `json:"statement"`
+	CheckedAt time.Time `json:"checked_at"`
+
+	// Events is the number of audit events of the poll, that were checked.
+	Events int `json:"events"`
+}
+
+// decryptionEvents are the audit events, after which the votes of a poll
+// could be known.
+var decryptionEvents = map[string]bool{
+	"stop":            true,
+	"export-key":      true,
+	"partial-decrypt": true,
+}
+
+// NoDecryption returns a signed NoDecryptionCertificate for a started poll.
+// The certificate is also written to the audit log.
+//
+// It needs an audit log, that implements AuditReader. Otherwise an error with
+// errorcode.Unsupported is returned. If the poll was stopped or its key was
+// exported, an error with errorcode.Invalid is returned.
+//
+// In read only mode, an error with errorcode.ReadOnly is returned. A read
+// only instance can be a standby or a follower, whose audit log does not
+// contain the events of the instance, that handles the polls.
+func (d *Decrypt) NoDecryption(ctx context.Context, pollID string) (certificate, signature []byte, err error) {
+	if d.readOnly.Load() {
+		return nil, nil, fmt.Errorf("can not certify poll: %w", errorcode.ReadOnly)
+	}
+
+	if err := d.validateID(pollID); err != nil {
+		return nil, nil, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	reader, ok := d.auditLog.(AuditReader)
+	if !ok {
+		return nil, nil, fmt.Errorf("audit log can not be read: %w", errorcode.Unsupported)
+	}
+
+	if _, err := d.store.LoadKey(pollID); err != nil {
+		return nil, nil, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	events, err := reader.Events(pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading audit log: %w", err)
+	}
+
+	for _, event := range events {
+		if decryptionEvents[event] {
+			return nil, nil, fmt.Errorf("audit log contains %s event: %w", event, errorcode.Invalid)
+		}
+	}
+
+	certificate, err = json.Marshal(NoDecryptionCertificate{
+		PollID:    pollID,
+		Statement: NoDecryptionStatement,
+		CheckedAt: d.now().UTC(),
+		Events:    len(events),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding certificate: %w", err)
+	}
+
+	signature, err = d.crypto.Sign(certificate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing certificate: %w", err)
+	}
+
+	message := fmt.Sprintf("certificate=%s signature=%s", certificate, base64.StdEncoding.EncodeToString(signature))
+	if err := d.record(ctx, "no-decryption", pollID, message); err != nil {
+		return nil, nil, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	return certificate, signature, nil
+}
+
+// RunNoDecryption calls NoDecryption() for all running polls every interval
+// until ctx is done. Polls, that were already stopped, are skipped.
+//
+// Errors are written to the default logger.
+func (d *Decrypt) RunNoDecryption(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ids, err := d.store.ListPolls()
+		if err != nil {
+			log.Printf("Error: listing polls for no decryption certificates: %v", err)
+			continue
+		}
+
+		for _, id := range ids {
+			if _, _, err := d.NoDecryption(ctx, id); err != nil {
+				if errors.Is(err, errorcode.Invalid) || errors.Is(err, errorcode.NotExist) || errors.Is(err, errorcode.ReadOnly) {
+					continue
+				}
+				log.Printf("Error: creating no decryption certificate for poll %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// SetReadOnly enables or disables the read only mode.
+//
+// In read only mode, no poll keys are created and polls can not be stopped or
+// cleared. All other methods work as usual. This can be used during store
+// migrations or after the legal election window closed.
+func (d *Decrypt) SetReadOnly(ctx context.Context, readOnly bool) error {
+	d.readOnly.Store(readOnly)
+
+	if err := d.record(ctx, "read-only", "", fmt.Sprintf("read only mode set to %t", readOnly)); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
+
+// ReadOnly returns true, if the service is in read only mode.
+func (d *Decrypt) ReadOnly() bool {
+	return d.readOnly.Load()
+}
+
+// WipeToken returns the confirmation token that is needed to call Wipe().
+//
+// The token is derived from the public main key. It makes sure, that the
+// caller knows, which instance of the service gets wiped.
+func (d *Decrypt) WipeToken() string {
+	return WipeTokenFor(d.crypto.PublicMainKey())
+}
+
+// WipeTokenFor returns the confirmation token for Wipe() for a service with
+// the given public main key.
+func WipeTokenFor(publicMainKey []byte) string {
+	hash := sha256.Sum256(publicMainKey)
+	return hex.EncodeToString(hash[:8])
+}
+
+// Wipe removes the data of all polls from the store.
+//
+// If withMainKey is true, the main key is also removed. This is only possible,
+// if the decrypt component was initialized with WithRemoveMainKey().
+//
+// confirmation has to be the value returned by WipeToken(). Otherwise an error
+// with errorcode.Invalid is returned.
+func (d *Decrypt) Wipe(ctx context.Context, confirmation string, withMainKey bool) error {
+	if subtle.ConstantTimeCompare([]byte(confirmation), []byte(d.WipeToken())) != 1 {
+		return fmt.Errorf("wrong confirmation token: %w", errorcode.Invalid)
+	}
+
+	if withMainKey && d.removeMainKey == nil {
+		return fmt.Errorf("removing the main key is not supported: %w", errorcode.Invalid)
+	}
+
+	pollIDs, err := d.store.ListPolls()
+	if err != nil {
+		return fmt.Errorf("listing polls: %w", err)
+	}
+
+	for _, pollID := range pollIDs {
+		if err := d.store.ClearPoll(pollID); err != nil {
+			return fmt.Errorf("clearing poll %s from store: %w", pollID, err)
+		}
+	}
+
+	if withMainKey {
+		if err := d.removeMainKey(); err != nil {
+			return fmt.Errorf("removing main key: %w", err)
+		}
+	}
+
+	message := fmt.Sprintf("removed %d polls", len(pollIDs))
+	if withMainKey {
+		message += " and the main key"
+	}
+
+	if err := d.record(ctx, "wipe", "", message); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+
+	return nil
+}
+
+// MainKeyFingerprint returns the fingerprint of a public main key. It is the
+// hex encoded sha256 hash of the key.
+func MainKeyFingerprint(publicMainKey []byte) string {
+	hash := sha256.Sum256(publicMainKey)
+	return hex.EncodeToString(hash[:])
+}
+
+// Fingerprint returns a short fingerprint of a public main key or a public
+// poll key in the format of OpenSSH. It is `SHA256:` followed by the base64
+// encoded sha256 hash of the key without padding.
+//
+// It is meant for humans, that compare keys across systems.
+func Fingerprint(pubKey []byte) string {
+	hash := sha256.Sum256(pubKey)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(hash[:])
+}
+
+// CheckMainKey makes sure, that the service uses the main key with the given
+// fingerprint. Clients can call it before they start a poll to detect a
+// misconfigured environment.
+//
+// The fingerprint can be created with MainKeyFingerprint() or Fingerprint().
+//
+// Returns an error with errorcode.Invalid, if the fingerprint does not match.
+func (d *Decrypt) CheckMainKey(ctx context.Context, fingerprint string) error {
+	expected := MainKeyFingerprint(d.crypto.PublicMainKey())
+	if strings.HasPrefix(fingerprint, "SHA256:") {
+		expected = Fingerprint(d.crypto.PublicMainKey())
+	} else {
+		fingerprint = strings.ToLower(fingerprint)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(fingerprint), []byte(expected)) != 1 {
+		return fmt.Errorf("service uses main key %s: %w", expected, errorcode.Invalid)
+	}
+	return nil
+}
+
+// ExportKey returns the private key of a poll sealed for the auditor. See
+// WithSealer().
+//
+// It is meant for audits, for example after a court order. The reason is
+// mandatory and written to the audit log.
+//
+// Only the first key of the poll is exported, not the replacement keys from
+// Rekey().
+//
+// Returns an error with errorcode.Unsupported, if no sealer is configured.
+func (d *Decrypt) ExportKey(ctx context.Context, pollID string, reason string) ([]byte, error) {
+	if d.sealer == nil {
+		return nil, fmt.Errorf("key export is not configured: %w", errorcode.Unsupported)
+	}
+
+	if err := d.validateID(pollID); err != nil {
+		return nil, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("no reason given: %w", errorcode.Invalid)
+	}
+
+	pollKey, err := d.store.LoadKey(pollID)
+	if err != nil {
+		return nil, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	sealed, err := d.sealer.Seal(pollKey)
+	if err != nil {
+		return nil, fmt.Errorf("sealing poll key: %w", err)
+	}
+
+	if err := d.record(ctx, "export-key", pollID, fmt.Sprintf("reason=%q", reason)); err != nil {
+		return nil, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	return sealed, nil
+}
+
+// Rekey creates a replacement key for a running poll, for example after a
+// suspected leak of the poll key. It returns the new public poll key and its
+// signature. Start(), Status() and PublicKeys() return the new key afterwards,
+// so new votes are encrypted for it.
+//
+// Stop() decrypts each vote with the first key of the poll and all
+// replacement keys, so the votes, that were already cast, don't have to be
+// discarded. The voting tokens, the poll signing key, the order of the result
+// and the unlinked pseudonyms are still derived from the first key.
+//
+// The reason is mandatory and written to the audit log. Polls, that were
+// started for a trustee, can not be rekeyed.
+//
+// It needs a store, that implements RekeyStore. Otherwise an error with
+// errorcode.Unsupported is returned.
+func (d *Decrypt) Rekey(ctx context.Context, pollID string, reason string) (pubKey []byte, pubKeySig []byte, err error) {
+	if d.readOnly.Load() {
+		return nil, nil, fmt.Errorf("can not rekey poll: %w", errorcode.ReadOnly)
+	}
+
+	if err := d.validateID(pollID); err != nil {
+		return nil, nil, fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return nil, nil, fmt.Errorf("no reason given: %w", errorcode.Invalid)
+	}
+
+	rekeyStore, ok := d.store.(RekeyStore)
+	if !ok {
+		return nil, nil, fmt.Errorf("store can not save replacement keys: %w", errorcode.Unsupported)
+	}
+
+	pollKey, err := d.store.LoadKey(pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading poll key: %w", err)
+	}
+
+	config, err := d.loadConfig(pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading poll config: %w", err)
+	}
+
+	if config.Trustee {
+		return nil, nil, fmt.Errorf("poll was started for a trustee: %w", errorcode.Invalid)
+	}
+
+	previous, err := d.currentKey(pollID, pollKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading replacement keys: %w", err)
+	}
+
+	previousPubKey, _, err := d.crypto.PublicPollKey(previous)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating public poll key: %w", err)
+	}
+
+	key, err := d.crypto.CreatePollKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating poll key: %w", err)
+	}
+
+	if err := rekeyStore.SaveReplacementKey(pollID, key); err != nil {
+		return nil, nil, fmt.Errorf("saving replacement key: %w", err)
+	}
+
+	pubKey, pubKeySig, err = d.publicPollKey(pollID, pollKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing pub key: %w", err)
+	}
+
+	message := fmt.Sprintf("fingerprint=%s previous=%s reason=%q", Fingerprint(pubKey), Fingerprint(previousPubKey), reason)
+	if err := d.record(ctx, "rekey", pollID, message); err != nil {
+		return nil, nil, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	log.Printf("public poll key for poll %s is %s", pollID, base64.StdEncoding.EncodeToString(pubKey))
+	return pubKey, pubKeySig, nil
+}
+
+// replacementKeys returns the replacement keys of a poll from Rekey() in the
+// order, they were created.
+func (d *Decrypt) replacementKeys(pollID string) ([][]byte, error) {
+	rekeyStore, ok := d.store.(RekeyStore)
+	if !ok {
+		return nil, nil
+	}
+	return rekeyStore.LoadReplacementKeys(pollID)
+}
+
+// currentKey returns the key, new votes of a poll are encrypted for. It is
+// the last replacement key or the poll key, if the poll was not rekeyed.
+func (d *Decrypt) currentKey(pollID string, pollKey []byte) ([]byte, error) {
+	replacements, err := d.replacementKeys(pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(replacements) == 0 {
+		return pollKey, nil
+	}
+	return replacements[len(replacements)-1], nil
+}
+
+// ExportAuditLog returns the entries of the audit log as json lines and a
+// signature of them created with the main key. With a poll id, only the
+// entries of the poll are returned. Zero times do not limit the time range.
+//
+// It needs an audit log, that implements AuditExporter. Otherwise an error
+// with errorcode.Unsupported is returned.
+func (d *Decrypt) ExportAuditLog(ctx context.Context, pollID string, from, to time.Time) (content, signature []byte, err error) {
+	exporter, ok := d.auditLog.(AuditExporter)
+	if !ok {
+		return nil, nil, fmt.Errorf("audit log can not be exported: %w", errorcode.Unsupported)
+	}
+
+	if pollID != "" {
+		if err := d.validateID(pollID); err != nil {
+			return nil, nil, fmt.Errorf("invalid poll id: %w", err)
+		}
+	}
+
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return nil, nil, fmt.Errorf("time range ends before it starts: %w", errorcode.Invalid)
+	}
+
+	content, err = exporter.Export(pollID, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("exporting audit log: %w", err)
+	}
+
+	signature, err = d.crypto.Sign(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing audit log: %w", err)
+	}
+
+	return content, signature, nil
+}
+
+// Revocation is an entry of the revocation list. It revokes the public poll
+// key with the fingerprint. A later poll with the same id and another key is
+// not revoked.
+type Revocation struct {
+	PollID      string    `json:"poll_id"`
+	Fingerprint string    `json:"fingerprint"`
+	Reason      string    `json:"reason"`
+	RevokedAt   time.Time `json:"revoked_at"`
+}
+
+// RevocationList is the list of all revoked poll keys, that is signed with the
+// main key.
+type RevocationList struct {
+	Revocations []Revocation `json:"revocations"`
+}
+
+// Revoke revokes the public poll key of a running poll, for example after a
+// suspected compromise of the key. After Rekey(), it is the public key of the
+// last replacement key. The poll can not be stopped or decrypted
+// afterwards and its public key is not returned anymore. The revocation is
+// added to the revocation list. See RevocationList().
+//
+// The reason is mandatory and written to the audit log. Revoking a revoked
+// poll again does nothing.
+//
+// It needs a store, that implements RevocationStore. Otherwise an error with
+// errorcode.Unsupported is returned.
+func (d *Decrypt) Revoke(ctx context.Context, pollID string, reason string) error {
+	if d.readOnly.Load() {
+		return fmt.Errorf("can not revoke poll: %w", errorcode.ReadOnly)
+	}
+
+	if err := d.validateID(pollID); err != nil {
+		return fmt.Errorf("invalid poll id: %w", err)
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("no reason given: %w", errorcode.Invalid)
+	}
+
+	revocationStore, ok := d.store.(RevocationStore)
+	if !ok {
+		return fmt.Errorf("store can not save revocations: %w", errorcode.Unsupported)
+	}
+
+	pollKey, err := d.store.LoadKey(pollID)
+	if err != nil {
+		return fmt.Errorf("loading poll key: %w", err)
+	}
+
+	current, err := d.currentKey(pollID, pollKey)
+	if err != nil {
+		return fmt.Errorf("loading replacement keys: %w", err)
+	}
+
+	pubKey, _, err := d.crypto.PublicPollKey(current)
+	if err != nil {
+		return fmt.Errorf("creating public poll key: %w", err)
+	}
+
+	fingerprint := Fingerprint(pubKey)
+	if _, revoked, err := d.findRevocation(pollID, fingerprint); err != nil {
+		return fmt.Errorf("loading revocations: %w", err)
+	} else if revoked {
+		return nil
+	}
+
+	revocation, err := json.Marshal(Revocation{
+		PollID:      pollID,
+		Fingerprint: fingerprint,
+		Reason:      reason,
+		RevokedAt:   d.now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding revocation: %w", err)
+	}
+
+	if err := revocationStore.SaveRevocation(revocation); err != nil {
+		return fmt.Errorf("saving revocation: %w", err)
+	}
+
+	if err := d.record(ctx, "revoke", pollID, fmt.Sprintf("fingerprint=%s reason=%q", fingerprint, reason)); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+
+	return nil
+}
+
+// RevocationList returns the list of all revoked poll keys as json and its
+// signature created with the main key. The list is the same for each call
+// until a poll is revoked.
+//
+// Without a store, that implements RevocationStore, the list is empty.
+func (d *Decrypt) RevocationList(ctx context.Context) (list, signature []byte, err error) {
+	revocations, err := d.revocations()
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading revocations: %w", err)
+	}
+
+	list, err = json.Marshal(RevocationList{Revocations: revocations})
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding revocation list: %w", err)
+	}
+
+	signature, err = d.crypto.Sign(list)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing revocation list: %w", err)
+	}
+
+	return list, signature, nil
+}
+
+// revocations returns the revocations from the store in the order, they were
+// saved.
+func (d *Decrypt) revocations() ([]Revocation, error) {
+	revocationStore, ok := d.store.(RevocationStore)
+	if !ok {
+		return []Revocation{}, nil
+	}
+
+	encoded, err := revocationStore.Revocations()
+	if err != nil {
+		return nil, err
+	}
+
+	revocations := make([]Revocation, len(encoded))
+	for i, raw := range encoded {
+		if err := json.Unmarshal(raw, &revocations[i]); err != nil {
+			return nil, fmt.Errorf("decoding revocation %d: %w", i, err)
+		}
+	}
+	return revocations, nil
+}
+
+// findRevocation returns the revocation of the public poll key with the
+// fingerprint.
+func (d *Decrypt) findRevocation(pollID string, fingerprint string) (Revocation, bool, error) {
+	revocations, err := d.revocations()
+	if err != nil {
+		return Revocation{}, false, err
+	}
+
+	for _, revocation := range revocations {
+		if revocation.PollID == pollID && revocation.Fingerprint == fingerprint {
+			return revocation, true, nil
+		}
+	}
+	return Revocation{}, false, nil
+}
+
+// checkRevoked returns an error with errorcode.Invalid, if the poll key was
+// revoked.
+func (d *Decrypt) checkRevoked(pollID string, pollKey []byte) error {
+	if _, ok := d.store.(RevocationStore); !ok {
+		return nil
+	}
+
+	current, err := d.currentKey(pollID, pollKey)
+	if err != nil {
+		return fmt.Errorf("loading replacement keys: %w", err)
+	}
+
+	pubKey, _, err := d.crypto.PublicPollKey(current)
+	if err != nil {
+		return fmt.Errorf("creating public poll key: %w", err)
+	}
+
+	revocation, revoked, err := d.findRevocation(pollID, Fingerprint(pubKey))
+	if err != nil {
+		return fmt.Errorf("loading revocations: %w", err)
+	}
+
+	if revoked {
+		return fmt.Errorf("poll key was revoked at %s: %w", revocation.RevokedAt.Format(time.RFC3339), errorcode.Invalid)
+	}
+	return nil
+}
+
+// Version returns the build information of the running binary as json and a
+// signature of it created with the main key.
+//
+// This ties the results signed with the main key to a specific build.
+func (d *Decrypt) Version(ctx context.Context) (info, signature []byte, err error) {
+	info, err = json.Marshal(version.Get())
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding build info: %w", err)
+	}
+
+	signature, err = d.crypto.Sign(info)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing build info: %w", err)
+	}
+
+	return info, signature, nil
+}
+
+// maxNonceSize is the maximum size of the nonce for Attest().
+const maxNonceSize = 64
+
+// Attestation is the evidence, that the service runs an untampered build.
+type Attestation struct {
+	// BinaryHash is the sha256 hash of the running binary.
+	BinaryHash []byte
+
+	// PubKey is the public main key.
+	PubKey []byte
+
+	// Evidence is created by the Attestor over AttestationData().
+	Evidence []byte
+}
+
+// AttestationData returns the data that is attested by Attest().
+//
+// It binds the binary hash and the public main key to the nonce of the
+// caller. Verifiers have to call it with the values from the Attestation and
+// their own nonce.
+func AttestationData(nonce, binaryHash, pubKey []byte) []byte {
+	pubKeyHash := sha256.Sum256(pubKey)
+	binHash := sha256.Sum256(binaryHash)
+
+	data := make([]byte, 0, len(binHash)+len(pubKeyHash)+len(nonce))
+	data = append(data, binHash[:]...)
+	data = append(data, pubKeyHash[:]...)
+	return append(data, nonce...)
+}
+
+// Attest returns evidence, that binds the hash of the running binary to the
+// public main key.
+//
+// The nonce is chosen by the caller to make sure, that the evidence is fresh.
+// It has to be between 1 and 64 bytes.
+//
+// Returns an error with errorcode.Unsupported, if the decrypt component was
+// initialized without WithAttestor().
+func (d *Decrypt) Attest(ctx context.Context, nonce []byte) (Attestation, error) {
+	if d.attestor == nil {
+		return Attestation{}, fmt.Errorf("attestation is not configured: %w", errorcode.Unsupported)
+	}
+
+	if len(nonce) == 0 || len(nonce) > maxNonceSize {
+		return Attestation{}, fmt.Errorf("nonce has %d bytes, expected 1 to %d: %w", len(nonce), maxNonceSize, errorcode.Invalid)
+	}
+
+	pubKey := d.crypto.PublicMainKey()
+
+	evidence, err := d.attestor.Attest(AttestationData(nonce, d.binaryHash, pubKey))
+	if err != nil {
+		return Attestation{}, fmt.Errorf("creating attestation: %w", err)
+	}
+
+	return Attestation{
+		BinaryHash: d.binaryHash,
+		PubKey:     pubKey,
+		Evidence:   evidence,
+	}, nil
+}
+
+// checkLimits makes sure, that the vote list does not exceed the configured
+// limits. Returns an error with code errorcode.Limit if it does.
+func (d *Decrypt) checkLimits(voteList [][]byte) error {
+	if len(voteList) > d.maxVotes {
+		return fmt.Errorf("received %d votes, only %d votes supported: %w", len(voteList), d.maxVotes, errorcode.Limit)
+	}
+
+	var pollSize int
+	for i, vote := range voteList {
+		if len(vote) > d.maxVoteSize {
+			return fmt.Errorf("vote %d has %d bytes, only %d bytes supported: %w", i, len(vote), d.maxVoteSize, errorcode.Limit)
+		}
+
+		pollSize += len(vote)
+		if pollSize > d.maxPollSize {
+			return fmt.Errorf("votes have more then %d bytes: %w", d.maxPollSize, errorcode.Limit)
+		}
+	}
+
+	return nil
+}
+
+// saveConfig saves the config of a poll in the store.
+//
+// It is not an error, if the config was already saved before.
+func (d *Decrypt) saveConfig(pollID string, config StartConfig) error {
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+
+	if err := d.store.SaveMeta(pollID, encoded); err != nil && !errors.Is(err, errorcode.Exist) {
+		return fmt.Errorf("saving meta: %w", err)
+	}
+
+	return nil
+}
+
+// loadConfig loads the config of a poll from the store.
+//
+// Returns an empty config for polls that where started without one.
+func (d *Decrypt) loadConfig(pollID string) (StartConfig, error) {
+	encoded, err := d.store.LoadMeta(pollID)
+	if err != nil {
+		if errors.Is(err, errorcode.NotExist) {
+			return StartConfig{}, nil
+		}
+		return StartConfig{}, fmt.Errorf("loading meta: %w", err)
+	}
+
+	var config StartConfig
+	if err := json.Unmarshal(encoded, &config); err != nil {
+		return StartConfig{}, fmt.Errorf("decoding config: %w", err)
+	}
+
+	return config, nil
+}
+
+// orderSeedLabel is used to derive the order seed from the poll key.
+const orderSeedLabel = "vote-decrypt order seed"
+
+// orderSeed returns the key for the permutation of the votes of a poll.
+//
+// It is derived from the poll key, so it is secret as long as the poll key and
+// the same for each Stop() call of a poll.
+func orderSeed(pollKey []byte) []byte {
+	mac := hmac.New(sha256.New, pollKey)
+	mac.Write([]byte(orderSeedLabel))
+	return mac.Sum(nil)
+}
+
+// pseudonymLabel is the hmac message to derive the pseudonym key from the poll
+// key.
+const pseudonymLabel = "vote-decrypt pseudonym key"
+
+// unlinkTags returns a copy of the tags, where each pseudonym is replaced by
+// its hmac with a key, that is derived from the poll key.
+//
+// The revote policy only needs to know, which votes have the same pseudonym.
+// With the hmac, the pseudonyms are never used after the tags are read. The
+// pseudonyms do not appear in the result, the audit log or the store. When the
+// poll is cleared, the poll key and with it the key of the hmac are deleted.
+func unlinkTags(pollKey []byte, tags []VoteTag) []VoteTag {
+	if tags == nil {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, pollKey)
+	mac.Write([]byte(pseudonymLabel))
+	pseudonymKey := mac.Sum(nil)
+
+	unlinked := make([]VoteTag, len(tags))
+	for i, tag := range tags {
+		mac := hmac.New(sha256.New, pseudonymKey)
+		mac.Write([]byte(tag.Pseudonym))
+		unlinked[i] = VoteTag{Pseudonym: string(mac.Sum(nil)), Time: tag.Time}
+	}
+	return unlinked
+}
+
+// voteOrder returns the indexes of the votes in the order of the result.
+//
+// The order is a keyed pseudorandom permutation: The votes are sorted by the
+// hmac of the encrypted vote with the seed. It is independent of the order of
+// voteList, so the position of a vote in the result does not reveal, when the
+// vote was cast.
+func voteOrder(seed []byte, voteList [][]byte) []int {
+	// The tags of all votes share one buffer and one mac, so the order does
+	// not allocate per vote.
+	mac := hmac.New(sha256.New, seed)
+	size := mac.Size()
+	tags := make([]byte, len(voteList)*size)
+	order := make([]int, len(voteList))
+	for i, vote := range voteList {
+		mac.Reset()
+		mac.Write(vote)
+		mac.Sum(tags[i*size : i*size])
+		order[i] = i
+	}
+
+	tag := func(i int) []byte {
+		return tags[i*size : (i+1)*size]
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return bytes.Compare(tag(order[i]), tag(order[j])) < 0
+	})
+	return order
+}
+
+// recordOrderSeed writes the sealed order seed of a poll to the audit log.
+//
+// The message contains the sha256 hash of the seed. If a Sealer is configured,
+// it also contains the sealed seed, so an auditor can reproduce the order.
+func (d *Decrypt) recordOrderSeed(ctx context.Context, pollID string, pollKey []byte) error {
+	seed := orderSeed(pollKey)
+	hash := sha256.Sum256(seed)
+	message := fmt.Sprintf("seed_hash=%x", hash)
+
+	if d.sealer != nil {
+		sealed, err := d.sealer.Seal(seed)
+		if err != nil {
+			return fmt.Errorf("sealing order seed: %w", err)
+		}
+		message += " sealed_seed=" + base64.StdEncoding.EncodeToString(sealed)
+	}
+
+	return d.record(ctx, "order", pollID, message)
+}
+
+// decryptVotes decrypts a list of votes and returns them in the order of
+// voteOrder(). If weights are given, they are returned in the same order.
+//
+// Votes, that are rejected by a vote filter, are not decrypted. They are
+// counted by the reason of the rejection. Decrypted votes that are bigger then
+// the maximum plaintext size are handled by the oversize policy.
+//
+// config is the configuration of the poll. It defines, if the votes need a
+// voting token (WithTokens()) or a ring signature (WithRing()).
+//
+// If the votes have tags, the votes of a pseudonym are handled by the revote
+// policy of the poll. It returns the number of superseded votes.
+//
+// The votes are decrypted by the workers of the pool. If progress is not nil,
+// it is called after each vote.
+//
+// If codes is not nil, the workers write the tracking code of each vote to it
+// at the index of the vote in voteList. So the commitment does not need a
+// second pass over the encrypted votes.
+func (d *Decrypt) decryptVotes(pool *workerPool, progress func(), key []byte, replacements [][]byte, pollID string, voteList [][]byte, stopConfig StopConfig, config StartConfig, codes []byte) ([][]byte, []string, map[string]int, int, error) {
+	weights := stopConfig.Weights
+	order := voteOrder(orderSeed(key), voteList)
+
+	batch := 1
+	if d.tuner != nil {
+		batch = d.tuner.batch(len(order), pool.workers)
+	}
+
+	decrypter := d.newVoteDecrypter(key, replacements, pollID)
+	buffers := smallVoteBuffers(decrypter, voteList, order)
+
+	// Each job decrypts the votes of one batch and writes them to their own
+	// positions of results. All jobs share the same function, so sending a
+	// job does not allocate.
+	results := make([]decryptedVote, len(voteList))
+	var wg sync.WaitGroup
+	run := func(start, end int) {
+		defer wg.Done()
+		began := time.Now()
+		for pos := start; pos < end; pos++ {
+			var buf []byte
+			if buffers != nil {
+				buf = buffers[pos]
+			}
+			index := order[pos]
+			if codes != nil {
+				hash := sha256.Sum256(voteList[index])
+				copy(codes[index*sha256.Size:], hash[:])
+			}
+			results[pos] = d.decryptVote(decrypter, buf, voteList[index], config)
+			if progress != nil {
+				progress()
+			}
+		}
+
+		if d.tuner != nil {
+			d.tuner.observe(end-start, time.Since(began))
+		}
+	}
+
+	for start := 0; start < len(order); start += batch {
+		wg.Add(1)
+		pool.jobs <- poolJob{run: run, start: start, end: min(start+batch, len(order))}
+	}
+	wg.Wait()
+
+	// Bundle decrypted votes.
+	decryptedList := make([][]byte, 0, len(voteList))
+	var weightList []string
+	if weights != nil {
+		weightList = make([]string, 0, len(weights))
+	}
+	// A token or a key image, that was used more than once, invalidates all
+	// its votes. It is not possible to tell, which of them is the legitimate
+	// one.
+	tokenCount := make(map[string]int)
+	imageCount := make(map[string]int)
+	for _, decrypted := range results {
+		if decrypted.token != nil {
+			tokenCount[string(decrypted.token)]++
+		}
+		if decrypted.keyImage != nil {
+			imageCount[string(decrypted.keyImage)]++
+		}
+	}
+
+	for pos, decrypted := range results {
+		if decrypted.err != nil {
+			return nil, nil, nil, 0, decrypted.err
+		}
+
+		if decrypted.token != nil && tokenCount[string(decrypted.token)] > 1 {
+			results[pos].invalid = "token used twice"
+		}
+
+		if decrypted.keyImage != nil && imageCount[string(decrypted.keyImage)] > 1 {
+			results[pos].invalid = "signer voted twice"
+		}
+	}
+
+	superseded := applyRevotePolicy(config.Revote, results, order, unlinkTags(key, stopConfig.Tags))
+
+	var invalid map[string]int
+	for pos, decrypted := range results {
+		if decrypted.superseded {
+			// Drop the reference to the plaintext of the superseded vote, so
+			// it can not be linked with the latest vote of the voter.
+			results[pos].value = nil
+			continue
+		}
+
+		if decrypted.invalid != "" {
+			if invalid == nil {
+				invalid = make(map[string]int)
+			}
+			invalid[decrypted.invalid]++
+			continue
+		}
+		decryptedList = append(decryptedList, decrypted.value)
+		if weights != nil {
+			weightList = append(weightList, weights[order[pos]])
+		}
+	}
+
+	return decryptedList, weightList, invalid, superseded, nil
+}
+
+// applyRevotePolicy handles the valid votes of pseudonyms with more than one
+// vote. results are the decrypted votes in the order of the result and
+// order[pos] is the position of a result in tags. It returns the number of
+// superseded votes.
+func applyRevotePolicy(policy RevotePolicy, results []decryptedVote, order []int, tags []VoteTag) int {
+	if tags == nil {
+		return 0
+	}
+
+	// later reports, if the vote at pos replaces the vote at other. A vote,
+	// that could not be decrypted, never replaces a decrypted vote. With the
+	// same time, the later vote in the request wins.
+	later := func(pos, other int) bool {
+		if results[pos].failed != results[other].failed {
+			return results[other].failed
+		}
+
+		t, otherTime := tags[order[pos]].Time, tags[order[other]].Time
+		if !t.Equal(otherTime) {
+			return t.After(otherTime)
+		}
+		return order[pos] > order[other]
+	}
+
+	// last is the position of the latest vote of each pseudonym.
+	last := make(map[string]int)
+	count := make(map[string]int)
+	for pos, decrypted := range results {
+		if decrypted.invalid != "" {
+			continue
+		}
+
+		pseudonym := tags[order[pos]].Pseudonym
+		count[pseudonym]++
+
+		if other, ok := last[pseudonym]; !ok || later(pos, other) {
+			last[pseudonym] = pos
+		}
+	}
+
+	var superseded int
+	for pos, decrypted := range results {
+		if decrypted.invalid != "" {
+			continue
+		}
+
+		pseudonym := tags[order[pos]].Pseudonym
+		if count[pseudonym] < 2 {
+			continue
+		}
+
+		switch policy {
+		case RevoteLast:
+			if last[pseudonym] != pos {
+				results[pos].superseded = true
+				superseded++
+			}
+		default:
+			results[pos].invalid = "voted twice"
+		}
+	}
+	return superseded
+}
+
+// validateTags makes sure, that there is a tag with a pseudonym for each vote,
+// if tags are given.
+func validateTags(tags []VoteTag, votes int) error {
+	if tags == nil {
+		return nil
+	}
+
+	if len(tags) != votes {
+		return fmt.Errorf("got %d tags for %d votes: %w", len(tags), votes, errorcode.Invalid)
+	}
+
+	for i, tag := range tags {
+		if tag.Pseudonym == "" {
+			return fmt.Errorf("tag %d has no pseudonym: %w", i, errorcode.Invalid)
 		}
 	}
+	return nil
+}
 
-	pubKey, pubKeySig, err = d.crypto.PublicPollKey(pollKey)
-	if err != nil {
-		return nil, nil, fmt.Errorf("signing pub key: %w", err)
+// weightPattern is the format of a vote weight. A positive decimal number like
+// `1` or `2.5`.
+var weightPattern = regexp.MustCompile(`^[0-9]{1,15}(\.[0-9]{1,15})?$`)
+
+// validateWeights makes sure, that there is a valid weight for each vote, if
+// weights are given.
+func validateWeights(weights []string, votes int) error {
+	if weights == nil {
+		return nil
 	}
 
-	// Log the pubKey as base64 as long as the backend does not support his
-	log.Printf("public poll key for poll %s is %s", pollID, base64.StdEncoding.EncodeToString(pubKey))
-	return pubKey, pubKeySig, nil
+	if len(weights) != votes {
+		return fmt.Errorf("got %d weights for %d votes: %w", len(weights), votes, errorcode.Invalid)
+	}
+
+	for i, weight := range weights {
+		if !weightPattern.MatchString(weight) {
+			return fmt.Errorf("weight %d is not a decimal number: %w", i, errorcode.Invalid)
+		}
+	}
+	return nil
 }
 
-// Stop takes a list of ecrypted votes, decryptes them and returns them in a
-// random order together with a signature.
+// runMemory estimates the memory in bytes, that is needed to decrypt the votes
+// and create the content. It is the size of the encrypted votes for the
+// decrypted votes, the content and its copies, and an overhead for each vote.
+func runMemory(voteList [][]byte) int64 {
+	var size int64
+	for _, vote := range voteList {
+		size += int64(len(vote))
+	}
+	return 3*size + 256*int64(len(voteList))
+}
+
+// Limits of the priority of a stop call. The priority is a label of the queue
+// metrics, so the number of values has to be small.
+const (
+	MinPriority = -10
+	MaxPriority = 10
+)
+
+var (
+	metricQueueDepth = metrics.NewGauge(
+		"vote_decrypt_stop_queue_depth",
+		"Number of stop calls, that wait for resources.",
+		"priority",
+	)
+
+	metricQueueWaits = metrics.NewCounter(
+		"vote_decrypt_stop_queue_waits_total",
+		"Number of stop calls, that got resources.",
+		"priority",
+	)
+
+	metricQueueWaitSeconds = metrics.NewCounter(
+		"vote_decrypt_stop_queue_wait_seconds_total",
+		"Time, that stop calls waited for resources.",
+		"priority",
+	)
+)
+
+// budget limits the number of parallel stop runs and their estimated memory.
+// The zero value has no limits.
 //
-// If the function is called multiple times with the same pollID and voteList,
-// it returns the same output. But if fails if it is called with different
-// votes.
+// Runs, that have to wait, are started by priority. Runs with the same
+// priority are started in the order of their calls. A waiting run blocks
+// all runs with a lower priority, so a big run is not starved by small ones.
+type budget struct {
+	maxRuns  int
+	maxBytes int64
+
+	mu      sync.Mutex
+	runs    int
+	bytes   int64
+	waiting []*budgetWaiter
+}
+
+type budgetWaiter struct {
+	priority int
+	size     int64
+	ready    chan struct{} // Closed, when the run got its resources.
+}
+
+// acquire waits until a run with the estimated memory size can start. The
+// returned function has to be called after the run.
 //
-// TODO: This implementation is wrong. Not the output has to be hashed and saved, but the input.
-func (d *Decrypt) Stop(ctx context.Context, pollID string, voteList [][]byte) (decryptedContent, signature []byte, err error) {
-	pollKey, err := d.store.LoadKey(pollID)
-	if err != nil {
-		return nil, nil, fmt.Errorf("loading poll key: %w", err)
+// A run, that is bigger then the whole budget, fails with errorcode.Limit.
+func (b *budget) acquire(ctx context.Context, priority int, size int64) (func(), error) {
+	if b.maxRuns == 0 && b.maxBytes == 0 {
+		return func() {}, nil
 	}
 
-	if len(voteList) > d.maxVotes {
-		return nil, nil, fmt.Errorf("received %d votes, only %d votes supported: %w", len(voteList), d.maxVotes, errorcode.Invalid)
+	if b.maxBytes > 0 && size > b.maxBytes {
+		return nil, fmt.Errorf("poll needs about %d bytes, memory budget is %d bytes: %w", size, b.maxBytes, errorcode.Limit)
 	}
 
-	decrypted, err := d.decryptVotes(pollKey, voteList)
-	if err != nil {
-		return nil, nil, fmt.Errorf("decrypting votes: %w", err)
-	}
+	label := strconv.Itoa(priority)
+	start := time.Now()
+	w := &budgetWaiter{priority: priority, size: size, ready: make(chan struct{})}
 
-	decryptedContent, err = d.listToContent(pollID, decrypted)
-	if err != nil {
-		return nil, nil, fmt.Errorf("creating content: %w", err)
-	}
+	b.mu.Lock()
+	i := sort.Search(len(b.waiting), func(i int) bool { return b.waiting[i].priority < priority })
+	b.waiting = append(b.waiting, nil)
+	copy(b.waiting[i+1:], b.waiting[i:])
+	b.waiting[i] = w
+	metricQueueDepth.Add(1, label)
+	b.dispatch()
+	b.mu.Unlock()
 
-	signature = d.crypto.Sign(decryptedContent)
+	select {
+	case <-w.ready:
+		metricQueueWaits.Inc(label)
+		metricQueueWaitSeconds.Add(time.Since(start).Seconds(), label)
+		return func() { b.release(size) }, nil
 
-	// This has to be the last step of this function to protect agains timing
-	// attacks. All other steps have to be run, even when the calll is doomed to
-	// fail in this step
-	if err := d.store.ValidateSignature(pollID, signature); err != nil {
-		if errors.Is(err, errorcode.Invalid) {
-			return nil, nil, fmt.Errorf("stop was called with different parameters before")
+	case <-ctx.Done():
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		select {
+		case <-w.ready:
+			// The run got its resources at the same time.
+			b.runs--
+			b.bytes -= size
+		default:
+			for i, other := range b.waiting {
+				if other == w {
+					b.waiting = append(b.waiting[:i], b.waiting[i+1:]...)
+					break
+				}
+			}
+			metricQueueDepth.Add(-1, label)
 		}
-		return nil, nil, fmt.Errorf("validate signature: %w", err)
+		b.dispatch()
+		return nil, ctx.Err()
 	}
+}
 
-	return decryptedContent, signature, nil
+func (b *budget) release(size int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.runs--
+	b.bytes -= size
+	b.dispatch()
 }
 
-// Clear stops a poll by removing the generated cryptographic key.
-func (d *Decrypt) Clear(ctx context.Context, pollID string) error {
-	if err := d.store.ClearPoll(pollID); err != nil {
-		return fmt.Errorf("clearing poll from store: %w", err)
+// dispatch starts the waiting runs in order, until the first one does not fit.
+// It has to be called with the lock.
+func (b *budget) dispatch() {
+	for len(b.waiting) > 0 {
+		w := b.waiting[0]
+		if (b.maxRuns > 0 && b.runs >= b.maxRuns) || (b.maxBytes > 0 && b.bytes+w.size > b.maxBytes) {
+			return
+		}
+
+		b.runs++
+		b.bytes += w.size
+		b.waiting = b.waiting[1:]
+		metricQueueDepth.Add(-1, strconv.Itoa(w.priority))
+		close(w.ready)
 	}
-	return nil
 }
 
-// randInt returns a random int between 0 and n from a random source like crypt.Reader
-func randInt(source io.Reader, n int) (int, error) {
-	if n <= 0 {
-		return 0, nil
+// workerPool is a fixed number of goroutines, that decrypt votes. One pool can
+// be shared by many polls, so they do not use more then the configured number
+// of decrypt workers together.
+type workerPool struct {
+	jobs    chan poolJob
+	wg      sync.WaitGroup
+	workers int
+}
+
+// poolJob decrypts the votes from start to end.
+type poolJob struct {
+	run        func(start, end int)
+	start, end int
+}
+
+func newWorkerPool(workers int) *workerPool {
+	p := &workerPool{jobs: make(chan poolJob), workers: workers}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				job.run(job.start, job.end)
+			}
+		}()
 	}
+	return p
+}
 
-	r, err := rand.Int(source, big.NewInt(int64(n)))
-	if err != nil {
-		return 0, fmt.Errorf("getting random number from rand.Int: %w", err)
+// newPool returns a worker pool for the given number of votes. With
+// WithAutoTune(), the number of workers depends on the measured cost of the
+// votes.
+func (d *Decrypt) newPool(votes int) *workerPool {
+	workers := d.decryptWorkers
+	if d.tuner != nil {
+		workers = d.tuner.workers(votes, workers)
 	}
+	return newWorkerPool(workers)
+}
 
-	return int(r.Int64()), nil
+// close stops the workers after all jobs are done.
+func (p *workerPool) close() {
+	close(p.jobs)
+	p.wg.Wait()
 }
 
-// decryptVotes decrypts a list of votes and returns them decrypted in random
-// order.
+// smallVoteSize is the maximal size of an encrypted vote, that is decrypted
+// into the shared buffer of smallVoteBuffers().
+const smallVoteSize = 1024
+
+// smallVoteBuffers returns a buffer for the plaintext of each small vote at
+// its position in the result. All buffers are parts of one allocation, so
+// decrypting a small vote does not allocate memory for its plaintext. The
+// plaintext is smaller than the ciphertext, so the buffer of a vote has the
+// size of its ciphertext.
 //
-// Uses `d.decrptWorkers` parallel goroutines.
-func (d *Decrypt) decryptVotes(key []byte, voteList [][]byte) ([][]byte, error) {
-	voteChan := make(chan []byte, 1)
+// It returns nil, if the crypto backend can not decrypt into a buffer.
+// Bigger votes get a nil buffer.
+func smallVoteBuffers(decrypter voteDecrypter, voteList [][]byte, order []int) [][]byte {
+	if decrypter.prepared == nil {
+		return nil
+	}
 
-	// Choose a random vote from the voteList and sends them to voteChan.
-	go func() {
-		defer close(voteChan)
-
-		n := len(voteList)
-		for n > 0 {
-			i, err := randInt(d.random, n-1)
-			if err != nil {
-				// TODO: handle error
-				panic(err)
-			}
+	size := 0
+	for _, vote := range voteList {
+		if len(vote) <= smallVoteSize {
+			size += len(vote)
+		}
+	}
 
-			voteChan <- voteList[i]
-			voteList[i] = voteList[n-1]
-			n--
+	shared := make([]byte, size)
+	buffers := make([][]byte, len(order))
+	offset := 0
+	for pos, i := range order {
+		if size := len(voteList[i]); size <= smallVoteSize {
+			// The capacity is limited, so a vote can not write into the
+			// buffer of the next vote.
+			buffers[pos] = shared[offset : offset : offset+size]
+			offset += size
 		}
-	}()
+	}
+	return buffers
+}
 
-	// Decrypt votes in parallel using multiple "decrypt workers". Receiving the
-	// votes from voteChan and sending them to decryptedChan.
-	var wg sync.WaitGroup
-	wg.Add(d.decryptWorkers)
-	decryptedChan := make(chan []byte, 1)
-	for i := 0; i < d.decryptWorkers; i++ {
-		go func() {
-			defer wg.Done()
-			for vote := range voteChan {
-				decrypted, err := d.crypto.Decrypt(key, vote)
-				if err != nil {
-					// TODO: Is is allowed to log the error?
-					log.Printf("TODO: vote: %v", err)
-					decrypted = d.decryptErrorValue
-				}
+// voteDecrypter decrypts the votes of one poll with the poll key and, if this
+// fails, with the replacement keys.
+type voteDecrypter struct {
+	crypto       Crypto
+	key          []byte
+	replacements [][]byte
+	pollID       string
+
+	// prepared are the functions of PollDecrypter for the key and each
+	// replacement key. It is nil, if the crypto backend does not implement it.
+	prepared []func(dst, value []byte) ([]byte, error)
+}
+
+func (d *Decrypt) newVoteDecrypter(key []byte, replacements [][]byte, pollID string) voteDecrypter {
+	decrypter := voteDecrypter{
+		crypto:       d.crypto,
+		key:          key,
+		replacements: replacements,
+		pollID:       pollID,
+	}
+
+	pd, ok := d.crypto.(PollDecrypter)
+	if !ok {
+		return decrypter
+	}
+
+	prepared := make([]func(dst, value []byte) ([]byte, error), 0, 1+len(replacements))
+	for _, k := range append([][]byte{key}, replacements...) {
+		f, err := pd.PollDecrypter(k, pollID)
+		if err != nil {
+			// Fall back to DecryptPoll(), that returns the error for each
+			// vote.
+			return decrypter
+		}
+		prepared = append(prepared, f)
+	}
+
+	decrypter.prepared = prepared
+	return decrypter
+}
 
-				decryptedChan <- decrypted
+// decrypt decrypts a vote. If possible, the plaintext is appended to dst.
+func (v voteDecrypter) decrypt(dst, vote []byte) ([]byte, error) {
+	if v.prepared == nil {
+		decrypted, err := v.crypto.DecryptPoll(v.key, v.pollID, vote)
+		for _, replacement := range v.replacements {
+			if err == nil {
+				break
 			}
-		}()
+			decrypted, err = v.crypto.DecryptPoll(replacement, v.pollID, vote)
+		}
+		return decrypted, err
 	}
 
-	// Close the decryptedChan when all the decryption is done.
-	go func() {
-		wg.Wait()
-		close(decryptedChan)
-	}()
+	decrypted, err := v.prepared[0](dst, vote)
+	for _, decrypt := range v.prepared[1:] {
+		if err == nil {
+			break
+		}
+		decrypted, err = decrypt(dst, vote)
+	}
+	return decrypted, err
+}
 
-	// Bundle decrypted votes.
-	decryptedList := make([][]byte, len(voteList))
-	var i int
-	for decrypted := range decryptedChan {
-		decryptedList[i] = decrypted
-		i++
+// decryptVote decrypts one vote. If the poll has a ring, the ring signature is
+// validated and removed from the vote. If the poll uses tokens, the voting
+// token is validated and removed from the plaintext.
+//
+// If possible, the plaintext is appended to buf.
+func (d *Decrypt) decryptVote(decrypter voteDecrypter, buf []byte, vote []byte, config StartConfig) decryptedVote {
+	key, pollID := decrypter.key, decrypter.pollID
+
+	var keyImage []byte
+	if len(config.Ring) > 0 {
+		verifier, ok := d.crypto.(RingVerifier)
+		if !ok {
+			return decryptedVote{invalid: "invalid ring signature"}
+		}
+
+		image, ciphertext, err := verifier.VerifyRing(config.Ring, pollID, vote)
+		if err != nil {
+			return decryptedVote{invalid: "invalid ring signature"}
+		}
+		keyImage = image
+		vote = ciphertext
+	}
+
+	if reason := d.filterVote(vote); reason != "" {
+		return decryptedVote{invalid: reason}
+	}
+
+	decrypted, err := decrypter.decrypt(buf, vote)
+	failed := err != nil
+	if failed {
+		// TODO: Is is allowed to log the error?
+		log.Printf("Debug: decrypting vote: %v", err)
+		decrypted = d.decryptErrorValue
+	}
+
+	var token []byte
+	if config.Tokens {
+		issuer, ok := d.crypto.(TokenIssuer)
+		if !ok || err != nil || len(decrypted) < issuer.TokenSize() {
+			return decryptedVote{invalid: "invalid token"}
+		}
+
+		token = decrypted[:issuer.TokenSize()]
+		if err := issuer.ValidateToken(key, token); err != nil {
+			return decryptedVote{invalid: "invalid token"}
+		}
+		decrypted = decrypted[issuer.TokenSize():]
+	}
+
+	if len(decrypted) > d.maxPlaintextSize {
+		switch d.oversizePolicy {
+		case OversizeInvalid:
+			return decryptedVote{invalid: "plaintext too large"}
+		default:
+			return decryptedVote{err: fmt.Errorf("decrypted vote has %d bytes, only %d bytes supported: %w", len(decrypted), d.maxPlaintextSize, errorcode.Limit)}
+		}
+	}
+
+	return decryptedVote{value: decrypted, token: token, keyImage: keyImage, failed: failed}
+}
+
+// decryptedVote is the result of one vote in decryptVotes(). If invalid is
+// not empty, the vote was rejected with this reason. If err is set, the poll
+// can not be decrypted. token is the voting token of the vote, if the poll
+// uses tokens. keyImage identifies the signer of the vote, if the poll has a
+// ring. failed is set, if the vote could not be decrypted and value is the
+// decrypt error value. superseded is set, if a later vote of the same
+// pseudonym replaces the vote.
+type decryptedVote struct {
+	value      []byte
+	invalid    string
+	token      []byte
+	keyImage   []byte
+	failed     bool
+	superseded bool
+	err        error
+}
+
+// filterVote returns the reason, why a vote is rejected by a vote filter. It
+// returns an empty string, if the vote is accepted.
+func (d *Decrypt) filterVote(vote []byte) string {
+	for _, filter := range d.voteFilters {
+		if err := filter(vote); err != nil {
+			return err.Error()
+		}
 	}
-	return decryptedList, nil
+	return ""
 }
 
-// validateID makes sure, the id can be used for the filesystem store.
+// validateID makes sure, the id can be used for the filesystem store and is
+// accepted by all validators from WithIDValidator().
+//
+// An id can only contain letters, digits, `/` and `.`. It can not be empty and
+// can not contain empty segments or the segments `.` and `..` between the
+// slashes. So it can not be used for path traversal in the stores.
 func (d *Decrypt) validateID(id string) error {
+	if id == "" {
+		return fmt.Errorf("id is empty: %w", errorcode.Invalid)
+	}
+
 	for _, c := range id {
 		if !((c >= 'a' && c <= 'z') ||
 			(c >= 'A' && c <= 'Z') ||
@@ -235,9 +3193,65 @@ func (d *Decrypt) validateID(id string) error {
 			return fmt.Errorf("id contains invalid character %c: %w", c, errorcode.Invalid)
 		}
 	}
+
+	for _, segment := range strings.Split(id, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("id contains invalid segment %q: %w", segment, errorcode.Invalid)
+		}
+	}
+
+	for _, validator := range d.idValidators {
+		if err := validator(id); err != nil {
+			return fmt.Errorf("%w: %w", err, errorcode.Invalid)
+		}
+	}
 	return nil
 }
 
+// IDValidator checks a poll id, that was received from a caller. If it
+// returns an error, the call is refused with errorcode.Invalid.
+type IDValidator func(id string) error
+
+// IDPattern returns an IDValidator, that only accepts ids, that match the
+// pattern. The pattern should be anchored with `^` and `$`.
+func IDPattern(pattern *regexp.Regexp) IDValidator {
+	return func(id string) error {
+		if !pattern.MatchString(id) {
+			return fmt.Errorf("id does not match %s", pattern)
+		}
+		return nil
+	}
+}
+
+// IDMaxLength returns an IDValidator, that only accepts ids with up to
+// maxLength bytes.
+func IDMaxLength(maxLength int) IDValidator {
+	return func(id string) error {
+		if len(id) > maxLength {
+			return fmt.Errorf("id has %d bytes, only %d are allowed", len(id), maxLength)
+		}
+		return nil
+	}
+}
+
+// IDNamespaces returns an IDValidator, that only accepts ids in one of the
+// namespaces. The namespace of an id is the part before the first `/`. For
+// example `meeting1` for `meeting1/42`.
+func IDNamespaces(namespaces ...string) IDValidator {
+	allowed := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		allowed[namespace] = true
+	}
+
+	return func(id string) error {
+		namespace, _, found := strings.Cut(id, "/")
+		if !found || !allowed[namespace] {
+			return fmt.Errorf("id is not in an allowed namespace")
+		}
+		return nil
+	}
+}
+
 // Crypto implements all required cryptographic functions.
 type Crypto interface {
 	// CreatePollKey creates a new keypair for a poll.
@@ -246,16 +3260,89 @@ type Crypto interface {
 	// PublicPollKey returns the public poll key and the signature for a given key.
 	PublicPollKey(key []byte) (pubKey []byte, pubKeySig []byte, err error)
 
-	// Decrypt returned the plaintext from value using the key.
-	Decrypt(key []byte, value []byte) ([]byte, error)
+	// DecryptPoll returned the plaintext from value using the key.
+	//
+	// pollID is the id of the poll, the vote belongs to. It has to be used to
+	// make sure, that the vote was encrypted for this poll, if the format of
+	// value supports it.
+	DecryptPoll(key []byte, pollID string, value []byte) ([]byte, error)
 
 	// Sign returns the signature for the given data.
-	Sign(value []byte) []byte
+	Sign(value []byte) ([]byte, error)
 
 	// PublicMainKey returns the public main key.
 	PublicMainKey() []byte
 }
 
+// PollDecrypter is implemented by crypto backends, that can prepare the
+// decryption of the votes of a poll. crypto.Crypto implements it.
+//
+// Small votes are decrypted into one buffer, so they do not allocate memory
+// for the plaintext.
+type PollDecrypter interface {
+	// PollDecrypter returns a function, that decrypts the votes of a poll like
+	// DecryptPoll() and appends the plaintext to dst.
+	PollDecrypter(key []byte, pollID string) (func(dst, value []byte) ([]byte, error), error)
+}
+
+// TokenIssuer is implemented by crypto backends, that can issue voting tokens.
+// See WithTokens().
+type TokenIssuer interface {
+	// PublicTokenKey returns the public token key of a poll and its signature
+	// created with the main key.
+	PublicTokenKey(key []byte) (pubKey []byte, pubKeySig []byte, err error)
+
+	// IssueToken evaluates a blinded token with the token key of the poll and
+	// returns it with a proof, that it was created with the public token key.
+	IssueToken(key []byte, blinded []byte) (evaluated, proof []byte, err error)
+
+	// ValidateToken returns an error, if the token was not issued for the poll.
+	ValidateToken(key []byte, token []byte) error
+
+	// TokenSize returns the size of a token at the beginning of a plaintext.
+	TokenSize() int
+}
+
+// PollSigner is implemented by crypto backends, that can sign the artifacts of
+// a poll with a key, that is derived for the poll. See WithPollSigningKeys().
+type PollSigner interface {
+	// PollSigningKey returns the public poll signing key of a poll and its
+	// delegation signed by the main key.
+	PollSigningKey(key []byte, pollID string) (pubKey, delegation []byte, err error)
+
+	// SignPoll signs value with the poll signing key of a poll.
+	SignPoll(key []byte, pollID string, value []byte) ([]byte, error)
+}
+
+// MeetingSigner is implemented by crypto backends, that can sign the artifacts
+// of the polls of a meeting with a key, that is derived for the meeting. See
+// WithMeetingSigningKeys().
+type MeetingSigner interface {
+	// MeetingSigningKey returns the public meeting signing key of a meeting
+	// and its delegation signed by the main key.
+	MeetingSigningKey(secret []byte, meetingID string) (pubKey, delegation []byte, err error)
+
+	// SignMeeting signs value with the meeting signing key of a meeting.
+	SignMeeting(secret []byte, meetingID string, value []byte) ([]byte, error)
+}
+
+// RingVerifier is implemented by crypto backends, that can verify ring
+// signatures of votes. See WithRing().
+type RingVerifier interface {
+	// VerifyRing checks the ring signature of a vote. It returns the key image,
+	// that is the same for all votes of a signer in a poll, and the ciphertext
+	// without the signature.
+	VerifyRing(ring [][]byte, pollID string, vote []byte) (keyImage, ciphertext []byte, err error)
+}
+
+// PartialDecrypter is implemented by crypto backends, that can create
+// decryption shares for Decrypt.PartialDecrypt().
+type PartialDecrypter interface {
+	// PartialDecrypt returns the decryption share of a vote for the key and a
+	// proof, that the share was created with the key.
+	PartialDecrypt(key []byte, pollID string, value []byte) (share, proof []byte, err error)
+}
+
 // Store saves the data, that have to be persistent.
 type Store interface {
 	// SaveKey stores the private key.
@@ -277,25 +3364,266 @@ type Store interface {
 	// Has to return `errorcode.NotExist` when the id does not exist.
 	ValidateSignature(id string, hash []byte) error
 
+	// SaveMeta stores the meta data of a poll.
+	//
+	// Has to return an error `errorcode.Exist` if the meta data is already
+	// known.
+	SaveMeta(id string, meta []byte) error
+
+	// LoadMeta returns the meta data of a poll.
+	//
+	// If the meta data is unknown return `errorcode.NotExist`
+	LoadMeta(id string) (meta []byte, err error)
+
 	// ClearPoll removes all data for the poll.
 	//
 	// Does not return an error if poll does not exist.
 	ClearPoll(id string) error
+
+	// ListPolls returns the ids of all polls in the store.
+	ListPolls() ([]string, error)
+
+	// ScheduleClear saves the time when the poll should be removed.
+	//
+	// Overwrites an older schedule for the same poll. The schedule is removed
+	// by ClearPoll().
+	ScheduleClear(id string, at time.Time) error
+
+	// ScheduledClears returns all polls that are scheduled to be removed with
+	// the time of the removal.
+	ScheduledClears() (map[string]time.Time, error)
+
+	// SaveCommitment stores the leaves of the merkle tree over the votes of a
+	// stopped poll.
+	//
+	// Has to return an error `errorcode.Exist` if the commitment is already
+	// known.
+	SaveCommitment(id string, leaves []byte) error
+
+	// LoadCommitment returns the leaves saved with SaveCommitment().
+	//
+	// If the commitment is unknown return `errorcode.NotExist`
+	LoadCommitment(id string) (leaves []byte, err error)
+}
+
+// HashStore is implemented by stores, that can return the hash, that was saved
+// with ValidateSignature(). It is needed to replicate stopped polls.
+type HashStore interface {
+	// LoadHash returns the hash of a poll, that was saved with
+	// ValidateSignature().
+	//
+	// If the poll was not stopped, it returns `errorcode.NotExist`.
+	LoadHash(id string) (hash []byte, err error)
+}
+
+// RevocationStore is implemented by stores, that can save revoked poll keys.
+// It is needed for Decrypt.Revoke().
+type RevocationStore interface {
+	// SaveRevocation appends a revocation to the list of revocations.
+	//
+	// The list is not changed by ClearPoll().
+	SaveRevocation(revocation []byte) error
+
+	// Revocations returns all revocations in the order, they were saved.
+	Revocations() ([][]byte, error)
+}
+
+// RekeyStore is implemented by stores, that can save replacement keys of a
+// poll. It is needed for Decrypt.Rekey().
+type RekeyStore interface {
+	// SaveReplacementKey appends a private key to the replacement keys of a
+	// poll.
+	//
+	// The replacement keys are removed by ClearPoll().
+	SaveReplacementKey(id string, key []byte) error
+
+	// LoadReplacementKeys returns the replacement keys of a poll in the order,
+	// they were saved. It returns no keys and no error, if the poll has no
+	// replacement keys.
+	LoadReplacementKeys(id string) ([][]byte, error)
+}
+
+// AuditLog records security relevant events.
+type AuditLog interface {
+	// Record saves an event. pollID can be empty, if the event does not
+	// belong to a poll.
+	Record(event, pollID, message string) error
+}
+
+// AuditReader is an AuditLog, whose events can be read again. It is needed
+// for NoDecryption().
+type AuditReader interface {
+	AuditLog
+
+	// Events returns the names of all events of a poll in the order, they
+	// were recorded.
+	Events(pollID string) ([]string, error)
+}
+
+// AuditExporter is an AuditLog, that can export its entries. It is needed for
+// ExportAuditLog().
+type AuditExporter interface {
+	AuditLog
+
+	// Export returns the entries of the poll, that were recorded between from
+	// (inclusive) and to (exclusive), as one json object per line. An empty
+	// pollID and zero times match all entries.
+	Export(pollID string, from, to time.Time) ([]byte, error)
+}
+
+// Attestor creates evidence about the host, for example a TPM quote.
+type Attestor interface {
+	// Attest returns evidence over data.
+	Attest(data []byte) ([]byte, error)
+}
+
+// VoteFilter checks an encrypted vote before it is decrypted, for example its
+// ciphertext format. If it returns an error, the vote is not decrypted. The
+// error message is used as reason in the invalid section of the result.
+type VoteFilter func(vote []byte) error
+
+// Sealer encrypts data for an auditor, for example to a public key, whose
+// private key is kept offline.
+type Sealer interface {
+	// Seal returns the encrypted data.
+	Seal(data []byte) ([]byte, error)
+}
+
+// ResultWriter receives the decrypted votes of a stopped poll one by one, for
+// example to write them to a database or a message queue.
+//
+// The votes are only passed to the writer, after the result was signed and
+// the signature was validated against earlier Stop() calls. This way, a Stop()
+// call with other votes does not reveal any vote. If Stop() is called again
+// with the same votes, the writer receives them again.
+type ResultWriter interface {
+	// WriteVote is called for each decrypted vote in the order of the result.
+	// weight is empty, if the votes have no weights.
+	WriteVote(pollID string, vote []byte, weight string) error
+
+	// Finish is called after the last vote with the content and signature,
+	// that Stop() returns.
+	Finish(pollID string, content, signature []byte) error
+}
+
+// Monitor is told, when the votes of a poll are decrypted, for example to send
+// alerts. See package alert.
+type Monitor interface {
+	// StopStarted is called before the votes of a poll are decrypted.
+	StopStarted(pollID string)
+
+	// StopFinished is called after the votes were decrypted. invalid is the
+	// number of votes, that could not be decrypted or were rejected.
+	StopFinished(pollID string, votes, invalid int, err error)
+}
+
+// logAuditLog is the default AuditLog that writes the events to the default
+// logger.
+type logAuditLog struct{}
+
+func (logAuditLog) Record(event, pollID, message string) error {
+	log.Printf("Audit: event=%s poll=%s %s", event, pollID, message)
+	return nil
+}
+
+type callerKey struct{}
+
+// ContextWithCaller returns a context with the identity of the caller, for
+// example the name of the client certificate. The identity is written to the
+// audit log for all events, that are recorded with this context.
+func ContextWithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the identity, that was set with
+// ContextWithCaller(). It is empty, if no identity was set.
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// record writes an event to the audit log. If the context contains the
+// identity of the caller, it is added to the message.
+func (d *Decrypt) record(ctx context.Context, event, pollID, message string) error {
+	if caller := CallerFromContext(ctx); caller != "" {
+		if message != "" {
+			message += " "
+		}
+		message += fmt.Sprintf("caller=%q", caller)
+	}
+	return d.auditLog.Record(event, pollID, message)
 }
 
-// jsonListToContent creates one byte slice from a list of votes in json format.
-func jsonListToContent(pollID string, decrypted [][]byte) ([]byte, error) {
-	votes := make([]json.RawMessage, len(decrypted))
-	for i, vote := range decrypted {
+// Result is the content of a stopped poll.
+type Result struct {
+	ID       string
+	Votes    [][]byte
+	Metadata []byte
+
+	// Weights are the weights of the votes in the same order. Nil, if the
+	// votes have no weights.
+	Weights []string
+
+	// NotBefore is the earliest stop time of the poll, if it was set.
+	NotBefore *time.Time
+
+	// Invalid counts the votes, that where not decrypted, by the reason of
+	// the rejection.
+	Invalid map[string]int
+
+	// Superseded is the number of votes, that where replaced by a later vote
+	// of the same pseudonym. See RevoteLast.
+	Superseded int
+
+	// CiphertextRoot is the root of the merkle tree over the tracking codes
+	// of all votes. Nil, if the decrypt component does not use
+	// WithCommitment().
+	CiphertextRoot []byte
+	// Election is the id of the election of the poll, if it was started with
+	// WithElection().
+	Election string
+	// Features are the experimental features, that the poll uses. See
+	// WithFeatures().
+	Features []Feature
+}
+
+// jsonResultToContent creates one byte slice from a result in json format.
+func jsonResultToContent(result Result) ([]byte, error) {
+	votes := make([]json.RawMessage, len(result.Votes))
+	for i, vote := range result.Votes {
 		votes[i] = vote
 	}
 
+	var weights []json.Number
+	if result.Weights != nil {
+		weights = make([]json.Number, len(result.Weights))
+		for i, weight := range result.Weights {
+			weights[i] = json.Number(weight)
+		}
+	}
+
 	content := struct {
-		ID    string            `json:"id"`
-		Votes []json.RawMessage `json:"votes"`
+		ID         string            `json:"id"`
+		Votes      []json.RawMessage `json:"votes"`
+		Weights    []json.Number     `json:"weights,omitempty"`
+		Metadata   []byte            `json:"metadata,omitempty"`
+		NotBefore  *time.Time        `json:"not_before,omitempty"`
+		Invalid    map[string]int    `json:"invalid,omitempty"`
+		Superseded int               `json:"superseded,omitempty"`
+		Root       []byte            `json:"ciphertext_root,omitempty"`
+		Election   string            `json:"election,omitempty"`
+		Features   []Feature         `json:"features,omitempty"`
 	}{
-		pollID,
+		result.ID,
 		votes,
+		weights,
+		result.Metadata,
+		result.NotBefore,
+		result.Invalid,
+		result.Superseded,
+		result.CiphertextRoot,
+		result.Election,
+		result.Features,
 	}
 
 	decryptedContent, err := json.Marshal(content)
@@ -305,3 +3633,56 @@ func jsonListToContent(pollID string, decrypted [][]byte) ([]byte, error) {
 
 	return decryptedContent, nil
 }
+
+// ParseResult decodes the content of a stopped poll in the default json
+// format. It fails, if the content is not in this format, or if the number of
+// weights does not match the number of votes.
+func ParseResult(content []byte) (Result, error) {
+	var decoded struct {
+		ID         string            `json:"id"`
+		Votes      []json.RawMessage `json:"votes"`
+		Weights    []json.Number     `json:"weights"`
+		Metadata   []byte            `json:"metadata"`
+		NotBefore  *time.Time        `json:"not_before"`
+		Invalid    map[string]int    `json:"invalid"`
+		Superseded int               `json:"superseded"`
+		Root       []byte            `json:"ciphertext_root"`
+		Election   string            `json:"election"`
+		Features   []Feature         `json:"features"`
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&decoded); err != nil {
+		return Result{}, fmt.Errorf("decoding content: %w", err)
+	}
+
+	result := Result{
+		ID:             decoded.ID,
+		Votes:          make([][]byte, len(decoded.Votes)),
+		Metadata:       decoded.Metadata,
+		NotBefore:      decoded.NotBefore,
+		Invalid:        decoded.Invalid,
+		Superseded:     decoded.Superseded,
+		CiphertextRoot: decoded.Root,
+		Election:       decoded.Election,
+		Features:       decoded.Features,
+	}
+	for i, vote := range decoded.Votes {
+		result.Votes[i] = vote
+	}
+
+	if decoded.Weights != nil {
+		result.Weights = make([]string, len(decoded.Weights))
+		for i, weight := range decoded.Weights {
+			result.Weights[i] = weight.String()
+		}
+
+		if err := validateWeights(result.Weights, len(result.Votes)); err != nil {
+			return Result{}, fmt.Errorf("invalid weights: %w", err)
+		}
+	}
+
+	return result, nil
+}
@@ -3,11 +3,25 @@ package decrypt_test
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/OpenSlides/vote-decrypt/audit"
 	"github.com/OpenSlides/vote-decrypt/decrypt"
 	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/merkle"
+	"github.com/OpenSlides/vote-decrypt/metrics"
+	"github.com/OpenSlides/vote-decrypt/version"
 )
 
 // TODO: test concurency.
@@ -75,7 +89,7 @@ func TestStop(t *testing.T) {
 			t.Errorf("got signature %s, expected signature %s", signature, "sig:"+string(content))
 		}
 
-		expected := `{"id":"test/1","votes":["Y","A","N"]}`
+		expected := `{"id":"test/1","votes":["A","Y","N"]}`
 		if string(content) != expected {
 			t.Errorf("got %s, expected %s", content, expected)
 		}
@@ -104,7 +118,148 @@ func TestStop(t *testing.T) {
 			t.Errorf("got signature %s, expected signature %s", signature, "sig:"+string(content))
 		}
 
-		expected := `{"id":"test/1","votes":["Y","A",{"error":"encryption not valid"}]}`
+		expected := `{"id":"test/1","votes":["A",{"error":"encryption not valid"},"Y"]}`
+		if string(content) != expected {
+			t.Errorf("got %s, expected %s", content, expected)
+		}
+	})
+
+	t.Run("vote filter", func(t *testing.T) {
+		store := NewStoreMock()
+		filter := func(vote []byte) error {
+			if bytes.Contains(vote, []byte("old")) {
+				return errors.New("format old not accepted")
+			}
+			return nil
+		}
+		d := decrypt.New(cr, store, decrypt.WithRandomSource(randomMock{}), decrypt.WithVoteFilter(filter))
+
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		votes := [][]byte{
+			[]byte(`enc:"Y"`),
+			[]byte(`enc:"old"`),
+			[]byte(`enc:"A"`),
+			[]byte(`enc:"old"`),
+		}
+
+		content, _, err := d.Stop(context.Background(), "test/1", votes)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		expected := `{"id":"test/1","votes":["A","Y"],"invalid":{"format old not accepted":2}}`
+		if string(content) != expected {
+			t.Errorf("got %s, expected %s", content, expected)
+		}
+	})
+
+	t.Run("plaintext too large", func(t *testing.T) {
+		votes := func() [][]byte {
+			return [][]byte{
+				[]byte(`enc:"Y"`),
+				[]byte(`enc:"this vote is too large"`),
+				[]byte(`enc:"A"`),
+			}
+		}
+
+		t.Run("fail", func(t *testing.T) {
+			d := decrypt.New(cr, NewStoreMock(), decrypt.WithRandomSource(randomMock{}), decrypt.WithMaxPlaintextSize(10, decrypt.OversizeFail))
+			if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+
+			_, _, err := d.Stop(context.Background(), "test/1", votes())
+			if !errors.Is(err, errorcode.Limit) {
+				t.Errorf("stop returned `%v`, expected `%v`", err, errorcode.Limit)
+			}
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			d := decrypt.New(cr, NewStoreMock(), decrypt.WithRandomSource(randomMock{}), decrypt.WithMaxPlaintextSize(10, decrypt.OversizeInvalid))
+			if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+
+			content, _, err := d.Stop(context.Background(), "test/1", votes())
+			if err != nil {
+				t.Fatalf("stop: %v", err)
+			}
+
+			expected := `{"id":"test/1","votes":["A","Y"],"invalid":{"plaintext too large":1}}`
+			if string(content) != expected {
+				t.Errorf("got %s, expected %s", content, expected)
+			}
+		})
+	})
+
+	t.Run("order", func(t *testing.T) {
+		votes := []string{`enc:"Y"`, `enc:"N"`, `enc:"A"`, `enc:"Y"`}
+
+		var contents []string
+		for _, order := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {1, 3, 0, 2}} {
+			auditLog := new(auditLogMock)
+			d := decrypt.New(cr, NewStoreMock(), decrypt.WithAuditLog(auditLog), decrypt.WithSealer(sealerMock{}))
+			if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+
+			voteList := make([][]byte, len(order))
+			for i, j := range order {
+				voteList[i] = []byte(votes[j])
+			}
+
+			content, _, err := d.Stop(context.Background(), "test/1", voteList)
+			if err != nil {
+				t.Fatalf("stop: %v", err)
+			}
+			contents = append(contents, string(content))
+
+			entry, ok := auditLog.last("order")
+			if !ok {
+				t.Fatalf("no order event in audit log")
+			}
+
+			if !strings.Contains(entry.message, "sealed_seed=") {
+				t.Errorf("order event `%s` does not contain the sealed seed", entry.message)
+			}
+		}
+
+		for _, content := range contents[1:] {
+			if content != contents[0] {
+				t.Errorf("order depends on the order of the input: got %s and %s", contents[0], content)
+			}
+		}
+	})
+
+	t.Run("weights", func(t *testing.T) {
+		d := decrypt.New(cr, NewStoreMock(), decrypt.WithRandomSource(randomMock{}))
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		votes := [][]byte{
+			[]byte(`enc:"Y"`),
+			[]byte(`enc:"N"`),
+			[]byte(`enc:"A"`),
+		}
+
+		if _, _, err := d.Stop(context.Background(), "test/1", votes, decrypt.WithWeights([]string{"1", "2"})); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("stop with missing weight returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "test/1", votes, decrypt.WithWeights([]string{"1", "2", "-3"})); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("stop with negative weight returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+
+		content, _, err := d.Stop(context.Background(), "test/1", votes, decrypt.WithWeights([]string{"1", "2", "3.5"}))
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		expected := `{"id":"test/1","votes":["A","Y","N"],"weights":[3.5,1,2]}`
 		if string(content) != expected {
 			t.Errorf("got %s, expected %s", content, expected)
 		}
@@ -146,8 +301,82 @@ func TestStop(t *testing.T) {
 		}
 
 		_, _, err := d.Stop(context.Background(), "test/1", votes)
-		if !errors.Is(err, errorcode.Invalid) {
-			t.Errorf("stop returned `%v` expected `%v`", err, errorcode.Invalid)
+		if !errors.Is(err, errorcode.Limit) {
+			t.Errorf("stop returned `%v` expected `%v`", err, errorcode.Limit)
+		}
+	})
+
+	t.Run("Vote to large", func(t *testing.T) {
+		store := NewStoreMock()
+		d := decrypt.New(
+			cr,
+			store,
+			decrypt.WithRandomSource(randomMock{}),
+			decrypt.WithMaxVoteSize(7),
+		)
+
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		votes := [][]byte{
+			[]byte(`enc:"Y"`),
+			[]byte(`enc:"No"`),
+		}
+
+		_, _, err := d.Stop(context.Background(), "test/1", votes)
+		if !errors.Is(err, errorcode.Limit) {
+			t.Errorf("stop returned `%v` expected `%v`", err, errorcode.Limit)
+		}
+	})
+
+	t.Run("Poll to large", func(t *testing.T) {
+		store := NewStoreMock()
+		d := decrypt.New(
+			cr,
+			store,
+			decrypt.WithRandomSource(randomMock{}),
+			decrypt.WithMaxPollSize(20),
+		)
+
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		votes := [][]byte{
+			[]byte(`enc:"Y"`),
+			[]byte(`enc:"N"`),
+			[]byte(`enc:"A"`),
+		}
+
+		_, _, err := d.Stop(context.Background(), "test/1", votes)
+		if !errors.Is(err, errorcode.Limit) {
+			t.Errorf("stop returned `%v` expected `%v`", err, errorcode.Limit)
+		}
+	})
+
+	t.Run("with metadata", func(t *testing.T) {
+		store := NewStoreMock()
+		d := decrypt.New(cr, store, decrypt.WithRandomSource(randomMock{}))
+
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithMetadata([]byte("meta"))); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		votes := [][]byte{
+			[]byte(`enc:"Y"`),
+			[]byte(`enc:"N"`),
+			[]byte(`enc:"A"`),
+		}
+
+		content, _, err := d.Stop(context.Background(), "test/1", votes)
+		if err != nil {
+			t.Errorf("stop: %v", err)
+		}
+
+		expected := `{"id":"test/1","votes":["A","Y","N"],"metadata":"bWV0YQ=="}`
+		if string(content) != expected {
+			t.Errorf("got %s, expected %s", content, expected)
 		}
 	})
 
@@ -183,19 +412,2318 @@ func TestStop(t *testing.T) {
 			t.Errorf("got signature %s, expected signature %s", signature, "sig:"+string(content))
 		}
 
-		expected := `"Y","A","N"`
+		expected := `"A","Y","N"`
 		if string(content) != expected {
 			t.Errorf("got %s, expected %s", content, expected)
 		}
 	})
 }
 
-func TestClear(t *testing.T) {
-	cr := cryptoMock{}
-	store := NewStoreMock()
-	d := decrypt.New(cr, store)
+func TestStopNotBefore(t *testing.T) {
+	votes := [][]byte{[]byte(`enc:"Y"`)}
 
-	if err := d.Clear(context.Background(), "test/1"); err != nil {
-		t.Fatalf("clear: %v", err)
+	t.Run("before", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithNotBefore(time.Now().Add(time.Hour))); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		_, _, err := d.Stop(context.Background(), "test/1", votes)
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("stop returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("after", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		notBefore := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithNotBefore(notBefore)); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		content, _, err := d.Stop(context.Background(), "test/1", votes)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		expected := `{"id":"test/1","votes":["Y"],"not_before":"2020-01-02T03:04:05Z"}`
+		if string(content) != expected {
+			t.Errorf("got %s, expected %s", content, expected)
+		}
+
+		status, err := d.Status(context.Background(), "test/1")
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+
+		if !status.NotBefore.Equal(notBefore) {
+			t.Errorf("status has not before %s, expected %s", status.NotBefore, notBefore)
+		}
+	})
+
+	t.Run("with clock", func(t *testing.T) {
+		notBefore := time.Now().Add(-time.Hour)
+		clock := func() time.Time { return notBefore.Add(-time.Minute) }
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithClock(clock))
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithNotBefore(notBefore)); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		_, _, err := d.Stop(context.Background(), "test/1", votes)
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("stop returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+}
+
+func TestStopSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("creating stop key: %v", err)
+	}
+
+	votes := [][]byte{
+		[]byte(`enc:"Y"`),
+		[]byte(`enc:"N"`),
+	}
+
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithStopKey(publicKey))
+	if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	t.Run("without signature", func(t *testing.T) {
+		_, _, err := d.Stop(context.Background(), "test/1", votes)
+		if !errors.Is(err, errorcode.Forbidden) {
+			t.Errorf("stop returned `%v`, expected `%v`", err, errorcode.Forbidden)
+		}
+	})
+
+	t.Run("signature for other votes", func(t *testing.T) {
+		signature := ed25519.Sign(privateKey, decrypt.StopRequestMessage("test/1", votes[:1], nil))
+
+		_, _, err := d.Stop(context.Background(), "test/1", votes, decrypt.WithRequestSignature(signature))
+		if !errors.Is(err, errorcode.Forbidden) {
+			t.Errorf("stop returned `%v`, expected `%v`", err, errorcode.Forbidden)
+		}
+	})
+
+	t.Run("valid signature", func(t *testing.T) {
+		signature := ed25519.Sign(privateKey, decrypt.StopRequestMessage("test/1", votes, nil))
+
+		if _, _, err := d.Stop(context.Background(), "test/1", votes, decrypt.WithRequestSignature(signature)); err != nil {
+			t.Errorf("stop: %v", err)
+		}
+	})
+}
+
+func TestPartialDecrypt(t *testing.T) {
+	votes := [][]byte{
+		[]byte(`enc:"Y"`),
+		[]byte(`invalid`),
+	}
+
+	t.Run("trustee poll", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithFeatures(decrypt.FeatureTrustee))
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithTrustee()); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		status, err := d.Status(context.Background(), "test/1")
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+
+		if fmt.Sprint(status.Features) != "[trustee]" {
+			t.Errorf("got features %v, expected [trustee]", status.Features)
+		}
+
+		shares, err := d.PartialDecrypt(context.Background(), "test/1", votes)
+		if err != nil {
+			t.Fatalf("PartialDecrypt: %v", err)
+		}
+
+		expected := []decrypt.DecryptionShare{
+			{Share: []byte(`share:enc:"Y"`), Proof: []byte("proof")},
+			{Invalid: "invalid vote"},
+		}
+		if fmt.Sprint(shares) != fmt.Sprint(expected) {
+			t.Errorf("got shares %v, expected %v", shares, expected)
+		}
+
+		if _, err := d.PartialDecrypt(context.Background(), "test/1", votes); err != nil {
+			t.Errorf("second PartialDecrypt with the same votes: %v", err)
+		}
+
+		if _, err := d.PartialDecrypt(context.Background(), "test/1", votes[:1]); err == nil {
+			t.Errorf("PartialDecrypt with other votes did not fail")
+		}
+
+		if _, _, err := d.Stop(context.Background(), "test/1", votes); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("stop of a trustee poll returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("feature disabled", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithTrustee()); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("start returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+
+	t.Run("normal poll", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, err := d.PartialDecrypt(context.Background(), "test/1", votes); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("PartialDecrypt returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("unsupported crypto", func(t *testing.T) {
+		d := decrypt.New(struct{ decrypt.Crypto }{cryptoMock{}}, NewStoreMock(), decrypt.WithFeatures(decrypt.FeatureTrustee))
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithTrustee()); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("start returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+}
+
+func TestTokens(t *testing.T) {
+	t.Run("token poll", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithTokens()); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		issued, err := d.IssueToken(context.Background(), "test/1", []byte("blinded"))
+		if err != nil {
+			t.Fatalf("IssueToken: %v", err)
+		}
+
+		expected := decrypt.IssuedToken{
+			Evaluated:    []byte("token:blinded"),
+			Proof:        []byte("proof"),
+			PublicKey:    []byte("tokenPubKey"),
+			PublicKeySig: []byte("tokenKeySig"),
+		}
+		if fmt.Sprint(issued) != fmt.Sprint(expected) {
+			t.Errorf("got token %v, expected %v", issued, expected)
+		}
+
+		votes := [][]byte{
+			[]byte(`enc:tk01"Y"`),
+			[]byte(`enc:tk02"N"`),
+			[]byte(`enc:tk01"N"`),
+			[]byte(`enc:xx03"Y"`),
+			[]byte(`enc:tk`),
+		}
+		content, _, err := d.Stop(context.Background(), "test/1", votes)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		result, err := decrypt.ParseResult(content)
+		if err != nil {
+			t.Fatalf("parsing result: %v", err)
+		}
+
+		if got := fmt.Sprintf("%s", result.Votes); got != `["N"]` {
+			t.Errorf("got votes %s, expected [\"N\"]", got)
+		}
+
+		if result.Invalid["token used twice"] != 2 || result.Invalid["invalid token"] != 2 {
+			t.Errorf("got invalid votes %v", result.Invalid)
+		}
+	})
+
+	t.Run("normal poll", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, err := d.IssueToken(context.Background(), "test/1", []byte("blinded")); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("IssueToken returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("unsupported crypto", func(t *testing.T) {
+		d := decrypt.New(struct{ decrypt.Crypto }{cryptoMock{}}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithTokens()); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("start returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+}
+
+func TestRing(t *testing.T) {
+	ring := decrypt.WithRing([][]byte{[]byte("a"), []byte("b"), []byte("c")})
+
+	t.Run("ring poll", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1", ring); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		votes := [][]byte{
+			[]byte(`ring:a:enc:"Y"`),
+			[]byte(`ring:b:enc:"N"`),
+			[]byte(`ring:b:enc:"Y"`),
+			[]byte(`ring:d:enc:"Y"`),
+			[]byte(`enc:"Y"`),
+		}
+		content, _, err := d.Stop(context.Background(), "test/1", votes)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		result, err := decrypt.ParseResult(content)
+		if err != nil {
+			t.Fatalf("parsing result: %v", err)
+		}
+
+		if got := fmt.Sprintf("%s", result.Votes); got != `["Y"]` {
+			t.Errorf("got votes %s, expected [\"Y\"]", got)
+		}
+
+		if result.Invalid["signer voted twice"] != 2 || result.Invalid["invalid ring signature"] != 2 {
+			t.Errorf("got invalid votes %v", result.Invalid)
+		}
+	})
+
+	t.Run("trustee poll", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithFeatures(decrypt.FeatureTrustee))
+		if _, _, err := d.Start(context.Background(), "test/1", ring, decrypt.WithTrustee()); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("start returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("unsupported crypto", func(t *testing.T) {
+		d := decrypt.New(struct{ decrypt.Crypto }{cryptoMock{}}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1", ring); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("start returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+}
+
+func TestRevote(t *testing.T) {
+	votes := [][]byte{
+		[]byte(`enc:"Y"`),
+		[]byte(`enc:"N"`),
+		[]byte(`enc:"A"`),
+		[]byte(`invalid`),
+		[]byte(`enc:"Y"`),
+	}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tags := decrypt.WithTags([]decrypt.VoteTag{
+		{Pseudonym: "alice", Time: start.Add(2 * time.Minute)},
+		{Pseudonym: "alice", Time: start.Add(time.Minute)},
+		{Pseudonym: "bob", Time: start},
+		{Pseudonym: "bob", Time: start.Add(time.Minute)},
+		{Pseudonym: "carol", Time: start},
+	})
+
+	for _, tt := range []struct {
+		name       string
+		policy     decrypt.RevotePolicy
+		votes      string
+		invalid    map[string]int
+		superseded int
+	}{
+		{
+			"last",
+			decrypt.RevoteLast,
+			`["A" "Y" "Y"]`,
+			nil,
+			2,
+		},
+		{
+			"invalid",
+			decrypt.RevoteInvalid,
+			`["Y"]`,
+			map[string]int{"voted twice": 4},
+			0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := decrypt.New(cryptoMock{}, NewStoreMock())
+			if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithRevotePolicy(tt.policy)); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+
+			content, _, err := d.Stop(context.Background(), "test/1", votes, tags)
+			if err != nil {
+				t.Fatalf("stop: %v", err)
+			}
+
+			result, err := decrypt.ParseResult(content)
+			if err != nil {
+				t.Fatalf("parsing result: %v", err)
+			}
+
+			got := make([]string, len(result.Votes))
+			for i, vote := range result.Votes {
+				got[i] = string(vote)
+			}
+			sort.Strings(got)
+			if fmt.Sprintf("%s", got) != tt.votes {
+				t.Errorf("got votes %s, expected %s", got, tt.votes)
+			}
+
+			if fmt.Sprint(result.Invalid) != fmt.Sprint(tt.invalid) {
+				t.Errorf("got invalid votes %v, expected %v", result.Invalid, tt.invalid)
+			}
+
+			if result.Superseded != tt.superseded {
+				t.Errorf("got %d superseded votes, expected %d", result.Superseded, tt.superseded)
+			}
+		})
+	}
+
+	t.Run("unlinked pseudonyms", func(t *testing.T) {
+		stop := func(pseudonyms ...string) ([]byte, *auditLogMock, *StoreMock) {
+			renamed := make([]decrypt.VoteTag, len(pseudonyms))
+			for i, pseudonym := range pseudonyms {
+				renamed[i] = decrypt.VoteTag{Pseudonym: pseudonym, Time: start.Add(time.Duration(i) * time.Minute)}
+			}
+
+			auditLog := new(auditLogMock)
+			store := NewStoreMock()
+			d := decrypt.New(cryptoMock{}, store, decrypt.WithAuditLog(auditLog))
+			if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithRevotePolicy(decrypt.RevoteLast)); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+
+			content, _, err := d.Stop(context.Background(), "test/1", votes, decrypt.WithTags(renamed))
+			if err != nil {
+				t.Fatalf("stop: %v", err)
+			}
+			return content, auditLog, store
+		}
+
+		content, auditLog, store := stop("alice", "alice", "bob", "bob", "carol")
+		other, _, _ := stop("dave", "dave", "erin", "erin", "frank")
+		if string(content) != string(other) {
+			t.Errorf("result depends on the pseudonyms:\n%s\n%s", content, other)
+		}
+
+		var stored [][]byte
+		stored = append(stored, content)
+		for _, entry := range auditLog.entries {
+			stored = append(stored, []byte(entry.message))
+		}
+		for _, value := range store.metas {
+			stored = append(stored, value)
+		}
+		for _, value := range store.signatures {
+			stored = append(stored, value)
+		}
+
+		for _, value := range stored {
+			for _, pseudonym := range []string{"alice", "bob", "carol"} {
+				if bytes.Contains(value, []byte(pseudonym)) {
+					t.Errorf("pseudonym %s found in %s", pseudonym, value)
+				}
+			}
+		}
+	})
+
+	t.Run("missing tags", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithRevotePolicy(decrypt.RevoteLast)); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "test/1", votes[:2], tags); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("stop returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+}
+
+func TestPollSigningKeys(t *testing.T) {
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithPollSigningKeys())
+	pubKey, pubKeySig, err := d.Start(context.Background(), "test/1")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if expected := "pollsig:test/1:" + string(pubKey); string(pubKeySig) != expected {
+		t.Errorf("got public key signature %s, expected %s", pubKeySig, expected)
+	}
+
+	content, signature, err := d.Stop(context.Background(), "test/1", [][]byte{[]byte(`enc:"Y"`)})
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	if expected := "pollsig:test/1:" + string(content); string(signature) != expected {
+		t.Errorf("got result signature %s, expected %s", signature, expected)
+	}
+
+	signingKey, delegation, err := d.PollSigningKey(context.Background(), "test/1")
+	if err != nil {
+		t.Fatalf("PollSigningKey: %v", err)
+	}
+
+	if string(signingKey) != "pollSigningKey:test/1" || string(delegation) != "sig:pollSigningKey:test/1" {
+		t.Errorf("got poll signing key %s with delegation %s", signingKey, delegation)
+	}
+
+	t.Run("without poll signing keys", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, _, err := d.PollSigningKey(context.Background(), "test/1"); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("PollSigningKey returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+
+	t.Run("unsupported crypto", func(t *testing.T) {
+		d := decrypt.New(struct{ decrypt.Crypto }{cryptoMock{}}, NewStoreMock(), decrypt.WithPollSigningKeys())
+		if _, _, err := d.Start(context.Background(), "test/1"); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("start returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+}
+
+func TestMeetingSigningKeys(t *testing.T) {
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithMeetingSigningKeys([]byte("secret")), decrypt.WithPollSigningKeys())
+
+	pubKey, pubKeySig, err := d.Start(context.Background(), "meeting1/1")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if expected := "meetingsig:meeting1:" + string(pubKey); string(pubKeySig) != expected {
+		t.Errorf("got public key signature %s, expected %s", pubKeySig, expected)
+	}
+
+	content, signature, err := d.Stop(context.Background(), "meeting1/1", [][]byte{[]byte(`enc:"Y"`)})
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	if expected := "meetingsig:meeting1:" + string(content); string(signature) != expected {
+		t.Errorf("got result signature %s, expected %s", signature, expected)
+	}
+
+	if _, _, err := d.PollSigningKey(context.Background(), "meeting1/1"); !errors.Is(err, errorcode.Unsupported) {
+		t.Errorf("PollSigningKey returned `%v`, expected `%v`", err, errorcode.Unsupported)
+	}
+
+	t.Run("status", func(t *testing.T) {
+		if _, _, err := d.Start(context.Background(), "meeting1/2"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		status, err := d.Status(context.Background(), "meeting1/2")
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+
+		if status.Meeting != "meeting1" || string(status.MeetingSigningKey) != "meetingSigningKey:meeting1" || string(status.MeetingDelegation) != "sig:meetingSigningKey:meeting1" {
+			t.Errorf("got status meeting %q with key %s and delegation %s", status.Meeting, status.MeetingSigningKey, status.MeetingDelegation)
+		}
+	})
+
+	t.Run("poll without meeting", func(t *testing.T) {
+		_, pubKeySig, err := d.Start(context.Background(), "2")
+		if err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if !strings.HasPrefix(string(pubKeySig), "pollsig:2:") {
+			t.Errorf("got public key signature %s, expected the poll signing key", pubKeySig)
+		}
+	})
+
+	t.Run("invalid meeting", func(t *testing.T) {
+		if _, _, err := d.MeetingSigningKey(context.Background(), "meeting1/1"); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("MeetingSigningKey returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("without meeting signing keys", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.MeetingSigningKey(context.Background(), "meeting1"); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("MeetingSigningKey returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+}
+
+func TestReplay(t *testing.T) {
+	d := decrypt.New(cryptoMock{}, NewStoreMock())
+	startConfig := decrypt.StartConfig{Metadata: []byte("meta")}
+	if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithMetadata(startConfig.Metadata)); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	votes := [][]byte{[]byte(`enc:"Y"`), []byte(`enc:"N"`), []byte(`enc:"A"`)}
+	weights := decrypt.WithWeights([]string{"1", "2", "3"})
+	content, _, err := d.Stop(context.Background(), "test/1", votes, weights)
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	t.Run("same votes", func(t *testing.T) {
+		replayed, err := decrypt.New(cryptoMock{}, nil).Replay(context.Background(), []byte("pollKey"), "test/1", votes, startConfig, weights)
+		if err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+
+		if string(replayed) != string(content) {
+			t.Errorf("replay returned %s, expected %s", replayed, content)
+		}
+	})
+
+	t.Run("other votes", func(t *testing.T) {
+		otherVotes := [][]byte{[]byte(`enc:"Y"`), []byte(`enc:"Y"`), []byte(`enc:"A"`)}
+		replayed, err := decrypt.New(cryptoMock{}, nil).Replay(context.Background(), []byte("pollKey"), "test/1", otherVotes, startConfig, weights)
+		if err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+
+		if string(replayed) == string(content) {
+			t.Errorf("replay with other votes returned the same content")
+		}
+	})
+}
+
+func TestCoSign(t *testing.T) {
+	store := NewStoreMock()
+	primary := decrypt.New(cryptoMock{}, store)
+	if _, _, err := primary.Start(context.Background(), "test/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	votes := [][]byte{[]byte(`enc:"Y"`), []byte(`enc:"N"`)}
+	content, _, err := primary.Stop(context.Background(), "test/1", votes)
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	// The co-signer is a standby with the same store.
+	_, key, _ := ed25519.GenerateKey(nil)
+	cosigner := decrypt.New(cryptoMock{}, store, decrypt.WithCoSigningKey(key))
+	if err := cosigner.SetReadOnly(context.Background(), true); err != nil {
+		t.Fatalf("set read only: %v", err)
+	}
+
+	cosignature, err := cosigner.CoSign(context.Background(), "test/1", votes, content)
+	if err != nil {
+		t.Fatalf("co-sign: %v", err)
+	}
+
+	if !decrypt.VerifyCoSignature("test/1", content, cosignature) {
+		t.Errorf("invalid co-signature")
+	}
+
+	if decrypt.VerifyCoSignature("test/2", content, cosignature) {
+		t.Errorf("co-signature is valid for another poll")
+	}
+
+	t.Run("other content", func(t *testing.T) {
+		other := []byte(strings.Replace(string(content), "Y", "N", 1))
+		if _, err := cosigner.CoSign(context.Background(), "test/1", votes, other); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("CoSign returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("unknown poll", func(t *testing.T) {
+		if _, err := cosigner.CoSign(context.Background(), "test/2", votes, content); !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("CoSign returned `%v`, expected `%v`", err, errorcode.NotExist)
+		}
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		if _, err := primary.CoSign(context.Background(), "test/1", votes, content); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("CoSign returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+}
+
+type resultWriterMock struct {
+	votes     []string
+	weights   []string
+	signature []byte
+	fail      bool
+}
+
+func (w *resultWriterMock) WriteVote(pollID string, vote []byte, weight string) error {
+	if w.fail {
+		return errors.New("writer failed")
+	}
+	w.votes = append(w.votes, string(vote))
+	w.weights = append(w.weights, weight)
+	return nil
+}
+
+func (w *resultWriterMock) Finish(pollID string, content, signature []byte) error {
+	w.signature = signature
+	return nil
+}
+
+func TestResultWriter(t *testing.T) {
+	t.Run("receives the votes", func(t *testing.T) {
+		writer := new(resultWriterMock)
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithResultWriter(writer))
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		votes := [][]byte{[]byte(`enc:"Y"`), []byte(`enc:"N"`), []byte(`enc:"A"`)}
+		content, signature, err := d.Stop(context.Background(), "test/1", votes, decrypt.WithWeights([]string{"1", "2", "3"}))
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		result, err := decrypt.ParseResult(content)
+		if err != nil {
+			t.Fatalf("ParseResult: %v", err)
+		}
+
+		if len(writer.votes) != len(result.Votes) {
+			t.Fatalf("writer got %d votes, expected %d", len(writer.votes), len(result.Votes))
+		}
+
+		for i, vote := range result.Votes {
+			if writer.votes[i] != string(vote) || writer.weights[i] != result.Weights[i] {
+				t.Errorf("writer got vote %d as %s with weight %s, expected %s with weight %s", i, writer.votes[i], writer.weights[i], vote, result.Weights[i])
+			}
+		}
+
+		if !bytes.Equal(writer.signature, signature) {
+			t.Errorf("writer got signature %s, expected %s", writer.signature, signature)
+		}
+	})
+
+	t.Run("different votes", func(t *testing.T) {
+		writer := new(resultWriterMock)
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithResultWriter(writer))
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "test/1", [][]byte{[]byte(`enc:"Y"`)}); err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+		writer.votes = nil
+
+		if _, _, err := d.Stop(context.Background(), "test/1", [][]byte{[]byte(`enc:"N"`)}); err == nil {
+			t.Fatalf("second stop with other votes did not fail")
+		}
+
+		if len(writer.votes) != 0 {
+			t.Errorf("writer got votes %v from a failed stop", writer.votes)
+		}
+	})
+
+	t.Run("failing writer", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithResultWriter(&resultWriterMock{fail: true}))
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "test/1", [][]byte{[]byte(`enc:"Y"`)}); err == nil {
+			t.Errorf("stop with a failing writer did not fail")
+		}
+	})
+}
+
+func TestStopMany(t *testing.T) {
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithDecryptWorkers(2))
+	for _, id := range []string{"poll/1", "poll/2"} {
+		if _, _, err := d.Start(context.Background(), id); err != nil {
+			t.Fatalf("start %s: %v", id, err)
+		}
+	}
+
+	polls := []decrypt.ElectionPoll{
+		{ID: "poll/1", Votes: [][]byte{[]byte(`enc:"Y"`), []byte(`enc:"N"`)}},
+		{ID: "poll/2", Votes: [][]byte{[]byte(`enc:"A"`)}, Options: []decrypt.StopOption{decrypt.WithWeights([]string{"3"})}},
+		{ID: "poll/3", Votes: [][]byte{[]byte(`enc:"Y"`)}},
+	}
+
+	progress := make(map[string]int)
+	results, err := d.StopMany(context.Background(), polls, func(p decrypt.Progress) {
+		if p.Decrypted > p.Total {
+			t.Errorf("poll %s has %d of %d decrypted votes", p.PollID, p.Decrypted, p.Total)
+		}
+		progress[p.PollID] = p.Decrypted
+	})
+	if err != nil {
+		t.Fatalf("StopMany: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, expected 3", len(results))
+	}
+
+	if progress["poll/1"] != 2 || progress["poll/2"] != 1 {
+		t.Errorf("got progress %v", progress)
+	}
+
+	for i, poll := range polls[:2] {
+		if results[i].ID != poll.ID || results[i].Err != nil {
+			t.Fatalf("result %d is for %s with error %v", i, results[i].ID, results[i].Err)
+		}
+
+		content, signature, err := d.Stop(context.Background(), poll.ID, poll.Votes, poll.Options...)
+		if err != nil {
+			t.Fatalf("stop %s: %v", poll.ID, err)
+		}
+
+		if string(results[i].Content) != string(content) || string(results[i].Signature) != string(signature) {
+			t.Errorf("result of %s is %s, Stop() returned %s", poll.ID, results[i].Content, content)
+		}
+	}
+
+	if !errors.Is(results[2].Err, errorcode.NotExist) {
+		t.Errorf("result of the unknown poll has error %v, expected %v", results[2].Err, errorcode.NotExist)
+	}
+
+	t.Run("duplicate poll", func(t *testing.T) {
+		_, err := d.StopMany(context.Background(), []decrypt.ElectionPoll{polls[0], polls[0]}, nil)
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("StopMany with a duplicate poll returned %v, expected %v", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		dir := t.TempDir()
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithSpill(dir, 50))
+		for _, id := range []string{"poll/1", "poll/2"} {
+			if _, _, err := d.Start(context.Background(), id); err != nil {
+				t.Fatalf("start %s: %v", id, err)
+			}
+		}
+
+		bigVotes := make([][]byte, 20)
+		for i := range bigVotes {
+			bigVotes[i] = []byte(`enc:"Y"`)
+		}
+		polls := []decrypt.ElectionPoll{
+			{ID: "poll/1", Votes: bigVotes},
+			{ID: "poll/2", Votes: [][]byte{[]byte(`enc:"A"`)}},
+		}
+
+		results, err := d.StopMany(context.Background(), polls, nil)
+		if err != nil {
+			t.Fatalf("StopMany: %v", err)
+		}
+
+		if results[0].Spilled == nil || results[0].Content != nil {
+			t.Fatalf("big result was not spilled")
+		}
+		defer results[0].Spilled.Close()
+
+		if results[1].Spilled != nil {
+			t.Errorf("small result was spilled")
+		}
+
+		spilled, err := results[0].Spilled.Bytes()
+		if err != nil {
+			t.Fatalf("reading spilled result: %v", err)
+		}
+
+		content, _, err := d.Stop(context.Background(), "poll/1", bigVotes)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		if string(spilled) != string(content) {
+			t.Errorf("spilled result is %s, Stop() returned %s", spilled, content)
+		}
+	})
+}
+
+func TestAutoTune(t *testing.T) {
+	votes := make([][]byte, 1000)
+	for i := range votes {
+		votes[i] = []byte(fmt.Sprintf(`enc:"%d"`, i))
+	}
+
+	expected, err := decrypt.New(cryptoMock{}, nil, decrypt.WithDecryptWorkers(1)).Replay(context.Background(), []byte("pollKey"), "test/1", votes, decrypt.StartConfig{})
+	if err != nil {
+		t.Fatalf("replay without tuning: %v", err)
+	}
+
+	d := decrypt.New(cryptoMock{}, nil, decrypt.WithDecryptWorkers(4), decrypt.WithAutoTune())
+
+	// The first run uses the estimate, the second the measured cost.
+	for run := 1; run <= 2; run++ {
+		content, err := d.Replay(context.Background(), []byte("pollKey"), "test/1", votes, decrypt.StartConfig{})
+		if err != nil {
+			t.Fatalf("replay %d: %v", run, err)
+		}
+
+		if string(content) != string(expected) {
+			t.Errorf("run %d with tuning returned another result", run)
+		}
+	}
+
+	var buf bytes.Buffer
+	metrics.Write(&buf)
+	if !strings.Contains(buf.String(), "vote_decrypt_decrypt_vote_cost_seconds ") {
+		t.Errorf("vote cost was not measured")
+	}
+}
+
+func TestSmallVotes(t *testing.T) {
+	votes := [][]byte{
+		[]byte(`enc:"Y"`),
+		[]byte(`invalid`),
+		append([]byte(`enc:"`), append(bytes.Repeat([]byte("x"), 2000), '"')...),
+		[]byte(`enc:"N"`),
+	}
+
+	expected, err := decrypt.New(cryptoMock{}, nil).Replay(context.Background(), []byte("pollKey"), "test/1", votes, decrypt.StartConfig{})
+	if err != nil {
+		t.Fatalf("replay with DecryptPoll: %v", err)
+	}
+
+	content, err := decrypt.New(pollDecrypterMock{}, nil).Replay(context.Background(), []byte("pollKey"), "test/1", votes, decrypt.StartConfig{})
+	if err != nil {
+		t.Fatalf("replay with PollDecrypter: %v", err)
+	}
+
+	if string(content) != string(expected) {
+		t.Errorf("got %s, expected %s", content, expected)
+	}
+
+	t.Run("allocations", func(t *testing.T) {
+		allocs := func(count int) float64 {
+			votes := make([][]byte, count)
+			for i := range votes {
+				votes[i] = []byte(fmt.Sprintf(`enc:"%d"`, i))
+			}
+
+			d := decrypt.New(pollDecrypterMock{}, nil, decrypt.WithDecryptWorkers(1))
+			return testing.AllocsPerRun(10, func() {
+				d.Replay(context.Background(), []byte("pollKey"), "test/1", votes, decrypt.StartConfig{})
+			})
+		}
+
+		// Growing the result may allocate a few times, but not per vote.
+		if few, many := allocs(100), allocs(400); many > few+5 {
+			t.Errorf("got %.0f allocations for 100 votes and %.0f for 400 votes", few, many)
+		}
+	})
+}
+
+func TestBudget(t *testing.T) {
+	t.Run("parallel stops", func(t *testing.T) {
+		running := make(chan struct{})
+		unblock := make(chan struct{})
+		blockFilter := func(vote []byte) error {
+			if string(vote) == `enc:"block"` {
+				close(running)
+				<-unblock
+			}
+			return nil
+		}
+
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithMaxParallelStops(1), decrypt.WithVoteFilter(blockFilter))
+		for _, id := range []string{"poll/1", "poll/2"} {
+			if _, _, err := d.Start(context.Background(), id); err != nil {
+				t.Fatalf("start %s: %v", id, err)
+			}
+		}
+
+		done := make(chan error)
+		go func() {
+			_, _, err := d.Stop(context.Background(), "poll/1", [][]byte{[]byte(`enc:"block"`)})
+			done <- err
+		}()
+		<-running
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, _, err := d.Stop(ctx, "poll/2", [][]byte{[]byte(`enc:"Y"`)}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("second stop returned %v, expected %v", err, context.DeadlineExceeded)
+		}
+
+		close(unblock)
+		if err := <-done; err != nil {
+			t.Fatalf("first stop: %v", err)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "poll/2", [][]byte{[]byte(`enc:"Y"`)}); err != nil {
+			t.Errorf("second stop after the first: %v", err)
+		}
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithMemoryBudget(1000))
+		if _, _, err := d.Start(context.Background(), "poll/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "poll/1", [][]byte{bytes.Repeat([]byte("x"), 1000)}); !errors.Is(err, errorcode.Limit) {
+			t.Errorf("stop of a big poll returned %v, expected %v", err, errorcode.Limit)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "poll/1", [][]byte{[]byte(`enc:"Y"`)}); err != nil {
+			t.Errorf("stop of a small poll: %v", err)
+		}
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		running := make(chan struct{})
+		unblock := make(chan struct{})
+		var mu sync.Mutex
+		var order []string
+		filter := func(vote []byte) error {
+			if string(vote) == `enc:"block"` {
+				close(running)
+				<-unblock
+				return nil
+			}
+
+			mu.Lock()
+			order = append(order, string(vote))
+			mu.Unlock()
+			return nil
+		}
+
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithMaxParallelStops(1), decrypt.WithVoteFilter(filter))
+		for _, id := range []string{"poll/1", "poll/2", "poll/3", "poll/4"} {
+			if _, _, err := d.Start(context.Background(), id); err != nil {
+				t.Fatalf("start %s: %v", id, err)
+			}
+		}
+
+		var wg sync.WaitGroup
+		stop := func(pollID, vote string, priority int) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := d.Stop(context.Background(), pollID, [][]byte{[]byte(vote)}, decrypt.WithPriority(priority)); err != nil {
+					t.Errorf("stop %s: %v", pollID, err)
+				}
+			}()
+		}
+
+		stop("poll/1", `enc:"block"`, 0)
+		<-running
+
+		stop("poll/2", `enc:"test"`, -1)
+		waitQueueDepth(t, "-1", 1)
+		stop("poll/3", `enc:"normal"`, 0)
+		waitQueueDepth(t, "0", 1)
+		stop("poll/4", `enc:"main"`, 5)
+		waitQueueDepth(t, "5", 1)
+
+		close(unblock)
+		wg.Wait()
+
+		expect := []string{`enc:"main"`, `enc:"normal"`, `enc:"test"`}
+		if strings.Join(order, " ") != strings.Join(expect, " ") {
+			t.Errorf("polls were decrypted in order %v, expected %v", order, expect)
+		}
+	})
+
+	t.Run("invalid priority", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "poll/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "poll/1", nil, decrypt.WithPriority(decrypt.MaxPriority+1)); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("stop returned %v, expected %v", err, errorcode.Invalid)
+		}
+	})
+}
+
+// waitQueueDepth waits until the metric of waiting stop calls with the priority
+// has the value.
+func waitQueueDepth(t *testing.T, priority string, depth int) {
+	t.Helper()
+
+	line := fmt.Sprintf("vote_decrypt_stop_queue_depth{priority=%q} %d\n", priority, depth)
+	for i := 0; i < 500; i++ {
+		var buf bytes.Buffer
+		metrics.Write(&buf)
+		if strings.Contains(buf.String(), line) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("queue depth of priority %s did not become %d", priority, depth)
+}
+
+func TestParseResult(t *testing.T) {
+	d := decrypt.New(cryptoMock{}, NewStoreMock())
+	if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithMetadata([]byte("meta"))); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	votes := [][]byte{[]byte(`enc:"Y"`), []byte(`enc:"N"`), []byte(`enc:"A"`)}
+	content, _, err := d.Stop(context.Background(), "test/1", votes, decrypt.WithWeights([]string{"1", "2.5", "3"}))
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	result, err := decrypt.ParseResult(content)
+	if err != nil {
+		t.Fatalf("ParseResult: %v", err)
+	}
+
+	if result.ID != "test/1" || len(result.Votes) != 3 || string(result.Metadata) != "meta" {
+		t.Errorf("got result %v, expected the values of the poll", result)
+	}
+
+	if got := strings.Join(result.Weights, ","); got != "3,1,2.5" {
+		t.Errorf("got weights %s, expected 3,1,2.5", got)
+	}
+
+	for _, content := range []string{
+		`{"id":"test/1","votes":["Y"],"weights":[1,2]}`,
+		`{"id":"test/1","votes":["Y"],"unknown":1}`,
+		`not json`,
+	} {
+		if _, err := decrypt.ParseResult([]byte(content)); err == nil {
+			t.Errorf("ParseResult(%s) did not fail", content)
+		}
+	}
+}
+
+func TestCommitment(t *testing.T) {
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithCommitment())
+	if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	votes := [][]byte{[]byte(`enc:"Y"`), []byte(`enc:"N"`), []byte(`enc:"A"`)}
+	content, _, err := d.Stop(context.Background(), "test/1", votes)
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	result, err := decrypt.ParseResult(content)
+	if err != nil {
+		t.Fatalf("ParseResult: %v", err)
+	}
+
+	if len(result.CiphertextRoot) == 0 {
+		t.Fatalf("result has no ciphertext root: %s", content)
+	}
+
+	t.Run("proof for each vote", func(t *testing.T) {
+		for _, vote := range votes {
+			trackingCode := decrypt.TrackingCode(vote)
+			proof, err := d.InclusionProof(context.Background(), "test/1", trackingCode)
+			if err != nil {
+				t.Fatalf("InclusionProof(%s): %v", vote, err)
+			}
+
+			if proof.Size != len(votes) {
+				t.Errorf("proof has size %d, expected %d", proof.Size, len(votes))
+			}
+
+			if !proof.Verify(result.CiphertextRoot, trackingCode) {
+				t.Errorf("proof for vote %s is invalid", vote)
+			}
+		}
+	})
+
+	t.Run("unknown tracking code", func(t *testing.T) {
+		_, err := d.InclusionProof(context.Background(), "test/1", decrypt.TrackingCode([]byte(`enc:"X"`)))
+		if !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("got error `%v`, expected NotExist", err)
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		replayed, err := decrypt.New(cryptoMock{}, nil, decrypt.WithCommitment()).Replay(context.Background(), []byte("pollKey"), "test/1", votes, decrypt.StartConfig{})
+		if err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+
+		if string(replayed) != string(content) {
+			t.Errorf("replay returned %s, expected %s", replayed, content)
+		}
+	})
+
+	t.Run("large poll", func(t *testing.T) {
+		votes := make([][]byte, 10000)
+		leaves := make([][]byte, len(votes))
+		for i := range votes {
+			votes[i] = []byte(fmt.Sprintf(`enc:"%d"`, i))
+			leaves[i] = decrypt.TrackingCode(votes[i])
+		}
+		sort.Slice(leaves, func(i, j int) bool { return bytes.Compare(leaves[i], leaves[j]) < 0 })
+
+		content, err := decrypt.New(cryptoMock{}, nil, decrypt.WithCommitment(), decrypt.WithDecryptWorkers(4)).Replay(context.Background(), []byte("pollKey"), "test/2", votes, decrypt.StartConfig{})
+		if err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+
+		result, err := decrypt.ParseResult(content)
+		if err != nil {
+			t.Fatalf("ParseResult: %v", err)
+		}
+
+		if !bytes.Equal(result.CiphertextRoot, merkle.Root(leaves)) {
+			t.Errorf("ciphertext root is not the root of the sorted tracking codes")
+		}
+	})
+
+	t.Run("without commitment", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		content, _, err := d.Stop(context.Background(), "test/1", votes)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		if bytes.Contains(content, []byte("ciphertext_root")) {
+			t.Errorf("content without commitment contains a root: %s", content)
+		}
+
+		if _, err := d.InclusionProof(context.Background(), "test/1", decrypt.TrackingCode(votes[0])); !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("got error `%v`, expected NotExist", err)
+		}
+	})
+}
+
+func TestNoDecryption(t *testing.T) {
+	auditLog := new(auditLogMock)
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithAuditLog(auditLog))
+	if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	t.Run("running poll", func(t *testing.T) {
+		content, signature, err := d.NoDecryption(context.Background(), "test/1")
+		if err != nil {
+			t.Fatalf("NoDecryption: %v", err)
+		}
+
+		if string(signature) != "sig:"+string(content) {
+			t.Errorf("got signature %s, expected signature over the certificate", signature)
+		}
+
+		var certificate decrypt.NoDecryptionCertificate
+		if err := json.Unmarshal(content, &certificate); err != nil {
+			t.Fatalf("decoding certificate: %v", err)
+		}
+
+		if certificate.PollID != "test/1" || certificate.Statement != decrypt.NoDecryptionStatement || certificate.Events != 1 {
+			t.Errorf("got certificate %v, expected certificate for test/1 over one event", certificate)
+		}
+
+		if _, ok := auditLog.last("no-decryption"); !ok {
+			t.Errorf("no-decryption event was not written to the audit log")
+		}
+	})
+
+	t.Run("unknown poll", func(t *testing.T) {
+		if _, _, err := d.NoDecryption(context.Background(), "test/2"); !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("got error `%v`, expected NotExist", err)
+		}
+	})
+
+	t.Run("stopped poll", func(t *testing.T) {
+		if _, _, err := d.Stop(context.Background(), "test/1", [][]byte{[]byte(`enc:"Y"`)}); err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		if _, _, err := d.NoDecryption(context.Background(), "test/1"); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("got error `%v`, expected Invalid", err)
+		}
+	})
+
+	t.Run("read only", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithAuditLog(auditLog), decrypt.WithReadOnly(true))
+		if _, _, err := d.NoDecryption(context.Background(), "test/1"); !errors.Is(err, errorcode.ReadOnly) {
+			t.Errorf("got error `%v`, expected ReadOnly", err)
+		}
+	})
+
+	t.Run("audit log can not be read", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.NoDecryption(context.Background(), "test/1"); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("got error `%v`, expected Unsupported", err)
+		}
+	})
+}
+
+func TestValidateID(t *testing.T) {
+	d := decrypt.New(
+		cryptoMock{},
+		NewStoreMock(),
+		decrypt.WithIDValidator(decrypt.IDMaxLength(12)),
+		decrypt.WithIDValidator(decrypt.IDNamespaces("meeting1")),
+		decrypt.WithIDValidator(decrypt.IDPattern(regexp.MustCompile(`^[a-z0-9]+/[0-9]+$`))),
+	)
+
+	if _, _, err := d.Start(context.Background(), "meeting1/42"); err != nil {
+		t.Errorf("start with valid id: %v", err)
+	}
+
+	for _, id := range []string{
+		"",
+		"meeting1/../42",
+		"meeting1//42",
+		"meeting1/4_2",
+		"meeting1/4242424242",
+		"meeting2/42",
+		"meeting1/abc",
+	} {
+		if _, _, err := d.Start(context.Background(), id); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("start with id %q returned `%v`, expected `%v`", id, err, errorcode.Invalid)
+		}
+
+		if _, err := d.Status(context.Background(), id); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("status with id %q returned `%v`, expected `%v`", id, err, errorcode.Invalid)
+		}
+	}
+}
+
+func TestCheckMainKey(t *testing.T) {
+	d := decrypt.New(cryptoMock{}, NewStoreMock())
+
+	fingerprint := decrypt.MainKeyFingerprint([]byte("mainPubKey"))
+	if err := d.CheckMainKey(context.Background(), fingerprint); err != nil {
+		t.Errorf("check with the correct fingerprint: %v", err)
+	}
+
+	other := decrypt.MainKeyFingerprint([]byte("otherPubKey"))
+	if err := d.CheckMainKey(context.Background(), other); !errors.Is(err, errorcode.Invalid) {
+		t.Errorf("check with another fingerprint returned `%v`, expected `%v`", err, errorcode.Invalid)
+	}
+	t.Run("sha256 format", func(t *testing.T) {
+		fingerprint := decrypt.Fingerprint([]byte("mainPubKey"))
+		if !strings.HasPrefix(fingerprint, "SHA256:") || len(fingerprint) != 50 {
+			t.Errorf("got fingerprint %s, expected SHA256: and 43 characters", fingerprint)
+		}
+
+		if err := d.CheckMainKey(context.Background(), fingerprint); err != nil {
+			t.Errorf("check with the correct fingerprint: %v", err)
+		}
+
+		other := decrypt.Fingerprint([]byte("otherPubKey"))
+		if err := d.CheckMainKey(context.Background(), other); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("check with another fingerprint returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+}
+
+func TestExportKey(t *testing.T) {
+	t.Run("not configured", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock())
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		_, err := d.ExportKey(context.Background(), "test/1", "court order 123")
+		if !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("export returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+
+	auditLog := new(auditLogMock)
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithSealer(sealerMock{}), decrypt.WithAuditLog(auditLog))
+	if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	t.Run("without reason", func(t *testing.T) {
+		_, err := d.ExportKey(context.Background(), "test/1", " ")
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("export returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("unknown poll", func(t *testing.T) {
+		_, err := d.ExportKey(context.Background(), "test/2", "court order 123")
+		if !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("export returned `%v`, expected `%v`", err, errorcode.NotExist)
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		sealed, err := d.ExportKey(context.Background(), "test/1", "court order 123")
+		if err != nil {
+			t.Fatalf("export: %v", err)
+		}
+
+		if string(sealed) != "sealed:pollKey" {
+			t.Errorf("got sealed key %s, expected sealed:pollKey", sealed)
+		}
+
+		entry, ok := auditLog.last("export-key")
+		if !ok {
+			t.Fatalf("no export-key event in audit log")
+		}
+
+		if entry.pollID != "test/1" || !strings.Contains(entry.message, "court order 123") {
+			t.Errorf("got audit entry %v, expected poll test/1 with the reason", entry)
+		}
+	})
+}
+
+func TestRekey(t *testing.T) {
+	t.Run("not supported", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, struct{ decrypt.Store }{NewStoreMock()})
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, _, err := d.Rekey(context.Background(), "test/1", "key leaked"); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("rekey returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+
+	t.Run("trustee", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithFeatures(decrypt.FeatureTrustee))
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithTrustee()); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if _, _, err := d.Rekey(context.Background(), "test/1", "key leaked"); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("rekey returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	auditLog := new(auditLogMock)
+	d := decrypt.New(new(keyCryptoMock), NewStoreMock(), decrypt.WithAuditLog(auditLog))
+	if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if _, _, err := d.Rekey(context.Background(), "test/1", " "); !errors.Is(err, errorcode.Invalid) {
+		t.Errorf("rekey without reason returned `%v`, expected `%v`", err, errorcode.Invalid)
+	}
+
+	if _, _, err := d.Rekey(context.Background(), "test/2", "key leaked"); !errors.Is(err, errorcode.NotExist) {
+		t.Errorf("rekey of unknown poll returned `%v`, expected `%v`", err, errorcode.NotExist)
+	}
+
+	pubKey, pubKeySig, err := d.Rekey(context.Background(), "test/1", "key leaked")
+	if err != nil {
+		t.Fatalf("rekey: %v", err)
+	}
+
+	if string(pubKey) != "pub:key2" || string(pubKeySig) != "sig:pub:key2" {
+		t.Errorf("got public key %s with signature %s, expected pub:key2", pubKey, pubKeySig)
+	}
+
+	entry, ok := auditLog.last("rekey")
+	if !ok {
+		t.Fatalf("no rekey event in audit log")
+	}
+
+	if entry.pollID != "test/1" || !strings.Contains(entry.message, "key leaked") || !strings.Contains(entry.message, "previous="+decrypt.Fingerprint([]byte("pub:key1"))) {
+		t.Errorf("got audit entry %v, expected poll test/1 with the previous key and the reason", entry)
+	}
+
+	t.Run("new public key", func(t *testing.T) {
+		pubKey, _, err := d.Start(context.Background(), "test/1")
+		if err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if string(pubKey) != "pub:key2" {
+			t.Errorf("start returned %s, expected pub:key2", pubKey)
+		}
+
+		status, err := d.Status(context.Background(), "test/1")
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+
+		if string(status.PubKey) != "pub:key2" {
+			t.Errorf("status returned %s, expected pub:key2", status.PubKey)
+		}
+	})
+
+	t.Run("mixed votes", func(t *testing.T) {
+		votes := [][]byte{[]byte(`key1:"Y"`), []byte(`key2:"N"`), []byte(`key3:"A"`)}
+		content, _, err := d.Stop(context.Background(), "test/1", votes)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		result, err := decrypt.ParseResult(content)
+		if err != nil {
+			t.Fatalf("parsing result: %v", err)
+		}
+
+		var got []string
+		for _, vote := range result.Votes {
+			got = append(got, string(vote))
+		}
+		sort.Strings(got)
+
+		expected := []string{`"N"`, `"Y"`, `{"error":"encryption not valid"}`}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("got votes %v, expected %v", got, expected)
+		}
+	})
+}
+
+func TestRevoke(t *testing.T) {
+	t.Run("not supported", func(t *testing.T) {
+		d := decrypt.New(cryptoMock{}, struct{ decrypt.Store }{NewStoreMock()})
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if err := d.Revoke(context.Background(), "test/1", "key leaked"); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("revoke returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+
+		list, _, err := d.RevocationList(context.Background())
+		if err != nil {
+			t.Fatalf("RevocationList: %v", err)
+		}
+
+		if string(list) != `{"revocations":[]}` {
+			t.Errorf("got revocation list %s, expected an empty list", list)
+		}
+	})
+
+	auditLog := new(auditLogMock)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithAuditLog(auditLog), decrypt.WithClock(func() time.Time { return now }))
+	for _, id := range []string{"test/1", "test/2"} {
+		if _, _, err := d.Start(context.Background(), id); err != nil {
+			t.Fatalf("start %s: %v", id, err)
+		}
+	}
+
+	if err := d.Revoke(context.Background(), "test/1", " "); !errors.Is(err, errorcode.Invalid) {
+		t.Errorf("revoke without reason returned `%v`, expected `%v`", err, errorcode.Invalid)
+	}
+
+	if err := d.Revoke(context.Background(), "test/3", "key leaked"); !errors.Is(err, errorcode.NotExist) {
+		t.Errorf("revoke of unknown poll returned `%v`, expected `%v`", err, errorcode.NotExist)
+	}
+
+	if err := d.Revoke(context.Background(), "test/1", "key leaked"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	if err := d.Revoke(context.Background(), "test/1", "again"); err != nil {
+		t.Fatalf("second revoke: %v", err)
+	}
+
+	entry, ok := auditLog.last("revoke")
+	if !ok {
+		t.Fatalf("no revoke event in audit log")
+	}
+
+	if entry.pollID != "test/1" || !strings.Contains(entry.message, "key leaked") {
+		t.Errorf("got audit entry %v, expected poll test/1 with the reason", entry)
+	}
+
+	t.Run("revocation list", func(t *testing.T) {
+		list, signature, err := d.RevocationList(context.Background())
+		if err != nil {
+			t.Fatalf("RevocationList: %v", err)
+		}
+
+		expected := `{"revocations":[{"poll_id":"test/1","fingerprint":"` + decrypt.Fingerprint([]byte("pollPubKey")) + `","reason":"key leaked","revoked_at":"2024-05-01T12:00:00Z"}]}`
+		if string(list) != expected {
+			t.Errorf("got revocation list\n%s\nexpected\n%s", list, expected)
+		}
+
+		if string(signature) != "sig:"+string(list) {
+			t.Errorf("got signature %s, expected the list signed with the main key", signature)
+		}
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		if _, _, err := d.Start(context.Background(), "test/1"); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("start returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+
+		if _, err := d.Status(context.Background(), "test/1"); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("status returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "test/1", [][]byte{[]byte(`enc:"Y"`)}); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("stop returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+
+		if _, _, err := d.ImportKey(context.Background(), "test/1", []byte("pollKey")); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("import returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("other polls", func(t *testing.T) {
+		keys, err := d.PublicKeys(context.Background())
+		if err != nil {
+			t.Fatalf("PublicKeys: %v", err)
+		}
+
+		if len(keys) != 1 || keys[0].ID != "test/2" {
+			t.Errorf("got public keys %v, expected only test/2", keys)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "test/2", [][]byte{[]byte(`enc:"Y"`)}); err != nil {
+			t.Errorf("stop: %v", err)
+		}
+	})
+}
+
+func TestImportKey(t *testing.T) {
+	auditLog := new(auditLogMock)
+	store := NewStoreMock()
+	d := decrypt.New(cryptoMock{}, store, decrypt.WithAuditLog(auditLog))
+
+	t.Run("empty key", func(t *testing.T) {
+		_, _, err := d.ImportKey(context.Background(), "test/1", make([]byte, 32))
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("import returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		pubKey, pubKeySig, err := d.ImportKey(context.Background(), "test/1", []byte("importedKey"))
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+
+		if string(pubKey) != "pollPubKey" || string(pubKeySig) != "pollKeySig" {
+			t.Errorf("got pub key %s with sig %s, expected pollPubKey and pollKeySig", pubKey, pubKeySig)
+		}
+
+		if key, _ := store.LoadKey("test/1"); string(key) != "importedKey" {
+			t.Errorf("store has key %s, expected importedKey", key)
+		}
+
+		if _, ok := auditLog.last("import-key"); !ok {
+			t.Errorf("no import-key event in audit log")
+		}
+	})
+
+	t.Run("same key again", func(t *testing.T) {
+		if _, _, err := d.ImportKey(context.Background(), "test/1", []byte("importedKey")); err != nil {
+			t.Errorf("import: %v", err)
+		}
+	})
+
+	t.Run("different key", func(t *testing.T) {
+		_, _, err := d.ImportKey(context.Background(), "test/1", []byte("otherKey"))
+		if !errors.Is(err, errorcode.Exist) {
+			t.Errorf("import returned `%v`, expected `%v`", err, errorcode.Exist)
+		}
+	})
+
+	t.Run("started poll", func(t *testing.T) {
+		if _, _, err := d.Start(context.Background(), "test/2"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		_, _, err := d.ImportKey(context.Background(), "test/2", []byte("importedKey"))
+		if !errors.Is(err, errorcode.Exist) {
+			t.Errorf("import returned `%v`, expected `%v`", err, errorcode.Exist)
+		}
+	})
+
+	t.Run("start after import", func(t *testing.T) {
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if key, _ := store.LoadKey("test/1"); string(key) != "importedKey" {
+			t.Errorf("start changed the key to %s", key)
+		}
+	})
+}
+
+func TestElection(t *testing.T) {
+	d := decrypt.New(cryptoMock{}, NewStoreMock())
+	keys, err := d.StartElection(context.Background(), "election/1", []string{"poll/2", "poll/1"}, decrypt.WithMetadata([]byte("meta")))
+	if err != nil {
+		t.Fatalf("StartElection: %v", err)
+	}
+
+	if len(keys) != 2 || keys[0].ID != "poll/2" || keys[1].ID != "poll/1" {
+		t.Errorf("got keys %v, expected keys for poll/2 and poll/1", keys)
+	}
+
+	if _, _, err := d.Start(context.Background(), "poll/3"); err != nil {
+		t.Fatalf("start other poll: %v", err)
+	}
+
+	t.Run("polls", func(t *testing.T) {
+		polls, err := d.ElectionPolls(context.Background(), "election/1")
+		if err != nil {
+			t.Fatalf("ElectionPolls: %v", err)
+		}
+
+		if strings.Join(polls, ",") != "poll/1,poll/2" {
+			t.Errorf("got polls %v, expected poll/1 and poll/2", polls)
+		}
+
+		status, err := d.Status(context.Background(), "poll/1")
+		if err != nil {
+			t.Fatalf("Status: %v", err)
+		}
+
+		if status.Election != "election/1" {
+			t.Errorf("status has election %s, expected election/1", status.Election)
+		}
+	})
+
+	t.Run("other election", func(t *testing.T) {
+		_, err := d.StartElection(context.Background(), "election/2", []string{"poll/1"})
+		if !errors.Is(err, errorcode.Exist) {
+			t.Errorf("StartElection with a poll of another election returned %v, expected %v", err, errorcode.Exist)
+		}
+	})
+
+	t.Run("missing poll", func(t *testing.T) {
+		_, _, err := d.StopElection(context.Background(), "election/1", []decrypt.ElectionPoll{
+			{ID: "poll/1", Votes: [][]byte{[]byte(`enc:"Y"`)}},
+		})
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("StopElection without poll/2 returned %v, expected %v", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("other poll", func(t *testing.T) {
+		_, _, err := d.StopElection(context.Background(), "election/1", []decrypt.ElectionPoll{
+			{ID: "poll/1", Votes: [][]byte{[]byte(`enc:"Y"`)}},
+			{ID: "poll/3", Votes: [][]byte{[]byte(`enc:"Y"`)}},
+		})
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("StopElection with poll/3 returned %v, expected %v", err, errorcode.Invalid)
+		}
+
+		// No poll may be stopped by the invalid call.
+		if _, _, err := d.Stop(context.Background(), "poll/1", [][]byte{[]byte(`enc:"N"`)}); err != nil {
+			t.Errorf("poll/1 was stopped by an invalid StopElection: %v", err)
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		report, signature, err := d.StopElection(context.Background(), "election/1", []decrypt.ElectionPoll{
+			{ID: "poll/2", Votes: [][]byte{[]byte(`enc:"Y"`), []byte(`enc:"A"`)}},
+			{ID: "poll/1", Votes: [][]byte{[]byte(`enc:"N"`)}},
+		})
+		if err != nil {
+			t.Fatalf("StopElection: %v", err)
+		}
+
+		if string(signature) != "sig:"+string(report) {
+			t.Errorf("report has signature %s", signature)
+		}
+
+		var decoded decrypt.ElectionReport
+		if err := json.Unmarshal(report, &decoded); err != nil {
+			t.Fatalf("decoding report: %v", err)
+		}
+
+		if decoded.Election != "election/1" || len(decoded.Polls) != 2 || decoded.Polls[0].ID != "poll/1" {
+			t.Fatalf("got report %s", report)
+		}
+
+		result, err := decrypt.ParseResult(decoded.Polls[0].Content)
+		if err != nil {
+			t.Fatalf("ParseResult: %v", err)
+		}
+
+		if result.Election != "election/1" || string(result.Metadata) != "meta" {
+			t.Errorf("result of poll/1 has election %s and metadata %s", result.Election, result.Metadata)
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		if err := d.ClearElection(context.Background(), "election/1"); err != nil {
+			t.Fatalf("ClearElection: %v", err)
+		}
+
+		polls, err := d.ElectionPolls(context.Background(), "election/1")
+		if err != nil {
+			t.Fatalf("ElectionPolls: %v", err)
+		}
+
+		if len(polls) != 0 {
+			t.Errorf("election has polls %v after clear", polls)
+		}
+
+		if _, err := d.Status(context.Background(), "poll/3"); err != nil {
+			t.Errorf("other poll was cleared: %v", err)
+		}
+	})
+}
+
+// prefetchStoreMock closes loaded, when the key of poll/2 is loaded after
+// arm() was called. With slow, loading the key of poll/2 takes some time and
+// sets finished afterwards.
+type prefetchStoreMock struct {
+	*StoreMock
+	mu       sync.Mutex
+	loaded   chan struct{}
+	slow     bool
+	finished bool
+}
+
+func (s *prefetchStoreMock) arm() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loaded = make(chan struct{})
+}
+
+func (s *prefetchStoreMock) LoadKey(id string) ([]byte, error) {
+	s.mu.Lock()
+	if id == "poll/2" && s.loaded != nil {
+		close(s.loaded)
+		s.loaded = nil
+	}
+	slow := id == "poll/2" && s.slow
+	s.mu.Unlock()
+
+	if slow {
+		time.Sleep(50 * time.Millisecond)
+		defer func() {
+			s.mu.Lock()
+			s.finished = true
+			s.mu.Unlock()
+		}()
+	}
+	return s.StoreMock.LoadKey(id)
+}
+
+// prefetchCryptoMock decrypts the votes of poll/1 only after the key of poll/2
+// was loaded.
+type prefetchCryptoMock struct {
+	cryptoMock
+	loaded <-chan struct{}
+}
+
+func (c *prefetchCryptoMock) DecryptPoll(key []byte, pollID string, value []byte) ([]byte, error) {
+	if pollID == "poll/1" {
+		select {
+		case <-c.loaded:
+		case <-time.After(time.Second):
+			return nil, fmt.Errorf("poll/2 was not prefetched")
+		}
+	}
+	return c.cryptoMock.DecryptPoll(key, pollID, value)
+}
+
+func TestElectionPrefetch(t *testing.T) {
+	store := &prefetchStoreMock{StoreMock: NewStoreMock()}
+	crypto := &prefetchCryptoMock{}
+	d := decrypt.New(crypto, store)
+	if _, err := d.StartElection(context.Background(), "election/1", []string{"poll/1", "poll/2"}); err != nil {
+		t.Fatalf("StartElection: %v", err)
+	}
+
+	store.arm()
+	crypto.loaded = store.loaded
+
+	report, _, err := d.StopElection(context.Background(), "election/1", []decrypt.ElectionPoll{
+		{ID: "poll/1", Votes: [][]byte{[]byte(`enc:"Y"`)}},
+		{ID: "poll/2", Votes: [][]byte{[]byte(`enc:"N"`)}},
+	})
+	if err != nil {
+		t.Fatalf("StopElection: %v", err)
+	}
+
+	var decoded decrypt.ElectionReport
+	if err := json.Unmarshal(report, &decoded); err != nil {
+		t.Fatalf("decoding report: %v", err)
+	}
+
+	result, err := decrypt.ParseResult(decoded.Polls[0].Content)
+	if err != nil {
+		t.Fatalf("ParseResult: %v", err)
+	}
+
+	if len(result.Votes) != 1 || string(result.Votes[0]) != `"Y"` {
+		t.Errorf("key of poll/2 was not loaded, while poll/1 was decrypted: %s", decoded.Polls[0].Content)
+	}
+
+	t.Run("failing poll", func(t *testing.T) {
+		store := &prefetchStoreMock{StoreMock: NewStoreMock()}
+		d := decrypt.New(cryptoMock{}, store)
+		if _, err := d.StartElection(context.Background(), "election/1", []string{"poll/1", "poll/2"}); err != nil {
+			t.Fatalf("StartElection: %v", err)
+		}
+
+		store.mu.Lock()
+		store.slow = true
+		store.mu.Unlock()
+
+		_, _, err := d.StopElection(context.Background(), "election/1", []decrypt.ElectionPoll{
+			{ID: "poll/1", Votes: [][]byte{[]byte(`enc:"Y"`)}, Options: []decrypt.StopOption{decrypt.WithWeights([]string{"1", "2"})}},
+			{ID: "poll/2", Votes: [][]byte{[]byte(`enc:"N"`)}},
+		})
+		if err == nil {
+			t.Fatalf("StopElection with invalid weights did not fail")
+		}
+
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		if !store.finished {
+			t.Errorf("StopElection returned before the prefetch of poll/2 was done")
+		}
+	})
+}
+
+func TestStatus(t *testing.T) {
+	cr := cryptoMock{}
+	store := NewStoreMock()
+	d := decrypt.New(cr, store)
+
+	t.Run("not started", func(t *testing.T) {
+		_, err := d.Status(context.Background(), "test/1")
+		if !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("status returned `%v`, expected `%v`", err, errorcode.NotExist)
+		}
+	})
+
+	t.Run("started", func(t *testing.T) {
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithMetadata([]byte("meta"))); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		// The metadata of the second call is ignored.
+		if _, _, err := d.Start(context.Background(), "test/1", decrypt.WithMetadata([]byte("other"))); err != nil {
+			t.Fatalf("second start: %v", err)
+		}
+
+		status, err := d.Status(context.Background(), "test/1")
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+
+		if string(status.PubKey) != "pollPubKey" {
+			t.Errorf("status returned pub key `%s`, expected `pollPubKey`", status.PubKey)
+		}
+
+		if string(status.Metadata) != "meta" {
+			t.Errorf("status returned metadata `%s`, expected `meta`", status.Metadata)
+		}
+	})
+}
+
+func TestPublicKeys(t *testing.T) {
+	store := NewStoreMock()
+	d := decrypt.New(cryptoMock{}, store, decrypt.WithDeletionDelay(time.Hour))
+
+	for _, id := range []string{"test/2", "test/1", "test/3"} {
+		if _, _, err := d.Start(context.Background(), id); err != nil {
+			t.Fatalf("start %s: %v", id, err)
+		}
+	}
+
+	if err := d.Clear(context.Background(), "test/3"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+
+	keys, err := d.PublicKeys(context.Background())
+	if err != nil {
+		t.Fatalf("PublicKeys: %v", err)
+	}
+
+	if len(keys) != 2 || keys[0].ID != "test/1" || keys[1].ID != "test/2" {
+		t.Fatalf("got keys %v, expected test/1 and test/2", keys)
+	}
+
+	if string(keys[0].PubKey) != "pollPubKey" || string(keys[0].PubKeySig) != "pollKeySig" {
+		t.Errorf("got pub key %s with sig %s, expected pollPubKey and pollKeySig", keys[0].PubKey, keys[0].PubKeySig)
+	}
+}
+
+func TestClear(t *testing.T) {
+	cr := cryptoMock{}
+
+	t.Run("without delay", func(t *testing.T) {
+		store := NewStoreMock()
+		auditLog := new(auditLogMock)
+		d := decrypt.New(cr, store, decrypt.WithAuditLog(auditLog))
+
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if err := d.Clear(context.Background(), "test/1"); err != nil {
+			t.Fatalf("clear: %v", err)
+		}
+
+		if _, err := store.LoadKey("test/1"); !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("poll key still exists after clear")
+		}
+
+		entry, ok := auditLog.last("clear")
+		if !ok {
+			t.Fatalf("no clear event in audit log")
+		}
+
+		if !strings.Contains(entry.message, `"poll_id":"test/1"`) || !strings.Contains(entry.message, "signature=") {
+			t.Errorf("clear event has message `%s`, expected a signed deletion certificate", entry.message)
+		}
+	})
+
+	t.Run("with delay", func(t *testing.T) {
+		store := NewStoreMock()
+		d := decrypt.New(cr, store, decrypt.WithDeletionDelay(time.Hour))
+
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if err := d.Clear(context.Background(), "test/1"); err != nil {
+			t.Fatalf("clear: %v", err)
+		}
+
+		if err := d.ClearScheduled(context.Background()); err != nil {
+			t.Fatalf("clear scheduled: %v", err)
+		}
+
+		if _, err := store.LoadKey("test/1"); err != nil {
+			t.Errorf("poll key was removed before the delay: %v", err)
+		}
+	})
+
+	t.Run("after delay", func(t *testing.T) {
+		store := NewStoreMock()
+		d := decrypt.New(cr, store, decrypt.WithDeletionDelay(time.Nanosecond))
+
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		if err := d.Clear(context.Background(), "test/1"); err != nil {
+			t.Fatalf("clear: %v", err)
+		}
+
+		time.Sleep(time.Millisecond)
+
+		if err := d.ClearScheduled(context.Background()); err != nil {
+			t.Fatalf("clear scheduled: %v", err)
+		}
+
+		if _, err := store.LoadKey("test/1"); !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("poll key still exists after the delay")
+		}
+	})
+}
+
+func TestWipe(t *testing.T) {
+	cr := cryptoMock{}
+
+	t.Run("valid", func(t *testing.T) {
+		store := NewStoreMock()
+		d := decrypt.New(cr, store)
+
+		for _, id := range []string{"test/1", "test/2"} {
+			if _, _, err := d.Start(context.Background(), id); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+		}
+
+		if err := d.Wipe(context.Background(), d.WipeToken(), false); err != nil {
+			t.Fatalf("wipe: %v", err)
+		}
+
+		ids, _ := store.ListPolls()
+		if len(ids) != 0 {
+			t.Errorf("store has polls %v after wipe", ids)
+		}
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		store := NewStoreMock()
+		d := decrypt.New(cr, store)
+
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		err := d.Wipe(context.Background(), "wrong", false)
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("wipe returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+
+		ids, _ := store.ListPolls()
+		if len(ids) != 1 {
+			t.Errorf("store has polls %v after failed wipe, expected [test/1]", ids)
+		}
+	})
+
+	t.Run("with main key", func(t *testing.T) {
+		var removed bool
+		d := decrypt.New(cr, NewStoreMock(), decrypt.WithRemoveMainKey(func() error {
+			removed = true
+			return nil
+		}))
+
+		if err := d.Wipe(context.Background(), d.WipeToken(), true); err != nil {
+			t.Fatalf("wipe: %v", err)
+		}
+
+		if !removed {
+			t.Errorf("main key was not removed")
+		}
+	})
+
+	t.Run("with main key not supported", func(t *testing.T) {
+		d := decrypt.New(cr, NewStoreMock())
+
+		err := d.Wipe(context.Background(), d.WipeToken(), true)
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("wipe returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+}
+
+func TestReadOnly(t *testing.T) {
+	cr := cryptoMock{}
+	store := NewStoreMock()
+	d := decrypt.New(cr, store)
+
+	if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if err := d.SetReadOnly(context.Background(), true); err != nil {
+		t.Fatalf("set read only: %v", err)
+	}
+
+	t.Run("start existing poll", func(t *testing.T) {
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Errorf("start: %v", err)
+		}
+	})
+
+	t.Run("start new poll", func(t *testing.T) {
+		_, _, err := d.Start(context.Background(), "test/2")
+		if !errors.Is(err, errorcode.ReadOnly) {
+			t.Errorf("start returned `%v`, expected `%v`", err, errorcode.ReadOnly)
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		_, _, err := d.Stop(context.Background(), "test/1", [][]byte{[]byte(`enc:"Y"`)})
+		if !errors.Is(err, errorcode.ReadOnly) {
+			t.Errorf("stop returned `%v`, expected `%v`", err, errorcode.ReadOnly)
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		if _, err := d.Status(context.Background(), "test/1"); err != nil {
+			t.Errorf("status: %v", err)
+		}
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		if err := d.SetReadOnly(context.Background(), false); err != nil {
+			t.Fatalf("set read only: %v", err)
+		}
+
+		if _, _, err := d.Stop(context.Background(), "test/1", [][]byte{[]byte(`enc:"Y"`)}); err != nil {
+			t.Errorf("stop: %v", err)
+		}
+	})
+}
+
+func TestAttest(t *testing.T) {
+	cr := cryptoMock{}
+	binaryHash := []byte("binary-hash")
+
+	t.Run("valid", func(t *testing.T) {
+		d := decrypt.New(cr, NewStoreMock(), decrypt.WithAttestor(attestorMock{}, binaryHash))
+
+		attestation, err := d.Attest(context.Background(), []byte("nonce"))
+		if err != nil {
+			t.Fatalf("attest: %v", err)
+		}
+
+		if !bytes.Equal(attestation.BinaryHash, binaryHash) {
+			t.Errorf("got binary hash %q, expected %q", attestation.BinaryHash, binaryHash)
+		}
+
+		if !bytes.Equal(attestation.PubKey, cr.PublicMainKey()) {
+			t.Errorf("got pub key %q, expected %q", attestation.PubKey, cr.PublicMainKey())
+		}
+
+		expect := append([]byte("evidence:"), decrypt.AttestationData([]byte("nonce"), binaryHash, cr.PublicMainKey())...)
+		if !bytes.Equal(attestation.Evidence, expect) {
+			t.Errorf("evidence is not over the attestation data")
+		}
+	})
+
+	t.Run("invalid nonce", func(t *testing.T) {
+		d := decrypt.New(cr, NewStoreMock(), decrypt.WithAttestor(attestorMock{}, binaryHash))
+
+		for _, nonce := range [][]byte{nil, make([]byte, 65)} {
+			if _, err := d.Attest(context.Background(), nonce); !errors.Is(err, errorcode.Invalid) {
+				t.Errorf("attest with nonce of size %d returned `%v`, expected `%v`", len(nonce), err, errorcode.Invalid)
+			}
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		d := decrypt.New(cr, NewStoreMock())
+
+		if _, err := d.Attest(context.Background(), []byte("nonce")); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("attest returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+}
+
+type monitorMock struct {
+	calls []string
+}
+
+func (m *monitorMock) StopStarted(pollID string) {
+	m.calls = append(m.calls, "started "+pollID)
+}
+
+func (m *monitorMock) StopFinished(pollID string, votes, invalid int, err error) {
+	m.calls = append(m.calls, fmt.Sprintf("finished %s %d/%d %v", pollID, invalid, votes, err))
+}
+
+func TestMonitor(t *testing.T) {
+	filter := func(vote []byte) error {
+		if bytes.Contains(vote, []byte("old")) {
+			return errors.New("format old not accepted")
+		}
+		return nil
+	}
+
+	monitor := new(monitorMock)
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithVoteFilter(filter), decrypt.WithMonitor(monitor))
+
+	if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	votes := [][]byte{
+		[]byte(`enc:"Y"`),
+		[]byte(`old:"N"`),
+		[]byte(`enc:"A"`),
+	}
+
+	if _, _, err := d.Stop(context.Background(), "test/1", votes); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	expect := []string{"started test/1", "finished test/1 1/3 <nil>"}
+	if strings.Join(monitor.calls, ",") != strings.Join(expect, ",") {
+		t.Errorf("monitor got %v, expected %v", monitor.calls, expect)
+	}
+}
+
+func TestExportAuditLog(t *testing.T) {
+	cr := cryptoMock{}
+
+	t.Run("valid", func(t *testing.T) {
+		auditLog := audit.New(path.Join(t.TempDir(), "audit.log"))
+		d := decrypt.New(cr, NewStoreMock(), decrypt.WithAuditLog(auditLog))
+
+		if _, _, err := d.Start(context.Background(), "test/1"); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		content, signature, err := d.ExportAuditLog(context.Background(), "test/1", time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatalf("export audit log: %v", err)
+		}
+
+		if !strings.Contains(string(content), `"event":"start"`) {
+			t.Errorf("export %s does not contain the start event", content)
+		}
+
+		if expect := "sig:" + string(content); string(signature) != expect {
+			t.Errorf("got signature %q, expected %q", signature, expect)
+		}
+	})
+
+	t.Run("invalid time range", func(t *testing.T) {
+		auditLog := audit.New(path.Join(t.TempDir(), "audit.log"))
+		d := decrypt.New(cr, NewStoreMock(), decrypt.WithAuditLog(auditLog))
+
+		now := time.Now()
+		if _, _, err := d.ExportAuditLog(context.Background(), "", now, now.Add(-time.Hour)); !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("export audit log returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		d := decrypt.New(cr, NewStoreMock(), decrypt.WithAuditLog(new(auditLogMock)))
+
+		if _, _, err := d.ExportAuditLog(context.Background(), "", time.Time{}, time.Time{}); !errors.Is(err, errorcode.Unsupported) {
+			t.Errorf("export audit log returned `%v`, expected `%v`", err, errorcode.Unsupported)
+		}
+	})
+}
+
+func TestVersion(t *testing.T) {
+	d := decrypt.New(cryptoMock{}, NewStoreMock())
+
+	info, signature, err := d.Version(context.Background())
+	if err != nil {
+		t.Fatalf("version: %v", err)
+	}
+
+	var decoded version.Info
+	if err := json.Unmarshal(info, &decoded); err != nil {
+		t.Fatalf("decoding info: %v", err)
+	}
+
+	if decoded.GoVersion != runtime.Version() {
+		t.Errorf("got go version %q, expected %q", decoded.GoVersion, runtime.Version())
+	}
+
+	if expect := "sig:" + string(info); string(signature) != expect {
+		t.Errorf("got signature %q, expected %q", signature, expect)
+	}
+}
+
+func TestAuditCaller(t *testing.T) {
+	auditLog := new(auditLogMock)
+	d := decrypt.New(cryptoMock{}, NewStoreMock(), decrypt.WithAuditLog(auditLog))
+
+	ctx := decrypt.ContextWithCaller(context.Background(), "mtls:vote-service")
+	if got := decrypt.CallerFromContext(ctx); got != "mtls:vote-service" {
+		t.Errorf("got caller %q, expected mtls:vote-service", got)
+	}
+
+	if _, _, err := d.Start(ctx, "test/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if _, _, err := d.NoDecryption(ctx, "test/1"); err != nil {
+		t.Fatalf("no decryption: %v", err)
+	}
+
+	if _, _, err := d.Stop(ctx, "test/1", nil); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	for _, event := range []string{"start", "no-decryption", "stop", "order"} {
+		entry, ok := auditLog.last(event)
+		if !ok {
+			t.Fatalf("no %s event in audit log", event)
+		}
+
+		if !strings.Contains(entry.message, `caller="mtls:vote-service"`) {
+			t.Errorf("%s event has message %q, expected the caller", event, entry.message)
+		}
+	}
+
+	if err := d.Clear(context.Background(), "test/1"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+
+	entry, _ := auditLog.last("clear")
+	if strings.Contains(entry.message, "caller=") {
+		t.Errorf("clear event without caller has message %q", entry.message)
 	}
 }
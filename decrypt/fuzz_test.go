@@ -0,0 +1,57 @@
+package decrypt_test
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+)
+
+// FuzzStoreRecords loads arbitrary records from the store. It only checks,
+// that the decrypt component does not panic on a corrupted store. Run it with
+// `go test ./decrypt -fuzz FuzzStoreRecords`.
+func FuzzStoreRecords(f *testing.F) {
+	log.SetOutput(io.Discard)
+	f.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		f.Fatalf("creating poll key: %v", err)
+	}
+
+	vote, err := crypto.EncryptForPoll(rand.Reader, ecdh.X25519(), crypto.FormatV2, "1", key.PublicKey().Bytes(), []byte(`"Y"`))
+	if err != nil {
+		f.Fatalf("encrypting vote: %v", err)
+	}
+
+	f.Add(key.Bytes(), []byte(`{}`), make([]byte, 32), []byte(`{"poll_id":"1"}`), vote)
+	f.Add([]byte{}, []byte(`{"max_votes":1,"ring":["a"]}`), []byte{}, []byte{}, []byte{})
+
+	c := crypto.New(make([]byte, 32), rand.Reader, nil)
+	f.Fuzz(func(t *testing.T, pollKey, meta, commitment, revocation, vote []byte) {
+		store := NewStoreMock()
+		store.keys["1"] = pollKey
+		store.metas["1"] = meta
+		store.commitments["1"] = commitment
+		store.revocations = [][]byte{revocation}
+		store.rekeys["1"] = [][]byte{pollKey, meta}
+
+		d := decrypt.New(c, store)
+		ctx := context.Background()
+
+		d.Status(ctx, "1")
+		d.PublicKeys(ctx)
+		d.InclusionProof(ctx, "1", vote)
+		d.RevocationList(ctx)
+		d.PollSigningKey(ctx, "1")
+		d.IssueToken(ctx, "1", vote)
+		d.PartialDecrypt(ctx, "1", [][]byte{vote})
+		d.Stop(ctx, "1", [][]byte{vote, vote})
+	})
+}
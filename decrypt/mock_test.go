@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/OpenSlides/vote-decrypt/errorcode"
 )
@@ -25,8 +26,8 @@ func (c cryptoMock) PublicPollKey(key []byte) (pubKey []byte, pubKeySig []byte,
 	return []byte("pollPubKey"), []byte("pollKeySig"), nil
 }
 
-// Decrypt returned the plaintext from value using the key.
-func (c cryptoMock) Decrypt(key []byte, value []byte) ([]byte, error) {
+// DecryptPoll returned the plaintext from value using the key.
+func (c cryptoMock) DecryptPoll(key []byte, pollID string, value []byte) ([]byte, error) {
 	prefix := []byte("enc:")
 
 	if !bytes.HasPrefix(value, prefix) {
@@ -35,21 +36,148 @@ func (c cryptoMock) Decrypt(key []byte, value []byte) ([]byte, error) {
 	return bytes.TrimPrefix(value, prefix), nil
 }
 
+// pollDecrypterMock is like cryptoMock, but implements decrypt.PollDecrypter.
+type pollDecrypterMock struct {
+	cryptoMock
+}
+
+// PollDecrypter decrypts like DecryptPoll, but appends the plaintext to dst.
+func (c pollDecrypterMock) PollDecrypter(key []byte, pollID string) (func(dst, value []byte) ([]byte, error), error) {
+	return func(dst, value []byte) ([]byte, error) {
+		plaintext, err := c.DecryptPoll(key, pollID, value)
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, plaintext...), nil
+	}, nil
+}
+
+// PartialDecrypt returns the value as share.
+func (c cryptoMock) PartialDecrypt(key []byte, pollID string, value []byte) (share, proof []byte, err error) {
+	if !bytes.HasPrefix(value, []byte("enc:")) {
+		return nil, nil, fmt.Errorf("decrypt error")
+	}
+	return []byte(fmt.Sprintf("share:%s", value)), []byte("proof"), nil
+}
+
+// PublicTokenKey returns a fake public token key.
+func (c cryptoMock) PublicTokenKey(key []byte) (pubKey []byte, pubKeySig []byte, err error) {
+	return []byte("tokenPubKey"), []byte("tokenKeySig"), nil
+}
+
+// IssueToken returns the blinded value as evaluated token.
+func (c cryptoMock) IssueToken(key []byte, blinded []byte) (evaluated, proof []byte, err error) {
+	return []byte(fmt.Sprintf("token:%s", blinded)), []byte("proof"), nil
+}
+
+// ValidateToken accepts tokens, that start with "tk".
+func (c cryptoMock) ValidateToken(key []byte, token []byte) error {
+	if !bytes.HasPrefix(token, []byte("tk")) {
+		return fmt.Errorf("invalid token")
+	}
+	return nil
+}
+
+// TokenSize returns 4.
+func (c cryptoMock) TokenSize() int {
+	return 4
+}
+
+// VerifyRing accepts votes like `ring:<key image>:<ciphertext>`, if the key
+// image is in the ring.
+func (c cryptoMock) VerifyRing(ring [][]byte, pollID string, vote []byte) (keyImage, ciphertext []byte, err error) {
+	parts := bytes.SplitN(vote, []byte(":"), 3)
+	if len(parts) != 3 || string(parts[0]) != "ring" {
+		return nil, nil, fmt.Errorf("invalid signature")
+	}
+
+	for _, key := range ring {
+		if bytes.Equal(key, parts[1]) {
+			return parts[1], parts[2], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("signer not in ring")
+}
+
+// PollSigningKey returns a fake poll signing key with the poll id.
+func (c cryptoMock) PollSigningKey(key []byte, pollID string) (pubKey, delegation []byte, err error) {
+	pubKey = []byte(fmt.Sprintf("pollSigningKey:%s", pollID))
+	return pubKey, []byte(fmt.Sprintf("sig:%s", pubKey)), nil
+}
+
+// SignPoll returns the signature for the given data with the poll id.
+func (c cryptoMock) SignPoll(key []byte, pollID string, value []byte) ([]byte, error) {
+	return []byte(fmt.Sprintf("pollsig:%s:%s", pollID, value)), nil
+}
+
+// MeetingSigningKey returns a fake meeting signing key with the meeting id.
+func (c cryptoMock) MeetingSigningKey(secret []byte, meetingID string) (pubKey, delegation []byte, err error) {
+	pubKey = []byte(fmt.Sprintf("meetingSigningKey:%s", meetingID))
+	return pubKey, []byte(fmt.Sprintf("sig:%s", pubKey)), nil
+}
+
+// SignMeeting returns the signature for the given data with the meeting id.
+func (c cryptoMock) SignMeeting(secret []byte, meetingID string, value []byte) ([]byte, error) {
+	return []byte(fmt.Sprintf("meetingsig:%s:%s", meetingID, value)), nil
+}
+
 // Returns the signature for the given data.
-func (c cryptoMock) Sign(value []byte) []byte {
-	return []byte(fmt.Sprintf("sig:%s", value))
+func (c cryptoMock) Sign(value []byte) ([]byte, error) {
+	return []byte(fmt.Sprintf("sig:%s", value)), nil
+}
+
+// keyCryptoMock is a cryptoMock, that creates a new poll key for each call.
+// Votes like `key1:"Y"` can only be decrypted with the key `key1`.
+type keyCryptoMock struct {
+	cryptoMock
+
+	mu   sync.Mutex
+	keys int
+}
+
+// CreatePollKey returns the keys `key1`, `key2`, ...
+func (c *keyCryptoMock) CreatePollKey() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.keys++
+	return []byte(fmt.Sprintf("key%d", c.keys)), nil
+}
+
+// PublicPollKey returns the key with the prefix `pub:`.
+func (c *keyCryptoMock) PublicPollKey(key []byte) (pubKey []byte, pubKeySig []byte, err error) {
+	pubKey = append([]byte("pub:"), key...)
+	return pubKey, []byte(fmt.Sprintf("sig:%s", pubKey)), nil
+}
+
+// DecryptPoll removes the prefix `KEY:`.
+func (c *keyCryptoMock) DecryptPoll(key []byte, pollID string, value []byte) ([]byte, error) {
+	prefix := append(append([]byte{}, key...), ':')
+	if !bytes.HasPrefix(value, prefix) {
+		return nil, fmt.Errorf("decrypt error")
+	}
+	return bytes.TrimPrefix(value, prefix), nil
 }
 
 type StoreMock struct {
-	mu         sync.Mutex
-	keys       map[string][]byte
-	signatures map[string][]byte
+	mu          sync.Mutex
+	keys        map[string][]byte
+	signatures  map[string][]byte
+	metas       map[string][]byte
+	clears      map[string]time.Time
+	commitments map[string][]byte
+	revocations [][]byte
+	rekeys      map[string][][]byte
 }
 
 func NewStoreMock() *StoreMock {
 	return &StoreMock{
-		keys:       make(map[string][]byte),
-		signatures: make(map[string][]byte),
+		keys:        make(map[string][]byte),
+		signatures:  make(map[string][]byte),
+		metas:       make(map[string][]byte),
+		clears:      make(map[string]time.Time),
+		commitments: make(map[string][]byte),
+		rekeys:      make(map[string][][]byte),
 	}
 }
 
@@ -79,6 +207,29 @@ func (s *StoreMock) LoadKey(id string) ([]byte, error) {
 	return s.keys[id], nil
 }
 
+func (s *StoreMock) SaveMeta(id string, meta []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.metas[id] != nil {
+		return errorcode.Exist
+	}
+
+	s.metas[id] = meta
+	return nil
+}
+
+func (s *StoreMock) LoadMeta(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.metas[id] == nil {
+		return nil, errorcode.NotExist
+	}
+
+	return s.metas[id], nil
+}
+
 // ValidateSignature makes sure, that no other signature is saved for a
 // poll. Saves the signature for future calls.
 //
@@ -112,9 +263,96 @@ func (s *StoreMock) ClearPoll(id string) error {
 
 	delete(s.keys, id)
 	delete(s.signatures, id)
+	delete(s.metas, id)
+	delete(s.clears, id)
+	delete(s.commitments, id)
+	delete(s.rekeys, id)
+	return nil
+}
+
+func (s *StoreMock) ListPolls() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *StoreMock) ScheduleClear(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clears[id] = at
+	return nil
+}
+
+func (s *StoreMock) ScheduledClears() (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheduled := make(map[string]time.Time, len(s.clears))
+	for id, at := range s.clears {
+		scheduled[id] = at
+	}
+	return scheduled, nil
+}
+
+func (s *StoreMock) SaveCommitment(id string, leaves []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.commitments[id] != nil {
+		return errorcode.Exist
+	}
+
+	s.commitments[id] = leaves
+	return nil
+}
+
+func (s *StoreMock) LoadCommitment(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.commitments[id] == nil {
+		return nil, errorcode.NotExist
+	}
+
+	return s.commitments[id], nil
+}
+
+func (s *StoreMock) SaveReplacementKey(id string, key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rekeys[id] = append(s.rekeys[id], key)
+	return nil
+}
+
+func (s *StoreMock) LoadReplacementKeys(id string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rekeys[id], nil
+}
+
+func (s *StoreMock) SaveRevocation(revocation []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revocations = append(s.revocations, revocation)
 	return nil
 }
 
+func (s *StoreMock) Revocations() ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.revocations, nil
+}
+
 type randomMock struct{}
 
 func (r randomMock) Read(data []byte) (n int, err error) {
@@ -123,3 +361,62 @@ func (r randomMock) Read(data []byte) (n int, err error) {
 	}
 	return len(data), nil
 }
+
+type auditEntry struct {
+	event   string
+	pollID  string
+	message string
+}
+
+type auditLogMock struct {
+	mu      sync.Mutex
+	entries []auditEntry
+}
+
+func (a *auditLogMock) Record(event, pollID, message string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries = append(a.entries, auditEntry{event, pollID, message})
+	return nil
+}
+
+func (a *auditLogMock) Events(pollID string) ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var events []string
+	for _, entry := range a.entries {
+		if entry.pollID == pollID {
+			events = append(events, entry.event)
+		}
+	}
+	return events, nil
+}
+
+// last returns the last entry with the given event.
+func (a *auditLogMock) last(event string) (auditEntry, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := len(a.entries) - 1; i >= 0; i-- {
+		if a.entries[i].event == event {
+			return a.entries[i], true
+		}
+	}
+	return auditEntry{}, false
+}
+
+// attestorMock returns the attested data as evidence.
+type attestorMock struct{}
+
+func (attestorMock) Attest(data []byte) ([]byte, error) {
+	return append([]byte("evidence:"), data...), nil
+}
+
+// sealerMock prefixes the data instead of encrypting it.
+type sealerMock struct{}
+
+func (sealerMock) Seal(data []byte) ([]byte, error) {
+	return append([]byte("sealed:"), data...), nil
+}
@@ -1,22 +1,50 @@
 package decrypt
 
-import "io"
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+)
 
 // Option for decrypt.New().
 type Option = func(*Decrypt)
 
-// WithRandomSource sets the random source. Uses crypt/rand.Reader as default.
-//
-// # Also sets the decryptWorkers to 1
+// WithRandomSource sets the decryptWorkers to 1.
 //
 // Should only be used for testing.
+//
+// Deprecated: The order of the votes is not random any more but derived from
+// the poll key, so the random source is not used. Use WithDecryptWorkers().
 func WithRandomSource(r io.Reader) Option {
 	return func(d *Decrypt) {
-		d.random = r
 		d.decryptWorkers = 1
 	}
 }
 
+// WithDecryptWorkers sets the number of goroutines that decrypt the votes of
+// a poll in parallel. Defaults to GOMAXPROCS.
+func WithDecryptWorkers(workers int) Option {
+	return func(d *Decrypt) {
+		d.decryptWorkers = workers
+	}
+}
+
+// WithAutoTune measures the time to decrypt one vote while the polls are
+// decrypted and tunes the worker pool with it: Polls with little work use fewer
+// workers and each job of a worker decrypts a batch of votes instead of one
+// vote. The number of workers is at most the value of WithDecryptWorkers().
+//
+// The first poll uses an estimate of the time. The tuning does not change the
+// result.
+func WithAutoTune() Option {
+	return func(d *Decrypt) {
+		d.tuner = new(tuner)
+	}
+}
+
 // WithMaxVotes sets the number of maximum votes, that are supported.
 func WithMaxVotes(maxVotes int) Option {
 	return func(d *Decrypt) {
@@ -24,13 +52,466 @@ func WithMaxVotes(maxVotes int) Option {
 	}
 }
 
+// WithMaxVoteSize sets the maximum size in bytes of one encrypted vote.
+func WithMaxVoteSize(maxVoteSize int) Option {
+	return func(d *Decrypt) {
+		d.maxVoteSize = maxVoteSize
+	}
+}
+
+// WithMaxPollSize sets the maximum size in bytes of all encrypted votes of one
+// poll together.
+func WithMaxPollSize(maxPollSize int) Option {
+	return func(d *Decrypt) {
+		d.maxPollSize = maxPollSize
+	}
+}
+
+// OversizePolicy defines, what happens with a decrypted vote that is bigger
+// then the size set with WithMaxPlaintextSize().
+type OversizePolicy int
+
+const (
+	// OversizeFail lets the Stop() call fail with errorcode.Limit.
+	OversizeFail OversizePolicy = iota
+
+	// OversizeInvalid removes the vote from the result and counts it in the
+	// invalid section.
+	OversizeInvalid
+)
+
+// WithMaxParallelStops limits the number of polls, that are decrypted at the
+// same time. Further Stop() calls wait, until a running call is finished or
+// their context is done.
+func WithMaxParallelStops(maxStops int) Option {
+	return func(d *Decrypt) {
+		d.budget.maxRuns = maxStops
+	}
+}
+
+// WithMemoryBudget limits the estimated memory of all polls, that are decrypted
+// at the same time. Further Stop() calls wait, until enough memory is released
+// or their context is done. A poll, that needs more then the whole budget, is
+// refused with errorcode.Limit.
+//
+// The estimation is three times the size of the encrypted votes and a small
+// overhead for each vote. The encrypted votes of waiting calls are already in
+// memory and are not part of the budget.
+func WithMemoryBudget(bytes int64) Option {
+	return func(d *Decrypt) {
+		d.budget.maxBytes = bytes
+	}
+}
+
+// WithMaxPlaintextSize sets the maximum size in bytes of one decrypted vote.
+// The policy defines what happens with bigger votes.
+func WithMaxPlaintextSize(maxPlaintextSize int, policy OversizePolicy) Option {
+	return func(d *Decrypt) {
+		d.maxPlaintextSize = maxPlaintextSize
+		d.oversizePolicy = policy
+	}
+}
+
+// WithAuditLog sets the audit log, that records all security relevant events.
+//
+// As default, the events are written to the default logger.
+func WithAuditLog(auditLog AuditLog) Option {
+	return func(d *Decrypt) {
+		d.auditLog = auditLog
+	}
+}
+
+// WithRemoveMainKey sets a function that removes the main key. It is called by
+// Wipe().
+func WithRemoveMainKey(f func() error) Option {
+	return func(d *Decrypt) {
+		d.removeMainKey = f
+	}
+}
+
+// WithDeletionDelay sets a delay for removing polls. If set, Clear() only
+// schedules the removal. ClearScheduled() or RunScheduledClears() has to be
+// used to remove the polls after the delay.
+func WithDeletionDelay(delay time.Duration) Option {
+	return func(d *Decrypt) {
+		d.deletionDelay = delay
+	}
+}
+
+// WithClock sets the source of the current time. It is used for the time
+// policies of WithNotBefore() and WithDeletionDelay() and for the time in the
+// certificates. Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(d *Decrypt) {
+		d.now = now
+	}
+}
+
+// WithReadOnly starts the decrypt component in read only mode. See
+// Decrypt.SetReadOnly().
+func WithReadOnly(readOnly bool) Option {
+	return func(d *Decrypt) {
+		d.readOnly.Store(readOnly)
+	}
+}
+
+// WithAttestor enables Decrypt.Attest(). binaryHash is the hash of the running
+// binary.
+func WithAttestor(attestor Attestor, binaryHash []byte) Option {
+	return func(d *Decrypt) {
+		d.attestor = attestor
+		d.binaryHash = binaryHash
+	}
+}
+
+// WithVoteFilter adds a filter, that is called for each encrypted vote before
+// it is decrypted. Rejected votes are not part of the votes of the result but
+// are counted in its invalid section.
+func WithVoteFilter(filter VoteFilter) Option {
+	return func(d *Decrypt) {
+		d.voteFilters = append(d.voteFilters, filter)
+	}
+}
+
+// WithIDValidator adds a validator, that is called for each poll id, that is
+// received from a caller. It is called after the built in check of the
+// characters. See IDPattern(), IDMaxLength() and IDNamespaces().
+func WithIDValidator(validator IDValidator) Option {
+	return func(d *Decrypt) {
+		d.idValidators = append(d.idValidators, validator)
+	}
+}
+
+// WithSealer sets the sealer for the auditor. It is used for the order seed in
+// the audit log (see Decrypt.Stop()) and for Decrypt.ExportKey().
+//
+// Without a sealer, only the hash of the seed is written to the audit log and
+// poll keys can not be exported.
+func WithSealer(sealer Sealer) Option {
+	return func(d *Decrypt) {
+		d.sealer = sealer
+	}
+}
+
+// WithStopKey sets the public ed25519 key of the vote service. If set, each
+// Stop() call has to be signed with the private key. See
+// WithRequestSignature().
+func WithStopKey(publicKey ed25519.PublicKey) Option {
+	return func(d *Decrypt) {
+		d.stopKey = publicKey
+	}
+}
+
+// WithCommitment adds the root of a merkle tree over the tracking codes of all
+// votes to the result of a poll. The tree is saved in the store, so voters can
+// fetch an InclusionProof for their vote.
+func WithCommitment() Option {
+	return func(d *Decrypt) {
+		d.commitment = true
+	}
+}
+
+// WithPollSigningKeys signs the public poll keys and the results of the polls
+// with a poll signing key instead of the main key. The main key only signs the
+// delegation of each poll signing key. See Decrypt.PollSigningKey().
+//
+// The crypto backend has to implement PollSigner.
+func WithPollSigningKeys() Option {
+	return func(d *Decrypt) {
+		d.pollSigningKeys = true
+	}
+}
+
+// WithMeetingSigningKeys signs the public poll keys and the results of the
+// polls of a meeting with a meeting signing key instead of the main key. The
+// meeting of a poll is the part of the poll id before the first `/`, see
+// MeetingID(). Polls without a meeting are signed like before.
+//
+// The meeting signing keys are derived from secret, that has to have at least 32
+// random bytes. The main key only signs the delegation of each meeting signing
+// key. See Decrypt.MeetingSigningKey(). The meeting signing keys are used
+// instead of WithPollSigningKeys() for the polls of a meeting.
+//
+// The crypto backend has to implement MeetingSigner.
+func WithMeetingSigningKeys(secret []byte) Option {
+	return func(d *Decrypt) {
+		d.meetingSecret = secret
+	}
+}
+
+// WithCoSigningKey allows other instances to ask this instance for a
+// co-signature of their results. See Decrypt.CoSign().
+//
+// The key has to be different on each instance. It is not the main key, since
+// a standby instance has the same main key as the primary.
+func WithCoSigningKey(key ed25519.PrivateKey) Option {
+	return func(d *Decrypt) {
+		d.coSigningKey = key
+	}
+}
+
+// Feature is an experimental capability, that is disabled by default.
+type Feature string
+
+// FeatureTrustee allows polls, that are started with WithTrustee().
+const FeatureTrustee Feature = "trustee"
+
+// Features are all experimental features.
+var Features = []Feature{FeatureTrustee}
+
+// ParseFeature returns the experimental feature with the given name.
+func ParseFeature(name string) (Feature, error) {
+	for _, feature := range Features {
+		if string(feature) == name {
+			return feature, nil
+		}
+	}
+	return "", fmt.Errorf("unknown feature %q: %w", name, errorcode.Invalid)
+}
+
+// WithFeatures enables experimental features. Polls, that need a disabled
+// feature, are refused with errorcode.Unsupported.
+//
+// The features of a poll are returned by Decrypt.Status() and are part of the
+// signed result.
+func WithFeatures(features ...Feature) Option {
+	return func(d *Decrypt) {
+		if d.features == nil {
+			d.features = make(map[Feature]bool)
+		}
+		for _, feature := range features {
+			d.features[feature] = true
+		}
+	}
+}
+
 // WithListToContent takes a function that is used to create the content
 // returned from the Stop() call.
 //
 // The function taks an id and the randomized list of decrypted votes and
 // createa the output format.
+//
+// The metadata of the poll is not part of the content. Use
+// WithResultToContent() to include it.
 func WithListToContent(f func(id string, decrypted [][]byte) ([]byte, error)) Option {
 	return func(d *Decrypt) {
-		d.listToContent = f
+		d.resultToContent = func(result Result) ([]byte, error) {
+			return f(result.ID, result.Votes)
+		}
+	}
+}
+
+// WithResultToContent takes a function that is used to create the content
+// returned from the Stop() call.
+//
+// The function takes the result of a poll with the randomized list of
+// decrypted votes and creates the output format.
+func WithResultToContent(f func(result Result) ([]byte, error)) Option {
+	return func(d *Decrypt) {
+		d.resultToContent = f
+	}
+}
+
+// WithResultWriter adds a writer, that receives the decrypted votes of each
+// stopped poll one by one. See ResultWriter.
+//
+// The content is still created and returned by Stop(), since the signature is
+// created over it. If a writer fails, Stop() returns an error, but the poll is
+// stopped.
+func WithResultWriter(w ResultWriter) Option {
+	return func(d *Decrypt) {
+		d.resultWriters = append(d.resultWriters, w)
+	}
+}
+
+// WithSpill writes the results of StopMany(), that are bigger then size bytes,
+// to encrypted temporary files in dir, until the caller reads them. So the
+// finished polls of a big StopMany() call do not stay in memory, while the
+// other polls are decrypted. See StopManyResult.Spilled.
+//
+// A result is only written to the file, after it was created and signed in
+// memory. So this does not lower the memory, that is needed to stop one poll.
+// Stop() and StopElection() do not use the files.
+//
+// If dir is empty, the default directory for temporary files is used.
+func WithSpill(dir string, size int) Option {
+	return func(d *Decrypt) {
+		d.spillDir = dir
+		d.spillSize = size
+	}
+}
+
+// WithMonitor adds a monitor, that is told about the decryption of each poll.
+// See Monitor.
+func WithMonitor(m Monitor) Option {
+	return func(d *Decrypt) {
+		d.monitors = append(d.monitors, m)
+	}
+}
+
+// StopConfig are the options of a Stop() call.
+type StopConfig struct {
+	Weights   []string
+	Signature []byte
+	Priority  int
+	Tags      []VoteTag
+}
+
+// StopOption for Decrypt.Stop().
+type StopOption = func(*StopConfig)
+
+// WithWeights attaches a weight to each vote, for example for delegations.
+//
+// There has to be one weight for each vote in the same order. A weight is a
+// decimal number like `1` or `2.5`.
+func WithWeights(weights []string) StopOption {
+	return func(c *StopConfig) {
+		c.Weights = weights
+	}
+}
+
+// VoteTag is the authenticated pseudonym of the voter of a vote and the time,
+// the vote was cast.
+type VoteTag struct {
+	Pseudonym string
+	Time      time.Time
+}
+
+// WithTags attaches a tag to each vote. The votes of a pseudonym are handled by
+// the revote policy of the poll. See WithRevotePolicy().
+//
+// There has to be one tag for each vote in the same order. The vote service is
+// responsible to authenticate the voters. If the decrypt component uses
+// WithStopKey(), the tags are part of the signed TaggedStopRequestMessage().
+func WithTags(tags []VoteTag) StopOption {
+	return func(c *StopConfig) {
+		c.Tags = tags
+	}
+}
+
+// WithRequestSignature attaches the signature of the vote service over
+// StopRequestMessage(). It is needed, if the decrypt component was initialized
+// with WithStopKey().
+func WithRequestSignature(signature []byte) StopOption {
+	return func(c *StopConfig) {
+		c.Signature = signature
+	}
+}
+
+// WithPriority sets the priority of the call, if it has to wait for resources.
+// See WithMaxParallelStops() and WithMemoryBudget().
+//
+// Calls with a higher priority are started first. The priority has to be
+// between MinPriority and MaxPriority. Defaults to 0.
+func WithPriority(priority int) StopOption {
+	return func(c *StopConfig) {
+		c.Priority = priority
+	}
+}
+
+// StartConfig is the configuration of a poll. It is saved, when a poll is
+// started.
+type StartConfig struct {
+	Metadata  []byte       `json:"metadata,omitempty"`
+	NotBefore *time.Time   `json:"not_before,omitempty"`
+	Election  string       `json:"election,omitempty"`
+	Trustee   bool         `json:"trustee,omitempty"`
+	Tokens    bool         `json:"tokens,omitempty"`
+	Ring      [][]byte     `json:"ring,omitempty"`
+	Revote    RevotePolicy `json:"revote,omitempty"`
+}
+
+// features returns the experimental features, that are needed by the poll.
+func (c StartConfig) features() []Feature {
+	var features []Feature
+	if c.Trustee {
+		features = append(features, FeatureTrustee)
+	}
+	return features
+}
+
+// StartOption for Decrypt.Start().
+type StartOption = func(*StartConfig)
+
+// WithMetadata attaches opaque metadata to a poll, for example a title, a
+// meeting id or a ballot hash.
+//
+// The metadata is part of the signed result of the poll.
+func WithMetadata(metadata []byte) StartOption {
+	return func(c *StartConfig) {
+		c.Metadata = metadata
+	}
+}
+
+// WithNotBefore sets the earliest time, the poll can be stopped. Stop() calls
+// before this time are refused.
+//
+// The time is part of the signed result of the poll.
+func WithNotBefore(notBefore time.Time) StartOption {
+	return func(c *StartConfig) {
+		t := notBefore.UTC().Truncate(time.Second)
+		c.NotBefore = &t
+	}
+}
+
+// WithElection adds the poll to an election. See Decrypt.StartElection().
+//
+// The election id is part of the signed result of the poll.
+func WithElection(electionID string) StartOption {
+	return func(c *StartConfig) {
+		c.Election = electionID
+	}
+}
+
+// WithTrustee starts the poll for a trustee of a poll, that is decrypted by
+// many trustees together. The poll can not be stopped. Its votes can only be
+// decrypted with Decrypt.PartialDecrypt().
+func WithTrustee() StartOption {
+	return func(c *StartConfig) {
+		c.Trustee = true
+	}
+}
+
+// WithTokens requires a voting token in each vote of the poll. Voters get the
+// tokens with Decrypt.IssueToken(). A vote without a valid token or with a
+// token, that was already used, is counted as invalid.
+func WithTokens() StartOption {
+	return func(c *StartConfig) {
+		c.Tokens = true
+	}
+}
+
+// WithRing requires a linkable ring signature of one of the given public keys
+// in each vote of the poll, for example the keys of all eligible voters. A vote
+// with an invalid signature is counted as invalid. If a signer voted more than
+// once, all its votes are counted as invalid.
+//
+// The ring is part of the poll configuration. The votes have to be signed for
+// the same keys in the same order.
+func WithRing(ring [][]byte) StartOption {
+	return func(c *StartConfig) {
+		c.Ring = ring
+	}
+}
+
+// RevotePolicy defines, what happens with the votes of a pseudonym, that voted
+// more than once. See WithTags().
+type RevotePolicy int
+
+const (
+	// RevoteInvalid counts all valid votes of the pseudonym as invalid.
+	RevoteInvalid RevotePolicy = iota
+
+	// RevoteLast keeps only the latest valid vote of the pseudonym. The other
+	// votes are counted as superseded. A voter, that was coerced, can vote
+	// again to override the vote.
+	RevoteLast
+)
+
+// WithRevotePolicy sets the revote policy of the poll. The default is
+// RevoteInvalid.
+func WithRevotePolicy(policy RevotePolicy) StartOption {
+	return func(c *StartConfig) {
+		c.Revote = policy
 	}
 }
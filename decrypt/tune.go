@@ -0,0 +1,97 @@
+package decrypt
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/metrics"
+)
+
+const (
+	// tuneJobDuration is the time, that one job of the worker pool should
+	// take. Shorter jobs spend more time in the channel of the pool then in
+	// decrypting, longer jobs balance the work badly between the workers.
+	tuneJobDuration = 200 * time.Microsecond
+
+	// tuneWorkerDuration is the minimum time of work for one worker. Polls
+	// with less work use fewer workers, so they do not pay for goroutines,
+	// that decrypt only a few votes.
+	tuneWorkerDuration = 2 * time.Millisecond
+
+	// tuneJobsPerWorker is the minimum number of jobs per worker, so a
+	// worker, that is slowed down, does not delay the whole poll.
+	tuneJobsPerWorker = 4
+
+	// tuneMaxBatch is the maximum number of votes of one job.
+	tuneMaxBatch = 1024
+
+	// tuneInitialCost is the assumed time to decrypt one vote, before the
+	// first vote was measured.
+	tuneInitialCost = 50 * time.Microsecond
+)
+
+var metricVoteCost = metrics.NewGauge(
+	"vote_decrypt_decrypt_vote_cost_seconds",
+	"Measured average time to decrypt one vote, that is used to tune the worker pool.",
+)
+
+// tuner chooses the number of decrypt workers and the number of votes per job
+// of the worker pool from the measured time to decrypt one vote. See
+// WithAutoTune().
+//
+// The time is a moving average of all jobs, so it follows changes of the
+// hardware, for example a throttled CPU, and of the votes.
+type tuner struct {
+	cost atomic.Int64 // time per vote in nanoseconds. 0 means unknown.
+}
+
+// voteCost returns the average time to decrypt one vote.
+func (t *tuner) voteCost() time.Duration {
+	if cost := t.cost.Load(); cost > 0 {
+		return time.Duration(cost)
+	}
+	return tuneInitialCost
+}
+
+// observe adds the time of a job, that decrypted the given number of votes.
+func (t *tuner) observe(votes int, duration time.Duration) {
+	if votes == 0 {
+		return
+	}
+
+	sample := int64(duration) / int64(votes)
+	for {
+		old := t.cost.Load()
+		cost := sample
+		if old > 0 {
+			// Exponential moving average with a weight of 1/8 for the new
+			// sample.
+			cost = old + (sample-old)/8
+		}
+
+		if t.cost.CompareAndSwap(old, max(cost, 1)) {
+			metricVoteCost.Set(time.Duration(cost).Seconds())
+			return
+		}
+	}
+}
+
+// workers returns the number of workers for the given number of votes. It is
+// at most maxWorkers.
+func (t *tuner) workers(votes int, maxWorkers int) int {
+	work := time.Duration(votes) * t.voteCost()
+	workers := int(work / tuneWorkerDuration)
+	return min(max(workers, 1), maxWorkers)
+}
+
+// batch returns the number of votes per job for a poll with the given number
+// of votes, that is decrypted by the given number of workers.
+func (t *tuner) batch(votes int, workers int) int {
+	size := int(tuneJobDuration / t.voteCost())
+
+	if perWorker := votes / (workers * tuneJobsPerWorker); size > perWorker {
+		size = perWorker
+	}
+
+	return min(max(size, 1), tuneMaxBatch)
+}
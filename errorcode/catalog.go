@@ -0,0 +1,140 @@
+package errorcode
+
+import (
+	"strings"
+)
+
+// Machine readable reasons of errors. They are sent to the client together with
+// the human readable message, so a client can react on an error without
+// parsing the message.
+//
+// The reasons of the DecryptErrors are returned by DecryptError.Reason(). The
+// other reasons are used for errors, that do not come from the decrypt
+// component.
+const (
+	ReasonUnknown     = "UNKNOWN"
+	ReasonExist       = "EXIST"
+	ReasonNotExist    = "NOT_EXIST"
+	ReasonInvalid     = "INVALID"
+	ReasonLimit       = "LIMIT"
+	ReasonReadOnly    = "READ_ONLY"
+	ReasonUnsupported = "UNSUPPORTED"
+	ReasonForbidden   = "FORBIDDEN"
+	ReasonUnavailable = "UNAVAILABLE"
+
+	// ReasonUnauthenticated is a call of an admin method without a valid
+	// admin token.
+	ReasonUnauthenticated = "UNAUTHENTICATED"
+
+	// ReasonTimeout is a call, that did not finish before its deadline.
+	ReasonTimeout = "TIMEOUT"
+
+	// ReasonInternal is an unexpected error, for example of the store.
+	ReasonInternal = "INTERNAL"
+)
+
+// Reason returns the machine readable reason of the error, for example
+// NOT_EXIST.
+func (err DecryptError) Reason() string {
+	switch err {
+	case Exist:
+		return ReasonExist
+	case NotExist:
+		return ReasonNotExist
+	case Invalid:
+		return ReasonInvalid
+	case Limit:
+		return ReasonLimit
+	case ReadOnly:
+		return ReasonReadOnly
+	case Unsupported:
+		return ReasonUnsupported
+	case Forbidden:
+		return ReasonForbidden
+	case Unavailable:
+		return ReasonUnavailable
+	default:
+		return ReasonUnknown
+	}
+}
+
+// DefaultLanguage is the language of the message catalog, that is used, if no
+// requested language is supported.
+const DefaultLanguage = "en"
+
+// catalog contains the messages for the election officials for each reason
+// and language.
+var catalog = map[string]map[string]string{
+	ReasonUnknown: {
+		"en": "An unknown error occurred.",
+		"de": "Ein unbekannter Fehler ist aufgetreten.",
+	},
+	ReasonExist: {
+		"en": "The poll already exists.",
+		"de": "Die Abstimmung existiert bereits.",
+	},
+	ReasonNotExist: {
+		"en": "The poll does not exist or was not started.",
+		"de": "Die Abstimmung existiert nicht oder wurde nicht gestartet.",
+	},
+	ReasonInvalid: {
+		"en": "The request is invalid, for example the poll was already stopped with other votes.",
+		"de": "Die Anfrage ist ungültig, zum Beispiel wurde die Abstimmung bereits mit anderen Stimmen beendet.",
+	},
+	ReasonLimit: {
+		"en": "A limit was exceeded, for example too many votes. Try again later or contact the administrator.",
+		"de": "Ein Limit wurde überschritten, zum Beispiel zu viele Stimmen. Versuchen Sie es später erneut oder wenden Sie sich an die Administration.",
+	},
+	ReasonReadOnly: {
+		"en": "The decrypt service is in read only mode. Polls can not be started or stopped at the moment.",
+		"de": "Der Entschlüsselungsdienst ist im Nur-Lese-Modus. Abstimmungen können im Moment nicht gestartet oder beendet werden.",
+	},
+	ReasonUnsupported: {
+		"en": "This function is not enabled on the decrypt service.",
+		"de": "Diese Funktion ist im Entschlüsselungsdienst nicht aktiviert.",
+	},
+	ReasonForbidden: {
+		"en": "The request was refused, because it is not allowed or its signature is invalid.",
+		"de": "Die Anfrage wurde abgelehnt, weil sie nicht erlaubt ist oder ihre Signatur ungültig ist.",
+	},
+	ReasonUnavailable: {
+		"en": "The decrypt service is temporarily unavailable. Try again in a few moments.",
+		"de": "Der Entschlüsselungsdienst ist vorübergehend nicht erreichbar. Versuchen Sie es in einigen Augenblicken erneut.",
+	},
+	ReasonUnauthenticated: {
+		"en": "The admin token is missing or wrong.",
+		"de": "Das Admin-Token fehlt oder ist falsch.",
+	},
+	ReasonTimeout: {
+		"en": "The decryption took too long. Try again or contact the administrator.",
+		"de": "Die Entschlüsselung hat zu lange gedauert. Versuchen Sie es erneut oder wenden Sie sich an die Administration.",
+	},
+	ReasonInternal: {
+		"en": "An internal error occurred in the decrypt service. Contact the administrator.",
+		"de": "Im Entschlüsselungsdienst ist ein interner Fehler aufgetreten. Wenden Sie sich an die Administration.",
+	},
+}
+
+// Message returns the message for a reason in the best language of an
+// Accept-Language header, for example `de-DE,de;q=0.9,en;q=0.8`. It also
+// returns the language of the message.
+//
+// The quality values of the header are ignored, the languages are tried in
+// their order. If no language is supported, DefaultLanguage is used.
+func Message(reason string, acceptLanguage string) (message, language string) {
+	messages, ok := catalog[reason]
+	if !ok {
+		messages = catalog[ReasonUnknown]
+	}
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		primary, _, _ := strings.Cut(tag, "-")
+
+		if message, ok := messages[primary]; ok {
+			return message, primary
+		}
+	}
+	return messages[DefaultLanguage], DefaultLanguage
+}
@@ -19,6 +19,24 @@ const (
 	//
 	// Has to be returned by store.ValidateHash if the hash is invalid.
 	Invalid
+
+	// Limit happens when a configured limit is exceeded.
+	Limit
+
+	// ReadOnly happens when a method is called, that changes the state, but
+	// the service is in read only mode.
+	ReadOnly
+
+	// Unsupported happens when a feature is called, that is not configured.
+	Unsupported
+
+	// Forbidden happens when a request is not signed or the signature is
+	// invalid.
+	Forbidden
+
+	// Unavailable happens when a backend is failing and the call is rejected
+	// without trying it.
+	Unavailable
 )
 
 // DecryptError are all known errors from the decrypt error.
@@ -35,6 +53,21 @@ func (err DecryptError) Error() string {
 	case Invalid:
 		return "invalid content"
 
+	case Limit:
+		return "limit exceeded"
+
+	case ReadOnly:
+		return "service is in read only mode"
+
+	case Unsupported:
+		return "not supported"
+
+	case Forbidden:
+		return "permission denied"
+
+	case Unavailable:
+		return "temporarily unavailable"
+
 	default:
 		return "unknown error"
 	}
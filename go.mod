@@ -3,16 +3,23 @@ module github.com/OpenSlides/vote-decrypt
 go 1.22
 
 require (
+	filippo.io/nistec v0.0.3
 	github.com/alecthomas/kong v1.2.1
 	github.com/golang/protobuf v1.5.4
-	golang.org/x/crypto v0.27.0
-	golang.org/x/sys v0.26.0
+	github.com/google/go-tpm v0.9.1
+	github.com/google/go-tpm-tools v0.3.13-0.20230620182252-4639ecce2aba
+	github.com/twmb/franz-go v1.18.1
+	golang.org/x/crypto v0.32.0
+	golang.org/x/sys v0.29.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142
 	google.golang.org/grpc v1.67.1
 	google.golang.org/protobuf v1.35.1
 )
 
 require (
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/pierrec/lz4/v4 v4.1.22 // indirect
+	github.com/twmb/franz-go/pkg/kmsg v1.9.0 // indirect
 	golang.org/x/net v0.28.0 // indirect
-	golang.org/x/text v0.18.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
+	golang.org/x/text v0.21.0 // indirect
 )
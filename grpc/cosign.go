@@ -0,0 +1,131 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+)
+
+// CoSigner verifies the result of a poll and signs it. Usually, it is the
+// Client of another instance.
+type CoSigner interface {
+	CoSign(ctx context.Context, pollID string, voteList [][]byte, content []byte, options ...decrypt.StopOption) (decrypt.CoSignature, error)
+}
+
+// WithCoSigners asks the co-signers for a co-signature of each result of
+// Stop. They are added to the response, so the Go client adds them to the
+// ResultEnvelope.
+//
+// Stop fails with the code Unavailable, if less than quorum co-signers return
+// a valid co-signature. If quorum is 0, all co-signers have to sign. The poll
+// is already stopped in this case and Stop can be called again with the same
+// votes.
+func WithCoSigners(quorum int, cosigners ...CoSigner) ServerOption {
+	return func(c *serverConfig) {
+		c.cosignQuorum = quorum
+		c.cosigners = cosigners
+	}
+}
+
+// coSign collects the co-signatures for the result of a stop request.
+func (s grpcServer) coSign(ctx context.Context, req *StopRequest, content []byte) ([]*CoSignature, error) {
+	if len(s.cosigners) == 0 {
+		return nil, nil
+	}
+
+	quorum := s.cosignQuorum
+	if quorum <= 0 || quorum > len(s.cosigners) {
+		quorum = len(s.cosigners)
+	}
+
+	cosignatures := make([]decrypt.CoSignature, len(s.cosigners))
+	var wg sync.WaitGroup
+	for i, cosigner := range s.cosigners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			cosignature, err := cosigner.CoSign(ctx, req.Id, req.Votes, content, stopOptions(req)...)
+			if err != nil {
+				log.Printf("Error: co-signer %d for poll %s: %v", i+1, req.Id, err)
+				return
+			}
+
+			if !decrypt.VerifyCoSignature(req.Id, content, cosignature) {
+				log.Printf("Error: co-signer %d returned an invalid co-signature for poll %s", i+1, req.Id)
+				return
+			}
+			cosignatures[i] = cosignature
+		}()
+	}
+	wg.Wait()
+
+	var valid []*CoSignature
+	for _, cosignature := range cosignatures {
+		if cosignature.PubKey == nil || hasCoSigner(valid, cosignature.PubKey) {
+			continue
+		}
+		valid = append(valid, &CoSignature{PubKey: cosignature.PubKey, Signature: cosignature.Signature})
+	}
+
+	if len(valid) < quorum {
+		return nil, fmt.Errorf("got %d of %d needed co-signatures: %w", len(valid), quorum, errorcode.Unavailable)
+	}
+	return valid, nil
+}
+
+// hasCoSigner returns true, if one of the co-signatures is from pubKey.
+func hasCoSigner(cosignatures []*CoSignature, pubKey []byte) bool {
+	for _, cosignature := range cosignatures {
+		if bytes.Equal(cosignature.PubKey, pubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s grpcServer) CoSign(ctx context.Context, req *CoSignRequest) (*CoSignature, error) {
+	stop := req.GetStop()
+	if stop == nil {
+		return nil, s.grpcError(fmt.Errorf("co-sign request without stop request: %w", errorcode.Invalid))
+	}
+
+	log.Printf("CoSign request for id %s", stop.Id)
+	cosignature, err := s.decrypt.CoSign(ctx, stop.Id, stop.Votes, req.Content, stopOptions(stop)...)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("co-signing result: %w", err))
+	}
+
+	return &CoSignature{PubKey: cosignature.PubKey, Signature: cosignature.Signature}, nil
+}
+
+// CoSign calls the CoSign grpc message.
+func (c *Client) CoSign(ctx context.Context, pollID string, voteList [][]byte, content []byte, options ...decrypt.StopOption) (decrypt.CoSignature, error) {
+	resp, err := c.decryptClient.CoSign(ctx, &CoSignRequest{
+		Stop:    newStopRequest(pollID, voteList, false, options...),
+		Content: content,
+	})
+	if err != nil {
+		return decrypt.CoSignature{}, fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return decrypt.CoSignature{PubKey: resp.PubKey, Signature: resp.Signature}, nil
+}
+
+// coSignatures converts the co-signatures of a stop response.
+func coSignatures(cosignatures []*CoSignature) []decrypt.CoSignature {
+	if len(cosignatures) == 0 {
+		return nil
+	}
+
+	converted := make([]decrypt.CoSignature, len(cosignatures))
+	for i, cosignature := range cosignatures {
+		converted[i] = decrypt.CoSignature{PubKey: cosignature.PubKey, Signature: cosignature.Signature}
+	}
+	return converted
+}
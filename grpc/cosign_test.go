@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type failingCoSigner struct{}
+
+func (failingCoSigner) CoSign(ctx context.Context, pollID string, voteList [][]byte, content []byte, options ...decrypt.StopOption) (decrypt.CoSignature, error) {
+	return decrypt.CoSignature{}, errors.New("co-signer is down")
+}
+
+func TestCoSigners(t *testing.T) {
+	cr := crypto.New(make([]byte, 32), rand.Reader, nil)
+	backend := store.New(t.TempDir())
+	primary := decrypt.New(cr, backend)
+
+	// The standby uses the same store and main key, but its own co-signing
+	// key.
+	pubKey, key, _ := ed25519.GenerateKey(nil)
+	standby := decrypt.New(cr, backend, decrypt.WithCoSigningKey(key))
+
+	if _, _, err := primary.Start(context.Background(), "1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	req := &StopRequest{Id: "1"}
+
+	t.Run("quorum", func(t *testing.T) {
+		server := grpcServer{decrypt: primary, cosigners: []CoSigner{standby, failingCoSigner{}}, cosignQuorum: 1}
+
+		resp, err := server.Stop(context.Background(), req)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		if len(resp.Cosignatures) != 1 || string(resp.Cosignatures[0].PubKey) != string(pubKey) {
+			t.Fatalf("got co-signatures %v, expected one of the standby", resp.Cosignatures)
+		}
+
+		cosignature := decrypt.CoSignature{PubKey: resp.Cosignatures[0].PubKey, Signature: resp.Cosignatures[0].Signature}
+		if !decrypt.VerifyCoSignature("1", resp.Votes, cosignature) {
+			t.Errorf("invalid co-signature")
+		}
+	})
+
+	t.Run("without quorum", func(t *testing.T) {
+		server := grpcServer{decrypt: primary, cosigners: []CoSigner{standby, failingCoSigner{}}}
+
+		_, err := server.Stop(context.Background(), req)
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("stop returned `%v`, expected code %s", err, codes.Unavailable)
+		}
+	})
+}
@@ -21,7 +21,33 @@ type DecryptClient interface {
 	PublicMainKey(ctx context.Context, in *EmptyMessage, opts ...grpc.CallOption) (*PublicMainKeyResponse, error)
 	Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (*StartResponse, error)
 	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*StopResponse, error)
+	CoSign(ctx context.Context, in *CoSignRequest, opts ...grpc.CallOption) (*CoSignature, error)
 	Clear(ctx context.Context, in *ClearRequest, opts ...grpc.CallOption) (*EmptyMessage, error)
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	Wipe(ctx context.Context, in *WipeRequest, opts ...grpc.CallOption) (*EmptyMessage, error)
+	SetReadOnly(ctx context.Context, in *SetReadOnlyRequest, opts ...grpc.CallOption) (*EmptyMessage, error)
+	Attest(ctx context.Context, in *AttestRequest, opts ...grpc.CallOption) (*AttestResponse, error)
+	Version(ctx context.Context, in *EmptyMessage, opts ...grpc.CallOption) (*VersionResponse, error)
+	CheckMainKey(ctx context.Context, in *CheckMainKeyRequest, opts ...grpc.CallOption) (*EmptyMessage, error)
+	ExportKey(ctx context.Context, in *ExportKeyRequest, opts ...grpc.CallOption) (*ExportKeyResponse, error)
+	InclusionProof(ctx context.Context, in *InclusionProofRequest, opts ...grpc.CallOption) (*InclusionProofResponse, error)
+	NoDecryption(ctx context.Context, in *NoDecryptionRequest, opts ...grpc.CallOption) (*NoDecryptionResponse, error)
+	ImportKey(ctx context.Context, in *ImportKeyRequest, opts ...grpc.CallOption) (*StartResponse, error)
+	PublicKeys(ctx context.Context, in *EmptyMessage, opts ...grpc.CallOption) (*PublicKeysResponse, error)
+	StartElection(ctx context.Context, in *StartElectionRequest, opts ...grpc.CallOption) (*PublicKeysResponse, error)
+	StopElection(ctx context.Context, in *StopElectionRequest, opts ...grpc.CallOption) (*StopElectionResponse, error)
+	ClearElection(ctx context.Context, in *ClearElectionRequest, opts ...grpc.CallOption) (*EmptyMessage, error)
+	StopMany(ctx context.Context, in *StopManyRequest, opts ...grpc.CallOption) (Decrypt_StopManyClient, error)
+	PartialDecrypt(ctx context.Context, in *PartialDecryptRequest, opts ...grpc.CallOption) (*PartialDecryptResponse, error)
+	IssueToken(ctx context.Context, in *IssueTokenRequest, opts ...grpc.CallOption) (*IssueTokenResponse, error)
+	PollSigningKey(ctx context.Context, in *PollSigningKeyRequest, opts ...grpc.CallOption) (*PollSigningKeyResponse, error)
+	MeetingSigningKey(ctx context.Context, in *MeetingSigningKeyRequest, opts ...grpc.CallOption) (*PollSigningKeyResponse, error)
+	Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*EmptyMessage, error)
+	Rekey(ctx context.Context, in *RekeyRequest, opts ...grpc.CallOption) (*StartResponse, error)
+	RevocationList(ctx context.Context, in *EmptyMessage, opts ...grpc.CallOption) (*RevocationListResponse, error)
+	ExportAuditLog(ctx context.Context, in *ExportAuditLogRequest, opts ...grpc.CallOption) (*ExportAuditLogResponse, error)
+	EffectiveConfig(ctx context.Context, in *EmptyMessage, opts ...grpc.CallOption) (*EffectiveConfigResponse, error)
+	SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*SetLogLevelResponse, error)
 }
 
 type decryptClient struct {
@@ -59,6 +85,15 @@ func (c *decryptClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.
 	return out, nil
 }
 
+func (c *decryptClient) CoSign(ctx context.Context, in *CoSignRequest, opts ...grpc.CallOption) (*CoSignature, error) {
+	out := new(CoSignature)
+	err := c.cc.Invoke(ctx, "/Decrypt/CoSign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *decryptClient) Clear(ctx context.Context, in *ClearRequest, opts ...grpc.CallOption) (*EmptyMessage, error) {
 	out := new(EmptyMessage)
 	err := c.cc.Invoke(ctx, "/Decrypt/Clear", in, out, opts...)
@@ -68,6 +103,254 @@ func (c *decryptClient) Clear(ctx context.Context, in *ClearRequest, opts ...grp
 	return out, nil
 }
 
+func (c *decryptClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/Status", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) Wipe(ctx context.Context, in *WipeRequest, opts ...grpc.CallOption) (*EmptyMessage, error) {
+	out := new(EmptyMessage)
+	err := c.cc.Invoke(ctx, "/Decrypt/Wipe", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) SetReadOnly(ctx context.Context, in *SetReadOnlyRequest, opts ...grpc.CallOption) (*EmptyMessage, error) {
+	out := new(EmptyMessage)
+	err := c.cc.Invoke(ctx, "/Decrypt/SetReadOnly", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) Attest(ctx context.Context, in *AttestRequest, opts ...grpc.CallOption) (*AttestResponse, error) {
+	out := new(AttestResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/Attest", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) Version(ctx context.Context, in *EmptyMessage, opts ...grpc.CallOption) (*VersionResponse, error) {
+	out := new(VersionResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/Version", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) CheckMainKey(ctx context.Context, in *CheckMainKeyRequest, opts ...grpc.CallOption) (*EmptyMessage, error) {
+	out := new(EmptyMessage)
+	err := c.cc.Invoke(ctx, "/Decrypt/CheckMainKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) ExportKey(ctx context.Context, in *ExportKeyRequest, opts ...grpc.CallOption) (*ExportKeyResponse, error) {
+	out := new(ExportKeyResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/ExportKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) InclusionProof(ctx context.Context, in *InclusionProofRequest, opts ...grpc.CallOption) (*InclusionProofResponse, error) {
+	out := new(InclusionProofResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/InclusionProof", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) NoDecryption(ctx context.Context, in *NoDecryptionRequest, opts ...grpc.CallOption) (*NoDecryptionResponse, error) {
+	out := new(NoDecryptionResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/NoDecryption", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) ImportKey(ctx context.Context, in *ImportKeyRequest, opts ...grpc.CallOption) (*StartResponse, error) {
+	out := new(StartResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/ImportKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) PublicKeys(ctx context.Context, in *EmptyMessage, opts ...grpc.CallOption) (*PublicKeysResponse, error) {
+	out := new(PublicKeysResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/PublicKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) StartElection(ctx context.Context, in *StartElectionRequest, opts ...grpc.CallOption) (*PublicKeysResponse, error) {
+	out := new(PublicKeysResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/StartElection", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) StopElection(ctx context.Context, in *StopElectionRequest, opts ...grpc.CallOption) (*StopElectionResponse, error) {
+	out := new(StopElectionResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/StopElection", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) ClearElection(ctx context.Context, in *ClearElectionRequest, opts ...grpc.CallOption) (*EmptyMessage, error) {
+	out := new(EmptyMessage)
+	err := c.cc.Invoke(ctx, "/Decrypt/ClearElection", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) StopMany(ctx context.Context, in *StopManyRequest, opts ...grpc.CallOption) (Decrypt_StopManyClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Decrypt_ServiceDesc.Streams[0], "/Decrypt/StopMany", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &decryptStopManyClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Decrypt_StopManyClient interface {
+	Recv() (*StopManyResponse, error)
+	grpc.ClientStream
+}
+
+type decryptStopManyClient struct {
+	grpc.ClientStream
+}
+
+func (x *decryptStopManyClient) Recv() (*StopManyResponse, error) {
+	m := new(StopManyResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *decryptClient) PartialDecrypt(ctx context.Context, in *PartialDecryptRequest, opts ...grpc.CallOption) (*PartialDecryptResponse, error) {
+	out := new(PartialDecryptResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/PartialDecrypt", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) IssueToken(ctx context.Context, in *IssueTokenRequest, opts ...grpc.CallOption) (*IssueTokenResponse, error) {
+	out := new(IssueTokenResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/IssueToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) PollSigningKey(ctx context.Context, in *PollSigningKeyRequest, opts ...grpc.CallOption) (*PollSigningKeyResponse, error) {
+	out := new(PollSigningKeyResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/PollSigningKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) MeetingSigningKey(ctx context.Context, in *MeetingSigningKeyRequest, opts ...grpc.CallOption) (*PollSigningKeyResponse, error) {
+	out := new(PollSigningKeyResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/MeetingSigningKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*EmptyMessage, error) {
+	out := new(EmptyMessage)
+	err := c.cc.Invoke(ctx, "/Decrypt/Revoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) Rekey(ctx context.Context, in *RekeyRequest, opts ...grpc.CallOption) (*StartResponse, error) {
+	out := new(StartResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/Rekey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) RevocationList(ctx context.Context, in *EmptyMessage, opts ...grpc.CallOption) (*RevocationListResponse, error) {
+	out := new(RevocationListResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/RevocationList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) ExportAuditLog(ctx context.Context, in *ExportAuditLogRequest, opts ...grpc.CallOption) (*ExportAuditLogResponse, error) {
+	out := new(ExportAuditLogResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/ExportAuditLog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) EffectiveConfig(ctx context.Context, in *EmptyMessage, opts ...grpc.CallOption) (*EffectiveConfigResponse, error) {
+	out := new(EffectiveConfigResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/EffectiveConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *decryptClient) SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*SetLogLevelResponse, error) {
+	out := new(SetLogLevelResponse)
+	err := c.cc.Invoke(ctx, "/Decrypt/SetLogLevel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DecryptServer is the server API for Decrypt service.
 // All implementations should embed UnimplementedDecryptServer
 // for forward compatibility
@@ -75,7 +358,33 @@ type DecryptServer interface {
 	PublicMainKey(context.Context, *EmptyMessage) (*PublicMainKeyResponse, error)
 	Start(context.Context, *StartRequest) (*StartResponse, error)
 	Stop(context.Context, *StopRequest) (*StopResponse, error)
+	CoSign(context.Context, *CoSignRequest) (*CoSignature, error)
 	Clear(context.Context, *ClearRequest) (*EmptyMessage, error)
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	Wipe(context.Context, *WipeRequest) (*EmptyMessage, error)
+	SetReadOnly(context.Context, *SetReadOnlyRequest) (*EmptyMessage, error)
+	Attest(context.Context, *AttestRequest) (*AttestResponse, error)
+	Version(context.Context, *EmptyMessage) (*VersionResponse, error)
+	CheckMainKey(context.Context, *CheckMainKeyRequest) (*EmptyMessage, error)
+	ExportKey(context.Context, *ExportKeyRequest) (*ExportKeyResponse, error)
+	InclusionProof(context.Context, *InclusionProofRequest) (*InclusionProofResponse, error)
+	NoDecryption(context.Context, *NoDecryptionRequest) (*NoDecryptionResponse, error)
+	ImportKey(context.Context, *ImportKeyRequest) (*StartResponse, error)
+	PublicKeys(context.Context, *EmptyMessage) (*PublicKeysResponse, error)
+	StartElection(context.Context, *StartElectionRequest) (*PublicKeysResponse, error)
+	StopElection(context.Context, *StopElectionRequest) (*StopElectionResponse, error)
+	ClearElection(context.Context, *ClearElectionRequest) (*EmptyMessage, error)
+	StopMany(*StopManyRequest, Decrypt_StopManyServer) error
+	PartialDecrypt(context.Context, *PartialDecryptRequest) (*PartialDecryptResponse, error)
+	IssueToken(context.Context, *IssueTokenRequest) (*IssueTokenResponse, error)
+	PollSigningKey(context.Context, *PollSigningKeyRequest) (*PollSigningKeyResponse, error)
+	MeetingSigningKey(context.Context, *MeetingSigningKeyRequest) (*PollSigningKeyResponse, error)
+	Revoke(context.Context, *RevokeRequest) (*EmptyMessage, error)
+	Rekey(context.Context, *RekeyRequest) (*StartResponse, error)
+	RevocationList(context.Context, *EmptyMessage) (*RevocationListResponse, error)
+	ExportAuditLog(context.Context, *ExportAuditLogRequest) (*ExportAuditLogResponse, error)
+	EffectiveConfig(context.Context, *EmptyMessage) (*EffectiveConfigResponse, error)
+	SetLogLevel(context.Context, *SetLogLevelRequest) (*SetLogLevelResponse, error)
 }
 
 // UnimplementedDecryptServer should be embedded to have forward compatible implementations.
@@ -91,9 +400,87 @@ func (UnimplementedDecryptServer) Start(context.Context, *StartRequest) (*StartR
 func (UnimplementedDecryptServer) Stop(context.Context, *StopRequest) (*StopResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
 }
+func (UnimplementedDecryptServer) CoSign(context.Context, *CoSignRequest) (*CoSignature, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CoSign not implemented")
+}
 func (UnimplementedDecryptServer) Clear(context.Context, *ClearRequest) (*EmptyMessage, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Clear not implemented")
 }
+func (UnimplementedDecryptServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedDecryptServer) Wipe(context.Context, *WipeRequest) (*EmptyMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Wipe not implemented")
+}
+func (UnimplementedDecryptServer) SetReadOnly(context.Context, *SetReadOnlyRequest) (*EmptyMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetReadOnly not implemented")
+}
+func (UnimplementedDecryptServer) Attest(context.Context, *AttestRequest) (*AttestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Attest not implemented")
+}
+func (UnimplementedDecryptServer) Version(context.Context, *EmptyMessage) (*VersionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Version not implemented")
+}
+func (UnimplementedDecryptServer) CheckMainKey(context.Context, *CheckMainKeyRequest) (*EmptyMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckMainKey not implemented")
+}
+func (UnimplementedDecryptServer) ExportKey(context.Context, *ExportKeyRequest) (*ExportKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportKey not implemented")
+}
+func (UnimplementedDecryptServer) InclusionProof(context.Context, *InclusionProofRequest) (*InclusionProofResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InclusionProof not implemented")
+}
+func (UnimplementedDecryptServer) NoDecryption(context.Context, *NoDecryptionRequest) (*NoDecryptionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NoDecryption not implemented")
+}
+func (UnimplementedDecryptServer) ImportKey(context.Context, *ImportKeyRequest) (*StartResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportKey not implemented")
+}
+func (UnimplementedDecryptServer) PublicKeys(context.Context, *EmptyMessage) (*PublicKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublicKeys not implemented")
+}
+func (UnimplementedDecryptServer) StartElection(context.Context, *StartElectionRequest) (*PublicKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartElection not implemented")
+}
+func (UnimplementedDecryptServer) StopElection(context.Context, *StopElectionRequest) (*StopElectionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopElection not implemented")
+}
+func (UnimplementedDecryptServer) ClearElection(context.Context, *ClearElectionRequest) (*EmptyMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClearElection not implemented")
+}
+func (UnimplementedDecryptServer) StopMany(*StopManyRequest, Decrypt_StopManyServer) error {
+	return status.Errorf(codes.Unimplemented, "method StopMany not implemented")
+}
+func (UnimplementedDecryptServer) PartialDecrypt(context.Context, *PartialDecryptRequest) (*PartialDecryptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PartialDecrypt not implemented")
+}
+func (UnimplementedDecryptServer) IssueToken(context.Context, *IssueTokenRequest) (*IssueTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IssueToken not implemented")
+}
+func (UnimplementedDecryptServer) PollSigningKey(context.Context, *PollSigningKeyRequest) (*PollSigningKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PollSigningKey not implemented")
+}
+func (UnimplementedDecryptServer) MeetingSigningKey(context.Context, *MeetingSigningKeyRequest) (*PollSigningKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MeetingSigningKey not implemented")
+}
+func (UnimplementedDecryptServer) Revoke(context.Context, *RevokeRequest) (*EmptyMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
+func (UnimplementedDecryptServer) Rekey(context.Context, *RekeyRequest) (*StartResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rekey not implemented")
+}
+func (UnimplementedDecryptServer) RevocationList(context.Context, *EmptyMessage) (*RevocationListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevocationList not implemented")
+}
+func (UnimplementedDecryptServer) ExportAuditLog(context.Context, *ExportAuditLogRequest) (*ExportAuditLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportAuditLog not implemented")
+}
+func (UnimplementedDecryptServer) EffectiveConfig(context.Context, *EmptyMessage) (*EffectiveConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EffectiveConfig not implemented")
+}
+func (UnimplementedDecryptServer) SetLogLevel(context.Context, *SetLogLevelRequest) (*SetLogLevelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLogLevel not implemented")
+}
 
 // UnsafeDecryptServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to DecryptServer will
@@ -160,6 +547,24 @@ func _Decrypt_Stop_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Decrypt_CoSign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CoSignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).CoSign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/CoSign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).CoSign(ctx, req.(*CoSignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Decrypt_Clear_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ClearRequest)
 	if err := dec(in); err != nil {
@@ -178,28 +583,671 @@ func _Decrypt_Clear_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
-// Decrypt_ServiceDesc is the grpc.ServiceDesc for Decrypt service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var Decrypt_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "Decrypt",
-	HandlerType: (*DecryptServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "PublicMainKey",
-			Handler:    _Decrypt_PublicMainKey_Handler,
-		},
-		{
-			MethodName: "Start",
-			Handler:    _Decrypt_Start_Handler,
-		},
-		{
-			MethodName: "Stop",
-			Handler:    _Decrypt_Stop_Handler,
-		},
-		{
-			MethodName: "Clear",
-			Handler:    _Decrypt_Clear_Handler,
+func _Decrypt_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_Wipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).Wipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/Wipe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).Wipe(ctx, req.(*WipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_SetReadOnly_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetReadOnlyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).SetReadOnly(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/SetReadOnly",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).SetReadOnly(ctx, req.(*SetReadOnlyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_Attest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AttestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).Attest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/Attest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).Attest(ctx, req.(*AttestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_Version_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmptyMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).Version(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/Version",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).Version(ctx, req.(*EmptyMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_CheckMainKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckMainKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).CheckMainKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/CheckMainKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).CheckMainKey(ctx, req.(*CheckMainKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_ExportKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).ExportKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/ExportKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).ExportKey(ctx, req.(*ExportKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_InclusionProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InclusionProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).InclusionProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/InclusionProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).InclusionProof(ctx, req.(*InclusionProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_NoDecryption_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NoDecryptionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).NoDecryption(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/NoDecryption",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).NoDecryption(ctx, req.(*NoDecryptionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_ImportKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).ImportKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/ImportKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).ImportKey(ctx, req.(*ImportKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_PublicKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmptyMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).PublicKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/PublicKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).PublicKeys(ctx, req.(*EmptyMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_StartElection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartElectionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).StartElection(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/StartElection",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).StartElection(ctx, req.(*StartElectionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_StopElection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopElectionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).StopElection(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/StopElection",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).StopElection(ctx, req.(*StopElectionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_ClearElection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClearElectionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).ClearElection(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/ClearElection",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).ClearElection(ctx, req.(*ClearElectionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_StopMany_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StopManyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DecryptServer).StopMany(m, &decryptStopManyServer{stream})
+}
+
+type Decrypt_StopManyServer interface {
+	Send(*StopManyResponse) error
+	grpc.ServerStream
+}
+
+type decryptStopManyServer struct {
+	grpc.ServerStream
+}
+
+func (x *decryptStopManyServer) Send(m *StopManyResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Decrypt_PartialDecrypt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PartialDecryptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).PartialDecrypt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/PartialDecrypt",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).PartialDecrypt(ctx, req.(*PartialDecryptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_IssueToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IssueTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).IssueToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/IssueToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).IssueToken(ctx, req.(*IssueTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_PollSigningKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PollSigningKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).PollSigningKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/PollSigningKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).PollSigningKey(ctx, req.(*PollSigningKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_MeetingSigningKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MeetingSigningKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).MeetingSigningKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/MeetingSigningKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).MeetingSigningKey(ctx, req.(*MeetingSigningKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/Revoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).Revoke(ctx, req.(*RevokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_Rekey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RekeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).Rekey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/Rekey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).Rekey(ctx, req.(*RekeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_RevocationList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmptyMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).RevocationList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/RevocationList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).RevocationList(ctx, req.(*EmptyMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_ExportAuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportAuditLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).ExportAuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/ExportAuditLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).ExportAuditLog(ctx, req.(*ExportAuditLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_EffectiveConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmptyMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).EffectiveConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/EffectiveConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).EffectiveConfig(ctx, req.(*EmptyMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Decrypt_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetLogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecryptServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Decrypt/SetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecryptServer).SetLogLevel(ctx, req.(*SetLogLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Decrypt_ServiceDesc is the grpc.ServiceDesc for Decrypt service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Decrypt_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "Decrypt",
+	HandlerType: (*DecryptServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PublicMainKey",
+			Handler:    _Decrypt_PublicMainKey_Handler,
+		},
+		{
+			MethodName: "Start",
+			Handler:    _Decrypt_Start_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _Decrypt_Stop_Handler,
+		},
+		{
+			MethodName: "CoSign",
+			Handler:    _Decrypt_CoSign_Handler,
+		},
+		{
+			MethodName: "Clear",
+			Handler:    _Decrypt_Clear_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _Decrypt_Status_Handler,
+		},
+		{
+			MethodName: "Wipe",
+			Handler:    _Decrypt_Wipe_Handler,
+		},
+		{
+			MethodName: "SetReadOnly",
+			Handler:    _Decrypt_SetReadOnly_Handler,
+		},
+		{
+			MethodName: "Attest",
+			Handler:    _Decrypt_Attest_Handler,
+		},
+		{
+			MethodName: "Version",
+			Handler:    _Decrypt_Version_Handler,
+		},
+		{
+			MethodName: "CheckMainKey",
+			Handler:    _Decrypt_CheckMainKey_Handler,
+		},
+		{
+			MethodName: "ExportKey",
+			Handler:    _Decrypt_ExportKey_Handler,
+		},
+		{
+			MethodName: "InclusionProof",
+			Handler:    _Decrypt_InclusionProof_Handler,
+		},
+		{
+			MethodName: "NoDecryption",
+			Handler:    _Decrypt_NoDecryption_Handler,
+		},
+		{
+			MethodName: "ImportKey",
+			Handler:    _Decrypt_ImportKey_Handler,
+		},
+		{
+			MethodName: "PublicKeys",
+			Handler:    _Decrypt_PublicKeys_Handler,
+		},
+		{
+			MethodName: "StartElection",
+			Handler:    _Decrypt_StartElection_Handler,
+		},
+		{
+			MethodName: "StopElection",
+			Handler:    _Decrypt_StopElection_Handler,
+		},
+		{
+			MethodName: "ClearElection",
+			Handler:    _Decrypt_ClearElection_Handler,
+		},
+		{
+			MethodName: "PartialDecrypt",
+			Handler:    _Decrypt_PartialDecrypt_Handler,
+		},
+		{
+			MethodName: "IssueToken",
+			Handler:    _Decrypt_IssueToken_Handler,
+		},
+		{
+			MethodName: "PollSigningKey",
+			Handler:    _Decrypt_PollSigningKey_Handler,
+		},
+		{
+			MethodName: "MeetingSigningKey",
+			Handler:    _Decrypt_MeetingSigningKey_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _Decrypt_Revoke_Handler,
+		},
+		{
+			MethodName: "Rekey",
+			Handler:    _Decrypt_Rekey_Handler,
+		},
+		{
+			MethodName: "RevocationList",
+			Handler:    _Decrypt_RevocationList_Handler,
+		},
+		{
+			MethodName: "ExportAuditLog",
+			Handler:    _Decrypt_ExportAuditLog_Handler,
+		},
+		{
+			MethodName: "EffectiveConfig",
+			Handler:    _Decrypt_EffectiveConfig_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _Decrypt_SetLogLevel_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StopMany",
+			Handler:       _Decrypt_StopMany_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grpc/decrypt.proto",
+}
+
+// ReplicationClient is the client API for Replication service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ReplicationClient interface {
+	Replicate(ctx context.Context, in *ReplicateRequest, opts ...grpc.CallOption) (*EmptyMessage, error)
+}
+
+type replicationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReplicationClient(cc grpc.ClientConnInterface) ReplicationClient {
+	return &replicationClient{cc}
+}
+
+func (c *replicationClient) Replicate(ctx context.Context, in *ReplicateRequest, opts ...grpc.CallOption) (*EmptyMessage, error) {
+	out := new(EmptyMessage)
+	err := c.cc.Invoke(ctx, "/Replication/Replicate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReplicationServer is the server API for Replication service.
+// All implementations should embed UnimplementedReplicationServer
+// for forward compatibility
+type ReplicationServer interface {
+	Replicate(context.Context, *ReplicateRequest) (*EmptyMessage, error)
+}
+
+// UnimplementedReplicationServer should be embedded to have forward compatible implementations.
+type UnimplementedReplicationServer struct {
+}
+
+func (UnimplementedReplicationServer) Replicate(context.Context, *ReplicateRequest) (*EmptyMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Replicate not implemented")
+}
+
+// UnsafeReplicationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReplicationServer will
+// result in compilation errors.
+type UnsafeReplicationServer interface {
+	mustEmbedUnimplementedReplicationServer()
+}
+
+func RegisterReplicationServer(s grpc.ServiceRegistrar, srv ReplicationServer) {
+	s.RegisterService(&Replication_ServiceDesc, srv)
+}
+
+func _Replication_Replicate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplicateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicationServer).Replicate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Replication/Replicate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicationServer).Replicate(ctx, req.(*ReplicateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Replication_ServiceDesc is the grpc.ServiceDesc for Replication service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Replication_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "Replication",
+	HandlerType: (*ReplicationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Replicate",
+			Handler:    _Replication_Replicate_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
@@ -0,0 +1,476 @@
+package grpc
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/certificate"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/rekor"
+)
+
+// ResultEnvelope is the result of a stopped poll together with its signature.
+//
+// It has a canonical json encoding, so consumers that do not use protobuf can
+// verify the signature:
+//
+//	{"content":"...","id":"...","signature":"..."}
+//
+// The fields are in this order, there is no whitespace and the byte fields are
+// encoded with base64url without padding (RFC 4648, section 5). The signature
+// is created with the main key over the decoded content.
+//
+// If the server uses a time stamping authority, the field "timestamp" follows
+// the signature. It is a RFC 3161 timestamp token for the signature. See
+// package tsa.
+//
+// If the algorithm of the signature is known, the field "algorithm" is the
+// first field. It is one of the identifiers of crypto.Algorithm, for example
+// `ed25519`. Verify() only accepts a signature of this algorithm.
+//
+// If the main key has a X.509 certificate, the field "certificates" follows
+// the algorithm. It is a list of the DER encoded certificates of the chain,
+// starting with the certificate of the main key. See VerifyCertificate().
+//
+// If the fingerprint of the main key is known, the field "fingerprint" follows
+// the content. See decrypt.Fingerprint(). It is not signed and only helps
+// humans to find the right public main key.
+//
+// If the result is signed with a poll signing key, the field "delegation"
+// follows the content and the field "poll_signing_key" the id. The signature
+// is created with the poll signing key. The delegation is the signature of
+// crypto.DelegationMessage() with the main key.
+//
+// If the result is signed with a meeting signing key, the field "meeting"
+// follows the id. The key is in the field "poll_signing_key" and the delegation
+// is the signature of crypto.MeetingDelegationMessage() with the main key. The
+// meeting has to be the meeting of the poll id, see decrypt.MeetingID().
+//
+// If the result was co-signed by other instances, the field "cosignatures"
+// follows the content. It is a list of objects with the fields "pub_key" and
+// "signature". Each signature is created with the ed25519 co-signing key of an
+// instance over decrypt.CoSignatureMessage(). See VerifyCoSignatures().
+//
+// If the result was added to a transparency log, the field "transparency"
+// follows the timestamp. It is an object with the fields of rekor.Entry in
+// snake case. Verify() checks, that the entry is for the content and included
+// in the tree of the log. See VerifyTransparency().
+type ResultEnvelope struct {
+	Algorithm      crypto.Algorithm
+	Certificates   [][]byte
+	ID             string
+	Content        []byte
+	CoSignatures   []decrypt.CoSignature
+	Delegation     []byte
+	Fingerprint    string
+	Meeting        string
+	PollSigningKey []byte
+	Signature      []byte
+	Timestamp      []byte
+	Transparency   *rekor.Entry
+}
+
+// MarshalJSON returns the canonical json encoding.
+func (e ResultEnvelope) MarshalJSON() ([]byte, error) {
+	id, err := json.Marshal(e.ID)
+	if err != nil {
+		return nil, fmt.Errorf("encoding id: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{`)
+	if e.Algorithm != "" {
+		algorithm, err := json.Marshal(e.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("encoding algorithm: %w", err)
+		}
+		buf.WriteString(`"algorithm":`)
+		buf.Write(algorithm)
+		buf.WriteString(`,`)
+	}
+	if len(e.Certificates) > 0 {
+		buf.WriteString(`"certificates":[`)
+		for i, cert := range e.Certificates {
+			if i > 0 {
+				buf.WriteString(`,`)
+			}
+			buf.WriteString(`"`)
+			buf.WriteString(base64.RawURLEncoding.EncodeToString(cert))
+			buf.WriteString(`"`)
+		}
+		buf.WriteString(`],`)
+	}
+	buf.WriteString(`"content":"`)
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(e.Content))
+	buf.WriteString(`",`)
+	if len(e.CoSignatures) > 0 {
+		buf.WriteString(`"cosignatures":[`)
+		for i, cosignature := range e.CoSignatures {
+			if i > 0 {
+				buf.WriteString(`,`)
+			}
+			buf.WriteString(`{"pub_key":"`)
+			buf.WriteString(base64.RawURLEncoding.EncodeToString(cosignature.PubKey))
+			buf.WriteString(`","signature":"`)
+			buf.WriteString(base64.RawURLEncoding.EncodeToString(cosignature.Signature))
+			buf.WriteString(`"}`)
+		}
+		buf.WriteString(`],`)
+	}
+	if len(e.Delegation) > 0 {
+		buf.WriteString(`"delegation":"`)
+		buf.WriteString(base64.RawURLEncoding.EncodeToString(e.Delegation))
+		buf.WriteString(`",`)
+	}
+	if e.Fingerprint != "" {
+		fingerprint, err := json.Marshal(e.Fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("encoding fingerprint: %w", err)
+		}
+		buf.WriteString(`"fingerprint":`)
+		buf.Write(fingerprint)
+		buf.WriteString(`,`)
+	}
+	buf.WriteString(`"id":`)
+	buf.Write(id)
+	if e.Meeting != "" {
+		meeting, err := json.Marshal(e.Meeting)
+		if err != nil {
+			return nil, fmt.Errorf("encoding meeting: %w", err)
+		}
+		buf.WriteString(`,"meeting":`)
+		buf.Write(meeting)
+	}
+	if len(e.PollSigningKey) > 0 {
+		buf.WriteString(`,"poll_signing_key":"`)
+		buf.WriteString(base64.RawURLEncoding.EncodeToString(e.PollSigningKey))
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(`,"signature":"`)
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(e.Signature))
+	if len(e.Timestamp) > 0 {
+		buf.WriteString(`","timestamp":"`)
+		buf.WriteString(base64.RawURLEncoding.EncodeToString(e.Timestamp))
+	}
+	buf.WriteString(`"`)
+	if e.Transparency != nil {
+		transparency, err := marshalTransparency(*e.Transparency)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`,"transparency":`)
+		buf.Write(transparency)
+	}
+	buf.WriteString(`}`)
+	return buf.Bytes(), nil
+}
+
+// transparencyJSON is the json form of a rekor.Entry in the envelope.
+type transparencyJSON struct {
+	Body                 string   `json:"body"`
+	Checkpoint           string   `json:"checkpoint"`
+	Hashes               []string `json:"hashes"`
+	IntegratedTime       int64    `json:"integrated_time"`
+	LogID                string   `json:"log_id"`
+	LogIndex             int64    `json:"log_index"`
+	RootHash             string   `json:"root_hash"`
+	SignedEntryTimestamp string   `json:"signed_entry_timestamp"`
+	TreeIndex            int64    `json:"tree_index"`
+	TreeSize             int64    `json:"tree_size"`
+	UUID                 string   `json:"uuid"`
+}
+
+// marshalTransparency encodes the entry with the fields in alphabetical order
+// and the byte fields in base64url.
+func marshalTransparency(entry rekor.Entry) ([]byte, error) {
+	hashes := make([]string, len(entry.Hashes))
+	for i, hash := range entry.Hashes {
+		hashes[i] = base64.RawURLEncoding.EncodeToString(hash)
+	}
+
+	encoded, err := json.Marshal(transparencyJSON{
+		Body:                 base64.RawURLEncoding.EncodeToString(entry.Body),
+		Checkpoint:           entry.Checkpoint,
+		Hashes:               hashes,
+		IntegratedTime:       entry.IntegratedTime,
+		LogID:                entry.LogID,
+		LogIndex:             entry.LogIndex,
+		RootHash:             base64.RawURLEncoding.EncodeToString(entry.RootHash),
+		SignedEntryTimestamp: base64.RawURLEncoding.EncodeToString(entry.SignedEntryTimestamp),
+		TreeIndex:            entry.TreeIndex,
+		TreeSize:             entry.TreeSize,
+		UUID:                 entry.UUID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding transparency entry: %w", err)
+	}
+	return encoded, nil
+}
+
+// unmarshalTransparency decodes an entry encoded by marshalTransparency.
+func unmarshalTransparency(raw transparencyJSON) (*rekor.Entry, error) {
+	body, err := base64.RawURLEncoding.DecodeString(raw.Body)
+	if err != nil {
+		return nil, fmt.Errorf("decoding body: %w", err)
+	}
+
+	rootHash, err := base64.RawURLEncoding.DecodeString(raw.RootHash)
+	if err != nil {
+		return nil, fmt.Errorf("decoding root hash: %w", err)
+	}
+
+	set, err := base64.RawURLEncoding.DecodeString(raw.SignedEntryTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signed entry timestamp: %w", err)
+	}
+
+	hashes := make([][]byte, len(raw.Hashes))
+	for i, encoded := range raw.Hashes {
+		hashes[i], err = base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decoding hash %d: %w", i+1, err)
+		}
+	}
+
+	return &rekor.Entry{
+		UUID:                 raw.UUID,
+		LogID:                raw.LogID,
+		LogIndex:             raw.LogIndex,
+		IntegratedTime:       raw.IntegratedTime,
+		Body:                 body,
+		SignedEntryTimestamp: set,
+		TreeIndex:            raw.TreeIndex,
+		TreeSize:             raw.TreeSize,
+		RootHash:             rootHash,
+		Hashes:               hashes,
+		Checkpoint:           raw.Checkpoint,
+	}, nil
+}
+
+// UnmarshalJSON decodes an envelope created by MarshalJSON.
+func (e *ResultEnvelope) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Algorithm    string   `json:"algorithm"`
+		Certificates []string `json:"certificates"`
+		Content      string   `json:"content"`
+		CoSignatures []struct {
+			PubKey    string `json:"pub_key"`
+			Signature string `json:"signature"`
+		} `json:"cosignatures"`
+		Delegation     string            `json:"delegation"`
+		Fingerprint    string            `json:"fingerprint"`
+		ID             string            `json:"id"`
+		Meeting        string            `json:"meeting"`
+		PollSigningKey string            `json:"poll_signing_key"`
+		Signature      string            `json:"signature"`
+		Timestamp      string            `json:"timestamp"`
+		Transparency   *transparencyJSON `json:"transparency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(raw.Content)
+	if err != nil {
+		return fmt.Errorf("decoding content: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(raw.Signature)
+	if err != nil {
+		return fmt.Errorf("decoding signature: %w", err)
+	}
+
+	var timestamp []byte
+	if raw.Timestamp != "" {
+		timestamp, err = base64.RawURLEncoding.DecodeString(raw.Timestamp)
+		if err != nil {
+			return fmt.Errorf("decoding timestamp: %w", err)
+		}
+	}
+
+	var delegation, pollSigningKey []byte
+	if raw.Delegation != "" {
+		delegation, err = base64.RawURLEncoding.DecodeString(raw.Delegation)
+		if err != nil {
+			return fmt.Errorf("decoding delegation: %w", err)
+		}
+	}
+
+	if raw.PollSigningKey != "" {
+		pollSigningKey, err = base64.RawURLEncoding.DecodeString(raw.PollSigningKey)
+		if err != nil {
+			return fmt.Errorf("decoding poll signing key: %w", err)
+		}
+	}
+
+	var certificates [][]byte
+	for i, encoded := range raw.Certificates {
+		cert, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("decoding certificate %d: %w", i+1, err)
+		}
+		certificates = append(certificates, cert)
+	}
+
+	var cosignatures []decrypt.CoSignature
+	for i, encoded := range raw.CoSignatures {
+		pubKey, err := base64.RawURLEncoding.DecodeString(encoded.PubKey)
+		if err != nil {
+			return fmt.Errorf("decoding key of co-signature %d: %w", i+1, err)
+		}
+
+		signature, err := base64.RawURLEncoding.DecodeString(encoded.Signature)
+		if err != nil {
+			return fmt.Errorf("decoding co-signature %d: %w", i+1, err)
+		}
+		cosignatures = append(cosignatures, decrypt.CoSignature{PubKey: pubKey, Signature: signature})
+	}
+
+	var transparency *rekor.Entry
+	if raw.Transparency != nil {
+		transparency, err = unmarshalTransparency(*raw.Transparency)
+		if err != nil {
+			return fmt.Errorf("decoding transparency entry: %w", err)
+		}
+	}
+
+	*e = ResultEnvelope{
+		Algorithm:      crypto.Algorithm(raw.Algorithm),
+		Certificates:   certificates,
+		ID:             raw.ID,
+		Content:        content,
+		CoSignatures:   cosignatures,
+		Delegation:     delegation,
+		Fingerprint:    raw.Fingerprint,
+		Meeting:        raw.Meeting,
+		PollSigningKey: pollSigningKey,
+		Signature:      signature,
+		Timestamp:      timestamp,
+		Transparency:   transparency,
+	}
+	return nil
+}
+
+// Verify checks the signature of the envelope with the public main key.
+//
+// If the envelope has an algorithm, the public main key has to use it. If it
+// has a fingerprint, it has to be the fingerprint of the public main key.
+func (e ResultEnvelope) Verify(publicMainKey []byte) error {
+	if e.Fingerprint != "" {
+		if expected := decrypt.Fingerprint(publicMainKey); e.Fingerprint != expected {
+			return fmt.Errorf("envelope for poll %s is from the main key %s, not from %s", e.ID, e.Fingerprint, expected)
+		}
+	}
+
+	signingKey := publicMainKey
+	switch {
+	case e.Meeting != "":
+		if decrypt.MeetingID(e.ID) != e.Meeting {
+			return fmt.Errorf("poll %s is not in meeting %s", e.ID, e.Meeting)
+		}
+
+		if !crypto.VerifyMeetingDelegation(publicMainKey, e.Meeting, e.PollSigningKey, e.Delegation) {
+			return fmt.Errorf("invalid delegation of the meeting signing key for meeting %s", e.Meeting)
+		}
+		signingKey = e.PollSigningKey
+
+	case len(e.PollSigningKey) > 0 || len(e.Delegation) > 0:
+		if !crypto.VerifyDelegation(publicMainKey, e.ID, e.PollSigningKey, e.Delegation) {
+			return fmt.Errorf("invalid delegation of the poll signing key for poll %s", e.ID)
+		}
+		signingKey = e.PollSigningKey
+	}
+
+	valid := crypto.Verify(signingKey, e.Content, e.Signature)
+	if e.Algorithm != "" {
+		valid = crypto.VerifyAlgorithm(e.Algorithm, signingKey, e.Content, e.Signature)
+	}
+
+	if !valid {
+		return fmt.Errorf("invalid signature for poll %s", e.ID)
+	}
+
+	if e.Transparency != nil {
+		if err := rekor.Verify(*e.Transparency, e.Content, nil); err != nil {
+			return fmt.Errorf("transparency entry for poll %s: %w", e.ID, err)
+		}
+	}
+	return nil
+}
+
+// VerifyTransparency checks, that the envelope has an entry of the
+// transparency log with the PEM encoded public key logPublicKey. See
+// rekor.Verify().
+//
+// It does not check the signature of the main key. Call Verify() for this.
+func (e ResultEnvelope) VerifyTransparency(logPublicKey []byte) error {
+	if e.Transparency == nil {
+		return fmt.Errorf("envelope for poll %s has no transparency entry", e.ID)
+	}
+
+	if err := rekor.Verify(*e.Transparency, e.Content, logPublicKey); err != nil {
+		return fmt.Errorf("transparency entry for poll %s: %w", e.ID, err)
+	}
+	return nil
+}
+
+// VerifyCoSignatures checks, that at least quorum of the trusted co-signing
+// keys have a valid co-signature in the envelope. Co-signatures of other keys
+// are ignored.
+//
+// It does not check the signature of the main key. Call Verify() for this.
+func (e ResultEnvelope) VerifyCoSignatures(trusted [][]byte, quorum int) error {
+	valid := make(map[string]bool)
+	for _, key := range trusted {
+		for _, cosignature := range e.CoSignatures {
+			if bytes.Equal(cosignature.PubKey, key) && decrypt.VerifyCoSignature(e.ID, e.Content, cosignature) {
+				valid[string(key)] = true
+				break
+			}
+		}
+	}
+
+	if len(valid) < quorum {
+		return fmt.Errorf("result of poll %s has %d valid co-signatures of trusted keys, expected %d", e.ID, len(valid), quorum)
+	}
+	return nil
+}
+
+// VerifyCertificate checks the certificate chain of the envelope with the
+// roots and the signature with the key of the certificate. If roots is nil,
+// the trust store of the system is used.
+//
+// The chain has to be valid now. Results of old polls can be checked with the
+// public main key and Verify().
+func (e ResultEnvelope) VerifyCertificate(roots *x509.CertPool) error {
+	if len(e.Certificates) == 0 {
+		return fmt.Errorf("envelope for poll %s has no certificate", e.ID)
+	}
+
+	publicMainKey, err := certificate.Verify(e.Certificates, roots, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return e.Verify(publicMainKey)
+}
+
+// VerifyResult decodes a canonical json envelope and verifies its signature
+// with the public main key. It returns the signed content.
+func VerifyResult(publicMainKey []byte, envelope []byte) ([]byte, error) {
+	var e ResultEnvelope
+	if err := json.Unmarshal(envelope, &e); err != nil {
+		return nil, fmt.Errorf("decoding envelope: %w", err)
+	}
+
+	if err := e.Verify(publicMainKey); err != nil {
+		return nil, err
+	}
+
+	return e.Content, nil
+}
@@ -0,0 +1,352 @@
+package grpc_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/certificate"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/merkle"
+	"github.com/OpenSlides/vote-decrypt/rekor"
+)
+
+func TestResultEnvelopeJSON(t *testing.T) {
+	envelope := grpc.ResultEnvelope{
+		ID:        "test/1",
+		Content:   []byte{0xfb, 0xff, 0x01},
+		Signature: []byte{0xfe, 0xf0},
+	}
+
+	got, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	expect := `{"content":"-_8B","id":"test/1","signature":"_vA"}`
+	if string(got) != expect {
+		t.Errorf("got %s, expected %s", got, expect)
+	}
+
+	var decoded grpc.ResultEnvelope
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if decoded.ID != envelope.ID || !bytes.Equal(decoded.Content, envelope.Content) || !bytes.Equal(decoded.Signature, envelope.Signature) {
+		t.Errorf("got %v after roundtrip, expected %v", decoded, envelope)
+	}
+
+	t.Run("with timestamp", func(t *testing.T) {
+		envelope.Timestamp = []byte{0x30, 0x03}
+
+		got, err := json.Marshal(envelope)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+
+		expect := `{"content":"-_8B","id":"test/1","signature":"_vA","timestamp":"MAM"}`
+		if string(got) != expect {
+			t.Errorf("got %s, expected %s", got, expect)
+		}
+
+		var decoded grpc.ResultEnvelope
+		if err := json.Unmarshal(got, &decoded); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+
+		if !bytes.Equal(decoded.Timestamp, envelope.Timestamp) {
+			t.Errorf("got timestamp %x after roundtrip, expected %x", decoded.Timestamp, envelope.Timestamp)
+		}
+	})
+	t.Run("with algorithm", func(t *testing.T) {
+		envelope := grpc.ResultEnvelope{
+			Algorithm: crypto.Ed25519,
+			ID:        "test/1",
+			Content:   []byte{0xfb, 0xff, 0x01},
+			Signature: []byte{0xfe, 0xf0},
+		}
+
+		got, err := json.Marshal(envelope)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+
+		expect := `{"algorithm":"ed25519","content":"-_8B","id":"test/1","signature":"_vA"}`
+		if string(got) != expect {
+			t.Errorf("got %s, expected %s", got, expect)
+		}
+
+		var decoded grpc.ResultEnvelope
+		if err := json.Unmarshal(got, &decoded); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+
+		if decoded.Algorithm != envelope.Algorithm {
+			t.Errorf("got algorithm %q after roundtrip, expected %q", decoded.Algorithm, envelope.Algorithm)
+		}
+	})
+
+	t.Run("with certificates and fingerprint", func(t *testing.T) {
+		envelope := grpc.ResultEnvelope{
+			Algorithm:    crypto.Ed25519,
+			Certificates: [][]byte{{0x30, 0x01}, {0x30, 0x02}},
+			ID:           "test/1",
+			Content:      []byte{0xfb, 0xff, 0x01},
+			Fingerprint:  "SHA256:abc",
+			Signature:    []byte{0xfe, 0xf0},
+		}
+
+		got, err := json.Marshal(envelope)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+
+		expect := `{"algorithm":"ed25519","certificates":["MAE","MAI"],"content":"-_8B","fingerprint":"SHA256:abc","id":"test/1","signature":"_vA"}`
+		if string(got) != expect {
+			t.Errorf("got %s, expected %s", got, expect)
+		}
+
+		var decoded grpc.ResultEnvelope
+		if err := json.Unmarshal(got, &decoded); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+
+		if decoded.Fingerprint != envelope.Fingerprint {
+			t.Errorf("got fingerprint %q after roundtrip, expected %q", decoded.Fingerprint, envelope.Fingerprint)
+		}
+
+		if len(decoded.Certificates) != 2 || !bytes.Equal(decoded.Certificates[1], envelope.Certificates[1]) {
+			t.Errorf("got certificates %x after roundtrip, expected %x", decoded.Certificates, envelope.Certificates)
+		}
+	})
+}
+
+func TestVerifyResult(t *testing.T) {
+	cr := crypto.New(make([]byte, 32), rand.Reader, nil)
+	content := []byte(`{"id":"test/1","votes":["Y"]}`)
+
+	signature, err := cr.Sign(content)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	encoded, err := json.Marshal(grpc.ResultEnvelope{ID: "test/1", Content: content, Signature: signature})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		got, err := grpc.VerifyResult(cr.PublicMainKey(), encoded)
+		if err != nil {
+			t.Fatalf("VerifyResult: %v", err)
+		}
+
+		if !bytes.Equal(got, content) {
+			t.Errorf("got content %s, expected %s", got, content)
+		}
+	})
+
+	t.Run("modified content", func(t *testing.T) {
+		modified, _ := json.Marshal(grpc.ResultEnvelope{ID: "test/1", Content: []byte(`{"id":"test/1","votes":["N"]}`), Signature: signature})
+
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), modified); err == nil {
+			t.Errorf("VerifyResult did not return an error")
+		}
+	})
+	t.Run("fingerprint", func(t *testing.T) {
+		withFingerprint, _ := json.Marshal(grpc.ResultEnvelope{ID: "test/1", Content: content, Fingerprint: decrypt.Fingerprint(cr.PublicMainKey()), Signature: signature})
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), withFingerprint); err != nil {
+			t.Errorf("VerifyResult: %v", err)
+		}
+
+		other, _ := json.Marshal(grpc.ResultEnvelope{ID: "test/1", Content: content, Fingerprint: decrypt.Fingerprint([]byte("other")), Signature: signature})
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), other); err == nil {
+			t.Errorf("VerifyResult with another fingerprint did not return an error")
+		}
+	})
+
+	t.Run("other algorithm", func(t *testing.T) {
+		other, _ := json.Marshal(grpc.ResultEnvelope{Algorithm: crypto.ECDSAP256SHA256, ID: "test/1", Content: content, Signature: signature})
+
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), other); err == nil {
+			t.Errorf("VerifyResult with the wrong algorithm did not return an error")
+		}
+	})
+
+	t.Run("poll signing key", func(t *testing.T) {
+		pollKey := make([]byte, 32)
+		pollSigningKey, delegation, err := cr.PollSigningKey(pollKey, "test/1")
+		if err != nil {
+			t.Fatalf("PollSigningKey: %v", err)
+		}
+
+		pollSignature, err := cr.SignPoll(pollKey, "test/1", content)
+		if err != nil {
+			t.Fatalf("SignPoll: %v", err)
+		}
+
+		envelope := grpc.ResultEnvelope{ID: "test/1", Content: content, Delegation: delegation, PollSigningKey: pollSigningKey, Signature: pollSignature}
+		encoded, _ := json.Marshal(envelope)
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), encoded); err != nil {
+			t.Errorf("VerifyResult: %v", err)
+		}
+
+		envelope.ID = "test/2"
+		otherPoll, _ := json.Marshal(envelope)
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), otherPoll); err == nil {
+			t.Errorf("VerifyResult with the delegation of another poll did not return an error")
+		}
+
+		withoutDelegation, _ := json.Marshal(grpc.ResultEnvelope{ID: "test/1", Content: content, PollSigningKey: pollSigningKey, Signature: pollSignature})
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), withoutDelegation); err == nil {
+			t.Errorf("VerifyResult without delegation did not return an error")
+		}
+	})
+
+	t.Run("meeting signing key", func(t *testing.T) {
+		secret := make([]byte, 32)
+		meetingSigningKey, delegation, err := cr.MeetingSigningKey(secret, "test")
+		if err != nil {
+			t.Fatalf("MeetingSigningKey: %v", err)
+		}
+
+		meetingSignature, err := cr.SignMeeting(secret, "test", content)
+		if err != nil {
+			t.Fatalf("SignMeeting: %v", err)
+		}
+
+		envelope := grpc.ResultEnvelope{ID: "test/1", Content: content, Delegation: delegation, Meeting: "test", PollSigningKey: meetingSigningKey, Signature: meetingSignature}
+		encoded, _ := json.Marshal(envelope)
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), encoded); err != nil {
+			t.Errorf("VerifyResult: %v", err)
+		}
+
+		envelope.ID = "other/1"
+		otherMeeting, _ := json.Marshal(envelope)
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), otherMeeting); err == nil {
+			t.Errorf("VerifyResult for a poll of another meeting did not return an error")
+		}
+
+		withoutMeeting, _ := json.Marshal(grpc.ResultEnvelope{ID: "test/1", Content: content, Delegation: delegation, PollSigningKey: meetingSigningKey, Signature: meetingSignature})
+		if _, err := grpc.VerifyResult(cr.PublicMainKey(), withoutMeeting); err == nil {
+			t.Errorf("VerifyResult with a meeting delegation as poll delegation did not return an error")
+		}
+	})
+
+	t.Run("transparency entry", func(t *testing.T) {
+		hash := sha256.Sum256(content)
+		body := []byte(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":"` + hex.EncodeToString(hash[:]) + `"}}}}`)
+		entry := &rekor.Entry{UUID: "uuid1", LogIndex: 7, Body: body, TreeSize: 1, RootHash: merkle.Root([][]byte{body})}
+
+		encoded, _ := json.Marshal(grpc.ResultEnvelope{ID: "test/1", Content: content, Signature: signature, Transparency: entry})
+		var decoded grpc.ResultEnvelope
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+
+		if decoded.Transparency == nil || decoded.Transparency.UUID != "uuid1" || decoded.Transparency.LogIndex != 7 {
+			t.Fatalf("got transparency entry %v", decoded.Transparency)
+		}
+
+		if err := decoded.Verify(cr.PublicMainKey()); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+
+		decoded.Transparency.RootHash = merkle.Root([][]byte{[]byte("other")})
+		if err := decoded.Verify(cr.PublicMainKey()); err == nil {
+			t.Errorf("Verify with an invalid inclusion proof did not return an error")
+		}
+
+		withoutEntry := grpc.ResultEnvelope{ID: "test/1", Content: content, Signature: signature}
+		if err := withoutEntry.VerifyTransparency(nil); err == nil {
+			t.Errorf("VerifyTransparency without an entry did not return an error")
+		}
+	})
+
+	t.Run("co-signatures", func(t *testing.T) {
+		trusted1, key1, _ := ed25519.GenerateKey(nil)
+		trusted2, key2, _ := ed25519.GenerateKey(nil)
+		other, otherKey, _ := ed25519.GenerateKey(nil)
+
+		cosign := func(pubKey ed25519.PublicKey, key ed25519.PrivateKey) decrypt.CoSignature {
+			return decrypt.CoSignature{PubKey: pubKey, Signature: ed25519.Sign(key, decrypt.CoSignatureMessage("test/1", content))}
+		}
+
+		envelope := grpc.ResultEnvelope{ID: "test/1", Content: content, Signature: signature, CoSignatures: []decrypt.CoSignature{
+			cosign(trusted1, key1),
+			cosign(other, otherKey),
+			{PubKey: trusted2, Signature: ed25519.Sign(key2, content)},
+		}}
+
+		encoded, _ := json.Marshal(envelope)
+		var decoded grpc.ResultEnvelope
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+
+		if err := decoded.Verify(cr.PublicMainKey()); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+
+		trusted := [][]byte{trusted1, trusted2}
+		if err := decoded.VerifyCoSignatures(trusted, 1); err != nil {
+			t.Errorf("VerifyCoSignatures with quorum 1: %v", err)
+		}
+
+		if err := decoded.VerifyCoSignatures(trusted, 2); err == nil {
+			t.Errorf("VerifyCoSignatures with an invalid co-signature of a trusted key did not return an error")
+		}
+
+		if err := decoded.VerifyCoSignatures([][]byte{trusted1, trusted1}, 2); err == nil {
+			t.Errorf("VerifyCoSignatures counted a trusted key twice")
+		}
+	})
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	mainKey := make([]byte, 32)
+	cr := crypto.New(mainKey, rand.Reader, nil)
+	content := []byte(`{"id":"test/1","votes":["Y"]}`)
+
+	signature, err := cr.Sign(content)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	key, err := crypto.PrivateMainKey(mainKey, false)
+	if err != nil {
+		t.Fatalf("PrivateMainKey: %v", err)
+	}
+
+	der, err := certificate.SelfSigned(key, "vote-decrypt", time.Hour)
+	if err != nil {
+		t.Fatalf("SelfSigned: %v", err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	envelope := grpc.ResultEnvelope{Certificates: [][]byte{der}, ID: "test/1", Content: content, Signature: signature}
+	if err := envelope.VerifyCertificate(roots); err != nil {
+		t.Errorf("VerifyCertificate: %v", err)
+	}
+
+	if err := envelope.VerifyCertificate(x509.NewCertPool()); err == nil {
+		t.Errorf("VerifyCertificate with other roots did not fail")
+	}
+
+	envelope.Content = []byte(`{"id":"test/1","votes":["N"]}`)
+	if err := envelope.VerifyCertificate(roots); err == nil {
+		t.Errorf("VerifyCertificate with modified content did not fail")
+	}
+}
@@ -0,0 +1,79 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/store"
+	"google.golang.org/protobuf/proto"
+)
+
+// fuzzMethod returns a function, that decodes a protobuf message and calls
+// a grpc method with it. Invalid messages are ignored, like grpc does.
+func fuzzMethod[Req proto.Message, Resp any](newReq func() Req, method func(context.Context, Req) (Resp, error)) func(context.Context, []byte) {
+	return func(ctx context.Context, data []byte) {
+		req := newReq()
+		if err := proto.Unmarshal(data, req); err != nil {
+			return
+		}
+		method(ctx, req)
+	}
+}
+
+// FuzzServer calls the grpc methods with arbitrary messages. It only checks,
+// that the server does not panic. Run it with `go test ./grpc -fuzz
+// FuzzServer`.
+func FuzzServer(f *testing.F) {
+	log.SetOutput(io.Discard)
+	f.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	d := decrypt.New(
+		crypto.New(make([]byte, 32), rand.Reader, nil),
+		store.New(f.TempDir()),
+	)
+	s := grpcServer{decrypt: d}
+
+	methods := []func(context.Context, []byte){
+		fuzzMethod(func() *StartRequest { return new(StartRequest) }, s.Start),
+		fuzzMethod(func() *StopRequest { return new(StopRequest) }, s.Stop),
+		fuzzMethod(func() *CoSignRequest { return new(CoSignRequest) }, s.CoSign),
+		fuzzMethod(func() *ClearRequest { return new(ClearRequest) }, s.Clear),
+		fuzzMethod(func() *StatusRequest { return new(StatusRequest) }, s.Status),
+		fuzzMethod(func() *StartElectionRequest { return new(StartElectionRequest) }, s.StartElection),
+		fuzzMethod(func() *StopElectionRequest { return new(StopElectionRequest) }, s.StopElection),
+		fuzzMethod(func() *PollSigningKeyRequest { return new(PollSigningKeyRequest) }, s.PollSigningKey),
+		fuzzMethod(func() *MeetingSigningKeyRequest { return new(MeetingSigningKeyRequest) }, s.MeetingSigningKey),
+		fuzzMethod(func() *InclusionProofRequest { return new(InclusionProofRequest) }, s.InclusionProof),
+		fuzzMethod(func() *PartialDecryptRequest { return new(PartialDecryptRequest) }, s.PartialDecrypt),
+		fuzzMethod(func() *IssueTokenRequest { return new(IssueTokenRequest) }, s.IssueToken),
+		fuzzMethod(func() *NoDecryptionRequest { return new(NoDecryptionRequest) }, s.NoDecryption),
+		fuzzMethod(func() *ImportKeyRequest { return new(ImportKeyRequest) }, s.ImportKey),
+		fuzzMethod(func() *RekeyRequest { return new(RekeyRequest) }, s.Rekey),
+		fuzzMethod(func() *RevokeRequest { return new(RevokeRequest) }, s.Revoke),
+		fuzzMethod(func() *CheckMainKeyRequest { return new(CheckMainKeyRequest) }, s.CheckMainKey),
+		fuzzMethod(func() *SetLogLevelRequest { return new(SetLogLevelRequest) }, s.SetLogLevel),
+	}
+
+	for i, msg := range []proto.Message{
+		&StartRequest{Id: "1"},
+		&StopRequest{Id: "1", Votes: [][]byte{[]byte("vote")}},
+		&ClearRequest{Id: "1"},
+		&StatusRequest{Id: "1"},
+	} {
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			f.Fatalf("encoding seed: %v", err)
+		}
+		f.Add(uint8(i), data)
+	}
+
+	f.Fuzz(func(t *testing.T, method uint8, data []byte) {
+		methods[int(method)%len(methods)](context.Background(), data)
+	})
+}
@@ -5,25 +5,311 @@ package grpc
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/OpenSlides/vote-decrypt/crypto"
 	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/loglevel"
+	"github.com/OpenSlides/vote-decrypt/security"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+// adminMethods are the grpc methods that need the admin token.
+var adminMethods = map[string]bool{
+	"/Decrypt/Wipe":            true,
+	"/Decrypt/SetReadOnly":     true,
+	"/Decrypt/ExportKey":       true,
+	"/Decrypt/ImportKey":       true,
+	"/Decrypt/Revoke":          true,
+	"/Decrypt/Rekey":           true,
+	"/Decrypt/ExportAuditLog":  true,
+	"/Decrypt/EffectiveConfig": true,
+	"/Decrypt/SetLogLevel":     true,
+}
+
+// progressInterval is the time between two progress messages of StopMany.
+const progressInterval = 200 * time.Millisecond
+
+// ServerOption for RunServer().
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	adminToken         string
+	quotas             []Quota
+	keepalive          Keepalive
+	requestTimeout     time.Duration
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	timestamper        Timestamper
+	uploader           ResultUploader
+	certificateChain   [][]byte
+	securityLog        SecurityLog
+	effectiveConfig    []byte
+	logLevel           LogLevel
+	roles              []RoleBinding
+	policy             Policy
+	cosigners          []CoSigner
+	cosignQuorum       int
+	transparencyLog    TransparencyLog
+	ready              func()
+}
+
+// WithReady calls f, when the server listens on its address and is ready for
+// requests.
+func WithReady(f func()) ServerOption {
+	return func(c *serverConfig) {
+		c.ready = f
+	}
+}
+
+// WithAdminToken sets the token that is needed to call admin methods like
+// Wipe. The token has to be send as grpc metadata `authorization` in the form
+// `Bearer TOKEN`.
+//
+// If no admin token is set, the admin methods are disabled.
+func WithAdminToken(token string) ServerOption {
+	return func(c *serverConfig) {
+		c.adminToken = token
+	}
+}
+
+// Keepalive configures, how the server handles idle connections.
+//
+// The zero value of each field uses the default of grpc-go.
+type Keepalive struct {
+	// Time after which the server pings an idle client.
+	Time time.Duration
+
+	// Timeout for the response of a ping.
+	Timeout time.Duration
+
+	// MaxConnectionIdle is the time after which an idle connection is closed.
+	MaxConnectionIdle time.Duration
+
+	// MinTime is the minimum time between two pings of a client. Clients that
+	// ping more often, are disconnected.
+	MinTime time.Duration
+
+	// PermitWithoutStream allows pings of clients, when there is no active
+	// call.
+	PermitWithoutStream bool
+}
+
+// WithKeepalive sets the keepalive configuration of the server.
+//
+// Load balancers often drop idle connections. The client can prevent this
+// with pings, if the server permits them.
+func WithKeepalive(k Keepalive) ServerOption {
+	return func(c *serverConfig) {
+		c.keepalive = k
+	}
+}
+
+// WithRequestTimeout sets a deadline for each call, that was sent without a
+// deadline.
+func WithRequestTimeout(timeout time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.requestTimeout = timeout
+	}
+}
+
+// Timestamper returns a timestamp token for data, for example from a RFC 3161
+// time stamping authority. See package tsa.
+type Timestamper interface {
+	Timestamp(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// WithTimestamper adds a timestamp token for the signature of each result to
+// the response of Stop.
+//
+// If the timestamper fails, the result is returned without a token. Stop can
+// be called again to get one.
+func WithTimestamper(t Timestamper) ServerOption {
+	return func(c *serverConfig) {
+		c.timestamper = t
+	}
+}
+
+// ResultUploader saves the result of a poll and returns an url to download
+// it. See package objectstore.
+type ResultUploader interface {
+	Upload(ctx context.Context, pollID string, content []byte) (url string, err error)
+}
+
+// WithResultUploader enables uploads of results. If a Stop request asks for
+// it, the result is uploaded and only its url, hash and signature are
+// returned.
+func WithResultUploader(u ResultUploader) ServerOption {
+	return func(c *serverConfig) {
+		c.uploader = u
+	}
+}
+
+// WithCertificateChain sets the X.509 certificate of the main key and its
+// intermediate certificates in the DER form. The chain is sent with the public
+// main key and the response of Stop. See package certificate.
+func WithCertificateChain(chain [][]byte) ServerOption {
+	return func(c *serverConfig) {
+		c.certificateChain = chain
+	}
+}
+
+// WithEffectiveConfig sets the json, that is returned by the admin method
+// EffectiveConfig. Secrets have to be redacted by the caller.
+//
+// Without this option, EffectiveConfig returns the code Unimplemented.
+func WithEffectiveConfig(config []byte) ServerOption {
+	return func(c *serverConfig) {
+		c.effectiveConfig = config
+	}
+}
+
+// LogLevel changes the level of the log at runtime. See package loglevel.
+type LogLevel interface {
+	SetLevel(level loglevel.Level) (previous loglevel.Level)
+}
+
+// WithLogLevel enables the admin method SetLogLevel.
+//
+// Without this option, SetLogLevel returns the code Unimplemented.
+func WithLogLevel(l LogLevel) ServerOption {
+	return func(c *serverConfig) {
+		c.logLevel = l
+	}
+}
+
+// SecurityLog receives security events like failed authentications. See
+// package security.
+type SecurityLog interface {
+	Report(event security.Event)
+}
+
+// WithSecurityLog reports calls with an invalid admin token, requests with an
+// invalid signature and calls, that exceed a quota, to the security log.
+func WithSecurityLog(l SecurityLog) ServerOption {
+	return func(c *serverConfig) {
+		c.securityLog = l
+	}
+}
+
+// nopSecurityLog is the SecurityLog, if none is configured.
+type nopSecurityLog struct{}
+
+func (nopSecurityLog) Report(security.Event) {}
+
+// WithUnaryInterceptors adds interceptors for unary grpc methods.
+//
+// They are called in the given order after the builtin interceptors for quotas
+// and admin methods.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) ServerOption {
+	return func(c *serverConfig) {
+		c.unaryInterceptors = append(c.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors adds interceptors for streaming grpc methods.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) ServerOption {
+	return func(c *serverConfig) {
+		c.streamInterceptors = append(c.streamInterceptors, interceptors...)
+	}
+}
+
+// interceptors returns the builtin interceptors and the interceptors of the
+// options in the order, in which they are called.
+//
+// The caller is authenticated before the quota is counted, so callers without
+// access can not use up the quota of other callers.
+func (c serverConfig) interceptors(decrypt *decrypt.Decrypt) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
+	limiter := newQuotaLimiter(c.quotas)
+
+	// With roles, the role interceptor replaces the admin interceptor.
+	authInterceptor := adminInterceptor(c.adminToken, c.securityLog)
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		localizeStreamInterceptor,
+		identityStreamInterceptor(c.adminToken),
+	}
+	if len(c.roles) > 0 {
+		authInterceptor = roleInterceptor(c.roles, c.securityLog)
+		streamInterceptors = append(streamInterceptors, roleStreamInterceptor(c.roles, c.securityLog))
+	}
+	streamInterceptors = append(streamInterceptors, quotaStreamInterceptor(limiter, c.securityLog))
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		localizeInterceptor,
+		identityInterceptor(c.adminToken),
+		timeoutInterceptor(c.requestTimeout),
+		authInterceptor,
+		quotaInterceptor(limiter, c.securityLog),
+	}
+	if c.policy != nil {
+		unaryInterceptors = append(unaryInterceptors, policyInterceptor(c.policy, decrypt, c.securityLog))
+		streamInterceptors = append(streamInterceptors, policyStreamInterceptor(c.policy, decrypt, c.securityLog))
+	}
+	unaryInterceptors = append(unaryInterceptors, signatureInterceptor(c.securityLog))
+	unaryInterceptors = append(unaryInterceptors, c.unaryInterceptors...)
+	streamInterceptors = append(streamInterceptors, c.streamInterceptors...)
+
+	// The read only check is the last one, so the interceptor of the leader
+	// election can send the client to the leader first.
+	unaryInterceptors = append(unaryInterceptors, readOnlyInterceptor(decrypt.ReadOnly))
+	streamInterceptors = append(streamInterceptors, readOnlyStreamInterceptor(decrypt.ReadOnly))
+
+	return unaryInterceptors, streamInterceptors
+}
+
 // RunServer runs a grpc server on the given addr until ctx is done.
-func RunServer(ctx context.Context, decrypt *decrypt.Decrypt, addr string) error {
+func RunServer(ctx context.Context, decrypt *decrypt.Decrypt, addr string, options ...ServerOption) error {
+	config := serverConfig{securityLog: nopSecurityLog{}}
+	for _, o := range options {
+		o(&config)
+	}
+
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("listen on address %q: %w", addr, err)
 	}
 
-	registrar := grpc.NewServer()
-	RegisterDecryptServer(registrar, grpcServer{decrypt})
+	unaryInterceptors, streamInterceptors := config.interceptors(decrypt)
+
+	registrar := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:              config.keepalive.Time,
+			Timeout:           config.keepalive.Timeout,
+			MaxConnectionIdle: config.keepalive.MaxConnectionIdle,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             config.keepalive.MinTime,
+			PermitWithoutStream: config.keepalive.PermitWithoutStream,
+		}),
+	)
+	RegisterDecryptServer(registrar, grpcServer{
+		decrypt:          decrypt,
+		timestamper:      config.timestamper,
+		uploader:         config.uploader,
+		certificateChain: config.certificateChain,
+		effectiveConfig:  config.effectiveConfig,
+		logLevel:         config.logLevel,
+		cosigners:        config.cosigners,
+		cosignQuorum:     config.cosignQuorum,
+		transparencyLog:  config.transparencyLog,
+	})
 
 	wait := make(chan struct{})
 	go func() {
@@ -33,6 +319,9 @@ func RunServer(ctx context.Context, decrypt *decrypt.Decrypt, addr string) error
 	}()
 
 	log.Printf("Running grpc server on %s\n", addr)
+	if config.ready != nil {
+		config.ready()
+	}
 	if err := registrar.Serve(lis); err != nil {
 		return fmt.Errorf("running grpc server: %w", err)
 	}
@@ -42,6 +331,77 @@ func RunServer(ctx context.Context, decrypt *decrypt.Decrypt, addr string) error
 	return nil
 }
 
+// timeoutInterceptor returns a grpc interceptor that sets a deadline for calls
+// without a deadline. With a timeout of 0, nothing is changed.
+func timeoutInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+			return handler(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// adminInterceptor returns a grpc interceptor that makes sure, that the admin
+// methods are only called with the admin token.
+func adminInterceptor(adminToken string, securityLog SecurityLog) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !adminMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		if adminToken == "" {
+			securityLog.Report(security.Event{
+				Kind:   security.AuthFailure,
+				Source: callerAddr(ctx),
+				Method: methodName(info.FullMethod),
+				Reason: "admin methods are disabled",
+			})
+			return nil, status.Error(codes.PermissionDenied, "admin methods are disabled")
+		}
+
+		var token string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("authorization"); len(values) > 0 {
+				token = strings.TrimPrefix(values[0], "Bearer ")
+			}
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			log.Printf("Invalid admin token for %s", info.FullMethod)
+			securityLog.Report(security.Event{
+				Kind:   security.AuthFailure,
+				Source: callerAddr(ctx),
+				Method: methodName(info.FullMethod),
+				Reason: "invalid admin token",
+			})
+			return nil, status.Error(codes.Unauthenticated, "invalid admin token")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// signatureInterceptor returns a grpc interceptor that reports requests, that
+// were rejected because of a missing or invalid signature.
+func signatureInterceptor(securityLog SecurityLog) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if status.Code(err) == codes.PermissionDenied {
+			securityLog.Report(security.Event{
+				Kind:   security.SignatureFailure,
+				Source: callerAddr(ctx),
+				Method: methodName(info.FullMethod),
+				Reason: status.Convert(err).Message(),
+			})
+		}
+		return resp, err
+	}
+}
+
 // Client holds the connection to a decrypt server.
 //
 // This is not needed vote vote-decrypt but is used by the vote-service.
@@ -49,11 +409,50 @@ type Client struct {
 	decryptClient DecryptClient
 }
 
+// ClientOption for NewClient().
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	retry      RetryPolicy
+	hedgeDelay time.Duration
+	breaker    *circuitBreaker
+	keepalive  time.Duration
+	language   string
+}
+
+// WithClientKeepalive pings the server, after the connection was idle for
+// interval. This keeps long lived connections open, that would otherwise be
+// closed by load balancers. The server has to permit the pings with
+// WithKeepalive().
+func WithClientKeepalive(interval time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.keepalive = interval
+	}
+}
+
 // NewClient creates a connection to a decrypt grpc server and wrapps then
 // into a decrypt.crypto interface.
-func NewClient(addr string) (*Client, func() error, error) {
+//
+// Without options, failed calls are not retried.
+func NewClient(addr string, options ...ClientOption) (*Client, func() error, error) {
+	var config clientConfig
+	for _, o := range options {
+		o(&config)
+	}
+
 	// TODO: use secure connection
-	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	dialOptions := []grpc.DialOption{grpc.WithInsecure(), grpc.WithUnaryInterceptor(config.unaryInterceptor())}
+	if config.language != "" {
+		dialOptions = append(dialOptions, grpc.WithStreamInterceptor(config.streamInterceptor()))
+	}
+	if config.keepalive > 0 {
+		dialOptions = append(dialOptions, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                config.keepalive,
+			PermitWithoutStream: true,
+		}))
+	}
+
+	conn, err := grpc.Dial(addr, dialOptions...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("creating connection to decrypt service: %w", err)
 	}
@@ -71,9 +470,48 @@ func (c *Client) PublicMainKey(ctx context.Context) ([]byte, error) {
 	return resp.PublicKey, nil
 }
 
+// MainKeyCertificate calls the PublicMainKey grpc method and returns the
+// certificate chain of the main key in the DER form. It is empty, if the
+// server has no certificate.
+func (c *Client) MainKeyCertificate(ctx context.Context) ([][]byte, error) {
+	resp, err := c.decryptClient.PublicMainKey(ctx, &EmptyMessage{})
+	if err != nil {
+		return nil, fmt.Errorf("sending grpc request: %w", err)
+	}
+
+	return resp.CertificateChain, nil
+}
+
+// MainKeyAlgorithm calls the PublicMainKey grpc method and returns the
+// signature algorithm of the main key.
+//
+// Older servers do not send the algorithm. In this case, it is detected from
+// the public key.
+func (c *Client) MainKeyAlgorithm(ctx context.Context) (crypto.Algorithm, error) {
+	resp, err := c.decryptClient.PublicMainKey(ctx, &EmptyMessage{})
+	if err != nil {
+		return "", fmt.Errorf("sending grpc request: %w", err)
+	}
+
+	if resp.Algorithm != "" {
+		return crypto.Algorithm(resp.Algorithm), nil
+	}
+	return crypto.KeyAlgorithm(resp.PublicKey)
+}
+
 // Start calls the Start grpc message.
-func (c *Client) Start(ctx context.Context, pollID string) (pubKey []byte, pubKeySig []byte, err error) {
-	resp, err := c.decryptClient.Start(ctx, &StartRequest{Id: pollID})
+func (c *Client) Start(ctx context.Context, pollID string, options ...decrypt.StartOption) (pubKey []byte, pubKeySig []byte, err error) {
+	var config decrypt.StartConfig
+	for _, o := range options {
+		o(&config)
+	}
+
+	req := &StartRequest{Id: pollID, Metadata: config.Metadata, Trustee: config.Trustee, Tokens: config.Tokens, Ring: config.Ring, Revote: RevotePolicy(config.Revote)}
+	if config.NotBefore != nil {
+		req.NotBefore = config.NotBefore.Unix()
+	}
+
+	resp, err := c.decryptClient.Start(ctx, req)
 	if err != nil {
 		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
 	}
@@ -82,14 +520,104 @@ func (c *Client) Start(ctx context.Context, pollID string) (pubKey []byte, pubKe
 }
 
 // Stop calls the Stop grpc message.
-func (c *Client) Stop(ctx context.Context, pollID string, voteList [][]byte) (decryptedContent, signature []byte, err error) {
-	resp, err := c.decryptClient.Stop(ctx, &StopRequest{Id: pollID, Votes: voteList})
+func (c *Client) Stop(ctx context.Context, pollID string, voteList [][]byte, options ...decrypt.StopOption) (decryptedContent, signature []byte, err error) {
+	resp, err := c.stop(ctx, pollID, voteList, false, options...)
 	if err != nil {
-		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
+		return nil, nil, err
 	}
 	return resp.Votes, resp.Signature, nil
 }
 
+// ResultLocation is the response of StopUpload. The result can be downloaded
+// from URL. It has the sha256 hash Hash.
+type ResultLocation struct {
+	URL       string
+	Hash      []byte
+	Signature []byte
+	Timestamp []byte
+}
+
+// StopUpload calls the Stop grpc message and asks the server to upload the
+// result to the object storage instead of sending it in the response.
+//
+// The result can be downloaded with objectstore.Fetch(). Its signature has to
+// be checked like the result of Stop.
+func (c *Client) StopUpload(ctx context.Context, pollID string, voteList [][]byte, options ...decrypt.StopOption) (ResultLocation, error) {
+	resp, err := c.stop(ctx, pollID, voteList, true, options...)
+	if err != nil {
+		return ResultLocation{}, err
+	}
+
+	return ResultLocation{
+		URL:       resp.ResultUrl,
+		Hash:      resp.ResultHash,
+		Signature: resp.Signature,
+		Timestamp: resp.Timestamp,
+	}, nil
+}
+
+// StopEnvelope calls the Stop grpc message and returns the result as
+// ResultEnvelope. Its json encoding can be published for consumers that do not
+// use protobuf.
+//
+// If the server is configured with a time stamping authority, the envelope
+// contains the timestamp token. If it is configured with a certificate for the
+// main key, the envelope contains the certificate chain. If it is configured
+// with co-signers, the envelope contains their co-signatures and with a
+// transparency log the entry of the result.
+func (c *Client) StopEnvelope(ctx context.Context, pollID string, voteList [][]byte, options ...decrypt.StopOption) (ResultEnvelope, error) {
+	resp, err := c.stop(ctx, pollID, voteList, false, options...)
+	if err != nil {
+		return ResultEnvelope{}, err
+	}
+
+	return ResultEnvelope{
+		Algorithm:      crypto.Algorithm(resp.SignatureAlgorithm),
+		Certificates:   resp.CertificateChain,
+		ID:             pollID,
+		Content:        resp.Votes,
+		CoSignatures:   coSignatures(resp.Cosignatures),
+		Fingerprint:    resp.MainKeyFingerprint,
+		Delegation:     resp.Delegation,
+		Meeting:        resp.Meeting,
+		PollSigningKey: resp.PollSigningKey,
+		Signature:      resp.Signature,
+		Timestamp:      resp.Timestamp,
+		Transparency:   rekorEntry(resp.Transparency),
+	}, nil
+}
+
+func (c *Client) stop(ctx context.Context, pollID string, voteList [][]byte, upload bool, options ...decrypt.StopOption) (*StopResponse, error) {
+	resp, err := c.decryptClient.Stop(ctx, newStopRequest(pollID, voteList, upload, options...))
+	if err != nil {
+		return nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+	return resp, nil
+}
+
+// newStopRequest builds the grpc message of a stop call.
+func newStopRequest(pollID string, voteList [][]byte, upload bool, options ...decrypt.StopOption) *StopRequest {
+	var config decrypt.StopConfig
+	for _, o := range options {
+		o(&config)
+	}
+
+	var tags []*VoteTag
+	for _, tag := range config.Tags {
+		tags = append(tags, &VoteTag{Pseudonym: tag.Pseudonym, TimeMs: tag.Time.UnixMilli()})
+	}
+
+	return &StopRequest{
+		Id:        pollID,
+		Votes:     voteList,
+		Weights:   config.Weights,
+		Signature: config.Signature,
+		Upload:    upload,
+		Priority:  int32(config.Priority),
+		Tags:      tags,
+	}
+}
+
 // Clear calls the Clear grpc message.
 func (c *Client) Clear(ctx context.Context, pollID string) error {
 	_, err := c.decryptClient.Clear(ctx, &ClearRequest{Id: pollID})
@@ -100,60 +628,1157 @@ func (c *Client) Clear(ctx context.Context, pollID string) error {
 	return nil
 }
 
-type grpcServer struct {
-	decrypt *decrypt.Decrypt
+// Status calls the Status grpc message.
+func (c *Client) Status(ctx context.Context, pollID string) (decrypt.PollStatus, error) {
+	resp, err := c.decryptClient.Status(ctx, &StatusRequest{Id: pollID})
+	if err != nil {
+		return decrypt.PollStatus{}, fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	status := decrypt.PollStatus{
+		PubKey:            resp.PubKey,
+		PubKeySig:         resp.PubSig,
+		Metadata:          resp.Metadata,
+		Fingerprint:       resp.Fingerprint,
+		Election:          resp.Election,
+		Meeting:           resp.Meeting,
+		MeetingSigningKey: resp.MeetingSigningKey,
+		MeetingDelegation: resp.MeetingDelegation,
+	}
+	for _, feature := range resp.Features {
+		status.Features = append(status.Features, decrypt.Feature(feature))
+	}
+	if resp.NotBefore != 0 {
+		status.NotBefore = time.Unix(resp.NotBefore, 0).UTC()
+	}
+	return status, nil
 }
 
-// grpcError converts an error to a grpc error.
-func (s grpcServer) grpcError(err error) error {
-	// TODO: Set the logger on initialization.
-	log.Printf("GRPC: %v", err)
+// PublicKeys calls the PublicKeys grpc message.
+func (c *Client) PublicKeys(ctx context.Context) ([]decrypt.PollPublicKey, error) {
+	resp, err := c.decryptClient.PublicKeys(ctx, &EmptyMessage{})
+	if err != nil {
+		return nil, fmt.Errorf("sending grpc message: %w", err)
+	}
 
-	// Currently, all errors are internal
-	return status.Error(codes.Internal, "Ups, someting went wrong!")
+	keys := make([]decrypt.PollPublicKey, len(resp.Keys))
+	for i, key := range resp.Keys {
+		keys[i] = decrypt.PollPublicKey{
+			ID:          key.Id,
+			PubKey:      key.PubKey,
+			PubKeySig:   key.PubSig,
+			Fingerprint: key.Fingerprint,
+		}
+	}
+	return keys, nil
 }
 
-func (s grpcServer) Start(ctx context.Context, req *StartRequest) (*StartResponse, error) {
-	log.Printf("Start request for id %s", req.Id)
-	pubKey, pubKeySig, err := s.decrypt.Start(ctx, req.Id)
+// StartElection calls the StartElection grpc message.
+func (c *Client) StartElection(ctx context.Context, electionID string, pollIDs []string, options ...decrypt.StartOption) ([]decrypt.PollPublicKey, error) {
+	var config decrypt.StartConfig
+	for _, o := range options {
+		o(&config)
+	}
+
+	req := &StartElectionRequest{Id: electionID, PollIds: pollIDs, Metadata: config.Metadata}
+	if config.NotBefore != nil {
+		req.NotBefore = config.NotBefore.Unix()
+	}
+
+	resp, err := c.decryptClient.StartElection(ctx, req)
 	if err != nil {
-		return nil, s.grpcError(fmt.Errorf("starting vote: %w", err))
+		return nil, fmt.Errorf("sending grpc message: %w", err)
 	}
 
-	return &StartResponse{
-		PubKey: pubKey,
-		PubSig: pubKeySig,
+	keys := make([]decrypt.PollPublicKey, len(resp.Keys))
+	for i, key := range resp.Keys {
+		keys[i] = decrypt.PollPublicKey{
+			ID:          key.Id,
+			PubKey:      key.PubKey,
+			PubKeySig:   key.PubSig,
+			Fingerprint: key.Fingerprint,
+		}
+	}
+	return keys, nil
+}
+
+// StopElection calls the StopElection grpc message.
+//
+// The report is a json encoded decrypt.ElectionReport. The signature is
+// created with the main key over the report.
+func (c *Client) StopElection(ctx context.Context, electionID string, polls []decrypt.ElectionPoll) (report, signature []byte, err error) {
+	req := &StopElectionRequest{Id: electionID, Polls: make([]*StopRequest, len(polls))}
+	for i, poll := range polls {
+		req.Polls[i] = newStopRequest(poll.ID, poll.Votes, false, poll.Options...)
+	}
+
+	resp, err := c.decryptClient.StopElection(ctx, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return resp.Report, resp.Signature, nil
+}
+
+// StopMany calls the StopMany grpc message. It stops many polls in one call.
+//
+// If progress is not nil, it is called for each progress message of the
+// server. The results are in the order of polls. A poll, that could not be
+// stopped, has an error with the grpc code and message of the failure.
+func (c *Client) StopMany(ctx context.Context, polls []decrypt.ElectionPoll, progress func(decrypt.Progress)) ([]decrypt.StopManyResult, error) {
+	req := &StopManyRequest{Polls: make([]*StopRequest, len(polls))}
+	for i, poll := range polls {
+		req.Polls[i] = newStopRequest(poll.ID, poll.Votes, false, poll.Options...)
+	}
+
+	stream, err := c.decryptClient.StopMany(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	var results []decrypt.StopManyResult
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("receiving grpc message: %w", err)
+		}
+
+		if p := resp.GetProgress(); p != nil && progress != nil {
+			progress(decrypt.Progress{PollID: p.Id, Decrypted: int(p.Decrypted), Total: int(p.Total)})
+		}
+
+		if r := resp.GetResult(); r != nil {
+			result := decrypt.StopManyResult{ID: r.Id, Content: r.Votes, Signature: r.Signature}
+			if r.Code != 0 {
+				result.Err = stopManyError(r)
+			}
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// ClearElection calls the ClearElection grpc message.
+func (c *Client) ClearElection(ctx context.Context, electionID string) error {
+	if _, err := c.decryptClient.ClearElection(ctx, &ClearElectionRequest{Id: electionID}); err != nil {
+		return fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return nil
+}
+
+// PollSigningKey calls the PollSigningKey grpc message. It returns the public
+// poll signing key of a poll and its delegation. The delegation has to be
+// checked with crypto.VerifyDelegation() and the public main key.
+func (c *Client) PollSigningKey(ctx context.Context, pollID string) (pubKey, delegation []byte, err error) {
+	resp, err := c.decryptClient.PollSigningKey(ctx, &PollSigningKeyRequest{Id: pollID})
+	if err != nil {
+		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+	return resp.PubKey, resp.Delegation, nil
+}
+
+// MeetingSigningKey calls the MeetingSigningKey grpc message. It returns the
+// public meeting signing key of a meeting and its delegation. The delegation
+// has to be checked with crypto.VerifyMeetingDelegation() and the public main
+// key.
+func (c *Client) MeetingSigningKey(ctx context.Context, meetingID string) (pubKey, delegation []byte, err error) {
+	resp, err := c.decryptClient.MeetingSigningKey(ctx, &MeetingSigningKeyRequest{Id: meetingID})
+	if err != nil {
+		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+	return resp.PubKey, resp.Delegation, nil
+}
+
+// InclusionProof calls the InclusionProof grpc message.
+//
+// The returned proof has to be checked with InclusionProof.Verify() and the
+// ciphertext root from the signed result of the poll.
+func (c *Client) InclusionProof(ctx context.Context, pollID string, trackingCode []byte) (decrypt.InclusionProof, error) {
+	resp, err := c.decryptClient.InclusionProof(ctx, &InclusionProofRequest{Id: pollID, TrackingCode: trackingCode})
+	if err != nil {
+		return decrypt.InclusionProof{}, fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return decrypt.InclusionProof{
+		Index: int(resp.Index),
+		Size:  int(resp.Size),
+		Path:  resp.Path,
 	}, nil
 }
 
-func (s grpcServer) Stop(ctx context.Context, req *StopRequest) (*StopResponse, error) {
-	log.Printf("Stop request for id %s", req.Id)
-	decrypted, signature, err := s.decrypt.Stop(ctx, req.Id, req.Votes)
+// PartialDecrypt calls the PartialDecrypt grpc message. It returns the
+// decryption shares of a trustee poll in the order of voteList.
+//
+// Only the option decrypt.WithRequestSignature() is sent. The shares have to
+// be checked with crypto.VerifyShare() and the public poll key of the
+// trustee.
+func (c *Client) PartialDecrypt(ctx context.Context, pollID string, voteList [][]byte, options ...decrypt.StopOption) ([]decrypt.DecryptionShare, error) {
+	var config decrypt.StopConfig
+	for _, o := range options {
+		o(&config)
+	}
+
+	resp, err := c.decryptClient.PartialDecrypt(ctx, &PartialDecryptRequest{
+		Id:        pollID,
+		Votes:     voteList,
+		Signature: config.Signature,
+	})
 	if err != nil {
-		return nil, s.grpcError(fmt.Errorf("stopping vote: %w", err))
+		return nil, fmt.Errorf("sending grpc message: %w", err)
 	}
 
-	return &StopResponse{
-		Votes:     decrypted,
-		Signature: signature,
+	shares := make([]decrypt.DecryptionShare, len(resp.Shares))
+	for i, share := range resp.Shares {
+		shares[i] = decrypt.DecryptionShare{
+			Share:   share.Share,
+			Proof:   share.Proof,
+			Invalid: share.Invalid,
+		}
+	}
+	return shares, nil
+}
+
+// IssueToken calls the IssueToken grpc message. The caller has to check, that
+// the voter is eligible.
+//
+// The returned token has to be finalized with crypto.TokenRequest.Finalize().
+// The signature of the public token key has to be checked with the public main
+// key.
+func (c *Client) IssueToken(ctx context.Context, pollID string, blinded []byte) (decrypt.IssuedToken, error) {
+	resp, err := c.decryptClient.IssueToken(ctx, &IssueTokenRequest{
+		Id:      pollID,
+		Blinded: blinded,
+	})
+	if err != nil {
+		return decrypt.IssuedToken{}, fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return decrypt.IssuedToken{
+		Evaluated:    resp.Evaluated,
+		Proof:        resp.Proof,
+		PublicKey:    resp.PubKey,
+		PublicKeySig: resp.PubSig,
 	}, nil
 }
 
-func (s grpcServer) Clear(ctx context.Context, req *ClearRequest) (*EmptyMessage, error) {
-	log.Printf("Stop request for id %s", req.Id)
-	err := s.decrypt.Clear(ctx, req.Id)
+// NoDecryption calls the NoDecryption grpc message.
+//
+// It returns a decrypt.NoDecryptionCertificate in json format and its
+// signature created with the main key.
+func (c *Client) NoDecryption(ctx context.Context, pollID string) (certificate, signature []byte, err error) {
+	resp, err := c.decryptClient.NoDecryption(ctx, &NoDecryptionRequest{Id: pollID})
 	if err != nil {
-		return nil, s.grpcError(fmt.Errorf("clearing vote: %w", err))
+		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
 	}
 
-	return new(EmptyMessage), nil
+	return resp.Certificate, resp.Signature, nil
 }
 
-func (s grpcServer) PublicMainKey(ctx context.Context, req *EmptyMessage) (*PublicMainKeyResponse, error) {
-	log.Printf("Public Poll Key request")
-	key := s.decrypt.PublicMainKey(ctx)
+// Attest calls the Attest grpc message.
+//
+// The returned attestation has to be checked with decrypt.AttestationData()
+// and the verifier of the attestation backend, for example tpm.VerifyQuote().
+func (c *Client) Attest(ctx context.Context, nonce []byte) (decrypt.Attestation, error) {
+	resp, err := c.decryptClient.Attest(ctx, &AttestRequest{Nonce: nonce})
+	if err != nil {
+		return decrypt.Attestation{}, fmt.Errorf("sending grpc message: %w", err)
+	}
 
-	return &PublicMainKeyResponse{
-		PublicKey: key,
+	return decrypt.Attestation{
+		BinaryHash: resp.BinaryHash,
+		PubKey:     resp.PubKey,
+		Evidence:   resp.Evidence,
 	}, nil
 }
+
+// CheckMainKey calls the CheckMainKey grpc message. It returns an error, if
+// the server uses another main key then the one with the given fingerprint.
+//
+// The fingerprint can be created with decrypt.MainKeyFingerprint().
+func (c *Client) CheckMainKey(ctx context.Context, fingerprint string) error {
+	if _, err := c.decryptClient.CheckMainKey(ctx, &CheckMainKeyRequest{Fingerprint: fingerprint}); err != nil {
+		return fmt.Errorf("sending grpc message: %w", err)
+	}
+	return nil
+}
+
+// Version calls the Version grpc message.
+//
+// It returns the build information of the server as json and the signature
+// of it created with the main key.
+func (c *Client) Version(ctx context.Context) (info, signature []byte, err error) {
+	resp, err := c.decryptClient.Version(ctx, &EmptyMessage{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return resp.Info, resp.Signature, nil
+}
+
+// ExportKey calls the ExportKey grpc message. It returns the private key of the
+// poll sealed for the auditor.
+//
+// adminToken has to be the token, the server was started with.
+func (c *Client) ExportKey(ctx context.Context, adminToken string, pollID string, reason string) ([]byte, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	resp, err := c.decryptClient.ExportKey(ctx, &ExportKeyRequest{Id: pollID, Reason: reason})
+	if err != nil {
+		return nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return resp.SealedKey, nil
+}
+
+// Revoke calls the Revoke grpc message. It revokes the public poll key of a
+// running poll.
+//
+// adminToken has to be the token, the server was started with.
+func (c *Client) Revoke(ctx context.Context, adminToken string, pollID string, reason string) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	if _, err := c.decryptClient.Revoke(ctx, &RevokeRequest{Id: pollID, Reason: reason}); err != nil {
+		return fmt.Errorf("sending grpc message: %w", err)
+	}
+	return nil
+}
+
+// ExportAuditLog calls the ExportAuditLog grpc message. It returns the entries
+// of the audit log as json lines and their signature. An empty pollID and
+// zero times export all entries.
+//
+// The signature has to be checked with the public main key. The hash chain
+// can be checked with audit.Verify(), if pollID is empty.
+//
+// adminToken has to be the token, the server was started with.
+func (c *Client) ExportAuditLog(ctx context.Context, adminToken string, pollID string, from, to time.Time) (content, signature []byte, err error) {
+	req := &ExportAuditLogRequest{Id: pollID}
+	if !from.IsZero() {
+		req.From = from.Unix()
+	}
+	if !to.IsZero() {
+		req.To = to.Unix()
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	resp, err := c.decryptClient.ExportAuditLog(ctx, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+	return resp.Log, resp.Signature, nil
+}
+
+// EffectiveConfig calls the EffectiveConfig grpc message. It returns the
+// configuration, the build information and the enabled features of the server
+// as json.
+//
+// adminToken has to be the token, the server was started with.
+func (c *Client) EffectiveConfig(ctx context.Context, adminToken string) ([]byte, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	resp, err := c.decryptClient.EffectiveConfig(ctx, &EmptyMessage{})
+	if err != nil {
+		return nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+	return resp.Config, nil
+}
+
+// SetLogLevel calls the SetLogLevel grpc message. It changes the log level of
+// the server to debug, info, warning or error and returns the previous level.
+//
+// adminToken has to be the token, the server was started with.
+func (c *Client) SetLogLevel(ctx context.Context, adminToken string, level string) (previous string, err error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	resp, err := c.decryptClient.SetLogLevel(ctx, &SetLogLevelRequest{Level: level})
+	if err != nil {
+		return "", fmt.Errorf("sending grpc message: %w", err)
+	}
+	return resp.Previous, nil
+}
+
+// Rekey calls the Rekey grpc message. It creates a replacement key for a
+// running poll and returns the new public poll key and its signature.
+//
+// adminToken has to be the token, the server was started with.
+func (c *Client) Rekey(ctx context.Context, adminToken string, pollID string, reason string) (pubKey []byte, pubKeySig []byte, err error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	resp, err := c.decryptClient.Rekey(ctx, &RekeyRequest{Id: pollID, Reason: reason})
+	if err != nil {
+		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+	return resp.PubKey, resp.PubSig, nil
+}
+
+// RevocationList calls the RevocationList grpc message. It returns the list of
+// revoked poll keys as json and its signature. The signature has to be checked
+// with the public main key.
+func (c *Client) RevocationList(ctx context.Context) (list, signature []byte, err error) {
+	resp, err := c.decryptClient.RevocationList(ctx, &EmptyMessage{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+	return resp.List, resp.Signature, nil
+}
+
+// ImportKey calls the ImportKey grpc message. It starts the poll with an
+// externally created private poll key.
+//
+// adminToken has to be the token, the server was started with.
+func (c *Client) ImportKey(ctx context.Context, adminToken string, pollID string, pollKey []byte, options ...decrypt.StartOption) (pubKey []byte, pubKeySig []byte, err error) {
+	var config decrypt.StartConfig
+	for _, o := range options {
+		o(&config)
+	}
+
+	req := &ImportKeyRequest{Id: pollID, Key: pollKey, Metadata: config.Metadata, Trustee: config.Trustee, Tokens: config.Tokens, Ring: config.Ring, Revote: RevotePolicy(config.Revote)}
+	if config.NotBefore != nil {
+		req.NotBefore = config.NotBefore.Unix()
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	resp, err := c.decryptClient.ImportKey(ctx, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return resp.PubKey, resp.PubSig, nil
+}
+
+// Wipe calls the Wipe grpc message.
+//
+// adminToken has to be the token, the server was started with. confirmation
+// has to be the value from decrypt.WipeTokenFor() for the public main key of
+// the server.
+func (c *Client) Wipe(ctx context.Context, adminToken string, confirmation string, withMainKey bool) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	_, err := c.decryptClient.Wipe(ctx, &WipeRequest{Confirmation: confirmation, MainKey: withMainKey})
+	if err != nil {
+		return fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return nil
+}
+
+// SetReadOnly calls the SetReadOnly grpc message.
+//
+// adminToken has to be the token, the server was started with.
+func (c *Client) SetReadOnly(ctx context.Context, adminToken string, readOnly bool) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	_, err := c.decryptClient.SetReadOnly(ctx, &SetReadOnlyRequest{ReadOnly: readOnly})
+	if err != nil {
+		return fmt.Errorf("sending grpc message: %w", err)
+	}
+
+	return nil
+}
+
+type grpcServer struct {
+	decrypt          *decrypt.Decrypt
+	timestamper      Timestamper
+	uploader         ResultUploader
+	certificateChain [][]byte
+	effectiveConfig  []byte
+	logLevel         LogLevel
+	cosigners        []CoSigner
+	cosignQuorum     int
+	transparencyLog  TransparencyLog
+}
+
+// grpcError converts an error to a grpc error.
+//
+// Known errors from the errorcode package are returned with a matching grpc
+// code and their message. All other errors are internal.
+func (s grpcServer) grpcError(err error) error {
+	// TODO: Set the logger on initialization.
+	log.Printf("GRPC: %v", err)
+
+	var errCode errorcode.DecryptError
+	if !errors.As(err, &errCode) {
+		return reasonError(codes.Internal, errorcode.ReasonInternal, "Ups, someting went wrong!")
+	}
+
+	switch errCode {
+	case errorcode.Exist:
+		return reasonError(codes.AlreadyExists, errCode.Reason(), err.Error())
+
+	case errorcode.NotExist:
+		return reasonError(codes.NotFound, errCode.Reason(), err.Error())
+
+	case errorcode.Invalid:
+		return reasonError(codes.InvalidArgument, errCode.Reason(), err.Error())
+
+	case errorcode.Limit:
+		return reasonError(codes.ResourceExhausted, errCode.Reason(), err.Error())
+
+	case errorcode.ReadOnly:
+		return reasonError(codes.Unavailable, errCode.Reason(), err.Error())
+
+	case errorcode.Unsupported:
+		return reasonError(codes.Unimplemented, errCode.Reason(), err.Error())
+
+	case errorcode.Forbidden:
+		return reasonError(codes.PermissionDenied, errCode.Reason(), err.Error())
+
+	case errorcode.Unavailable:
+		return reasonError(codes.Unavailable, errCode.Reason(), err.Error())
+
+	default:
+		return reasonError(codes.Internal, errorcode.ReasonInternal, "Ups, someting went wrong!")
+	}
+}
+
+func (s grpcServer) Start(ctx context.Context, req *StartRequest) (*StartResponse, error) {
+	log.Printf("Start request for id %s", req.Id)
+	options := []decrypt.StartOption{decrypt.WithMetadata(req.Metadata)}
+	if req.NotBefore != 0 {
+		options = append(options, decrypt.WithNotBefore(time.Unix(req.NotBefore, 0)))
+	}
+	if req.Trustee {
+		options = append(options, decrypt.WithTrustee())
+	}
+	if req.Tokens {
+		options = append(options, decrypt.WithTokens())
+	}
+	if len(req.Ring) > 0 {
+		options = append(options, decrypt.WithRing(req.Ring))
+	}
+	if req.Revote != RevotePolicy_REVOTE_INVALID {
+		options = append(options, decrypt.WithRevotePolicy(decrypt.RevotePolicy(req.Revote)))
+	}
+
+	pubKey, pubKeySig, err := s.decrypt.Start(ctx, req.Id, options...)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("starting vote: %w", err))
+	}
+
+	fingerprint := decrypt.Fingerprint(pubKey)
+	log.Printf("Poll %s has the public key %s", req.Id, fingerprint)
+
+	return &StartResponse{
+		PubKey:      pubKey,
+		PubSig:      pubKeySig,
+		Fingerprint: fingerprint,
+	}, nil
+}
+
+func (s grpcServer) Stop(ctx context.Context, req *StopRequest) (*StopResponse, error) {
+	log.Printf("Stop request for id %s", req.Id)
+	if req.Upload && s.uploader == nil {
+		return nil, s.grpcError(fmt.Errorf("result upload is not configured: %w", errorcode.Unsupported))
+	}
+
+	decrypted, signature, err := s.decrypt.Stop(ctx, req.Id, req.Votes, stopOptions(req)...)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("stopping vote: %w", err))
+	}
+
+	resp := &StopResponse{
+		Votes:              decrypted,
+		Signature:          signature,
+		SignatureAlgorithm: s.signatureAlgorithm(ctx),
+		CertificateChain:   s.certificateChain,
+		MainKeyFingerprint: decrypt.Fingerprint(s.decrypt.PublicMainKey(ctx)),
+		Caller:             decrypt.CallerFromContext(ctx),
+	}
+
+	// Errors with errorcode.Unsupported mean, that the results are signed with
+	// the main key.
+	if err := s.addSigningKey(ctx, req.Id, resp); err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	cosignatures, err := s.coSign(ctx, req, decrypted)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("co-signing result: %w", err))
+	}
+	resp.Cosignatures = cosignatures
+
+	if s.timestamper != nil {
+		token, err := s.timestamper.Timestamp(ctx, signature)
+		if err != nil {
+			log.Printf("Error: getting timestamp for poll %s: %v", req.Id, err)
+		}
+		resp.Timestamp = token
+	}
+
+	if s.transparencyLog != nil {
+		signingKey := resp.PollSigningKey
+		if len(signingKey) == 0 {
+			signingKey = s.decrypt.PublicMainKey(ctx)
+		}
+
+		entry, err := s.transparencyLog.Upload(ctx, decrypted, signature, signingKey)
+		if err != nil {
+			log.Printf("Error: adding result of poll %s to the transparency log: %v", req.Id, err)
+		} else {
+			resp.Transparency = transparencyEntry(entry)
+		}
+	}
+
+	if req.Upload {
+		resultURL, err := s.uploader.Upload(ctx, req.Id, decrypted)
+		if err != nil {
+			return nil, s.grpcError(fmt.Errorf("uploading result: %w", err))
+		}
+
+		hash := sha256.Sum256(decrypted)
+		resp.Votes = nil
+		resp.ResultUrl = resultURL
+		resp.ResultHash = hash[:]
+	}
+
+	return resp, nil
+}
+
+// stopOptions returns the options of a stop request.
+func stopOptions(req *StopRequest) []decrypt.StopOption {
+	var options []decrypt.StopOption
+	if len(req.Weights) > 0 {
+		options = append(options, decrypt.WithWeights(req.Weights))
+	}
+	if len(req.Signature) > 0 {
+		options = append(options, decrypt.WithRequestSignature(req.Signature))
+	}
+	if req.Priority != 0 {
+		options = append(options, decrypt.WithPriority(int(req.Priority)))
+	}
+	if len(req.Tags) > 0 {
+		tags := make([]decrypt.VoteTag, len(req.Tags))
+		for i, tag := range req.Tags {
+			tags[i] = decrypt.VoteTag{Pseudonym: tag.Pseudonym, Time: time.UnixMilli(tag.TimeMs)}
+		}
+		options = append(options, decrypt.WithTags(tags))
+	}
+	return options
+}
+
+func (s grpcServer) Clear(ctx context.Context, req *ClearRequest) (*EmptyMessage, error) {
+	log.Printf("Stop request for id %s", req.Id)
+	err := s.decrypt.Clear(ctx, req.Id)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("clearing vote: %w", err))
+	}
+
+	return new(EmptyMessage), nil
+}
+
+func (s grpcServer) Status(ctx context.Context, req *StatusRequest) (*StatusResponse, error) {
+	log.Printf("Status request for id %s", req.Id)
+	pollStatus, err := s.decrypt.Status(ctx, req.Id)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("getting status: %w", err))
+	}
+
+	resp := &StatusResponse{
+		PubKey:            pollStatus.PubKey,
+		PubSig:            pollStatus.PubKeySig,
+		Metadata:          pollStatus.Metadata,
+		Election:          pollStatus.Election,
+		Fingerprint:       pollStatus.Fingerprint,
+		Meeting:           pollStatus.Meeting,
+		MeetingSigningKey: pollStatus.MeetingSigningKey,
+		MeetingDelegation: pollStatus.MeetingDelegation,
+	}
+	for _, feature := range pollStatus.Features {
+		resp.Features = append(resp.Features, string(feature))
+	}
+	if !pollStatus.NotBefore.IsZero() {
+		resp.NotBefore = pollStatus.NotBefore.Unix()
+	}
+	return resp, nil
+}
+
+func (s grpcServer) PublicKeys(ctx context.Context, req *EmptyMessage) (*PublicKeysResponse, error) {
+	log.Printf("PublicKeys request")
+	keys, err := s.decrypt.PublicKeys(ctx)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("listing public keys: %w", err))
+	}
+
+	resp := &PublicKeysResponse{Keys: make([]*PollPublicKey, len(keys))}
+	for i, key := range keys {
+		resp.Keys[i] = &PollPublicKey{
+			Id:          key.ID,
+			PubKey:      key.PubKey,
+			PubSig:      key.PubKeySig,
+			Fingerprint: key.Fingerprint,
+		}
+	}
+	return resp, nil
+}
+
+func (s grpcServer) StartElection(ctx context.Context, req *StartElectionRequest) (*PublicKeysResponse, error) {
+	log.Printf("StartElection request for id %s with %d polls", req.Id, len(req.PollIds))
+	options := []decrypt.StartOption{decrypt.WithMetadata(req.Metadata)}
+	if req.NotBefore != 0 {
+		options = append(options, decrypt.WithNotBefore(time.Unix(req.NotBefore, 0)))
+	}
+
+	keys, err := s.decrypt.StartElection(ctx, req.Id, req.PollIds, options...)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("starting election: %w", err))
+	}
+
+	resp := &PublicKeysResponse{Keys: make([]*PollPublicKey, len(keys))}
+	for i, key := range keys {
+		resp.Keys[i] = &PollPublicKey{
+			Id:          key.ID,
+			PubKey:      key.PubKey,
+			PubSig:      key.PubKeySig,
+			Fingerprint: key.Fingerprint,
+		}
+	}
+	return resp, nil
+}
+
+func (s grpcServer) StopElection(ctx context.Context, req *StopElectionRequest) (*StopElectionResponse, error) {
+	log.Printf("StopElection request for id %s", req.Id)
+	polls := make([]decrypt.ElectionPoll, len(req.Polls))
+	for i, poll := range req.Polls {
+		if poll.Upload {
+			return nil, s.grpcError(fmt.Errorf("results of elections can not be uploaded: %w", errorcode.Unsupported))
+		}
+
+		polls[i] = decrypt.ElectionPoll{ID: poll.Id, Votes: poll.Votes, Options: stopOptions(poll)}
+	}
+
+	report, signature, err := s.decrypt.StopElection(ctx, req.Id, polls)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("stopping election: %w", err))
+	}
+
+	return &StopElectionResponse{Report: report, Signature: signature}, nil
+}
+
+func (s grpcServer) StopMany(req *StopManyRequest, stream Decrypt_StopManyServer) error {
+	log.Printf("StopMany request for %d polls", len(req.Polls))
+	polls := make([]decrypt.ElectionPoll, len(req.Polls))
+	for i, poll := range req.Polls {
+		if poll.Upload {
+			return s.grpcError(fmt.Errorf("results of stop many can not be uploaded: %w", errorcode.Unsupported))
+		}
+
+		polls[i] = decrypt.ElectionPoll{ID: poll.Id, Votes: poll.Votes, Options: stopOptions(poll)}
+	}
+
+	// The progress is collected and sent in an interval, so a slow client does
+	// not slow down the decryption.
+	var mu sync.Mutex
+	latest := make(map[string]decrypt.Progress)
+	sendProgress := func() error {
+		mu.Lock()
+		pending := latest
+		latest = make(map[string]decrypt.Progress)
+		mu.Unlock()
+
+		ids := make([]string, 0, len(pending))
+		for id := range pending {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			p := pending[id]
+			err := stream.Send(&StopManyResponse{Event: &StopManyResponse_Progress{Progress: &StopProgress{
+				Id:        p.PollID,
+				Decrypted: int64(p.Decrypted),
+				Total:     int64(p.Total),
+			}}})
+			if err != nil {
+				return fmt.Errorf("sending progress: %w", err)
+			}
+		}
+		return nil
+	}
+
+	done := make(chan struct{})
+	var results []decrypt.StopManyResult
+	var stopErr error
+	go func() {
+		defer close(done)
+		results, stopErr = s.decrypt.StopMany(stream.Context(), polls, func(p decrypt.Progress) {
+			mu.Lock()
+			latest[p.PollID] = p
+			mu.Unlock()
+		})
+	}()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+			if err := sendProgress(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if stopErr != nil {
+		return s.grpcError(fmt.Errorf("stopping polls: %w", stopErr))
+	}
+
+	// Spilled results are read one by one, when they are sent. A result has to
+	// be in memory as a whole for its message, but the results, that are
+	// already sent, are dropped.
+	defer func() {
+		for _, r := range results {
+			if r.Spilled != nil {
+				r.Spilled.Close()
+			}
+		}
+	}()
+
+	if err := sendProgress(); err != nil {
+		return err
+	}
+
+	for i, r := range results {
+		results[i].Content = nil
+
+		if r.Spilled != nil {
+			content, err := r.Spilled.Bytes()
+			if err != nil {
+				r.Err = fmt.Errorf("reading spilled result: %w", err)
+			}
+			r.Content = content
+			r.Spilled.Close()
+		}
+
+		result := &StopManyResult{Id: r.ID, Votes: r.Content, Signature: r.Signature}
+		if r.Err != nil {
+			st := status.Convert(s.grpcError(fmt.Errorf("stopping poll %s: %w", r.ID, r.Err)))
+			result.Code = uint32(st.Code())
+			result.Error = st.Message()
+			result.Reason = statusReason(st)
+			result.Message, result.Locale = errorcode.Message(result.Reason, acceptLanguage(stream.Context()))
+		}
+
+		if err := stream.Send(&StopManyResponse{Event: &StopManyResponse_Result{Result: result}}); err != nil {
+			return fmt.Errorf("sending result: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s grpcServer) ClearElection(ctx context.Context, req *ClearElectionRequest) (*EmptyMessage, error) {
+	log.Printf("ClearElection request for id %s", req.Id)
+	if err := s.decrypt.ClearElection(ctx, req.Id); err != nil {
+		return nil, s.grpcError(fmt.Errorf("clearing election: %w", err))
+	}
+
+	return new(EmptyMessage), nil
+}
+
+func (s grpcServer) PollSigningKey(ctx context.Context, req *PollSigningKeyRequest) (*PollSigningKeyResponse, error) {
+	log.Printf("PollSigningKey request for id %s", req.Id)
+	pubKey, delegation, err := s.decrypt.PollSigningKey(ctx, req.Id)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("loading poll signing key: %w", err))
+	}
+
+	return &PollSigningKeyResponse{PubKey: pubKey, Delegation: delegation}, nil
+}
+
+// addSigningKey adds the meeting or poll signing key, that signed the result,
+// and its delegation to the response.
+func (s grpcServer) addSigningKey(ctx context.Context, pollID string, resp *StopResponse) error {
+	// Errors with errorcode.Unsupported mean, that the key is not used.
+	if meetingID := decrypt.MeetingID(pollID); meetingID != "" {
+		pubKey, delegation, err := s.decrypt.MeetingSigningKey(ctx, meetingID)
+		switch {
+		case err == nil:
+			resp.Meeting = meetingID
+			resp.PollSigningKey = pubKey
+			resp.Delegation = delegation
+			resp.SignatureAlgorithm = string(crypto.Ed25519)
+			return nil
+		case !errors.Is(err, errorcode.Unsupported):
+			return fmt.Errorf("loading meeting signing key: %w", err)
+		}
+	}
+
+	pubKey, delegation, err := s.decrypt.PollSigningKey(ctx, pollID)
+	switch {
+	case err == nil:
+		resp.PollSigningKey = pubKey
+		resp.Delegation = delegation
+		resp.SignatureAlgorithm = string(crypto.Ed25519)
+	case !errors.Is(err, errorcode.Unsupported):
+		return fmt.Errorf("loading poll signing key: %w", err)
+	}
+	return nil
+}
+
+func (s grpcServer) MeetingSigningKey(ctx context.Context, req *MeetingSigningKeyRequest) (*PollSigningKeyResponse, error) {
+	log.Printf("MeetingSigningKey request for meeting %s", req.Id)
+	pubKey, delegation, err := s.decrypt.MeetingSigningKey(ctx, req.Id)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("loading meeting signing key: %w", err))
+	}
+
+	return &PollSigningKeyResponse{PubKey: pubKey, Delegation: delegation}, nil
+}
+
+func (s grpcServer) InclusionProof(ctx context.Context, req *InclusionProofRequest) (*InclusionProofResponse, error) {
+	log.Printf("InclusionProof request for id %s", req.Id)
+	proof, err := s.decrypt.InclusionProof(ctx, req.Id, req.TrackingCode)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("creating inclusion proof: %w", err))
+	}
+
+	return &InclusionProofResponse{
+		Index: int64(proof.Index),
+		Size:  int64(proof.Size),
+		Path:  proof.Path,
+	}, nil
+}
+
+func (s grpcServer) PartialDecrypt(ctx context.Context, req *PartialDecryptRequest) (*PartialDecryptResponse, error) {
+	log.Printf("PartialDecrypt request for id %s", req.Id)
+	var options []decrypt.StopOption
+	if len(req.Signature) > 0 {
+		options = append(options, decrypt.WithRequestSignature(req.Signature))
+	}
+
+	shares, err := s.decrypt.PartialDecrypt(ctx, req.Id, req.Votes, options...)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("partial decrypting votes: %w", err))
+	}
+
+	resp := &PartialDecryptResponse{Shares: make([]*DecryptionShare, len(shares))}
+	for i, share := range shares {
+		resp.Shares[i] = &DecryptionShare{
+			Share:   share.Share,
+			Proof:   share.Proof,
+			Invalid: share.Invalid,
+		}
+	}
+	return resp, nil
+}
+
+func (s grpcServer) IssueToken(ctx context.Context, req *IssueTokenRequest) (*IssueTokenResponse, error) {
+	log.Printf("IssueToken request for id %s", req.Id)
+	issued, err := s.decrypt.IssueToken(ctx, req.Id, req.Blinded)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("issuing token: %w", err))
+	}
+
+	return &IssueTokenResponse{
+		Evaluated: issued.Evaluated,
+		Proof:     issued.Proof,
+		PubKey:    issued.PublicKey,
+		PubSig:    issued.PublicKeySig,
+	}, nil
+}
+
+func (s grpcServer) NoDecryption(ctx context.Context, req *NoDecryptionRequest) (*NoDecryptionResponse, error) {
+	log.Printf("NoDecryption request for id %s", req.Id)
+	certificate, signature, err := s.decrypt.NoDecryption(ctx, req.Id)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("creating no decryption certificate: %w", err))
+	}
+
+	return &NoDecryptionResponse{
+		Certificate: certificate,
+		Signature:   signature,
+	}, nil
+}
+
+func (s grpcServer) ExportKey(ctx context.Context, req *ExportKeyRequest) (*ExportKeyResponse, error) {
+	log.Printf("ExportKey request for id %s", req.Id)
+	sealed, err := s.decrypt.ExportKey(ctx, req.Id, req.Reason)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("exporting key: %w", err))
+	}
+
+	return &ExportKeyResponse{SealedKey: sealed}, nil
+}
+
+func (s grpcServer) Revoke(ctx context.Context, req *RevokeRequest) (*EmptyMessage, error) {
+	log.Printf("Revoke request for id %s", req.Id)
+	if err := s.decrypt.Revoke(ctx, req.Id, req.Reason); err != nil {
+		return nil, s.grpcError(fmt.Errorf("revoking poll key: %w", err))
+	}
+
+	return &EmptyMessage{}, nil
+}
+
+func (s grpcServer) Rekey(ctx context.Context, req *RekeyRequest) (*StartResponse, error) {
+	log.Printf("Rekey request for id %s", req.Id)
+	pubKey, pubKeySig, err := s.decrypt.Rekey(ctx, req.Id, req.Reason)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("rekeying poll: %w", err))
+	}
+
+	return &StartResponse{
+		PubKey:      pubKey,
+		PubSig:      pubKeySig,
+		Fingerprint: decrypt.Fingerprint(pubKey),
+	}, nil
+}
+
+func (s grpcServer) ExportAuditLog(ctx context.Context, req *ExportAuditLogRequest) (*ExportAuditLogResponse, error) {
+	log.Printf("ExportAuditLog request for id %q", req.Id)
+	var from, to time.Time
+	if req.From != 0 {
+		from = time.Unix(req.From, 0)
+	}
+	if req.To != 0 {
+		to = time.Unix(req.To, 0)
+	}
+
+	content, signature, err := s.decrypt.ExportAuditLog(ctx, req.Id, from, to)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("exporting audit log: %w", err))
+	}
+
+	return &ExportAuditLogResponse{Log: content, Signature: signature}, nil
+}
+
+func (s grpcServer) EffectiveConfig(ctx context.Context, req *EmptyMessage) (*EffectiveConfigResponse, error) {
+	log.Printf("EffectiveConfig request")
+	if s.effectiveConfig == nil {
+		return nil, s.grpcError(fmt.Errorf("effective config is not configured: %w", errorcode.Unsupported))
+	}
+
+	return &EffectiveConfigResponse{Config: s.effectiveConfig}, nil
+}
+
+func (s grpcServer) SetLogLevel(ctx context.Context, req *SetLogLevelRequest) (*SetLogLevelResponse, error) {
+	log.Printf("SetLogLevel request for level %q", req.Level)
+	if s.logLevel == nil {
+		return nil, s.grpcError(fmt.Errorf("log level is not configured: %w", errorcode.Unsupported))
+	}
+
+	level, err := loglevel.ParseLevel(req.Level)
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	previous := s.logLevel.SetLevel(level)
+	log.Printf("Warning: log level changed from %s to %s", previous, level)
+	return &SetLogLevelResponse{Previous: previous.String()}, nil
+}
+
+func (s grpcServer) RevocationList(ctx context.Context, req *EmptyMessage) (*RevocationListResponse, error) {
+	log.Printf("RevocationList request")
+	list, signature, err := s.decrypt.RevocationList(ctx)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("loading revocation list: %w", err))
+	}
+
+	return &RevocationListResponse{List: list, Signature: signature}, nil
+}
+
+func (s grpcServer) ImportKey(ctx context.Context, req *ImportKeyRequest) (*StartResponse, error) {
+	log.Printf("ImportKey request for id %s", req.Id)
+	options := []decrypt.StartOption{decrypt.WithMetadata(req.Metadata)}
+	if req.NotBefore != 0 {
+		options = append(options, decrypt.WithNotBefore(time.Unix(req.NotBefore, 0)))
+	}
+	if req.Trustee {
+		options = append(options, decrypt.WithTrustee())
+	}
+	if req.Tokens {
+		options = append(options, decrypt.WithTokens())
+	}
+	if len(req.Ring) > 0 {
+		options = append(options, decrypt.WithRing(req.Ring))
+	}
+	if req.Revote != RevotePolicy_REVOTE_INVALID {
+		options = append(options, decrypt.WithRevotePolicy(decrypt.RevotePolicy(req.Revote)))
+	}
+
+	pubKey, pubKeySig, err := s.decrypt.ImportKey(ctx, req.Id, req.Key, options...)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("importing key: %w", err))
+	}
+
+	return &StartResponse{
+		PubKey: pubKey,
+		PubSig: pubKeySig,
+	}, nil
+}
+
+func (s grpcServer) Wipe(ctx context.Context, req *WipeRequest) (*EmptyMessage, error) {
+	log.Printf("Wipe request")
+	if err := s.decrypt.Wipe(ctx, req.Confirmation, req.MainKey); err != nil {
+		return nil, s.grpcError(fmt.Errorf("wiping: %w", err))
+	}
+
+	return new(EmptyMessage), nil
+}
+
+func (s grpcServer) SetReadOnly(ctx context.Context, req *SetReadOnlyRequest) (*EmptyMessage, error) {
+	log.Printf("SetReadOnly request with %t", req.ReadOnly)
+	if err := s.decrypt.SetReadOnly(ctx, req.ReadOnly); err != nil {
+		return nil, s.grpcError(fmt.Errorf("setting read only mode: %w", err))
+	}
+
+	return new(EmptyMessage), nil
+}
+
+func (s grpcServer) Attest(ctx context.Context, req *AttestRequest) (*AttestResponse, error) {
+	log.Printf("Attest request")
+	attestation, err := s.decrypt.Attest(ctx, req.Nonce)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("attesting: %w", err))
+	}
+
+	return &AttestResponse{
+		BinaryHash: attestation.BinaryHash,
+		PubKey:     attestation.PubKey,
+		Evidence:   attestation.Evidence,
+	}, nil
+}
+
+func (s grpcServer) CheckMainKey(ctx context.Context, req *CheckMainKeyRequest) (*EmptyMessage, error) {
+	log.Printf("CheckMainKey request")
+	if err := s.decrypt.CheckMainKey(ctx, req.Fingerprint); err != nil {
+		return nil, s.grpcError(fmt.Errorf("checking main key: %w", err))
+	}
+
+	return &EmptyMessage{}, nil
+}
+
+func (s grpcServer) Version(ctx context.Context, req *EmptyMessage) (*VersionResponse, error) {
+	log.Printf("Version request")
+	info, signature, err := s.decrypt.Version(ctx)
+	if err != nil {
+		return nil, s.grpcError(fmt.Errorf("getting version: %w", err))
+	}
+
+	return &VersionResponse{
+		Info:      info,
+		Signature: signature,
+	}, nil
+}
+
+func (s grpcServer) PublicMainKey(ctx context.Context, req *EmptyMessage) (*PublicMainKeyResponse, error) {
+	log.Printf("Public Poll Key request")
+	key := s.decrypt.PublicMainKey(ctx)
+
+	return &PublicMainKeyResponse{
+		PublicKey:        key,
+		Algorithm:        s.signatureAlgorithm(ctx),
+		CertificateChain: s.certificateChain,
+		Fingerprint:      decrypt.Fingerprint(key),
+	}, nil
+}
+
+// signatureAlgorithm returns the identifier of the algorithm of the main key or
+// an empty string, if it is unknown.
+func (s grpcServer) signatureAlgorithm(ctx context.Context) string {
+	algorithm, err := crypto.KeyAlgorithm(s.decrypt.PublicMainKey(ctx))
+	if err != nil {
+		return ""
+	}
+	return string(algorithm)
+}
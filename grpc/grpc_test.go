@@ -0,0 +1,163 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/security"
+	"github.com/OpenSlides/vote-decrypt/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTimeoutInterceptor(t *testing.T) {
+	interceptor := timeoutInterceptor(time.Minute)
+
+	var deadline time.Time
+	var hasDeadline bool
+	handler := func(ctx context.Context, req any) (any, error) {
+		deadline, hasDeadline = ctx.Deadline()
+		return nil, nil
+	}
+
+	t.Run("without deadline", func(t *testing.T) {
+		interceptor(context.Background(), nil, nil, handler)
+
+		if !hasDeadline {
+			t.Fatalf("handler was called without a deadline")
+		}
+
+		if until := time.Until(deadline); until <= 0 || until > time.Minute {
+			t.Errorf("deadline is in %s, expected a minute", until)
+		}
+	})
+
+	t.Run("with deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+
+		interceptor(ctx, nil, nil, handler)
+
+		if until := time.Until(deadline); until <= time.Minute {
+			t.Errorf("deadline is in %s, expected the deadline of the caller", until)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		timeoutInterceptor(0)(context.Background(), nil, nil, handler)
+
+		if hasDeadline {
+			t.Errorf("handler was called with a deadline")
+		}
+	})
+}
+
+type securityLogMock struct {
+	events []security.Event
+}
+
+func (l *securityLogMock) Report(event security.Event) {
+	l.events = append(l.events, event)
+}
+
+func TestSecurityEvents(t *testing.T) {
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+
+	t.Run("invalid admin token", func(t *testing.T) {
+		securityLog := new(securityLogMock)
+		interceptor := adminInterceptor("secret", securityLog)
+
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer wrong"))
+		if _, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Wipe"}, handler); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("got error %v, expected code %s", err, codes.Unauthenticated)
+		}
+
+		if len(securityLog.events) != 1 || securityLog.events[0].Kind != security.AuthFailure || securityLog.events[0].Method != "Wipe" {
+			t.Errorf("got events %v, expected one auth failure for Wipe", securityLog.events)
+		}
+
+		ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+		if _, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Wipe"}, handler); err != nil {
+			t.Fatalf("call with valid token: %v", err)
+		}
+
+		if len(securityLog.events) != 1 {
+			t.Errorf("call with valid token was reported")
+		}
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		securityLog := new(securityLogMock)
+		interceptor := signatureInterceptor(securityLog)
+
+		forbidden := func(ctx context.Context, req any) (any, error) {
+			return nil, status.Error(codes.PermissionDenied, "invalid request signature")
+		}
+
+		interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Stop"}, forbidden)
+		interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Stop"}, handler)
+
+		if len(securityLog.events) != 1 || securityLog.events[0].Kind != security.SignatureFailure || securityLog.events[0].Reason != "invalid request signature" {
+			t.Errorf("got events %v, expected one signature failure", securityLog.events)
+		}
+	})
+
+	t.Run("quota", func(t *testing.T) {
+		securityLog := new(securityLogMock)
+		interceptor := quotaInterceptor(newQuotaLimiter([]Quota{{Method: "Start", Limit: 1, Period: time.Hour}}), securityLog)
+
+		for i := 0; i < 2; i++ {
+			interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Start"}, handler)
+		}
+
+		if len(securityLog.events) != 1 || securityLog.events[0].Kind != security.RateLimit || securityLog.events[0].Method != "Start" {
+			t.Errorf("got events %v, expected one rate limit event for Start", securityLog.events)
+		}
+	})
+}
+
+// callChain calls the handler through the unary interceptors in the given
+// order.
+func callChain(ctx context.Context, interceptors []grpc.UnaryServerInterceptor, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if len(interceptors) == 0 {
+		return handler(ctx, nil)
+	}
+
+	return interceptors[0](ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return callChain(ctx, interceptors[1:], info, handler)
+	})
+}
+
+func TestQuotaAfterAuth(t *testing.T) {
+	config := serverConfig{
+		adminToken:  "secret",
+		quotas:      []Quota{{Method: "Wipe", Limit: 1, Period: time.Hour}},
+		securityLog: new(securityLogMock),
+	}
+	unary, _ := config.interceptors(decrypt.New(crypto.New(make([]byte, 32), rand.Reader, nil), store.New(t.TempDir())))
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Wipe"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer wrong"))
+		if _, err := callChain(ctx, unary, info, handler); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("call %d with wrong token returned %v, expected code %s", i, err, codes.Unauthenticated)
+		}
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	if _, err := callChain(ctx, unary, info, handler); err != nil {
+		t.Errorf("call with admin token after calls with wrong tokens returned %v, expected it to be allowed", err)
+	}
+}
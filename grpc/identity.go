@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// callerIdentity returns the identity of the caller of a grpc call.
+//
+// A caller with the admin token has the id of the token with the prefix
+// `admin:`, even if it also has a client certificate. So it keeps RoleAdmin,
+// when roles are used. The id is the first 16 hex characters of the sha256
+// hash of the token, so the token itself is not written to the audit log.
+//
+// Otherwise, it is the first name of a verified client certificate with the
+// prefix `mtls:`. Without a certificate, it is the id of the bearer token with
+// the prefix `token:`. Without a token, it is the address of the caller with
+// the prefix `addr:`.
+//
+// The subject of a JWT is not used. The service has no key to verify the
+// signature of a JWT, so the subject could be chosen by the caller and would
+// match role bindings of other callers. A JWT is identified by its hash like
+// any other bearer token.
+func callerIdentity(ctx context.Context, adminToken string) string {
+	token, hasToken := bearerToken(ctx)
+	hash := sha256.Sum256([]byte(token))
+	tokenID := hex.EncodeToString(hash[:8])
+
+	if hasToken && adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return "admin:" + tokenID
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 && len(info.State.VerifiedChains[0]) > 0 {
+			cert := info.State.VerifiedChains[0][0]
+			switch {
+			case len(cert.URIs) > 0:
+				return "mtls:" + cert.URIs[0].String()
+			case len(cert.DNSNames) > 0:
+				return "mtls:" + cert.DNSNames[0]
+			case len(cert.EmailAddresses) > 0:
+				return "mtls:" + cert.EmailAddresses[0]
+			case cert.Subject.CommonName != "":
+				return "mtls:" + cert.Subject.CommonName
+			}
+		}
+	}
+
+	if hasToken {
+		return "token:" + tokenID
+	}
+
+	if addr := callerAddr(ctx); addr != "" {
+		return "addr:" + addr
+	}
+	return ""
+}
+
+// bearerToken returns the bearer token of the authorization header.
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimPrefix(values[0], "Bearer "), true
+}
+
+// identityInterceptor returns a grpc interceptor, that adds the identity of
+// the caller to the context. It is written to the audit log by the decrypt
+// component.
+func identityInterceptor(adminToken string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(decrypt.ContextWithCaller(ctx, callerIdentity(ctx, adminToken)), req)
+	}
+}
+
+// identityStreamInterceptor is like identityInterceptor for streaming grpc
+// methods.
+func identityStreamInterceptor(adminToken string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := decrypt.ContextWithCaller(ss.Context(), callerIdentity(ss.Context(), adminToken))
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// contextStream is a grpc.ServerStream with another context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,98 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestCallerIdentity(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 4711}
+
+	for _, tt := range []struct {
+		name   string
+		ctx    context.Context
+		expect string
+	}{
+		{
+			"address",
+			peer.NewContext(context.Background(), &peer.Peer{Addr: addr}),
+			"addr:192.0.2.1",
+		},
+		{
+			"admin token",
+			metadata.NewIncomingContext(
+				peer.NewContext(context.Background(), &peer.Peer{Addr: addr}),
+				metadata.Pairs("authorization", "Bearer admin-secret"),
+			),
+			"admin:16175223c8ddce5a",
+		},
+		{
+			"other token",
+			metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer other")),
+			"token:d9298a10d1b07358",
+		},
+		{
+			"client certificate",
+			peer.NewContext(context.Background(), &peer.Peer{
+				Addr: addr,
+				AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+					VerifiedChains: [][]*x509.Certificate{{{
+						Subject:  pkix.Name{CommonName: "ignored"},
+						DNSNames: []string{"vote-service"},
+					}}},
+				}},
+			}),
+			"mtls:vote-service",
+		},
+		{
+			"client certificate and admin token",
+			metadata.NewIncomingContext(
+				peer.NewContext(context.Background(), &peer.Peer{
+					Addr: addr,
+					AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+						VerifiedChains: [][]*x509.Certificate{{{DNSNames: []string{"vote-service"}}}},
+					}},
+				}),
+				metadata.Pairs("authorization", "Bearer admin-secret"),
+			),
+			"admin:16175223c8ddce5a",
+		},
+		{
+			"client certificate and other token",
+			metadata.NewIncomingContext(
+				peer.NewContext(context.Background(), &peer.Peer{
+					Addr: addr,
+					AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+						VerifiedChains: [][]*x509.Certificate{{{DNSNames: []string{"vote-service"}}}},
+					}},
+				}),
+				metadata.Pairs("authorization", "Bearer other"),
+			),
+			"mtls:vote-service",
+		},
+		{
+			"unverified certificate",
+			peer.NewContext(context.Background(), &peer.Peer{
+				Addr: addr,
+				AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{{DNSNames: []string{"vote-service"}}},
+				}},
+			}),
+			"addr:192.0.2.1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := callerIdentity(tt.ctx, "admin-secret"); got != tt.expect {
+				t.Errorf("got identity %q, expected %q", got, tt.expect)
+			}
+		})
+	}
+}
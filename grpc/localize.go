@@ -0,0 +1,214 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain is the domain of the ErrorInfo details of the errors.
+const errorDomain = "vote-decrypt"
+
+// reasonError returns a grpc error with a machine readable reason as
+// ErrorInfo detail.
+func reasonError(code codes.Code, reason string, message string) error {
+	st, err := status.New(code, message).WithDetails(&errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: errorDomain,
+	})
+	if err != nil {
+		return status.Error(code, message)
+	}
+	return st.Err()
+}
+
+// codeReasons are the reasons for errors without an ErrorInfo detail, for
+// example errors of the interceptors.
+var codeReasons = map[codes.Code]string{
+	codes.AlreadyExists:     errorcode.ReasonExist,
+	codes.NotFound:          errorcode.ReasonNotExist,
+	codes.InvalidArgument:   errorcode.ReasonInvalid,
+	codes.ResourceExhausted: errorcode.ReasonLimit,
+	codes.Unimplemented:     errorcode.ReasonUnsupported,
+	codes.PermissionDenied:  errorcode.ReasonForbidden,
+	codes.Unauthenticated:   errorcode.ReasonUnauthenticated,
+	codes.Unavailable:       errorcode.ReasonUnavailable,
+	codes.DeadlineExceeded:  errorcode.ReasonTimeout,
+	codes.Internal:          errorcode.ReasonInternal,
+}
+
+// statusReason returns the reason of a status. It is the reason of the
+// ErrorInfo detail or, if there is none, the reason for the grpc code.
+func statusReason(st *status.Status) string {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+
+	if reason, ok := codeReasons[st.Code()]; ok {
+		return reason
+	}
+	return errorcode.ReasonUnknown
+}
+
+// acceptLanguage returns the accept-language header of an incoming call.
+func acceptLanguage(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get("accept-language"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// localize adds the reason and a message from the message catalog in the
+// language, that the client accepts, to a grpc error.
+//
+// The message of the status is not changed, so clients, that only read the
+// message, get the same error as before.
+func localize(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st := status.Convert(err)
+	if st.Code() == codes.OK {
+		return err
+	}
+
+	var hasInfo bool
+	for _, detail := range st.Details() {
+		switch detail.(type) {
+		case *errdetails.LocalizedMessage:
+			return err
+		case *errdetails.ErrorInfo:
+			hasInfo = true
+		}
+	}
+
+	reason := statusReason(st)
+	message, language := errorcode.Message(reason, acceptLanguage(ctx))
+
+	details := []protoadapt.MessageV1{&errdetails.LocalizedMessage{Locale: language, Message: message}}
+	if !hasInfo {
+		details = append(details, &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain})
+	}
+
+	localized, detailErr := st.WithDetails(details...)
+	if detailErr != nil {
+		return err
+	}
+	return localized.Err()
+}
+
+// localizeInterceptor localizes the errors of unary grpc methods.
+func localizeInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	return resp, localize(ctx, err)
+}
+
+// localizeStreamInterceptor localizes the errors of streaming grpc methods.
+func localizeStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return localize(ss.Context(), handler(srv, ss))
+}
+
+// WithLanguage sets the languages of the localized error messages. The value
+// has the format of an Accept-Language header, for example `de` or
+// `de-DE,en;q=0.8`. Without this option, the messages are in english.
+//
+// Use LocalizedMessage() to get the message of an error.
+func WithLanguage(language string) ClientOption {
+	return func(c *clientConfig) {
+		c.language = language
+	}
+}
+
+// withLanguage adds the language of the client to an outgoing call.
+func (c clientConfig) withLanguage(ctx context.Context) context.Context {
+	if c.language == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "accept-language", c.language)
+}
+
+// streamInterceptor adds the language to streaming calls.
+func (c clientConfig) streamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(c.withLanguage(ctx), desc, cc, method, opts...)
+	}
+}
+
+// stopManyError returns the error of a failed poll of StopMany with its reason
+// and localized message.
+func stopManyError(r *StopManyResult) error {
+	st := status.New(codes.Code(r.Code), r.Error)
+	if r.Reason == "" {
+		return st.Err()
+	}
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: r.Reason, Domain: errorDomain}}
+	if r.Message != "" {
+		details = append(details, &errdetails.LocalizedMessage{Locale: r.Locale, Message: r.Message})
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// ErrorReason returns the machine readable reason of an error of a client
+// method, for example NOT_EXIST. See the Reason constants of the package
+// errorcode for all values.
+//
+// It returns an empty string for nil and UNKNOWN for errors, that are not
+// grpc errors.
+func ErrorReason(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errorcode.ReasonTimeout
+		}
+		return errorcode.ReasonUnknown
+	}
+	return statusReason(st)
+}
+
+// LocalizedMessage returns the message of an error of a client method, that
+// can be shown to election officials. It is in the language set by
+// WithLanguage().
+//
+// For errors without a localized message, the english message for the reason
+// of the error is returned. It returns an empty string for nil.
+func LocalizedMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if st, ok := status.FromError(err); ok {
+		for _, detail := range st.Details() {
+			if msg, ok := detail.(*errdetails.LocalizedMessage); ok {
+				return msg.Message
+			}
+		}
+	}
+
+	message, _ := errorcode.Message(ErrorReason(err), "")
+	return message
+}
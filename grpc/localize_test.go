@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLocalizeInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Stop"}
+
+	for _, tt := range []struct {
+		name        string
+		language    string
+		err         error
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			"decrypt error",
+			"de-DE,de;q=0.9,en;q=0.8",
+			grpcServer{}.grpcError(fmt.Errorf("stopping poll: %w", errorcode.NotExist)),
+			errorcode.ReasonNotExist,
+			"Die Abstimmung existiert nicht oder wurde nicht gestartet.",
+		},
+		{
+			"interceptor error",
+			"de",
+			status.Error(codes.Unauthenticated, "invalid admin token"),
+			errorcode.ReasonUnauthenticated,
+			"Das Admin-Token fehlt oder ist falsch.",
+		},
+		{
+			"unknown language",
+			"fr",
+			grpcServer{}.grpcError(fmt.Errorf("stopping poll: %w", errorcode.ReadOnly)),
+			errorcode.ReasonReadOnly,
+			"The decrypt service is in read only mode. Polls can not be started or stopped at the moment.",
+		},
+		{
+			"internal error",
+			"",
+			grpcServer{}.grpcError(fmt.Errorf("some store error")),
+			errorcode.ReasonInternal,
+			"An internal error occurred in the decrypt service. Contact the administrator.",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("accept-language", tt.language))
+			handler := func(ctx context.Context, req any) (any, error) {
+				return nil, tt.err
+			}
+
+			_, err := localizeInterceptor(ctx, nil, info, handler)
+
+			if got := status.Convert(err).Message(); got != status.Convert(tt.err).Message() {
+				t.Errorf("status message is %q, expected it unchanged", got)
+			}
+
+			if got := ErrorReason(fmt.Errorf("sending grpc message: %w", err)); got != tt.wantReason {
+				t.Errorf("got reason %q, expected %q", got, tt.wantReason)
+			}
+
+			if got := LocalizedMessage(err); got != tt.wantMessage {
+				t.Errorf("got message %q, expected %q", got, tt.wantMessage)
+			}
+		})
+	}
+
+	t.Run("no error", func(t *testing.T) {
+		_, err := localizeInterceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+
+		if err != nil {
+			t.Errorf("got error %v", err)
+		}
+	})
+}
+
+func TestStopManyError(t *testing.T) {
+	err := stopManyError(&StopManyResult{
+		Id:      "1",
+		Code:    uint32(codes.InvalidArgument),
+		Error:   "stopping poll 1: invalid",
+		Reason:  errorcode.ReasonInvalid,
+		Message: "Die Anfrage ist ungültig.",
+		Locale:  "de",
+	})
+
+	if got := ErrorReason(err); got != errorcode.ReasonInvalid {
+		t.Errorf("got reason %q, expected %q", got, errorcode.ReasonInvalid)
+	}
+
+	if got := LocalizedMessage(err); got != "Die Anfrage ist ungültig." {
+		t.Errorf("got message %q", got)
+	}
+}
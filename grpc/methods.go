@@ -0,0 +1,116 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// MethodKind tells, what a grpc method of the Decrypt service changes.
+type MethodKind int
+
+const (
+	// WriteMethod changes the data of the store, for example polls, keys,
+	// commitments or the audit log. It is only handled by the leader and refused in
+	// read only mode.
+	WriteMethod MethodKind = iota
+
+	// ReadMethod does not change any state.
+	ReadMethod
+
+	// LeaderMethod does not write to the store, but is only handled by the
+	// leader. It is allowed in read only mode. SetReadOnly has to work on a
+	// standby, so it can be promoted, and tokens are only issued by one
+	// instance.
+	LeaderMethod
+
+	// LocalMethod only changes the running instance, for example its log
+	// level. It is handled by every instance.
+	LocalMethod
+)
+
+// methodKinds is the kind of each method of the Decrypt service. A test makes
+// sure, that each method of the service is in it.
+//
+// CoSign is a read method, since a co-signer only decrypts and signs. It has
+// to work on the other instances, also on followers and standbys.
+var methodKinds = map[string]MethodKind{
+	"/Decrypt/Start":             WriteMethod,
+	"/Decrypt/Stop":              WriteMethod,
+	"/Decrypt/Clear":             WriteMethod,
+	"/Decrypt/Wipe":              WriteMethod,
+	"/Decrypt/ExportKey":         WriteMethod,
+	"/Decrypt/NoDecryption":      WriteMethod,
+	"/Decrypt/ImportKey":         WriteMethod,
+	"/Decrypt/StartElection":     WriteMethod,
+	"/Decrypt/StopElection":      WriteMethod,
+	"/Decrypt/ClearElection":     WriteMethod,
+	"/Decrypt/StopMany":          WriteMethod,
+	"/Decrypt/PartialDecrypt":    WriteMethod,
+	"/Decrypt/Revoke":            WriteMethod,
+	"/Decrypt/Rekey":             WriteMethod,
+	"/Decrypt/CoSign":            ReadMethod,
+	"/Decrypt/PublicMainKey":     ReadMethod,
+	"/Decrypt/Status":            ReadMethod,
+	"/Decrypt/Attest":            ReadMethod,
+	"/Decrypt/Version":           ReadMethod,
+	"/Decrypt/CheckMainKey":      ReadMethod,
+	"/Decrypt/InclusionProof":    ReadMethod,
+	"/Decrypt/PublicKeys":        ReadMethod,
+	"/Decrypt/PollSigningKey":    ReadMethod,
+	"/Decrypt/MeetingSigningKey": ReadMethod,
+	"/Decrypt/RevocationList":    ReadMethod,
+	"/Decrypt/ExportAuditLog":    ReadMethod,
+	"/Decrypt/EffectiveConfig":   ReadMethod,
+	"/Decrypt/SetReadOnly":       LeaderMethod,
+	"/Decrypt/IssueToken":        LeaderMethod,
+	"/Decrypt/SetLogLevel":       LocalMethod,
+}
+
+// KindOf returns the kind of a grpc method like `/Decrypt/Stop`. Unknown
+// methods are a WriteMethod, so they are never handled by an instance, that
+// is not allowed to write.
+func KindOf(fullMethod string) MethodKind {
+	kind, ok := methodKinds[fullMethod]
+	if !ok {
+		return WriteMethod
+	}
+	return kind
+}
+
+// NeedsLeader returns true, if the grpc method is only handled by the leader.
+func NeedsLeader(fullMethod string) bool {
+	kind := KindOf(fullMethod)
+	return kind == WriteMethod || kind == LeaderMethod
+}
+
+// readOnlyInterceptor returns a grpc interceptor, that refuses the write
+// methods in read only mode.
+func readOnlyInterceptor(readOnly func() bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkReadOnly(readOnly, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// readOnlyStreamInterceptor is like readOnlyInterceptor for streaming grpc
+// methods.
+func readOnlyStreamInterceptor(readOnly func() bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkReadOnly(readOnly, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkReadOnly(readOnly func() bool, fullMethod string) error {
+	if KindOf(fullMethod) == WriteMethod && readOnly() {
+		return reasonError(codes.Unavailable, errorcode.ReadOnly.Reason(), "instance is in read only mode")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMethodKinds(t *testing.T) {
+	methods := make(map[string]bool)
+	for _, method := range Decrypt_ServiceDesc.Methods {
+		methods["/Decrypt/"+method.MethodName] = true
+	}
+	for _, stream := range Decrypt_ServiceDesc.Streams {
+		methods["/Decrypt/"+stream.StreamName] = true
+	}
+
+	for method := range methods {
+		if _, ok := methodKinds[method]; !ok {
+			t.Errorf("method %s has no kind", method)
+		}
+	}
+
+	for method := range methodKinds {
+		if !methods[method] {
+			t.Errorf("method %s has a kind, but is not in the service", method)
+		}
+	}
+
+	for _, method := range readRoleMethods {
+		if kind := KindOf("/Decrypt/" + method); kind != ReadMethod {
+			t.Errorf("read role method %s has kind %d", method, kind)
+		}
+	}
+
+	for method := range roleMethods[RoleAuditor] {
+		if kind := KindOf("/Decrypt/" + method); kind != ReadMethod {
+			t.Errorf("auditor can call %s with kind %d", method, kind)
+		}
+	}
+
+	if !NeedsLeader("/Decrypt/Unknown") {
+		t.Errorf("unknown method is handled without the leader")
+	}
+}
+
+func TestReadOnlyInterceptor(t *testing.T) {
+	readOnly := true
+	interceptor := readOnlyInterceptor(func() bool { return readOnly })
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+
+	for _, tt := range []struct {
+		method string
+		expect codes.Code
+	}{
+		{"/Decrypt/Stop", codes.Unavailable},
+		{"/Decrypt/Revoke", codes.Unavailable},
+		{"/Decrypt/Wipe", codes.Unavailable},
+		{"/Decrypt/Status", codes.OK},
+		{"/Decrypt/CoSign", codes.OK},
+		{"/Decrypt/SetReadOnly", codes.OK},
+		{"/Decrypt/SetLogLevel", codes.OK},
+	} {
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+		if got := status.Code(err); got != tt.expect {
+			t.Errorf("%s in read only mode returned code %s, expected %s", tt.method, got, tt.expect)
+		}
+	}
+
+	readOnly = false
+	if _, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Stop"}, handler); err != nil {
+		t.Errorf("Stop without read only mode returned %v", err)
+	}
+}
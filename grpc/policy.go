@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/policy"
+	"github.com/OpenSlides/vote-decrypt/security"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Policy decides, if a call is allowed, for example policy.OPA.
+type Policy interface {
+	Allow(ctx context.Context, input policy.Input) (policy.Decision, error)
+}
+
+// WithPolicy lets a policy decide about each call. The policy is asked after
+// the admin token and the roles are checked. If the policy can not be asked,
+// the call fails with Unavailable.
+func WithPolicy(p Policy) ServerOption {
+	return func(c *serverConfig) {
+		c.policy = p
+	}
+}
+
+// policyInput returns the input of the policy for a call. req is nil for
+// streaming methods.
+func policyInput(ctx context.Context, d *decrypt.Decrypt, fullMethod string, req any) policy.Input {
+	input := policy.Input{
+		Method: methodName(fullMethod),
+		Caller: decrypt.CallerFromContext(ctx),
+		Time:   time.Now(),
+	}
+
+	if r, ok := req.(interface{ GetId() string }); ok {
+		input.PollID = r.GetId()
+	}
+
+	var metadata []byte
+	if r, ok := req.(interface{ GetMetadata() []byte }); ok && input.Method == "Start" {
+		metadata = r.GetMetadata()
+	} else if input.PollID != "" {
+		// Errors are ignored. A poll, that does not exist, has no metadata.
+		if pollStatus, err := d.Status(ctx, input.PollID); err == nil {
+			metadata = pollStatus.Metadata
+		}
+	}
+
+	if json.Valid(metadata) {
+		input.Metadata = metadata
+	}
+
+	return input
+}
+
+// askPolicy returns an error, if the policy does not allow the call.
+func askPolicy(ctx context.Context, p Policy, input policy.Input, securityLog SecurityLog) error {
+	decision, err := p.Allow(ctx, input)
+	if err != nil {
+		log.Printf("Error: asking policy for %s: %v", input.Method, err)
+		return status.Error(codes.Unavailable, "policy is not available")
+	}
+
+	if !decision.Allow {
+		reason := "denied by policy"
+		if decision.Reason != "" {
+			reason = fmt.Sprintf("denied by policy: %s", decision.Reason)
+		}
+
+		securityLog.Report(security.Event{
+			Kind:   security.AuthFailure,
+			Source: callerAddr(ctx),
+			Method: input.Method,
+			Reason: reason,
+		})
+		return status.Error(codes.PermissionDenied, reason)
+	}
+
+	return nil
+}
+
+// policyInterceptor returns a grpc interceptor, that asks the policy for each
+// call.
+func policyInterceptor(p Policy, d *decrypt.Decrypt, securityLog SecurityLog) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := askPolicy(ctx, p, policyInput(ctx, d, info.FullMethod, req), securityLog); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// policyStreamInterceptor is like policyInterceptor for streaming methods. The
+// input contains no poll, since the request is not known yet.
+func policyStreamInterceptor(p Policy, d *decrypt.Decrypt, securityLog SecurityLog) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := askPolicy(ss.Context(), p, policyInput(ss.Context(), d, info.FullMethod, nil), securityLog); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
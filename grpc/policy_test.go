@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/policy"
+	"github.com/OpenSlides/vote-decrypt/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type policyMock struct {
+	inputs   []policy.Input
+	decision policy.Decision
+	err      error
+}
+
+func (p *policyMock) Allow(ctx context.Context, input policy.Input) (policy.Decision, error) {
+	p.inputs = append(p.inputs, input)
+	return p.decision, p.err
+}
+
+func TestPolicyInterceptor(t *testing.T) {
+	d := decrypt.New(
+		crypto.New(make([]byte, 32), rand.Reader, nil),
+		store.New(t.TempDir()),
+	)
+	if _, _, err := d.Start(context.Background(), "1", decrypt.WithMetadata([]byte(`{"meeting":1}`))); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "called", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Stop"}
+	ctx := decrypt.ContextWithCaller(context.Background(), "mtls:vote-service")
+
+	t.Run("allowed", func(t *testing.T) {
+		p := &policyMock{decision: policy.Decision{Allow: true}}
+
+		resp, err := policyInterceptor(p, d, new(securityLogMock))(ctx, &StopRequest{Id: "1"}, info, handler)
+		if err != nil || resp != "called" {
+			t.Fatalf("interceptor returned %v, %v", resp, err)
+		}
+
+		input := p.inputs[0]
+		if input.Method != "Stop" || input.Caller != "mtls:vote-service" || input.PollID != "1" || string(input.Metadata) != `{"meeting":1}` || input.Time.IsZero() {
+			t.Errorf("policy got input %v", input)
+		}
+	})
+
+	t.Run("start metadata", func(t *testing.T) {
+		p := &policyMock{decision: policy.Decision{Allow: true}}
+		startInfo := &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Start"}
+
+		if _, err := policyInterceptor(p, d, new(securityLogMock))(ctx, &StartRequest{Id: "2", Metadata: []byte(`{"meeting":2}`)}, startInfo, handler); err != nil {
+			t.Fatalf("interceptor: %v", err)
+		}
+
+		if got := string(p.inputs[0].Metadata); got != `{"meeting":2}` {
+			t.Errorf("policy got metadata %s, expected the metadata of the request", got)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		p := &policyMock{decision: policy.Decision{Reason: "outside meeting hours"}}
+		securityLog := new(securityLogMock)
+
+		_, err := policyInterceptor(p, d, securityLog)(ctx, &StopRequest{Id: "1"}, info, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("interceptor returned %v, expected PermissionDenied", err)
+		}
+
+		if len(securityLog.events) != 1 || securityLog.events[0].Reason != "denied by policy: outside meeting hours" {
+			t.Errorf("got security events %v", securityLog.events)
+		}
+	})
+
+	t.Run("policy not available", func(t *testing.T) {
+		p := &policyMock{err: errors.New("connection refused")}
+
+		_, err := policyInterceptor(p, d, new(securityLogMock))(ctx, &StopRequest{Id: "1"}, info, handler)
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("interceptor returned %v, expected Unavailable", err)
+		}
+	})
+}
@@ -0,0 +1,256 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/metrics"
+	"github.com/OpenSlides/vote-decrypt/security"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	metricRequests = metrics.NewCounter(
+		"vote_decrypt_grpc_requests_total",
+		"Number of grpc requests.",
+		"method",
+	)
+
+	metricQuotaExceeded = metrics.NewCounter(
+		"vote_decrypt_quota_exceeded_total",
+		"Number of grpc requests that where rejected because of a quota.",
+		"method",
+	)
+)
+
+// Quota limits how often a grpc method can be called in a period.
+type Quota struct {
+	// Method is the name of the grpc method, for example `Start`.
+	Method string
+
+	// Limit is the number of allowed calls in each period.
+	Limit int
+
+	// Period is the length of the time window.
+	Period time.Duration
+
+	// PerPoll counts the calls for each poll id. Otherwise they are counted
+	// for each caller.
+	PerPoll bool
+}
+
+// ParseQuota parses a quota in the form `METHOD=LIMIT/PERIOD`, for example
+// `Start=100/1h`.
+//
+// With `METHOD:poll=LIMIT/PERIOD` the quota is counted for each poll instead
+// of each caller.
+func ParseQuota(value string) (Quota, error) {
+	method, rule, found := strings.Cut(value, "=")
+	if !found {
+		return Quota{}, fmt.Errorf("quota %q has no `=`", value)
+	}
+
+	var perPoll bool
+	if name, scope, found := strings.Cut(method, ":"); found {
+		if scope != "poll" {
+			return Quota{}, fmt.Errorf("quota %q has unknown scope %q", value, scope)
+		}
+		method = name
+		perPoll = true
+	}
+
+	rawLimit, rawPeriod, found := strings.Cut(rule, "/")
+	if !found {
+		return Quota{}, fmt.Errorf("quota %q has no `/`", value)
+	}
+
+	limit, err := strconv.Atoi(rawLimit)
+	if err != nil || limit < 0 {
+		return Quota{}, fmt.Errorf("quota %q has invalid limit %q", value, rawLimit)
+	}
+
+	period, err := time.ParseDuration(rawPeriod)
+	if err != nil || period <= 0 {
+		return Quota{}, fmt.Errorf("quota %q has invalid period %q", value, rawPeriod)
+	}
+
+	return Quota{
+		Method:  method,
+		Limit:   limit,
+		Period:  period,
+		PerPoll: perPoll,
+	}, nil
+}
+
+// WithQuotas sets quotas for grpc methods.
+func WithQuotas(quotas ...Quota) ServerOption {
+	return func(c *serverConfig) {
+		c.quotas = append(c.quotas, quotas...)
+	}
+}
+
+// quotaWindow counts the calls in one time window.
+type quotaWindow struct {
+	start time.Time
+	count int
+}
+
+// quotaLimiter counts the calls to grpc methods with fixed time windows.
+type quotaLimiter struct {
+	quotas map[string][]Quota
+
+	mu      sync.Mutex
+	windows map[string]*quotaWindow
+	now     func() time.Time
+}
+
+func newQuotaLimiter(quotas []Quota) *quotaLimiter {
+	byMethod := make(map[string][]Quota)
+	for _, q := range quotas {
+		byMethod[q.Method] = append(byMethod[q.Method], q)
+	}
+
+	return &quotaLimiter{
+		quotas:  byMethod,
+		windows: make(map[string]*quotaWindow),
+		now:     time.Now,
+	}
+}
+
+// allow returns false, if one of the quotas for the method is exceeded.
+//
+// The call is counted for all quotas of the method.
+func (l *quotaLimiter) allow(method, caller, pollID string) bool {
+	quotas := l.quotas[method]
+	if len(quotas) == 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	allowed := true
+	for i, q := range quotas {
+		scope := "caller:" + caller
+		if q.PerPoll {
+			scope = "poll:" + pollID
+		}
+
+		key := fmt.Sprintf("%s/%d/%s", method, i, scope)
+		window := l.windows[key]
+		if window == nil || now.Sub(window.start) >= q.Period {
+			window = &quotaWindow{start: now}
+			l.windows[key] = window
+		}
+
+		window.count++
+		if window.count > q.Limit {
+			allowed = false
+		}
+	}
+
+	return allowed
+}
+
+// prune removes expired windows if there are many of them.
+func (l *quotaLimiter) prune(now time.Time) {
+	if len(l.windows) < 10_000 {
+		return
+	}
+
+	var maxPeriod time.Duration
+	for _, quotas := range l.quotas {
+		for _, q := range quotas {
+			if q.Period > maxPeriod {
+				maxPeriod = q.Period
+			}
+		}
+	}
+
+	for key, window := range l.windows {
+		if now.Sub(window.start) >= maxPeriod {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// quotaInterceptor returns a grpc interceptor that rejects calls, that exceed
+// a quota.
+func quotaInterceptor(limiter *quotaLimiter, securityLog SecurityLog) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		method := methodName(info.FullMethod)
+		metricRequests.Inc(method)
+
+		var pollID string
+		if r, ok := req.(interface{ GetId() string }); ok {
+			pollID = r.GetId()
+		}
+
+		if !limiter.allow(method, callerAddr(ctx), pollID) {
+			metricQuotaExceeded.Inc(method)
+			reportQuota(securityLog, callerAddr(ctx), method)
+			return nil, status.Error(codes.ResourceExhausted, "quota exceeded")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// quotaStreamInterceptor is like quotaInterceptor() for streaming methods. The
+// quotas are only checked per method and caller, since the request is not
+// known yet.
+func quotaStreamInterceptor(limiter *quotaLimiter, securityLog SecurityLog) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		method := methodName(info.FullMethod)
+		metricRequests.Inc(method)
+
+		if !limiter.allow(method, callerAddr(ss.Context()), "") {
+			metricQuotaExceeded.Inc(method)
+			reportQuota(securityLog, callerAddr(ss.Context()), method)
+			return status.Error(codes.ResourceExhausted, "quota exceeded")
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// reportQuota reports a call, that exceeded a quota, to the security log.
+func reportQuota(securityLog SecurityLog, caller, method string) {
+	securityLog.Report(security.Event{
+		Kind:   security.RateLimit,
+		Source: caller,
+		Method: method,
+		Reason: "quota exceeded",
+	})
+}
+
+// methodName returns the name of a grpc method without the service, for
+// example `Start` for `/Decrypt/Start`.
+func methodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+// callerAddr returns the ip address of the caller.
+func callerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuota(t *testing.T) {
+	for _, tt := range []struct {
+		value  string
+		expect Quota
+		err    bool
+	}{
+		{"Start=100/1h", Quota{Method: "Start", Limit: 100, Period: time.Hour}, false},
+		{"Stop:poll=3/24h", Quota{Method: "Stop", Limit: 3, Period: 24 * time.Hour, PerPoll: true}, false},
+		{"Start", Quota{}, true},
+		{"Start=100", Quota{}, true},
+		{"Start=many/1h", Quota{}, true},
+		{"Start=100/ever", Quota{}, true},
+		{"Start:meeting=100/1h", Quota{}, true},
+	} {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseQuota(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Errorf("ParseQuota returned %v, expected an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseQuota: %v", err)
+			}
+
+			if got != tt.expect {
+				t.Errorf("ParseQuota returned %v, expected %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestQuotaLimiter(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newQuotaLimiter([]Quota{
+		{Method: "Start", Limit: 2, Period: time.Hour},
+		{Method: "Stop", Limit: 1, Period: time.Hour, PerPoll: true},
+	})
+	limiter.now = func() time.Time { return now }
+
+	t.Run("per caller", func(t *testing.T) {
+		if !limiter.allow("Start", "caller1", "1") || !limiter.allow("Start", "caller1", "2") {
+			t.Fatalf("first two calls where not allowed")
+		}
+
+		if limiter.allow("Start", "caller1", "3") {
+			t.Errorf("third call was allowed")
+		}
+
+		if !limiter.allow("Start", "caller2", "3") {
+			t.Errorf("call from other caller was not allowed")
+		}
+	})
+
+	t.Run("per poll", func(t *testing.T) {
+		if !limiter.allow("Stop", "caller1", "1") {
+			t.Fatalf("first call was not allowed")
+		}
+
+		if limiter.allow("Stop", "caller2", "1") {
+			t.Errorf("second call for the same poll was allowed")
+		}
+
+		if !limiter.allow("Stop", "caller1", "2") {
+			t.Errorf("call for other poll was not allowed")
+		}
+	})
+
+	t.Run("next period", func(t *testing.T) {
+		now = now.Add(time.Hour)
+
+		if !limiter.allow("Start", "caller1", "4") {
+			t.Errorf("call in next period was not allowed")
+		}
+	})
+
+	t.Run("method without quota", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			if !limiter.allow("Status", "caller1", "1") {
+				t.Fatalf("call %d was not allowed", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package grpc
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// idempotentMethods are the grpc methods that can be called more then once
+// with the same result. A Stop with the same votes returns the same result and
+// a Start for an existing poll returns the existing key.
+var idempotentMethods = map[string]bool{
+	"/Decrypt/PublicMainKey":     true,
+	"/Decrypt/Start":             true,
+	"/Decrypt/Stop":              true,
+	"/Decrypt/CoSign":            true,
+	"/Decrypt/Status":            true,
+	"/Decrypt/Attest":            true,
+	"/Decrypt/Version":           true,
+	"/Decrypt/CheckMainKey":      true,
+	"/Decrypt/InclusionProof":    true,
+	"/Decrypt/PublicKeys":        true,
+	"/Decrypt/StartElection":     true,
+	"/Decrypt/StopElection":      true,
+	"/Decrypt/PartialDecrypt":    true,
+	"/Decrypt/PollSigningKey":    true,
+	"/Decrypt/MeetingSigningKey": true,
+	"/Decrypt/Revoke":            true,
+	"/Decrypt/RevocationList":    true,
+	"/Decrypt/EffectiveConfig":   true,
+}
+
+// readMethods are the grpc methods that do not change anything on the server.
+// Only they are hedged.
+var readMethods = map[string]bool{
+	"/Decrypt/PublicMainKey":     true,
+	"/Decrypt/Status":            true,
+	"/Decrypt/Version":           true,
+	"/Decrypt/CheckMainKey":      true,
+	"/Decrypt/InclusionProof":    true,
+	"/Decrypt/PublicKeys":        true,
+	"/Decrypt/PollSigningKey":    true,
+	"/Decrypt/MeetingSigningKey": true,
+	"/Decrypt/RevocationList":    true,
+	"/Decrypt/EffectiveConfig":   true,
+}
+
+// RetryPolicy configures, how often a failed call is retried.
+//
+// Only calls of idempotent methods that fail with the code UNAVAILABLE are
+// retried. The first retry waits InitialBackoff. Each following retry waits
+// Multiplier times longer, but not longer then MaxBackoff. A random jitter of
+// up to 20% is added to each wait.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+// DefaultRetryPolicy is a retry policy for short network problems.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+}
+
+// WithRetry retries failed calls with the policy.
+func WithRetry(policy RetryPolicy) ClientOption {
+	return func(c *clientConfig) {
+		c.retry = policy
+	}
+}
+
+// WithHedging sends a second call of a read method, if there was no response
+// after delay. The first response is used.
+func WithHedging(delay time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.hedgeDelay = delay
+	}
+}
+
+// WithCircuitBreaker lets all calls fail immediately with the code
+// UNAVAILABLE, after threshold calls in a row failed with UNAVAILABLE. After
+// cooldown, one call is sent to the server. If it succeeds, the calls are sent
+// again.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.breaker = &circuitBreaker{
+			threshold: threshold,
+			cooldown:  cooldown,
+			now:       time.Now,
+		}
+	}
+}
+
+// unaryInterceptor returns the interceptor that implements the circuit
+// breaker, the retries and the hedging.
+func (c clientConfig) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = c.withLanguage(ctx)
+		call := func(ctx context.Context) error {
+			if c.breaker != nil && !c.breaker.allow() {
+				return status.Error(codes.Unavailable, "circuit breaker is open")
+			}
+
+			var err error
+			if c.hedgeDelay > 0 && readMethods[method] {
+				err = hedge(ctx, c.hedgeDelay, method, req, reply, cc, invoker, opts...)
+			} else {
+				err = invoker(ctx, method, req, reply, cc, opts...)
+			}
+
+			if c.breaker != nil {
+				c.breaker.record(status.Code(err) != codes.Unavailable)
+			}
+			return err
+		}
+
+		attempts := c.retry.MaxAttempts
+		if attempts < 1 || !idempotentMethods[method] {
+			attempts = 1
+		}
+
+		backoff := c.retry.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			err := call(ctx)
+			if err == nil || status.Code(err) != codes.Unavailable || attempt >= attempts {
+				return err
+			}
+
+			wait := backoff + time.Duration(rand.Int63n(int64(backoff)/5+1))
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+
+			backoff = time.Duration(float64(backoff) * c.retry.Multiplier)
+			if c.retry.MaxBackoff > 0 && backoff > c.retry.MaxBackoff {
+				backoff = c.retry.MaxBackoff
+			}
+		}
+	}
+}
+
+// hedge calls the method. If there is no response after delay, it calls the
+// method a second time and uses the first response.
+func hedge(ctx context.Context, delay time.Duration, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	msg, ok := reply.(proto.Message)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		reply proto.Message
+		err   error
+	}
+	results := make(chan result, 2)
+	send := func() {
+		r := msg.ProtoReflect().New().Interface()
+		err := invoker(ctx, method, req, r, cc, opts...)
+		results <- result{r, err}
+	}
+
+	go send()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			pending++
+			go send()
+
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				proto.Reset(msg)
+				proto.Merge(msg, r.reply)
+				return nil
+			}
+
+			// If the first call fails before the hedge was sent, the hedge
+			// is not sent. The retry policy decides about another call.
+			if pending == 0 {
+				return r.err
+			}
+		}
+	}
+}
+
+// circuitBreaker counts the failed calls in a row.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow returns true, if a call can be sent.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	if b.now().Before(b.openUntil) || b.probing {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// record counts the result of a call.
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
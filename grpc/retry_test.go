@@ -0,0 +1,119 @@
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func failingInvoker(failures int64, calls *atomic.Int64) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if calls.Add(1) <= failures {
+			return status.Error(codes.Unavailable, "connection lost")
+		}
+		return nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	config := clientConfig{retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2}}
+	interceptor := config.unaryInterceptor()
+
+	t.Run("idempotent", func(t *testing.T) {
+		var calls atomic.Int64
+		if err := interceptor(context.Background(), "/Decrypt/Stop", &StopRequest{}, &StopResponse{}, nil, failingInvoker(2, &calls)); err != nil {
+			t.Errorf("call returned: %v", err)
+		}
+
+		if got := calls.Load(); got != 3 {
+			t.Errorf("invoker was called %d times, expected 3", got)
+		}
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		var calls atomic.Int64
+		err := interceptor(context.Background(), "/Decrypt/Stop", &StopRequest{}, &StopResponse{}, nil, failingInvoker(5, &calls))
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("call returned `%v`, expected code Unavailable", err)
+		}
+
+		if got := calls.Load(); got != 3 {
+			t.Errorf("invoker was called %d times, expected 3", got)
+		}
+	})
+
+	t.Run("not idempotent", func(t *testing.T) {
+		var calls atomic.Int64
+		err := interceptor(context.Background(), "/Decrypt/Wipe", &WipeRequest{}, &EmptyMessage{}, nil, failingInvoker(1, &calls))
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("call returned `%v`, expected code Unavailable", err)
+		}
+
+		if got := calls.Load(); got != 1 {
+			t.Errorf("invoker was called %d times, expected 1", got)
+		}
+	})
+}
+
+func TestHedging(t *testing.T) {
+	config := clientConfig{hedgeDelay: 10 * time.Millisecond}
+	interceptor := config.unaryInterceptor()
+
+	var calls atomic.Int64
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+
+		reply.(*VersionResponse).Info = []byte("info")
+		return nil
+	}
+
+	reply := &VersionResponse{}
+	if err := interceptor(context.Background(), "/Decrypt/Version", &EmptyMessage{}, reply, nil, invoker); err != nil {
+		t.Fatalf("call returned: %v", err)
+	}
+
+	if string(reply.Info) != "info" {
+		t.Errorf("got info %q, expected the response of the hedged call", reply.Info)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("invoker was called %d times, expected 2", got)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	config := clientConfig{breaker: &circuitBreaker{threshold: 2, cooldown: time.Minute, now: func() time.Time { return now }}}
+	interceptor := config.unaryInterceptor()
+
+	var calls atomic.Int64
+	invoker := failingInvoker(2, &calls)
+	for i := 0; i < 3; i++ {
+		err := interceptor(context.Background(), "/Decrypt/Version", &EmptyMessage{}, &VersionResponse{}, nil, invoker)
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("call %d returned `%v`, expected code Unavailable", i, err)
+		}
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("invoker was called %d times, expected 2 while the breaker is open", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if err := interceptor(context.Background(), "/Decrypt/Version", &EmptyMessage{}, &VersionResponse{}, nil, invoker); err != nil {
+		t.Errorf("call after cooldown returned: %v", err)
+	}
+
+	if err := interceptor(context.Background(), "/Decrypt/Version", &EmptyMessage{}, &VersionResponse{}, nil, invoker); err != nil {
+		t.Errorf("call after closed breaker returned: %v", err)
+	}
+}
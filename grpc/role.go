@@ -0,0 +1,200 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/security"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Role is a set of grpc methods, that a caller is allowed to call.
+type Role string
+
+// The roles of callers.
+const (
+	// RoleVoteService can start, stop and clear polls, co-sign results,
+	// certify undecrypted polls and call the read methods. It is the role of
+	// the vote service of OpenSlides and of instances, that ask for
+	// co-signatures.
+	RoleVoteService Role = "vote-service"
+
+	// RoleAdmin can call all methods, including the admin methods like Wipe
+	// or Rekey.
+	RoleAdmin Role = "admin"
+
+	// RoleAuditor can only call the read methods and export the audit log.
+	RoleAuditor Role = "auditor"
+)
+
+// readRoleMethods are the methods, that do not change the state of the
+// service.
+var readRoleMethods = []string{
+	"PublicMainKey",
+	"Status",
+	"PublicKeys",
+	"Version",
+	"CheckMainKey",
+	"InclusionProof",
+	"PollSigningKey",
+	"MeetingSigningKey",
+	"RevocationList",
+	"Attest",
+}
+
+// roleMethods are the methods of each role. RoleAdmin can call all methods.
+var roleMethods = map[Role]map[string]bool{
+	RoleVoteService: methodSet(append([]string{
+		"Start",
+		"Stop",
+		"CoSign",
+		"Clear",
+		"StartElection",
+		"StopElection",
+		"ClearElection",
+		"StopMany",
+		"PartialDecrypt",
+		"IssueToken",
+		"NoDecryption",
+	}, readRoleMethods...)),
+	RoleAuditor: methodSet(append([]string{
+		"ExportAuditLog",
+		"EffectiveConfig",
+	}, readRoleMethods...)),
+}
+
+func methodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		set[method] = true
+	}
+	return set
+}
+
+// allows returns true, if the role can call the grpc method.
+func (r Role) allows(method string) bool {
+	if r == RoleAdmin {
+		return true
+	}
+	return roleMethods[r][method]
+}
+
+// RoleBinding gives a role to the callers with an identity.
+type RoleBinding struct {
+	// Identity is the identity of the caller, like it is written to the audit
+	// log, for example `mtls:vote-service` or `token:9f86d081884c7d65`. An
+	// identity ending with `*` matches all identities with this prefix.
+	Identity string
+
+	// Role is the role of the caller.
+	Role Role
+}
+
+// matches returns true, if the binding is for the identity.
+func (b RoleBinding) matches(identity string) bool {
+	if prefix, ok := strings.CutSuffix(b.Identity, "*"); ok {
+		return strings.HasPrefix(identity, prefix)
+	}
+	return b.Identity == identity
+}
+
+// ParseRoleBinding parses a role binding in the form `IDENTITY=ROLE`, for
+// example `mtls:vote-service=vote-service`.
+func ParseRoleBinding(value string) (RoleBinding, error) {
+	identity, role, found := strings.Cut(value, "=")
+	if !found {
+		return RoleBinding{}, fmt.Errorf("role %q has no `=`", value)
+	}
+
+	if identity == "" {
+		return RoleBinding{}, fmt.Errorf("role %q has no identity", value)
+	}
+
+	switch Role(role) {
+	case RoleVoteService, RoleAdmin, RoleAuditor:
+	default:
+		return RoleBinding{}, fmt.Errorf("role %q has unknown role %q", value, role)
+	}
+
+	return RoleBinding{Identity: identity, Role: Role(role)}, nil
+}
+
+// WithRoles enables role based authorization. Each call needs a caller with a
+// role, that allows the method. Callers with the admin token have the role
+// RoleAdmin.
+//
+// With roles, the admin methods do not need the admin token, if the caller
+// has a role, that allows them, for example RoleAdmin from a client
+// certificate.
+func WithRoles(bindings ...RoleBinding) ServerOption {
+	return func(c *serverConfig) {
+		c.roles = append(c.roles, bindings...)
+	}
+}
+
+// callerRole returns the role of a caller. It is the role of the first
+// binding, that matches the identity.
+func callerRole(bindings []RoleBinding, identity string) (Role, bool) {
+	if strings.HasPrefix(identity, "admin:") {
+		return RoleAdmin, true
+	}
+
+	for _, binding := range bindings {
+		if binding.matches(identity) {
+			return binding.Role, true
+		}
+	}
+	return "", false
+}
+
+// authorize returns an error, if the caller of ctx is not allowed to call the
+// method.
+func authorize(ctx context.Context, bindings []RoleBinding, fullMethod string, securityLog SecurityLog) error {
+	identity := decrypt.CallerFromContext(ctx)
+	method := methodName(fullMethod)
+
+	role, ok := callerRole(bindings, identity)
+	if !ok || !role.allows(method) {
+		reason := fmt.Sprintf("caller %s has no role", identity)
+		if ok {
+			reason = fmt.Sprintf("role %s can not call %s", role, method)
+		}
+
+		log.Printf("Authorization failed for %s: %s", fullMethod, reason)
+		securityLog.Report(security.Event{
+			Kind:   security.AuthFailure,
+			Source: callerAddr(ctx),
+			Method: method,
+			Reason: reason,
+		})
+		return status.Error(codes.PermissionDenied, reason)
+	}
+
+	return nil
+}
+
+// roleInterceptor returns a grpc interceptor, that makes sure, that the caller
+// has a role, that allows the method.
+func roleInterceptor(bindings []RoleBinding, securityLog SecurityLog) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := authorize(ctx, bindings, info.FullMethod, securityLog); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// roleStreamInterceptor is like roleInterceptor for streaming grpc methods.
+func roleStreamInterceptor(bindings []RoleBinding, securityLog SecurityLog) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), bindings, info.FullMethod, securityLog); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,142 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/security"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseRoleBinding(t *testing.T) {
+	for _, tt := range []struct {
+		value  string
+		expect RoleBinding
+		err    bool
+	}{
+		{"mtls:vote-service=vote-service", RoleBinding{Identity: "mtls:vote-service", Role: RoleVoteService}, false},
+		{"token:9f86d081884c7d65=auditor", RoleBinding{Identity: "token:9f86d081884c7d65", Role: RoleAuditor}, false},
+		{"mtls:*=admin", RoleBinding{Identity: "mtls:*", Role: RoleAdmin}, false},
+		{"mtls:vote-service", RoleBinding{}, true},
+		{"=admin", RoleBinding{}, true},
+		{"mtls:vote-service=root", RoleBinding{}, true},
+	} {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRoleBinding(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Errorf("ParseRoleBinding returned %v, expected an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseRoleBinding: %v", err)
+			}
+
+			if got != tt.expect {
+				t.Errorf("ParseRoleBinding returned %v, expected %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestRoleInterceptor(t *testing.T) {
+	securityLog := new(securityLogMock)
+	interceptor := roleInterceptor([]RoleBinding{
+		{Identity: "mtls:vote-service", Role: RoleVoteService},
+		{Identity: "mtls:auditor.*", Role: RoleAuditor},
+	}, securityLog)
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+
+	for _, tt := range []struct {
+		caller string
+		method string
+		allow  bool
+	}{
+		{"mtls:vote-service", "Stop", true},
+		{"mtls:vote-service", "Status", true},
+		{"mtls:vote-service", "Wipe", false},
+		{"mtls:auditor.example.com", "ExportAuditLog", true},
+		{"mtls:auditor.example.com", "Stop", false},
+		{"admin:16175223c8ddce5a", "Wipe", true},
+		{"addr:192.0.2.1", "Status", false},
+	} {
+		t.Run(tt.caller+" "+tt.method, func(t *testing.T) {
+			ctx := decrypt.ContextWithCaller(context.Background(), tt.caller)
+			info := &grpc.UnaryServerInfo{FullMethod: "/Decrypt/" + tt.method}
+
+			_, err := interceptor(ctx, nil, info, handler)
+
+			if tt.allow {
+				if err != nil {
+					t.Errorf("call returned %v, expected it to be allowed", err)
+				}
+				return
+			}
+
+			if status.Code(err) != codes.PermissionDenied {
+				t.Errorf("call returned %v, expected PermissionDenied", err)
+			}
+		})
+	}
+
+	if len(securityLog.events) != 3 {
+		t.Fatalf("got %d security events, expected 3", len(securityLog.events))
+	}
+
+	if event := securityLog.events[0]; event.Kind != security.AuthFailure || event.Method != "Wipe" {
+		t.Errorf("got event %v, expected an auth failure for Wipe", event)
+	}
+}
+
+func TestRoleAdminTokenWithCertificate(t *testing.T) {
+	identity := identityInterceptor("admin-secret")
+	role := roleInterceptor([]RoleBinding{{Identity: "mtls:vote-service", Role: RoleVoteService}}, new(securityLogMock))
+
+	ctx := metadata.NewIncomingContext(
+		peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{{DNSNames: []string{"vote-service"}}}},
+			}},
+		}),
+		metadata.Pairs("authorization", "Bearer admin-secret"),
+	)
+	info := &grpc.UnaryServerInfo{FullMethod: "/Decrypt/Wipe"}
+
+	_, err := identity(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return role(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+	})
+	if err != nil {
+		t.Errorf("call with client certificate and admin token returned %v, expected it to be allowed", err)
+	}
+}
+
+func TestRoleMethods(t *testing.T) {
+	for _, method := range Decrypt_ServiceDesc.Methods {
+		if !RoleAdmin.allows(method.MethodName) {
+			t.Errorf("admin can not call %s", method.MethodName)
+		}
+
+		if adminMethods["/Decrypt/"+method.MethodName] && RoleVoteService.allows(method.MethodName) {
+			t.Errorf("vote service can call admin method %s", method.MethodName)
+		}
+	}
+
+	if RoleAuditor.allows("NoDecryption") {
+		t.Errorf("auditor can call NoDecryption, that writes to the audit log")
+	}
+}
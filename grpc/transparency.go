@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/OpenSlides/vote-decrypt/rekor"
+)
+
+// TransparencyLog adds the hash of a signed result to a transparency log and
+// returns the entry with its inclusion proof. See package rekor.
+type TransparencyLog interface {
+	Upload(ctx context.Context, content, signature, pubKey []byte) (rekor.Entry, error)
+}
+
+// WithTransparencyLog adds each result of Stop to the transparency log. The
+// entry is added to the response, so the Go client adds it to the
+// ResultEnvelope.
+//
+// If the log fails, the result is returned without an entry. Stop can be
+// called again to get one.
+func WithTransparencyLog(l TransparencyLog) ServerOption {
+	return func(c *serverConfig) {
+		c.transparencyLog = l
+	}
+}
+
+// transparencyEntry converts an entry of the log to its grpc message.
+func transparencyEntry(entry rekor.Entry) *TransparencyEntry {
+	return &TransparencyEntry{
+		Uuid:                 entry.UUID,
+		LogId:                entry.LogID,
+		LogIndex:             entry.LogIndex,
+		IntegratedTime:       entry.IntegratedTime,
+		Body:                 entry.Body,
+		SignedEntryTimestamp: entry.SignedEntryTimestamp,
+		TreeIndex:            entry.TreeIndex,
+		TreeSize:             entry.TreeSize,
+		RootHash:             entry.RootHash,
+		Hashes:               entry.Hashes,
+		Checkpoint:           entry.Checkpoint,
+	}
+}
+
+// rekorEntry converts the grpc message to an entry of the log. It returns nil,
+// if the message is nil.
+func rekorEntry(entry *TransparencyEntry) *rekor.Entry {
+	if entry == nil {
+		return nil
+	}
+
+	return &rekor.Entry{
+		UUID:                 entry.Uuid,
+		LogID:                entry.LogId,
+		LogIndex:             entry.LogIndex,
+		IntegratedTime:       entry.IntegratedTime,
+		Body:                 entry.Body,
+		SignedEntryTimestamp: entry.SignedEntryTimestamp,
+		TreeIndex:            entry.TreeIndex,
+		TreeSize:             entry.TreeSize,
+		RootHash:             entry.RootHash,
+		Hashes:               entry.Hashes,
+		Checkpoint:           entry.Checkpoint,
+	}
+}
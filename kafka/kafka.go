@@ -0,0 +1,270 @@
+// Package kafka publishes the results of stopped polls to a kafka topic.
+//
+// The producer uses franz-go with a transactional id. The records of a poll
+// are published in one transaction: either all of them are committed or none.
+// If publishing fails, for example because a broker is not reachable or the
+// process is killed, the transaction is aborted and consumers with the
+// isolation level read_committed do not see any record of it. All records of
+// a poll use the poll id as key and are written to the same partition.
+//
+// This gives exactly once semantics for each successful Stop() call. If Stop()
+// is called again for a poll, the writer receives the result again and it is
+// committed again in a new transaction. Consumers should use the poll id for
+// deduplication, for example with a compacted topic.
+//
+// Only one instance should publish with the same transactional id. A new
+// producer with the same id fences the old one, the old one fails with
+// PRODUCER_FENCED.
+package kafka
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	decryptgrpc "github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+)
+
+// DefaultTransactionalID is used, if the config has no transactional id.
+const DefaultTransactionalID = "vote-decrypt"
+
+// Config configures the producer.
+type Config struct {
+	// Brokers are the addresses of bootstrap brokers as `HOST:PORT`.
+	Brokers []string
+
+	// Topic for the results. It has to exist.
+	Topic string
+
+	// PerVote publishes each decrypted vote as its own record, followed by a
+	// record with the signature. Otherwise, only one record with the result
+	// envelope is published for each poll.
+	PerVote bool
+
+	// TransactionalID of the producer. Defaults to DefaultTransactionalID.
+	TransactionalID string
+
+	// TLS is used for the connections to the brokers, if it is not nil.
+	TLS *tls.Config
+
+	// SASL is used to authenticate at the brokers, if it is not nil, for
+	// example a mechanism from the packages sasl/plain or sasl/scram of
+	// franz-go.
+	SASL sasl.Mechanism
+
+	// Timeout for producing the records of one poll. It is also the timeout
+	// of the transaction at the broker. Defaults to one minute.
+	Timeout time.Duration
+}
+
+// client is the part of kgo.Client, that the producer uses.
+type client interface {
+	BeginTransaction() error
+	ProduceSync(ctx context.Context, rs ...*kgo.Record) kgo.ProduceResults
+	AbortBufferedRecords(ctx context.Context) error
+	EndTransaction(ctx context.Context, commit kgo.TransactionEndTry) error
+	Close()
+}
+
+// Producer publishes results to kafka. It implements decrypt.ResultWriter.
+//
+// Each record has the header `type`. It is `result` for a record with a
+// grpc.ResultEnvelope in its canonical json encoding, `vote` for a decrypted
+// vote and `end` for the last record of a poll in per vote mode.
+type Producer struct {
+	config Config
+	client client
+
+	// txMu makes sure, that only one transaction is open at a time.
+	txMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string][]*kgo.Record // Votes of each poll, that are published with Finish().
+}
+
+// New creates the producer and checks, that a broker is reachable.
+func New(config Config) (*Producer, error) {
+	if len(config.Brokers) == 0 {
+		return nil, errors.New("no brokers")
+	}
+
+	if config.Topic == "" {
+		return nil, errors.New("no topic")
+	}
+
+	if config.TransactionalID == "" {
+		config.TransactionalID = DefaultTransactionalID
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = time.Minute
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(config.Brokers...),
+		kgo.DefaultProduceTopic(config.Topic),
+		kgo.TransactionalID(config.TransactionalID),
+		kgo.TransactionTimeout(config.Timeout),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+	}
+
+	if config.TLS != nil {
+		opts = append(opts, kgo.DialTLSConfig(config.TLS))
+	}
+
+	if config.SASL != nil {
+		opts = append(opts, kgo.SASL(config.SASL))
+	}
+
+	cl, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.Ping(ctx); err != nil {
+		cl.Close()
+		return nil, fmt.Errorf("connecting to brokers: %w", err)
+	}
+
+	return newProducer(config, cl), nil
+}
+
+func newProducer(config Config, cl client) *Producer {
+	return &Producer{
+		config:  config,
+		client:  cl,
+		pending: make(map[string][]*kgo.Record),
+	}
+}
+
+// Close closes the connections. An open transaction is aborted by the broker
+// after its timeout.
+func (p *Producer) Close() error {
+	p.client.Close()
+	return nil
+}
+
+// WriteVote remembers a decrypted vote in per vote mode. The votes are
+// published with Finish() in the same transaction as the end record.
+func (p *Producer) WriteVote(pollID string, vote []byte, weight string) error {
+	if !p.config.PerVote {
+		return nil
+	}
+
+	headers := []kgo.RecordHeader{{Key: "type", Value: []byte("vote")}}
+	if weight != "" {
+		headers = append(headers, kgo.RecordHeader{Key: "weight", Value: []byte(weight)})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending[pollID] = append(p.pending[pollID], &kgo.Record{Key: []byte(pollID), Value: vote, Headers: headers})
+	return nil
+}
+
+// Finish publishes the result of a poll in one transaction.
+//
+// Without per vote mode, it publishes the result envelope. In per vote mode,
+// it publishes the votes and a record with the number of votes, the sha256
+// hash of the content and the signature. The content itself is not published,
+// since it can be bigger then the limit of the broker.
+func (p *Producer) Finish(pollID string, content, signature []byte) error {
+	p.mu.Lock()
+	records := p.pending[pollID]
+	delete(p.pending, pollID)
+	p.mu.Unlock()
+
+	if !p.config.PerVote {
+		value, err := json.Marshal(decryptgrpc.ResultEnvelope{ID: pollID, Content: content, Signature: signature})
+		if err != nil {
+			return fmt.Errorf("encoding envelope: %w", err)
+		}
+
+		last := &kgo.Record{Key: []byte(pollID), Value: value, Headers: []kgo.RecordHeader{{Key: "type", Value: []byte("result")}}}
+		if err := p.publish([]*kgo.Record{last}); err != nil {
+			return fmt.Errorf("publishing result: %w", err)
+		}
+		return nil
+	}
+
+	votes := len(records)
+	hash := sha256.Sum256(content)
+	value, err := json.Marshal(struct {
+		ID          string `json:"id"`
+		Votes       int    `json:"votes"`
+		ContentHash string `json:"content_hash"`
+		Signature   string `json:"signature"`
+	}{
+		pollID,
+		votes,
+		base64.RawURLEncoding.EncodeToString(hash[:]),
+		base64.RawURLEncoding.EncodeToString(signature),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding end record: %w", err)
+	}
+
+	last := &kgo.Record{
+		Key:   []byte(pollID),
+		Value: value,
+		Headers: []kgo.RecordHeader{
+			{Key: "type", Value: []byte("end")},
+			{Key: "votes", Value: []byte(strconv.Itoa(votes))},
+		},
+	}
+	if err := p.publish(append(records, last)); err != nil {
+		return fmt.Errorf("publishing result: %w", err)
+	}
+	return nil
+}
+
+// publish produces the records in one transaction and commits it. If
+// something fails, the transaction is aborted.
+func (p *Producer) publish(records []*kgo.Record) error {
+	p.txMu.Lock()
+	defer p.txMu.Unlock()
+
+	if err := p.client.BeginTransaction(); err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+
+	if err := p.client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		return p.abort(fmt.Errorf("producing: %w", err))
+	}
+
+	// The context of EndTransaction is not canceled. Otherwise it is unknown,
+	// if the commit was successful.
+	if err := p.client.EndTransaction(context.Background(), kgo.TryCommit); err != nil {
+		return p.abort(fmt.Errorf("committing transaction: %w", err))
+	}
+	return nil
+}
+
+// abort aborts the open transaction and returns err.
+func (p *Producer) abort(err error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+	if abortErr := p.client.AbortBufferedRecords(ctx); abortErr != nil {
+		return fmt.Errorf("%w: aborting buffered records: %w", err, abortErr)
+	}
+
+	if abortErr := p.client.EndTransaction(context.Background(), kgo.TryAbort); abortErr != nil {
+		return fmt.Errorf("%w: aborting transaction: %w", err, abortErr)
+	}
+	return err
+}
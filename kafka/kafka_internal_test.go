@@ -0,0 +1,244 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	decryptgrpc "github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// fakeClient records the transactions of a producer. Records are only
+// committed, if the transaction is committed.
+type fakeClient struct {
+	produceErr error
+	commitErr  error
+
+	mu        sync.Mutex
+	inTxn     bool
+	open      []*kgo.Record
+	committed [][]*kgo.Record // Records of each committed transaction.
+	aborted   int
+}
+
+func (c *fakeClient) BeginTransaction() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inTxn {
+		return errors.New("transaction already open")
+	}
+	c.inTxn = true
+	return nil
+}
+
+func (c *fakeClient) ProduceSync(ctx context.Context, rs ...*kgo.Record) kgo.ProduceResults {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := make(kgo.ProduceResults, len(rs))
+	for i, r := range rs {
+		results[i] = kgo.ProduceResult{Record: r, Err: c.produceErr}
+		if !c.inTxn {
+			results[i].Err = errors.New("not in a transaction")
+		}
+		if results[i].Err == nil {
+			c.open = append(c.open, r)
+		}
+	}
+	return results
+}
+
+func (c *fakeClient) AbortBufferedRecords(ctx context.Context) error {
+	return nil
+}
+
+func (c *fakeClient) EndTransaction(ctx context.Context, commit kgo.TransactionEndTry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.inTxn {
+		return nil
+	}
+
+	if commit == kgo.TryCommit && c.commitErr != nil {
+		return c.commitErr
+	}
+
+	c.inTxn = false
+	if commit == kgo.TryCommit {
+		c.committed = append(c.committed, c.open)
+	} else {
+		c.aborted++
+	}
+	c.open = nil
+	return nil
+}
+
+func (c *fakeClient) Close() {}
+
+func header(r *kgo.Record, key string) string {
+	for _, h := range r.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestProducerEnvelope(t *testing.T) {
+	client := new(fakeClient)
+	producer := newProducer(Config{Topic: "results"}, client)
+
+	if err := producer.WriteVote("poll/1", []byte(`"Y"`), ""); err != nil {
+		t.Fatalf("WriteVote: %v", err)
+	}
+
+	if err := producer.Finish("poll/1", []byte("content"), []byte("signature")); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+
+	if len(client.committed) != 1 || len(client.committed[0]) != 1 {
+		t.Fatalf("got committed transactions %v, expected one with one record", client.committed)
+	}
+
+	record := client.committed[0][0]
+	if string(record.Key) != "poll/1" || header(record, "type") != "result" {
+		t.Errorf("got record with key %s and type %s", record.Key, header(record, "type"))
+	}
+
+	var envelope decryptgrpc.ResultEnvelope
+	if err := json.Unmarshal(record.Value, &envelope); err != nil {
+		t.Fatalf("decoding envelope: %v", err)
+	}
+
+	if envelope.ID != "poll/1" || string(envelope.Content) != "content" || string(envelope.Signature) != "signature" {
+		t.Errorf("got envelope %v", envelope)
+	}
+}
+
+func TestProducerPerVote(t *testing.T) {
+	client := new(fakeClient)
+	producer := newProducer(Config{Topic: "results", PerVote: true}, client)
+
+	votes := 2500
+	for i := 0; i < votes; i++ {
+		if err := producer.WriteVote("poll/1", []byte(strconv.Itoa(i)), "2"); err != nil {
+			t.Fatalf("WriteVote: %v", err)
+		}
+	}
+
+	if len(client.committed) != 0 || client.inTxn {
+		t.Fatalf("votes were published before Finish")
+	}
+
+	if err := producer.Finish("poll/1", []byte("content"), []byte("signature")); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+
+	if len(client.committed) != 1 {
+		t.Fatalf("got %d transactions, expected 1", len(client.committed))
+	}
+
+	records := client.committed[0]
+	if len(records) != votes+1 {
+		t.Fatalf("got %d records, expected %d", len(records), votes+1)
+	}
+
+	for i, r := range records[:votes] {
+		if string(r.Key) != "poll/1" || string(r.Value) != strconv.Itoa(i) || header(r, "type") != "vote" || header(r, "weight") != "2" {
+			t.Errorf("record %d is %v", i, r)
+		}
+	}
+
+	end := records[votes]
+	if header(end, "type") != "end" || header(end, "votes") != strconv.Itoa(votes) {
+		t.Errorf("last record has headers %v", end.Headers)
+	}
+}
+
+func TestProducerAbort(t *testing.T) {
+	t.Run("produce fails", func(t *testing.T) {
+		client := &fakeClient{produceErr: errors.New("broker not reachable")}
+		producer := newProducer(Config{Topic: "results", PerVote: true}, client)
+
+		if err := producer.WriteVote("poll/1", []byte(`"Y"`), ""); err != nil {
+			t.Fatalf("WriteVote: %v", err)
+		}
+
+		if err := producer.Finish("poll/1", []byte("content"), []byte("signature")); err == nil {
+			t.Fatalf("Finish did not fail")
+		}
+
+		if len(client.committed) != 0 || client.aborted != 1 {
+			t.Errorf("got %d committed and %d aborted transactions, expected only one aborted", len(client.committed), client.aborted)
+		}
+
+		client.produceErr = nil
+		if err := producer.Finish("poll/2", []byte("content"), []byte("signature")); err != nil {
+			t.Fatalf("Finish after abort: %v", err)
+		}
+
+		if len(client.committed) != 1 || len(client.committed[0]) != 1 {
+			t.Errorf("the transaction after an abort has the records %v, expected only the end record", client.committed)
+		}
+	})
+
+	t.Run("commit fails", func(t *testing.T) {
+		client := &fakeClient{commitErr: errors.New("coordinator not available")}
+		producer := newProducer(Config{Topic: "results"}, client)
+
+		if err := producer.Finish("poll/1", []byte("content"), []byte("signature")); err == nil {
+			t.Fatalf("Finish did not fail")
+		}
+
+		if len(client.committed) != 0 || client.aborted != 1 {
+			t.Errorf("got %d committed and %d aborted transactions, expected only one aborted", len(client.committed), client.aborted)
+		}
+	})
+}
+
+func TestProducerConcurrentPolls(t *testing.T) {
+	client := new(fakeClient)
+	producer := newProducer(Config{Topic: "results", PerVote: true}, client)
+
+	polls := 10
+	var wg sync.WaitGroup
+	for i := 0; i < polls; i++ {
+		wg.Add(1)
+		go func(pollID string) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := producer.WriteVote(pollID, []byte(strconv.Itoa(j)), ""); err != nil {
+					t.Errorf("WriteVote: %v", err)
+				}
+			}
+
+			if err := producer.Finish(pollID, []byte("content"), []byte("signature")); err != nil {
+				t.Errorf("Finish: %v", err)
+			}
+		}("poll/" + strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	if len(client.committed) != polls {
+		t.Fatalf("got %d transactions, expected %d", len(client.committed), polls)
+	}
+
+	for _, records := range client.committed {
+		if len(records) != 11 {
+			t.Errorf("transaction has %d records, expected 11", len(records))
+		}
+
+		for _, r := range records {
+			if string(r.Key) != string(records[0].Key) {
+				t.Errorf("transaction has records for %s and %s", records[0].Key, r.Key)
+				break
+			}
+		}
+	}
+}
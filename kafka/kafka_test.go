@@ -0,0 +1,23 @@
+package kafka_test
+
+import (
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/kafka"
+)
+
+func TestNewInvalidConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config kafka.Config
+	}{
+		{"no brokers", kafka.Config{Topic: "results"}},
+		{"no topic", kafka.Config{Brokers: []string{"localhost:9092"}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := kafka.New(tt.config); err == nil {
+				t.Errorf("New did not fail")
+			}
+		})
+	}
+}
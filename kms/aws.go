@@ -0,0 +1,226 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AWSConfig configures the AWS KMS signer.
+type AWSConfig struct {
+	// KeyID is the id, the ARN or an alias of an asymmetric KMS key with the
+	// key spec ECC_NIST_P256, RSA_2048, RSA_3072 or RSA_4096. RSA keys sign
+	// with RSASSA_PSS_SHA_256.
+	KeyID string
+
+	// Region of the key, for example `eu-central-1`.
+	Region string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Endpoint of the KMS api. Defaults to `https://kms.REGION.amazonaws.com`.
+	Endpoint string
+}
+
+// AWS signs with a key from AWS KMS.
+type AWS struct {
+	config AWSConfig
+	client *http.Client
+	public []byte
+	now    func() time.Time
+
+	signingAlgorithm string
+}
+
+// NewAWS initializes the AWS KMS signer and fetches the public key.
+func NewAWS(ctx context.Context, config AWSConfig) (*AWS, error) {
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com", config.Region)
+	}
+
+	a := &AWS{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+
+	var resp struct {
+		KeySpec   string `json:"KeySpec"`
+		PublicKey []byte `json:"PublicKey"`
+	}
+	if err := a.call(ctx, "GetPublicKey", map[string]string{"KeyId": config.KeyID}, &resp); err != nil {
+		return nil, fmt.Errorf("fetching public key: %w", err)
+	}
+
+	switch resp.KeySpec {
+	case "ECC_NIST_P256":
+		a.signingAlgorithm = "ECDSA_SHA_256"
+	case "RSA_2048", "RSA_3072", "RSA_4096":
+		a.signingAlgorithm = "RSASSA_PSS_SHA_256"
+	default:
+		return nil, fmt.Errorf("unsupported key spec %s, supported are ECC_NIST_P256, RSA_2048, RSA_3072 and RSA_4096", resp.KeySpec)
+	}
+
+	public, err := publicKeyFromDER(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading public key: %w", err)
+	}
+	a.public = public
+
+	return a, nil
+}
+
+// Public returns the public key in the uncompressed form or as PKIX, ASN.1 DER
+// for RSA keys.
+func (a *AWS) Public() []byte {
+	return a.public
+}
+
+// Sign returns the signature for the message as r and s or as RSA-PSS
+// signature.
+func (a *AWS) Sign(message []byte) ([]byte, error) {
+	req := map[string]string{
+		"KeyId":            a.config.KeyID,
+		"Message":          base64.StdEncoding.EncodeToString(message),
+		"MessageType":      "RAW",
+		"SigningAlgorithm": a.signingAlgorithm,
+	}
+
+	var resp struct {
+		Signature []byte `json:"Signature"`
+	}
+	if err := a.call(context.Background(), "Sign", req, &resp); err != nil {
+		return nil, fmt.Errorf("signing with aws kms: %w", err)
+	}
+
+	if a.signingAlgorithm == "RSASSA_PSS_SHA_256" {
+		return resp.Signature, nil
+	}
+	return rawECDSASignature(resp.Signature)
+}
+
+// call sends a request to the KMS api signed with AWS signature version 4.
+func (a *AWS) call(ctx context.Context, action string, request any, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("encoding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.config.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	if err := a.signRequest(req, body); err != nil {
+		return fmt.Errorf("signing request: %w", err)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("aws kms returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, response); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
+
+// signRequest adds the headers for AWS signature version 4.
+func (a *AWS) signRequest(req *http.Request, body []byte) error {
+	endpoint, err := url.Parse(a.config.Endpoint)
+	if err != nil {
+		return fmt.Errorf("parsing endpoint: %w", err)
+	}
+
+	now := a.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", endpoint.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if a.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.config.SessionToken)
+	}
+
+	signedHeaders := []string{"content-type", "host", "x-amz-date"}
+	if a.config.SessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+	}
+	signedHeaders = append(signedHeaders, "x-amz-target")
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = endpoint.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/kms/aws4_request", date, a.config.Region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+a.config.SecretAccessKey), date)
+	key = hmacSHA256(key, a.config.Region)
+	key = hmacSHA256(key, "kms")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.config.AccessKeyID,
+		scope,
+		strings.Join(signedHeaders, ";"),
+		signature,
+	))
+
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
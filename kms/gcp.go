@@ -0,0 +1,202 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// GCPConfig configures the Google Cloud KMS signer.
+type GCPConfig struct {
+	// KeyVersion is the resource name of the key version, for example
+	// `projects/P/locations/L/keyRings/R/cryptoKeys/K/cryptoKeyVersions/1`.
+	//
+	// The key has to use the algorithm EC_SIGN_ED25519, EC_SIGN_P256_SHA256
+	// or one of RSA_SIGN_PSS_2048_SHA256, RSA_SIGN_PSS_3072_SHA256 and
+	// RSA_SIGN_PSS_4096_SHA256.
+	KeyVersion string
+
+	// AccessToken for the KMS api. If empty, the token of the default service
+	// account is fetched from the metadata server.
+	AccessToken string
+
+	// Endpoint of the KMS api. Defaults to `https://cloudkms.googleapis.com`.
+	Endpoint string
+
+	// MetadataEndpoint is the address of the metadata server. Defaults to
+	// `http://metadata.google.internal`.
+	MetadataEndpoint string
+}
+
+// GCP signs with a key from Google Cloud KMS.
+type GCP struct {
+	config    GCPConfig
+	client    *http.Client
+	public    []byte
+	algorithm string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpire time.Time
+}
+
+// NewGCP initializes the Google Cloud KMS signer and fetches the public key.
+func NewGCP(ctx context.Context, config GCPConfig) (*GCP, error) {
+	if config.Endpoint == "" {
+		config.Endpoint = "https://cloudkms.googleapis.com"
+	}
+
+	if config.MetadataEndpoint == "" {
+		config.MetadataEndpoint = "http://metadata.google.internal"
+	}
+
+	g := &GCP{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var resp struct {
+		PEM       string `json:"pem"`
+		Algorithm string `json:"algorithm"`
+	}
+	if err := g.call(ctx, http.MethodGet, "/publicKey", nil, &resp); err != nil {
+		return nil, fmt.Errorf("fetching public key: %w", err)
+	}
+
+	switch resp.Algorithm {
+	case "EC_SIGN_ED25519", "EC_SIGN_P256_SHA256", "RSA_SIGN_PSS_2048_SHA256", "RSA_SIGN_PSS_3072_SHA256", "RSA_SIGN_PSS_4096_SHA256":
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", resp.Algorithm)
+	}
+	g.algorithm = resp.Algorithm
+
+	public, err := publicKeyFromPEM(resp.PEM)
+	if err != nil {
+		return nil, fmt.Errorf("reading public key: %w", err)
+	}
+	g.public = public
+
+	return g, nil
+}
+
+// Public returns the public key.
+func (g *GCP) Public() []byte {
+	return g.public
+}
+
+// Sign returns the signature for the message.
+func (g *GCP) Sign(message []byte) ([]byte, error) {
+	var req any
+	if g.algorithm == "EC_SIGN_ED25519" {
+		req = map[string][]byte{"data": message}
+	} else {
+		hash := sha256.Sum256(message)
+		req = map[string]map[string][]byte{"digest": {"sha256": hash[:]}}
+	}
+
+	var resp struct {
+		Signature []byte `json:"signature"`
+	}
+	if err := g.call(context.Background(), http.MethodPost, ":asymmetricSign", req, &resp); err != nil {
+		return nil, fmt.Errorf("signing with gcp kms: %w", err)
+	}
+
+	if g.algorithm != "EC_SIGN_P256_SHA256" {
+		return resp.Signature, nil
+	}
+	return rawECDSASignature(resp.Signature)
+}
+
+// call sends a request for the key version to the KMS api.
+func (g *GCP) call(ctx context.Context, method, suffix string, request any, response any) error {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+
+	var body io.Reader
+	if request != nil {
+		encoded, err := json.Marshal(request)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s%s", g.config.Endpoint, g.config.KeyVersion, suffix)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return g.do(req, response)
+}
+
+// accessToken returns the configured access token or fetches one from the
+// metadata server.
+func (g *GCP) accessToken(ctx context.Context) (string, error) {
+	if g.config.AccessToken != "" {
+		return g.config.AccessToken, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.token != "" && time.Now().Before(g.tokenExpire) {
+		return g.token, nil
+	}
+
+	url := g.config.MetadataEndpoint + "/computeMetadata/v1/instance/service-accounts/default/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := g.do(req, &resp); err != nil {
+		return "", fmt.Errorf("fetching token from metadata server: %w", err)
+	}
+
+	g.token = resp.AccessToken
+	// Renew the token one minute before it expires.
+	g.tokenExpire = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - time.Minute)
+	return g.token, nil
+}
+
+func (g *GCP) do(req *http.Request, response any) error {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, response); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
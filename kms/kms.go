@@ -0,0 +1,70 @@
+// Package kms implements signers for the main key, that delegate the signing
+// to a cloud key management service.
+//
+// The signers implement the crypto.Signer interface. Supported are AWS KMS and
+// Google Cloud KMS.
+//
+// The public key is fetched from the key management service on
+// initialization. ed25519 keys are used as they are. For ECDSA P-256 keys, the
+// public key is returned in the uncompressed form (65 bytes) and the
+// signatures are converted from ASN.1 to r and s as 32 byte big endian values
+// (64 bytes). RSA keys are returned in the PKIX, ASN.1 DER form and sign with
+// RSASSA-PSS and SHA-256. All signatures can be checked with crypto.Verify().
+package kms
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+)
+
+// publicKeyFromDER converts a DER encoded public key in the PKIX format to the
+// format used by the decrypt service.
+func publicKeyFromDER(der []byte) ([]byte, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key: %w", err)
+	}
+
+	return crypto.EncodePublicKey(key)
+}
+
+// publicKeyFromPEM works like publicKeyFromDER but for a PEM encoded key.
+func publicKeyFromPEM(encoded string) ([]byte, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem data")
+	}
+
+	return publicKeyFromDER(block.Bytes)
+}
+
+// rawECDSASignature converts an ASN.1 encoded ECDSA P-256 signature to r and s
+// as 32 byte big endian values.
+func rawECDSASignature(der []byte) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signature: %w", err)
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("signature has trailing data")
+	}
+
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.BitLen() > 256 || sig.S.BitLen() > 256 {
+		return nil, fmt.Errorf("invalid signature values")
+	}
+
+	raw := make([]byte, 64)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:])
+	return raw, nil
+}
@@ -0,0 +1,210 @@
+package kms_test
+
+import (
+	"context"
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/kms"
+)
+
+func TestAWS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("creating key: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("encoding public key: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var req struct {
+			KeyID   string `json:"KeyId"`
+			Message []byte `json:"Message"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			json.NewEncoder(w).Encode(map[string]any{"KeySpec": "ECC_NIST_P256", "PublicKey": der})
+
+		case "TrentService.Sign":
+			hash := sha256.Sum256(req.Message)
+			sig, _ := ecdsa.SignASN1(rand.Reader, key, hash[:])
+			json.NewEncoder(w).Encode(map[string]any{"Signature": sig})
+
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	signer, err := kms.NewAWS(context.Background(), kms.AWSConfig{
+		KeyID:           "alias/main",
+		Region:          "eu-central-1",
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		Endpoint:        srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewAWS: %v", err)
+	}
+
+	if len(signer.Public()) != 65 {
+		t.Errorf("public key has %d bytes, expected 65", len(signer.Public()))
+	}
+
+	message := []byte("my message")
+	sig, err := signer.Sign(message)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	if !crypto.Verify(signer.Public(), message, sig) {
+		t.Errorf("signature is not valid")
+	}
+}
+
+func TestAWSRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("creating key: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("encoding public key: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Message          []byte `json:"Message"`
+			SigningAlgorithm string `json:"SigningAlgorithm"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			json.NewEncoder(w).Encode(map[string]any{"KeySpec": "RSA_2048", "PublicKey": der})
+
+		case "TrentService.Sign":
+			if req.SigningAlgorithm != "RSASSA_PSS_SHA_256" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			hash := sha256.Sum256(req.Message)
+			sig, _ := rsa.SignPSS(rand.Reader, key, stdcrypto.SHA256, hash[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+			json.NewEncoder(w).Encode(map[string]any{"Signature": sig})
+
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	signer, err := kms.NewAWS(context.Background(), kms.AWSConfig{
+		KeyID:           "alias/main",
+		Region:          "eu-central-1",
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		Endpoint:        srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewAWS: %v", err)
+	}
+
+	algorithm, err := crypto.KeyAlgorithm(signer.Public())
+	if err != nil || algorithm != crypto.RSAPSSSHA256 {
+		t.Errorf("KeyAlgorithm returned %q, %v, expected %q", algorithm, err, crypto.RSAPSSSHA256)
+	}
+
+	message := []byte("my message")
+	sig, err := signer.Sign(message)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	if !crypto.Verify(signer.Public(), message, sig) {
+		t.Errorf("signature is not valid")
+	}
+}
+
+func TestGCP(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("creating key: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("encoding public key: %v", err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	keyVersion := "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/" + keyVersion + "/publicKey":
+			json.NewEncoder(w).Encode(map[string]any{"pem": string(pemKey), "algorithm": "EC_SIGN_ED25519"})
+
+		case "/v1/" + keyVersion + ":asymmetricSign":
+			var req struct {
+				Data []byte `json:"data"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(map[string]any{"signature": ed25519.Sign(priv, req.Data)})
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	signer, err := kms.NewGCP(context.Background(), kms.GCPConfig{
+		KeyVersion:  keyVersion,
+		AccessToken: "token",
+		Endpoint:    srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewGCP: %v", err)
+	}
+
+	if string(signer.Public()) != string(pub) {
+		t.Errorf("got public key %x, expected %x", signer.Public(), pub)
+	}
+
+	message := []byte("my message")
+	sig, err := signer.Sign(message)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	if !crypto.Verify(signer.Public(), message, sig) {
+		t.Errorf("signature is not valid")
+	}
+}
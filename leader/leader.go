@@ -0,0 +1,312 @@
+// Package leader elects one active instance of vote-decrypt, when more then
+// one instance uses the same storage backend.
+//
+// The instances compete for a lease. The instance that holds the lease is the
+// leader and decrypts the votes. All other instances run in read only mode and
+// tell the client the address of the leader. If the leader does not renew the
+// lease, another instance takes it over after the lease expired.
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	decryptgrpc "github.com/OpenSlides/vote-decrypt/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// LeaderHeader is the grpc header, that contains the address of the leader,
+// when a non leader refuses a request.
+const LeaderHeader = "vote-decrypt-leader"
+
+// Lease is the lock that is held by the leader.
+type Lease interface {
+	// Acquire takes or renews the lease for the holder until now+ttl.
+	//
+	// It returns false, if another holder has a lease that did not expire. In
+	// this case, it also returns the other holder.
+	Acquire(holder string, ttl time.Duration) (ok bool, current string, err error)
+
+	// Release gives up the lease, if it is held by the holder.
+	Release(holder string) error
+}
+
+// Elector competes for a lease and knows, if this instance is the leader.
+type Elector struct {
+	lease  Lease
+	holder string
+	ttl    time.Duration
+
+	onChange func(leader bool)
+
+	mu      sync.Mutex
+	until   time.Time
+	current string
+}
+
+// New initializes an Elector.
+//
+// holder identifies this instance. It should be the address, under which the
+// instance can be reached by clients. onChange is called, when this instance
+// becomes the leader or stops being the leader.
+func New(lease Lease, holder string, ttl time.Duration, onChange func(leader bool)) *Elector {
+	return &Elector{
+		lease:    lease,
+		holder:   holder,
+		ttl:      ttl,
+		onChange: onChange,
+	}
+}
+
+// IsLeader returns true, if this instance holds a lease that did not expire.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return time.Now().Before(e.until)
+}
+
+// Leader returns the last known leader. It is empty, if no leader is known.
+func (e *Elector) Leader() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.current
+}
+
+// Run tries to acquire or renew the lease until ctx is done. It takes three
+// tries per ttl. When ctx is done, the lease is released.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		e.try()
+
+		select {
+		case <-ctx.Done():
+			e.stop()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) try() {
+	wasLeader := e.IsLeader()
+
+	start := time.Now()
+	ok, current, err := e.lease.Acquire(e.holder, e.ttl)
+	if err != nil {
+		log.Printf("Error: acquiring lease: %v", err)
+	}
+
+	e.mu.Lock()
+	if err == nil {
+		e.current = current
+		if ok {
+			// The lease is counted from the start of the call, so this
+			// instance stops before any other instance can take over.
+			e.until = start.Add(e.ttl)
+		} else {
+			e.until = time.Time{}
+		}
+	}
+	isLeader := time.Now().Before(e.until)
+	e.mu.Unlock()
+
+	if isLeader != wasLeader {
+		e.onChange(isLeader)
+	}
+}
+
+func (e *Elector) stop() {
+	wasLeader := e.IsLeader()
+
+	e.mu.Lock()
+	e.until = time.Time{}
+	e.mu.Unlock()
+
+	if wasLeader {
+		e.onChange(false)
+	}
+
+	if err := e.lease.Release(e.holder); err != nil {
+		log.Printf("Error: releasing lease: %v", err)
+	}
+}
+
+// UnaryInterceptor returns a grpc interceptor that refuses the methods, that
+// change polls, when this instance is not the leader. The methods are
+// classified by NeedsLeader() of the vote-decrypt grpc package. The error has the code
+// UNAVAILABLE and the address of the leader in the LeaderHeader.
+func (e *Elector) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !decryptgrpc.NeedsLeader(info.FullMethod) || e.IsLeader() {
+			return handler(ctx, req)
+		}
+
+		return nil, e.notLeader(func(md metadata.MD) error { return grpc.SetHeader(ctx, md) })
+	}
+}
+
+// StreamInterceptor is like UnaryInterceptor() for streaming methods.
+func (e *Elector) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !decryptgrpc.NeedsLeader(info.FullMethod) || e.IsLeader() {
+			return handler(srv, ss)
+		}
+
+		return e.notLeader(ss.SetHeader)
+	}
+}
+
+// notLeader returns the error for a call on an instance, that is not the
+// leader, and sets the LeaderHeader.
+func (e *Elector) notLeader(setHeader func(metadata.MD) error) error {
+	leader := e.Leader()
+	if leader == "" {
+		return status.Error(codes.Unavailable, "no leader elected")
+	}
+
+	if err := setHeader(metadata.Pairs(LeaderHeader, leader)); err != nil {
+		return status.Errorf(codes.Internal, "setting header: %v", err)
+	}
+	return status.Errorf(codes.Unavailable, "instance is not the leader, leader is %s", leader)
+}
+
+// FileLease is a lease in a file. It can be used, when all instances use the
+// same file system, for example with the file storage backend.
+//
+// The file is locked with flock while it is read and written. It depends on
+// the file system, if this works between different hosts.
+type FileLease struct {
+	path string
+}
+
+// NewFileLease initializes a FileLease.
+func NewFileLease(path string) *FileLease {
+	return &FileLease{
+		path: path,
+	}
+}
+
+type leaseContent struct {
+	Holder string    `json:"holder"`
+	Until  time.Time `json:"until"`
+}
+
+// Acquire takes or renews the lease.
+func (l *FileLease) Acquire(holder string, ttl time.Duration) (bool, string, error) {
+	var acquired bool
+	var current string
+	err := l.locked(func(f *os.File) error {
+		content, err := readLease(f)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if content.Holder != "" && content.Holder != holder && now.Before(content.Until) {
+			current = content.Holder
+			return nil
+		}
+
+		if err := writeLease(f, leaseContent{Holder: holder, Until: now.Add(ttl)}); err != nil {
+			return err
+		}
+
+		acquired = true
+		current = holder
+		return nil
+	})
+	if err != nil {
+		return false, "", err
+	}
+
+	return acquired, current, nil
+}
+
+// Release removes the lease, if it is held by the holder.
+func (l *FileLease) Release(holder string) error {
+	return l.locked(func(f *os.File) error {
+		content, err := readLease(f)
+		if err != nil {
+			return err
+		}
+
+		if content.Holder != holder {
+			return nil
+		}
+
+		return writeLease(f, leaseContent{})
+	})
+}
+
+// locked opens the lease file and calls fn while the file is locked.
+func (l *FileLease) locked(fn func(f *os.File) error) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), os.ModePerm); err != nil {
+		return fmt.Errorf("creating lease dir: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening lease file: %w", err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("locking lease file: %w", err)
+	}
+	defer unlockFile(f)
+
+	return fn(f)
+}
+
+func readLease(f *os.File) (leaseContent, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return leaseContent{}, fmt.Errorf("reading lease file: %w", err)
+	}
+
+	var content leaseContent
+	if len(data) == 0 {
+		return content, nil
+	}
+
+	if err := json.Unmarshal(data, &content); err != nil {
+		return leaseContent{}, fmt.Errorf("decoding lease file: %w", err)
+	}
+	return content, nil
+}
+
+func writeLease(f *os.File, content leaseContent) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("encoding lease: %w", err)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("truncating lease file: %w", err)
+	}
+
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("writing lease file: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("syncing lease file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package leader_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/leader"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestFileLease(t *testing.T) {
+	lease := leader.NewFileLease(filepath.Join(t.TempDir(), "leader.lease"))
+
+	ok, current, err := lease.Acquire("a", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	if !ok || current != "a" {
+		t.Errorf("Acquire returned (%t, %s), expected (true, a)", ok, current)
+	}
+
+	ok, current, err = lease.Acquire("b", time.Minute)
+	if err != nil {
+		t.Fatalf("second Acquire: %v", err)
+	}
+
+	if ok || current != "a" {
+		t.Errorf("second Acquire returned (%t, %s), expected (false, a)", ok, current)
+	}
+
+	if err := lease.Release("a"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	ok, current, err = lease.Acquire("b", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+
+	if !ok || current != "b" {
+		t.Errorf("Acquire after release returned (%t, %s), expected (true, b)", ok, current)
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		if _, _, err := lease.Acquire("b", time.Millisecond); err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+
+		ok, _, err := lease.Acquire("c", time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+
+		if !ok {
+			t.Errorf("expired lease was not taken over")
+		}
+	})
+}
+
+func TestElector(t *testing.T) {
+	lease := leader.NewFileLease(filepath.Join(t.TempDir(), "leader.lease"))
+	ttl := 300 * time.Millisecond
+
+	var changesA atomic.Int64
+	electorA := leader.New(lease, "a", ttl, func(bool) { changesA.Add(1) })
+	electorB := leader.New(lease, "b", ttl, func(bool) {})
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	go func() {
+		electorA.Run(ctxA)
+		close(doneA)
+	}()
+
+	waitFor(t, electorA.IsLeader)
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	doneB := make(chan struct{})
+	go func() {
+		electorB.Run(ctxB)
+		close(doneB)
+	}()
+	defer func() {
+		cancelB()
+		<-doneB
+	}()
+
+	waitFor(t, func() bool { return electorB.Leader() == "a" })
+
+	if electorB.IsLeader() {
+		t.Errorf("both instances are the leader")
+	}
+
+	cancelA()
+	<-doneA
+
+	if electorA.IsLeader() {
+		t.Errorf("instance is still the leader after it was stopped")
+	}
+
+	if got := changesA.Load(); got != 2 {
+		t.Errorf("onChange was called %d times, expected 2", got)
+	}
+
+	waitFor(t, electorB.IsLeader)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("condition was not met")
+}
+
+// streamMock is a grpc server stream, that records its header.
+type streamMock struct {
+	grpc.ServerStream
+	header metadata.MD
+}
+
+func (s *streamMock) SetHeader(md metadata.MD) error {
+	s.header = md
+	return nil
+}
+
+func TestFollowerRefusesWrites(t *testing.T) {
+	lease := leader.NewFileLease(filepath.Join(t.TempDir(), "leader.lease"))
+	leaderElector := leader.New(lease, "a", time.Minute, func(bool) {})
+	follower := leader.New(lease, "b", time.Minute, func(bool) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		leaderElector.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	waitFor(t, leaderElector.IsLeader)
+
+	followerCtx, cancelFollower := context.WithCancel(context.Background())
+	followerDone := make(chan struct{})
+	go func() {
+		follower.Run(followerCtx)
+		close(followerDone)
+	}()
+	defer func() {
+		cancelFollower()
+		<-followerDone
+	}()
+	waitFor(t, func() bool { return follower.Leader() == "a" })
+
+	interceptor := follower.StreamInterceptor()
+	handler := func(srv any, ss grpc.ServerStream) error { return nil }
+
+	for _, method := range []string{
+		"/Decrypt/Stop",
+		"/Decrypt/PartialDecrypt",
+		"/Decrypt/Revoke",
+		"/Decrypt/Rekey",
+		"/Decrypt/IssueToken",
+		"/Decrypt/SetReadOnly",
+	} {
+		stream := new(streamMock)
+		err := interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: method}, handler)
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("%s on the follower returned %v, expected code %s", method, err, codes.Unavailable)
+		}
+
+		if got := stream.header.Get(leader.LeaderHeader); len(got) != 1 || got[0] != "a" {
+			t.Errorf("%s on the follower has leader header %v, expected a", method, got)
+		}
+	}
+
+	for _, method := range []string{"/Decrypt/Status", "/Decrypt/CoSign", "/Decrypt/SetLogLevel"} {
+		if err := interceptor(nil, new(streamMock), &grpc.StreamServerInfo{FullMethod: method}, handler); err != nil {
+			t.Errorf("%s on the follower returned %v", method, err)
+		}
+	}
+}
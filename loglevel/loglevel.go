@@ -0,0 +1,123 @@
+// Package loglevel filters the messages of the standard logger by their level.
+//
+// The level of a message is the prefix of the message, like `Error:` or
+// `Warning:`. Messages without a prefix have the level info. Audit events, that
+// are written to the log, are never filtered.
+package loglevel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+)
+
+// Level of a log message.
+type Level int32
+
+// Supported levels. A filter with a level writes the messages of this level
+// and all higher levels.
+const (
+	Debug Level = iota
+	Info
+	Warning
+	Error
+)
+
+// names are the names of the levels, as they are used in the configuration.
+var names = map[Level]string{
+	Debug:   "debug",
+	Info:    "info",
+	Warning: "warning",
+	Error:   "error",
+}
+
+// prefixes are the prefixes of the messages with a level other then info.
+var prefixes = map[string]Level{
+	"Debug:":   Debug,
+	"Warning:": Warning,
+	"Error:":   Error,
+}
+
+// auditPrefix is the prefix of audit events, that are written to the log.
+const auditPrefix = "Audit:"
+
+// String returns the name of the level.
+func (l Level) String() string {
+	if name, ok := names[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel returns the level with the given name.
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range names {
+		if levelName == name {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q: %w", name, errorcode.Invalid)
+}
+
+// header matches the date and time, that the standard logger writes before
+// each message.
+var header = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} )?(\d{2}:\d{2}:\d{2}(\.\d+)? )?`)
+
+// Filter is an io.Writer for the standard logger, that drops messages below
+// its level.
+//
+//	filter := loglevel.NewFilter(log.Writer(), loglevel.Info)
+//	log.SetOutput(filter)
+//
+// The level can be changed at any time.
+type Filter struct {
+	w     io.Writer
+	level atomic.Int32
+}
+
+// NewFilter returns a filter, that writes the messages with at least the given
+// level to w.
+func NewFilter(w io.Writer, level Level) *Filter {
+	f := Filter{w: w}
+	f.level.Store(int32(level))
+	return &f
+}
+
+// Level returns the current level.
+func (f *Filter) Level() Level {
+	return Level(f.level.Load())
+}
+
+// SetLevel changes the level and returns the previous level.
+func (f *Filter) SetLevel(level Level) Level {
+	return Level(f.level.Swap(int32(level)))
+}
+
+// Write writes one message of the standard logger to the underlying writer,
+// if its level is high enough. Dropped messages are reported as written.
+func (f *Filter) Write(p []byte) (int, error) {
+	if messageLevel(p) < f.Level() {
+		return len(p), nil
+	}
+	return f.w.Write(p)
+}
+
+// messageLevel returns the level of a message of the standard logger.
+func messageLevel(p []byte) Level {
+	message := p[len(header.Find(p)):]
+
+	if bytes.HasPrefix(message, []byte(auditPrefix)) {
+		return Error
+	}
+
+	for prefix, level := range prefixes {
+		if bytes.HasPrefix(message, []byte(prefix)) {
+			return level
+		}
+	}
+	return Info
+}
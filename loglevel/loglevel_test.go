@@ -0,0 +1,59 @@
+package loglevel_test
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/loglevel"
+)
+
+func TestFilter(t *testing.T) {
+	var buf bytes.Buffer
+	filter := loglevel.NewFilter(&buf, loglevel.Warning)
+	logger := log.New(filter, "", log.LstdFlags|log.Lmicroseconds)
+
+	logger.Printf("Debug: debug message")
+	logger.Printf("info message")
+	logger.Printf("Warning: warning message")
+	logger.Printf("Error: error message")
+	logger.Printf("Audit: event=start poll=1")
+
+	got := buf.String()
+	for _, message := range []string{"warning message", "error message", "event=start"} {
+		if !strings.Contains(got, message) {
+			t.Errorf("log does not contain %q:\n%s", message, got)
+		}
+	}
+
+	for _, message := range []string{"debug message", "info message"} {
+		if strings.Contains(got, message) {
+			t.Errorf("log contains %q:\n%s", message, got)
+		}
+	}
+
+	if previous := filter.SetLevel(loglevel.Debug); previous != loglevel.Warning {
+		t.Errorf("SetLevel returned %s, expected warning", previous)
+	}
+
+	logger.Printf("Debug: second debug message")
+	if !strings.Contains(buf.String(), "second debug message") {
+		t.Errorf("debug message was filtered after SetLevel(Debug)")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []loglevel.Level{loglevel.Debug, loglevel.Info, loglevel.Warning, loglevel.Error} {
+		got, err := loglevel.ParseLevel(level.String())
+		if err != nil || got != level {
+			t.Errorf("ParseLevel(%q) returned %s, %v", level.String(), got, err)
+		}
+	}
+
+	if _, err := loglevel.ParseLevel("verbose"); !errors.Is(err, errorcode.Invalid) {
+		t.Errorf("ParseLevel(verbose) returned `%v`, expected `%v`", err, errorcode.Invalid)
+	}
+}
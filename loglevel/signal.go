@@ -0,0 +1,35 @@
+package loglevel
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+)
+
+// ToggleOnSignal switches the filter to the level debug, when a signal is
+// received, and back to the previous level, when it is received again. It
+// returns when ctx is done.
+func (f *Filter) ToggleOnSignal(ctx context.Context, signals ...os.Signal) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, signals...)
+	defer signal.Stop(sig)
+
+	previous := f.Level()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-sig:
+			if f.Level() == Debug {
+				f.SetLevel(previous)
+			} else {
+				previous = f.SetLevel(Debug)
+			}
+
+			// Written directly, so the message is not filtered.
+			log.New(f.w, "", log.LstdFlags).Printf("Log level set to %s", f.Level())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+//go:build !windows
+
+package loglevel_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/loglevel"
+)
+
+func TestToggleOnSignal(t *testing.T) {
+	// Without a handler, SIGUSR1 would stop the test binary, before
+	// ToggleOnSignal has registered its handler.
+	ignored := make(chan os.Signal, 1)
+	signal.Notify(ignored, syscall.SIGUSR1)
+	defer signal.Stop(ignored)
+
+	var buf bytes.Buffer
+	filter := loglevel.NewFilter(&buf, loglevel.Error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		filter.ToggleOnSignal(ctx, syscall.SIGUSR1)
+		close(done)
+	}()
+
+	waitForLevel := func(level loglevel.Level) {
+		t.Helper()
+
+		for i := 0; i < 100; i++ {
+			if filter.Level() == level {
+				return
+			}
+			syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("level is %s, expected %s", filter.Level(), level)
+	}
+
+	// The signal is sent until the handler is registered and has changed the
+	// level.
+	waitForLevel(loglevel.Debug)
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	for i := 0; i < 100 && filter.Level() != loglevel.Error; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := filter.Level(); got != loglevel.Error {
+		t.Errorf("level after the second signal is %s, expected error", got)
+	}
+
+	cancel()
+	<-done
+
+	if !strings.Contains(buf.String(), "Log level set to debug") {
+		t.Errorf("change of the level was not logged:\n%s", buf.String())
+	}
+}
@@ -1,40 +1,114 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdh"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/OpenSlides/vote-decrypt/archive"
+	"github.com/OpenSlides/vote-decrypt/audit"
+	"github.com/OpenSlides/vote-decrypt/bench"
+	"github.com/OpenSlides/vote-decrypt/certificate"
 	"github.com/OpenSlides/vote-decrypt/crypto"
 	"github.com/OpenSlides/vote-decrypt/decrypt"
 	"github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/qr"
+	"github.com/OpenSlides/vote-decrypt/server"
 	"github.com/OpenSlides/vote-decrypt/store"
+	"github.com/OpenSlides/vote-decrypt/store/integrity"
+	"github.com/OpenSlides/vote-decrypt/tpm"
+	"github.com/OpenSlides/vote-decrypt/version"
+	"github.com/OpenSlides/vote-decrypt/winservice"
 	"github.com/alecthomas/kong"
-	"golang.org/x/sys/unix"
 )
 
 func main() {
 	ctx, cancel := interruptContext()
 	defer cancel()
 
-	cliCtx := kong.Parse(&cli, kong.UsageOnError())
+	cliCtx := kong.Parse(&cli, kong.UsageOnError(), kong.Vars{"version": versionString()})
 
 	var err error
 	switch cliCtx.Command() {
-	case "server <main-key>":
+	case "server", "server <main-key>":
 		err = runServer(ctx)
 
+	case "check-config", "check-config <main-key>":
+		err = runCheckConfig(ctx)
+
 	case "main-key <main-key>":
 		err = runMainKey(ctx)
 
+	case "convert-key <key>":
+		err = runConvertKey(ctx)
+
 	case "pub-key <main-key>":
 		err = runPubKey(ctx)
 
+	case "certificate <main-key>":
+		err = runCertificate(ctx)
+
+	case "wipe <main-key>":
+		err = runWipe(ctx)
+
+	case "import-key <main-key> <poll-id> <poll-key>":
+		err = runImportKey(ctx)
+
+	case "tpm-seal <main-key> <sealed-key>":
+		err = runTPMSeal(ctx)
+
+	case "public-keys":
+		err = runPublicKeys(ctx)
+
+	case "effective-config":
+		err = runEffectiveConfig(ctx)
+
+	case "log-level <level>":
+		err = runLogLevel(ctx)
+
+	case "store verify":
+		err = runStoreVerify(ctx)
+
+	case "store gc", "store gc <main-key>":
+		err = runStoreGC(ctx)
+
+	case "audit export <output>":
+		err = runAuditExport(ctx)
+
+	case "audit verify <log>":
+		err = runAuditVerify(ctx)
+
+	case "crash-report <report>":
+		err = runCrashReport(ctx)
+
+	case "archive <output>":
+		err = runArchive(ctx)
+
+	case "replay":
+		err = runReplay(ctx)
+
+	case "bench":
+		err = runBench(ctx)
+
+	case "loadtest":
+		err = runLoadTest(ctx)
+
+	case "crypto-caps":
+		err = runCryptoCaps(ctx)
+
 	default:
 		panic(fmt.Sprintf("Unknown command: %s", cliCtx.Command()))
 	}
@@ -46,93 +120,1072 @@ func main() {
 }
 
 var cli struct {
-	Server struct {
-		MainKey *os.File `arg:"" help:"Path to the main key file."`
+	Version kong.VersionFlag `help:"Show the build information and exit."`
 
-		Port  int    `help:"Port for the server. Defaults to 9014." short:"p" env:"VOTE_DECRYPT_PORT" default:"9014"`
-		Store string `help:"Path for the file system storage of poll keys." env:"VOTE_DECRYPT_STORE" default:"vote_data"`
-	} `cmd:"" help:"Starts the vote decrypt grpc server." default:"withargs"`
+	Server server.Config `cmd:"" help:"Starts the vote decrypt grpc server." default:"withargs"`
+
+	CheckConfig server.Config `cmd:"" name:"check-config" help:"Checks the configuration of the server without starting it and prints the effective configuration."`
 
 	MainKey struct {
 		MainKey string `arg:"" help:"Path to the main key file."`
-	} `cmd:"" help:"Creates a main key file. It is just 32 bytes of random data."`
+		Raw     bool   `help:"Write the 32 random bytes instead of a PEM encoded PKCS#8 key."`
+		FIPS    bool   `help:"Create an ECDSA P-256 key for the FIPS mode instead of an ed25519 key." name:"fips"`
+	} `cmd:"" help:"Creates a main key file with a PEM encoded PKCS#8 key."`
+
+	ConvertKey struct {
+		Key     *os.File `arg:"" help:"Path of the key file in the raw or PEM format."`
+		PollKey bool     `help:"The key is a private poll key or escrow key instead of a main key."`
+		Raw     bool     `help:"Print the raw bytes instead of the PEM format."`
+		FIPS    bool     `help:"The key is a P-256 key of the FIPS mode." name:"fips"`
+	} `cmd:"" name:"convert-key" help:"Prints a main key or poll key in the PEM format or as raw bytes."`
 
 	PubKey struct {
 		MainKey     *os.File `arg:"" help:"Path to the main key file."`
 		SkipNewline bool     `help:"Do not output the trailing newline." short:"n"`
 		Base64      bool     `help:"Decode the output with base64." short:"b" name:"base64"`
+		PEM         bool     `help:"Print the key as PEM encoded PKIX public key." name:"pem"`
+		Fingerprint bool     `help:"Print the SHA256 fingerprint of the key."`
+		FIPS        bool     `help:"Calculate the ECDSA P-256 public key of the FIPS mode." name:"fips"`
+		QR          bool     `help:"Print a QR code instead of the text. It contains the base64 encoded key or, with --pem or --fingerprint, the text of the format." name:"qr"`
+		QRPNG       string   `help:"Write the QR code as PNG image to the given path." name:"qr-png" type:"path"`
 	} `cmd:"" help:"Calculates the public key for a private key file"`
+
+	Certificate struct {
+		MainKey    *os.File `arg:"" help:"Path to the main key file."`
+		CommonName string   `help:"Common name of the subject." default:"vote-decrypt"`
+		Days       int      `help:"Validity of the self-signed certificate in days." default:"365"`
+		Request    bool     `help:"Create a certificate request for a certificate authority instead of a self-signed certificate."`
+		FIPS       bool     `help:"Use the ECDSA P-256 key of the FIPS mode." name:"fips"`
+	} `cmd:"" help:"Prints a self-signed X.509 certificate or a certificate request for the main key in the PEM format."`
+
+	Wipe struct {
+		MainKey *os.File `arg:"" help:"Path to the main key file."`
+
+		server.StoreConfig `embed:""`
+
+		AuditLog      string `help:"Path of the audit log file. If empty, audit events are written to stdout." env:"VOTE_DECRYPT_AUDIT_LOG"`
+		Confirm       string `help:"Confirmation token. Call the command without it to see the token."`
+		RemoveMainKey bool   `help:"Also remove the main key file."`
+	} `cmd:"" help:"Removes the data of all polls and optionally the main key."`
+
+	ImportKey struct {
+		MainKey *os.File `arg:"" help:"Path to the main key file."`
+		PollID  string   `arg:"" help:"ID of the poll."`
+		PollKey *os.File `arg:"" help:"Path of the private x25519 poll key. It has to contain the 32 raw bytes or a PEM encoded PKCS#8 key."`
+		FIPS    bool     `help:"The poll key is a P-256 key for a server in FIPS mode." name:"fips"`
+		Trustee bool     `help:"The poll key is the key of a trustee. The poll can only be decrypted with PartialDecrypt. Needs --fips."`
+		Tokens  bool     `help:"Votes of the poll need a voting token from IssueToken."`
+
+		server.StoreConfig `embed:""`
+
+		AuditLog string `help:"Path of the audit log file. If empty, audit events are written to stdout." env:"VOTE_DECRYPT_AUDIT_LOG"`
+	} `cmd:"" name:"import-key" help:"Starts a poll with an externally created private poll key."`
+
+	PublicKeys struct {
+		Addr  string `help:"Address of the vote-decrypt service." default:"localhost:9014"`
+		QR    bool   `help:"Print a QR code with the base64 encoded public key of each poll instead of the json." name:"qr"`
+		QRPNG string `help:"Write a QR code for each poll as PNG image to the given directory. The file name is the poll id." name:"qr-png" type:"path"`
+	} `cmd:"" name:"public-keys" help:"Prints the public keys of all active polls of a running service as json."`
+
+	EffectiveConfig struct {
+		Addr       string `help:"Address of the vote-decrypt service." default:"localhost:9014"`
+		AdminToken string `help:"Admin token of the service." env:"VOTE_DECRYPT_ADMIN_TOKEN"`
+	} `cmd:"" name:"effective-config" help:"Prints the configuration, the build information and the enabled features of a running service as json."`
+
+	LogLevel struct {
+		Level      string `arg:"" help:"New log level." enum:"debug,info,warning,error"`
+		Addr       string `help:"Address of the vote-decrypt service." default:"localhost:9014"`
+		AdminToken string `help:"Admin token of the service." env:"VOTE_DECRYPT_ADMIN_TOKEN"`
+	} `cmd:"" name:"log-level" help:"Changes the log level of a running service."`
+
+	Store struct {
+		Verify struct {
+			server.StoreConfig `embed:""`
+		} `cmd:"" help:"Checks the hmac of all store records and reports the integrity of each poll."`
+
+		GC struct {
+			MainKey *os.File `arg:"" optional:"" help:"Path to the main key file. If given, polls, whose scheduled removal has passed, are removed."`
+
+			server.StoreConfig `embed:""`
+
+			AuditLog string `help:"Path of the audit log file. If empty, audit events are written to stdout." env:"VOTE_DECRYPT_AUDIT_LOG"`
+		} `cmd:"" name:"gc" help:"Removes expired polls and the leftovers of removed polls from the store."`
+	} `cmd:"" help:"Commands for the storage backend."`
+
+	Audit struct {
+		Export struct {
+			Output     string    `arg:"" help:"Path of the exported file. The base64 encoded signature is written to OUTPUT.sig." type:"path"`
+			Addr       string    `help:"Address of the vote-decrypt service." default:"localhost:9014"`
+			AdminToken string    `help:"Admin token of the service." env:"VOTE_DECRYPT_ADMIN_TOKEN"`
+			PollID     string    `help:"Only export the entries of this poll." name:"poll-id"`
+			From       time.Time `help:"Only export entries recorded at or after this time (RFC 3339)."`
+			To         time.Time `help:"Only export entries recorded before this time (RFC 3339)."`
+		} `cmd:"" help:"Exports the signed audit log of a running service as json lines."`
+
+		Verify struct {
+			Log           string `arg:"" help:"Path of the audit log file or of an export." type:"existingfile"`
+			PublicMainKey string `help:"Base64 encoded public main key. If given, the signature of an export in LOG.sig is checked." name:"public-main-key"`
+		} `cmd:"" help:"Checks the hash chain of an audit log and the signature of an export."`
+	} `cmd:"" help:"Commands for the audit log."`
+
+	TPMSeal struct {
+		MainKey   *os.File `arg:"" help:"Path to the main key file."`
+		SealedKey string   `arg:"" help:"Path for the sealed main key file."`
+
+		PCR       []int  `help:"PCRs from the SHA256 bank to bind the key to. Defaults to 7 (secure boot state)." name:"pcr"`
+		TPMDevice string `help:"Path of the TPM device. Defaults to /dev/tpmrm0 or /dev/tpm0." env:"VOTE_DECRYPT_TPM_DEVICE"`
+	} `cmd:"" name:"tpm-seal" help:"Seals a main key file to the TPM of this host."`
+
+	CrashReport struct {
+		Report     *os.File `arg:"" help:"Path of the crash report."`
+		PrivateKey *os.File `help:"Path of the private key for the crash key. Needed for encrypted reports." name:"private-key"`
+		FIPS       bool     `help:"The private key is a P-256 key." name:"fips"`
+	} `cmd:"" name:"crash-report" help:"Decrypts a crash report and prints it as json."`
+
+	Archive struct {
+		Output         string   `arg:"" help:"Path of the archive file." type:"path"`
+		Result         *os.File `help:"Path of the signed result envelope in json format." required:""`
+		Addr           string   `help:"Address of the vote-decrypt service." default:"localhost:9014"`
+		AdminToken     string   `help:"Admin token of the service. If given, the audit log of the poll is added to the archive." env:"VOTE_DECRYPT_ADMIN_TOKEN"`
+		WithoutPollKey bool     `help:"Do not add the public poll key. Needed for polls, that were already cleared." name:"without-poll-key"`
+	} `cmd:"" help:"Creates a self-contained archive of a stopped poll, that can be checked with verify-archive."`
+
+	Replay struct {
+		Result        *os.File `help:"Path of the signed result envelope in json format." required:""`
+		Votes         *os.File `help:"Path of the archived votes of the poll in json format." required:""`
+		PublicMainKey string   `help:"Base64 encoded public main key, that signed the result." required:""`
+
+		PollKey          *os.File `help:"Path of the private poll key."`
+		SealedKey        *os.File `help:"Path of the poll key exported with ExportKey. Needs --escrow-private-key."`
+		EscrowPrivateKey *os.File `help:"Path of the private x25519 key of the auditor."`
+		FIPS             bool     `help:"The poll and escrow keys are P-256 keys of a server in FIPS mode." name:"fips"`
+
+		Formats          []string `help:"Accepted formats of encrypted votes. Has to be the same as on the server."`
+		MaxPlaintextSize int      `help:"Maximum size of one decrypted vote in bytes. Has to be the same as on the server." default:"0"`
+		OversizePolicy   string   `help:"Policy for bigger decrypted votes. Has to be the same as on the server." enum:"fail,invalid" default:"fail"`
+	} `cmd:"" help:"Decrypts the archived votes of a poll again and compares them with the signed result."`
+
+	Bench struct {
+		Votes    int    `help:"Number of votes per poll." default:"100000"`
+		VoteSize int    `help:"Size of one plaintext vote in bytes." default:"100"`
+		Polls    int    `help:"Number of polls to stop." default:"5"`
+		Workers  int    `help:"Number of decrypt workers. 0 means one per CPU." default:"0"`
+		AutoTune bool   `help:"Tune the number of decrypt workers and the votes per job. --workers is the maximum."`
+		Store    string `help:"Path for the file system storage. Defaults to a temporary folder."`
+	} `cmd:"" help:"Measures the decryption throughput with synthetic votes."`
+
+	Loadtest struct {
+		Addr        string `help:"Address of the vote-decrypt service." default:"localhost:9014"`
+		Polls       int    `help:"Number of polls to run." default:"100"`
+		Concurrency int    `help:"Number of polls that run at the same time." default:"10"`
+		Votes       int    `help:"Number of votes per poll." default:"1000"`
+		VoteSize    int    `help:"Size of one plaintext vote in bytes." default:"100"`
+	} `cmd:"" help:"Runs polls against a remote vote-decrypt service and measures the latency."`
+
+	CryptoCaps struct {
+		Duration            time.Duration `help:"Time to measure each primitive. 0 skips the measurement." default:"1s"`
+		VoteSize            int           `help:"Size of one plaintext vote in bytes." default:"100"`
+		RequireConstantTime bool          `help:"Fail, if aes-256-gcm is not constant-time on this machine."`
+	} `cmd:"" name:"crypto-caps" help:"Shows, if the cryptographic primitives use hardware and constant-time implementations, and measures them."`
 }
 
 func runServer(ctx context.Context) error {
+	isService, err := winservice.IsService()
+	if err != nil {
+		return fmt.Errorf("detecting windows service: %w", err)
+	}
+
+	if isService {
+		return winservice.Run(func(ctx context.Context) error {
+			return server.Run(ctx, cli.Server)
+		})
+	}
+
+	return server.Run(ctx, cli.Server)
+}
+
+func runCheckConfig(ctx context.Context) error {
+	return server.Check(ctx, cli.CheckConfig, os.Stdout)
+}
+
+func runPubKey(ctx context.Context) error {
+	key, err := server.ReadMainKey(cli.PubKey.MainKey)
+	if err != nil {
+		return fmt.Errorf("reading key: %w", err)
+	}
+
+	cryptoLib, err := server.LocalCrypto(key, rand.Reader, cli.PubKey.FIPS)
+	if err != nil {
+		return fmt.Errorf("initializing crypto: %w", err)
+	}
+	pubKey := cryptoLib.PublicMainKey()
+
+	var text []byte
+	switch {
+	case cli.PubKey.Fingerprint:
+		text = []byte(decrypt.Fingerprint(pubKey) + "\n")
+
+	case cli.PubKey.PEM:
+		text, err = crypto.EncodePublicMainKey(pubKey)
+		if err != nil {
+			return fmt.Errorf("encoding public key: %w", err)
+		}
+
+	case cli.PubKey.Base64:
+		text = []byte(base64.StdEncoding.EncodeToString(pubKey) + "\n")
+
+	default:
+		text = append(pubKey, '\n')
+	}
+
+	if cli.PubKey.QR || cli.PubKey.QRPNG != "" {
+		if !cli.PubKey.Fingerprint && !cli.PubKey.PEM {
+			text = []byte(base64.StdEncoding.EncodeToString(pubKey))
+		}
+		return writeQR(bytes.TrimSpace(text), cli.PubKey.QRPNG)
+	}
+
+	if cli.PubKey.SkipNewline {
+		text = bytes.TrimSuffix(text, []byte("\n"))
+	}
+
+	_, err = os.Stdout.Write(text)
+	return err
+}
+
+// qrScale is the width of a module of a QR code in pixels.
+const qrScale = 8
+
+// writeQR prints the data as QR code to stdout or, if pngPath is not empty,
+// writes it as PNG image.
+func writeQR(data []byte, pngPath string) error {
+	code, err := qr.Encode(data)
+	if err != nil {
+		return fmt.Errorf("creating qr code: %w", err)
+	}
+
+	if pngPath == "" {
+		fmt.Print(code.Terminal())
+		return nil
+	}
+
+	f, err := os.Create(pngPath)
+	if err != nil {
+		return fmt.Errorf("creating png file: %w", err)
+	}
+	defer f.Close()
+
+	if err := code.WritePNG(f, qrScale); err != nil {
+		return fmt.Errorf("writing png file: %w", err)
+	}
+	return f.Close()
+}
+
+func runCertificate(ctx context.Context) error {
+	key, err := server.ReadMainKey(cli.Certificate.MainKey)
+	if err != nil {
+		return fmt.Errorf("reading key: %w", err)
+	}
+
+	privateKey, err := crypto.PrivateMainKey(key, cli.Certificate.FIPS)
+	if err != nil {
+		return fmt.Errorf("reading key: %w", err)
+	}
+
+	if cli.Certificate.Request {
+		der, err := certificate.Request(privateKey, cli.Certificate.CommonName)
+		if err != nil {
+			return err
+		}
+		return pem.Encode(os.Stdout, &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+	}
+
+	der, err := certificate.SelfSigned(privateKey, cli.Certificate.CommonName, time.Duration(cli.Certificate.Days)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(certificate.EncodePEM([][]byte{der}))
+	return err
+}
+
+func runMainKey(ctx context.Context) error {
 	key := make([]byte, 32)
-	if _, err := io.ReadFull(cli.Server.MainKey, key); err != nil {
+	if cli.MainKey.FIPS {
+		ecdhKey, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("creating key: %w", err)
+		}
+		key = ecdhKey.Bytes()
+	} else if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return fmt.Errorf("reading key: %w", err)
+	}
+
+	content := key
+	if !cli.MainKey.Raw {
+		encoded, err := crypto.EncodeMainKey(key, cli.MainKey.FIPS)
+		if err != nil {
+			return fmt.Errorf("encoding key: %w", err)
+		}
+		content = encoded
+	}
+
+	if err := os.WriteFile(cli.MainKey.MainKey, content, 0o600); err != nil {
+		return fmt.Errorf("writing main key: %w", err)
+	}
+
+	return nil
+}
+
+func runConvertKey(ctx context.Context) error {
+	data, err := io.ReadAll(cli.ConvertKey.Key)
+	if err != nil {
 		return fmt.Errorf("reading key: %w", err)
 	}
 
-	cryptoLib := crypto.New(key, rand.Reader, nil)
+	var curve ecdh.Curve
+	if cli.ConvertKey.FIPS {
+		curve = ecdh.P256()
+	}
 
-	fmt.Printf("Public Main Key: %s\n", base64.StdEncoding.EncodeToString(cryptoLib.PublicMainKey()))
+	decode := crypto.DecodeMainKey
+	encode := func(key []byte) ([]byte, error) { return crypto.EncodeMainKey(key, cli.ConvertKey.FIPS) }
+	if cli.ConvertKey.PollKey {
+		decode = crypto.DecodePollKey
+		encode = func(key []byte) ([]byte, error) { return crypto.EncodePollKey(curve, key) }
+	}
+
+	key, err := decode(data)
+	if err != nil {
+		return fmt.Errorf("decoding key: %w", err)
+	}
+
+	if !cli.ConvertKey.Raw {
+		key, err = encode(key)
+		if err != nil {
+			return fmt.Errorf("encoding key: %w", err)
+		}
+	}
+
+	_, err = os.Stdout.Write(key)
+	return err
+}
+
+func runWipe(ctx context.Context) error {
+	key, err := server.ReadMainKey(cli.Wipe.MainKey)
+	if err != nil {
+		return fmt.Errorf("reading key: %w", err)
+	}
+
+	mainKeyFile := cli.Wipe.MainKey.Name()
+	options := []decrypt.Option{
+		decrypt.WithRemoveMainKey(func() error { return os.Remove(mainKeyFile) }),
+	}
+	if cli.Wipe.AuditLog != "" {
+		options = append(options, decrypt.WithAuditLog(audit.New(cli.Wipe.AuditLog)))
+	}
+
+	backend, err := cli.Wipe.OpenStore()
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
 
 	decrypter := decrypt.New(
-		cryptoLib,
-		store.New(cli.Server.Store),
+		crypto.New(key, rand.Reader, nil),
+		backend,
+		options...,
 	)
 
-	addr := fmt.Sprintf(":%d", cli.Server.Port)
+	if cli.Wipe.Confirm == "" {
+		fmt.Printf("This removes all poll keys from the %s store. This can not be undone.\n", cli.Wipe.StoreBackend)
+		fmt.Printf("To confirm, call the command again with --confirm %s\n", decrypter.WipeToken())
+		return fmt.Errorf("no confirmation token given")
+	}
 
-	if err := grpc.RunServer(ctx, decrypter, addr); err != nil {
-		return fmt.Errorf("running grpc server: %w", err)
+	if err := decrypter.Wipe(ctx, cli.Wipe.Confirm, cli.Wipe.RemoveMainKey); err != nil {
+		return fmt.Errorf("wiping: %w", err)
 	}
 
 	return nil
 }
 
-func runPubKey(ctx context.Context) error {
-	key := make([]byte, 32)
-	if _, err := io.ReadFull(cli.PubKey.MainKey, key); err != nil {
+func runImportKey(ctx context.Context) error {
+	key, err := server.ReadMainKey(cli.ImportKey.MainKey)
+	if err != nil {
 		return fmt.Errorf("reading key: %w", err)
 	}
 
-	pubKey := crypto.New(key, rand.Reader, nil).PublicMainKey()
+	pollKey, err := readPollKey(cli.ImportKey.PollKey)
+	if err != nil {
+		return fmt.Errorf("reading poll key: %w", err)
+	}
 
-	decodedKey := string(pubKey)
-	if cli.PubKey.Base64 {
-		decodedKey = base64.StdEncoding.EncodeToString(pubKey)
+	var options []decrypt.Option
+	if cli.ImportKey.AuditLog != "" {
+		options = append(options, decrypt.WithAuditLog(audit.New(cli.ImportKey.AuditLog)))
 	}
 
-	print := fmt.Println
-	if cli.PubKey.SkipNewline {
-		print = fmt.Print
+	backend, err := cli.ImportKey.OpenStore()
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+
+	cryptoLib, err := server.LocalCrypto(key, rand.Reader, cli.ImportKey.FIPS)
+	if err != nil {
+		return fmt.Errorf("initializing crypto: %w", err)
 	}
-	print(decodedKey)
 
+	var startOptions []decrypt.StartOption
+	if cli.ImportKey.Trustee {
+		if !cli.ImportKey.FIPS {
+			return fmt.Errorf("trustee keys have to be P-256 keys, use --fips")
+		}
+		startOptions = append(startOptions, decrypt.WithTrustee())
+
+		// The flag --trustee enables the experimental feature for this import.
+		options = append(options, decrypt.WithFeatures(decrypt.FeatureTrustee))
+	}
+
+	decrypter := decrypt.New(cryptoLib, backend, options...)
+	if cli.ImportKey.Tokens {
+		startOptions = append(startOptions, decrypt.WithTokens())
+	}
+
+	pubKey, pubKeySig, err := decrypter.ImportKey(ctx, cli.ImportKey.PollID, pollKey, startOptions...)
+	if err != nil {
+		return fmt.Errorf("importing poll key: %w", err)
+	}
+
+	fmt.Printf("public poll key: %s\n", base64.StdEncoding.EncodeToString(pubKey))
+	fmt.Printf("signature: %s\n", base64.StdEncoding.EncodeToString(pubKeySig))
+	fmt.Printf("fingerprint: %s\n", decrypt.Fingerprint(pubKey))
 	return nil
 }
 
-func runMainKey(ctx context.Context) error {
-	key := make([]byte, 32)
-	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+func runPublicKeys(ctx context.Context) error {
+	client, close, err := grpc.NewClient(cli.PublicKeys.Addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", cli.PublicKeys.Addr, err)
+	}
+	defer close()
+
+	keys, err := client.PublicKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("getting public keys: %w", err)
+	}
+
+	if cli.PublicKeys.QR || cli.PublicKeys.QRPNG != "" {
+		for _, key := range keys {
+			encoded := []byte(base64.StdEncoding.EncodeToString(key.PubKey))
+			if cli.PublicKeys.QRPNG != "" {
+				name := strings.ReplaceAll(key.ID, "/", "_") + ".png"
+				if err := writeQR(encoded, filepath.Join(cli.PublicKeys.QRPNG, name)); err != nil {
+					return fmt.Errorf("poll %s: %w", key.ID, err)
+				}
+				continue
+			}
+
+			fmt.Printf("Poll %s (%s):\n", key.ID, key.Fingerprint)
+			if err := writeQR(encoded, ""); err != nil {
+				return fmt.Errorf("poll %s: %w", key.ID, err)
+			}
+		}
+		return nil
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(keys); err != nil {
+		return fmt.Errorf("encoding public keys: %w", err)
+	}
+	return nil
+}
+
+func runEffectiveConfig(ctx context.Context) error {
+	client, close, err := grpc.NewClient(cli.EffectiveConfig.Addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", cli.EffectiveConfig.Addr, err)
+	}
+	defer close()
+
+	config, err := client.EffectiveConfig(ctx, cli.EffectiveConfig.AdminToken)
+	if err != nil {
+		return fmt.Errorf("getting effective config: %w", err)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, config, "", "  "); err != nil {
+		return fmt.Errorf("decoding effective config: %w", err)
+	}
+	indented.WriteByte('\n')
+
+	if _, err := indented.WriteTo(os.Stdout); err != nil {
+		return fmt.Errorf("writing effective config: %w", err)
+	}
+	return nil
+}
+
+func runLogLevel(ctx context.Context) error {
+	client, close, err := grpc.NewClient(cli.LogLevel.Addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", cli.LogLevel.Addr, err)
+	}
+	defer close()
+
+	previous, err := client.SetLogLevel(ctx, cli.LogLevel.AdminToken, cli.LogLevel.Level)
+	if err != nil {
+		return fmt.Errorf("setting log level: %w", err)
+	}
+
+	fmt.Printf("log level changed from %s to %s\n", previous, cli.LogLevel.Level)
+	return nil
+}
+
+func runAuditExport(ctx context.Context) error {
+	client, close, err := grpc.NewClient(cli.Audit.Export.Addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", cli.Audit.Export.Addr, err)
+	}
+	defer close()
+
+	content, signature, err := client.ExportAuditLog(ctx, cli.Audit.Export.AdminToken, cli.Audit.Export.PollID, cli.Audit.Export.From, cli.Audit.Export.To)
+	if err != nil {
+		return fmt.Errorf("exporting audit log: %w", err)
+	}
+
+	if err := os.WriteFile(cli.Audit.Export.Output, content, 0600); err != nil {
+		return fmt.Errorf("writing export: %w", err)
+	}
+
+	if err := os.WriteFile(cli.Audit.Export.Output+".sig", []byte(base64.StdEncoding.EncodeToString(signature)+"\n"), 0600); err != nil {
+		return fmt.Errorf("writing signature: %w", err)
+	}
+
+	fmt.Printf("exported %d bytes to %s\n", len(content), cli.Audit.Export.Output)
+	return nil
+}
+
+func runCrashReport(ctx context.Context) error {
+	report, err := io.ReadAll(cli.CrashReport.Report)
+	if err != nil {
+		return fmt.Errorf("reading report: %w", err)
+	}
+
+	if cli.CrashReport.PrivateKey != nil {
+		privateKey, err := readPollKey(cli.CrashReport.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("reading private key: %w", err)
+		}
+
+		// The main key is not needed to decrypt the report.
+		randomMainKey := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, randomMainKey); err != nil {
+			return fmt.Errorf("reading random: %w", err)
+		}
+		cryptoLib, err := server.LocalCrypto(randomMainKey, rand.Reader, cli.CrashReport.FIPS)
+		if err != nil {
+			return fmt.Errorf("initializing crypto: %w", err)
+		}
+
+		report, err = cryptoLib.Decrypt(privateKey, report)
+		if err != nil {
+			return fmt.Errorf("decrypting report: %w", err)
+		}
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, report, "", "  "); err != nil {
+		return fmt.Errorf("decoding report: %w", err)
+	}
+
+	fmt.Println(indented.String())
+	return nil
+}
+
+func runArchive(ctx context.Context) error {
+	rawEnvelope, err := io.ReadAll(cli.Archive.Result)
+	if err != nil {
+		return fmt.Errorf("reading result: %w", err)
+	}
+
+	var envelope grpc.ResultEnvelope
+	if err := json.Unmarshal(rawEnvelope, &envelope); err != nil {
+		return fmt.Errorf("decoding result envelope: %w", err)
+	}
+
+	client, close, err := grpc.NewClient(cli.Archive.Addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", cli.Archive.Addr, err)
+	}
+	defer close()
+
+	publicMainKey, err := client.PublicMainKey(ctx)
+	if err != nil {
+		return fmt.Errorf("getting public main key: %w", err)
+	}
+
+	a := archive.Archive{
+		Created:       time.Now(),
+		PublicMainKey: publicMainKey,
+		Envelope:      envelope,
+	}
+
+	if !cli.Archive.WithoutPollKey {
+		status, err := client.Status(ctx, envelope.ID)
+		if err != nil {
+			return fmt.Errorf("getting poll key: %w", err)
+		}
+		a.PollKey = status.PubKey
+		a.PollKeySig = status.PubKeySig
+	}
+
+	if cli.Archive.AdminToken != "" {
+		a.AuditLog, a.AuditLogSig, err = client.ExportAuditLog(ctx, cli.Archive.AdminToken, envelope.ID, time.Time{}, time.Time{})
+		if err != nil {
+			return fmt.Errorf("exporting audit log: %w", err)
+		}
+	}
+
+	// An archive, that can not be verified now, will not get better.
+	if _, err := a.Verify(); err != nil {
+		return fmt.Errorf("verifying archive: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err != nil {
+		return fmt.Errorf("creating archive: %w", err)
+	}
+
+	if err := os.WriteFile(cli.Archive.Output, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+
+	fmt.Printf("archived poll %s to %s\n", envelope.ID, cli.Archive.Output)
+	return nil
+}
+
+func runAuditVerify(ctx context.Context) error {
+	content, err := os.ReadFile(cli.Audit.Verify.Log)
+	if err != nil {
+		return fmt.Errorf("reading audit log: %w", err)
+	}
+
+	if cli.Audit.Verify.PublicMainKey != "" {
+		pubKey, err := base64.StdEncoding.DecodeString(cli.Audit.Verify.PublicMainKey)
+		if err != nil {
+			return fmt.Errorf("decoding public main key: %w", err)
+		}
+
+		encoded, err := os.ReadFile(cli.Audit.Verify.Log + ".sig")
+		if err != nil {
+			return fmt.Errorf("reading signature: %w", err)
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil {
+			return fmt.Errorf("decoding signature: %w", err)
+		}
+
+		if !crypto.Verify(pubKey, content, signature) {
+			return fmt.Errorf("invalid signature")
+		}
+		fmt.Println("signature: ok")
+	}
+
+	if err := audit.Verify(content); err != nil {
+		return fmt.Errorf("invalid hash chain: %w", err)
+	}
+	fmt.Println("hash chain: ok")
+	return nil
+}
+
+func runStoreVerify(ctx context.Context) error {
+	if cli.Store.Verify.IntegrityKey == "" {
+		return fmt.Errorf("no integrity key given. Use --integrity-key or VOTE_DECRYPT_INTEGRITY_KEY")
+	}
+
+	backend, err := cli.Store.Verify.OpenStore()
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+
+	statuses, err := backend.(*integrity.Store).Verify()
+	if err != nil {
+		return fmt.Errorf("verifying store: %w", err)
+	}
+
+	var invalid int
+	for _, status := range statuses {
+		if status.Err != nil {
+			invalid++
+			fmt.Printf("%s: INVALID: %v\n", status.ID, status.Err)
+			continue
+		}
+		fmt.Printf("%s: ok\n", status.ID)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d polls are invalid", invalid, len(statuses))
+	}
+	return nil
+}
+
+func runStoreGC(ctx context.Context) error {
+	backend, err := cli.Store.GC.OpenStore()
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+
+	// ScheduledClears also moves an old store into the current layout. So it
+	// has to be called before the usage is measured.
+	scheduled, err := backend.ScheduledClears()
+	if err != nil {
+		return fmt.Errorf("loading scheduled clears: %w", err)
+	}
+
+	before, err := storeUsage(cli.Store.GC.FilePaths())
+	if err != nil {
+		return err
+	}
+
+	var expired int
+	for _, at := range scheduled {
+		if !at.After(time.Now()) {
+			expired++
+		}
+	}
+
+	if cli.Store.GC.MainKey != nil {
+		key, err := server.ReadMainKey(cli.Store.GC.MainKey)
+		if err != nil {
+			return fmt.Errorf("reading key: %w", err)
+		}
+
+		var options []decrypt.Option
+		if cli.Store.GC.AuditLog != "" {
+			options = append(options, decrypt.WithAuditLog(audit.New(cli.Store.GC.AuditLog)))
+		}
+
+		decrypter := decrypt.New(crypto.New(key, rand.Reader, nil), backend, options...)
+		if err := decrypter.ClearScheduled(ctx); err != nil {
+			return fmt.Errorf("removing expired polls: %w", err)
+		}
+		fmt.Printf("expired polls removed: %d\n", expired)
+	} else if expired > 0 {
+		fmt.Printf("expired polls: %d (call the command with the main key to remove them)\n", expired)
+	}
+
+	compacter, ok := backend.(store.Compacter)
+	if !ok {
+		fmt.Printf("The %s store does not need compaction\n", cli.Store.GC.StoreBackend)
+		return nil
+	}
+
+	report, err := compacter.Compact()
+	if err != nil {
+		return fmt.Errorf("compacting store: %w", err)
+	}
+
+	fmt.Printf("stale index entries: %d\n", report.StaleEntries)
+	fmt.Printf("empty directories: %d\n", report.EmptyDirs)
+	for _, orphan := range report.Orphans {
+		fmt.Printf("orphaned directory: %s\n", orphan)
+	}
+
+	after, err := storeUsage(cli.Store.GC.FilePaths())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("reclaimed: %d bytes\n", before-after)
+
+	return nil
+}
+
+// storeUsage returns the size of the file system stores in bytes.
+func storeUsage(paths []string) (int64, error) {
+	var size int64
+	for _, path := range paths {
+		usage, err := store.New(path).Usage()
+		if err != nil {
+			return 0, fmt.Errorf("measuring store %s: %w", path, err)
+		}
+		size += usage
+	}
+	return size, nil
+}
+
+// replayVotes is the format of the archived votes for the replay command.
+type replayVotes struct {
+	Votes   [][]byte `json:"votes"`
+	Weights []string `json:"weights"`
+}
+
+func runReplay(ctx context.Context) error {
+	publicMainKey, err := base64.StdEncoding.DecodeString(cli.Replay.PublicMainKey)
+	if err != nil {
+		return fmt.Errorf("decoding public main key: %w", err)
+	}
+
+	rawEnvelope, err := io.ReadAll(cli.Replay.Result)
+	if err != nil {
+		return fmt.Errorf("reading result: %w", err)
+	}
+
+	var envelope grpc.ResultEnvelope
+	if err := json.Unmarshal(rawEnvelope, &envelope); err != nil {
+		return fmt.Errorf("decoding result: %w", err)
+	}
+
+	if err := envelope.Verify(publicMainKey); err != nil {
+		return fmt.Errorf("verifying result: %w", err)
+	}
+	fmt.Println("signature: ok")
+
+	var votes replayVotes
+	if err := json.NewDecoder(cli.Replay.Votes).Decode(&votes); err != nil {
+		return fmt.Errorf("decoding votes: %w", err)
+	}
+
+	// The main key is not needed to decrypt votes.
+	randomMainKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, randomMainKey); err != nil {
+		return fmt.Errorf("reading random: %w", err)
+	}
+	cryptoLib, err := server.LocalCrypto(randomMainKey, rand.Reader, cli.Replay.FIPS)
+	if err != nil {
+		return fmt.Errorf("initializing crypto: %w", err)
+	}
+
+	pollKey, err := replayPollKey(cryptoLib)
+	if err != nil {
+		return err
+	}
+
+	result, err := decrypt.ParseResult(envelope.Content)
+	if err != nil {
+		return fmt.Errorf("parsing signed content: %w", err)
+	}
+
+	var options []decrypt.Option
+	if cli.Replay.MaxPlaintextSize > 0 {
+		policy := decrypt.OversizeFail
+		if cli.Replay.OversizePolicy == "invalid" {
+			policy = decrypt.OversizeInvalid
+		}
+		options = append(options, decrypt.WithMaxPlaintextSize(cli.Replay.MaxPlaintextSize, policy))
+	}
+
+	if len(cli.Replay.Formats) > 0 {
+		formats := make([]crypto.Format, len(cli.Replay.Formats))
+		for i, name := range cli.Replay.Formats {
+			format, err := crypto.ParseFormat(name)
+			if err != nil {
+				return fmt.Errorf("parsing formats: %w", err)
+			}
+			formats[i] = format
+		}
+		options = append(options, decrypt.WithVoteFilter(crypto.AcceptFormats(formats...)))
+	}
+
+	// The original result contains a ciphertext root, if the service was
+	// started with VOTE_DECRYPT_COMMITMENT.
+	if result.CiphertextRoot != nil {
+		options = append(options, decrypt.WithCommitment())
+	}
+
+	var stopOptions []decrypt.StopOption
+	if votes.Weights != nil {
+		stopOptions = append(stopOptions, decrypt.WithWeights(votes.Weights))
+	}
+
+	replayed, err := decrypt.New(cryptoLib, nil, options...).Replay(
+		ctx,
+		pollKey,
+		envelope.ID,
+		votes.Votes,
+		decrypt.StartConfig{Metadata: result.Metadata, NotBefore: result.NotBefore, Election: result.Election},
+		stopOptions...,
+	)
+	if err != nil {
+		return fmt.Errorf("replaying poll %s: %w", envelope.ID, err)
+	}
+
+	if !bytes.Equal(replayed, envelope.Content) {
+		fmt.Printf("content: MISMATCH\n")
+		fmt.Printf("signed:   %s\n", envelope.Content)
+		fmt.Printf("replayed: %s\n", replayed)
+		return fmt.Errorf("replayed result of poll %s does not match the signed result", envelope.ID)
+	}
+
+	fmt.Println("content: ok")
+	return nil
+}
+
+// replayPollKey returns the poll key for the replay command. It is read from
+// the poll key file or unsealed with the private escrow key.
+func replayPollKey(cryptoLib crypto.Crypto) ([]byte, error) {
+	if cli.Replay.PollKey != nil {
+		key, err := readPollKey(cli.Replay.PollKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading poll key: %w", err)
+		}
+		return key, nil
+	}
+
+	if cli.Replay.SealedKey == nil || cli.Replay.EscrowPrivateKey == nil {
+		return nil, fmt.Errorf("no poll key given. Use --poll-key or --sealed-key with --escrow-private-key")
+	}
+
+	sealed, err := io.ReadAll(cli.Replay.SealedKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading sealed key: %w", err)
+	}
+
+	escrowKey, err := readPollKey(cli.Replay.EscrowPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading escrow key: %w", err)
+	}
+
+	key, err := cryptoLib.Decrypt(escrowKey, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("unsealing poll key: %w", err)
+	}
+	return key, nil
+}
+
+// readPollKey reads a private poll key in the raw or PEM format.
+func readPollKey(f *os.File) ([]byte, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.DecodePollKey(data)
+}
+
+func runBench(ctx context.Context) error {
+	storePath := cli.Bench.Store
+	if storePath == "" {
+		dir, err := os.MkdirTemp("", "vote-decrypt-bench")
+		if err != nil {
+			return fmt.Errorf("creating temporary folder: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		storePath = dir
+	}
+
+	fmt.Printf("Decrypting %d polls with %d votes of %d bytes...\n", cli.Bench.Polls, cli.Bench.Votes, cli.Bench.VoteSize)
+
+	result, err := bench.Run(ctx, store.New(storePath), bench.Config{
+		Votes:    cli.Bench.Votes,
+		VoteSize: cli.Bench.VoteSize,
+		Polls:    cli.Bench.Polls,
+		Workers:  cli.Bench.Workers,
+		AutoTune: cli.Bench.AutoTune,
+	})
+	if err != nil {
+		return fmt.Errorf("running benchmark: %w", err)
+	}
+
+	fmt.Println(result)
+	return nil
+}
+
+func runLoadTest(ctx context.Context) error {
+	client, close, err := grpc.NewClient(cli.Loadtest.Addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", cli.Loadtest.Addr, err)
+	}
+	defer close()
+
+	mainKey, err := client.PublicMainKey(ctx)
+	if err != nil {
+		return fmt.Errorf("getting public main key: %w", err)
+	}
+
+	fmt.Printf("Running %d polls with %d votes of %d bytes against %s...\n", cli.Loadtest.Polls, cli.Loadtest.Votes, cli.Loadtest.VoteSize, cli.Loadtest.Addr)
+
+	result, err := bench.LoadTest(ctx, client, mainKey, bench.LoadConfig{
+		Polls:       cli.Loadtest.Polls,
+		Concurrency: cli.Loadtest.Concurrency,
+		Votes:       cli.Loadtest.Votes,
+		VoteSize:    cli.Loadtest.VoteSize,
+	})
+	if err != nil {
+		return fmt.Errorf("running load test: %w", err)
+	}
+
+	fmt.Println(result)
+
+	if result.Errors > 0 {
+		return fmt.Errorf("%d polls failed", result.Errors)
+	}
+	return nil
+}
+
+func runCryptoCaps(ctx context.Context) error {
+	caps := crypto.Caps()
+	fmt.Println(caps)
+
+	if cli.CryptoCaps.Duration > 0 {
+		primitives, err := bench.MeasurePrimitives(cli.CryptoCaps.Duration, cli.CryptoCaps.VoteSize)
+		if err != nil {
+			return fmt.Errorf("measuring primitives: %w", err)
+		}
+		fmt.Println(primitives)
+	}
+
+	if err := caps.CheckConstantTime(); err != nil {
+		if cli.CryptoCaps.RequireConstantTime {
+			return err
+		}
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+func runTPMSeal(ctx context.Context) error {
+	key, err := server.ReadMainKey(cli.TPMSeal.MainKey)
+	if err != nil {
 		return fmt.Errorf("reading key: %w", err)
 	}
 
-	if err := os.WriteFile(cli.MainKey.MainKey, key, 0o600); err != nil {
-		return fmt.Errorf("writing main key: %w", err)
+	device, err := tpm.Open(cli.TPMSeal.TPMDevice)
+	if err != nil {
+		return fmt.Errorf("opening tpm: %w", err)
+	}
+	defer device.Close()
+
+	sealed, err := tpm.Seal(device, key, cli.TPMSeal.PCR)
+	if err != nil {
+		return fmt.Errorf("sealing key: %w", err)
+	}
+
+	if err := os.WriteFile(cli.TPMSeal.SealedKey, sealed, 0o600); err != nil {
+		return fmt.Errorf("writing sealed key: %w", err)
 	}
 
 	return nil
 }
 
+// versionString returns the build information as indented json.
+func versionString() string {
+	info, err := json.MarshalIndent(version.Get(), "", "  ")
+	if err != nil {
+		return fmt.Sprintf("unknown: %v", err)
+	}
+	return string(info)
+}
+
 // interruptContext works like signal.NotifyContext. It returns a context that
 // is canceled, when a signal is received.
 //
-// It listens on os.Interrupt and unix.SIGTERM. If the signal is received two
+// It listens on os.Interrupt and syscall.SIGTERM. If the signal is received two
 // times, os.Exit(2) is called.
 func interruptContext() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, os.Interrupt, unix.SIGTERM)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 		<-sig
 		cancel()
 		<-sig
@@ -0,0 +1,133 @@
+// Package merkle implements a merkle tree over a list of hashes.
+//
+// The tree is the merkle hash tree from RFC 6962, section 2.1. A leaf is
+// hashed with the prefix 0x00, an inner node with the prefix 0x01. So a leaf
+// can not be used as an inner node. The list is split at the biggest power of
+// two, that is smaller then the number of leaves.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sync"
+)
+
+// parallelLeaves is the minimal number of leaves of a subtree, that is hashed
+// in its own goroutine by ParallelRoot().
+const parallelLeaves = 4096
+
+// Root returns the root hash of the tree over the leaves.
+func Root(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		empty := sha256.Sum256(nil)
+		return empty[:]
+	}
+
+	if len(leaves) == 1 {
+		return leafHash(leaves[0])
+	}
+
+	k := split(len(leaves))
+	return nodeHash(Root(leaves[:k]), Root(leaves[k:]))
+}
+
+// ParallelRoot returns the same hash as Root(), but hashes the subtrees with
+// up to workers goroutines.
+func ParallelRoot(leaves [][]byte, workers int) []byte {
+	if workers <= 1 || len(leaves) < 2*parallelLeaves {
+		return Root(leaves)
+	}
+
+	k := split(len(leaves))
+	var left []byte
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		left = ParallelRoot(leaves[:k], workers/2)
+	}()
+	right := ParallelRoot(leaves[k:], workers-workers/2)
+	wg.Wait()
+
+	return nodeHash(left, right)
+}
+
+// Proof returns the hashes, that are needed to calculate the root from the
+// leaf at index.
+func Proof(leaves [][]byte, index int) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+
+	k := split(len(leaves))
+	if index < k {
+		return append(Proof(leaves[:k], index), Root(leaves[k:]))
+	}
+	return append(Proof(leaves[k:], index-k), Root(leaves[:k]))
+}
+
+// Verify checks, that the leaf is at index in a tree with size leaves and the
+// root.
+func Verify(root []byte, leaf []byte, index, size int, proof [][]byte) bool {
+	if index < 0 || index >= size {
+		return false
+	}
+
+	hash, rest, ok := rootFromProof(leafHash(leaf), index, size, proof)
+	return ok && len(rest) == 0 && bytes.Equal(hash, root)
+}
+
+// rootFromProof calculates the root of a tree with size leaves from the hash
+// of the leaf at index. It consumes the proof from the end and returns the
+// unused part.
+func rootFromProof(hash []byte, index, size int, proof [][]byte) ([]byte, [][]byte, bool) {
+	if size == 1 {
+		return hash, proof, true
+	}
+
+	if len(proof) == 0 {
+		return nil, nil, false
+	}
+
+	sibling := proof[len(proof)-1]
+	proof = proof[:len(proof)-1]
+
+	k := split(size)
+	if index < k {
+		left, rest, ok := rootFromProof(hash, index, k, proof)
+		if !ok {
+			return nil, nil, false
+		}
+		return nodeHash(left, sibling), rest, true
+	}
+
+	right, rest, ok := rootFromProof(hash, index-k, size-k, proof)
+	if !ok {
+		return nil, nil, false
+	}
+	return nodeHash(sibling, right), rest, true
+}
+
+// split returns the biggest power of two, that is smaller then n.
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func leafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
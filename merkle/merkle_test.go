@@ -0,0 +1,69 @@
+package merkle_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/merkle"
+)
+
+func TestRoot(t *testing.T) {
+	// Test vector from RFC 6962 for the empty tree.
+	got := hex.EncodeToString(merkle.Root(nil))
+	expect := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got != expect {
+		t.Errorf("root of empty tree is %s, expected %s", got, expect)
+	}
+
+	a := merkle.Root([][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	b := merkle.Root([][]byte{[]byte("a"), []byte("c"), []byte("b")})
+	if hex.EncodeToString(a) == hex.EncodeToString(b) {
+		t.Errorf("trees with different order have the same root")
+	}
+}
+
+func TestProof(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			hash := sha256.Sum256([]byte(fmt.Sprintf("vote %d", i)))
+			leaves[i] = hash[:]
+		}
+		root := merkle.Root(leaves)
+
+		for index := range leaves {
+			proof := merkle.Proof(leaves, index)
+
+			if !merkle.Verify(root, leaves[index], index, size, proof) {
+				t.Errorf("size %d, index %d: proof is invalid", size, index)
+			}
+
+			if merkle.Verify(root, []byte("other"), index, size, proof) {
+				t.Errorf("size %d, index %d: proof is valid for another leaf", size, index)
+			}
+
+			if size > 1 && merkle.Verify(root, leaves[index], (index+1)%size, size, proof) {
+				t.Errorf("size %d, index %d: proof is valid for another index", size, index)
+			}
+		}
+	}
+}
+
+func TestParallelRoot(t *testing.T) {
+	for _, size := range []int{0, 1, 100, 10000, 33333} {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			hash := sha256.Sum256([]byte(fmt.Sprintf("vote %d", i)))
+			leaves[i] = hash[:]
+		}
+
+		expect := hex.EncodeToString(merkle.Root(leaves))
+		for _, workers := range []int{1, 3, 8} {
+			if got := hex.EncodeToString(merkle.ParallelRoot(leaves, workers)); got != expect {
+				t.Errorf("size %d, %d workers: got root %s, expected %s", size, workers, got, expect)
+			}
+		}
+	}
+}
@@ -0,0 +1,388 @@
+// Package metrics implements simple metrics that can be exported in the
+// prometheus text format.
+//
+// Metrics are created with NewCounter(), NewGauge() or NewHistogram() and are
+// registered globally. Handler() returns a http handler that writes all
+// registered metrics.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry = append(registry, m)
+}
+
+// vec holds the values of a metric for each combination of label values.
+type vec struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+
+	keys keyCache
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labels:     labels,
+		values:     make(map[string]float64),
+	}
+}
+
+func (v *vec) name() string {
+	return v.metricName
+}
+
+// key builds the label part of a metric line. It panics, if the number of
+// label values does not match the labels of the metric, since this is an
+// error in the code.
+func (v *vec) key(labelValues []string) string {
+	return v.keys.key(v.metricName, v.labels, labelValues)
+}
+
+// maxCachedLabels is the maximum number of labels of a metric, for which the
+// keys are cached.
+const maxCachedLabels = 4
+
+// keyCache remembers the key of each combination of label values. So metrics,
+// that are updated for each vote, do not allocate memory after the first
+// update.
+//
+// The number of label values is small for each metric, so the cache does not
+// need a limit.
+type keyCache struct {
+	mu   sync.RWMutex
+	keys map[[maxCachedLabels]string]string
+}
+
+func (c *keyCache) key(metricName string, labels, labelValues []string) string {
+	if len(labels) > maxCachedLabels || len(labelValues) != len(labels) {
+		return labelKey(metricName, labels, labelValues)
+	}
+
+	var values [maxCachedLabels]string
+	copy(values[:], labelValues)
+
+	c.mu.RLock()
+	key, ok := c.keys[values]
+	c.mu.RUnlock()
+	if ok {
+		return key
+	}
+
+	key = labelKey(metricName, labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[[maxCachedLabels]string]string)
+	}
+	c.keys[values] = key
+	return key
+}
+
+func labelKey(metricName string, labels, labelValues []string) string {
+	if len(labelValues) != len(labels) {
+		panic(fmt.Sprintf("metric %s needs %d label values, got %d", metricName, len(labels), len(labelValues)))
+	}
+
+	if len(labels) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = fmt.Sprintf("%s=%q", label, labelValues[i])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func (v *vec) add(value float64, labelValues []string) {
+	key := v.key(labelValues)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[key] += value
+}
+
+func (v *vec) set(value float64, labelValues []string) {
+	key := v.key(labelValues)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[key] = value
+}
+
+func (v *vec) get(labelValues []string) float64 {
+	key := v.key(labelValues)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.values[key]
+}
+
+func (v *vec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", v.metricName, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.metricName, v.kind)
+
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %g\n", v.metricName, key, v.values[key])
+	}
+}
+
+// Counter is a metric that can only increase.
+type Counter struct {
+	vec *vec
+}
+
+// NewCounter creates and registers a new counter.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, "counter", labels)}
+	register(c.vec)
+	return c
+}
+
+// Inc increases the counter for the given label values by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.vec.add(1, labelValues)
+}
+
+// Add increases the counter for the given label values by value.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.vec.add(value, labelValues)
+}
+
+// Value returns the current value for the given label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.vec.get(labelValues)
+}
+
+// Gauge is a metric that can increase and decrease.
+type Gauge struct {
+	vec *vec
+}
+
+// NewGauge creates and registers a new gauge.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labels)}
+	register(g.vec)
+	return g
+}
+
+// Set sets the gauge for the given label values.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.vec.set(value, labelValues)
+}
+
+// Add adds value to the gauge for the given label values. value can be
+// negative.
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.vec.add(value, labelValues)
+}
+
+// Value returns the current value for the given label values.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.vec.get(labelValues)
+}
+
+// Histogram is a metric that counts observed values in buckets, for example
+// durations.
+type Histogram struct {
+	metricName string
+	help       string
+	labels     []string
+	buckets    []float64
+	keys       keyCache
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64 // counts[i] is the number of values <= buckets[i].
+	count  uint64
+	sum    float64
+}
+
+// DurationBuckets are buckets for durations in seconds from 10 microseconds to
+// 10 seconds.
+var DurationBuckets = []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.1, 1, 10}
+
+// NewHistogram creates and registers a new histogram. buckets are the upper
+// bounds of the buckets in increasing order.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		buckets:    buckets,
+		values:     make(map[string]*histogramValue),
+	}
+	register(h)
+	return h
+}
+
+func (h *Histogram) name() string {
+	return h.metricName
+}
+
+// Observe adds a value for the given label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.keys.key(h.metricName, h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+// Count returns the number of observed values for the given label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.keys.key(h.metricName, h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if v, ok := h.values[key]; ok {
+		return v.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.metricName, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.metricName)
+
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, bucketKey(key, fmt.Sprintf("%g", bound)), v.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, bucketKey(key, "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", h.metricName, key, v.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, key, v.count)
+	}
+}
+
+// bucketKey adds the label `le` to the label part of a metric line.
+func bucketKey(key, bound string) string {
+	le := fmt.Sprintf("le=%q", bound)
+	if key == "" {
+		return "{" + le + "}"
+	}
+	return strings.TrimSuffix(key, "}") + "," + le + "}"
+}
+
+// Write writes all registered metrics in the prometheus text format.
+func Write(w io.Writer) {
+	registryMu.Lock()
+	metrics := make([]metric, len(registry))
+	copy(metrics, registry)
+	registryMu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler returns a http handler that writes all registered metrics.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Write(w)
+	})
+}
+
+// RunServer runs a http server on the given addr that serves the metrics on
+// the path `/metrics` until ctx is done.
+func RunServer(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	wait := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+		wait <- struct{}{}
+	}()
+
+	log.Printf("Running metrics server on %s\n", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("running metrics server: %w", err)
+	}
+
+	<-wait
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/metrics"
+)
+
+func TestCounter(t *testing.T) {
+	c := metrics.NewCounter("test_counter_total", "A counter for testing.", "method")
+
+	c.Inc("Start")
+	c.Inc("Start")
+	c.Add(3, "Stop")
+
+	if got := c.Value("Start"); got != 2 {
+		t.Errorf("counter for Start is %g, expected 2", got)
+	}
+
+	buf := new(bytes.Buffer)
+	metrics.Write(buf)
+
+	for _, line := range []string{
+		"# TYPE test_counter_total counter",
+		`test_counter_total{method="Start"} 2`,
+		`test_counter_total{method="Stop"} 3`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("output does not contain line `%s`:\n%s", line, buf)
+		}
+	}
+}
+
+func TestGauge(t *testing.T) {
+	g := metrics.NewGauge("test_gauge", "A gauge for testing.")
+
+	g.Set(5)
+	g.Add(-2)
+
+	buf := new(bytes.Buffer)
+	metrics.Write(buf)
+
+	if !strings.Contains(buf.String(), "test_gauge 3\n") {
+		t.Errorf("output does not contain gauge value:\n%s", buf)
+	}
+}
+
+func TestPollLabels(t *testing.T) {
+	labels := metrics.PollLabels{Allow: []string{"1/5", "2/*"}}
+
+	for pollID, expect := range map[string]string{
+		"1/5":  "1/5",
+		"1/50": metrics.OtherPolls,
+		"2/7":  "2/7",
+		"3/1":  metrics.OtherPolls,
+	} {
+		if got := labels.Label(pollID); got != expect {
+			t.Errorf("label of %s is %s, expected %s", pollID, got, expect)
+		}
+	}
+
+	labels.Buckets = 4
+	used := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		label := labels.Label(fmt.Sprintf("3/%d", i))
+		if label != labels.Label(fmt.Sprintf("3/%d", i)) {
+			t.Fatalf("label of poll 3/%d is not stable", i)
+		}
+		used[label] = true
+	}
+
+	if len(used) != 4 {
+		t.Errorf("got labels %v, expected 4 buckets", used)
+	}
+}
+
+func TestPollMonitor(t *testing.T) {
+	m := metrics.NewPollMonitor(metrics.PollLabels{Allow: []string{"monitor/1"}})
+
+	m.StopStarted("monitor/1")
+	m.StopFinished("monitor/1", 10, 2, nil)
+	m.StopStarted("monitor/2")
+	m.StopFinished("monitor/2", 5, 0, nil)
+	m.StopStarted("monitor/3")
+	m.StopFinished("monitor/3", 5, 0, errors.New("failed"))
+
+	buf := new(bytes.Buffer)
+	metrics.Write(buf)
+
+	for _, line := range []string{
+		`vote_decrypt_poll_votes_total{poll="monitor/1"} 10`,
+		`vote_decrypt_poll_invalid_votes_total{poll="monitor/1"} 2`,
+		`vote_decrypt_poll_votes_total{poll="other"} 5`,
+		`vote_decrypt_poll_stops_total{poll="other"} 2`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("output does not contain line `%s`:\n%s", line, buf)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := metrics.NewHistogram("test_histogram_seconds", "A histogram for testing.", []float64{0.1, 1}, "format")
+
+	h.Observe(0.05, "v1")
+	h.Observe(0.5, "v1")
+	h.Observe(5, "v1")
+
+	if got := h.Count("v1"); got != 3 {
+		t.Errorf("count for v1 is %d, expected 3", got)
+	}
+
+	buf := new(bytes.Buffer)
+	metrics.Write(buf)
+
+	for _, line := range []string{
+		"# TYPE test_histogram_seconds histogram",
+		`test_histogram_seconds_bucket{format="v1",le="0.1"} 1`,
+		`test_histogram_seconds_bucket{format="v1",le="1"} 2`,
+		`test_histogram_seconds_bucket{format="v1",le="+Inf"} 3`,
+		`test_histogram_seconds_sum{format="v1"} 5.55`,
+		`test_histogram_seconds_count{format="v1"} 3`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("output does not contain line `%s`:\n%s", line, buf)
+		}
+	}
+}
+
+func TestNoAllocations(t *testing.T) {
+	c := metrics.NewCounter("test_allocs_total", "A counter for testing allocations.", "format", "aead")
+	h := metrics.NewHistogram("test_allocs_seconds", "A histogram for testing allocations.", metrics.DurationBuckets, "format", "aead")
+
+	c.Inc("v2", "aes-256-gcm")
+	h.Observe(0.1, "v2", "aes-256-gcm")
+
+	if allocs := testing.AllocsPerRun(100, func() { c.Inc("v2", "aes-256-gcm") }); allocs != 0 {
+		t.Errorf("Inc allocates %g times", allocs)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { h.Observe(0.1, "v2", "aes-256-gcm") }); allocs != 0 {
+		t.Errorf("Observe allocates %g times", allocs)
+	}
+}
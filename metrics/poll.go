@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	metricPollVotes = NewCounter(
+		"vote_decrypt_poll_votes_total",
+		"Number of decrypted votes by poll.",
+		"poll",
+	)
+
+	metricPollInvalid = NewCounter(
+		"vote_decrypt_poll_invalid_votes_total",
+		"Number of votes, that could not be decrypted or were rejected, by poll.",
+		"poll",
+	)
+
+	metricPollStops = NewCounter(
+		"vote_decrypt_poll_stops_total",
+		"Number of decryptions by poll.",
+		"poll",
+	)
+
+	metricPollStopSeconds = NewCounter(
+		"vote_decrypt_poll_stop_seconds_total",
+		"Time, that the decryptions took, by poll.",
+		"poll",
+	)
+)
+
+// OtherPolls is the label of all polls, that are not in the allowlist, if no
+// hash buckets are used.
+const OtherPolls = "other"
+
+// PollLabels decides the value of the label `poll` of the per poll metrics.
+//
+// Each value creates new time series in prometheus. On instances with
+// thousands of polls, the poll ids can not be used directly. Polls in the
+// allowlist get their id as label. All other polls are put into a fixed number
+// of buckets by a hash of their id, or, without buckets, into the label
+// `other`.
+type PollLabels struct {
+	// Allow contains poll ids or prefixes of poll ids, that end with `*`, for
+	// example `1/*` for all polls of the namespace 1.
+	Allow []string
+
+	// Buckets is the number of hash buckets. Their labels are `bucket-0` to
+	// `bucket-N`.
+	Buckets int
+}
+
+// Label returns the label for a poll.
+func (l PollLabels) Label(pollID string) string {
+	for _, allowed := range l.Allow {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(pollID, prefix) {
+				return pollID
+			}
+			continue
+		}
+
+		if pollID == allowed {
+			return pollID
+		}
+	}
+
+	if l.Buckets <= 0 {
+		return OtherPolls
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(pollID))
+	return fmt.Sprintf("bucket-%d", h.Sum32()%uint32(l.Buckets))
+}
+
+// PollMonitor writes the per poll metrics. It implements decrypt.Monitor.
+type PollMonitor struct {
+	labels PollLabels
+	now    func() time.Time
+
+	mu      sync.Mutex
+	running map[string]time.Time
+}
+
+// NewPollMonitor initializes a PollMonitor.
+func NewPollMonitor(labels PollLabels) *PollMonitor {
+	return &PollMonitor{
+		labels:  labels,
+		now:     time.Now,
+		running: make(map[string]time.Time),
+	}
+}
+
+// StopStarted starts the timer for the decryption of a poll.
+func (m *PollMonitor) StopStarted(pollID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.running[pollID] = m.now()
+}
+
+// StopFinished counts the votes and the duration of a decryption. Failed
+// decryptions are only counted with their duration.
+func (m *PollMonitor) StopFinished(pollID string, votes, invalid int, err error) {
+	m.mu.Lock()
+	started, ok := m.running[pollID]
+	delete(m.running, pollID)
+	m.mu.Unlock()
+
+	label := m.labels.Label(pollID)
+	metricPollStops.Inc(label)
+	if ok {
+		metricPollStopSeconds.Add(m.now().Sub(started).Seconds(), label)
+	}
+
+	if err != nil {
+		return
+	}
+
+	metricPollVotes.Add(float64(votes), label)
+	metricPollInvalid.Add(float64(invalid), label)
+}
@@ -0,0 +1,290 @@
+// Package objectstore uploads results to an S3 compatible object storage and
+// creates presigned urls to download them.
+//
+// Big results do not have to be sent in the response of Stop. Many API
+// gateways limit the size of responses.
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxExpiry is the longest validity of a presigned url, that S3 accepts.
+const maxExpiry = 7 * 24 * time.Hour
+
+// Config configures the object storage.
+type Config struct {
+	// Endpoint is the url of the service, for example
+	// `https://s3.eu-central-1.amazonaws.com` or `http://minio:9000`.
+	Endpoint string
+
+	// Bucket is appended to the endpoint as first path segment. If empty, the
+	// endpoint has to be the url of the bucket.
+	Bucket string
+
+	// Region of the bucket, for example `eu-central-1`.
+	Region string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Prefix is prepended to the name of each object.
+	Prefix string
+
+	// Expiry is the validity of the presigned urls. Defaults to one hour.
+	Expiry time.Duration
+}
+
+// S3 uploads results to an S3 compatible object storage.
+type S3 struct {
+	config   Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// New initializes the object storage.
+func New(config Config) (*S3, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint: %w", err)
+	}
+
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("endpoint %s is not a http url", config.Endpoint)
+	}
+
+	if config.Expiry == 0 {
+		config.Expiry = time.Hour
+	}
+
+	if config.Expiry > maxExpiry {
+		return nil, fmt.Errorf("expiry %s is longer then %s", config.Expiry, maxExpiry)
+	}
+
+	return &S3{
+		config:   config,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+// Upload saves the result of a poll and returns a presigned url for it.
+//
+// The name of the object is built from the sha256 hash of the poll id and of
+// the content, so the poll id is not visible in the url.
+func (s *S3) Upload(ctx context.Context, pollID string, content []byte) (string, error) {
+	pollHash := sha256.Sum256([]byte(pollID))
+	contentHash := sha256.Sum256(content)
+	key := s.config.Prefix + hex.EncodeToString(pollHash[:]) + "/" + hex.EncodeToString(contentHash[:])
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	s.signRequest(req, s.canonicalPath(key), hex.EncodeToString(contentHash[:]))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("object storage returned status %d: %s", resp.StatusCode, body)
+	}
+
+	return s.presign(key), nil
+}
+
+// objectURL returns the url of the object with the key.
+func (s *S3) objectURL(key string) string {
+	return s.endpoint.Scheme + "://" + s.endpoint.Host + s.canonicalPath(key)
+}
+
+// canonicalPath returns the uri encoded path of the object.
+func (s *S3) canonicalPath(key string) string {
+	path := strings.TrimSuffix(s.endpoint.Path, "/")
+	if s.config.Bucket != "" {
+		path += "/" + uriEncode(s.config.Bucket, true)
+	}
+	return path + "/" + uriEncode(key, false)
+}
+
+// signRequest adds the headers for AWS signature version 4.
+func (s *S3) signRequest(req *http.Request, canonicalPath, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+	}
+
+	signedHeaders := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	if s.config.SessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = s.endpoint.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath,
+		"",
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(now)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID,
+		scope,
+		strings.Join(signedHeaders, ";"),
+		s.signature(now, canonicalRequest),
+	))
+}
+
+// presign returns a url to download the object, that is valid for the
+// configured expiry. It is signed with AWS signature version 4 in the query
+// string.
+func (s *S3) presign(key string) string {
+	now := s.now().UTC()
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.config.AccessKeyID + "/" + s.scope(now),
+		"X-Amz-Date":          now.Format("20060102T150405Z"),
+		"X-Amz-Expires":       strconv.Itoa(int(s.config.Expiry.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	if s.config.SessionToken != "" {
+		query["X-Amz-Security-Token"] = s.config.SessionToken
+	}
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, len(names))
+	for i, name := range names {
+		params[i] = uriEncode(name, true) + "=" + uriEncode(query[name], true)
+	}
+	canonicalQuery := strings.Join(params, "&")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		s.canonicalPath(key),
+		canonicalQuery,
+		"host:" + s.endpoint.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	return s.objectURL(key) + "?" + canonicalQuery + "&X-Amz-Signature=" + s.signature(now, canonicalRequest)
+}
+
+func (s *S3) scope(now time.Time) string {
+	return fmt.Sprintf("%s/%s/s3/aws4_request", now.Format("20060102"), s.config.Region)
+}
+
+func (s *S3) signature(now time.Time, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		s.scope(now),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode encodes a value as required by AWS signature version 4. All
+// characters except A-Z, a-z, 0-9, '-', '.', '_' and '~' are encoded. The
+// slash is only encoded, if encodeSlash is true.
+func uriEncode(value string, encodeSlash bool) string {
+	var out strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			out.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			out.WriteByte(b)
+		default:
+			fmt.Fprintf(&out, "%%%02X", b)
+		}
+	}
+	return out.String()
+}
+
+// Fetch downloads a result from a presigned url and checks, that it has the
+// sha256 hash.
+//
+// The signature of the result has to be checked separately.
+func Fetch(ctx context.Context, resultURL string, hash []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resultURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("object storage returned status %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading result: %w", err)
+	}
+
+	got := sha256.Sum256(content)
+	if !hmac.Equal(got[:], hash) {
+		return nil, fmt.Errorf("result has a different hash")
+	}
+
+	return content, nil
+}
@@ -0,0 +1,96 @@
+package objectstore_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/objectstore"
+)
+
+// fakeS3 saves uploaded objects and serves them, if the request has a
+// signature.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key-id/") {
+			http.Error(w, "no signature", http.StatusForbidden)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+
+	case http.MethodGet:
+		if r.URL.Query().Get("X-Amz-Signature") == "" {
+			http.Error(w, "no signature", http.StatusForbidden)
+			return
+		}
+
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(object)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	s3, err := objectstore.New(objectstore.Config{
+		Endpoint:        srv.URL,
+		Bucket:          "results",
+		Region:          "eu-central-1",
+		AccessKeyID:     "key-id",
+		SecretAccessKey: "secret",
+		Prefix:          "vote/",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	content := []byte(`{"id":"test/1","votes":["Y"]}`)
+	resultURL, err := s3.Upload(context.Background(), "test/1", content)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if strings.Contains(resultURL, "test") {
+		t.Errorf("url %s contains the poll id", resultURL)
+	}
+
+	if !strings.HasPrefix(resultURL, srv.URL+"/results/vote/") {
+		t.Errorf("url %s is not in the bucket with the prefix", resultURL)
+	}
+
+	hash := sha256.Sum256(content)
+	got, err := objectstore.Fetch(context.Background(), resultURL, hash[:])
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+
+	if string(got) != string(content) {
+		t.Errorf("got %s, expected %s", got, content)
+	}
+
+	if _, err := objectstore.Fetch(context.Background(), resultURL, make([]byte, 32)); err == nil {
+		t.Errorf("Fetch with a wrong hash did not fail")
+	}
+}
@@ -0,0 +1,122 @@
+// Package policy delegates authorization decisions to an Open Policy Agent
+// (OPA) server.
+//
+// For each call, the server gets an Input and returns, if the call is allowed.
+// This way, rules like "only during meeting hours" can be changed in a Rego
+// policy without changing the service.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// requestTimeout is the maximum time for one decision of the OPA server.
+const requestTimeout = 5 * time.Second
+
+// Input is the input of the policy for one call.
+type Input struct {
+	// Method is the name of the grpc method, for example `Stop`.
+	Method string `json:"method"`
+
+	// Caller is the identity of the caller, like it is written to the audit
+	// log, for example `mtls:vote-service`.
+	Caller string `json:"caller"`
+
+	// PollID is the id of the poll or election of the request. It is empty
+	// for methods without an id.
+	PollID string `json:"poll_id,omitempty"`
+
+	// Metadata is the metadata of the poll. For Start, it is the metadata of
+	// the request, otherwise the metadata, that the poll was started with. It
+	// is only set, if the metadata is valid json.
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+
+	// Time is the time of the call.
+	Time time.Time `json:"time"`
+}
+
+// Decision is the answer of the policy.
+type Decision struct {
+	Allow bool `json:"allow"`
+
+	// Reason can be set by the policy to explain a denied call.
+	Reason string `json:"reason,omitempty"`
+}
+
+// OPA asks an OPA server for decisions with its data API.
+type OPA struct {
+	url    string
+	client *http.Client
+}
+
+// NewOPA initializes an OPA client. url is the url of the decision, for
+// example `http://localhost:8181/v1/data/vote_decrypt/allow`.
+func NewOPA(url string) *OPA {
+	return &OPA{
+		url:    url,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Allow asks the OPA server, if a call is allowed.
+//
+// The result of the decision can be a boolean or an object like Decision. An
+// undefined result denies the call.
+func (o *OPA) Allow(ctx context.Context, input Input) (Decision, error) {
+	body, err := json.Marshal(struct {
+		Input Input `json:"input"`
+	}{input})
+	if err != nil {
+		return Decision{}, fmt.Errorf("encoding input: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return Decision{}, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return Decision{}, fmt.Errorf("opa returned status %s", resp.Status)
+	}
+
+	var decoded struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return Decision{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return parseResult(decoded.Result)
+}
+
+// parseResult decodes the result of a decision.
+func parseResult(result json.RawMessage) (Decision, error) {
+	if len(result) == 0 {
+		return Decision{Reason: "policy result is undefined"}, nil
+	}
+
+	var allow bool
+	if err := json.Unmarshal(result, &allow); err == nil {
+		return Decision{Allow: allow}, nil
+	}
+
+	var decision Decision
+	if err := json.Unmarshal(result, &decision); err != nil {
+		return Decision{}, fmt.Errorf("policy result %s is not a boolean or an object with the field allow", result)
+	}
+	return decision, nil
+}
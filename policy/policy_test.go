@@ -0,0 +1,83 @@
+package policy_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/policy"
+)
+
+func TestOPA(t *testing.T) {
+	var body struct {
+		Input policy.Input `json:"input"`
+	}
+	var result string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		w.Write([]byte(result))
+	}))
+	defer ts.Close()
+
+	opa := policy.NewOPA(ts.URL)
+	input := policy.Input{
+		Method:   "Stop",
+		Caller:   "mtls:vote-service",
+		PollID:   "meeting/1/poll/5",
+		Metadata: json.RawMessage(`{"meeting":1}`),
+		Time:     time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	for _, tt := range []struct {
+		name   string
+		result string
+		expect policy.Decision
+		err    bool
+	}{
+		{"boolean true", `{"result":true}`, policy.Decision{Allow: true}, false},
+		{"boolean false", `{"result":false}`, policy.Decision{}, false},
+		{"object", `{"result":{"allow":false,"reason":"outside meeting hours"}}`, policy.Decision{Reason: "outside meeting hours"}, false},
+		{"undefined", `{}`, policy.Decision{Reason: "policy result is undefined"}, false},
+		{"invalid", `{"result":"yes"}`, policy.Decision{}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			result = tt.result
+
+			got, err := opa.Allow(context.Background(), input)
+			if tt.err {
+				if err == nil {
+					t.Errorf("Allow returned %v, expected an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Allow: %v", err)
+			}
+
+			if got != tt.expect {
+				t.Errorf("Allow returned %v, expected %v", got, tt.expect)
+			}
+
+			if body.Input.Method != "Stop" || body.Input.Caller != "mtls:vote-service" || string(body.Input.Metadata) != `{"meeting":1}` {
+				t.Errorf("server got input %v", body.Input)
+			}
+		})
+	}
+
+	t.Run("server error", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "broken", http.StatusInternalServerError)
+		}))
+		defer ts.Close()
+
+		if _, err := policy.NewOPA(ts.URL).Allow(context.Background(), input); err == nil {
+			t.Errorf("Allow returned no error")
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package qr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// The example 1-M from the QR code tutorial of thonky.com.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	expect := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	if !bytes.Equal(got, expect) {
+		t.Errorf("got ecc %v, expected %v", got, expect)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	for mask, expect := range []int{0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0} {
+		if got := formatBits(mask); got != expect {
+			t.Errorf("format bits for mask %d: got %#x, expected %#x", mask, got, expect)
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	for version, expect := range map[int]int{7: 0x07C94, 8: 0x085BC, 40: 0x28C69} {
+		if got := versionBits(version); got != expect {
+			t.Errorf("version bits for %d: got %#x, expected %#x", version, got, expect)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		size    int
+		version int
+	}{
+		{"empty", 0, 1},
+		{"version 1", 14, 1},
+		{"version 2", 15, 2},
+		{"version 3", 42, 3},
+		{"public key", 44, 4},
+		{"version 10", 213, 10},
+		{"version 11", 214, 11},
+		{"version 40", 2331, 40},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte("a"), tt.size)
+			code, err := Encode(data)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			if code.version != tt.version {
+				t.Errorf("got version %d, expected %d", code.version, tt.version)
+			}
+
+			if got := code.readCodewords(); !bytes.Equal(got, addECCAndInterleave(code.version, encodeData(code.version, data))) {
+				t.Errorf("codewords in the code do not match the encoded data")
+			}
+		})
+	}
+
+	if _, err := Encode(make([]byte, 2332)); err == nil {
+		t.Errorf("Encode with too much data did not fail")
+	}
+}
+
+// readCodewords reads the codewords from the modules like a scanner.
+func (c *Code) readCodewords() []byte {
+	mask := -1
+	for m := 0; m < 8 && mask < 0; m++ {
+		bits := formatBits(m)
+		mask = m
+		for i := 0; i <= 5; i++ {
+			if c.modules[i][8] != ((bits>>i)&1 == 1) {
+				mask = -1
+			}
+		}
+	}
+
+	c.applyMask(mask)
+	defer c.applyMask(mask)
+
+	var result []byte
+	var i int
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.size - 1 - vert
+				}
+
+				if c.function[y][x] || i >= rawDataModules(c.version)/8*8 {
+					continue
+				}
+
+				if i%8 == 0 {
+					result = append(result, 0)
+				}
+				if c.modules[y][x] {
+					result[i/8] |= 1 << (7 - i%8)
+				}
+				i++
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+// Package redact contains wrappers for key material and decrypted votes, that
+// can not be logged by accident.
+//
+// All methods, that fmt, log, log/slog and encoding/json use to print a value,
+// return a placeholder instead of the content. The content is only returned by
+// Reveal().
+//
+// The tool cmd/logcheck makes sure, that the packages with key material or
+// votes do not log raw byte slices.
+package redact
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Secret is key material, for example a poll key or the main key.
+type Secret[T any] struct {
+	value T
+}
+
+// NewSecret wraps a value.
+func NewSecret[T any](value T) Secret[T] {
+	return Secret[T]{value: value}
+}
+
+// Reveal returns the wrapped value.
+func (s Secret[T]) Reveal() T {
+	return s.value
+}
+
+// String returns a placeholder.
+func (s Secret[T]) String() string {
+	return "[REDACTED SECRET]"
+}
+
+// GoString returns a placeholder for %#v.
+func (s Secret[T]) GoString() string {
+	return s.String()
+}
+
+// Format prints the placeholder for all verbs, including %x and %q.
+func (s Secret[T]) Format(f fmt.State, verb rune) {
+	io.WriteString(f, s.String())
+}
+
+// LogValue returns the placeholder for log/slog.
+func (s Secret[T]) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// MarshalJSON returns the placeholder as json string.
+func (s Secret[T]) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// Plaintext is the content of a decrypted vote.
+//
+// The placeholder contains the size of byte slices and strings, since it is
+// useful for debugging and not secret.
+type Plaintext[T any] struct {
+	value T
+}
+
+// NewPlaintext wraps a value.
+func NewPlaintext[T any](value T) Plaintext[T] {
+	return Plaintext[T]{value: value}
+}
+
+// Reveal returns the wrapped value.
+func (p Plaintext[T]) Reveal() T {
+	return p.value
+}
+
+// String returns a placeholder.
+func (p Plaintext[T]) String() string {
+	switch v := any(p.value).(type) {
+	case []byte:
+		return fmt.Sprintf("[REDACTED PLAINTEXT %d bytes]", len(v))
+	case string:
+		return fmt.Sprintf("[REDACTED PLAINTEXT %d bytes]", len(v))
+	default:
+		return "[REDACTED PLAINTEXT]"
+	}
+}
+
+// GoString returns a placeholder for %#v.
+func (p Plaintext[T]) GoString() string {
+	return p.String()
+}
+
+// Format prints the placeholder for all verbs, including %x and %q.
+func (p Plaintext[T]) Format(f fmt.State, verb rune) {
+	io.WriteString(f, p.String())
+}
+
+// LogValue returns the placeholder for log/slog.
+func (p Plaintext[T]) LogValue() slog.Value {
+	return slog.StringValue(p.String())
+}
+
+// MarshalJSON returns the placeholder as json string.
+func (p Plaintext[T]) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + p.String() + `"`), nil
+}
@@ -0,0 +1,56 @@
+package redact_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/redact"
+)
+
+func TestSecret(t *testing.T) {
+	secret := redact.NewSecret([]byte("poll-key"))
+
+	for _, verb := range []string{"%s", "%v", "%+v", "%#v", "%x", "%q", "%d"} {
+		if got := fmt.Sprintf(verb, secret); strings.Contains(got, "poll-key") || strings.Contains(got, "706f6c6c") {
+			t.Errorf("%s printed the secret: %s", verb, got)
+		}
+	}
+
+	if got := fmt.Sprintf("%v", struct{ Key redact.Secret[[]byte] }{secret}); strings.Contains(got, "poll-key") {
+		t.Errorf("secret in a struct was printed: %s", got)
+	}
+
+	encoded, err := json.Marshal(map[string]any{"key": secret})
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if bytes.Contains(encoded, []byte("poll-key")) {
+		t.Errorf("json contains the secret: %s", encoded)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("test", "key", secret)
+	if strings.Contains(buf.String(), "poll-key") {
+		t.Errorf("slog printed the secret: %s", buf.String())
+	}
+
+	if string(secret.Reveal()) != "poll-key" {
+		t.Errorf("Reveal returned %q", secret.Reveal())
+	}
+}
+
+func TestPlaintext(t *testing.T) {
+	vote := redact.NewPlaintext([]byte(`"Y"`))
+
+	if got := fmt.Sprintf("%s %q", vote, vote); got != "[REDACTED PLAINTEXT 3 bytes] [REDACTED PLAINTEXT 3 bytes]" {
+		t.Errorf("got %s, expected the placeholder with the size", got)
+	}
+
+	if string(vote.Reveal()) != `"Y"` {
+		t.Errorf("Reveal returned %s", vote.Reveal())
+	}
+}
@@ -0,0 +1,402 @@
+// Package rekor writes the hashes of signed results to a Rekor transparency
+// log of the sigstore project.
+//
+// An entry proves, that the result was published at the time of the entry.
+// Since the log is append only, a result can not be replaced later without
+// leaving a second entry for the same poll in the log.
+//
+// The entries are of the kind hashedrekord. They contain the sha256 hash of
+// the result, its signature and the public key, that signed it. Rekor checks
+// the signature over the hash. The public instance of sigstore supports this
+// for the algorithm ecdsa-p256-sha256, but not for ed25519. Use an own
+// instance or an internal log with the same API for ed25519 keys.
+package rekor
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/merkle"
+)
+
+// maxResponseSize is the maximum size of a response of the log. An entry with
+// its inclusion proof is a few kilobytes.
+const maxResponseSize = 1 << 20
+
+// Entry is an entry of the transparency log with the proof, that it is
+// included in the log.
+type Entry struct {
+	// UUID is the id of the entry in the log.
+	UUID string
+
+	// LogID is the hex encoded sha256 hash of the public key of the log.
+	LogID string
+
+	// LogIndex is the index of the entry in the log.
+	LogIndex int64
+
+	// IntegratedTime is the time, the entry was added to the log, in unix
+	// seconds.
+	IntegratedTime int64
+
+	// Body is the canonical json of the entry. It is the leaf of the merkle
+	// tree of the log.
+	Body []byte
+
+	// SignedEntryTimestamp is the signature of the log over the body, the
+	// integrated time, the log id and the log index.
+	SignedEntryTimestamp []byte
+
+	// TreeIndex is the index of the entry in the merkle tree of the log
+	// shard. It is different from LogIndex, if the log has more than one
+	// shard.
+	TreeIndex int64
+
+	// TreeSize is the size of the merkle tree, when the proof was created.
+	TreeSize int64
+
+	// RootHash is the root of the merkle tree.
+	RootHash []byte
+
+	// Hashes is the inclusion proof from the leaf to the root.
+	Hashes [][]byte
+
+	// Checkpoint is the signed checkpoint of the log for the root.
+	Checkpoint string
+}
+
+// Client writes entries to a Rekor log.
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+// New initializes a client for the log at url, for example
+// https://rekor.sigstore.dev.
+func New(url string) *Client {
+	return &Client{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Upload adds an entry for the content to the log. signature is the signature
+// of the content and pubKey the public key, that created it, in the form of
+// the public main key. See crypto.KeyAlgorithm().
+//
+// If the log already has the entry, the existing entry is returned.
+func (c *Client) Upload(ctx context.Context, content, signature, pubKey []byte) (Entry, error) {
+	body, err := hashedRekord(content, signature, pubKey)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/api/v1/log/entries", bytes.NewReader(body))
+	if err != nil {
+		return Entry{}, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Entry{}, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return decodeEntry(resp.Body)
+
+	case http.StatusConflict:
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return Entry{}, fmt.Errorf("log has the entry, but returned no location")
+		}
+		return c.fetch(ctx, location)
+
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return Entry{}, fmt.Errorf("log returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+}
+
+// fetch reads an existing entry from its location.
+func (c *Client) fetch(ctx context.Context, location string) (Entry, error) {
+	if strings.HasPrefix(location, "/") {
+		location = c.url + location
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return Entry{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Entry{}, fmt.Errorf("fetching existing entry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Entry{}, fmt.Errorf("fetching existing entry: log returned status %d", resp.StatusCode)
+	}
+	return decodeEntry(resp.Body)
+}
+
+// Check reads the size of the log. It can be used to check the connection.
+func (c *Client) Check(ctx context.Context) (treeSize int64, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/api/v1/log", nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("log returned status %d", resp.StatusCode)
+	}
+
+	var info struct {
+		TreeSize int64 `json:"treeSize"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&info); err != nil {
+		return 0, fmt.Errorf("decoding log info: %w", err)
+	}
+	return info.TreeSize, nil
+}
+
+// hashedRekord returns the request body for an entry of the kind
+// hashedrekord.
+func hashedRekord(content, signature, pubKey []byte) ([]byte, error) {
+	publicKeyPEM, err := crypto.EncodePublicMainKey(pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("encoding public key: %w", err)
+	}
+
+	algorithm, err := crypto.KeyAlgorithm(pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("detecting algorithm: %w", err)
+	}
+
+	// Rekor expects ASN.1 encoded ecdsa signatures.
+	if algorithm == crypto.ECDSAP256SHA256 && len(signature) == 64 {
+		signature, err = asn1Signature(signature)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hash := sha256.Sum256(content)
+
+	type object = map[string]any
+	return json.Marshal(object{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": object{
+			"signature": object{
+				"content":   base64.StdEncoding.EncodeToString(signature),
+				"publicKey": object{"content": base64.StdEncoding.EncodeToString(publicKeyPEM)},
+			},
+			"data": object{
+				"hash": object{"algorithm": "sha256", "value": hex.EncodeToString(hash[:])},
+			},
+		},
+	})
+}
+
+// asn1Signature converts a ecdsa signature from the form r || s to ASN.1.
+func asn1Signature(signature []byte) ([]byte, error) {
+	encoded, err := asn1.Marshal(struct{ R, S *big.Int }{
+		R: new(big.Int).SetBytes(signature[:32]),
+		S: new(big.Int).SetBytes(signature[32:]),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding signature: %w", err)
+	}
+	return encoded, nil
+}
+
+// logEntry is an entry in the response of the log.
+type logEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   struct {
+		InclusionProof struct {
+			Checkpoint string   `json:"checkpoint"`
+			Hashes     []string `json:"hashes"`
+			LogIndex   int64    `json:"logIndex"`
+			RootHash   string   `json:"rootHash"`
+			TreeSize   int64    `json:"treeSize"`
+		} `json:"inclusionProof"`
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// decodeEntry decodes a response of the log with one entry.
+func decodeEntry(r io.Reader) (Entry, error) {
+	var entries map[string]logEntry
+	if err := json.NewDecoder(io.LimitReader(r, maxResponseSize)).Decode(&entries); err != nil {
+		return Entry{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	if len(entries) != 1 {
+		return Entry{}, fmt.Errorf("log returned %d entries, expected 1", len(entries))
+	}
+
+	for uuid, raw := range entries {
+		body, err := base64.StdEncoding.DecodeString(raw.Body)
+		if err != nil {
+			return Entry{}, fmt.Errorf("decoding body: %w", err)
+		}
+
+		set, err := base64.StdEncoding.DecodeString(raw.Verification.SignedEntryTimestamp)
+		if err != nil {
+			return Entry{}, fmt.Errorf("decoding signed entry timestamp: %w", err)
+		}
+
+		proof := raw.Verification.InclusionProof
+		rootHash, err := hex.DecodeString(proof.RootHash)
+		if err != nil {
+			return Entry{}, fmt.Errorf("decoding root hash: %w", err)
+		}
+
+		hashes := make([][]byte, len(proof.Hashes))
+		for i, encoded := range proof.Hashes {
+			hashes[i], err = hex.DecodeString(encoded)
+			if err != nil {
+				return Entry{}, fmt.Errorf("decoding hash %d of inclusion proof: %w", i+1, err)
+			}
+		}
+
+		return Entry{
+			UUID:                 uuid,
+			LogID:                raw.LogID,
+			LogIndex:             raw.LogIndex,
+			IntegratedTime:       raw.IntegratedTime,
+			Body:                 body,
+			SignedEntryTimestamp: set,
+			TreeIndex:            proof.LogIndex,
+			TreeSize:             proof.TreeSize,
+			RootHash:             rootHash,
+			Hashes:               hashes,
+			Checkpoint:           proof.Checkpoint,
+		}, nil
+	}
+	return Entry{}, fmt.Errorf("log returned no entry")
+}
+
+// Verify checks, that the entry is for the content and that it is included in
+// the tree with the root hash of the entry.
+//
+// If logPublicKey is not nil, it also checks the signed entry timestamp with
+// this PEM encoded ecdsa key of the log. The key of the public instance can be
+// downloaded from https://rekor.sigstore.dev/api/v1/log/publicKey. Without
+// it, a log could create an entry with a matching proof at any time.
+func Verify(entry Entry, content []byte, logPublicKey []byte) error {
+	var body struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Data struct {
+				Hash struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"hash"`
+			} `json:"data"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(entry.Body, &body); err != nil {
+		return fmt.Errorf("decoding body of entry: %w", err)
+	}
+
+	if body.Kind != "hashedrekord" || body.Spec.Data.Hash.Algorithm != "sha256" {
+		return fmt.Errorf("entry is %s with %s, expected hashedrekord with sha256", body.Kind, body.Spec.Data.Hash.Algorithm)
+	}
+
+	hash := sha256.Sum256(content)
+	if body.Spec.Data.Hash.Value != hex.EncodeToString(hash[:]) {
+		return fmt.Errorf("entry is for a different content")
+	}
+
+	if !merkle.Verify(entry.RootHash, entry.Body, int(entry.TreeIndex), int(entry.TreeSize), entry.Hashes) {
+		return fmt.Errorf("invalid inclusion proof")
+	}
+
+	if logPublicKey == nil {
+		return nil
+	}
+
+	key, err := parseLogKey(logPublicKey)
+	if err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(keyID(key)) != entry.LogID {
+		return fmt.Errorf("entry is from log %s, not from the given key", entry.LogID)
+	}
+
+	// The signed entry timestamp is created over the canonical json of these
+	// fields. json.Marshal sorts the keys of maps.
+	payload, err := json.Marshal(map[string]any{
+		"body":           base64.StdEncoding.EncodeToString(entry.Body),
+		"integratedTime": entry.IntegratedTime,
+		"logID":          entry.LogID,
+		"logIndex":       entry.LogIndex,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding signed payload: %w", err)
+	}
+
+	payloadHash := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(key, payloadHash[:], entry.SignedEntryTimestamp) {
+		return fmt.Errorf("invalid signed entry timestamp")
+	}
+	return nil
+}
+
+// parseLogKey decodes the PEM encoded public key of a log.
+func parseLogKey(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("log key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing log key: %w", err)
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("log key is %T, expected an ecdsa key", key)
+	}
+	return ecdsaKey, nil
+}
+
+// keyID returns the id of a log: the sha256 hash of its DER encoded public
+// key.
+func keyID(key *ecdsa.PublicKey) []byte {
+	der, _ := x509.MarshalPKIXPublicKey(key)
+	hash := sha256.Sum256(der)
+	return hash[:]
+}
@@ -0,0 +1,139 @@
+package rekor_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/merkle"
+	"github.com/OpenSlides/vote-decrypt/rekor"
+)
+
+// fakeLog is a log with two other entries. Each upload is added as third
+// entry. An upload of the same body again returns a conflict.
+func fakeLog(t testing.TB, key *ecdsa.PrivateKey) *httptest.Server {
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	logIDHash := sha256.Sum256(der)
+	logID := hex.EncodeToString(logIDHash[:])
+
+	var entry []byte
+	mux := http.NewServeMux()
+	response := func(w http.ResponseWriter, status int) {
+		leaves := [][]byte{[]byte("first"), []byte("second"), entry}
+		var hashes []string
+		for _, hash := range merkle.Proof(leaves, 2) {
+			hashes = append(hashes, hex.EncodeToString(hash))
+		}
+
+		body := base64.StdEncoding.EncodeToString(entry)
+		payload, _ := json.Marshal(map[string]any{"body": body, "integratedTime": 1700000000, "logID": logID, "logIndex": 12})
+		payloadHash := sha256.Sum256(payload)
+		set, _ := ecdsa.SignASN1(rand.Reader, key, payloadHash[:])
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{
+			"uuid1": map[string]any{
+				"body":           body,
+				"integratedTime": 1700000000,
+				"logID":          logID,
+				"logIndex":       12,
+				"verification": map[string]any{
+					"inclusionProof": map[string]any{
+						"checkpoint": "checkpoint",
+						"hashes":     hashes,
+						"logIndex":   2,
+						"rootHash":   hex.EncodeToString(merkle.Root(leaves)),
+						"treeSize":   3,
+					},
+					"signedEntryTimestamp": base64.StdEncoding.EncodeToString(set),
+				},
+			},
+		})
+	}
+
+	mux.HandleFunc("POST /api/v1/log/entries", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if entry != nil {
+			w.Header().Set("Location", "/api/v1/log/entries/uuid1")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		entry = body
+		response(w, http.StatusCreated)
+	})
+	mux.HandleFunc("GET /api/v1/log/entries/uuid1", func(w http.ResponseWriter, r *http.Request) {
+		response(w, http.StatusOK)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestUpload(t *testing.T) {
+	logKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	srv := fakeLog(t, logKey)
+	defer srv.Close()
+
+	der, _ := x509.MarshalPKIXPublicKey(&logKey.PublicKey)
+	logKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	cr := crypto.New(make([]byte, 32), rand.Reader, nil)
+	content := []byte(`{"id":"1","votes":["Y"]}`)
+	signature, err := cr.Sign(content)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	client := rekor.New(srv.URL)
+	entry, err := client.Upload(context.Background(), content, signature, cr.PublicMainKey())
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if entry.UUID != "uuid1" || entry.LogIndex != 12 || entry.TreeSize != 3 {
+		t.Errorf("got entry %s with index %d in tree of size %d", entry.UUID, entry.LogIndex, entry.TreeSize)
+	}
+
+	if err := rekor.Verify(entry, content, logKeyPEM); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	if err := rekor.Verify(entry, []byte("other"), nil); err == nil {
+		t.Errorf("Verify with other content did not return an error")
+	}
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherDER, _ := x509.MarshalPKIXPublicKey(&otherKey.PublicKey)
+	if err := rekor.Verify(entry, content, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: otherDER})); err == nil {
+		t.Errorf("Verify with the key of another log did not return an error")
+	}
+
+	t.Run("invalid proof", func(t *testing.T) {
+		modified := entry
+		modified.TreeIndex = 1
+		if err := rekor.Verify(modified, content, nil); err == nil {
+			t.Errorf("Verify with a wrong index did not return an error")
+		}
+	})
+
+	t.Run("existing entry", func(t *testing.T) {
+		existing, err := client.Upload(context.Background(), content, signature, cr.PublicMainKey())
+		if err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+
+		if existing.UUID != entry.UUID || string(existing.Body) != string(entry.Body) {
+			t.Errorf("got entry %s, expected the existing entry %s", existing.UUID, entry.UUID)
+		}
+	})
+}
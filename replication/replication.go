@@ -0,0 +1,601 @@
+// Package replication replicates the store of a vote-decrypt instance to a hot
+// standby instance.
+//
+// The primary wraps its store with NewPrimary(). Each write is sent to the
+// standby before it is written to the local store. Only the hash of a stop
+// request is written to the local store first, so a hash, that the primary
+// refuses, never reaches the standby. The standby runs a Server and applies
+// the writes to its own store. The connection uses mutual TLS.
+//
+// The standby runs in read only mode. It is promoted by disabling the read
+// only mode. After that, it refuses all replication requests.
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	decryptgrpc "github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/security"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// timeout is the maximum time for one replication request.
+const timeout = 10 * time.Second
+
+// Primary implements the decrypt.Store interface. It sends all writes to the
+// standby and then writes them to the wrapped store.
+//
+// If the standby is not reachable, the write fails. So each poll key, that was
+// returned to a client, exists on the standby, and each poll, whose result was
+// returned to a client, is stopped on the standby.
+type Primary struct {
+	decrypt.Store
+	client decryptgrpc.ReplicationClient
+}
+
+// NewPrimary initializes a Primary with a connection to the standby.
+func NewPrimary(store decrypt.Store, conn grpc.ClientConnInterface) *Primary {
+	return &Primary{
+		Store:  store,
+		client: decryptgrpc.NewReplicationClient(conn),
+	}
+}
+
+// Dial creates a connection to the standby at addr.
+func Dial(addr string, tlsConfig *tls.Config) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return nil, fmt.Errorf("creating connection to standby: %w", err)
+	}
+	return conn, nil
+}
+
+func (p *Primary) replicate(req *decryptgrpc.ReplicateRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if _, err := p.client.Replicate(ctx, req); err != nil {
+		if status.Code(err) == codes.InvalidArgument {
+			return fmt.Errorf("replicating %s: %s: %w", req.Operation, status.Convert(err).Message(), errorcode.Invalid)
+		}
+		return fmt.Errorf("replicating %s: %w", req.Operation, err)
+	}
+	return nil
+}
+
+// SaveKey saves the key on the standby and in the wrapped store.
+func (p *Primary) SaveKey(id string, key []byte) error {
+	if err := p.replicate(&decryptgrpc.ReplicateRequest{
+		Operation: decryptgrpc.ReplicateRequest_SAVE_KEY,
+		Id:        id,
+		Value:     key,
+	}); err != nil {
+		return err
+	}
+
+	if err := p.Store.SaveKey(id, key); err != nil {
+		// The key was not returned to the client. If the standby kept it, a
+		// retry with a new key would be ignored by the standby.
+		if !errors.Is(err, errorcode.Exist) {
+			p.rollback(id)
+		}
+		return err
+	}
+	return nil
+}
+
+// rollback removes a poll from the standby, after the wrapped store failed to
+// save it.
+func (p *Primary) rollback(id string) {
+	if err := p.replicate(&decryptgrpc.ReplicateRequest{
+		Operation: decryptgrpc.ReplicateRequest_CLEAR_POLL,
+		Id:        id,
+	}); err != nil {
+		log.Printf("Error: removing poll %s from the standby after a failed write: %v", id, err)
+	}
+}
+
+// SaveMeta saves the meta data on the standby and in the wrapped store.
+func (p *Primary) SaveMeta(id string, meta []byte) error {
+	if err := p.replicate(&decryptgrpc.ReplicateRequest{
+		Operation: decryptgrpc.ReplicateRequest_SAVE_META,
+		Id:        id,
+		Value:     meta,
+	}); err != nil {
+		return err
+	}
+	return p.Store.SaveMeta(id, meta)
+}
+
+// ValidateSignature validates the signature in the wrapped store and on the
+// standby.
+//
+// The wrapped store is asked first. If it refuses the hash, the poll was
+// stopped with other votes and the standby is not changed. If the standby
+// fails afterwards, the error is returned, so Stop() does not return the
+// result. A repeated Stop() with the same votes is accepted by the wrapped
+// store again and sends the hash to the standby again.
+func (p *Primary) ValidateSignature(id string, hash []byte) error {
+	if err := p.Store.ValidateSignature(id, hash); err != nil {
+		return err
+	}
+
+	return p.replicate(&decryptgrpc.ReplicateRequest{
+		Operation: decryptgrpc.ReplicateRequest_VALIDATE_SIGNATURE,
+		Id:        id,
+		Value:     hash,
+	})
+}
+
+// LoadHash returns the hash of the stop request from the wrapped store, if it
+// implements decrypt.HashStore.
+func (p *Primary) LoadHash(id string) ([]byte, error) {
+	hashStore, ok := p.Store.(decrypt.HashStore)
+	if !ok {
+		return nil, fmt.Errorf("store can not load hashes: %w", errorcode.Unsupported)
+	}
+	return hashStore.LoadHash(id)
+}
+
+// SaveCommitment saves the commitment on the standby and in the wrapped store.
+func (p *Primary) SaveCommitment(id string, leaves []byte) error {
+	if err := p.replicate(&decryptgrpc.ReplicateRequest{
+		Operation: decryptgrpc.ReplicateRequest_SAVE_COMMITMENT,
+		Id:        id,
+		Value:     leaves,
+	}); err != nil {
+		return err
+	}
+	return p.Store.SaveCommitment(id, leaves)
+}
+
+// ClearPoll removes the poll on the standby and in the wrapped store.
+func (p *Primary) ClearPoll(id string) error {
+	if err := p.replicate(&decryptgrpc.ReplicateRequest{
+		Operation: decryptgrpc.ReplicateRequest_CLEAR_POLL,
+		Id:        id,
+	}); err != nil {
+		return err
+	}
+	return p.Store.ClearPoll(id)
+}
+
+// ScheduleClear schedules the removal on the standby and in the wrapped store.
+func (p *Primary) ScheduleClear(id string, at time.Time) error {
+	if err := p.replicate(&decryptgrpc.ReplicateRequest{
+		Operation: decryptgrpc.ReplicateRequest_SCHEDULE_CLEAR,
+		Id:        id,
+		At:        at.Unix(),
+	}); err != nil {
+		return err
+	}
+	return p.Store.ScheduleClear(id, at)
+}
+
+// SaveReplacementKey saves the replacement key on the standby and in the
+// wrapped store, if it implements decrypt.RekeyStore.
+func (p *Primary) SaveReplacementKey(id string, key []byte) error {
+	rekeyStore, ok := p.Store.(decrypt.RekeyStore)
+	if !ok {
+		return fmt.Errorf("store can not save replacement keys: %w", errorcode.Unsupported)
+	}
+
+	if err := p.replicate(&decryptgrpc.ReplicateRequest{
+		Operation: decryptgrpc.ReplicateRequest_SAVE_REPLACEMENT_KEY,
+		Id:        id,
+		Value:     key,
+	}); err != nil {
+		return err
+	}
+	return rekeyStore.SaveReplacementKey(id, key)
+}
+
+// LoadReplacementKeys returns the replacement keys of the wrapped store.
+func (p *Primary) LoadReplacementKeys(id string) ([][]byte, error) {
+	rekeyStore, ok := p.Store.(decrypt.RekeyStore)
+	if !ok {
+		return nil, nil
+	}
+	return rekeyStore.LoadReplacementKeys(id)
+}
+
+// SaveRevocation saves the revocation on the standby and in the wrapped store,
+// if it implements decrypt.RevocationStore.
+func (p *Primary) SaveRevocation(revocation []byte) error {
+	revocationStore, ok := p.Store.(decrypt.RevocationStore)
+	if !ok {
+		return fmt.Errorf("store can not save revocations: %w", errorcode.Unsupported)
+	}
+
+	if err := p.replicate(&decryptgrpc.ReplicateRequest{
+		Operation: decryptgrpc.ReplicateRequest_SAVE_REVOCATION,
+		Value:     revocation,
+	}); err != nil {
+		return err
+	}
+	return revocationStore.SaveRevocation(revocation)
+}
+
+// Revocations returns the revocations of the wrapped store.
+func (p *Primary) Revocations() ([][]byte, error) {
+	revocationStore, ok := p.Store.(decrypt.RevocationStore)
+	if !ok {
+		return nil, nil
+	}
+	return revocationStore.Revocations()
+}
+
+// Sync sends the keys, the replacement keys, the meta data, the hashes of the
+// stop requests, the commitments and the scheduled removals of all polls and
+// the revocations in the wrapped store to the standby. It should be called,
+// when the primary starts.
+//
+// The wrapped store has to implement decrypt.HashStore. Otherwise, a promoted
+// standby would accept a second stop request with other votes. If the standby
+// has another hash for a poll, Sync fails with errorcode.Invalid.
+func (p *Primary) Sync() error {
+	if _, ok := p.Store.(decrypt.HashStore); !ok {
+		return fmt.Errorf("store can not load the hashes of stopped polls: %w", errorcode.Unsupported)
+	}
+
+	ids, err := p.Store.ListPolls()
+	if err != nil {
+		return fmt.Errorf("listing polls: %w", err)
+	}
+
+	scheduled, err := p.Store.ScheduledClears()
+	if err != nil {
+		return fmt.Errorf("loading scheduled clears: %w", err)
+	}
+
+	for _, id := range ids {
+		key, err := p.Store.LoadKey(id)
+		if err != nil {
+			if errors.Is(err, errorcode.NotExist) {
+				continue
+			}
+			return fmt.Errorf("loading key of poll %s: %w", id, err)
+		}
+
+		if err := p.replicate(&decryptgrpc.ReplicateRequest{
+			Operation: decryptgrpc.ReplicateRequest_SAVE_KEY,
+			Id:        id,
+			Value:     key,
+		}); err != nil {
+			return err
+		}
+
+		replacements, err := p.LoadReplacementKeys(id)
+		if err != nil {
+			return fmt.Errorf("loading replacement keys of poll %s: %w", id, err)
+		}
+
+		for _, replacement := range replacements {
+			if err := p.replicate(&decryptgrpc.ReplicateRequest{
+				Operation: decryptgrpc.ReplicateRequest_SAVE_REPLACEMENT_KEY,
+				Id:        id,
+				Value:     replacement,
+			}); err != nil {
+				return err
+			}
+		}
+
+		meta, err := p.Store.LoadMeta(id)
+		if err != nil && !errors.Is(err, errorcode.NotExist) {
+			return fmt.Errorf("loading meta of poll %s: %w", id, err)
+		}
+
+		if err == nil {
+			if err := p.replicate(&decryptgrpc.ReplicateRequest{
+				Operation: decryptgrpc.ReplicateRequest_SAVE_META,
+				Id:        id,
+				Value:     meta,
+			}); err != nil {
+				return err
+			}
+		}
+
+		hash, err := p.LoadHash(id)
+		if err != nil && !errors.Is(err, errorcode.NotExist) {
+			return fmt.Errorf("loading hash of poll %s: %w", id, err)
+		}
+
+		if err == nil {
+			if err := p.replicate(&decryptgrpc.ReplicateRequest{
+				Operation: decryptgrpc.ReplicateRequest_VALIDATE_SIGNATURE,
+				Id:        id,
+				Value:     hash,
+			}); err != nil {
+				return err
+			}
+		}
+
+		commitment, err := p.Store.LoadCommitment(id)
+		if err != nil && !errors.Is(err, errorcode.NotExist) {
+			return fmt.Errorf("loading commitment of poll %s: %w", id, err)
+		}
+
+		if err == nil {
+			if err := p.replicate(&decryptgrpc.ReplicateRequest{
+				Operation: decryptgrpc.ReplicateRequest_SAVE_COMMITMENT,
+				Id:        id,
+				Value:     commitment,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if at, ok := scheduled[id]; ok {
+			if err := p.replicate(&decryptgrpc.ReplicateRequest{
+				Operation: decryptgrpc.ReplicateRequest_SCHEDULE_CLEAR,
+				Id:        id,
+				At:        at.Unix(),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	revocations, err := p.Revocations()
+	if err != nil {
+		return fmt.Errorf("loading revocations: %w", err)
+	}
+
+	for _, revocation := range revocations {
+		if err := p.replicate(&decryptgrpc.ReplicateRequest{
+			Operation: decryptgrpc.ReplicateRequest_SAVE_REVOCATION,
+			Value:     revocation,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Server is the replication server of the standby. It applies the writes of
+// the primary to its store.
+type Server struct {
+	store   decrypt.Store
+	standby func() bool
+}
+
+// NewServer initializes a Server.
+//
+// standby is called for each request. If it returns false, the instance was
+// promoted and the request is refused. Usually, it is Decrypt.ReadOnly.
+func NewServer(store decrypt.Store, standby func() bool) *Server {
+	return &Server{
+		store:   store,
+		standby: standby,
+	}
+}
+
+// Replicate applies one write of the primary.
+//
+// Writes of keys, replacement keys, meta data, commitments and revocations,
+// that already exist, are ignored, so the primary can retry them.
+func (s *Server) Replicate(ctx context.Context, req *decryptgrpc.ReplicateRequest) (*decryptgrpc.EmptyMessage, error) {
+	if !s.standby() {
+		return nil, status.Error(codes.FailedPrecondition, "instance was promoted and is not a standby")
+	}
+
+	var err error
+	switch req.Operation {
+	case decryptgrpc.ReplicateRequest_SAVE_KEY:
+		err = s.store.SaveKey(req.Id, req.Value)
+		if errors.Is(err, errorcode.Exist) {
+			err = nil
+		}
+
+	case decryptgrpc.ReplicateRequest_SAVE_META:
+		err = s.store.SaveMeta(req.Id, req.Value)
+		if errors.Is(err, errorcode.Exist) {
+			err = nil
+		}
+
+	case decryptgrpc.ReplicateRequest_SAVE_COMMITMENT:
+		err = s.store.SaveCommitment(req.Id, req.Value)
+		if errors.Is(err, errorcode.Exist) {
+			err = nil
+		}
+
+	case decryptgrpc.ReplicateRequest_VALIDATE_SIGNATURE:
+		err = s.store.ValidateSignature(req.Id, req.Value)
+
+	case decryptgrpc.ReplicateRequest_CLEAR_POLL:
+		err = s.store.ClearPoll(req.Id)
+
+	case decryptgrpc.ReplicateRequest_SCHEDULE_CLEAR:
+		err = s.store.ScheduleClear(req.Id, time.Unix(req.At, 0))
+
+	case decryptgrpc.ReplicateRequest_SAVE_REVOCATION:
+		err = s.saveRevocation(req.Value)
+
+	case decryptgrpc.ReplicateRequest_SAVE_REPLACEMENT_KEY:
+		err = s.saveReplacementKey(req.Id, req.Value)
+
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown operation %s", req.Operation)
+	}
+
+	if err != nil {
+		log.Printf("Error: replicating %s of poll %s: %v", req.Operation, req.Id, err)
+		if errors.Is(err, errorcode.Invalid) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "applying write failed")
+	}
+
+	return &decryptgrpc.EmptyMessage{}, nil
+}
+
+// saveReplacementKey saves a replacement key, that the store does not know
+// yet.
+func (s *Server) saveReplacementKey(id string, key []byte) error {
+	rekeyStore, ok := s.store.(decrypt.RekeyStore)
+	if !ok {
+		return fmt.Errorf("store can not save replacement keys: %w", errorcode.Unsupported)
+	}
+
+	keys, err := rekeyStore.LoadReplacementKeys(id)
+	if err != nil {
+		return fmt.Errorf("loading replacement keys: %w", err)
+	}
+
+	for _, existing := range keys {
+		if bytes.Equal(existing, key) {
+			return nil
+		}
+	}
+	return rekeyStore.SaveReplacementKey(id, key)
+}
+
+// saveRevocation saves a revocation, that the store does not know yet.
+func (s *Server) saveRevocation(revocation []byte) error {
+	revocationStore, ok := s.store.(decrypt.RevocationStore)
+	if !ok {
+		return fmt.Errorf("store can not save revocations: %w", errorcode.Unsupported)
+	}
+
+	revocations, err := revocationStore.Revocations()
+	if err != nil {
+		return fmt.Errorf("loading revocations: %w", err)
+	}
+
+	for _, existing := range revocations {
+		if bytes.Equal(existing, revocation) {
+			return nil
+		}
+	}
+	return revocationStore.SaveRevocation(revocation)
+}
+
+// ServerOption for RunServer().
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	securityLog decryptgrpc.SecurityLog
+}
+
+// WithSecurityLog reports connections, that fail the mutual TLS handshake, to
+// the security log.
+func WithSecurityLog(l decryptgrpc.SecurityLog) ServerOption {
+	return func(c *serverConfig) {
+		c.securityLog = l
+	}
+}
+
+// RunServer runs the replication server on addr until ctx is done.
+func RunServer(ctx context.Context, server *Server, addr string, tlsConfig *tls.Config, options ...ServerOption) error {
+	var config serverConfig
+	for _, o := range options {
+		o(&config)
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on address %q: %w", addr, err)
+	}
+
+	creds := credentials.NewTLS(tlsConfig)
+	if config.securityLog != nil {
+		creds = reportingCreds{TransportCredentials: creds, securityLog: config.securityLog}
+	}
+
+	registrar := grpc.NewServer(grpc.Creds(creds))
+	decryptgrpc.RegisterReplicationServer(registrar, server)
+
+	go func() {
+		<-ctx.Done()
+		registrar.GracefulStop()
+	}()
+
+	log.Printf("Running replication server on %s\n", addr)
+	if err := registrar.Serve(lis); err != nil {
+		return fmt.Errorf("running replication server: %w", err)
+	}
+
+	return nil
+}
+
+// reportingCreds are transport credentials, that report failed handshakes of
+// clients to the security log.
+type reportingCreds struct {
+	credentials.TransportCredentials
+	securityLog decryptgrpc.SecurityLog
+}
+
+func (c reportingCreds) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, authInfo, err := c.TransportCredentials.ServerHandshake(rawConn)
+	if err != nil {
+		var source string
+		if addr := rawConn.RemoteAddr(); addr != nil {
+			source, _, _ = net.SplitHostPort(addr.String())
+		}
+
+		c.securityLog.Report(security.Event{
+			Kind:   security.PeerRejected,
+			Source: source,
+			Reason: err.Error(),
+		})
+	}
+	return conn, authInfo, err
+}
+
+// Clone is needed, so grpc does not use the clone of the wrapped credentials
+// without reporting.
+func (c reportingCreds) Clone() credentials.TransportCredentials {
+	return reportingCreds{TransportCredentials: c.TransportCredentials.Clone(), securityLog: c.securityLog}
+}
+
+// TLSConfig creates the configuration for mutual TLS.
+//
+// certFile and keyFile are the certificate and the key of this instance in PEM
+// format. caFile is the certificate of the CA, that signed the certificate of
+// the other instance. If server is true, the config requires a valid client
+// certificate.
+func TLSConfig(certFile, keyFile, caFile string, server bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificate: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading ca file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificate found in %s", caFile)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS13,
+	}
+
+	if server {
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
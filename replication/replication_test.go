@@ -0,0 +1,210 @@
+package replication_test
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	decryptgrpc "github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/replication"
+	"github.com/OpenSlides/vote-decrypt/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestReplication(t *testing.T) {
+	standbyStore := store.New(t.TempDir())
+	var standby atomic.Bool
+	standby.Store(true)
+
+	conn := startServer(t, replication.NewServer(standbyStore, standby.Load))
+	primary := replication.NewPrimary(store.New(t.TempDir()), conn)
+
+	if err := primary.SaveKey("test/1", []byte("secret")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	if err := primary.SaveMeta("test/1", []byte("meta")); err != nil {
+		t.Fatalf("SaveMeta: %v", err)
+	}
+
+	if err := primary.ValidateSignature("test/1", []byte("hash")); err != nil {
+		t.Fatalf("ValidateSignature: %v", err)
+	}
+
+	key, err := standbyStore.LoadKey("test/1")
+	if err != nil {
+		t.Fatalf("LoadKey on standby: %v", err)
+	}
+
+	if string(key) != "secret" {
+		t.Errorf("standby has key %q, expected secret", key)
+	}
+
+	meta, err := standbyStore.LoadMeta("test/1")
+	if err != nil {
+		t.Fatalf("LoadMeta on standby: %v", err)
+	}
+
+	if string(meta) != "meta" {
+		t.Errorf("standby has meta %q, expected meta", meta)
+	}
+
+	t.Run("other signature", func(t *testing.T) {
+		err := primary.ValidateSignature("test/1", []byte("other"))
+		if !errors.Is(err, errorcode.Invalid) {
+			t.Errorf("ValidateSignature returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		local := store.New(t.TempDir())
+		if err := local.SaveKey("test/2", []byte("other secret")); err != nil {
+			t.Fatalf("SaveKey: %v", err)
+		}
+
+		if err := replication.NewPrimary(local, conn).Sync(); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+
+		if _, err := standbyStore.LoadKey("test/2"); err != nil {
+			t.Errorf("LoadKey on standby after sync: %v", err)
+		}
+	})
+
+	t.Run("clear poll", func(t *testing.T) {
+		if err := primary.ClearPoll("test/1"); err != nil {
+			t.Fatalf("ClearPoll: %v", err)
+		}
+
+		if _, err := standbyStore.LoadKey("test/1"); !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("LoadKey on standby returned `%v`, expected `%v`", err, errorcode.NotExist)
+		}
+	})
+
+	t.Run("promoted", func(t *testing.T) {
+		standby.Store(false)
+		defer standby.Store(true)
+
+		if err := primary.SaveKey("test/3", []byte("secret")); err == nil {
+			t.Errorf("SaveKey after promotion did not fail")
+		}
+
+		if _, err := primary.LoadKey("test/3"); !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("key was saved on the primary after the standby refused it: `%v`", err)
+		}
+	})
+}
+
+func startServer(t *testing.T, server *replication.Server) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	registrar := grpc.NewServer()
+	decryptgrpc.RegisterReplicationServer(registrar, server)
+	go registrar.Serve(lis)
+	t.Cleanup(registrar.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("creating connection: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestFailover(t *testing.T) {
+	cr := crypto.New(make([]byte, 32), rand.Reader, nil)
+	standbyStore := store.New(t.TempDir())
+	var standby atomic.Bool
+	standby.Store(true)
+
+	conn := startServer(t, replication.NewServer(standbyStore, standby.Load))
+	primary := decrypt.New(cr, replication.NewPrimary(store.New(t.TempDir()), conn))
+
+	ctx := context.Background()
+	if _, _, err := primary.Start(ctx, "test/1"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	votes := [][]byte{[]byte("vote")}
+	content, _, err := primary.Stop(ctx, "test/1", votes)
+	if err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// The primary fails and the standby is promoted.
+	standby.Store(false)
+	promoted := decrypt.New(cr, standbyStore)
+
+	if _, _, err := promoted.Stop(ctx, "test/1", [][]byte{[]byte("vote"), []byte("other vote")}); err == nil {
+		t.Errorf("Stop with other votes on the promoted standby did not fail")
+	}
+
+	again, _, err := promoted.Stop(ctx, "test/1", votes)
+	if err != nil {
+		t.Fatalf("Stop with the same votes on the promoted standby: %v", err)
+	}
+
+	if string(again) != string(content) {
+		t.Errorf("promoted standby returned %s, primary returned %s", again, content)
+	}
+}
+
+func TestReplicateHash(t *testing.T) {
+	standbyStore := store.New(t.TempDir())
+	conn := startServer(t, replication.NewServer(standbyStore, func() bool { return true }))
+
+	// The local store has a stopped poll, that the new standby does not know.
+	local := store.New(t.TempDir())
+	if err := local.SaveKey("test/1", []byte("secret")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	if err := local.ValidateSignature("test/1", []byte("hash")); err != nil {
+		t.Fatalf("ValidateSignature: %v", err)
+	}
+
+	primary := replication.NewPrimary(local, conn)
+
+	t.Run("refused hash", func(t *testing.T) {
+		if err := standbyStore.SaveKey("test/1", []byte("secret")); err != nil {
+			t.Fatalf("SaveKey on standby: %v", err)
+		}
+		defer standbyStore.ClearPoll("test/1")
+
+		if err := primary.ValidateSignature("test/1", []byte("other")); !errors.Is(err, errorcode.Invalid) {
+			t.Fatalf("ValidateSignature returned `%v`, expected `%v`", err, errorcode.Invalid)
+		}
+
+		if _, err := standbyStore.LoadHash("test/1"); !errors.Is(err, errorcode.NotExist) {
+			t.Errorf("standby got a hash, that the primary refused: `%v`", err)
+		}
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		if err := primary.Sync(); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+
+		hash, err := standbyStore.LoadHash("test/1")
+		if err != nil {
+			t.Fatalf("LoadHash on standby: %v", err)
+		}
+
+		if string(hash) != "hash" {
+			t.Errorf("standby has hash %q, expected hash", hash)
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package roughtime_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/roughtime"
+)
+
+// FuzzVerifyReply checks, that replies from the network do not panic. Run it
+// with `go test ./roughtime -fuzz FuzzVerifyReply`.
+func FuzzVerifyReply(f *testing.F) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	nonce := make([]byte, 64)
+
+	f.Add(signedReply(priv, time.Now(), nonce))
+	f.Add(roughtime.NewRequest(nonce))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, reply []byte) {
+		roughtime.VerifyReply(reply, pub, nonce)
+	})
+}
@@ -0,0 +1,497 @@
+// Package roughtime gets the time from Roughtime servers.
+//
+// Roughtime responses are signed by the server and bound to a random nonce of
+// the request, so the time can not be changed by the network or by a replayed
+// response. The package implements the original protocol by Google, that is
+// supported by all public servers.
+//
+// Clock uses the time of the servers instead of the clock of the host.
+package roughtime
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	nonceSize    = 64
+	requestSize  = 1024
+	maxReplySize = 4096
+)
+
+var (
+	certificateContext = []byte("RoughTime v1 delegation signature--\x00")
+	responseContext    = []byte("RoughTime v1 response signature\x00")
+)
+
+// Tags of the protocol. Each tag is four bytes read as little endian number.
+var (
+	tagCERT = makeTag("CERT")
+	tagDELE = makeTag("DELE")
+	tagINDX = makeTag("INDX")
+	tagMAXT = makeTag("MAXT")
+	tagMIDP = makeTag("MIDP")
+	tagMINT = makeTag("MINT")
+	tagNONC = makeTag("NONC")
+	tagPAD  = makeTag("PAD\xff")
+	tagPATH = makeTag("PATH")
+	tagPUBK = makeTag("PUBK")
+	tagRADI = makeTag("RADI")
+	tagROOT = makeTag("ROOT")
+	tagSIG  = makeTag("SIG\x00")
+	tagSREP = makeTag("SREP")
+)
+
+func makeTag(name string) uint32 {
+	return binary.LittleEndian.Uint32([]byte(name))
+}
+
+// Server is a Roughtime server.
+type Server struct {
+	// Addr is the address of the server in the form HOST:PORT.
+	Addr string
+
+	// PublicKey is the long term ed25519 key of the server.
+	PublicKey ed25519.PublicKey
+}
+
+// ParseServer parses a server in the form HOST:PORT=BASE64_PUBLIC_KEY.
+func ParseServer(value string) (Server, error) {
+	addr, rawKey, ok := strings.Cut(value, "=")
+	if !ok || addr == "" {
+		return Server{}, fmt.Errorf("invalid server %q, expected HOST:PORT=PUBLIC_KEY", value)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(rawKey)
+	if err != nil {
+		return Server{}, fmt.Errorf("decoding public key of %s: %w", addr, err)
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return Server{}, fmt.Errorf("public key of %s has %d bytes, expected %d", addr, len(key), ed25519.PublicKeySize)
+	}
+
+	return Server{Addr: addr, PublicKey: key}, nil
+}
+
+// Query asks the server for the time.
+//
+// It returns the midpoint and the radius of the time interval, the server
+// guarantees. The time needed for the request is not included in the radius.
+func Query(ctx context.Context, server Server) (midpoint time.Time, radius time.Duration, err error) {
+	var nonce [nonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return time.Time{}, 0, fmt.Errorf("creating nonce: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server.Addr)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("connecting to %s: %w", server.Addr, err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return time.Time{}, 0, fmt.Errorf("setting deadline: %w", err)
+	}
+
+	if _, err := conn.Write(NewRequest(nonce[:])); err != nil {
+		return time.Time{}, 0, fmt.Errorf("sending request: %w", err)
+	}
+
+	reply := make([]byte, maxReplySize)
+	n, err := conn.Read(reply)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("reading reply: %w", err)
+	}
+
+	return VerifyReply(reply[:n], server.PublicKey, nonce[:])
+}
+
+// NewRequest returns a request for the nonce. It is padded to 1024 bytes as
+// required by the protocol.
+func NewRequest(nonce []byte) []byte {
+	// The header of a message with two tags has 16 bytes.
+	padding := requestSize - 16 - len(nonce)
+	return encode(map[uint32][]byte{
+		tagNONC: nonce,
+		tagPAD:  make([]byte, padding),
+	})
+}
+
+// VerifyReply checks the signatures of a reply and that it is for the nonce.
+// It returns the midpoint and the radius of the reply.
+func VerifyReply(reply []byte, publicKey ed25519.PublicKey, nonce []byte) (midpoint time.Time, radius time.Duration, err error) {
+	msg, err := decode(reply)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("decoding reply: %w", err)
+	}
+
+	cert, err := decodeField(msg, tagCERT)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	dele, certSig, err := signedField(cert, tagDELE)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("reading certificate: %w", err)
+	}
+
+	if !ed25519.Verify(publicKey, append(bytes.Clone(certificateContext), dele...), certSig) {
+		return time.Time{}, 0, errors.New("invalid signature of the delegation")
+	}
+
+	delegation, err := decode(dele)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("decoding delegation: %w", err)
+	}
+
+	delegatedKey, err := field(delegation, tagPUBK, ed25519.PublicKeySize)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	minTime, err := uint64Field(delegation, tagMINT)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	maxTime, err := uint64Field(delegation, tagMAXT)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	srep, srepSig, err := signedField(msg, tagSREP)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	if !ed25519.Verify(delegatedKey, append(bytes.Clone(responseContext), srep...), srepSig) {
+		return time.Time{}, 0, errors.New("invalid signature of the response")
+	}
+
+	response, err := decode(srep)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("decoding signed response: %w", err)
+	}
+
+	root, err := field(response, tagROOT, sha512.Size)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	midp, err := uint64Field(response, tagMIDP)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	radi, err := field(response, tagRADI, 4)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	index, err := field(msg, tagINDX, 4)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	path, ok := msg[tagPATH]
+	if !ok || len(path)%sha512.Size != 0 {
+		return time.Time{}, 0, errors.New("invalid merkle path")
+	}
+
+	if !bytes.Equal(merkleRoot(nonce, binary.LittleEndian.Uint32(index), path), root) {
+		return time.Time{}, 0, errors.New("reply is not for the nonce of the request")
+	}
+
+	if midp < minTime || midp > maxTime {
+		return time.Time{}, 0, errors.New("time is outside of the validity of the delegation")
+	}
+
+	midpoint = time.UnixMicro(int64(midp)).UTC()
+	radius = time.Duration(binary.LittleEndian.Uint32(radi)) * time.Microsecond
+	return midpoint, radius, nil
+}
+
+// merkleRoot calculates the root of the merkle tree from the nonce and its
+// path.
+func merkleRoot(nonce []byte, index uint32, path []byte) []byte {
+	hash := hashLeaf(nonce)
+	for ; len(path) > 0; path = path[sha512.Size:] {
+		if index&1 == 0 {
+			hash = hashNode(hash, path[:sha512.Size])
+		} else {
+			hash = hashNode(path[:sha512.Size], hash)
+		}
+		index >>= 1
+	}
+	return hash
+}
+
+func hashLeaf(leaf []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte{0})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func hashNode(left, right []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// encode returns the wire format of a message.
+func encode(msg map[uint32][]byte) []byte {
+	tags := make([]uint32, 0, len(msg))
+	for tag := range msg {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(tags)))
+
+	var offset uint32
+	for i, tag := range tags {
+		if i > 0 {
+			binary.Write(&buf, binary.LittleEndian, offset)
+		}
+		offset += uint32(len(msg[tag]))
+	}
+
+	for _, tag := range tags {
+		binary.Write(&buf, binary.LittleEndian, tag)
+	}
+
+	for _, tag := range tags {
+		buf.Write(msg[tag])
+	}
+	return buf.Bytes()
+}
+
+// decode parses the wire format of a message.
+func decode(data []byte) (map[uint32][]byte, error) {
+	if len(data) < 4 || len(data)%4 != 0 {
+		return nil, errors.New("invalid message size")
+	}
+
+	count := binary.LittleEndian.Uint32(data)
+	if count == 0 {
+		return map[uint32][]byte{}, nil
+	}
+
+	headerSize := 4 + 8*uint64(count) - 4
+	if headerSize > uint64(len(data)) {
+		return nil, errors.New("message is too short for its header")
+	}
+
+	offsets := make([]uint32, count+1)
+	for i := uint32(1); i < count; i++ {
+		offsets[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+
+	body := data[headerSize:]
+	offsets[count] = uint32(len(body))
+
+	msg := make(map[uint32][]byte, count)
+	var lastTag uint32
+	for i := uint32(0); i < count; i++ {
+		tag := binary.LittleEndian.Uint32(data[4*count+4*i:])
+		if i > 0 && tag <= lastTag {
+			return nil, errors.New("tags are not sorted")
+		}
+		lastTag = tag
+
+		start, end := offsets[i], offsets[i+1]
+		if start > end || end > uint32(len(body)) || start%4 != 0 {
+			return nil, errors.New("invalid offset")
+		}
+		msg[tag] = body[start:end]
+	}
+	return msg, nil
+}
+
+func field(msg map[uint32][]byte, tag uint32, size int) ([]byte, error) {
+	value, ok := msg[tag]
+	if !ok {
+		return nil, fmt.Errorf("missing tag %s", tagName(tag))
+	}
+
+	if len(value) != size {
+		return nil, fmt.Errorf("tag %s has %d bytes, expected %d", tagName(tag), len(value), size)
+	}
+	return value, nil
+}
+
+func uint64Field(msg map[uint32][]byte, tag uint32) (uint64, error) {
+	value, err := field(msg, tag, 8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(value), nil
+}
+
+func decodeField(msg map[uint32][]byte, tag uint32) (map[uint32][]byte, error) {
+	value, ok := msg[tag]
+	if !ok {
+		return nil, fmt.Errorf("missing tag %s", tagName(tag))
+	}
+
+	decoded, err := decode(value)
+	if err != nil {
+		return nil, fmt.Errorf("decoding tag %s: %w", tagName(tag), err)
+	}
+	return decoded, nil
+}
+
+// signedField returns the value of tag and the signature of the message.
+func signedField(msg map[uint32][]byte, tag uint32) (value []byte, signature []byte, err error) {
+	value, ok := msg[tag]
+	if !ok {
+		return nil, nil, fmt.Errorf("missing tag %s", tagName(tag))
+	}
+
+	signature, err = field(msg, tagSIG, ed25519.SignatureSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, signature, nil
+}
+
+func tagName(tag uint32) string {
+	var name [4]byte
+	binary.LittleEndian.PutUint32(name[:], tag)
+	return strings.TrimRight(string(name[:]), "\x00\xff")
+}
+
+// Clock returns the time of Roughtime servers.
+//
+// After a Sync(), the time is advanced with the monotonic clock of the host.
+// So later changes of the system time have no effect.
+type Clock struct {
+	servers []Server
+
+	mu          sync.Mutex
+	trusted     time.Time
+	synced      time.Time
+	uncertainty time.Duration
+}
+
+// NewClock initializes a clock for the servers. Sync() has to be called
+// before the clock can be used.
+func NewClock(servers []Server) *Clock {
+	return &Clock{servers: servers}
+}
+
+// Sync asks all servers for the time and uses the median.
+//
+// It fails, if no server answered with a valid reply.
+func (c *Clock) Sync(ctx context.Context) error {
+	type sample struct {
+		offset      time.Duration
+		uncertainty time.Duration
+	}
+
+	var samples []sample
+	var errs []error
+	for _, server := range c.servers {
+		start := time.Now()
+		midpoint, radius, err := Query(ctx, server)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", server.Addr, err))
+			continue
+		}
+		roundTrip := time.Since(start)
+
+		// The server created the midpoint at some time during the round trip.
+		local := start.Add(roundTrip / 2)
+		samples = append(samples, sample{
+			offset:      midpoint.Sub(local),
+			uncertainty: radius + roundTrip/2,
+		})
+	}
+
+	if len(samples) == 0 {
+		return fmt.Errorf("no roughtime server answered: %w", errors.Join(errs...))
+	}
+
+	for _, err := range errs {
+		log.Printf("Warning: roughtime server %v", err)
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].offset < samples[j].offset })
+	median := samples[len(samples)/2]
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.synced = now
+	c.trusted = now.Add(median.offset).UTC()
+	c.uncertainty = median.uncertainty
+	return nil
+}
+
+// Now returns the current time.
+//
+// It panics, if Sync() was never successful.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.synced.IsZero() {
+		panic("roughtime clock was not synced")
+	}
+
+	// time.Since uses the monotonic clock.
+	return c.trusted.Add(time.Since(c.synced))
+}
+
+// Uncertainty returns how far Now() can be away from the time of the servers.
+// It is the radius of the reply plus half of the round trip time.
+func (c *Clock) Uncertainty() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.uncertainty
+}
+
+// Run calls Sync() every interval until ctx is done. Errors are logged. The
+// clock keeps the last synced time.
+func (c *Clock) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := c.Sync(ctx); err != nil {
+			log.Printf("Error: syncing roughtime clock: %v", err)
+		}
+	}
+}
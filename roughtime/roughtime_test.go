@@ -0,0 +1,202 @@
+package roughtime_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/roughtime"
+)
+
+func tag(name string) uint32 {
+	return binary.LittleEndian.Uint32([]byte(name))
+}
+
+// encode is the wire format of a message. It is implemented again, so the test
+// does not only check, that the package is compatible with itself.
+func encode(msg map[string][]byte) []byte {
+	names := make([]string, 0, len(msg))
+	for name := range msg {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return tag(names[i]) < tag(names[j]) })
+
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(names)))
+	var offset uint32
+	for i, name := range names {
+		if i > 0 {
+			out = binary.LittleEndian.AppendUint32(out, offset)
+		}
+		offset += uint32(len(msg[name]))
+	}
+	for _, name := range names {
+		out = binary.LittleEndian.AppendUint32(out, tag(name))
+	}
+	for _, name := range names {
+		out = append(out, msg[name]...)
+	}
+	return out
+}
+
+func decodeNonce(t *testing.T, request []byte) []byte {
+	// A request has the tags NONC and PAD\xff. The offset of PAD is the size
+	// of the nonce.
+	if len(request) != 1024 {
+		t.Errorf("request has %d bytes, expected 1024", len(request))
+	}
+	nonceSize := binary.LittleEndian.Uint32(request[4:])
+	return request[16 : 16+nonceSize]
+}
+
+func leaf(nonce []byte) []byte {
+	h := sha512.Sum512(append([]byte{0}, nonce...))
+	return h[:]
+}
+
+func node(left, right []byte) []byte {
+	h := sha512.Sum512(append(append([]byte{1}, left...), right...))
+	return h[:]
+}
+
+// signedReply returns a reply with the time at. The nonce is the right leaf of
+// a tree with two leaves.
+func signedReply(rootKey ed25519.PrivateKey, at time.Time, nonce []byte) []byte {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	dele := encode(map[string][]byte{
+		"PUBK": pub,
+		"MINT": binary.LittleEndian.AppendUint64(nil, 0),
+		"MAXT": binary.LittleEndian.AppendUint64(nil, uint64(at.Add(time.Hour).UnixMicro())),
+	})
+	cert := encode(map[string][]byte{
+		"DELE":    dele,
+		"SIG\x00": ed25519.Sign(rootKey, append([]byte("RoughTime v1 delegation signature--\x00"), dele...)),
+	})
+
+	sibling := leaf([]byte("other"))
+	srep := encode(map[string][]byte{
+		"ROOT": node(sibling, leaf(nonce)),
+		"MIDP": binary.LittleEndian.AppendUint64(nil, uint64(at.UnixMicro())),
+		"RADI": binary.LittleEndian.AppendUint32(nil, 1_000_000),
+	})
+
+	return encode(map[string][]byte{
+		"SIG\x00": ed25519.Sign(priv, append([]byte("RoughTime v1 response signature\x00"), srep...)),
+		"PATH":    sibling,
+		"SREP":    srep,
+		"CERT":    cert,
+		"INDX":    binary.LittleEndian.AppendUint32(nil, 1),
+	})
+}
+
+// fakeServer answers roughtime requests with the time at.
+func fakeServer(t *testing.T, rootKey ed25519.PrivateKey, at time.Time) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			conn.WriteTo(signedReply(rootKey, at, decodeNonce(t, buf[:n])), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestQuery(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	addr := fakeServer(t, priv, at)
+
+	server, err := roughtime.ParseServer(addr + "=" + base64.StdEncoding.EncodeToString(pub))
+	if err != nil {
+		t.Fatalf("ParseServer: %v", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		midpoint, radius, err := roughtime.Query(context.Background(), server)
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+
+		if !midpoint.Equal(at) {
+			t.Errorf("got time %s, expected %s", midpoint, at)
+		}
+
+		if radius != time.Second {
+			t.Errorf("got radius %s, expected 1s", radius)
+		}
+	})
+
+	t.Run("wrong public key", func(t *testing.T) {
+		other, _, _ := ed25519.GenerateKey(rand.Reader)
+		_, _, err := roughtime.Query(context.Background(), roughtime.Server{Addr: addr, PublicKey: other})
+		if err == nil {
+			t.Errorf("Query with the wrong public key did not fail")
+		}
+	})
+}
+
+func TestClock(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	at := time.Now().Add(-24 * time.Hour)
+	addr := fakeServer(t, priv, at)
+
+	clock := roughtime.NewClock([]roughtime.Server{{Addr: addr, PublicKey: pub}})
+	if err := clock.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if diff := clock.Now().Sub(at); diff < 0 || diff > time.Minute {
+		t.Errorf("clock is %s away from the server time", diff)
+	}
+
+	if clock.Uncertainty() < time.Second {
+		t.Errorf("got uncertainty %s, expected at least the radius of 1s", clock.Uncertainty())
+	}
+}
+
+func TestVerifyReplyWrongNonce(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	addr := fakeServer(t, priv, time.Now())
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	nonce := make([]byte, 64)
+	conn.Write(roughtime.NewRequest(nonce))
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	reply := make([]byte, 4096)
+	n, err := conn.Read(reply)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if _, _, err := roughtime.VerifyReply(reply[:n], pub, nonce); err != nil {
+		t.Fatalf("VerifyReply with the right nonce: %v", err)
+	}
+
+	nonce[0] = 1
+	if _, _, err := roughtime.VerifyReply(reply[:n], pub, nonce); err == nil {
+		t.Errorf("VerifyReply with another nonce did not fail")
+	}
+}
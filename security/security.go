@@ -0,0 +1,332 @@
+// Package security writes security events like failed authentications for a
+// SIEM.
+//
+// The events are separate from the operational log and from the audit log.
+// The audit log records, what happened to the polls. The security events
+// record rejected requests, that could be attacks.
+//
+// Each event is written as one line in the Common Event Format (CEF) or in the
+// Elastic Common Schema (ECS).
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/version"
+)
+
+// Kinds of security events.
+const (
+	// AuthFailure is a call of an admin method with a missing or wrong admin
+	// token.
+	AuthFailure = "auth_failure"
+
+	// PeerRejected is a connection to the replication server, that failed the
+	// mutual TLS handshake.
+	PeerRejected = "peer_rejected"
+
+	// SignatureFailure is a request with a missing or invalid signature, for
+	// example a stop request without a valid signature of the stop key.
+	SignatureFailure = "signature_failure"
+
+	// RateLimit is a call, that was rejected by a quota.
+	RateLimit = "rate_limit"
+)
+
+// kinds contains the human readable name, the CEF severity and the ECS
+// category and type of each kind.
+var kinds = map[string]struct {
+	name     string
+	severity int
+	category string
+	typ      string
+}{
+	AuthFailure:      {"Authentication failed", 7, "authentication", "start"},
+	PeerRejected:     {"Peer rejected", 7, "network", "denied"},
+	SignatureFailure: {"Signature verification failed", 8, "intrusion_detection", "denied"},
+	RateLimit:        {"Rate limit exceeded", 5, "network", "denied"},
+}
+
+// Event is one security event.
+type Event struct {
+	Time time.Time
+
+	// Kind is one of the constants like AuthFailure.
+	Kind string
+
+	// Source is the ip address of the caller. It is empty, if it is not
+	// known.
+	Source string
+
+	// Method is the name of the grpc method, for example `Stop`.
+	Method string
+
+	// Reason describes, why the request was rejected.
+	Reason string
+}
+
+// Format of the events.
+type Format string
+
+// Supported formats.
+const (
+	CEF Format = "cef"
+	ECS Format = "ecs"
+)
+
+// ecsVersion is the version of the Elastic Common Schema, that the events
+// follow.
+const ecsVersion = "8.11.0"
+
+// Log writes security events to a writer.
+type Log struct {
+	mu sync.Mutex
+
+	w       io.Writer
+	format  Format
+	now     func() time.Time
+	version string
+}
+
+// Option for New().
+type Option func(*Log)
+
+// WithClock sets the source of the time of the events. Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(l *Log) {
+		l.now = now
+	}
+}
+
+// New initializes a security log, that writes the events in the format to w.
+func New(w io.Writer, format Format, options ...Option) (*Log, error) {
+	if format != CEF && format != ECS {
+		return nil, fmt.Errorf("unknown format `%s`, expected cef or ecs", format)
+	}
+
+	l := &Log{
+		w:       w,
+		format:  format,
+		now:     time.Now,
+		version: version.Get().Version,
+	}
+
+	for _, o := range options {
+		o(l)
+	}
+
+	return l, nil
+}
+
+// Report writes one event. An empty time is set to the current time.
+//
+// A security event must not fail the request, so errors are only logged.
+func (l *Log) Report(event Event) {
+	if event.Time.IsZero() {
+		event.Time = l.now()
+	}
+
+	var line []byte
+	switch l.format {
+	case CEF:
+		line = cefLine(event, l.version)
+	case ECS:
+		line = ecsLine(event, l.version)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		log.Printf("Error: writing security event %s: %v", event.Kind, err)
+	}
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// cefLine returns the event in the Common Event Format.
+func cefLine(event Event, version string) []byte {
+	kind := kinds[event.Kind]
+	name := kind.name
+	if name == "" {
+		name = event.Kind
+	}
+
+	ext := []string{
+		fmt.Sprintf("rt=%d", event.Time.UnixMilli()),
+		"outcome=failure",
+	}
+	if event.Source != "" {
+		ext = append(ext, "src="+cefExtensionEscaper.Replace(event.Source))
+	}
+	if event.Method != "" {
+		ext = append(ext, "cs1Label=method", "cs1="+cefExtensionEscaper.Replace(event.Method))
+	}
+	if event.Reason != "" {
+		ext = append(ext, "msg="+cefExtensionEscaper.Replace(event.Reason))
+	}
+
+	return []byte(fmt.Sprintf(
+		"CEF:0|OpenSlides|vote-decrypt|%s|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(version),
+		cefHeaderEscaper.Replace(event.Kind),
+		cefHeaderEscaper.Replace(name),
+		kind.severity,
+		strings.Join(ext, " "),
+	))
+}
+
+// ecsLine returns the event as json object in the Elastic Common Schema.
+func ecsLine(event Event, version string) []byte {
+	kind := kinds[event.Kind]
+
+	type ecsEvent struct {
+		Kind     string   `json:"kind"`
+		Category []string `json:"category,omitempty"`
+		Type     []string `json:"type,omitempty"`
+		Action   string   `json:"action"`
+		Outcome  string   `json:"outcome"`
+		Severity int      `json:"severity"`
+		Reason   string   `json:"reason,omitempty"`
+	}
+
+	type ecsSource struct {
+		IP string `json:"ip"`
+	}
+
+	type ecsService struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	doc := struct {
+		Timestamp time.Time         `json:"@timestamp"`
+		ECS       map[string]string `json:"ecs"`
+		Event     ecsEvent          `json:"event"`
+		Message   string            `json:"message"`
+		Source    *ecsSource        `json:"source,omitempty"`
+		Service   ecsService        `json:"service"`
+		Labels    map[string]string `json:"labels,omitempty"`
+	}{
+		Timestamp: event.Time.UTC(),
+		ECS:       map[string]string{"version": ecsVersion},
+		Event: ecsEvent{
+			Kind:     "event",
+			Action:   event.Kind,
+			Outcome:  "failure",
+			Severity: kind.severity,
+			Reason:   event.Reason,
+		},
+		Message: kind.name,
+		Service: ecsService{Name: "vote-decrypt", Version: version},
+	}
+
+	if kind.category != "" {
+		doc.Event.Category = []string{kind.category}
+		doc.Event.Type = []string{kind.typ}
+	}
+
+	if doc.Message == "" {
+		doc.Message = event.Kind
+	}
+
+	if event.Source != "" {
+		doc.Source = &ecsSource{IP: event.Source}
+	}
+
+	if event.Method != "" {
+		doc.Labels = map[string]string{"grpc_method": event.Method}
+	}
+
+	line, err := json.Marshal(doc)
+	if err != nil {
+		// All fields are strings, numbers or times, so this can not happen.
+		panic(fmt.Sprintf("encoding security event: %v", err))
+	}
+	return line
+}
+
+// Open returns the writer for a target. The target is udp://HOST:PORT,
+// tcp://HOST:PORT or the path of a file. The file is opened for appending.
+//
+// Over the network, each event is sent in its own write. The connection is
+// opened on the first event and opened again after an error.
+func Open(target string) (io.WriteCloser, error) {
+	if u, err := url.Parse(target); err == nil && (u.Scheme == "udp" || u.Scheme == "tcp") {
+		if u.Host == "" {
+			return nil, fmt.Errorf("security log address `%s` has no host", target)
+		}
+		return &netWriter{network: u.Scheme, addr: u.Host}, nil
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open security log: %w", err)
+	}
+	return file, nil
+}
+
+// netWriter writes to a network connection, that is opened again after an
+// error.
+type netWriter struct {
+	mu sync.Mutex
+
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+func (w *netWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, err := w.write(p)
+	if err != nil {
+		// The server could have closed the connection. Try once with a new
+		// one.
+		return w.write(p)
+	}
+	return n, nil
+}
+
+func (w *netWriter) write(p []byte) (int, error) {
+	if w.conn == nil {
+		conn, err := net.DialTimeout(w.network, w.addr, 5*time.Second)
+		if err != nil {
+			return 0, err
+		}
+		w.conn = conn
+	}
+
+	n, err := w.conn.Write(p)
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	return n, err
+}
+
+func (w *netWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
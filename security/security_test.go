@@ -0,0 +1,95 @@
+package security_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/security"
+)
+
+func TestReport(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	event := security.Event{
+		Kind:   security.AuthFailure,
+		Source: "192.0.2.1",
+		Method: "Wipe",
+		Reason: "invalid admin token",
+	}
+
+	t.Run("cef", func(t *testing.T) {
+		var buf bytes.Buffer
+		l, err := security.New(&buf, security.CEF, security.WithClock(func() time.Time { return at }))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		l.Report(event)
+		l.Report(security.Event{Kind: security.SignatureFailure, Reason: "a=b|c\nd"})
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("got %d lines, expected 2: %s", len(lines), buf.String())
+		}
+
+		if !strings.HasPrefix(lines[0], "CEF:0|OpenSlides|vote-decrypt|") {
+			t.Errorf("line %s has not the CEF header", lines[0])
+		}
+
+		expect := "|auth_failure|Authentication failed|7|rt=1714564800000 outcome=failure src=192.0.2.1 cs1Label=method cs1=Wipe msg=invalid admin token"
+		if !strings.HasSuffix(lines[0], expect) {
+			t.Errorf("got line %s, expected suffix %s", lines[0], expect)
+		}
+
+		if expect := `msg=a\=b|c\nd`; !strings.HasSuffix(lines[1], expect) {
+			t.Errorf("got line %s, expected escaped suffix %s", lines[1], expect)
+		}
+	})
+
+	t.Run("ecs", func(t *testing.T) {
+		var buf bytes.Buffer
+		l, err := security.New(&buf, security.ECS, security.WithClock(func() time.Time { return at }))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		l.Report(event)
+
+		var doc struct {
+			Timestamp time.Time `json:"@timestamp"`
+			Event     struct {
+				Category []string `json:"category"`
+				Action   string   `json:"action"`
+				Outcome  string   `json:"outcome"`
+				Reason   string   `json:"reason"`
+			} `json:"event"`
+			Source struct {
+				IP string `json:"ip"`
+			} `json:"source"`
+			Labels map[string]string `json:"labels"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("decoding %s: %v", buf.Bytes(), err)
+		}
+
+		if !doc.Timestamp.Equal(at) {
+			t.Errorf("got time %s, expected %s", doc.Timestamp, at)
+		}
+
+		if doc.Event.Action != security.AuthFailure || doc.Event.Outcome != "failure" || len(doc.Event.Category) != 1 || doc.Event.Category[0] != "authentication" {
+			t.Errorf("got event %+v, expected a failed authentication", doc.Event)
+		}
+
+		if doc.Source.IP != "192.0.2.1" || doc.Labels["grpc_method"] != "Wipe" || doc.Event.Reason != "invalid admin token" {
+			t.Errorf("got %s, expected source, method and reason of the event", buf.Bytes())
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		if _, err := security.New(new(bytes.Buffer), "leef"); err == nil {
+			t.Errorf("New with unknown format did not return an error")
+		}
+	})
+}
@@ -0,0 +1,614 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/certificate"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	decryptgrpc "github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/loglevel"
+	"github.com/OpenSlides/vote-decrypt/policy"
+	"github.com/OpenSlides/vote-decrypt/redact"
+	"github.com/OpenSlides/vote-decrypt/rekor"
+	"github.com/OpenSlides/vote-decrypt/replication"
+	"github.com/OpenSlides/vote-decrypt/roughtime"
+	"github.com/OpenSlides/vote-decrypt/security"
+	"github.com/OpenSlides/vote-decrypt/spill"
+	"github.com/OpenSlides/vote-decrypt/store"
+)
+
+// checkTimeout is the maximum time for each check, that connects to another
+// service.
+const checkTimeout = 10 * time.Second
+
+// certificateWarning is the time before the expiry of a certificate, from
+// which Check() warns.
+const certificateWarning = 30 * 24 * time.Hour
+
+// Check validates the configuration without starting the server.
+//
+// It does the same as Run() up to the point, where the server would accept
+// requests. It resolves the main key, opens and reads the store, binds and
+// closes the ports of all listeners, loads the TLS material and parses all
+// other values. Each check is written as one line to w, followed by the
+// effective configuration. Secrets are redacted.
+//
+// The store is only read. So Check() can run next to an instance, that uses
+// the same configuration. The ports of the running instance are reported as
+// in use.
+//
+// Returns an error, if at least one check failed.
+func Check(ctx context.Context, config Config, w io.Writer) error {
+	c := checker{w: w}
+
+	var curve ecdh.Curve
+	if config.FIPS {
+		curve = ecdh.P256()
+	}
+
+	var cryptoLib crypto.Crypto
+	var hasMainKey bool
+	c.check("main key", func() (string, error) {
+		var err error
+		cryptoLib, _, err = loadMainKey(ctx, config, rand.Reader, curve)
+		if err != nil {
+			return "", err
+		}
+		hasMainKey = true
+
+		if err := cryptoLib.SelfTest(); err != nil {
+			return "", fmt.Errorf("self-test: %w", err)
+		}
+
+		algorithm, err := cryptoLib.Algorithm()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s from %s, fingerprint %s", algorithm, config.MainKeyBackend, decrypt.Fingerprint(cryptoLib.PublicMainKey())), nil
+	})
+
+	if config.FIPS {
+		c.check("fips mode", func() (string, error) {
+			if hasMainKey {
+				if err := cryptoLib.CheckFIPS(); err != nil {
+					return "", err
+				}
+			}
+
+			if len(config.RoughtimeServers) > 0 {
+				return "", fmt.Errorf("roughtime uses ed25519, that is not allowed")
+			}
+
+			if config.StopKey != "" {
+				return "", fmt.Errorf("the stop key is an ed25519 key, that is not allowed")
+			}
+
+			if !crypto.FIPSModule() {
+				return "without a validated cryptographic module", nil
+			}
+			return "with a validated cryptographic module", nil
+		})
+	}
+
+	c.check("crypto capabilities", func() (string, error) {
+		caps := crypto.Caps()
+		if err := caps.CheckConstantTime(); err != nil {
+			if config.RequireConstantTime {
+				return "", err
+			}
+			return fmt.Sprintf("%s (Warning: %v)", caps.Arch, err), nil
+		}
+		return fmt.Sprintf("%s with hardware aes-256-gcm", caps.Arch), nil
+	})
+
+	if config.MainKeyCertificate != "" {
+		c.check("main key certificate", func() (string, error) {
+			encoded, err := os.ReadFile(config.MainKeyCertificate)
+			if err != nil {
+				return "", err
+			}
+
+			chain, err := certificate.ParsePEM(encoded)
+			if err != nil {
+				return "", err
+			}
+
+			if hasMainKey {
+				if err := certificate.Check(chain, cryptoLib.PublicMainKey()); err != nil {
+					return "", err
+				}
+			}
+
+			return checkCertificates(chain)
+		})
+	}
+
+	if config.EscrowKey != "" {
+		c.check("escrow key", func() (string, error) {
+			escrowKey, err := base64.StdEncoding.DecodeString(config.EscrowKey)
+			if err != nil {
+				return "", fmt.Errorf("decoding: %w", err)
+			}
+
+			sealer, err := crypto.NewSealer(escrowKey, rand.Reader)
+			if err != nil {
+				return "", err
+			}
+
+			if config.FIPS && sealer.Curve() != ecdh.P256() {
+				return "", fmt.Errorf("fips mode needs a P-256 key")
+			}
+			return fmt.Sprintf("%d bytes", len(escrowKey)), nil
+		})
+	}
+
+	if config.StopKey != "" {
+		c.check("stop key", func() (string, error) {
+			stopKey, err := base64.StdEncoding.DecodeString(config.StopKey)
+			if err != nil {
+				return "", fmt.Errorf("decoding: %w", err)
+			}
+
+			if len(stopKey) != ed25519.PublicKeySize {
+				return "", fmt.Errorf("stop key has %d bytes, expected %d", len(stopKey), ed25519.PublicKeySize)
+			}
+			return "ed25519", nil
+		})
+	}
+
+	if config.MeetingKeyFile != "" {
+		c.check("meeting key", func() (string, error) {
+			secret, err := loadMeetingSecret(config.MeetingKeyFile)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d bytes", len(secret)), nil
+		})
+	}
+
+	if config.CoSignKeyFile != "" {
+		c.check("co-signing key", func() (string, error) {
+			key, err := loadCoSigningKey(config.CoSignKeyFile)
+			if err != nil {
+				return "", err
+			}
+			return decrypt.Fingerprint(key.Public().(ed25519.PublicKey)), nil
+		})
+	}
+
+	if len(config.CoSigners) > 0 {
+		c.check("co-signers", func() (string, error) {
+			if config.CoSignQuorum < 0 || config.CoSignQuorum > len(config.CoSigners) {
+				return "", fmt.Errorf("quorum %d is not between 0 and the %d co-signers", config.CoSignQuorum, len(config.CoSigners))
+			}
+
+			for _, addr := range config.CoSigners {
+				conn, err := net.DialTimeout("tcp", addr, checkTimeout)
+				if err != nil {
+					return "", fmt.Errorf("co-signer %s: %w", addr, err)
+				}
+				conn.Close()
+			}
+			return fmt.Sprintf("%d co-signers are reachable", len(config.CoSigners)), nil
+		})
+	}
+
+	c.check("poll ids and formats", func() (string, error) {
+		for _, name := range config.Formats {
+			if _, err := crypto.ParseFormat(name); err != nil {
+				return "", err
+			}
+		}
+
+		for _, name := range config.Experimental {
+			if _, err := decrypt.ParseFeature(name); err != nil {
+				return "", err
+			}
+		}
+
+		if config.IDPattern != "" {
+			if _, err := regexp.Compile(config.IDPattern); err != nil {
+				return "", fmt.Errorf("parsing id pattern: %w", err)
+			}
+		}
+		return "", nil
+	})
+
+	c.check("log level", func() (string, error) {
+		if config.LogLevel == "" {
+			return loglevel.Info.String(), nil
+		}
+
+		level, err := loglevel.ParseLevel(config.LogLevel)
+		if err != nil {
+			return "", err
+		}
+		return level.String(), nil
+	})
+
+	c.check("quotas", func() (string, error) {
+		for _, value := range config.Quota {
+			if _, err := decryptgrpc.ParseQuota(value); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("%d quotas", len(config.Quota)), nil
+	})
+
+	c.check("roles", func() (string, error) {
+		for _, value := range config.Roles {
+			if _, err := decryptgrpc.ParseRoleBinding(value); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("%d roles", len(config.Roles)), nil
+	})
+
+	if len(config.RoughtimeServers) > 0 {
+		c.check("roughtime", func() (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			servers := make([]roughtime.Server, len(config.RoughtimeServers))
+			for i, raw := range config.RoughtimeServers {
+				server, err := roughtime.ParseServer(raw)
+				if err != nil {
+					return "", err
+				}
+				servers[i] = server
+			}
+
+			clock := roughtime.NewClock(servers)
+			if err := clock.Sync(ctx); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("offset to system time %s", clock.Now().Sub(time.Now()).Round(time.Millisecond)), nil
+		})
+	}
+
+	c.check("audit log", func() (string, error) {
+		if (config.AuditSyslog != "" || config.AuditJournald) && config.AuditLog == "" {
+			return "", fmt.Errorf("syslog and journald audit sinks need an audit log file")
+		}
+
+		if config.NoDecryptionInterval > 0 && config.AuditLog == "" {
+			return "", fmt.Errorf("no decryption certificates need an audit log file")
+		}
+
+		if config.AuditLog == "" {
+			return "stdout", nil
+		}
+		return config.AuditLog, checkWritable(config.AuditLog)
+	})
+
+	if config.SecurityLog != "" {
+		c.check("security log", func() (string, error) {
+			if _, err := security.New(io.Discard, security.Format(config.SecurityLogFormat)); err != nil {
+				return "", err
+			}
+
+			if strings.HasPrefix(config.SecurityLog, "udp://") || strings.HasPrefix(config.SecurityLog, "tcp://") {
+				return config.SecurityLog, nil
+			}
+			return config.SecurityLog, checkWritable(config.SecurityLog)
+		})
+	}
+
+	if config.CrashDir != "" || config.CrashKey != "" {
+		c.check("crash reports", func() (string, error) {
+			if _, _, err := newCrashHandler(config); err != nil {
+				return "", err
+			}
+
+			f, err := os.CreateTemp(config.CrashDir, ".check-*")
+			if err != nil {
+				return "", err
+			}
+			f.Close()
+			return config.CrashDir, os.Remove(f.Name())
+		})
+	}
+
+	if config.SpillSize > 0 {
+		c.check("spill files", func() (string, error) {
+			f, err := spill.New(config.SpillDir)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("results bigger then %d bytes", config.SpillSize), f.Close()
+		})
+	}
+
+	if config.PolicyURL != "" {
+		c.check("policy", func() (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			decision, err := policy.NewOPA(config.PolicyURL).Allow(ctx, policy.Input{Method: "check-config", Time: time.Now()})
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("reachable, check-config allowed: %t", decision.Allow), nil
+		})
+	}
+
+	if config.RekorURL != "" {
+		c.check("transparency log", func() (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			treeSize, err := rekor.New(config.RekorURL).Check(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("reachable, %d entries", treeSize), nil
+		})
+	}
+
+	c.check("alerts", func() (string, error) {
+		alerter, err := newAlerter(config)
+		if err != nil {
+			return "", err
+		}
+
+		if alerter == nil {
+			return "disabled", nil
+		}
+		return "enabled", nil
+	})
+
+	c.check("store", func() (string, error) {
+		backend, err := config.OpenStore()
+		if err != nil {
+			return "", err
+		}
+
+		if _, ok := backend.(store.Compacter); config.GCInterval > 0 && !ok {
+			return "", fmt.Errorf("the store backend does not support garbage collection")
+		}
+
+		polls, err := backend.ListPolls()
+		if err != nil {
+			return "", fmt.Errorf("listing polls: %w", err)
+		}
+		return fmt.Sprintf("%s backend with %d polls", config.StoreBackend, len(polls)), nil
+	})
+
+	if config.Standby || config.ReplicaAddr != "" {
+		c.check("replication", func() (string, error) {
+			if config.Standby && config.ReplicaAddr != "" {
+				return "", fmt.Errorf("an instance can not be a standby and replicate to a standby")
+			}
+
+			if _, err := replication.TLSConfig(config.TLSCert, config.TLSKey, config.TLSCA, config.Standby); err != nil {
+				return "", err
+			}
+
+			cert, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+			if err != nil {
+				return "", err
+			}
+
+			detail, err := checkCertificates(cert.Certificate)
+			if err != nil || config.Standby {
+				return detail, err
+			}
+
+			conn, err := net.DialTimeout("tcp", config.ReplicaAddr, checkTimeout)
+			if err != nil {
+				return "", fmt.Errorf("connecting to standby: %w", err)
+			}
+			conn.Close()
+			return detail + ", standby " + config.ReplicaAddr + " is reachable", nil
+		})
+	}
+
+	if config.LeaderLease != "" {
+		c.check("leader election", func() (string, error) {
+			if config.Standby || config.ReplicaAddr != "" {
+				return "", fmt.Errorf("leader election can not be used with replication")
+			}
+
+			if config.LeaderAddr == "" {
+				return "", fmt.Errorf("leader election needs the address of this instance")
+			}
+
+			if _, err := os.Stat(filepath.Dir(config.LeaderLease)); err != nil {
+				return "", fmt.Errorf("directory of the lease file: %w", err)
+			}
+			return config.LeaderLease, nil
+		})
+	}
+
+	c.check("listeners", func() (string, error) {
+		port := config.Port
+		if port == 0 {
+			port = 9014
+		}
+
+		ports := []int{port}
+		if config.MetricsPort > 0 {
+			ports = append(ports, config.MetricsPort)
+		} else if config.PollMetrics {
+			return "", fmt.Errorf("per poll metrics need the metrics port")
+		}
+		if config.Standby {
+			ports = append(ports, config.ReplicationPort)
+		}
+
+		addrs := make([]string, len(ports))
+		for i, port := range ports {
+			addrs[i] = fmt.Sprintf(":%d", port)
+			lis, err := net.Listen("tcp", addrs[i])
+			if err != nil {
+				return "", err
+			}
+			lis.Close()
+		}
+		return strings.Join(addrs, ", "), nil
+	})
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Effective configuration:")
+	writeConfig(w, config)
+
+	if c.failed > 0 {
+		return fmt.Errorf("%d checks failed", c.failed)
+	}
+	return nil
+}
+
+// checker runs the checks of Check() and writes the results.
+type checker struct {
+	w      io.Writer
+	failed int
+}
+
+func (c *checker) check(name string, fn func() (string, error)) {
+	detail, err := fn()
+	if err != nil {
+		c.failed++
+		fmt.Fprintf(c.w, "FAIL  %s: %v\n", name, err)
+		return
+	}
+
+	if detail == "" {
+		fmt.Fprintf(c.w, "ok    %s\n", name)
+		return
+	}
+	fmt.Fprintf(c.w, "ok    %s: %s\n", name, detail)
+}
+
+// checkCertificates checks, that the certificates in DER form are valid now.
+// It returns the expiry of the first certificate with a warning, if it expires
+// soon.
+func checkCertificates(chain [][]byte) (string, error) {
+	now := time.Now()
+	var detail string
+	for i, der := range chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return "", fmt.Errorf("parsing certificate %d: %w", i+1, err)
+		}
+
+		if now.Before(cert.NotBefore) {
+			return "", fmt.Errorf("certificate %s is valid from %s", cert.Subject, cert.NotBefore.Format(time.RFC3339))
+		}
+
+		if now.After(cert.NotAfter) {
+			return "", fmt.Errorf("certificate %s expired at %s", cert.Subject, cert.NotAfter.Format(time.RFC3339))
+		}
+
+		if i == 0 {
+			detail = fmt.Sprintf("%s valid until %s", cert.Subject, cert.NotAfter.Format(time.RFC3339))
+			if cert.NotAfter.Sub(now) < certificateWarning {
+				detail += " (Warning: expires soon)"
+			}
+		}
+	}
+	return detail, nil
+}
+
+// checkWritable checks, that a file can be written, without creating it.
+func checkWritable(name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err == nil {
+		return f.Close()
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	info, err := os.Stat(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", filepath.Dir(name))
+	}
+	return nil
+}
+
+// writeConfig writes all fields of the config, that are not zero, with the
+// name of their environment variable or flag.
+func writeConfig(w io.Writer, config Config) {
+	configValues(reflect.ValueOf(config), func(name, value string) {
+		fmt.Fprintf(w, "  %s=%s\n", name, value)
+	})
+}
+
+// configValues calls fn for each field of the struct v, that is not zero,
+// with the name of its environment variable or flag. Fields with the tag
+// `secret` are redacted.
+func configValues(v reflect.Value, fn func(name, value string)) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+
+		if field.Anonymous {
+			configValues(value, fn)
+			continue
+		}
+
+		if value.IsZero() {
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			name = "--" + flagName(field)
+		}
+
+		var printed any = value.Interface()
+		if f, ok := printed.(*os.File); ok {
+			printed = f.Name()
+		}
+		if _, ok := field.Tag.Lookup("secret"); ok {
+			printed = redact.NewSecret(printed)
+		}
+
+		fn(name, fmt.Sprint(printed))
+	}
+}
+
+// flagName returns the name of the command line flag of a field like kong.
+func flagName(field reflect.StructField) string {
+	if name := field.Tag.Get("name"); name != "" {
+		return name
+	}
+
+	var b strings.Builder
+	runes := []rune(field.Name)
+	for i, r := range runes {
+		lower := strings.ToLower(string(r))
+		upper := lower != string(r)
+		if upper && i > 0 && (!isUpper(runes[i-1]) || (i+1 < len(runes) && !isUpper(runes[i+1]))) {
+			b.WriteByte('-')
+		}
+		b.WriteString(lower)
+	}
+	return b.String()
+}
+
+func isUpper(r rune) bool {
+	return strings.ToLower(string(r)) != string(r)
+}
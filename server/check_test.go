@@ -0,0 +1,104 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/server"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "main.key")
+	if err := os.WriteFile(keyFile, make([]byte, 32), 0o600); err != nil {
+		t.Fatalf("writing main key: %v", err)
+	}
+
+	config := func() server.Config {
+		mainKey, err := os.Open(keyFile)
+		if err != nil {
+			t.Fatalf("opening main key: %v", err)
+		}
+		t.Cleanup(func() { mainKey.Close() })
+
+		return server.Config{
+			MainKey:     mainKey,
+			Port:        freePort(t),
+			AdminToken:  "admin-secret",
+			StoreConfig: server.StoreConfig{Store: filepath.Join(dir, "store")},
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := server.Check(context.Background(), config(), &buf); err != nil {
+			t.Fatalf("Check: %v\n%s", err, buf.String())
+		}
+
+		out := buf.String()
+		if strings.Contains(out, "FAIL") {
+			t.Errorf("output contains a failed check:\n%s", out)
+		}
+
+		if strings.Contains(out, "admin-secret") {
+			t.Errorf("output contains the admin token:\n%s", out)
+		}
+
+		if !strings.Contains(out, "VOTE_DECRYPT_ADMIN_TOKEN=[REDACTED SECRET]") {
+			t.Errorf("output does not contain the redacted admin token:\n%s", out)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "store")); err == nil {
+			t.Errorf("Check created the store")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		c := config()
+		c.Quota = []string{"Start"}
+		c.StopKey = "abc"
+		c.CoSignKeyFile = filepath.Join(dir, "missing.key")
+		c.CoSigners = []string{"localhost:9014"}
+		c.CoSignQuorum = 2
+		c.CrashKey = "abc"
+
+		var buf bytes.Buffer
+		err := server.Check(context.Background(), c, &buf)
+		if err == nil {
+			t.Fatalf("Check returned no error")
+		}
+
+		out := buf.String()
+		for _, name := range []string{"FAIL  quotas", "FAIL  stop key", "FAIL  co-signing key", "FAIL  co-signers", "FAIL  crash reports", "ok    main key"} {
+			if !strings.Contains(out, name) {
+				t.Errorf("output does not contain %q:\n%s", name, out)
+			}
+		}
+	})
+
+	t.Run("port in use", func(t *testing.T) {
+		lis, err := net.Listen("tcp", ":0")
+		if err != nil {
+			t.Fatalf("listening: %v", err)
+		}
+		defer lis.Close()
+
+		c := config()
+		c.Port = lis.Addr().(*net.TCPAddr).Port
+
+		var buf bytes.Buffer
+		if err := server.Check(context.Background(), c, &buf); err == nil {
+			t.Fatalf("Check returned no error")
+		}
+
+		if want := fmt.Sprintf("FAIL  listeners: listen tcp :%d", c.Port); !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	})
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/store"
+	"github.com/OpenSlides/vote-decrypt/store/integrity"
+	"github.com/OpenSlides/vote-decrypt/store/split"
+	"github.com/OpenSlides/vote-decrypt/store/vault"
+)
+
+// Config is the configuration of the server.
+//
+// The struct tags are used by the command line parser of vote-decrypt. The
+// defaults in the tags are only used by the command line parser. The zero
+// value of each field disables the feature or uses the default.
+type Config struct {
+	MainKey *os.File `arg:"" optional:"" help:"Path to the main key file. Not needed, if the main key is in a key management service."`
+
+	MainKeyBackend string `help:"Where the main key is. A local file, a file sealed to the TPM or a key management service." enum:"file,tpm,aws-kms,gcp-kms" env:"VOTE_DECRYPT_MAIN_KEY_BACKEND" default:"file"`
+	KMSKey         string `help:"Id of the key in AWS KMS or resource name of the key version in Google Cloud KMS." env:"VOTE_DECRYPT_KMS_KEY"`
+	AWSRegion      string `help:"AWS region of the KMS key." env:"AWS_REGION"`
+	TPMDevice      string `help:"Path of the TPM device. Defaults to /dev/tpmrm0 or /dev/tpm0." env:"VOTE_DECRYPT_TPM_DEVICE"`
+
+	FIPS bool `help:"Only use algorithms, that are approved by FIPS 140-3. Poll keys use P-256 and the main key is an ECDSA P-256 key." name:"fips" env:"VOTE_DECRYPT_FIPS"`
+
+	RequireConstantTime bool `help:"Refuse to start, if aes-256-gcm is not constant-time, because the processor has no AES or carry-less multiplication instructions." env:"VOTE_DECRYPT_REQUIRE_CONSTANT_TIME"`
+
+	MainKeyCertificate string `help:"Path of a PEM file with the X.509 certificate of the main key and its intermediate certificates. It is sent with the public main key and the results." env:"VOTE_DECRYPT_MAIN_KEY_CERTIFICATE"`
+
+	Attestation    bool  `help:"Enable remote attestation with the TPM." env:"VOTE_DECRYPT_ATTESTATION"`
+	AttestationPCR []int `help:"PCRs from the SHA256 bank to include in the attestation. Defaults to 7 (secure boot state)." name:"attestation-pcr" env:"VOTE_DECRYPT_ATTESTATION_PCRS"`
+
+	Port int `help:"Port for the server. Defaults to 9014." short:"p" env:"VOTE_DECRYPT_PORT" default:"9014"`
+
+	StoreConfig `embed:""`
+
+	MaxVotes         int    `help:"Maximum number of votes per poll. 0 means no limit." env:"VOTE_DECRYPT_MAX_VOTES" default:"0"`
+	MaxVoteSize      int    `help:"Maximum size of one encrypted vote in bytes. 0 means no limit." env:"VOTE_DECRYPT_MAX_VOTE_SIZE" default:"0"`
+	MaxPollSize      int    `help:"Maximum size of all encrypted votes of a poll in bytes. 0 means no limit." env:"VOTE_DECRYPT_MAX_POLL_SIZE" default:"0"`
+	MaxPlaintextSize int    `help:"Maximum size of one decrypted vote in bytes. 0 means no limit." env:"VOTE_DECRYPT_MAX_PLAINTEXT_SIZE" default:"0"`
+	OversizePolicy   string `help:"What happens with bigger decrypted votes. fail stops the poll, invalid reports the vote as invalid." enum:"fail,invalid" env:"VOTE_DECRYPT_OVERSIZE_POLICY" default:"fail"`
+
+	DecryptWorkers   int   `help:"Maximum number of goroutines, that decrypt the votes of one poll. 0 means one per CPU." env:"VOTE_DECRYPT_DECRYPT_WORKERS" default:"0"`
+	DecryptAutoTune  bool  `help:"Tune the number of decrypt workers and the votes per job with the measured time to decrypt a vote." env:"VOTE_DECRYPT_DECRYPT_AUTOTUNE" default:"true" negatable:""`
+	MaxParallelStops int   `help:"Maximum number of polls, that are decrypted at the same time. Further stop requests wait. 0 means no limit." env:"VOTE_DECRYPT_MAX_PARALLEL_STOPS" default:"0"`
+	MemoryLimit      int64 `help:"Memory ceiling of the process in bytes. Sets the soft memory limit of the go runtime. Stop requests wait, when the estimated memory of all running stops would exceed three quarters of it. 0 means no limit." env:"VOTE_DECRYPT_MEMORY_LIMIT" default:"0"`
+
+	SpillSize int    `help:"Results of stop many, that are bigger then this number of bytes, are written to encrypted temporary files until they are sent. This does not lower the memory of a single poll. 0 keeps all results in memory." env:"VOTE_DECRYPT_SPILL_SIZE" default:"0"`
+	SpillDir  string `help:"Directory for the encrypted temporary files of big results. Defaults to the directory for temporary files." env:"VOTE_DECRYPT_SPILL_DIR" type:"path"`
+
+	Formats []string `help:"Accepted formats of encrypted votes (legacy, v1, v2). Votes in other formats are reported as invalid. Defaults to all formats." env:"VOTE_DECRYPT_FORMATS"`
+
+	IDPattern    string   `help:"Regular expression, that all poll ids have to match." env:"VOTE_DECRYPT_ID_PATTERN"`
+	IDMaxLength  int      `help:"Maximum length of a poll id in bytes. 0 means no limit." env:"VOTE_DECRYPT_ID_MAX_LENGTH" default:"0"`
+	IDNamespaces []string `help:"Allowed namespaces of poll ids. The namespace is the part before the first slash. Defaults to all namespaces." env:"VOTE_DECRYPT_ID_NAMESPACES"`
+
+	AdminToken string `help:"Token to call admin methods like wipe. If empty, admin methods are disabled." env:"VOTE_DECRYPT_ADMIN_TOKEN" secret:""`
+	EscrowKey  string `help:"Base64 encoded x25519 or P-256 public key of the auditor. Enables the export of poll keys and seals the order seed in the audit log." env:"VOTE_DECRYPT_ESCROW_KEY"`
+	StopKey    string `help:"Base64 encoded ed25519 public key of the vote service. If set, stop requests have to be signed with the private key." env:"VOTE_DECRYPT_STOP_KEY"`
+	AuditLog   string `help:"Path of the audit log file. If empty, audit events are written to stdout." env:"VOTE_DECRYPT_AUDIT_LOG"`
+
+	AuditSyslog   string `help:"Syslog server in the form udp://HOST:PORT, tcp://HOST:PORT or unix:///PATH. If set, the events of the audit log file are also sent to it." env:"VOTE_DECRYPT_AUDIT_SYSLOG"`
+	AuditJournald bool   `help:"Also send the events of the audit log file to the systemd journal." env:"VOTE_DECRYPT_AUDIT_JOURNALD"`
+
+	SecurityLog       string `help:"File or udp://HOST:PORT or tcp://HOST:PORT for security events like failed authentications. If empty, no security events are written." env:"VOTE_DECRYPT_SECURITY_LOG"`
+	SecurityLogFormat string `help:"Format of the security events." enum:"cef,ecs" env:"VOTE_DECRYPT_SECURITY_LOG_FORMAT" default:"ecs"`
+
+	CoreDumps bool   `help:"Allow core dumps of the process. They contain the main key and the poll keys." env:"VOTE_DECRYPT_CORE_DUMPS"`
+	CrashDir  string `help:"Directory for crash reports. If empty, crash reports are written to the log." env:"VOTE_DECRYPT_CRASH_DIR"`
+	CrashKey  string `help:"Base64 encoded x25519 or P-256 public key. If set, the crash reports in the crash directory are encrypted to this key." env:"VOTE_DECRYPT_CRASH_KEY"`
+
+	Commitment bool `help:"Add the root of a merkle tree over the tracking codes of all votes to the result and serve inclusion proofs." env:"VOTE_DECRYPT_COMMITMENT"`
+
+	PollSigningKeys bool   `help:"Sign public poll keys and results with a key derived for each poll. The main key signs the delegation of the poll signing keys." env:"VOTE_DECRYPT_POLL_SIGNING_KEYS"`
+	MeetingKeyFile  string `help:"File with a secret of at least 32 random bytes. If set, the polls of a meeting are signed with a key derived for the meeting. The meeting is the part of the poll id before the first /." env:"VOTE_DECRYPT_MEETING_KEY_FILE"`
+
+	Experimental []string `help:"Experimental features to enable (trustee). Polls, that need a disabled feature, are refused." env:"VOTE_DECRYPT_EXPERIMENTAL"`
+
+	TSAURL   string `help:"URL of a RFC 3161 time stamping authority. If set, the response of stop contains a timestamp token for the signature." name:"tsa-url" env:"VOTE_DECRYPT_TSA_URL"`
+	RekorURL string `help:"URL of a Rekor transparency log, for example https://rekor.sigstore.dev. If set, the hash of each result is added to the log." env:"VOTE_DECRYPT_REKOR_URL"`
+
+	ResultStoreEndpoint string        `help:"URL of a S3 compatible object storage. If set, stop requests can ask to upload the result instead of sending it in the response." env:"VOTE_DECRYPT_RESULT_STORE_ENDPOINT"`
+	ResultStoreBucket   string        `help:"Bucket for the results. If empty, the endpoint has to be the url of the bucket." env:"VOTE_DECRYPT_RESULT_STORE_BUCKET"`
+	ResultStoreRegion   string        `help:"Region of the bucket." env:"VOTE_DECRYPT_RESULT_STORE_REGION" default:"us-east-1"`
+	ResultStorePrefix   string        `help:"Prefix for the names of the result objects." env:"VOTE_DECRYPT_RESULT_STORE_PREFIX"`
+	ResultURLExpiry     time.Duration `help:"Validity of the urls of uploaded results. At most 7 days." name:"result-url-expiry" env:"VOTE_DECRYPT_RESULT_URL_EXPIRY" default:"1h"`
+
+	KafkaBrokers []string `help:"Kafka brokers as HOST:PORT. If set, the result of each stopped poll is published to a kafka topic." env:"VOTE_DECRYPT_KAFKA_BROKERS"`
+	KafkaTopic   string   `help:"Kafka topic for the results." env:"VOTE_DECRYPT_KAFKA_TOPIC" default:"vote-decrypt-results"`
+	KafkaPerVote bool     `help:"Publish each decrypted vote as its own record instead of one record with the result." env:"VOTE_DECRYPT_KAFKA_PER_VOTE"`
+
+	KafkaTransactionalID string `help:"Transactional id of the kafka producer. Only one instance should use the same id." env:"VOTE_DECRYPT_KAFKA_TRANSACTIONAL_ID" default:"vote-decrypt"`
+	KafkaTLS             bool   `help:"Use tls for the connections to the kafka brokers." name:"kafka-tls" env:"VOTE_DECRYPT_KAFKA_TLS"`
+	KafkaTLSCA           string `help:"Path of the ca certificate of the kafka brokers. If empty, the system certificates are used." name:"kafka-tls-ca" env:"VOTE_DECRYPT_KAFKA_TLS_CA"`
+	KafkaSASLMechanism   string `help:"SASL mechanism to authenticate at the kafka brokers." name:"kafka-sasl-mechanism" enum:"none,plain,scram-sha-256,scram-sha-512" env:"VOTE_DECRYPT_KAFKA_SASL_MECHANISM" default:"none"`
+	KafkaSASLUser        string `help:"User for the sasl authentication." name:"kafka-sasl-user" env:"VOTE_DECRYPT_KAFKA_SASL_USER"`
+	KafkaSASLPassword    string `help:"Password for the sasl authentication." name:"kafka-sasl-password" env:"VOTE_DECRYPT_KAFKA_SASL_PASSWORD" secret:""`
+
+	RoughtimeServers  []string      `help:"Roughtime servers in the form HOST:PORT=BASE64_PUBLIC_KEY. If set, their time is used for not before policies and the audit log instead of the system time." env:"VOTE_DECRYPT_ROUGHTIME_SERVERS"`
+	RoughtimeInterval time.Duration `help:"Interval for syncing the time with the roughtime servers. 0 means only at start." env:"VOTE_DECRYPT_ROUGHTIME_INTERVAL" default:"1h"`
+
+	DeletionDelay time.Duration `help:"Delay for removing the data of a cleared poll. 0 means immediately." env:"VOTE_DECRYPT_DELETION_DELAY" default:"0"`
+	ReadOnly      bool          `help:"Start in read only mode. No polls can be started, stopped or cleared." env:"VOTE_DECRYPT_READ_ONLY"`
+
+	NoDecryptionInterval time.Duration `help:"Interval for writing signed certificates, that no votes of the running polls were decrypted, to the audit log. 0 means never." env:"VOTE_DECRYPT_NO_DECRYPTION_INTERVAL" default:"0"`
+	GCInterval           time.Duration `help:"Interval for removing the leftovers of removed polls from the store. 0 means never." env:"VOTE_DECRYPT_GC_INTERVAL" default:"0"`
+
+	StoreBreakerThreshold int           `help:"Number of store calls in a row, that have to fail, before new polls are rejected. 0 means never." env:"VOTE_DECRYPT_STORE_BREAKER_THRESHOLD" default:"0"`
+	StoreBreakerCooldown  time.Duration `help:"Interval for probing the store, while new polls are rejected." env:"VOTE_DECRYPT_STORE_BREAKER_COOLDOWN" default:"10s"`
+
+	AlertInvalidPercent float64       `help:"Send an alert, if more then this percentage of the votes of a poll are invalid. 0 means never." env:"VOTE_DECRYPT_ALERT_INVALID_PERCENT" default:"0"`
+	AlertStopDuration   time.Duration `help:"Send an alert, if decrypting the votes of a poll takes longer. 0 means never." env:"VOTE_DECRYPT_ALERT_STOP_DURATION" default:"0"`
+	AlertStoreErrors    int           `help:"Send an alert, if this number of store calls in a row failed. Needs the store circuit breaker. 0 means never." env:"VOTE_DECRYPT_ALERT_STORE_ERRORS" default:"0"`
+	AlertWebhook        string        `help:"URL, that receives the alerts as json in a POST request." env:"VOTE_DECRYPT_ALERT_WEBHOOK" secret:""`
+	AlertSMTPAddr       string        `help:"Mail server for alerts as HOST:PORT." name:"alert-smtp-addr" env:"VOTE_DECRYPT_ALERT_SMTP_ADDR"`
+	AlertSMTPFrom       string        `help:"Sender of alert emails." name:"alert-smtp-from" env:"VOTE_DECRYPT_ALERT_SMTP_FROM"`
+	AlertSMTPTo         []string      `help:"Recipients of alert emails." name:"alert-smtp-to" env:"VOTE_DECRYPT_ALERT_SMTP_TO"`
+	AlertSMTPUsername   string        `help:"Username for the mail server." name:"alert-smtp-username" env:"VOTE_DECRYPT_ALERT_SMTP_USERNAME"`
+	AlertSMTPPassword   string        `help:"Password for the mail server." name:"alert-smtp-password" env:"VOTE_DECRYPT_ALERT_SMTP_PASSWORD" secret:""`
+
+	Standby         bool   `help:"Run as hot standby. Starts in read only mode and receives the writes of the primary until it is promoted." env:"VOTE_DECRYPT_STANDBY"`
+	ReplicationPort int    `help:"Port for the replication server of the standby." env:"VOTE_DECRYPT_REPLICATION_PORT" default:"9015"`
+	ReplicaAddr     string `help:"Address of the replication server of the standby. If set, all writes are sent to the standby." env:"VOTE_DECRYPT_REPLICA_ADDR"`
+	TLSCert         string `help:"Path of the tls certificate for replication." env:"VOTE_DECRYPT_TLS_CERT"`
+	TLSKey          string `help:"Path of the tls key for replication." env:"VOTE_DECRYPT_TLS_KEY"`
+	TLSCA           string `help:"Path of the ca certificate, that signed the certificate of the other instance." env:"VOTE_DECRYPT_TLS_CA"`
+
+	CoSignKeyFile string   `help:"File with the 32 byte ed25519 seed of the co-signing key of this instance. If set, other instances can ask for co-signatures of their results." env:"VOTE_DECRYPT_COSIGN_KEY_FILE"`
+	CoSigners     []string `help:"Addresses of the grpc servers of the instances, that co-sign each result of Stop." env:"VOTE_DECRYPT_COSIGNERS"`
+	CoSignQuorum  int      `help:"Number of co-signatures, that each result needs. 0 means all co-signers." env:"VOTE_DECRYPT_COSIGN_QUORUM" default:"0"`
+
+	LeaderLease string        `help:"Path of a lease file on a file system, that is shared by all instances. If set, only the instance that holds the lease handles polls." env:"VOTE_DECRYPT_LEADER_LEASE"`
+	LeaderAddr  string        `help:"Address of this instance. Other instances report it to clients, when this instance is the leader." env:"VOTE_DECRYPT_LEADER_ADDR"`
+	LeaderTTL   time.Duration `help:"Time after which the lease of a failed leader expires." env:"VOTE_DECRYPT_LEADER_TTL" default:"15s"`
+
+	KeepaliveTime                time.Duration `help:"Time after which the server pings an idle client. 0 uses the grpc default of 2h." env:"VOTE_DECRYPT_KEEPALIVE_TIME" default:"1m"`
+	KeepaliveTimeout             time.Duration `help:"Timeout for the response of a ping. 0 uses the grpc default of 20s." env:"VOTE_DECRYPT_KEEPALIVE_TIMEOUT" default:"20s"`
+	KeepaliveMinTime             time.Duration `help:"Minimum time between two pings of a client. Clients that ping more often are disconnected. 0 uses the grpc default of 5m." env:"VOTE_DECRYPT_KEEPALIVE_MIN_TIME" default:"30s"`
+	KeepalivePermitWithoutStream bool          `help:"Allow pings of clients without an active call." env:"VOTE_DECRYPT_KEEPALIVE_PERMIT_WITHOUT_STREAM" default:"true" negatable:""`
+	MaxConnectionIdle            time.Duration `help:"Time after which an idle connection is closed. 0 means never." env:"VOTE_DECRYPT_MAX_CONNECTION_IDLE" default:"0"`
+	RequestTimeout               time.Duration `help:"Deadline for calls, that are sent without a deadline. 0 means no deadline." env:"VOTE_DECRYPT_REQUEST_TIMEOUT" default:"0"`
+
+	Quota       []string `help:"Quota for a grpc method in the form METHOD=LIMIT/PERIOD, for example Start=100/1h. Use METHOD:poll=LIMIT/PERIOD to count per poll." env:"VOTE_DECRYPT_QUOTAS"`
+	Roles       []string `help:"Role of callers in the form IDENTITY=ROLE, for example mtls:vote-service=vote-service. The roles are vote-service, admin and auditor. If set, each call needs a role." env:"VOTE_DECRYPT_ROLES"`
+	PolicyURL   string   `help:"URL of an OPA decision, for example http://localhost:8181/v1/data/vote_decrypt/allow. If set, each call has to be allowed by the policy." env:"VOTE_DECRYPT_POLICY_URL"`
+	MetricsPort int      `help:"Port for the prometheus metrics. 0 means no metrics." env:"VOTE_DECRYPT_METRICS_PORT" default:"0"`
+	SelfTest    bool     `help:"Check the keys, the random source and the store at start and refuse to start, if a check fails." env:"VOTE_DECRYPT_SELF_TEST" default:"true" negatable:""`
+	LogLevel    string   `help:"Minimum level of the log messages. Can be changed at runtime with SetLogLevel or toggled to debug with SIGUSR1." enum:"debug,info,warning,error" env:"VOTE_DECRYPT_LOG_LEVEL" default:"info"`
+
+	PollMetrics        bool     `help:"Add metrics with the label poll for the votes, invalid votes and durations of each decryption." env:"VOTE_DECRYPT_POLL_METRICS"`
+	PollMetricsAllow   []string `help:"Poll ids or prefixes ending with *, that get their own label value in the per poll metrics." env:"VOTE_DECRYPT_POLL_METRICS_ALLOW"`
+	PollMetricsBuckets int      `help:"Number of hash buckets for the polls, that are not allowed. 0 puts them all into the label other." env:"VOTE_DECRYPT_POLL_METRICS_BUCKETS" default:"0"`
+
+	ChaosStoreLatency    time.Duration `hidden:"" help:"Chaos testing: Latency added to each store call."`
+	ChaosStoreErrorRate  float64       `hidden:"" help:"Chaos testing: Probability that a store call fails."`
+	ChaosRandomErrorRate float64       `hidden:"" help:"Chaos testing: Probability that reading random data fails."`
+}
+
+// StoreConfig are the arguments to configure the storage backend.
+type StoreConfig struct {
+	Store        string `help:"Path for the file system storage of poll keys." env:"VOTE_DECRYPT_STORE" default:"vote_data"`
+	StoreBackend string `help:"Storage backend for poll keys." enum:"file,vault" env:"VOTE_DECRYPT_STORE_BACKEND" default:"file"`
+
+	VaultAddr   string `help:"Address of the vault server." env:"VAULT_ADDR"`
+	VaultToken  string `help:"Token for the vault server." env:"VAULT_TOKEN" secret:""`
+	VaultMount  string `help:"Mount path of the vault KV secrets engine (version 2)." env:"VOTE_DECRYPT_VAULT_MOUNT" default:"secret"`
+	VaultPrefix string `help:"Path in the vault KV secrets engine for the poll data." env:"VOTE_DECRYPT_VAULT_PREFIX" default:"vote-decrypt"`
+
+	StateStoreBackend string `help:"Separate storage backend for the state of the polls. With none, the state is saved with the poll keys." enum:"none,file" env:"VOTE_DECRYPT_STATE_STORE_BACKEND" default:"none"`
+	StateStore        string `help:"Path for the file system storage of the poll state." env:"VOTE_DECRYPT_STATE_STORE" default:"vote_state"`
+
+	IntegrityKey string `help:"Path of a file with 32 random bytes. If set, each store record is protected with an hmac." env:"VOTE_DECRYPT_INTEGRITY_KEY"`
+}
+
+// OpenStore returns the configured storage backend.
+//
+// If a state store backend is configured, the poll keys are saved in the
+// storage backend and the state of the polls in the state store backend.
+//
+// If an integrity key is configured, the returned store is an
+// *integrity.Store.
+func (c StoreConfig) OpenStore() (decrypt.Store, error) {
+	backend, err := c.openKeyStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.StateStoreBackend == "file" {
+		backend = split.New(backend, store.New(c.stateStorePath()))
+	}
+
+	if c.IntegrityKey != "" {
+		key, err := readIntegrityKey(c.IntegrityKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading integrity key: %w", err)
+		}
+		backend = integrity.New(backend, key)
+	}
+
+	return backend, nil
+}
+
+// readIntegrityKey reads the key for the integrity store from a file.
+func readIntegrityKey(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadMainKey(f)
+}
+
+func (c StoreConfig) openKeyStore() (decrypt.Store, error) {
+	switch c.StoreBackend {
+	case "vault":
+		if c.VaultAddr == "" {
+			return nil, fmt.Errorf("vault store needs a vault address. Check the environment variable VAULT_ADDR")
+		}
+		mount := c.VaultMount
+		if mount == "" {
+			mount = "secret"
+		}
+		prefix := c.VaultPrefix
+		if prefix == "" {
+			prefix = "vote-decrypt"
+		}
+		return vault.New(c.VaultAddr, c.VaultToken, mount, prefix), nil
+
+	default:
+		return store.New(c.storePath()), nil
+	}
+}
+
+// FilePaths returns the folders of the configured file system stores.
+func (c StoreConfig) FilePaths() []string {
+	var paths []string
+	if c.StoreBackend != "vault" {
+		paths = append(paths, c.storePath())
+	}
+
+	if c.StateStoreBackend == "file" {
+		paths = append(paths, c.stateStorePath())
+	}
+	return paths
+}
+
+func (c StoreConfig) storePath() string {
+	if c.Store == "" {
+		return "vote_data"
+	}
+	return c.Store
+}
+
+func (c StoreConfig) stateStorePath() string {
+	if c.StateStore == "" {
+		return "vote_state"
+	}
+	return c.StateStore
+}
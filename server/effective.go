@@ -0,0 +1,102 @@
+package server
+
+import (
+	"reflect"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/version"
+)
+
+// EffectiveConfig is the configuration of a running instance. It is returned
+// as json by the admin method EffectiveConfig.
+type EffectiveConfig struct {
+	// Build is the build information of the binary.
+	Build version.Info `json:"build"`
+
+	// Config are all values, that are not empty, with the name of their
+	// environment variable or flag. Secrets are redacted.
+	Config map[string]string `json:"config"`
+
+	// Features are the names of the enabled features. Experimental features
+	// have the prefix `experimental:`.
+	Features []string `json:"features"`
+}
+
+// NewEffectiveConfig returns the effective configuration for a config.
+func NewEffectiveConfig(config Config) EffectiveConfig {
+	values := make(map[string]string)
+	configValues(reflect.ValueOf(config), func(name, value string) {
+		values[name] = value
+	})
+
+	return EffectiveConfig{
+		Build:    version.Get(),
+		Config:   values,
+		Features: features(config),
+	}
+}
+
+// features returns the names of the features, that are enabled by the config.
+func features(config Config) []string {
+	enabled := []struct {
+		name string
+		on   bool
+	}{
+		{"fips", config.FIPS},
+		{"fips-module", config.FIPS && crypto.FIPSModule()},
+		{"require-constant-time", config.RequireConstantTime},
+		{"decrypt-autotune", config.DecryptAutoTune},
+		{"spill", config.SpillSize > 0},
+		{"main-key-certificate", config.MainKeyCertificate != ""},
+		{"attestation", config.Attestation},
+		{"admin-methods", config.AdminToken != ""},
+		{"escrow", config.EscrowKey != ""},
+		{"signed-stop", config.StopKey != ""},
+		{"audit-log", config.AuditLog != ""},
+		{"audit-syslog", config.AuditSyslog != ""},
+		{"audit-journald", config.AuditJournald},
+		{"security-log", config.SecurityLog != ""},
+		{"core-dumps", config.CoreDumps},
+		{"crash-reports", config.CrashDir != ""},
+		{"commitment", config.Commitment},
+		{"poll-signing-keys", config.PollSigningKeys},
+		{"meeting-signing-keys", config.MeetingKeyFile != ""},
+		{"co-signing", config.CoSignKeyFile != ""},
+		{"co-signers", len(config.CoSigners) > 0},
+		{"timestamps", config.TSAURL != ""},
+		{"transparency-log", config.RekorURL != ""},
+		{"result-upload", config.ResultStoreEndpoint != ""},
+		{"kafka", len(config.KafkaBrokers) > 0},
+		{"roughtime", len(config.RoughtimeServers) > 0},
+		{"read-only", config.ReadOnly},
+		{"deletion-delay", config.DeletionDelay > 0},
+		{"no-decryption-certificates", config.NoDecryptionInterval > 0},
+		{"gc", config.GCInterval > 0},
+		{"split-store", config.StateStoreBackend == "file"},
+		{"integrity", config.IntegrityKey != ""},
+		{"store-breaker", config.StoreBreakerThreshold > 0},
+		{"alerts", config.AlertWebhook != "" || config.AlertSMTPAddr != ""},
+		{"standby", config.Standby},
+		{"replication", config.ReplicaAddr != ""},
+		{"leader-election", config.LeaderLease != ""},
+		{"quotas", len(config.Quota) > 0},
+		{"roles", len(config.Roles) > 0},
+		{"policy", config.PolicyURL != ""},
+		{"metrics", config.MetricsPort > 0},
+		{"poll-metrics", config.PollMetrics},
+		{"self-test", config.SelfTest},
+		{"chaos", config.ChaosStoreLatency > 0 || config.ChaosStoreErrorRate > 0 || config.ChaosRandomErrorRate > 0},
+	}
+
+	names := []string{}
+	for _, feature := range enabled {
+		if feature.on {
+			names = append(names, feature.name)
+		}
+	}
+
+	for _, name := range config.Experimental {
+		names = append(names, "experimental:"+name)
+	}
+	return names
+}
@@ -0,0 +1,62 @@
+package server_test
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/server"
+)
+
+func TestNewEffectiveConfig(t *testing.T) {
+	config := server.Config{
+		Port:         9014,
+		AdminToken:   "admin-secret",
+		AlertWebhook: "https://hooks.example.com/webhook-secret",
+		Commitment:   true,
+		StoreConfig:  server.StoreConfig{StoreBackend: "vault", VaultToken: "vault-secret"},
+	}
+
+	effective := server.NewEffectiveConfig(config)
+
+	encoded, err := json.Marshal(effective)
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+
+	for _, secret := range []string{"admin-secret", "webhook-secret", "vault-secret"} {
+		if strings.Contains(string(encoded), secret) {
+			t.Errorf("effective config contains %s: %s", secret, encoded)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"VOTE_DECRYPT_PORT":          "9014",
+		"VOTE_DECRYPT_STORE_BACKEND": "vault",
+		"VOTE_DECRYPT_ADMIN_TOKEN":   "[REDACTED SECRET]",
+		"VAULT_TOKEN":                "[REDACTED SECRET]",
+	} {
+		if got := effective.Config[name]; got != want {
+			t.Errorf("config %s is %q, expected %q", name, got, want)
+		}
+	}
+
+	if _, ok := effective.Config["VOTE_DECRYPT_AUDIT_LOG"]; ok {
+		t.Errorf("config contains the empty audit log")
+	}
+
+	for _, feature := range []string{"admin-methods", "commitment", "alerts"} {
+		if !slices.Contains(effective.Features, feature) {
+			t.Errorf("features %v do not contain %s", effective.Features, feature)
+		}
+	}
+
+	if slices.Contains(effective.Features, "replication") {
+		t.Errorf("features %v contain replication", effective.Features)
+	}
+
+	if effective.Build.GoVersion == "" {
+		t.Errorf("build info has no go version")
+	}
+}
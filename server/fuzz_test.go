@@ -0,0 +1,47 @@
+package server_test
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	decryptgrpc "github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/server"
+)
+
+// FuzzConfig reads arbitrary main key files and config values. It only checks,
+// that a broken configuration does not panic. Run it with `go test ./server
+// -fuzz FuzzConfig`.
+func FuzzConfig(f *testing.F) {
+	pemKey, err := crypto.EncodeMainKey([]byte("12345678901234567890123456789012"), true)
+	if err != nil {
+		f.Fatalf("EncodeMainKey: %v", err)
+	}
+
+	f.Add(make([]byte, 32), "Start=100/1h", []byte{})
+	f.Add(pemKey, "Stop:poll=1/1m", make([]byte, 65))
+	f.Add([]byte{}, "", []byte{})
+
+	f.Fuzz(func(t *testing.T, mainKey []byte, quota string, escrowKey []byte) {
+		name := filepath.Join(t.TempDir(), "main_key")
+		if err := os.WriteFile(name, mainKey, 0600); err != nil {
+			t.Fatalf("writing main key file: %v", err)
+		}
+
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("opening main key file: %v", err)
+		}
+		defer file.Close()
+
+		if key, err := server.ReadMainKey(file); err == nil {
+			server.LocalCrypto(key, rand.Reader, false)
+			server.LocalCrypto(key, rand.Reader, true)
+		}
+
+		decryptgrpc.ParseQuota(quota)
+		crypto.NewSealer(escrowKey, rand.Reader)
+	})
+}
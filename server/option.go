@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"google.golang.org/grpc"
+)
+
+// Option for Run().
+type Option func(*hooks)
+
+type hooks struct {
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	storeWrappers      []func(decrypt.Store) decrypt.Store
+	decryptOptions     []decrypt.Option
+	validators         []Validator
+}
+
+// Validator checks a grpc request before it is handled.
+//
+// method is the full grpc method name like `/Decrypt/Start` and req the
+// request message, for example *grpc.StartRequest from the grpc package of
+// vote-decrypt.
+//
+// If the validator returns an error, the request is rejected. If the error is
+// not a grpc status error, the code InvalidArgument is used.
+type Validator func(ctx context.Context, method string, req any) error
+
+// WithUnaryInterceptors adds interceptors for unary grpc methods. They are
+// called after the builtin interceptors and the validators.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(h *hooks) {
+		h.unaryInterceptors = append(h.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors adds interceptors for streaming grpc methods.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(h *hooks) {
+		h.streamInterceptors = append(h.streamInterceptors, interceptors...)
+	}
+}
+
+// WithStoreWrapper wraps the configured storage backend, for example to add
+// caching or logging. Multiple wrappers are applied in the given order, so the
+// last wrapper is the outermost.
+func WithStoreWrapper(wrapper func(decrypt.Store) decrypt.Store) Option {
+	return func(h *hooks) {
+		h.storeWrappers = append(h.storeWrappers, wrapper)
+	}
+}
+
+// WithDecryptOptions adds options for the decrypt component. They are applied
+// after the options from the Config.
+func WithDecryptOptions(options ...decrypt.Option) Option {
+	return func(h *hooks) {
+		h.decryptOptions = append(h.decryptOptions, options...)
+	}
+}
+
+// WithValidators adds validators for the grpc requests.
+func WithValidators(validators ...Validator) Option {
+	return func(h *hooks) {
+		h.validators = append(h.validators, validators...)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+)
+
+// selfTest checks the crypto backend and the store before the server accepts
+// requests.
+//
+// The store is checked by saving, loading and removing a poll key. In read only
+// mode, the store is only read, because it could be used by another instance.
+func selfTest(cryptoLib crypto.Crypto, backend decrypt.Store, readOnly bool) error {
+	if err := cryptoLib.SelfTest(); err != nil {
+		return fmt.Errorf("crypto: %w", err)
+	}
+
+	if err := storeSelfTest(backend, readOnly); err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+
+	log.Println("Self-test passed")
+	return nil
+}
+
+func storeSelfTest(backend decrypt.Store, readOnly bool) error {
+	if readOnly {
+		if _, err := backend.ListPolls(); err != nil {
+			return fmt.Errorf("listing polls: %w", err)
+		}
+		return nil
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return fmt.Errorf("reading random: %w", err)
+	}
+	id := "self-test/" + hex.EncodeToString(random)
+	key := []byte("vote-decrypt self-test " + id)
+
+	if err := backend.SaveKey(id, key); err != nil {
+		return fmt.Errorf("saving key: %w", err)
+	}
+
+	loaded, err := backend.LoadKey(id)
+	if err != nil {
+		backend.ClearPoll(id)
+		return fmt.Errorf("loading key: %w", err)
+	}
+
+	if err := backend.ClearPoll(id); err != nil {
+		return fmt.Errorf("removing poll %s: %w", id, err)
+	}
+
+	if !bytes.Equal(loaded, key) {
+		return fmt.Errorf("loaded key is different from the saved key")
+	}
+
+	return nil
+}
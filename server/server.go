@@ -0,0 +1,1119 @@
+// Package server runs the vote-decrypt service.
+//
+// It builds the decrypt component with its crypto, store and audit log from a
+// Config and runs the grpc server. The vote-decrypt binary is a thin wrapper
+// around Run().
+//
+// Embedders can register own grpc interceptors, store wrappers and request
+// validators with the options, without forking the service.
+package server
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/alert"
+	"github.com/OpenSlides/vote-decrypt/audit"
+	"github.com/OpenSlides/vote-decrypt/certificate"
+	"github.com/OpenSlides/vote-decrypt/chaos"
+	"github.com/OpenSlides/vote-decrypt/crash"
+	"github.com/OpenSlides/vote-decrypt/crypto"
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	decryptgrpc "github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/kafka"
+	"github.com/OpenSlides/vote-decrypt/kms"
+	"github.com/OpenSlides/vote-decrypt/leader"
+	"github.com/OpenSlides/vote-decrypt/loglevel"
+	"github.com/OpenSlides/vote-decrypt/metrics"
+	"github.com/OpenSlides/vote-decrypt/objectstore"
+	"github.com/OpenSlides/vote-decrypt/policy"
+	"github.com/OpenSlides/vote-decrypt/rekor"
+	"github.com/OpenSlides/vote-decrypt/replication"
+	"github.com/OpenSlides/vote-decrypt/roughtime"
+	"github.com/OpenSlides/vote-decrypt/security"
+	"github.com/OpenSlides/vote-decrypt/store"
+	"github.com/OpenSlides/vote-decrypt/store/breaker"
+	"github.com/OpenSlides/vote-decrypt/tpm"
+	"github.com/OpenSlides/vote-decrypt/tsa"
+	"github.com/OpenSlides/vote-decrypt/watchdog"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Run runs the vote-decrypt service until ctx is done.
+func Run(ctx context.Context, config Config, options ...Option) error {
+	var h hooks
+	for _, o := range options {
+		o(&h)
+	}
+
+	logLevel := loglevel.Info
+	if config.LogLevel != "" {
+		level, err := loglevel.ParseLevel(config.LogLevel)
+		if err != nil {
+			return fmt.Errorf("parsing log level: %w", err)
+		}
+		logLevel = level
+	}
+
+	logOutput := log.Writer()
+	logFilter := loglevel.NewFilter(logOutput, logLevel)
+	log.SetOutput(logFilter)
+	defer log.SetOutput(logOutput)
+	toggleLogLevel(ctx, logFilter)
+
+	// Core dumps are disabled before any key is loaded. Fatal errors of the
+	// runtime print the stacks of all goroutines instead.
+	if !config.CoreDumps {
+		if err := crash.DisableCoreDumps(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	debug.SetTraceback("all")
+
+	crashHandler, recentAudit, err := newCrashHandler(config)
+	if err != nil {
+		return err
+	}
+	defer crashHandler.Recover()
+
+	var decryptOptions []decrypt.Option
+
+	var curve ecdh.Curve
+	if config.FIPS {
+		curve = ecdh.P256()
+	}
+
+	var faults *chaos.Chaos
+	var random io.Reader = rand.Reader
+	if config.ChaosStoreLatency > 0 || config.ChaosStoreErrorRate > 0 || config.ChaosRandomErrorRate > 0 {
+		log.Printf("Warning: Chaos testing is enabled. Do not use this in production.")
+		faults = chaos.New(chaos.Config{
+			StoreLatency:    config.ChaosStoreLatency,
+			StoreErrorRate:  config.ChaosStoreErrorRate,
+			RandomErrorRate: config.ChaosRandomErrorRate,
+		})
+		random = faults.Random(rand.Reader)
+	}
+
+	cryptoLib, mainKeyFile, err := loadMainKey(ctx, config, random, curve)
+	if err != nil {
+		return err
+	}
+	if mainKeyFile != "" {
+		decryptOptions = append(decryptOptions, decrypt.WithRemoveMainKey(func() error { return os.Remove(mainKeyFile) }))
+	}
+
+	if config.FIPS {
+		if err := cryptoLib.CheckFIPS(); err != nil {
+			return fmt.Errorf("fips mode: %w", err)
+		}
+
+		if len(config.RoughtimeServers) > 0 {
+			return fmt.Errorf("fips mode: roughtime uses ed25519, that is not allowed")
+		}
+
+		if config.StopKey != "" {
+			return fmt.Errorf("fips mode: the stop key is an ed25519 key, that is not allowed")
+		}
+
+		if crypto.FIPSModule() {
+			log.Println("FIPS mode with a validated cryptographic module")
+		} else {
+			log.Println("Warning: FIPS mode without a validated cryptographic module. Use a binary from `make build-fips`.")
+		}
+	}
+
+	if err := crypto.Caps().CheckConstantTime(); err != nil {
+		if config.RequireConstantTime {
+			return fmt.Errorf("constant-time crypto: %w", err)
+		}
+		log.Printf("Warning: %v. See `vote-decrypt crypto-caps`.", err)
+	}
+
+	fmt.Printf("Public Main Key: %s\n", base64.StdEncoding.EncodeToString(cryptoLib.PublicMainKey()))
+	fmt.Printf("Main Key Fingerprint: %s\n", decrypt.Fingerprint(cryptoLib.PublicMainKey()))
+	fmt.Printf("Main Key Fingerprint (hex): %s\n", decrypt.MainKeyFingerprint(cryptoLib.PublicMainKey()))
+
+	var certificateChain [][]byte
+	if config.MainKeyCertificate != "" {
+		encoded, err := os.ReadFile(config.MainKeyCertificate)
+		if err != nil {
+			return fmt.Errorf("reading main key certificate: %w", err)
+		}
+
+		certificateChain, err = certificate.ParsePEM(encoded)
+		if err != nil {
+			return fmt.Errorf("reading main key certificate: %w", err)
+		}
+
+		if err := certificate.Check(certificateChain, cryptoLib.PublicMainKey()); err != nil {
+			return fmt.Errorf("main key certificate: %w", err)
+		}
+	}
+
+	var auditOptions []audit.Option
+	if len(config.RoughtimeServers) > 0 {
+		clock, err := roughtimeClock(ctx, config.RoughtimeServers)
+		if err != nil {
+			return fmt.Errorf("initializing roughtime clock: %w", err)
+		}
+
+		if config.RoughtimeInterval > 0 {
+			go clock.Run(ctx, config.RoughtimeInterval)
+		}
+
+		decryptOptions = append(decryptOptions, decrypt.WithClock(clock.Now))
+		auditOptions = append(auditOptions, audit.WithClock(clock.Now))
+	}
+
+	if config.AuditSyslog != "" {
+		syslog, err := audit.NewSyslog(config.AuditSyslog)
+		if err != nil {
+			return fmt.Errorf("initializing syslog audit sink: %w", err)
+		}
+		defer syslog.Close()
+
+		auditOptions = append(auditOptions, audit.WithSink(syslog))
+	}
+	if config.AuditJournald {
+		auditOptions = append(auditOptions, audit.WithSink(audit.NewJournald(audit.DefaultJournaldSocket)))
+	}
+	if (config.AuditSyslog != "" || config.AuditJournald) && config.AuditLog == "" {
+		return fmt.Errorf("syslog and journald audit sinks need an audit log file")
+	}
+
+	auditOptions = append(auditOptions, audit.WithSink(recentAudit))
+
+	if config.AuditLog != "" {
+		decryptOptions = append(decryptOptions, decrypt.WithAuditLog(audit.New(config.AuditLog, auditOptions...)))
+	}
+	if config.NoDecryptionInterval > 0 && config.AuditLog == "" {
+		return fmt.Errorf("no decryption certificates need an audit log file")
+	}
+	if config.ReadOnly || config.Standby {
+		decryptOptions = append(decryptOptions, decrypt.WithReadOnly(true))
+	}
+	if config.DeletionDelay > 0 {
+		decryptOptions = append(decryptOptions, decrypt.WithDeletionDelay(config.DeletionDelay))
+	}
+	if config.MaxVotes > 0 {
+		decryptOptions = append(decryptOptions, decrypt.WithMaxVotes(config.MaxVotes))
+	}
+	if config.MaxVoteSize > 0 {
+		decryptOptions = append(decryptOptions, decrypt.WithMaxVoteSize(config.MaxVoteSize))
+	}
+	if config.MaxPollSize > 0 {
+		decryptOptions = append(decryptOptions, decrypt.WithMaxPollSize(config.MaxPollSize))
+	}
+
+	if config.EscrowKey != "" {
+		escrowKey, err := base64.StdEncoding.DecodeString(config.EscrowKey)
+		if err != nil {
+			return fmt.Errorf("decoding escrow key: %w", err)
+		}
+
+		sealer, err := crypto.NewSealer(escrowKey, random)
+		if err != nil {
+			return fmt.Errorf("escrow key: %w", err)
+		}
+
+		if config.FIPS && sealer.Curve() != ecdh.P256() {
+			return fmt.Errorf("fips mode: escrow key is not a P-256 key")
+		}
+		decryptOptions = append(decryptOptions, decrypt.WithSealer(sealer))
+	}
+
+	if config.StopKey != "" {
+		stopKey, err := base64.StdEncoding.DecodeString(config.StopKey)
+		if err != nil {
+			return fmt.Errorf("decoding stop key: %w", err)
+		}
+
+		if len(stopKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("stop key has %d bytes, expected %d", len(stopKey), ed25519.PublicKeySize)
+		}
+		decryptOptions = append(decryptOptions, decrypt.WithStopKey(stopKey))
+	}
+
+	if config.Commitment {
+		decryptOptions = append(decryptOptions, decrypt.WithCommitment())
+	}
+
+	if config.PollSigningKeys {
+		decryptOptions = append(decryptOptions, decrypt.WithPollSigningKeys())
+	}
+
+	if config.MeetingKeyFile != "" {
+		secret, err := loadMeetingSecret(config.MeetingKeyFile)
+		if err != nil {
+			return err
+		}
+		decryptOptions = append(decryptOptions, decrypt.WithMeetingSigningKeys(secret))
+	}
+
+	if config.CoSignKeyFile != "" {
+		key, err := loadCoSigningKey(config.CoSignKeyFile)
+		if err != nil {
+			return err
+		}
+		decryptOptions = append(decryptOptions, decrypt.WithCoSigningKey(key))
+		fmt.Printf("Co-Signing Key: %s\n", base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+	}
+
+	if len(config.Experimental) > 0 {
+		features := make([]decrypt.Feature, len(config.Experimental))
+		for i, name := range config.Experimental {
+			feature, err := decrypt.ParseFeature(name)
+			if err != nil {
+				return fmt.Errorf("parsing experimental features: %w", err)
+			}
+			features[i] = feature
+		}
+		decryptOptions = append(decryptOptions, decrypt.WithFeatures(features...))
+	}
+
+	if config.MaxPlaintextSize > 0 {
+		policy := decrypt.OversizeFail
+		if config.OversizePolicy == "invalid" {
+			policy = decrypt.OversizeInvalid
+		}
+		decryptOptions = append(decryptOptions, decrypt.WithMaxPlaintextSize(config.MaxPlaintextSize, policy))
+	}
+
+	if config.DecryptWorkers > 0 {
+		decryptOptions = append(decryptOptions, decrypt.WithDecryptWorkers(config.DecryptWorkers))
+	}
+
+	if config.DecryptAutoTune {
+		decryptOptions = append(decryptOptions, decrypt.WithAutoTune())
+	}
+
+	if config.MaxParallelStops > 0 {
+		decryptOptions = append(decryptOptions, decrypt.WithMaxParallelStops(config.MaxParallelStops))
+	}
+
+	if config.MemoryLimit > 0 {
+		// The last quarter is left for the runtime, the grpc buffers and the
+		// requests, that wait for the budget.
+		debug.SetMemoryLimit(config.MemoryLimit)
+		decryptOptions = append(decryptOptions, decrypt.WithMemoryBudget(config.MemoryLimit/4*3))
+	}
+
+	if config.SpillSize > 0 {
+		decryptOptions = append(decryptOptions, decrypt.WithSpill(config.SpillDir, config.SpillSize))
+	}
+
+	if len(config.Formats) > 0 {
+		formats := make([]crypto.Format, len(config.Formats))
+		for i, name := range config.Formats {
+			format, err := crypto.ParseFormat(name)
+			if err != nil {
+				return fmt.Errorf("parsing formats: %w", err)
+			}
+			formats[i] = format
+		}
+		decryptOptions = append(decryptOptions, decrypt.WithVoteFilter(crypto.AcceptFormats(formats...)))
+	}
+
+	if config.IDPattern != "" {
+		pattern, err := regexp.Compile(config.IDPattern)
+		if err != nil {
+			return fmt.Errorf("parsing id pattern: %w", err)
+		}
+		decryptOptions = append(decryptOptions, decrypt.WithIDValidator(decrypt.IDPattern(pattern)))
+	}
+
+	if config.IDMaxLength > 0 {
+		decryptOptions = append(decryptOptions, decrypt.WithIDValidator(decrypt.IDMaxLength(config.IDMaxLength)))
+	}
+
+	if len(config.IDNamespaces) > 0 {
+		decryptOptions = append(decryptOptions, decrypt.WithIDValidator(decrypt.IDNamespaces(config.IDNamespaces...)))
+	}
+
+	if config.Attestation {
+		attestOption, closeAttestor, err := tpmAttestor(config.TPMDevice, config.AttestationPCR)
+		if err != nil {
+			return fmt.Errorf("initializing attestation: %w", err)
+		}
+		defer closeAttestor()
+		decryptOptions = append(decryptOptions, attestOption)
+	}
+
+	if len(config.KafkaBrokers) > 0 {
+		kafkaConfig, err := newKafkaConfig(config)
+		if err != nil {
+			return fmt.Errorf("kafka config: %w", err)
+		}
+
+		producer, err := kafka.New(kafkaConfig)
+		if err != nil {
+			return fmt.Errorf("connecting to kafka: %w", err)
+		}
+		defer producer.Close()
+		decryptOptions = append(decryptOptions, decrypt.WithResultWriter(producer))
+	}
+
+	alerter, err := newAlerter(config)
+	if err != nil {
+		return fmt.Errorf("initializing alerts: %w", err)
+	}
+	if alerter != nil {
+		decryptOptions = append(decryptOptions, decrypt.WithMonitor(alerter))
+		go alerter.Run(ctx, alertInterval)
+	}
+
+	if config.PollMetrics {
+		if config.MetricsPort <= 0 {
+			return fmt.Errorf("per poll metrics need the metrics port")
+		}
+
+		labels := metrics.PollLabels{Allow: config.PollMetricsAllow, Buckets: config.PollMetricsBuckets}
+		decryptOptions = append(decryptOptions, decrypt.WithMonitor(metrics.NewPollMonitor(labels)))
+	}
+
+	decryptOptions = append(decryptOptions, h.decryptOptions...)
+
+	backend, err := config.OpenStore()
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+
+	compacter, _ := backend.(store.Compacter)
+	if config.GCInterval > 0 && compacter == nil {
+		return fmt.Errorf("the store backend does not support garbage collection")
+	}
+
+	if faults != nil {
+		backend = faults.Store(backend)
+	}
+
+	if config.Standby && config.ReplicaAddr != "" {
+		return fmt.Errorf("an instance can not be a standby and replicate to a standby")
+	}
+
+	var primary *replication.Primary
+	if config.ReplicaAddr != "" {
+		tlsConfig, err := replication.TLSConfig(config.TLSCert, config.TLSKey, config.TLSCA, false)
+		if err != nil {
+			return fmt.Errorf("replication tls config: %w", err)
+		}
+
+		conn, err := replication.Dial(config.ReplicaAddr, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("connecting to standby: %w", err)
+		}
+		defer conn.Close()
+
+		primary = replication.NewPrimary(backend, conn)
+		backend = primary
+	}
+
+	if config.StoreBreakerThreshold > 0 {
+		var breakerOptions []breaker.Option
+		if alerter != nil {
+			breakerOptions = append(breakerOptions, breaker.WithFailureHook(alerter.StoreFailed))
+		}
+		backend = breaker.New(backend, config.StoreBreakerThreshold, config.StoreBreakerCooldown, breakerOptions...)
+	}
+
+	for _, wrap := range h.storeWrappers {
+		backend = wrap(backend)
+	}
+
+	decrypter := decrypt.New(
+		cryptoLib,
+		backend,
+		decryptOptions...,
+	)
+
+	if primary != nil {
+		if err := primary.Sync(); err != nil {
+			return fmt.Errorf("syncing standby: %w", err)
+		}
+	}
+
+	if config.SelfTest {
+		if err := selfTest(cryptoLib, backend, config.ReadOnly || config.Standby); err != nil {
+			return fmt.Errorf("self-test failed: %w", err)
+		}
+	}
+
+	var securityLog *security.Log
+	if config.SecurityLog != "" {
+		w, err := security.Open(config.SecurityLog)
+		if err != nil {
+			return fmt.Errorf("opening security log: %w", err)
+		}
+		defer w.Close()
+
+		securityLog, err = security.New(w, security.Format(config.SecurityLogFormat))
+		if err != nil {
+			return fmt.Errorf("initializing security log: %w", err)
+		}
+	}
+
+	if config.Standby {
+		tlsConfig, err := replication.TLSConfig(config.TLSCert, config.TLSKey, config.TLSCA, true)
+		if err != nil {
+			return fmt.Errorf("replication tls config: %w", err)
+		}
+
+		replicationServer := replication.NewServer(backend, decrypter.ReadOnly)
+		replicationAddr := fmt.Sprintf(":%d", config.ReplicationPort)
+
+		var replicationOptions []replication.ServerOption
+		if securityLog != nil {
+			replicationOptions = append(replicationOptions, replication.WithSecurityLog(securityLog))
+		}
+
+		go func() {
+			if err := replication.RunServer(ctx, replicationServer, replicationAddr, tlsConfig, replicationOptions...); err != nil {
+				log.Printf("Error: %v", err)
+			}
+		}()
+	}
+
+	var elector *leader.Elector
+	if config.LeaderLease != "" {
+		if config.Standby || config.ReplicaAddr != "" {
+			return fmt.Errorf("leader election can not be used with replication")
+		}
+
+		if config.LeaderAddr == "" {
+			return fmt.Errorf("leader election needs the address of this instance")
+		}
+
+		ttl := config.LeaderTTL
+		if ttl <= 0 {
+			ttl = 15 * time.Second
+		}
+
+		if err := decrypter.SetReadOnly(ctx, true); err != nil {
+			return fmt.Errorf("starting in read only mode: %w", err)
+		}
+
+		elector = leader.New(leader.NewFileLease(config.LeaderLease), config.LeaderAddr, ttl, func(isLeader bool) {
+			if isLeader {
+				log.Printf("Instance is the leader")
+			} else {
+				log.Printf("Instance is not the leader")
+			}
+
+			if err := decrypter.SetReadOnly(ctx, !isLeader); err != nil {
+				log.Printf("Error: setting read only mode: %v", err)
+			}
+		})
+		go elector.Run(ctx)
+	}
+
+	if config.DeletionDelay > 0 {
+		go decrypter.RunScheduledClears(ctx, time.Minute)
+	}
+
+	if config.NoDecryptionInterval > 0 {
+		go decrypter.RunNoDecryption(ctx, config.NoDecryptionInterval)
+	}
+
+	if config.GCInterval > 0 {
+		go runCompaction(ctx, compacter, decrypter.ReadOnly, config.GCInterval)
+	}
+
+	quotas := make([]decryptgrpc.Quota, len(config.Quota))
+	for i, value := range config.Quota {
+		quota, err := decryptgrpc.ParseQuota(value)
+		if err != nil {
+			return fmt.Errorf("parsing quota: %w", err)
+		}
+		quotas[i] = quota
+	}
+
+	roles := make([]decryptgrpc.RoleBinding, len(config.Roles))
+	for i, value := range config.Roles {
+		role, err := decryptgrpc.ParseRoleBinding(value)
+		if err != nil {
+			return fmt.Errorf("parsing role: %w", err)
+		}
+		roles[i] = role
+	}
+
+	if config.MetricsPort > 0 {
+		go func() {
+			if err := metrics.RunServer(ctx, fmt.Sprintf(":%d", config.MetricsPort)); err != nil {
+				log.Printf("Error: %v", err)
+			}
+		}()
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 9014
+	}
+	addr := fmt.Sprintf(":%d", port)
+
+	unaryInterceptors := h.unaryInterceptors
+	streamInterceptors := h.streamInterceptors
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{crashHandler.UnaryInterceptor()}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{crashHandler.StreamInterceptor()}, streamInterceptors...)
+	if elector != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{elector.UnaryInterceptor()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{elector.StreamInterceptor()}, streamInterceptors...)
+	}
+	if len(h.validators) > 0 {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{validatorInterceptor(h.validators)}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{validatorStreamInterceptor(h.validators)}, streamInterceptors...)
+	}
+
+	serverOptions := []decryptgrpc.ServerOption{
+		decryptgrpc.WithAdminToken(config.AdminToken),
+		decryptgrpc.WithQuotas(quotas...),
+		decryptgrpc.WithRoles(roles...),
+		decryptgrpc.WithKeepalive(decryptgrpc.Keepalive{
+			Time:                config.KeepaliveTime,
+			Timeout:             config.KeepaliveTimeout,
+			MaxConnectionIdle:   config.MaxConnectionIdle,
+			MinTime:             config.KeepaliveMinTime,
+			PermitWithoutStream: config.KeepalivePermitWithoutStream,
+		}),
+		decryptgrpc.WithRequestTimeout(config.RequestTimeout),
+		decryptgrpc.WithUnaryInterceptors(unaryInterceptors...),
+		decryptgrpc.WithStreamInterceptors(streamInterceptors...),
+		decryptgrpc.WithLogLevel(logFilter),
+	}
+
+	effectiveConfig, err := json.Marshal(NewEffectiveConfig(config))
+	if err != nil {
+		return fmt.Errorf("encoding effective config: %w", err)
+	}
+	serverOptions = append(serverOptions, decryptgrpc.WithEffectiveConfig(effectiveConfig))
+
+	if securityLog != nil {
+		serverOptions = append(serverOptions, decryptgrpc.WithSecurityLog(securityLog))
+	}
+
+	if config.TSAURL != "" {
+		serverOptions = append(serverOptions, decryptgrpc.WithTimestamper(tsa.New(config.TSAURL)))
+	}
+
+	if config.RekorURL != "" {
+		serverOptions = append(serverOptions, decryptgrpc.WithTransparencyLog(rekor.New(config.RekorURL)))
+	}
+
+	if certificateChain != nil {
+		serverOptions = append(serverOptions, decryptgrpc.WithCertificateChain(certificateChain))
+	}
+
+	if config.PolicyURL != "" {
+		serverOptions = append(serverOptions, decryptgrpc.WithPolicy(policy.NewOPA(config.PolicyURL)))
+	}
+
+	if len(config.CoSigners) > 0 {
+		cosigners := make([]decryptgrpc.CoSigner, len(config.CoSigners))
+		for i, cosignerAddr := range config.CoSigners {
+			client, closeConn, err := decryptgrpc.NewClient(cosignerAddr, decryptgrpc.WithRetry(decryptgrpc.DefaultRetryPolicy))
+			if err != nil {
+				return fmt.Errorf("connecting to co-signer %s: %w", cosignerAddr, err)
+			}
+			defer closeConn()
+			cosigners[i] = client
+		}
+		serverOptions = append(serverOptions, decryptgrpc.WithCoSigners(config.CoSignQuorum, cosigners...))
+	}
+
+	if config.ResultStoreEndpoint != "" {
+		uploader, err := objectstore.New(objectstore.Config{
+			Endpoint:        config.ResultStoreEndpoint,
+			Bucket:          config.ResultStoreBucket,
+			Region:          config.ResultStoreRegion,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Prefix:          config.ResultStorePrefix,
+			Expiry:          config.ResultURLExpiry,
+		})
+		if err != nil {
+			return fmt.Errorf("initializing result store: %w", err)
+		}
+		serverOptions = append(serverOptions, decryptgrpc.WithResultUploader(uploader))
+	}
+
+	notifier := watchdog.FromEnv()
+	serverOptions = append(serverOptions, decryptgrpc.WithReady(func() {
+		if err := notifier.Notify(watchdog.Ready); err != nil {
+			log.Printf("Error: %v", err)
+		}
+
+		if interval := watchdog.Interval(); interval > 0 {
+			log.Printf("Sending heartbeats to the watchdog every %s", interval)
+			go notifier.Run(ctx, interval, func(ctx context.Context) error {
+				return healthPing(ctx, backend)
+			})
+		}
+
+		go func() {
+			<-ctx.Done()
+			if err := notifier.Notify(watchdog.Stopping); err != nil {
+				log.Printf("Error: %v", err)
+			}
+		}()
+	}))
+
+	if err := decryptgrpc.RunServer(ctx, decrypter, addr, serverOptions...); err != nil {
+		return fmt.Errorf("running grpc server: %w", err)
+	}
+
+	return nil
+}
+
+// loadMeetingSecret reads the secret for the meeting signing keys.
+func loadMeetingSecret(path string) ([]byte, error) {
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading meeting key file: %w", err)
+	}
+
+	if len(secret) < crypto.MinMeetingSecretSize {
+		return nil, fmt.Errorf("meeting key file has %d bytes, at least %d are needed", len(secret), crypto.MinMeetingSecretSize)
+	}
+	return secret, nil
+}
+
+// loadCoSigningKey reads the seed of the co-signing key.
+func loadCoSigningKey(path string) (ed25519.PrivateKey, error) {
+	seed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading co-signing key file: %w", err)
+	}
+
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("co-signing key file has %d bytes, expected %d", len(seed), ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// loadMainKey resolves the main key from the configured backend. For a key
+// file, it also returns the path of the file, so Wipe() can remove it.
+func loadMainKey(ctx context.Context, config Config, random io.Reader, curve ecdh.Curve) (crypto.Crypto, string, error) {
+	switch config.MainKeyBackend {
+	case "aws-kms":
+		signer, err := kms.NewAWS(ctx, kms.AWSConfig{
+			KeyID:           config.KMSKey,
+			Region:          config.AWSRegion,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		})
+		if err != nil {
+			return crypto.Crypto{}, "", fmt.Errorf("initializing aws kms: %w", err)
+		}
+		return crypto.NewWithSigner(signer, random, curve), "", nil
+
+	case "gcp-kms":
+		signer, err := kms.NewGCP(ctx, kms.GCPConfig{
+			KeyVersion:  config.KMSKey,
+			AccessToken: os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		})
+		if err != nil {
+			return crypto.Crypto{}, "", fmt.Errorf("initializing gcp kms: %w", err)
+		}
+		return crypto.NewWithSigner(signer, random, curve), "", nil
+
+	case "tpm":
+		if config.MainKey == nil {
+			return crypto.Crypto{}, "", fmt.Errorf("no sealed main key file given")
+		}
+
+		key, err := unsealMainKey(config.MainKey, config.TPMDevice)
+		if err != nil {
+			return crypto.Crypto{}, "", fmt.Errorf("unsealing key: %w", err)
+		}
+
+		cryptoLib, err := LocalCrypto(key, random, config.FIPS)
+		if err != nil {
+			return crypto.Crypto{}, "", fmt.Errorf("initializing crypto: %w", err)
+		}
+		return cryptoLib, config.MainKey.Name(), nil
+
+	default:
+		if config.MainKey == nil {
+			return crypto.Crypto{}, "", fmt.Errorf("no main key file given")
+		}
+
+		key, err := ReadMainKey(config.MainKey)
+		if err != nil {
+			return crypto.Crypto{}, "", fmt.Errorf("reading key: %w", err)
+		}
+
+		cryptoLib, err := LocalCrypto(key, random, config.FIPS)
+		if err != nil {
+			return crypto.Crypto{}, "", fmt.Errorf("initializing crypto: %w", err)
+		}
+		return cryptoLib, config.MainKey.Name(), nil
+	}
+}
+
+// alertInterval is the time between two checks for long running decryptions.
+const alertInterval = 10 * time.Second
+
+// newAlerter creates the alerter from the config. It returns nil, if no
+// threshold is configured.
+func newAlerter(config Config) (*alert.Alerter, error) {
+	thresholds := alert.Thresholds{
+		InvalidRatio: config.AlertInvalidPercent / 100,
+		StopDuration: config.AlertStopDuration,
+		StoreErrors:  config.AlertStoreErrors,
+	}
+
+	if thresholds == (alert.Thresholds{}) {
+		return nil, nil
+	}
+
+	if thresholds.StoreErrors > 0 && config.StoreBreakerThreshold <= 0 {
+		return nil, fmt.Errorf("store error alerts need the circuit breaker of the store")
+	}
+
+	var notifiers []alert.Notifier
+	if config.AlertWebhook != "" {
+		notifiers = append(notifiers, alert.NewWebhook(config.AlertWebhook))
+	}
+	if config.AlertSMTPAddr != "" {
+		if config.AlertSMTPFrom == "" || len(config.AlertSMTPTo) == 0 {
+			return nil, fmt.Errorf("alert emails need a sender and a recipient")
+		}
+		notifiers = append(notifiers, alert.NewSMTP(config.AlertSMTPAddr, config.AlertSMTPFrom, config.AlertSMTPTo, config.AlertSMTPUsername, config.AlertSMTPPassword))
+	}
+
+	if len(notifiers) == 0 {
+		return nil, fmt.Errorf("alert thresholds need a webhook or a smtp server")
+	}
+
+	return alert.New(thresholds, notifiers), nil
+}
+
+// newKafkaConfig creates the config of the kafka producer.
+func newKafkaConfig(config Config) (kafka.Config, error) {
+	kafkaConfig := kafka.Config{
+		Brokers:         config.KafkaBrokers,
+		Topic:           config.KafkaTopic,
+		PerVote:         config.KafkaPerVote,
+		TransactionalID: config.KafkaTransactionalID,
+	}
+
+	if config.KafkaTLS || config.KafkaTLSCA != "" {
+		kafkaConfig.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+
+		if config.KafkaTLSCA != "" {
+			caPEM, err := os.ReadFile(config.KafkaTLSCA)
+			if err != nil {
+				return kafka.Config{}, fmt.Errorf("reading ca file: %w", err)
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return kafka.Config{}, fmt.Errorf("no certificate found in %s", config.KafkaTLSCA)
+			}
+			kafkaConfig.TLS.RootCAs = pool
+		}
+	}
+
+	switch config.KafkaSASLMechanism {
+	case "", "none":
+	case "plain":
+		kafkaConfig.SASL = plain.Auth{User: config.KafkaSASLUser, Pass: config.KafkaSASLPassword}.AsMechanism()
+	case "scram-sha-256":
+		kafkaConfig.SASL = scram.Auth{User: config.KafkaSASLUser, Pass: config.KafkaSASLPassword}.AsSha256Mechanism()
+	case "scram-sha-512":
+		kafkaConfig.SASL = scram.Auth{User: config.KafkaSASLUser, Pass: config.KafkaSASLPassword}.AsSha512Mechanism()
+	default:
+		return kafka.Config{}, fmt.Errorf("unknown sasl mechanism %s", config.KafkaSASLMechanism)
+	}
+
+	if kafkaConfig.SASL != nil && config.KafkaSASLUser == "" {
+		return kafka.Config{}, fmt.Errorf("sasl needs a user")
+	}
+
+	return kafkaConfig, nil
+}
+
+// roughtimeClock parses the servers and syncs a clock with them.
+func roughtimeClock(ctx context.Context, rawServers []string) (*roughtime.Clock, error) {
+	servers := make([]roughtime.Server, len(rawServers))
+	for i, raw := range rawServers {
+		server, err := roughtime.ParseServer(raw)
+		if err != nil {
+			return nil, err
+		}
+		servers[i] = server
+	}
+
+	clock := roughtime.NewClock(servers)
+	if err := clock.Sync(ctx); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Roughtime clock is %s with uncertainty %s", clock.Now().Format(time.RFC3339), clock.Uncertainty())
+	return clock, nil
+}
+
+// validatorInterceptor returns a grpc interceptor that calls the validators
+// before the request is handled.
+func validatorInterceptor(validators []Validator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		for _, validate := range validators {
+			if err := validate(ctx, info.FullMethod, req); err != nil {
+				var grpcErr interface{ GRPCStatus() *status.Status }
+				if errors.As(err, &grpcErr) {
+					return nil, err
+				}
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// validatorStreamInterceptor is like validatorInterceptor() for streaming
+// methods. The validators are called for each received message.
+func validatorStreamInterceptor(validators []Validator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, validatingStream{ServerStream: ss, method: info.FullMethod, validators: validators})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+	method     string
+	validators []Validator
+}
+
+func (s validatingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	for _, validate := range s.validators {
+		if err := validate(s.Context(), s.method, m); err != nil {
+			var grpcErr interface{ GRPCStatus() *status.Status }
+			if errors.As(err, &grpcErr) {
+				return err
+			}
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return nil
+}
+
+// runCompaction calls Compact() of the store every interval until ctx is
+// done. It is skipped in read only mode, because another instance could write
+// to a shared store at the same time.
+func runCompaction(ctx context.Context, compacter store.Compacter, readOnly func() bool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if readOnly() {
+			continue
+		}
+
+		report, err := compacter.Compact()
+		if err != nil {
+			log.Printf("Error: compacting store: %v", err)
+			continue
+		}
+
+		for _, orphan := range report.Orphans {
+			log.Printf("Warning: store contains directory %s, that is not in the index", orphan)
+		}
+
+		if report.StaleEntries > 0 || report.EmptyDirs > 0 {
+			log.Printf("Compacted store: removed %d stale entries and %d empty directories, reclaimed %d bytes", report.StaleEntries, report.EmptyDirs, report.ReclaimedBytes)
+		}
+	}
+}
+
+// LocalCrypto initializes the crypto backend with a main key from a file. In
+// fips mode, the main key is used as ECDSA P-256 key and the poll keys use
+// P-256. Otherwise they use ed25519 and x25519.
+func LocalCrypto(key []byte, random io.Reader, fips bool) (crypto.Crypto, error) {
+	if !fips {
+		return crypto.New(key, random, nil), nil
+	}
+
+	signer, err := crypto.NewECDSASigner(key, random)
+	if err != nil {
+		return crypto.Crypto{}, err
+	}
+	return crypto.NewWithSigner(signer, random, ecdh.P256()), nil
+}
+
+// ReadMainKey reads the main key from a file. The file can contain the 32 raw
+// bytes or a PEM encoded PKCS#8 key. See crypto.DecodeMainKey().
+func ReadMainKey(f *os.File) ([]byte, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.DecodeMainKey(data)
+}
+
+// unsealMainKey reads a sealed main key from a file and unseals it with the
+// TPM.
+func unsealMainKey(f *os.File, tpmDevice string) ([]byte, error) {
+	sealed, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	device, err := tpm.Open(tpmDevice)
+	if err != nil {
+		return nil, fmt.Errorf("opening tpm: %w", err)
+	}
+	defer device.Close()
+
+	key, err := tpm.Unseal(device, sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("sealed key has %d bytes, expected 32", len(key))
+	}
+	return key, nil
+}
+
+// tpmAttestor returns the option to enable attestation with the TPM. The
+// returned function has to be called to close the TPM.
+func tpmAttestor(tpmDevice string, pcrs []int) (decrypt.Option, func(), error) {
+	binaryHash, err := executableHash()
+	if err != nil {
+		return nil, nil, fmt.Errorf("hashing binary: %w", err)
+	}
+
+	device, err := tpm.Open(tpmDevice)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening tpm: %w", err)
+	}
+
+	attestor, err := tpm.NewAttestor(device, pcrs)
+	if err != nil {
+		device.Close()
+		return nil, nil, fmt.Errorf("creating attestor: %w", err)
+	}
+
+	fmt.Printf("Attestation Key: %s\n", base64.StdEncoding.EncodeToString(attestor.PublicKey()))
+	fmt.Printf("Binary Hash: %x\n", binaryHash)
+
+	closeFunc := func() {
+		attestor.Close()
+		device.Close()
+	}
+	return decrypt.WithAttestor(attestor, binaryHash), closeFunc, nil
+}
+
+// healthPing checks, that the store responds before the context ends.
+func healthPing(ctx context.Context, backend decrypt.Store) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := backend.ListPolls()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("listing polls: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("store did not respond: %w", ctx.Err())
+	}
+}
+
+// crashAuditEntries is the number of audit entries in a crash report.
+const crashAuditEntries = 50
+
+// newCrashHandler returns the handler for crash reports and the sink, that
+// keeps the last entries of the audit log for them.
+func newCrashHandler(config Config) (*crash.Handler, *crash.Recent, error) {
+	effectiveConfig, err := json.Marshal(NewEffectiveConfig(config))
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding effective config: %w", err)
+	}
+	configHash := sha256.Sum256(effectiveConfig)
+
+	recent := crash.NewRecent(crashAuditEntries)
+	options := []crash.Option{
+		crash.WithConfigHash(hex.EncodeToString(configHash[:])),
+		crash.WithAuditEntries(recent),
+	}
+
+	if config.CrashDir != "" {
+		options = append(options, crash.WithDir(config.CrashDir))
+	}
+
+	if config.CrashKey != "" {
+		if config.CrashDir == "" {
+			return nil, nil, fmt.Errorf("the crash key needs a crash directory")
+		}
+
+		crashKey, err := base64.StdEncoding.DecodeString(config.CrashKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding crash key: %w", err)
+		}
+
+		sealer, err := crypto.NewSealer(crashKey, rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("crash key: %w", err)
+		}
+		options = append(options, crash.WithSealer(sealer))
+	}
+
+	return crash.New(options...), recent, nil
+}
+
+// executableHash returns the sha256 hash of the running binary.
+func executableHash() ([]byte, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("finding executable: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening executable: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, fmt.Errorf("reading executable: %w", err)
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,145 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	decryptgrpc "github.com/OpenSlides/vote-decrypt/grpc"
+	"github.com/OpenSlides/vote-decrypt/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunWithHooks(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "main.key")
+	if err := os.WriteFile(keyFile, make([]byte, 32), 0o600); err != nil {
+		t.Fatalf("writing main key: %v", err)
+	}
+
+	mainKey, err := os.Open(keyFile)
+	if err != nil {
+		t.Fatalf("opening main key: %v", err)
+	}
+	defer mainKey.Close()
+
+	port := freePort(t)
+
+	var interceptorCalls atomic.Int64
+	interceptor := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		interceptorCalls.Add(1)
+		return handler(ctx, req)
+	}
+
+	var storeCalls atomic.Int64
+	wrapper := func(s decrypt.Store) decrypt.Store {
+		return countingStore{Store: s, calls: &storeCalls}
+	}
+
+	validator := func(ctx context.Context, method string, req any) error {
+		if r, ok := req.(*decryptgrpc.StartRequest); ok && r.Id == "forbidden/1" {
+			return errors.New("poll id is forbidden")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Run(
+			ctx,
+			server.Config{
+				MainKey:     mainKey,
+				Port:        port,
+				StoreConfig: server.StoreConfig{Store: filepath.Join(dir, "store")},
+			},
+			server.WithUnaryInterceptors(interceptor),
+			server.WithStoreWrapper(wrapper),
+			server.WithValidators(validator),
+		)
+	}()
+
+	client, closeClient, err := decryptgrpc.NewClient(fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	defer closeClient()
+
+	waitForServer(t, client)
+
+	if _, _, err := client.Start(ctx, "allowed/1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if storeCalls.Load() == 0 {
+		t.Errorf("store wrapper was not called")
+	}
+
+	_, _, err = client.Start(ctx, "forbidden/1")
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("start of forbidden poll returned `%v`, expected code InvalidArgument", err)
+	}
+
+	// PublicMainKey from waitForServer and the allowed start. The forbidden
+	// start is rejected by the validator before the interceptor.
+	if got := interceptorCalls.Load(); got < 2 {
+		t.Errorf("interceptor was called %d times, expected at least 2", got)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Run did not return after the context was canceled")
+	}
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("finding free port: %v", err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+func waitForServer(t *testing.T, client *decryptgrpc.Client) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, err := client.PublicMainKey(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("server did not start")
+}
+
+type countingStore struct {
+	decrypt.Store
+	calls *atomic.Int64
+}
+
+func (s countingStore) LoadKey(id string) ([]byte, error) {
+	s.calls.Add(1)
+	return s.Store.LoadKey(id)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package server
+
+import (
+	"context"
+
+	"github.com/OpenSlides/vote-decrypt/loglevel"
+	"golang.org/x/sys/unix"
+)
+
+// toggleLogLevel switches the filter to debug and back on SIGUSR1.
+func toggleLogLevel(ctx context.Context, filter *loglevel.Filter) {
+	go filter.ToggleOnSignal(ctx, unix.SIGUSR1)
+}
@@ -0,0 +1,11 @@
+package server
+
+import (
+	"context"
+
+	"github.com/OpenSlides/vote-decrypt/loglevel"
+)
+
+// toggleLogLevel does nothing, because there is no SIGUSR1 on windows. The
+// level can be changed with the admin method SetLogLevel.
+func toggleLogLevel(ctx context.Context, filter *loglevel.Filter) {}
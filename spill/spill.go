@@ -0,0 +1,256 @@
+// Package spill writes data, that does not have to stay in memory, to an
+// encrypted temporary file.
+//
+// Each File uses its own random key, that only exists in the memory of the
+// process. The file can not be decrypted after the process ends, so a crash
+// does not leave decrypted votes on the disk.
+//
+// The data is split into chunks of 64 KiB. Each chunk is encrypted with
+// aes-gcm. The nonce is the number of the chunk and the last chunk is marked
+// in the additional data, so chunks can not be reordered, removed or appended.
+package spill
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// chunkSize is the size of the plaintext of one chunk.
+const chunkSize = 64 << 10
+
+// File is an encrypted temporary file. Data is written like to an io.Writer
+// and can be read with Reader() after all data is written.
+type File struct {
+	mu       sync.Mutex
+	file     *os.File
+	aead     cipher.AEAD
+	buf      []byte
+	chunks   uint64
+	size     int64
+	finished bool
+	err      error
+}
+
+// New creates an encrypted temporary file in dir. If dir is empty, the
+// default directory for temporary files is used.
+//
+// The file has to be removed with Close().
+func New(dir string) (*File, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("creating key: %w", err)
+	}
+	defer clear(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating gcm: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, "vote-decrypt-spill-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	return &File{
+		file: file,
+		aead: aead,
+		buf:  make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// Write appends data to the file. It fails after Reader() was called.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	if f.finished {
+		return 0, errors.New("file is already finished")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), chunkSize-len(f.buf))
+		f.buf = append(f.buf, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(f.buf) == chunkSize {
+			if err := f.writeChunk(false); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	f.size += int64(written)
+	return written, nil
+}
+
+// Size returns the number of bytes, that were written to the file.
+func (f *File) Size() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.size
+}
+
+// Reader finishes the file and returns a reader for its data. No more data
+// can be written afterwards. Each call returns a new reader from the start.
+func (f *File) Reader() (io.Reader, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if !f.finished {
+		if err := f.writeChunk(true); err != nil {
+			return nil, err
+		}
+		f.finished = true
+	}
+
+	return &reader{f: f, chunks: f.chunks}, nil
+}
+
+// Bytes finishes the file and returns all its data.
+func (f *File) Bytes() ([]byte, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, f.Size())
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("reading spill file: %w", err)
+	}
+	return data, nil
+}
+
+// Close removes the file. It can be called more then once.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	clear(f.buf)
+	name := f.file.Name()
+	closeErr := f.file.Close()
+	f.file = nil
+	f.err = errors.New("file is closed")
+
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("removing spill file: %w", err)
+	}
+	return closeErr
+}
+
+// writeChunk encrypts the buffer and appends it to the file. f.mu has to be
+// locked.
+func (f *File) writeChunk(last bool) error {
+	sealed := f.aead.Seal(nil, nonce(f.chunks), f.buf, additionalData(last))
+	clear(f.buf)
+	f.buf = f.buf[:0]
+
+	if _, err := f.file.Write(sealed); err != nil {
+		f.err = fmt.Errorf("writing spill file: %w", err)
+		return f.err
+	}
+	f.chunks++
+	return nil
+}
+
+// nonce returns the nonce of the chunk with the number n.
+func nonce(n uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], n)
+	return nonce
+}
+
+// additionalData marks the last chunk.
+func additionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// reader decrypts the chunks of a finished File one by one.
+type reader struct {
+	f      *File
+	chunks uint64
+	next   uint64
+	buf    []byte
+	sealed []byte
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.next == r.chunks {
+			return 0, io.EOF
+		}
+
+		if err := r.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// readChunk decrypts the next chunk into the buffer.
+func (r *reader) readChunk() error {
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+
+	if r.f.file == nil {
+		return errors.New("file is closed")
+	}
+
+	sealedSize := chunkSize + r.f.aead.Overhead()
+	last := r.next == r.chunks-1
+	if last {
+		sealedSize = int(r.f.size%chunkSize) + r.f.aead.Overhead()
+	}
+
+	if cap(r.sealed) < sealedSize {
+		r.sealed = make([]byte, sealedSize)
+	}
+	r.sealed = r.sealed[:sealedSize]
+
+	offset := int64(r.next) * int64(chunkSize+r.f.aead.Overhead())
+	if _, err := r.f.file.ReadAt(r.sealed, offset); err != nil {
+		return fmt.Errorf("reading chunk %d: %w", r.next, err)
+	}
+
+	plaintext, err := r.f.aead.Open(r.sealed[:0], nonce(r.next), r.sealed, additionalData(last))
+	if err != nil {
+		return fmt.Errorf("decrypting chunk %d: %w", r.next, err)
+	}
+
+	r.buf = plaintext
+	r.next++
+	return nil
+}
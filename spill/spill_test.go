@@ -0,0 +1,95 @@
+package spill_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/spill"
+)
+
+// spillFile returns the path of the only spill file in dir.
+func spillFile(t *testing.T, dir string) string {
+	t.Helper()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "vote-decrypt-spill-*"))
+	if len(files) != 1 {
+		t.Fatalf("got %d spill files, expected 1", len(files))
+	}
+	return files[0]
+}
+
+func TestFile(t *testing.T) {
+	for _, size := range []int{0, 1, 64 << 10, 64<<10 + 1, 200000} {
+		dir := t.TempDir()
+		f, err := spill.New(dir)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		data := bytes.Repeat([]byte(`"Y",`), size/4+1)[:size]
+		for rest := data; len(rest) > 0; {
+			n := min(len(rest), 10000)
+			if _, err := f.Write(rest[:n]); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			rest = rest[n:]
+		}
+
+		got, err := f.Bytes()
+		if err != nil {
+			t.Fatalf("size %d: Bytes: %v", size, err)
+		}
+
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: got other data back", size)
+		}
+
+		if size > 0 {
+			onDisk, _ := os.ReadFile(spillFile(t, dir))
+			if bytes.Contains(onDisk, []byte(`"Y","Y"`)) {
+				t.Errorf("size %d: file contains the plaintext", size)
+			}
+		}
+
+		if _, err := f.Write([]byte("more")); err == nil {
+			t.Errorf("size %d: Write after Bytes did not fail", size)
+		}
+
+		if err := f.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+
+		if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+			t.Errorf("size %d: file was not removed", size)
+		}
+	}
+}
+
+func TestFileTampered(t *testing.T) {
+	dir := t.TempDir()
+	f, err := spill.New(dir)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer f.Close()
+
+	f.Write(bytes.Repeat([]byte("x"), 100000))
+	r, err := f.Reader()
+	if err != nil {
+		t.Fatalf("Reader: %v", err)
+	}
+
+	file, err := os.OpenFile(spillFile(t, dir), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("opening file: %v", err)
+	}
+	file.WriteAt([]byte("changed"), 100)
+	file.Close()
+
+	if _, err := io.ReadAll(r); err == nil {
+		t.Errorf("reading a changed file did not fail")
+	}
+}
@@ -0,0 +1,254 @@
+// Package breaker implements a circuit breaker for a vote-decrypt storage
+// backend.
+//
+// If the backend fails, new polls are rejected immediately instead of waiting
+// for a timeout of the backend. Polls, that are already running, can still be
+// stopped.
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/metrics"
+)
+
+var (
+	metricOpen = metrics.NewGauge(
+		"vote_decrypt_store_breaker_open",
+		"1 if the circuit breaker of the store is open, 0 otherwise.",
+	)
+
+	metricRejected = metrics.NewCounter(
+		"vote_decrypt_store_breaker_rejected_total",
+		"Number of new poll keys, that were rejected by the circuit breaker of the store.",
+	)
+)
+
+// Store wraps a decrypt.Store.
+//
+// After threshold calls in a row failed, the breaker opens. While it is open,
+// SaveKey fails immediately with an error, that wraps errorcode.Unavailable.
+// All other calls are sent to the backend, so running polls can be stopped.
+//
+// While the breaker is open, ListPolls() of the backend is called every
+// cooldown as probe. The breaker closes with the first call, that succeeds.
+//
+// Errors of the decrypt.Store interface, like errorcode.NotExist, are not
+// failures of the backend.
+type Store struct {
+	store     decrypt.Store
+	threshold int
+	cooldown  time.Duration
+
+	onFailure func(failures int, err error)
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	probing  bool
+}
+
+// Option for New().
+type Option func(*Store)
+
+// WithFailureHook sets a function, that is called after each failed call of
+// the backend with the number of calls in a row, that failed. It is called
+// while the breaker is locked, so it must not call the Store.
+func WithFailureHook(hook func(failures int, err error)) Option {
+	return func(s *Store) {
+		s.onFailure = hook
+	}
+}
+
+// New initializes a Store.
+func New(store decrypt.Store, threshold int, cooldown time.Duration, options ...Option) *Store {
+	s := &Store{
+		store:     store,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// Open returns true, if the breaker is open.
+func (s *Store) Open() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.open
+}
+
+// record counts the result of a call to the backend and returns err.
+func (s *Store) record(err error) error {
+	var errCode errorcode.DecryptError
+	failed := err != nil && !errors.As(err, &errCode)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !failed {
+		s.failures = 0
+		if s.open {
+			s.open = false
+			metricOpen.Set(0)
+			log.Println("Store recovered, closing circuit breaker")
+		}
+		return err
+	}
+
+	s.failures++
+	if s.onFailure != nil {
+		s.onFailure(s.failures, err)
+	}
+
+	if !s.open && s.failures >= s.threshold {
+		s.open = true
+		metricOpen.Set(1)
+		log.Printf("Error: %d store calls in a row failed, opening circuit breaker: %v", s.failures, err)
+		if !s.probing {
+			s.probing = true
+			time.AfterFunc(s.cooldown, s.probe)
+		}
+	}
+	return err
+}
+
+// probe calls the backend, until the breaker is closed.
+func (s *Store) probe() {
+	_, err := s.store.ListPolls()
+	s.record(err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.open {
+		s.probing = false
+		return
+	}
+	time.AfterFunc(s.cooldown, s.probe)
+}
+
+// SaveKey stores the private key. It fails immediately, if the breaker is open.
+func (s *Store) SaveKey(id string, key []byte) error {
+	if s.Open() {
+		metricRejected.Inc()
+		return fmt.Errorf("store is failing, circuit breaker is open: %w", errorcode.Unavailable)
+	}
+	return s.record(s.store.SaveKey(id, key))
+}
+
+// LoadKey returns the private key from the backend.
+func (s *Store) LoadKey(id string) ([]byte, error) {
+	key, err := s.store.LoadKey(id)
+	return key, s.record(err)
+}
+
+// ValidateSignature calls ValidateSignature of the backend.
+func (s *Store) ValidateSignature(id string, hash []byte) error {
+	return s.record(s.store.ValidateSignature(id, hash))
+}
+
+// LoadHash returns the hash of the stop request from the backend, if it
+// implements decrypt.HashStore.
+func (s *Store) LoadHash(id string) ([]byte, error) {
+	hashStore, ok := s.store.(decrypt.HashStore)
+	if !ok {
+		return nil, fmt.Errorf("store can not load hashes: %w", errorcode.Unsupported)
+	}
+	hash, err := hashStore.LoadHash(id)
+	return hash, s.record(err)
+}
+
+// SaveMeta stores the meta data in the backend.
+func (s *Store) SaveMeta(id string, meta []byte) error {
+	return s.record(s.store.SaveMeta(id, meta))
+}
+
+// LoadMeta returns the meta data from the backend.
+func (s *Store) LoadMeta(id string) ([]byte, error) {
+	meta, err := s.store.LoadMeta(id)
+	return meta, s.record(err)
+}
+
+// SaveCommitment stores the commitment in the backend.
+func (s *Store) SaveCommitment(id string, leaves []byte) error {
+	return s.record(s.store.SaveCommitment(id, leaves))
+}
+
+// LoadCommitment returns the commitment from the backend.
+func (s *Store) LoadCommitment(id string) ([]byte, error) {
+	leaves, err := s.store.LoadCommitment(id)
+	return leaves, s.record(err)
+}
+
+// ClearPoll removes the poll from the backend.
+func (s *Store) ClearPoll(id string) error {
+	return s.record(s.store.ClearPoll(id))
+}
+
+// ListPolls returns the polls of the backend.
+func (s *Store) ListPolls() ([]string, error) {
+	ids, err := s.store.ListPolls()
+	return ids, s.record(err)
+}
+
+// ScheduleClear saves the schedule in the backend.
+func (s *Store) ScheduleClear(id string, at time.Time) error {
+	return s.record(s.store.ScheduleClear(id, at))
+}
+
+// ScheduledClears returns the schedules from the backend.
+func (s *Store) ScheduledClears() (map[string]time.Time, error) {
+	schedules, err := s.store.ScheduledClears()
+	return schedules, s.record(err)
+}
+
+// SaveRevocation saves the revocation in the backend, if it implements
+// decrypt.RevocationStore.
+func (s *Store) SaveRevocation(revocation []byte) error {
+	revocationStore, ok := s.store.(decrypt.RevocationStore)
+	if !ok {
+		return fmt.Errorf("store can not save revocations: %w", errorcode.Unsupported)
+	}
+	return s.record(revocationStore.SaveRevocation(revocation))
+}
+
+// Revocations returns the revocations from the backend.
+func (s *Store) Revocations() ([][]byte, error) {
+	revocationStore, ok := s.store.(decrypt.RevocationStore)
+	if !ok {
+		return nil, nil
+	}
+	revocations, err := revocationStore.Revocations()
+	return revocations, s.record(err)
+}
+
+// SaveReplacementKey saves the replacement key in the backend, if it
+// implements decrypt.RekeyStore.
+func (s *Store) SaveReplacementKey(id string, key []byte) error {
+	rekeyStore, ok := s.store.(decrypt.RekeyStore)
+	if !ok {
+		return fmt.Errorf("store can not save replacement keys: %w", errorcode.Unsupported)
+	}
+	return s.record(rekeyStore.SaveReplacementKey(id, key))
+}
+
+// LoadReplacementKeys returns the replacement keys from the backend.
+func (s *Store) LoadReplacementKeys(id string) ([][]byte, error) {
+	rekeyStore, ok := s.store.(decrypt.RekeyStore)
+	if !ok {
+		return nil, nil
+	}
+	keys, err := rekeyStore.LoadReplacementKeys(id)
+	return keys, s.record(err)
+}
@@ -0,0 +1,155 @@
+package breaker_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/store"
+	"github.com/OpenSlides/vote-decrypt/store/breaker"
+)
+
+// failingStore is a store, that fails all calls, while failing is set.
+type failingStore struct {
+	decrypt.Store
+	failing  atomic.Bool
+	saveKeys atomic.Int32
+}
+
+var errBackend = errors.New("backend is down")
+
+func (s *failingStore) SaveKey(id string, key []byte) error {
+	s.saveKeys.Add(1)
+	if s.failing.Load() {
+		return errBackend
+	}
+	return s.Store.SaveKey(id, key)
+}
+
+func (s *failingStore) LoadKey(id string) ([]byte, error) {
+	if s.failing.Load() {
+		return nil, errBackend
+	}
+	return s.Store.LoadKey(id)
+}
+
+func (s *failingStore) ListPolls() ([]string, error) {
+	if s.failing.Load() {
+		return nil, errBackend
+	}
+	return s.Store.ListPolls()
+}
+
+func TestStore(t *testing.T) {
+	backend := &failingStore{Store: store.New(t.TempDir())}
+	s := breaker.New(backend, 3, 10*time.Millisecond)
+
+	if err := s.SaveKey("test/1", []byte("key")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	t.Run("errors of the interface", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			if _, err := s.LoadKey("test/unknown"); !errors.Is(err, errorcode.NotExist) {
+				t.Fatalf("LoadKey of an unknown poll returned %v", err)
+			}
+		}
+
+		if s.Open() {
+			t.Errorf("breaker is open after NotExist errors")
+		}
+	})
+
+	t.Run("open", func(t *testing.T) {
+		backend.failing.Store(true)
+		for i := 0; i < 3; i++ {
+			if _, err := s.LoadKey("test/1"); !errors.Is(err, errBackend) {
+				t.Fatalf("LoadKey returned %v, expected the backend error", err)
+			}
+		}
+
+		if !s.Open() {
+			t.Fatalf("breaker is not open after 3 failures")
+		}
+
+		before := backend.saveKeys.Load()
+		if err := s.SaveKey("test/2", []byte("key")); !errors.Is(err, errorcode.Unavailable) {
+			t.Errorf("SaveKey returned %v, expected %v", err, errorcode.Unavailable)
+		}
+
+		if backend.saveKeys.Load() != before {
+			t.Errorf("SaveKey was sent to the backend")
+		}
+	})
+
+	t.Run("recover", func(t *testing.T) {
+		backend.failing.Store(false)
+
+		for i := 0; s.Open(); i++ {
+			if i > 500 {
+				t.Fatalf("breaker did not close after the backend recovered")
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		if err := s.SaveKey("test/2", []byte("key")); err != nil {
+			t.Errorf("SaveKey after recovery: %v", err)
+		}
+	})
+}
+
+func TestStoreRunningPolls(t *testing.T) {
+	backend := &failingStore{Store: store.New(t.TempDir())}
+	s := breaker.New(backend, 1, time.Hour)
+
+	if err := s.SaveKey("test/1", []byte("key")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	backend.failing.Store(true)
+	s.ListPolls()
+	backend.failing.Store(false)
+
+	if !s.Open() {
+		t.Fatalf("breaker is not open")
+	}
+
+	// The poll key can be loaded to stop a running poll. This closes the
+	// breaker without waiting for the probe.
+	key, err := s.LoadKey("test/1")
+	if err != nil {
+		t.Fatalf("LoadKey while the breaker is open: %v", err)
+	}
+
+	if string(key) != "key" {
+		t.Errorf("LoadKey returned %q, expected `key`", key)
+	}
+
+	if s.Open() {
+		t.Errorf("breaker is still open after a successful call")
+	}
+}
+
+func TestFailureHook(t *testing.T) {
+	backend := &failingStore{Store: store.New(t.TempDir())}
+
+	var failures []int
+	s := breaker.New(backend, 10, time.Hour, breaker.WithFailureHook(func(n int, err error) {
+		failures = append(failures, n)
+	}))
+
+	backend.failing.Store(true)
+	s.LoadKey("test/1")
+	s.LoadKey("test/1")
+	backend.failing.Store(false)
+	s.ListPolls()
+	backend.failing.Store(true)
+	s.LoadKey("test/1")
+
+	if len(failures) != 3 || failures[1] != 2 || failures[2] != 1 {
+		t.Errorf("hook was called with %v, expected [1 2 1]", failures)
+	}
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Compacter is implemented by stores, that can remove the leftovers of
+// removed polls.
+type Compacter interface {
+	Compact() (CompactReport, error)
+}
+
+// CompactReport is the result of Compact().
+type CompactReport struct {
+	// StaleEntries is the number of index entries, that were removed, because
+	// the directory of the poll did not exist or was empty.
+	StaleEntries int
+
+	// EmptyDirs is the number of empty directories, that were removed.
+	EmptyDirs int
+
+	// Orphans are directories of polls, that are not in the index. They are
+	// not removed, because they could contain poll keys.
+	Orphans []string
+
+	// ReclaimedBytes is the size of the removed files.
+	ReclaimedBytes int64
+}
+
+// Add adds the values of another report.
+func (r CompactReport) Add(other CompactReport) CompactReport {
+	return CompactReport{
+		StaleEntries:   r.StaleEntries + other.StaleEntries,
+		EmptyDirs:      r.EmptyDirs + other.EmptyDirs,
+		Orphans:        append(r.Orphans, other.Orphans...),
+		ReclaimedBytes: r.ReclaimedBytes + other.ReclaimedBytes,
+	}
+}
+
+// Compact removes index entries of polls without files, empty directories and
+// unfinished index updates. It does not remove polls, that are scheduled to be
+// removed. Use decrypt.ClearScheduled() for them.
+//
+// Compact can be called while the store is in use by the same process.
+func (s *Store) Compact() (CompactReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var report CompactReport
+
+	if err := s.migrate(); err != nil {
+		return report, fmt.Errorf("migrating store: %w", err)
+	}
+
+	tmpFile := filepath.Join(s.path, indexFile+".tmp")
+	if info, err := os.Stat(tmpFile); err == nil {
+		if err := os.Remove(tmpFile); err != nil {
+			return report, fmt.Errorf("removing unfinished index: %w", err)
+		}
+		report.ReclaimedBytes += info.Size()
+	}
+
+	var oldIndexSize int64
+	if info, err := os.Stat(filepath.Join(s.path, indexFile)); err == nil {
+		oldIndexSize = info.Size()
+	}
+
+	index, err := s.readIndex()
+	if err != nil {
+		return report, fmt.Errorf("reading index: %w", err)
+	}
+
+	changed := false
+	for hash, id := range index {
+		if pollHash(id) != hash {
+			delete(index, hash)
+			report.StaleEntries++
+			changed = true
+			continue
+		}
+
+		entries, err := os.ReadDir(s.PollDir(id))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return report, fmt.Errorf("reading dir of poll %s: %w", id, err)
+		}
+
+		if len(entries) > 0 {
+			continue
+		}
+
+		if err == nil {
+			if err := os.Remove(s.PollDir(id)); err != nil {
+				return report, fmt.Errorf("removing dir of poll %s: %w", id, err)
+			}
+			report.EmptyDirs++
+		}
+
+		delete(index, hash)
+		report.StaleEntries++
+		changed = true
+	}
+
+	if changed {
+		if err := s.writeIndex(index); err != nil {
+			return report, fmt.Errorf("writing index: %w", err)
+		}
+
+		if info, err := os.Stat(filepath.Join(s.path, indexFile)); err == nil && info.Size() < oldIndexSize {
+			report.ReclaimedBytes += oldIndexSize - info.Size()
+		}
+	}
+
+	if err := s.compactFanOut(index, &report); err != nil {
+		return report, fmt.Errorf("compacting directories: %w", err)
+	}
+
+	return report, nil
+}
+
+// compactFanOut walks the two levels of the fan-out and the poll directories.
+// Empty directories are removed. Poll directories with files, that are not in
+// the index, are reported as orphans.
+func (s *Store) compactFanOut(index map[string]string, report *CompactReport) error {
+	first, err := subDirs(s.path, 2)
+	if err != nil {
+		return err
+	}
+
+	for _, firstDir := range first {
+		second, err := subDirs(firstDir, 2)
+		if err != nil {
+			return err
+		}
+
+		for _, secondDir := range second {
+			pollDirs, err := subDirs(secondDir, sha256.Size*2)
+			if err != nil {
+				return err
+			}
+
+			for _, pollDir := range pollDirs {
+				if _, ok := index[filepath.Base(pollDir)]; ok {
+					continue
+				}
+
+				removed, err := removeEmptyDir(pollDir)
+				if err != nil {
+					return err
+				}
+
+				if removed {
+					report.EmptyDirs++
+					continue
+				}
+				report.Orphans = append(report.Orphans, pollDir)
+			}
+
+			if removed, err := removeEmptyDir(secondDir); err != nil {
+				return err
+			} else if removed {
+				report.EmptyDirs++
+			}
+		}
+
+		if removed, err := removeEmptyDir(firstDir); err != nil {
+			return err
+		} else if removed {
+			report.EmptyDirs++
+		}
+	}
+
+	return nil
+}
+
+// subDirs returns the paths of the directories in dir, whose names have the
+// given length. Other directories do not belong to the store.
+func subDirs(dir string, nameLen int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading dir: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && len(entry.Name()) == nameLen {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// removeEmptyDir removes dir, if it is empty.
+func removeEmptyDir(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, fmt.Errorf("reading dir: %w", err)
+	}
+
+	if len(entries) > 0 {
+		return false, nil
+	}
+
+	if err := os.Remove(dir); err != nil {
+		return false, fmt.Errorf("removing dir: %w", err)
+	}
+	return true, nil
+}
+
+// Usage returns the size of all files in the store in bytes.
+func (s *Store) Usage() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var size int64
+	err := filepath.WalkDir(s.path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("walking data dir: %w", err)
+	}
+
+	return size, nil
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package store
+
+import (
+	"fmt"
+	"os"
+)
+
+// rename replaces newpath with oldpath atomically.
+func rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// syncDir syncs a directory, so that created and renamed files in it are on
+// the disk.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open dir: %w", err)
+	}
+	defer f.Close()
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("syncing dir: %w", err)
+	}
+	return nil
+}
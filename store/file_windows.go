@@ -0,0 +1,52 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// renameRetries is how often a rename is tried, when the target is opened by
+// another process.
+const renameRetries = 10
+
+// rename replaces newpath with oldpath.
+//
+// On NTFS, MoveFileEx with MOVEFILE_WRITE_THROUGH only returns after the
+// rename is flushed to the disk. Virus scanners and the search indexer open
+// new files for a short time, so the rename fails with an access denied or
+// sharing violation error. In this case, it is tried again.
+func rename(oldpath, newpath string) error {
+	from, err := windows.UTF16PtrFromString(oldpath)
+	if err != nil {
+		return fmt.Errorf("encoding path: %w", err)
+	}
+
+	to, err := windows.UTF16PtrFromString(newpath)
+	if err != nil {
+		return fmt.Errorf("encoding path: %w", err)
+	}
+
+	for i := 0; ; i++ {
+		err = windows.MoveFileEx(from, to, windows.MOVEFILE_REPLACE_EXISTING|windows.MOVEFILE_WRITE_THROUGH)
+		if err == nil {
+			return nil
+		}
+
+		retry := errors.Is(err, windows.ERROR_ACCESS_DENIED) || errors.Is(err, windows.ERROR_SHARING_VIOLATION)
+		if !retry || i >= renameRetries {
+			return fmt.Errorf("moving %s to %s: %w", oldpath, newpath, err)
+		}
+
+		time.Sleep(time.Duration(i+1) * 10 * time.Millisecond)
+	}
+}
+
+// syncDir does nothing. Directories can not be synced on Windows. NTFS writes
+// the metadata of the directory with the journal and rename() uses
+// MOVEFILE_WRITE_THROUGH.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,58 @@
+package store_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/store"
+	"github.com/OpenSlides/vote-decrypt/store/integrity"
+)
+
+// FuzzRecords writes arbitrary content into the files of the store and reads
+// them. It only checks, that a corrupted store does not panic. Run it with
+// `go test ./store -fuzz FuzzRecords`.
+func FuzzRecords(f *testing.F) {
+	f.Add([]byte(`{}`), []byte("2024-05-01T12:00:00Z"), []byte(`["a2V5"]`), []byte(`["cmV2b2tlZA=="]`))
+	f.Add([]byte(`{"ab":"1"}`), []byte{}, []byte(`null`), []byte(`[null]`))
+
+	f.Fuzz(func(t *testing.T, index, clear, rekey, revocations []byte) {
+		tmpPath := t.TempDir()
+		s := store.New(tmpPath)
+		if err := s.SaveKey("1", []byte("key")); err != nil {
+			t.Fatalf("SaveKey: %v", err)
+		}
+
+		for name, content := range map[string][]byte{
+			path.Join(tmpPath, "index.json"):       index,
+			path.Join(tmpPath, "revocations.json"): revocations,
+			path.Join(s.PollDir("1"), "clear"):     clear,
+			path.Join(s.PollDir("1"), "rekey"):     rekey,
+			path.Join(s.PollDir("1"), "meta"):      rekey,
+		} {
+			if err := os.WriteFile(name, content, 0600); err != nil {
+				t.Fatalf("writing file: %v", err)
+			}
+		}
+
+		sealed := integrity.New(s, []byte("integrity-key"))
+		for _, backend := range []interface {
+			LoadKey(id string) ([]byte, error)
+			LoadMeta(id string) ([]byte, error)
+			ListPolls() ([]string, error)
+			Revocations() ([][]byte, error)
+			LoadReplacementKeys(id string) ([][]byte, error)
+		}{s, sealed} {
+			backend.LoadKey("1")
+			backend.LoadMeta("1")
+			backend.ListPolls()
+			backend.Revocations()
+			backend.LoadReplacementKeys("1")
+		}
+
+		s.ScheduledClears()
+		s.Compact()
+		s.Usage()
+		sealed.Verify()
+	})
+}
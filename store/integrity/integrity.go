@@ -0,0 +1,273 @@
+// Package integrity protects the records of a vote-decrypt storage backend
+// against tampering and bit rot.
+package integrity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/store"
+)
+
+// tagSize is the size of the hmac that is appended to each record.
+const tagSize = sha256.Size
+
+// ErrTampered is returned, if the hmac of a record is invalid.
+var ErrTampered = errors.New("record was modified")
+
+// Store wraps a decrypt.Store and appends an hmac-sha256 to each record.
+//
+// The hmac is created over the kind of the record, the poll id and the value.
+// So a record can not be modified or moved to another poll without the key.
+// Records with an invalid hmac return an error, that wraps ErrTampered.
+//
+// The key, the replacement keys, the meta data, the hash of the first stop
+// request, the commitment and the revocations are protected. The scheduled removals are not.
+type Store struct {
+	store decrypt.Store
+	key   []byte
+}
+
+// New initializes a Store. The key should be 32 random bytes.
+func New(store decrypt.Store, key []byte) *Store {
+	return &Store{
+		store: store,
+		key:   key,
+	}
+}
+
+// tag returns the hmac for a record.
+func (s *Store) tag(kind, id string, value []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(id))
+	mac.Write([]byte{0})
+	mac.Write(value)
+	return mac.Sum(nil)
+}
+
+// seal appends the hmac to the value.
+func (s *Store) seal(kind, id string, value []byte) []byte {
+	sealed := make([]byte, 0, len(value)+tagSize)
+	sealed = append(sealed, value...)
+	return append(sealed, s.tag(kind, id, value)...)
+}
+
+// open checks the hmac of a record and returns the value without it.
+func (s *Store) open(kind, id string, sealed []byte) ([]byte, error) {
+	if len(sealed) < tagSize {
+		return nil, fmt.Errorf("%s of poll %s: %w", kind, id, ErrTampered)
+	}
+
+	value := sealed[:len(sealed)-tagSize]
+	if !hmac.Equal(sealed[len(sealed)-tagSize:], s.tag(kind, id, value)) {
+		return nil, fmt.Errorf("%s of poll %s: %w", kind, id, ErrTampered)
+	}
+	return value, nil
+}
+
+// SaveKey stores the private key with its hmac.
+func (s *Store) SaveKey(id string, key []byte) error {
+	return s.store.SaveKey(id, s.seal("key", id, key))
+}
+
+// LoadKey returns the private key, if its hmac is valid.
+func (s *Store) LoadKey(id string) ([]byte, error) {
+	sealed, err := s.store.LoadKey(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.open("key", id, sealed)
+}
+
+// ValidateSignature calls ValidateSignature of the wrapped store with the
+// hash and its hmac. A modified hash in the store leads to errorcode.Invalid.
+func (s *Store) ValidateSignature(id string, hash []byte) error {
+	return s.store.ValidateSignature(id, s.seal("hash", id, hash))
+}
+
+// LoadHash returns the hash of the stop request, if its hmac is valid and the
+// wrapped store implements decrypt.HashStore.
+func (s *Store) LoadHash(id string) ([]byte, error) {
+	hashStore, ok := s.store.(decrypt.HashStore)
+	if !ok {
+		return nil, fmt.Errorf("store can not load hashes: %w", errorcode.Unsupported)
+	}
+
+	sealed, err := hashStore.LoadHash(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.open("hash", id, sealed)
+}
+
+// SaveMeta stores the meta data with its hmac.
+func (s *Store) SaveMeta(id string, meta []byte) error {
+	return s.store.SaveMeta(id, s.seal("meta", id, meta))
+}
+
+// LoadMeta returns the meta data, if its hmac is valid.
+func (s *Store) LoadMeta(id string) ([]byte, error) {
+	sealed, err := s.store.LoadMeta(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.open("meta", id, sealed)
+}
+
+// SaveCommitment stores the commitment with its hmac.
+func (s *Store) SaveCommitment(id string, leaves []byte) error {
+	return s.store.SaveCommitment(id, s.seal("commitment", id, leaves))
+}
+
+// LoadCommitment returns the commitment, if its hmac is valid.
+func (s *Store) LoadCommitment(id string) ([]byte, error) {
+	sealed, err := s.store.LoadCommitment(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.open("commitment", id, sealed)
+}
+
+// ClearPoll removes all data for the poll.
+func (s *Store) ClearPoll(id string) error {
+	return s.store.ClearPoll(id)
+}
+
+// ListPolls returns the ids of all polls in the store.
+func (s *Store) ListPolls() ([]string, error) {
+	return s.store.ListPolls()
+}
+
+// ScheduleClear saves the time when the poll should be removed.
+func (s *Store) ScheduleClear(id string, at time.Time) error {
+	return s.store.ScheduleClear(id, at)
+}
+
+// ScheduledClears returns all polls that are scheduled to be removed.
+func (s *Store) ScheduledClears() (map[string]time.Time, error) {
+	return s.store.ScheduledClears()
+}
+
+// Compact calls Compact of the wrapped store, if it implements
+// store.Compacter.
+func (s *Store) Compact() (store.CompactReport, error) {
+	compacter, ok := s.store.(store.Compacter)
+	if !ok {
+		return store.CompactReport{}, nil
+	}
+	return compacter.Compact()
+}
+
+// SaveReplacementKey stores the replacement key with its hmac, if the wrapped
+// store implements decrypt.RekeyStore.
+func (s *Store) SaveReplacementKey(id string, key []byte) error {
+	rekeyStore, ok := s.store.(decrypt.RekeyStore)
+	if !ok {
+		return fmt.Errorf("store can not save replacement keys: %w", errorcode.Unsupported)
+	}
+	return rekeyStore.SaveReplacementKey(id, s.seal("rekey", id, key))
+}
+
+// LoadReplacementKeys returns the replacement keys, if all hmacs are valid.
+func (s *Store) LoadReplacementKeys(id string) ([][]byte, error) {
+	rekeyStore, ok := s.store.(decrypt.RekeyStore)
+	if !ok {
+		return nil, nil
+	}
+
+	sealed, err := rekeyStore.LoadReplacementKeys(id)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]byte, len(sealed))
+	for i, value := range sealed {
+		key, err := s.open("rekey", id, value)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// SaveRevocation stores the revocation with its hmac, if the wrapped store
+// implements decrypt.RevocationStore.
+func (s *Store) SaveRevocation(revocation []byte) error {
+	revocationStore, ok := s.store.(decrypt.RevocationStore)
+	if !ok {
+		return fmt.Errorf("store can not save revocations: %w", errorcode.Unsupported)
+	}
+	return revocationStore.SaveRevocation(s.seal("revocation", "", revocation))
+}
+
+// Revocations returns the revocations, if all hmacs are valid.
+func (s *Store) Revocations() ([][]byte, error) {
+	revocationStore, ok := s.store.(decrypt.RevocationStore)
+	if !ok {
+		return nil, nil
+	}
+
+	sealed, err := revocationStore.Revocations()
+	if err != nil {
+		return nil, err
+	}
+
+	revocations := make([][]byte, len(sealed))
+	for i, value := range sealed {
+		revocation, err := s.open("revocation", "", value)
+		if err != nil {
+			return nil, fmt.Errorf("revocation %d: %w", i, ErrTampered)
+		}
+		revocations[i] = revocation
+	}
+	return revocations, nil
+}
+
+// PollStatus is the integrity status of one poll.
+type PollStatus struct {
+	ID string
+
+	// Err is nil, if all records of the poll are valid.
+	Err error
+}
+
+// Verify checks the key and the meta data of all polls in the store.
+//
+// It returns an error, if the polls can not be listed or a record can not be
+// read for another reason then tampering.
+func (s *Store) Verify() ([]PollStatus, error) {
+	ids, err := s.store.ListPolls()
+	if err != nil {
+		return nil, fmt.Errorf("listing polls: %w", err)
+	}
+
+	statuses := make([]PollStatus, len(ids))
+	for i, id := range ids {
+		statuses[i] = PollStatus{ID: id}
+
+		if _, err := s.LoadKey(id); err != nil && !errors.Is(err, errorcode.NotExist) {
+			if !errors.Is(err, ErrTampered) {
+				return nil, fmt.Errorf("loading key of poll %s: %w", id, err)
+			}
+			statuses[i].Err = err
+			continue
+		}
+
+		if _, err := s.LoadMeta(id); err != nil && !errors.Is(err, errorcode.NotExist) {
+			if !errors.Is(err, ErrTampered) {
+				return nil, fmt.Errorf("loading meta of poll %s: %w", id, err)
+			}
+			statuses[i].Err = err
+		}
+	}
+
+	return statuses, nil
+}
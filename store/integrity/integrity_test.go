@@ -0,0 +1,92 @@
+package integrity_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/store"
+	"github.com/OpenSlides/vote-decrypt/store/integrity"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	fileStore := store.New(dir)
+	s := integrity.New(fileStore, []byte("integrity-key"))
+
+	for _, id := range []string{"test/1", "test/2"} {
+		if err := s.SaveKey(id, []byte("key of "+id)); err != nil {
+			t.Fatalf("SaveKey: %v", err)
+		}
+
+		if err := s.SaveMeta(id, []byte("meta of "+id)); err != nil {
+			t.Fatalf("SaveMeta: %v", err)
+		}
+	}
+
+	key, err := s.LoadKey("test/1")
+	if err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+
+	if string(key) != "key of test/1" {
+		t.Errorf("LoadKey returned %q, expected `key of test/1`", key)
+	}
+
+	if err := s.ValidateSignature("test/1", []byte("hash")); err != nil {
+		t.Fatalf("ValidateSignature: %v", err)
+	}
+
+	if err := s.ValidateSignature("test/1", []byte("hash")); err != nil {
+		t.Errorf("second ValidateSignature: %v", err)
+	}
+
+	statuses, err := s.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	for _, status := range statuses {
+		if status.Err != nil {
+			t.Errorf("poll %s is invalid: %v", status.ID, status.Err)
+		}
+	}
+
+	// Replace the meta data of test/2 with the one of test/1.
+	meta, err := os.ReadFile(filepath.Join(fileStore.PollDir("test/1"), "meta"))
+	if err != nil {
+		t.Fatalf("reading meta file: %v", err)
+	}
+
+	metaFile := filepath.Join(fileStore.PollDir("test/2"), "meta")
+	if err := os.Remove(metaFile); err != nil {
+		t.Fatalf("removing meta file: %v", err)
+	}
+
+	if err := os.WriteFile(metaFile, meta, 0o400); err != nil {
+		t.Fatalf("writing meta file: %v", err)
+	}
+
+	if _, err := s.LoadMeta("test/2"); !errors.Is(err, integrity.ErrTampered) {
+		t.Errorf("LoadMeta of moved record returned `%v`, expected `%v`", err, integrity.ErrTampered)
+	}
+
+	statuses, err = s.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	got := make(map[string]error)
+	for _, status := range statuses {
+		got[status.ID] = status.Err
+	}
+
+	if got["test/1"] != nil {
+		t.Errorf("poll test/1 is invalid: %v", got["test/1"])
+	}
+
+	if !errors.Is(got["test/2"], integrity.ErrTampered) {
+		t.Errorf("poll test/2 has status `%v`, expected `%v`", got["test/2"], integrity.ErrTampered)
+	}
+}
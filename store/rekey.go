@@ -0,0 +1,65 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+)
+
+// rekeyFile is the name of the file of a poll, that contains the replacement
+// keys.
+const rekeyFile = "rekey"
+
+// SaveReplacementKey appends a replacement key to the file `rekey` of the
+// poll.
+func (s *Store) SaveReplacementKey(id string, key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.loadReplacementKeys(id)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(append(keys, key))
+	if err != nil {
+		return fmt.Errorf("encoding replacement keys: %w", err)
+	}
+
+	if err := s.createPollDir(id); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.PollDir(id), rekeyFile), content); err != nil {
+		return fmt.Errorf("writing replacement keys: %w", err)
+	}
+	return nil
+}
+
+// LoadReplacementKeys returns the replacement keys of a poll in the order,
+// they were saved.
+func (s *Store) LoadReplacementKeys(id string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.loadReplacementKeys(id)
+}
+
+func (s *Store) loadReplacementKeys(id string) ([][]byte, error) {
+	content, err := s.readFile(id, rekeyFile)
+	if err != nil {
+		if errors.Is(err, errorcode.NotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var keys [][]byte
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return nil, fmt.Errorf("decoding replacement keys: %w", err)
+	}
+	return keys, nil
+}
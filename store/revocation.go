@@ -0,0 +1,62 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// revocationFile is the name of the file, that contains the revoked poll keys.
+// It is not part of a poll directory, so it is kept, when a poll is removed.
+const revocationFile = "revocations.json"
+
+// SaveRevocation appends a revocation to the file `revocations.json`.
+func (s *Store) SaveRevocation(revocation []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revocations, err := s.readRevocations()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(append(revocations, revocation))
+	if err != nil {
+		return fmt.Errorf("encoding revocations: %w", err)
+	}
+
+	if err := os.MkdirAll(s.path, os.ModePerm); err != nil {
+		return fmt.Errorf("creating data dir `%s`: %w", s.path, err)
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.path, revocationFile), content); err != nil {
+		return fmt.Errorf("writing revocations: %w", err)
+	}
+	return nil
+}
+
+// Revocations returns all revocations in the order, they were saved.
+func (s *Store) Revocations() ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.readRevocations()
+}
+
+func (s *Store) readRevocations() ([][]byte, error) {
+	content, err := os.ReadFile(filepath.Join(s.path, revocationFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading revocations: %w", err)
+	}
+
+	var revocations [][]byte
+	if err := json.Unmarshal(content, &revocations); err != nil {
+		return nil, fmt.Errorf("decoding revocations: %w", err)
+	}
+	return revocations, nil
+}
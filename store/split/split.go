@@ -0,0 +1,198 @@
+// Package split is a storage backend for vote-decrypt that saves the secret
+// poll keys and the other data of the polls in different backends.
+package split
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/decrypt"
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/store"
+)
+
+// Store implements the decrypt.Store interface by using one backend for the
+// poll keys and another backend for the state of the polls.
+//
+// The key backend only contains the private keys. The state backend contains
+// the meta data, the hash of the first stop request, the scheduled removals
+// and the commitments. So the two backends can have different security and query
+// requirements, for example vault for the keys and a database for the state.
+//
+// For each poll, an empty key is saved in the state backend. It marks, that
+// the poll exists. The state backend never sees a private key.
+type Store struct {
+	keys  decrypt.Store
+	state decrypt.Store
+}
+
+// New initializes a new Store.
+func New(keys, state decrypt.Store) *Store {
+	return &Store{
+		keys:  keys,
+		state: state,
+	}
+}
+
+// SaveKey stores the private key in the key backend and a marker in the state
+// backend.
+//
+// Returns errorcode.Exist, if the key already exists in the key backend.
+func (s *Store) SaveKey(id string, key []byte) error {
+	if err := s.keys.SaveKey(id, key); err != nil {
+		return err
+	}
+
+	if err := s.state.SaveKey(id, []byte{}); err != nil && !errors.Is(err, errorcode.Exist) {
+		return fmt.Errorf("saving marker in state store: %w", err)
+	}
+
+	return nil
+}
+
+// LoadKey returns the private key from the key backend.
+func (s *Store) LoadKey(id string) ([]byte, error) {
+	return s.keys.LoadKey(id)
+}
+
+// ValidateSignature makes sure, that no other signature is saved for a poll.
+// It uses the state backend.
+func (s *Store) ValidateSignature(id string, hash []byte) error {
+	return s.state.ValidateSignature(id, hash)
+}
+
+// LoadHash returns the hash of the stop request from the state backend, if it
+// implements decrypt.HashStore.
+func (s *Store) LoadHash(id string) ([]byte, error) {
+	hashStore, ok := s.state.(decrypt.HashStore)
+	if !ok {
+		return nil, fmt.Errorf("state backend can not load hashes: %w", errorcode.Unsupported)
+	}
+	return hashStore.LoadHash(id)
+}
+
+// SaveMeta stores the meta data in the state backend.
+func (s *Store) SaveMeta(id string, meta []byte) error {
+	return s.state.SaveMeta(id, meta)
+}
+
+// LoadMeta returns the meta data from the state backend.
+func (s *Store) LoadMeta(id string) ([]byte, error) {
+	return s.state.LoadMeta(id)
+}
+
+// ClearPoll removes the data of the poll from both backends. The key is
+// removed first.
+func (s *Store) ClearPoll(id string) error {
+	if err := s.keys.ClearPoll(id); err != nil {
+		return fmt.Errorf("clearing key store: %w", err)
+	}
+
+	if err := s.state.ClearPoll(id); err != nil {
+		return fmt.Errorf("clearing state store: %w", err)
+	}
+
+	return nil
+}
+
+// ListPolls returns the ids of all polls in any of the backends.
+func (s *Store) ListPolls() ([]string, error) {
+	keyIDs, err := s.keys.ListPolls()
+	if err != nil {
+		return nil, fmt.Errorf("listing key store: %w", err)
+	}
+
+	stateIDs, err := s.state.ListPolls()
+	if err != nil {
+		return nil, fmt.Errorf("listing state store: %w", err)
+	}
+
+	seen := make(map[string]bool, len(keyIDs))
+	var ids []string
+	for _, id := range append(keyIDs, stateIDs...) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// ScheduleClear saves the time of the removal in the state backend.
+func (s *Store) ScheduleClear(id string, at time.Time) error {
+	return s.state.ScheduleClear(id, at)
+}
+
+// ScheduledClears returns the scheduled removals from the state backend.
+func (s *Store) ScheduledClears() (map[string]time.Time, error) {
+	return s.state.ScheduledClears()
+}
+
+// SaveCommitment stores the commitment in the state backend.
+func (s *Store) SaveCommitment(id string, leaves []byte) error {
+	return s.state.SaveCommitment(id, leaves)
+}
+
+// LoadCommitment returns the commitment from the state backend.
+func (s *Store) LoadCommitment(id string) ([]byte, error) {
+	return s.state.LoadCommitment(id)
+}
+
+// Compact compacts both backends, if they implement store.Compacter.
+func (s *Store) Compact() (store.CompactReport, error) {
+	var report store.CompactReport
+	for _, backend := range []decrypt.Store{s.keys, s.state} {
+		compacter, ok := backend.(store.Compacter)
+		if !ok {
+			continue
+		}
+
+		backendReport, err := compacter.Compact()
+		if err != nil {
+			return report, err
+		}
+		report = report.Add(backendReport)
+	}
+	return report, nil
+}
+
+// SaveRevocation saves the revocation in the state backend, if it implements
+// decrypt.RevocationStore.
+func (s *Store) SaveRevocation(revocation []byte) error {
+	revocationStore, ok := s.state.(decrypt.RevocationStore)
+	if !ok {
+		return fmt.Errorf("state backend can not save revocations: %w", errorcode.Unsupported)
+	}
+	return revocationStore.SaveRevocation(revocation)
+}
+
+// Revocations returns the revocations from the state backend.
+func (s *Store) Revocations() ([][]byte, error) {
+	revocationStore, ok := s.state.(decrypt.RevocationStore)
+	if !ok {
+		return nil, nil
+	}
+	return revocationStore.Revocations()
+}
+
+// SaveReplacementKey saves the replacement key in the key backend, if it
+// implements decrypt.RekeyStore.
+func (s *Store) SaveReplacementKey(id string, key []byte) error {
+	rekeyStore, ok := s.keys.(decrypt.RekeyStore)
+	if !ok {
+		return fmt.Errorf("key backend can not save replacement keys: %w", errorcode.Unsupported)
+	}
+	return rekeyStore.SaveReplacementKey(id, key)
+}
+
+// LoadReplacementKeys returns the replacement keys from the key backend.
+func (s *Store) LoadReplacementKeys(id string) ([][]byte, error) {
+	rekeyStore, ok := s.keys.(decrypt.RekeyStore)
+	if !ok {
+		return nil, nil
+	}
+	return rekeyStore.LoadReplacementKeys(id)
+}
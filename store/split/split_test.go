@@ -0,0 +1,83 @@
+package split_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/store"
+	"github.com/OpenSlides/vote-decrypt/store/split"
+)
+
+func TestStore(t *testing.T) {
+	keyDir := t.TempDir()
+	stateDir := t.TempDir()
+	keyStore := store.New(keyDir)
+	stateStore := store.New(stateDir)
+	s := split.New(keyStore, stateStore)
+
+	if err := s.SaveKey("test/1", []byte("secret")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	if err := s.SaveKey("test/1", []byte("secret")); !errors.Is(err, errorcode.Exist) {
+		t.Errorf("second SaveKey returned `%v`, expected `%v`", err, errorcode.Exist)
+	}
+
+	if err := s.SaveMeta("test/1", []byte("meta")); err != nil {
+		t.Fatalf("SaveMeta: %v", err)
+	}
+
+	if err := s.ValidateSignature("test/1", []byte("hash")); err != nil {
+		t.Fatalf("ValidateSignature: %v", err)
+	}
+
+	if err := s.ValidateSignature("test/2", []byte("hash")); !errors.Is(err, errorcode.NotExist) {
+		t.Errorf("ValidateSignature of unknown poll returned `%v`, expected `%v`", err, errorcode.NotExist)
+	}
+
+	key, err := s.LoadKey("test/1")
+	if err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+
+	if string(key) != "secret" {
+		t.Errorf("LoadKey returned %q, expected secret", key)
+	}
+
+	stateKey, err := os.ReadFile(filepath.Join(stateStore.PollDir("test/1"), "key"))
+	if err != nil {
+		t.Fatalf("reading marker: %v", err)
+	}
+
+	if len(stateKey) != 0 {
+		t.Errorf("state store contains key %q, expected an empty marker", stateKey)
+	}
+
+	if _, err := os.Stat(filepath.Join(keyStore.PollDir("test/1"), "meta")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("meta data was saved in the key store")
+	}
+
+	ids, err := s.ListPolls()
+	if err != nil {
+		t.Fatalf("ListPolls: %v", err)
+	}
+
+	if len(ids) != 1 || ids[0] != "test/1" {
+		t.Errorf("ListPolls returned %v, expected [test/1]", ids)
+	}
+
+	if err := s.ClearPoll("test/1"); err != nil {
+		t.Fatalf("ClearPoll: %v", err)
+	}
+
+	if _, err := s.LoadKey("test/1"); !errors.Is(err, errorcode.NotExist) {
+		t.Errorf("LoadKey after ClearPoll returned `%v`, expected `%v`", err, errorcode.NotExist)
+	}
+
+	if _, err := s.LoadMeta("test/1"); !errors.Is(err, errorcode.NotExist) {
+		t.Errorf("LoadMeta after ClearPoll returned `%v`, expected `%v`", err, errorcode.NotExist)
+	}
+}
@@ -3,13 +3,18 @@
 package store
 
 import (
+	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/OpenSlides/vote-decrypt/errorcode"
 )
@@ -20,18 +25,38 @@ import (
 // save. If more then one process is running, it depends on the features of the
 // filesystem.
 //
-// For each poll, two files are created. `POLLID_key` that contains the private
-// key for the poll and `POLLID_hash` the contains the hash of the first stop
-// request.
+// Each poll gets its own directory. Its name is the hex encoded sha256 hash of
+// the poll id. So the poll id is never used as a file name. The directories are
+// spread over two levels of subdirectories by the first four characters of the
+// hash, for example `PATH/3f/a1/3fa1.../`. This keeps the number of entries
+// per directory small, even with thousands of polls. The file `index.json`
+// maps the hashes to the poll ids.
+//
+// In the directory of a poll, three files are created. `key` that contains the
+// private key for the poll, `meta` that contains the meta data of the poll and
+// `hash` the contains the hash of the first stop request. If the removal of a
+// poll is scheduled, the time is saved in `clear`. The leaves of the merkle
+// tree over the votes are saved in `commitment` and the replacement keys of a
+// rekeyed poll in `rekey`.
+//
+// Files of the old flat layout (`POLLID.key`, ...) are moved into the new
+// layout on the first access.
 //
 // TODO: Think about timing attacks when files do not exist or have wrong
 // content.
 type Store struct {
 	mu sync.Mutex
 
-	path string
+	path     string
+	migrated bool
 }
 
+// indexFile is the name of the file, that maps the hashes to the poll ids.
+const indexFile = "index.json"
+
+// pollFiles are the names of the files of a poll.
+var pollFiles = []string{"key", "meta", "hash", "clear", "commitment", "rekey"}
+
 // New initializes a new Store.
 func New(path string) *Store {
 	return &Store{
@@ -39,6 +64,17 @@ func New(path string) *Store {
 	}
 }
 
+// PollDir returns the directory, that contains the files of the poll.
+func (s *Store) PollDir(id string) string {
+	hash := pollHash(id)
+	return filepath.Join(s.path, hash[0:2], hash[2:4], hash)
+}
+
+func pollHash(id string) string {
+	hash := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(hash[:])
+}
+
 // SaveKey stores the private key.
 //
 // Has to return an error, if a key already exists.
@@ -46,15 +82,28 @@ func (s *Store) SaveKey(id string, key []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.path == "" {
-		return fmt.Errorf("No data dir provided. Check the environment variable VOTE_DECRYPT_STORE")
-	}
+	return s.createFile(id, "key", key)
+}
 
-	if err := os.MkdirAll(s.path, os.ModePerm); err != nil {
-		return fmt.Errorf("creating data dir `%s`: %w", s.path, err)
+// SaveMeta stores the meta data of a poll.
+//
+// Has to return an error, if the meta data already exists.
+func (s *Store) SaveMeta(id string, meta []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createFile(id, "meta", meta)
+}
+
+// createFile creates a new read only file of a poll with the given content.
+//
+// Returns errorcode.Exist, if the file already exists.
+func (s *Store) createFile(id string, name string, content []byte) (err error) {
+	if err := s.createPollDir(id); err != nil {
+		return err
 	}
 
-	f, err := os.OpenFile(s.keyFile(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+	f, err := os.OpenFile(filepath.Join(s.PollDir(id), name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
 	if err != nil {
 		if errors.Is(err, os.ErrExist) {
 			return errorcode.Exist
@@ -64,33 +113,229 @@ func (s *Store) SaveKey(id string, key []byte) error {
 
 	defer func() {
 		if cErr := f.Close(); err == nil && cErr != nil {
-			err = fmt.Errorf("closing file: %w", err)
+			err = fmt.Errorf("closing file: %w", cErr)
 		}
 	}()
 
-	if _, err := f.Write(key); err != nil {
-		return fmt.Errorf("writing key: %w", err)
+	if _, err := f.Write(content); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("syncing file: %w", err)
+	}
+
+	return syncDir(s.PollDir(id))
+}
+
+// createPollDir migrates the store, if necessary, and creates the directory
+// of a poll.
+func (s *Store) createPollDir(id string) error {
+	if s.path == "" {
+		return fmt.Errorf("No data dir provided. Check the environment variable VOTE_DECRYPT_STORE")
+	}
+
+	if err := s.migrate(); err != nil {
+		return fmt.Errorf("migrating store: %w", err)
+	}
+
+	return s.addPoll(id)
+}
+
+// addPoll adds the poll to the index and creates its directory.
+func (s *Store) addPoll(id string) error {
+	index, err := s.readIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	// The poll is added to the index before its first file is created. So
+	// there are no files, that are not in the index.
+	if _, ok := index[pollHash(id)]; !ok {
+		index[pollHash(id)] = id
+		if err := s.writeIndex(index); err != nil {
+			return fmt.Errorf("writing index: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(s.PollDir(id), 0700); err != nil {
+		return fmt.Errorf("creating poll dir: %w", err)
 	}
 
 	return nil
 }
 
-// LoadKey returns the private key from the store.
+// readFile returns the content of a file of a poll.
 //
-// If the poll is unknown return (nil, nil)
-func (s *Store) LoadKey(id string) ([]byte, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// If the file does not exist, it returns errorcode.NotExist.
+func (s *Store) readFile(id string, name string) ([]byte, error) {
+	if err := s.migrate(); err != nil {
+		return nil, fmt.Errorf("migrating store: %w", err)
+	}
 
-	key, err := os.ReadFile(s.keyFile(id))
+	content, err := os.ReadFile(filepath.Join(s.PollDir(id), name))
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, errorcode.NotExist
 		}
-		return nil, fmt.Errorf("reading key file: %w", err)
+		return nil, fmt.Errorf("reading %s file: %w", name, err)
+	}
+
+	return content, nil
+}
+
+// readIndex returns the index, that maps the hashes to the poll ids.
+func (s *Store) readIndex() (map[string]string, error) {
+	content, err := os.ReadFile(filepath.Join(s.path, indexFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return make(map[string]string), nil
+		}
+		return nil, err
+	}
+
+	index := make(map[string]string)
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf("decoding index: %w", err)
+	}
+	return index, nil
+}
+
+// writeIndex replaces the index atomically.
+func (s *Store) writeIndex(index map[string]string) error {
+	content, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("encoding index: %w", err)
+	}
+
+	if err := os.MkdirAll(s.path, os.ModePerm); err != nil {
+		return fmt.Errorf("creating data dir `%s`: %w", s.path, err)
+	}
+
+	return writeFileAtomic(filepath.Join(s.path, indexFile), content)
+}
+
+// writeFileAtomic replaces the file name with content. The content is written
+// to a temporary file, that is synced to disk before it is renamed. Afterwards,
+// the directory is synced, so the rename survives a power loss.
+func writeFileAtomic(name string, content []byte) (err error) {
+	tmpFile := name + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	if err := rename(tmpFile, name); err != nil {
+		return fmt.Errorf("renaming temporary file: %w", err)
+	}
+
+	return syncDir(filepath.Dir(name))
+}
+
+// migrate moves the files of the old flat layout into the directories of the
+// polls. It only runs once per Store.
+func (s *Store) migrate() error {
+	if s.migrated {
+		return nil
+	}
+
+	entries, err := os.ReadDir(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading data dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		name := strings.TrimPrefix(ext, ".")
+		if ext == "" || !isPollFile(name) {
+			continue
+		}
+
+		// The old layout replaced `/` in the poll id with `_`.
+		id := strings.ReplaceAll(strings.TrimSuffix(entry.Name(), ext), "_", "/")
+		if err := s.addPoll(id); err != nil {
+			return err
+		}
+
+		target := filepath.Join(s.PollDir(id), name)
+		if _, err := os.Stat(target); err == nil {
+			return fmt.Errorf("%s exists in the old and the new layout", entry.Name())
+		}
+
+		if err := rename(filepath.Join(s.path, entry.Name()), target); err != nil {
+			return fmt.Errorf("moving %s: %w", entry.Name(), err)
+		}
+	}
+
+	s.migrated = true
+	return nil
+}
+
+func isPollFile(name string) bool {
+	for _, pollFile := range pollFiles {
+		if name == pollFile {
+			return true
+		}
 	}
+	return false
+}
+
+// SaveCommitment stores the leaves of the merkle tree of a poll.
+//
+// Has to return an error, if a commitment already exists.
+func (s *Store) SaveCommitment(id string, leaves []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createFile(id, "commitment", leaves)
+}
+
+// LoadCommitment returns the leaves of the merkle tree of a poll.
+//
+// If the commitment is unknown, it returns errorcode.NotExist.
+func (s *Store) LoadCommitment(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.readFile(id, "commitment")
+}
+
+// LoadKey returns the private key from the store.
+//
+// If the poll is unknown return (nil, nil)
+func (s *Store) LoadKey(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.readFile(id, "key")
+}
+
+// LoadMeta returns the meta data of a poll.
+//
+// If the meta data is unknown, it returns errorcode.NotExist.
+func (s *Store) LoadMeta(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return key, nil
+	return s.readFile(id, "meta")
 }
 
 // ValidateSignature makes sure, that no other signature is saved for a
@@ -101,7 +346,11 @@ func (s *Store) ValidateSignature(id string, hash []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, err := os.Stat(s.keyFile(id)); err != nil {
+	if err := s.migrate(); err != nil {
+		return fmt.Errorf("migrating store: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(s.PollDir(id), "key")); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return errorcode.NotExist
 		}
@@ -109,7 +358,7 @@ func (s *Store) ValidateSignature(id string, hash []byte) error {
 		return fmt.Errorf("checking key file: %w", err)
 	}
 
-	f, err := os.OpenFile(s.hashFile(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+	f, err := os.OpenFile(filepath.Join(s.PollDir(id), "hash"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
 	if err != nil {
 		if errors.Is(err, os.ErrExist) {
 			return s.checkHash(id, hash)
@@ -128,11 +377,25 @@ func (s *Store) ValidateSignature(id string, hash []byte) error {
 		return fmt.Errorf("writing hash: %w", err)
 	}
 
-	return nil
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("syncing hash: %w", err)
+	}
+
+	return syncDir(s.PollDir(id))
+}
+
+// LoadHash returns the hash, that was saved with ValidateSignature().
+//
+// If the poll was not stopped, it returns errorcode.NotExist.
+func (s *Store) LoadHash(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.readFile(id, "hash")
 }
 
 func (s *Store) checkHash(id string, hash []byte) error {
-	content, err := os.ReadFile(s.hashFile(id))
+	content, err := os.ReadFile(filepath.Join(s.PollDir(id), "hash"))
 	if err != nil {
 		return fmt.Errorf("reading file content: %v", err)
 	}
@@ -145,27 +408,158 @@ func (s *Store) checkHash(id string, hash []byte) error {
 }
 
 // ClearPoll removes all data for the poll.
+//
+// The files are overwritten before they are removed. This does not help on
+// filesystems or disks, that do not write the data in place, for example
+// copy-on-write filesystems or SSDs with wear leveling.
 func (s *Store) ClearPoll(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := os.Remove(s.keyFile(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("deleting key file: %w", err)
+	if err := s.migrate(); err != nil {
+		return fmt.Errorf("migrating store: %w", err)
+	}
+
+	dir := s.PollDir(id)
+	for _, name := range pollFiles {
+		if err := shredFile(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("deleting %s file: %w", name, err)
+		}
+	}
+
+	if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("deleting poll dir: %w", err)
+	}
+
+	// The fan-out directories are removed, if they are empty. Errors are
+	// ignored, because other polls can use them.
+	os.Remove(filepath.Dir(dir))
+	os.Remove(filepath.Dir(filepath.Dir(dir)))
+
+	index, err := s.readIndex()
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
 	}
 
-	if err := os.Remove(s.hashFile(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("deleting hash file: %w", err)
+	if _, ok := index[pollHash(id)]; ok {
+		delete(index, pollHash(id))
+		if err := s.writeIndex(index); err != nil {
+			return fmt.Errorf("writing index: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (s *Store) keyFile(id string) string {
-	id = strings.ReplaceAll(id, "/", "_")
-	return path.Join(s.path, id+".key")
+// shredFile overwrites a file with zeros and removes it afterwards.
+//
+// Overwriting is done on a best effort basis. If it fails, the file is removed
+// anyway. Does not return an error, if the file does not exist.
+func shredFile(name string) error {
+	if info, err := os.Stat(name); err == nil {
+		if err := os.Chmod(name, 0600); err == nil {
+			if f, err := os.OpenFile(name, os.O_WRONLY, 0); err == nil {
+				f.Write(make([]byte, info.Size()))
+				f.Sync()
+				f.Close()
+			}
+		}
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
 }
 
-func (s *Store) hashFile(id string) string {
-	id = strings.ReplaceAll(id, "/", "_")
-	return path.Join(s.path, id+".hash")
+// ScheduleClear saves the time when the poll should be removed.
+//
+// Overwrites an older schedule for the same poll.
+func (s *Store) ScheduleClear(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.createPollDir(id); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.PollDir(id), "clear"), []byte(at.UTC().Format(time.RFC3339Nano))); err != nil {
+		return fmt.Errorf("writing clear file: %w", err)
+	}
+
+	return nil
+}
+
+// ScheduledClears returns all polls that are scheduled to be removed with the
+// time of the removal.
+func (s *Store) ScheduledClears() (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.listPolls()
+	if err != nil {
+		return nil, err
+	}
+
+	scheduled := make(map[string]time.Time)
+	for _, id := range ids {
+		content, err := os.ReadFile(filepath.Join(s.PollDir(id), "clear"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("reading clear file: %w", err)
+		}
+
+		at, err := time.Parse(time.RFC3339Nano, string(content))
+		if err != nil {
+			return nil, fmt.Errorf("parsing clear file of poll %s: %w", id, err)
+		}
+
+		scheduled[id] = at
+	}
+
+	return scheduled, nil
+}
+
+// ListPolls returns the ids of all polls in the store.
+//
+// A poll is part of the list, if it is in the index and its directory exists.
+func (s *Store) ListPolls() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listPolls()
+}
+
+func (s *Store) listPolls() ([]string, error) {
+	if err := s.migrate(); err != nil {
+		return nil, fmt.Errorf("migrating store: %w", err)
+	}
+
+	index, err := s.readIndex()
+	if err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+
+	var ids []string
+	for hash, id := range index {
+		// Ignore modified entries, that do not belong to the directory.
+		if pollHash(id) != hash {
+			continue
+		}
+
+		if _, err := os.Stat(s.PollDir(id)); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("checking poll dir: %w", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids, nil
 }
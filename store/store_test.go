@@ -6,7 +6,9 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/OpenSlides/vote-decrypt/errorcode"
 	"github.com/OpenSlides/vote-decrypt/store"
@@ -21,7 +23,7 @@ func TestSaveKey(t *testing.T) {
 			t.Fatalf("SaveKey: %v", err)
 		}
 
-		fullpath := path.Join(tmpPath, "test_5.key")
+		fullpath := path.Join(s.PollDir("test/5"), "key")
 		content, err := os.ReadFile(fullpath)
 		if err != nil {
 			t.Fatalf("Reading keyfile: %v", err)
@@ -78,6 +80,62 @@ func TestLoadKey(t *testing.T) {
 	})
 }
 
+func TestSaveMeta(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		tmpPath := t.TempDir()
+		s := store.New(tmpPath)
+
+		if err := s.SaveMeta("test/5", []byte("meta")); err != nil {
+			t.Fatalf("SaveMeta: %v", err)
+		}
+
+		content, err := os.ReadFile(path.Join(s.PollDir("test/5"), "meta"))
+		if err != nil {
+			t.Fatalf("Reading meta file: %v", err)
+		}
+
+		if !bytes.Equal(content, []byte("meta")) {
+			t.Errorf("SaveMeta created file with `%s`, expected `meta`", content)
+		}
+	})
+
+	t.Run("file exists", func(t *testing.T) {
+		tmpPath := t.TempDir()
+		os.WriteFile(path.Join(tmpPath, "test_5.meta"), []byte("old meta"), 0400)
+		s := store.New(tmpPath)
+
+		if err := s.SaveMeta("test/5", []byte("meta")); err != errorcode.Exist {
+			t.Errorf("SaveMeta returned error `%v`, expected `%v`", err, errorcode.Exist)
+		}
+	})
+}
+
+func TestLoadMeta(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		tmpPath := t.TempDir()
+		os.WriteFile(path.Join(tmpPath, "test_5.meta"), []byte("meta"), 0400)
+		s := store.New(tmpPath)
+
+		got, err := s.LoadMeta("test/5")
+		if err != nil {
+			t.Fatalf("LoadMeta returns: %v", err)
+		}
+
+		if !bytes.Equal(got, []byte("meta")) {
+			t.Errorf("LoadMeta returned `%s`, expected `meta`", got)
+		}
+	})
+
+	t.Run("meta unknown", func(t *testing.T) {
+		tmpPath := t.TempDir()
+		s := store.New(tmpPath)
+
+		if _, err := s.LoadMeta("test/5"); err != errorcode.NotExist {
+			t.Errorf("LoadMeta retunred `%v`, expected `%v`", err, errorcode.NotExist)
+		}
+	})
+}
+
 func TestValidateSignature(t *testing.T) {
 	t.Run("firt time", func(t *testing.T) {
 		tmpPath := t.TempDir()
@@ -88,7 +146,7 @@ func TestValidateSignature(t *testing.T) {
 			t.Errorf("ValidateSignature: %v", err)
 		}
 
-		fullpath := path.Join(tmpPath, "test_5.hash")
+		fullpath := path.Join(s.PollDir("test/5"), "hash")
 		content, err := os.ReadFile(fullpath)
 		if err != nil {
 			t.Fatalf("reading hash file: %v", err)
@@ -145,8 +203,10 @@ func TestClearPoll(t *testing.T) {
 		tmpPath := t.TempDir()
 		keyFile := path.Join(tmpPath, "test_5.key")
 		hashFile := path.Join(tmpPath, "test_5.hash")
+		metaFile := path.Join(tmpPath, "test_5.meta")
 		os.WriteFile(keyFile, []byte("key"), 0400)
 		os.WriteFile(hashFile, []byte("hash"), 0400)
+		os.WriteFile(metaFile, []byte("meta"), 0400)
 		s := store.New(tmpPath)
 
 		if err := s.ClearPoll("test/5"); err != nil {
@@ -160,6 +220,23 @@ func TestClearPoll(t *testing.T) {
 		if _, err := os.Stat(hashFile); !errors.Is(err, os.ErrNotExist) {
 			t.Errorf("hash file not deleted")
 		}
+
+		if _, err := os.Stat(metaFile); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("meta file not deleted")
+		}
+
+		if _, err := os.Stat(s.PollDir("test/5")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("poll dir not deleted")
+		}
+
+		got, err := s.ListPolls()
+		if err != nil {
+			t.Fatalf("ListPolls: %v", err)
+		}
+
+		if len(got) != 0 {
+			t.Errorf("ListPolls returned %v after ClearPoll, expected an empty list", got)
+		}
 	})
 
 	t.Run("files not exist", func(t *testing.T) {
@@ -171,3 +248,304 @@ func TestClearPoll(t *testing.T) {
 		}
 	})
 }
+
+func TestListPolls(t *testing.T) {
+	t.Run("some polls", func(t *testing.T) {
+		tmpPath := t.TempDir()
+		os.WriteFile(path.Join(tmpPath, "test_5.key"), []byte("key"), 0400)
+		os.WriteFile(path.Join(tmpPath, "test_5.hash"), []byte("hash"), 0400)
+		os.WriteFile(path.Join(tmpPath, "test_6.meta"), []byte("meta"), 0400)
+		os.WriteFile(path.Join(tmpPath, "other_file"), []byte("other"), 0400)
+		s := store.New(tmpPath)
+
+		got, err := s.ListPolls()
+		if err != nil {
+			t.Fatalf("ListPolls: %v", err)
+		}
+
+		if len(got) != 2 || got[0] != "test/5" || got[1] != "test/6" {
+			t.Errorf("ListPolls returned %v, expected [test/5 test/6]", got)
+		}
+	})
+
+	t.Run("no data dir", func(t *testing.T) {
+		s := store.New(path.Join(t.TempDir(), "not_existing"))
+
+		got, err := s.ListPolls()
+		if err != nil {
+			t.Fatalf("ListPolls: %v", err)
+		}
+
+		if len(got) != 0 {
+			t.Errorf("ListPolls returned %v, expected an empty list", got)
+		}
+	})
+}
+
+func TestScheduleClear(t *testing.T) {
+	tmpPath := t.TempDir()
+	s := store.New(tmpPath)
+	at := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := s.ScheduleClear("test/5", at); err != nil {
+		t.Fatalf("ScheduleClear: %v", err)
+	}
+
+	scheduled, err := s.ScheduledClears()
+	if err != nil {
+		t.Fatalf("ScheduledClears: %v", err)
+	}
+
+	if len(scheduled) != 1 || !scheduled["test/5"].Equal(at) {
+		t.Errorf("ScheduledClears returned %v, expected test/5 at %v", scheduled, at)
+	}
+
+	if err := s.ClearPoll("test/5"); err != nil {
+		t.Fatalf("ClearPoll: %v", err)
+	}
+
+	scheduled, err = s.ScheduledClears()
+	if err != nil {
+		t.Fatalf("ScheduledClears after ClearPoll: %v", err)
+	}
+
+	if len(scheduled) != 0 {
+		t.Errorf("ScheduledClears returned %v after ClearPoll, expected an empty map", scheduled)
+	}
+}
+
+func TestCommitment(t *testing.T) {
+	tmpPath := t.TempDir()
+	s := store.New(tmpPath)
+
+	if _, err := s.LoadCommitment("test/6"); !errors.Is(err, errorcode.NotExist) {
+		t.Errorf("LoadCommitment of unknown poll returned `%v`, expected NotExist", err)
+	}
+
+	if err := s.SaveCommitment("test/6", []byte("leaves")); err != nil {
+		t.Fatalf("SaveCommitment: %v", err)
+	}
+
+	if err := s.SaveCommitment("test/6", []byte("other")); !errors.Is(err, errorcode.Exist) {
+		t.Errorf("second SaveCommitment returned `%v`, expected Exist", err)
+	}
+
+	leaves, err := s.LoadCommitment("test/6")
+	if err != nil {
+		t.Fatalf("LoadCommitment: %v", err)
+	}
+
+	if string(leaves) != "leaves" {
+		t.Errorf("LoadCommitment returned %q, expected %q", leaves, "leaves")
+	}
+
+	if err := s.ClearPoll("test/6"); err != nil {
+		t.Fatalf("ClearPoll: %v", err)
+	}
+
+	if _, err := s.LoadCommitment("test/6"); !errors.Is(err, errorcode.NotExist) {
+		t.Errorf("LoadCommitment after ClearPoll returned `%v`, expected NotExist", err)
+	}
+}
+
+func TestReplacementKeys(t *testing.T) {
+	tmpPath := t.TempDir()
+	s := store.New(tmpPath)
+
+	if err := s.SaveKey("test/8", []byte("key")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	keys, err := s.LoadReplacementKeys("test/8")
+	if err != nil {
+		t.Fatalf("LoadReplacementKeys: %v", err)
+	}
+
+	if len(keys) != 0 {
+		t.Errorf("LoadReplacementKeys of poll without replacement keys returned %q", keys)
+	}
+
+	for _, key := range []string{"key2", "key3"} {
+		if err := s.SaveReplacementKey("test/8", []byte(key)); err != nil {
+			t.Fatalf("SaveReplacementKey: %v", err)
+		}
+	}
+
+	keys, err = s.LoadReplacementKeys("test/8")
+	if err != nil {
+		t.Fatalf("LoadReplacementKeys: %v", err)
+	}
+
+	if len(keys) != 2 || string(keys[0]) != "key2" || string(keys[1]) != "key3" {
+		t.Errorf("LoadReplacementKeys returned %q, expected [key2 key3]", keys)
+	}
+
+	if err := s.ClearPoll("test/8"); err != nil {
+		t.Fatalf("ClearPoll: %v", err)
+	}
+
+	if _, err := os.Stat(s.PollDir("test/8")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("poll dir exists after ClearPoll: %v", err)
+	}
+}
+
+func TestRevocations(t *testing.T) {
+	tmpPath := t.TempDir()
+	s := store.New(tmpPath)
+
+	revocations, err := s.Revocations()
+	if err != nil {
+		t.Fatalf("Revocations: %v", err)
+	}
+
+	if len(revocations) != 0 {
+		t.Errorf("Revocations of empty store returned %q", revocations)
+	}
+
+	if err := s.SaveKey("test/7", []byte("key")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	for _, revocation := range []string{"first", "second"} {
+		if err := s.SaveRevocation([]byte(revocation)); err != nil {
+			t.Fatalf("SaveRevocation: %v", err)
+		}
+	}
+
+	if err := s.ClearPoll("test/7"); err != nil {
+		t.Fatalf("ClearPoll: %v", err)
+	}
+
+	revocations, err = store.New(tmpPath).Revocations()
+	if err != nil {
+		t.Fatalf("Revocations: %v", err)
+	}
+
+	if len(revocations) != 2 || string(revocations[0]) != "first" || string(revocations[1]) != "second" {
+		t.Errorf("Revocations returned %q, expected [first second]", revocations)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	t.Run("hashed ids", func(t *testing.T) {
+		tmpPath := t.TempDir()
+		s := store.New(tmpPath)
+
+		for _, id := range []string{"test/1", "test_1", "../test"} {
+			if err := s.SaveKey(id, []byte("key "+id)); err != nil {
+				t.Fatalf("SaveKey(%s): %v", id, err)
+			}
+		}
+
+		for _, id := range []string{"test/1", "test_1", "../test"} {
+			key, err := s.LoadKey(id)
+			if err != nil {
+				t.Fatalf("LoadKey(%s): %v", id, err)
+			}
+
+			if string(key) != "key "+id {
+				t.Errorf("LoadKey(%s) returned %q, expected the key of the poll", id, key)
+			}
+		}
+
+		entries, err := os.ReadDir(tmpPath)
+		if err != nil {
+			t.Fatalf("reading data dir: %v", err)
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() && entry.Name() != "index.json" {
+				t.Errorf("data dir contains file %s", entry.Name())
+			}
+		}
+
+		got, err := s.ListPolls()
+		if err != nil {
+			t.Fatalf("ListPolls: %v", err)
+		}
+
+		if len(got) != 3 || got[0] != "../test" || got[1] != "test/1" || got[2] != "test_1" {
+			t.Errorf("ListPolls returned %v, expected [../test test/1 test_1]", got)
+		}
+	})
+
+	t.Run("migrate flat layout", func(t *testing.T) {
+		tmpPath := t.TempDir()
+		os.WriteFile(path.Join(tmpPath, "test_5.key"), []byte("key"), 0400)
+		os.WriteFile(path.Join(tmpPath, "test_5.clear"), []byte("2030-01-01T12:00:00Z"), 0600)
+		s := store.New(tmpPath)
+
+		scheduled, err := s.ScheduledClears()
+		if err != nil {
+			t.Fatalf("ScheduledClears: %v", err)
+		}
+
+		if _, ok := scheduled["test/5"]; !ok {
+			t.Errorf("ScheduledClears returned %v, expected test/5", scheduled)
+		}
+
+		if _, err := os.Stat(path.Join(tmpPath, "test_5.key")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("old key file was not moved")
+		}
+
+		if _, err := os.Stat(path.Join(s.PollDir("test/5"), "key")); err != nil {
+			t.Errorf("key file is not in the poll dir: %v", err)
+		}
+	})
+}
+
+func TestCompact(t *testing.T) {
+	tmpPath := t.TempDir()
+	s := store.New(tmpPath)
+
+	if err := s.SaveKey("test/1", []byte("key")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	if err := s.SaveKey("test/2", []byte("key")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	// Simulate a crash after the poll was added to the index.
+	if err := os.Remove(path.Join(s.PollDir("test/2"), "key")); err != nil {
+		t.Fatalf("removing key file: %v", err)
+	}
+
+	orphan := path.Join(tmpPath, "ab", "cd", strings.Repeat("ab", 32))
+	if err := os.MkdirAll(orphan, 0700); err != nil {
+		t.Fatalf("creating orphan: %v", err)
+	}
+	os.WriteFile(path.Join(orphan, "key"), []byte("key"), 0400)
+	os.MkdirAll(path.Join(tmpPath, "ef", "01"), 0700)
+
+	report, err := s.Compact()
+	if err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+
+	if report.StaleEntries != 1 {
+		t.Errorf("got %d stale entries, expected 1", report.StaleEntries)
+	}
+
+	// The poll dir of test/2, its fan-out dirs and ef/01.
+	if report.EmptyDirs != 5 {
+		t.Errorf("got %d empty dirs, expected 5", report.EmptyDirs)
+	}
+
+	if len(report.Orphans) != 1 || report.Orphans[0] != orphan {
+		t.Errorf("got orphans %v, expected [%s]", report.Orphans, orphan)
+	}
+
+	got, err := s.ListPolls()
+	if err != nil {
+		t.Fatalf("ListPolls: %v", err)
+	}
+
+	if len(got) != 1 || got[0] != "test/1" {
+		t.Errorf("ListPolls returned %v, expected [test/1]", got)
+	}
+
+	if _, err := s.LoadKey("test/1"); err != nil {
+		t.Errorf("LoadKey after Compact: %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+// Package vault is a storage backend for vote-decrypt that uses the KV secrets
+// engine (version 2) of HashiCorp Vault.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+)
+
+// Store implements the decrypt.Store interface by writing the data to the KV
+// secrets engine of vault.
+//
+// Each poll gets its own path `PREFIX/POLLID`, where `/` in the poll id is
+// replaced by `_`. Beneath this path, up to five secrets are created. `key`
+// contains the private key of the poll, `meta` the meta data of the poll,
+// `hash` the hash of the first stop request, `clear` the time, when the poll
+// should be removed and `commitment` the leaves of the merkle tree over the
+// votes.
+//
+// This allows vault policies for each poll and uses the audit log of vault
+// for all access to the poll keys.
+type Store struct {
+	addr   string
+	token  string
+	mount  string
+	prefix string
+
+	client *http.Client
+}
+
+// New initializes a new Store.
+//
+// addr is the address of the vault server, for example
+// `https://vault:8200`. mount is the path, where the KV secrets engine is
+// mounted, for example `secret`. All secrets are saved beneath prefix.
+func New(addr, token, mount, prefix string) *Store {
+	return &Store{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		mount:  strings.Trim(mount, "/"),
+		prefix: strings.Trim(prefix, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SaveKey stores the private key.
+//
+// Returns errorcode.Exist, if a key already exists.
+func (s *Store) SaveKey(id string, key []byte) error {
+	return s.create(s.secretPath(id, "key"), key)
+}
+
+// LoadKey returns the private key from the store.
+//
+// If the poll is unknown, it returns errorcode.NotExist.
+func (s *Store) LoadKey(id string) ([]byte, error) {
+	return s.read(s.secretPath(id, "key"))
+}
+
+// SaveMeta stores the meta data of a poll.
+//
+// Returns errorcode.Exist, if the meta data already exists.
+func (s *Store) SaveMeta(id string, meta []byte) error {
+	return s.create(s.secretPath(id, "meta"), meta)
+}
+
+// LoadMeta returns the meta data of a poll.
+//
+// If the meta data is unknown, it returns errorcode.NotExist.
+func (s *Store) LoadMeta(id string) ([]byte, error) {
+	return s.read(s.secretPath(id, "meta"))
+}
+
+// SaveCommitment stores the leaves of the merkle tree of a poll.
+//
+// Returns errorcode.Exist, if the commitment already exists.
+func (s *Store) SaveCommitment(id string, leaves []byte) error {
+	return s.create(s.secretPath(id, "commitment"), leaves)
+}
+
+// LoadCommitment returns the leaves of the merkle tree of a poll.
+//
+// If the commitment is unknown, it returns errorcode.NotExist.
+func (s *Store) LoadCommitment(id string) ([]byte, error) {
+	return s.read(s.secretPath(id, "commitment"))
+}
+
+// LoadHash returns the hash, that was saved with ValidateSignature().
+//
+// Returns errorcode.NotExist, if the poll was not stopped.
+func (s *Store) LoadHash(id string) ([]byte, error) {
+	return s.read(s.secretPath(id, "hash"))
+}
+
+// ValidateSignature makes sure, that no other signature is saved for a
+// poll. Saves the signature for future calls.
+//
+// Returns errorcode.NotExist, if the poll is unknown and errorcode.Invalid, if
+// another hash was saved before.
+func (s *Store) ValidateSignature(id string, hash []byte) error {
+	if _, err := s.read(s.secretPath(id, "key")); err != nil {
+		return err
+	}
+
+	err := s.create(s.secretPath(id, "hash"), hash)
+	if err == nil {
+		return nil
+	}
+
+	if err != errorcode.Exist {
+		return fmt.Errorf("saving hash: %w", err)
+	}
+
+	saved, err := s.read(s.secretPath(id, "hash"))
+	if err != nil {
+		return fmt.Errorf("reading hash: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare(hash, saved) != 1 {
+		return errorcode.Invalid
+	}
+
+	return nil
+}
+
+// ClearPoll removes all data for the poll including all versions of the
+// secrets.
+func (s *Store) ClearPoll(id string) error {
+	for _, name := range []string{"key", "hash", "meta", "clear", "commitment"} {
+		if err := s.destroy(s.secretPath(id, name)); err != nil {
+			return fmt.Errorf("deleting %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ListPolls returns the ids of all polls in the store.
+func (s *Store) ListPolls() ([]string, error) {
+	keys, err := s.list(s.prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing polls: %w", err)
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, strings.ReplaceAll(strings.TrimSuffix(key, "/"), "_", "/"))
+	}
+	return ids, nil
+}
+
+// ScheduleClear saves the time when the poll should be removed.
+func (s *Store) ScheduleClear(id string, at time.Time) error {
+	value := []byte(at.UTC().Format(time.RFC3339Nano))
+	if err := s.write(s.secretPath(id, "clear"), value, nil); err != nil {
+		return fmt.Errorf("writing clear time: %w", err)
+	}
+	return nil
+}
+
+// ScheduledClears returns all polls that are scheduled to be removed with the
+// time of the removal.
+func (s *Store) ScheduledClears() (map[string]time.Time, error) {
+	ids, err := s.ListPolls()
+	if err != nil {
+		return nil, err
+	}
+
+	scheduled := make(map[string]time.Time)
+	for _, id := range ids {
+		value, err := s.read(s.secretPath(id, "clear"))
+		if err != nil {
+			if err == errorcode.NotExist {
+				continue
+			}
+			return nil, fmt.Errorf("reading clear time of %s: %w", id, err)
+		}
+
+		at, err := time.Parse(time.RFC3339Nano, string(value))
+		if err != nil {
+			return nil, fmt.Errorf("parsing clear time of %s: %w", id, err)
+		}
+		scheduled[id] = at
+	}
+
+	return scheduled, nil
+}
+
+func (s *Store) secretPath(id, name string) string {
+	id = strings.ReplaceAll(id, "/", "_")
+	return s.prefix + "/" + id + "/" + name
+}
+
+// create writes a secret, that does not exist.
+//
+// Returns errorcode.Exist if the secret exists.
+func (s *Store) create(path string, value []byte) error {
+	cas := 0
+	return s.write(path, value, &cas)
+}
+
+// write writes a value to the secret at path. If cas is not nil, it is used
+// as check-and-set parameter.
+func (s *Store) write(path string, value []byte, cas *int) error {
+	body := struct {
+		Data    map[string]string `json:"data"`
+		Options map[string]int    `json:"options,omitempty"`
+	}{
+		Data: map[string]string{"value": base64.StdEncoding.EncodeToString(value)},
+	}
+
+	if cas != nil {
+		body.Options = map[string]int{"cas": *cas}
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encoding request: %w", err)
+	}
+
+	status, respBody, err := s.request(http.MethodPost, "/data/"+path, encoded)
+	if err != nil {
+		return err
+	}
+
+	if status == http.StatusBadRequest && strings.Contains(string(respBody), "check-and-set") {
+		return errorcode.Exist
+	}
+
+	if status != http.StatusOK && status != http.StatusNoContent {
+		return fmt.Errorf("vault returned status %d: %s", status, string(respBody))
+	}
+
+	return nil
+}
+
+// read returns the value of a secret.
+//
+// Returns errorcode.NotExist, if the secret does not exist.
+func (s *Store) read(path string) ([]byte, error) {
+	status, respBody, err := s.request(http.MethodGet, "/data/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusNotFound {
+		return nil, errorcode.NotExist
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d: %s", status, string(respBody))
+	}
+
+	var content struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &content); err != nil {
+		return nil, fmt.Errorf("decoding vault response: %w", err)
+	}
+
+	encoded, ok := content.Data.Data["value"]
+	if !ok {
+		// The secret was deleted but not destroyed.
+		return nil, errorcode.NotExist
+	}
+
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding value: %w", err)
+	}
+
+	return value, nil
+}
+
+// destroy removes all versions of a secret.
+//
+// Does not return an error, if the secret does not exist.
+func (s *Store) destroy(path string) error {
+	status, respBody, err := s.request(http.MethodDelete, "/metadata/"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	if status != http.StatusOK && status != http.StatusNoContent && status != http.StatusNotFound {
+		return fmt.Errorf("vault returned status %d: %s", status, string(respBody))
+	}
+
+	return nil
+}
+
+// list returns the keys beneath path.
+func (s *Store) list(path string) ([]string, error) {
+	status, respBody, err := s.request("LIST", "/metadata/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d: %s", status, string(respBody))
+	}
+
+	var content struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &content); err != nil {
+		return nil, fmt.Errorf("decoding vault response: %w", err)
+	}
+
+	return content.Data.Keys, nil
+}
+
+// request sends a request to the vault api and returns the status code and
+// the body of the response.
+func (s *Store) request(method, path string, body []byte) (int, []byte, error) {
+	url := fmt.Sprintf("%s/v1/%s%s", s.addr, s.mount, path)
+
+	req, err := http.NewRequestWithContext(context.Background(), method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", s.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("sending request to vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading vault response: %w", err)
+	}
+
+	return resp.StatusCode, respBody, nil
+}
@@ -0,0 +1,195 @@
+package vault_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/errorcode"
+	"github.com/OpenSlides/vote-decrypt/store/vault"
+)
+
+// fakeVault implements the parts of the vault KV v2 api, that are used by the
+// store.
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]string
+}
+
+func newFakeVault(t *testing.T) *vault.Store {
+	f := &fakeVault{secrets: make(map[string]map[string]string)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return vault.New(srv.URL, "token", "secret", "vote-decrypt")
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/"); ok {
+		switch r.Method {
+		case http.MethodGet:
+			secret, ok := f.secrets[path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": secret}})
+
+		case http.MethodPost:
+			var body struct {
+				Data    map[string]string `json:"data"`
+				Options map[string]int    `json:"options"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if cas, ok := body.Options["cas"]; ok && cas == 0 && f.secrets[path] != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":["check-and-set parameter did not match the current version"]}`))
+				return
+			}
+			f.secrets[path] = body.Data
+			w.Write([]byte(`{}`))
+		}
+		return
+	}
+
+	if path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/metadata/"); ok {
+		switch r.Method {
+		case http.MethodDelete:
+			delete(f.secrets, path)
+			w.WriteHeader(http.StatusNoContent)
+
+		case "LIST":
+			seen := make(map[string]bool)
+			var keys []string
+			for secretPath := range f.secrets {
+				rest, ok := strings.CutPrefix(secretPath, path+"/")
+				if !ok {
+					continue
+				}
+				key := rest
+				if i := strings.Index(rest, "/"); i >= 0 {
+					key = rest[:i+1]
+				}
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+			if len(keys) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			sort.Strings(keys)
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"keys": keys}})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func TestKey(t *testing.T) {
+	s := newFakeVault(t)
+
+	if _, err := s.LoadKey("test/5"); err != errorcode.NotExist {
+		t.Errorf("LoadKey for unknown poll returned `%v`, expected `%v`", err, errorcode.NotExist)
+	}
+
+	if err := s.SaveKey("test/5", []byte("key")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	if err := s.SaveKey("test/5", []byte("other key")); err != errorcode.Exist {
+		t.Errorf("second SaveKey returned `%v`, expected `%v`", err, errorcode.Exist)
+	}
+
+	got, err := s.LoadKey("test/5")
+	if err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+
+	if string(got) != "key" {
+		t.Errorf("LoadKey returned `%s`, expected `key`", got)
+	}
+}
+
+func TestValidateSignature(t *testing.T) {
+	s := newFakeVault(t)
+
+	if err := s.ValidateSignature("test/5", []byte("hash")); err != errorcode.NotExist {
+		t.Errorf("ValidateSignature for unknown poll returned `%v`, expected `%v`", err, errorcode.NotExist)
+	}
+
+	if err := s.SaveKey("test/5", []byte("key")); err != nil {
+		t.Fatalf("SaveKey: %v", err)
+	}
+
+	if err := s.ValidateSignature("test/5", []byte("hash")); err != nil {
+		t.Fatalf("first ValidateSignature: %v", err)
+	}
+
+	if err := s.ValidateSignature("test/5", []byte("hash")); err != nil {
+		t.Errorf("second ValidateSignature: %v", err)
+	}
+
+	if err := s.ValidateSignature("test/5", []byte("other")); err != errorcode.Invalid {
+		t.Errorf("ValidateSignature with other hash returned `%v`, expected `%v`", err, errorcode.Invalid)
+	}
+}
+
+func TestListAndClear(t *testing.T) {
+	s := newFakeVault(t)
+
+	s.SaveKey("test/5", []byte("key"))
+	s.SaveMeta("test/5", []byte("meta"))
+	s.SaveKey("test/6", []byte("key"))
+	at := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.ScheduleClear("test/6", at)
+
+	ids, err := s.ListPolls()
+	if err != nil {
+		t.Fatalf("ListPolls: %v", err)
+	}
+
+	if len(ids) != 2 || ids[0] != "test/5" || ids[1] != "test/6" {
+		t.Errorf("ListPolls returned %v, expected [test/5 test/6]", ids)
+	}
+
+	scheduled, err := s.ScheduledClears()
+	if err != nil {
+		t.Fatalf("ScheduledClears: %v", err)
+	}
+
+	if len(scheduled) != 1 || !scheduled["test/6"].Equal(at) {
+		t.Errorf("ScheduledClears returned %v, expected test/6 at %v", scheduled, at)
+	}
+
+	if err := s.ClearPoll("test/5"); err != nil {
+		t.Fatalf("ClearPoll: %v", err)
+	}
+
+	if _, err := s.LoadMeta("test/5"); err != errorcode.NotExist {
+		t.Errorf("LoadMeta after ClearPoll returned `%v`, expected `%v`", err, errorcode.NotExist)
+	}
+
+	ids, err = s.ListPolls()
+	if err != nil {
+		t.Fatalf("ListPolls after clear: %v", err)
+	}
+
+	if len(ids) != 1 || ids[0] != "test/6" {
+		t.Errorf("ListPolls after clear returned %v, expected [test/6]", ids)
+	}
+}
@@ -0,0 +1,210 @@
+package tpm
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// akTemplate is the template of the attestation key. It is a restricted
+// signing key, so the TPM only signs data with it, that it created itself.
+//
+// The key is created from the seed of the owner hierarchy, so it is the same
+// each time it is created on the same TPM. This allows to register the public
+// attestation key of a host once.
+var akTemplate = tpm2.Public{
+	Type:       tpm2.AlgECC,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagSensitiveDataOrigin | tpm2.FlagUserWithAuth | tpm2.FlagRestricted | tpm2.FlagSign | tpm2.FlagNoDA,
+	ECCParameters: &tpm2.ECCParams{
+		Sign: &tpm2.SigScheme{
+			Alg:  tpm2.AlgECDSA,
+			Hash: tpm2.AlgSHA256,
+		},
+		CurveID: tpm2.CurveNISTP256,
+	},
+}
+
+// Quote is a signed statement of the TPM over the values of some PCRs and
+// some data given by the caller.
+type Quote struct {
+	// AKPublic is the public attestation key in the PKIX DER format.
+	AKPublic []byte `json:"ak_public"`
+
+	// Attest is the TPMS_ATTEST structure, that was signed by the TPM.
+	Attest []byte `json:"attest"`
+
+	// Signature is the ASN.1 encoded ECDSA signature of Attest.
+	Signature []byte `json:"signature"`
+
+	// PCRs are the values of the quoted PCRs from the SHA256 bank.
+	PCRs map[int][]byte `json:"pcrs"`
+}
+
+// Attestor creates quotes with an attestation key of the TPM.
+type Attestor struct {
+	rw   io.ReadWriter
+	pcrs []int
+
+	ak       tpmutil.Handle
+	akPublic []byte
+}
+
+// NewAttestor creates the attestation key in the TPM.
+//
+// The quotes contain the given PCRs from the SHA256 bank. If no PCRs are given,
+// DefaultPCRs are used.
+//
+// Close has to be called to remove the attestation key from the TPM.
+func NewAttestor(rw io.ReadWriter, pcrs []int) (*Attestor, error) {
+	if len(pcrs) == 0 {
+		pcrs = DefaultPCRs
+	}
+	pcrs = append([]int(nil), pcrs...)
+	sort.Ints(pcrs)
+
+	ak, pub, err := tpm2.CreatePrimary(rw, tpm2.HandleOwner, tpm2.PCRSelection{}, "", "", akTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("creating attestation key: %w", err)
+	}
+
+	akPublic, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		tpm2.FlushContext(rw, ak)
+		return nil, fmt.Errorf("encoding attestation key: %w", err)
+	}
+
+	return &Attestor{
+		rw:       rw,
+		pcrs:     pcrs,
+		ak:       ak,
+		akPublic: akPublic,
+	}, nil
+}
+
+// Close removes the attestation key from the TPM.
+func (a *Attestor) Close() error {
+	return tpm2.FlushContext(a.rw, a.ak)
+}
+
+// PublicKey returns the public attestation key in the PKIX DER format.
+func (a *Attestor) PublicKey() []byte {
+	return a.akPublic
+}
+
+// Attest returns a json encoded Quote over data.
+//
+// The TPM only signs a digest of data, so data can have any size.
+func (a *Attestor) Attest(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: a.pcrs}
+
+	attest, sig, err := tpm2.Quote(a.rw, a.ak, "", "", digest[:], sel, tpm2.AlgNull)
+	if err != nil {
+		return nil, fmt.Errorf("creating quote: %w", err)
+	}
+
+	if sig.ECC == nil {
+		return nil, fmt.Errorf("tpm returned signature of type %v, expected ecc", sig.Alg)
+	}
+
+	signature, err := asn1.Marshal(struct{ R, S *big.Int }{sig.ECC.R, sig.ECC.S})
+	if err != nil {
+		return nil, fmt.Errorf("encoding signature: %w", err)
+	}
+
+	pcrValues, err := tpm2.ReadPCRs(a.rw, sel)
+	if err != nil {
+		return nil, fmt.Errorf("reading pcrs: %w", err)
+	}
+
+	encoded, err := json.Marshal(Quote{
+		AKPublic:  a.akPublic,
+		Attest:    attest,
+		Signature: signature,
+		PCRs:      pcrValues,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding quote: %w", err)
+	}
+	return encoded, nil
+}
+
+// VerifyQuote checks, that the json encoded quote was created by Attest for
+// data and that the PCR values in the quote were the values the TPM signed.
+//
+// It returns the decoded Quote. The caller has to check, that Quote.AKPublic
+// is the known attestation key of the host and that the PCR values are the
+// expected ones.
+func VerifyQuote(encoded []byte, data []byte) (Quote, error) {
+	var quote Quote
+	if err := json.Unmarshal(encoded, &quote); err != nil {
+		return Quote{}, fmt.Errorf("decoding quote: %w", err)
+	}
+
+	akPub, err := x509.ParsePKIXPublicKey(quote.AKPublic)
+	if err != nil {
+		return Quote{}, fmt.Errorf("parsing attestation key: %w", err)
+	}
+
+	ecdsaKey, ok := akPub.(*ecdsa.PublicKey)
+	if !ok {
+		return Quote{}, fmt.Errorf("attestation key has type %T, expected ecdsa", akPub)
+	}
+
+	attestDigest := sha256.Sum256(quote.Attest)
+	if !ecdsa.VerifyASN1(ecdsaKey, attestDigest[:], quote.Signature) {
+		return Quote{}, fmt.Errorf("invalid signature")
+	}
+
+	attest, err := tpm2.DecodeAttestationData(quote.Attest)
+	if err != nil {
+		return Quote{}, fmt.Errorf("decoding attestation data: %w", err)
+	}
+
+	if attest.Type != tpm2.TagAttestQuote || attest.AttestedQuoteInfo == nil {
+		return Quote{}, fmt.Errorf("attestation data is not a quote")
+	}
+
+	digest := sha256.Sum256(data)
+	if !bytes.Equal(attest.ExtraData, digest[:]) {
+		return Quote{}, fmt.Errorf("quote is for other data")
+	}
+
+	info := attest.AttestedQuoteInfo
+	if info.PCRSelection.Hash != tpm2.AlgSHA256 {
+		return Quote{}, fmt.Errorf("quote uses pcr bank %v, expected sha256", info.PCRSelection.Hash)
+	}
+
+	if len(info.PCRSelection.PCRs) != len(quote.PCRs) {
+		return Quote{}, fmt.Errorf("quote has %d pcr values, expected %d", len(quote.PCRs), len(info.PCRSelection.PCRs))
+	}
+
+	pcrs := append([]int(nil), info.PCRSelection.PCRs...)
+	sort.Ints(pcrs)
+
+	pcrHash := sha256.New()
+	for _, pcr := range pcrs {
+		value, ok := quote.PCRs[pcr]
+		if !ok {
+			return Quote{}, fmt.Errorf("quote has no value for pcr %d", pcr)
+		}
+		pcrHash.Write(value)
+	}
+
+	if !bytes.Equal(pcrHash.Sum(nil), info.PCRDigest) {
+		return Quote{}, fmt.Errorf("pcr values do not match the signed digest")
+	}
+
+	return quote, nil
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package tpm
+
+import (
+	"io"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+)
+
+// Open opens the TPM at the given path. If path is empty, the default device
+// is used.
+func Open(path string) (io.ReadWriteCloser, error) {
+	if path == "" {
+		return tpm2.OpenTPM()
+	}
+	return tpm2.OpenTPM(path)
+}
@@ -0,0 +1,17 @@
+package tpm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+)
+
+// Open opens the TPM with the TPM Base Services of Windows. There is no device
+// path on Windows, so path has to be empty.
+func Open(path string) (io.ReadWriteCloser, error) {
+	if path != "" {
+		return nil, fmt.Errorf("a tpm device can not be given on windows")
+	}
+	return tpm2.OpenTPM()
+}
@@ -0,0 +1,150 @@
+// Package tpm seals the main key to the TPM 2.0 of the host.
+//
+// The sealed blob can only be unsealed by the same TPM and only when the
+// selected PCRs have the same values as at the time of sealing. A copy of the
+// blob on another machine, or on the same machine with a modified boot chain,
+// is useless.
+package tpm
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// DefaultPCRs are the PCRs that are used, if no other PCRs are given.
+//
+// PCR 7 contains the secure boot state.
+var DefaultPCRs = []int{7}
+
+// srkTemplate is the template of the storage root key. It is created from the
+// seed of the owner hierarchy, so it is the same each time it is created on
+// the same TPM.
+var srkTemplate = tpm2.Public{
+	Type:       tpm2.AlgRSA,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagSensitiveDataOrigin | tpm2.FlagUserWithAuth | tpm2.FlagRestricted | tpm2.FlagDecrypt | tpm2.FlagNoDA,
+	RSAParameters: &tpm2.RSAParams{
+		Symmetric: &tpm2.SymScheme{
+			Alg:     tpm2.AlgAES,
+			KeyBits: 128,
+			Mode:    tpm2.AlgCFB,
+		},
+		KeyBits: 2048,
+	},
+}
+
+// blob is the format of a sealed main key.
+type blob struct {
+	PCRs    []int  `json:"pcrs"`
+	Public  []byte `json:"public"`
+	Private []byte `json:"private"`
+}
+
+// Seal seals the secret to the TPM with a policy over the given PCRs from the
+// SHA256 bank.
+//
+// The returned blob has to be given to Unseal.
+func Seal(rw io.ReadWriter, secret []byte, pcrs []int) ([]byte, error) {
+	if len(pcrs) == 0 {
+		pcrs = DefaultPCRs
+	}
+	pcrs = append([]int(nil), pcrs...)
+	sort.Ints(pcrs)
+
+	srk, err := createSRK(rw)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, srk)
+
+	policy, err := pcrPolicy(rw, pcrs)
+	if err != nil {
+		return nil, fmt.Errorf("computing pcr policy: %w", err)
+	}
+
+	private, public, err := tpm2.Seal(rw, srk, "", "", policy, secret)
+	if err != nil {
+		return nil, fmt.Errorf("sealing secret: %w", err)
+	}
+
+	encoded, err := json.Marshal(blob{PCRs: pcrs, Public: public, Private: private})
+	if err != nil {
+		return nil, fmt.Errorf("encoding sealed blob: %w", err)
+	}
+	return encoded, nil
+}
+
+// Unseal returns the secret from a blob created with Seal.
+//
+// Unseal fails, if the blob was created on another TPM or if the PCR values
+// have changed.
+func Unseal(rw io.ReadWriter, sealed []byte) ([]byte, error) {
+	var b blob
+	if err := json.Unmarshal(sealed, &b); err != nil {
+		return nil, fmt.Errorf("decoding sealed blob: %w", err)
+	}
+
+	srk, err := createSRK(rw)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, srk)
+
+	item, _, err := tpm2.Load(rw, srk, "", b.Public, b.Private)
+	if err != nil {
+		return nil, fmt.Errorf("loading sealed blob: %w", err)
+	}
+	defer tpm2.FlushContext(rw, item)
+
+	session, err := startPCRSession(rw, b.PCRs, tpm2.SessionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, session)
+
+	secret, err := tpm2.UnsealWithSession(rw, session, item, "")
+	if err != nil {
+		return nil, fmt.Errorf("unsealing secret (did the pcr values change?): %w", err)
+	}
+	return secret, nil
+}
+
+func createSRK(rw io.ReadWriter) (tpmutil.Handle, error) {
+	srk, _, err := tpm2.CreatePrimary(rw, tpm2.HandleOwner, tpm2.PCRSelection{}, "", "", srkTemplate)
+	if err != nil {
+		return 0, fmt.Errorf("creating storage root key: %w", err)
+	}
+	return srk, nil
+}
+
+// pcrPolicy calculates the policy digest for the current values of the pcrs.
+func pcrPolicy(rw io.ReadWriter, pcrs []int) ([]byte, error) {
+	session, err := startPCRSession(rw, pcrs, tpm2.SessionTrial)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, session)
+
+	return tpm2.PolicyGetDigest(rw, session)
+}
+
+// startPCRSession starts a session and binds it to the current values of the
+// pcrs.
+func startPCRSession(rw io.ReadWriter, pcrs []int, sessionType tpm2.SessionType) (tpmutil.Handle, error) {
+	session, _, err := tpm2.StartAuthSession(rw, tpm2.HandleNull, tpm2.HandleNull, make([]byte, sha256.Size), nil, sessionType, tpm2.AlgNull, tpm2.AlgSHA256)
+	if err != nil {
+		return 0, fmt.Errorf("starting auth session: %w", err)
+	}
+
+	if err := tpm2.PolicyPCR(rw, session, nil, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: pcrs}); err != nil {
+		tpm2.FlushContext(rw, session)
+		return 0, fmt.Errorf("binding session to pcrs: %w", err)
+	}
+	return session, nil
+}
@@ -0,0 +1,131 @@
+package tpm_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/tpm"
+	"github.com/google/go-tpm-tools/simulator"
+	"github.com/google/go-tpm/legacy/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+func TestSealUnseal(t *testing.T) {
+	sim, err := simulator.Get()
+	if err != nil {
+		t.Fatalf("starting simulator: %v", err)
+	}
+	defer sim.Close()
+
+	secret := []byte("my main key")
+
+	sealed, err := tpm.Seal(sim, secret, []int{7, 8})
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	if bytes.Contains(sealed, secret) {
+		t.Errorf("sealed blob contains the secret")
+	}
+
+	t.Run("unseal", func(t *testing.T) {
+		got, err := tpm.Unseal(sim, sealed)
+		if err != nil {
+			t.Fatalf("Unseal: %v", err)
+		}
+
+		if !bytes.Equal(got, secret) {
+			t.Errorf("Unseal returned %q, expected %q", got, secret)
+		}
+	})
+
+	t.Run("unrelated pcr changed", func(t *testing.T) {
+		digest := sha256.Sum256([]byte("other"))
+		if err := tpm2.PCRExtend(sim, tpmutil.Handle(9), tpm2.AlgSHA256, digest[:], ""); err != nil {
+			t.Fatalf("PCRExtend: %v", err)
+		}
+
+		if _, err := tpm.Unseal(sim, sealed); err != nil {
+			t.Errorf("Unseal: %v", err)
+		}
+	})
+
+	t.Run("pcr changed", func(t *testing.T) {
+		digest := sha256.Sum256([]byte("evil bootloader"))
+		if err := tpm2.PCRExtend(sim, tpmutil.Handle(8), tpm2.AlgSHA256, digest[:], ""); err != nil {
+			t.Fatalf("PCRExtend: %v", err)
+		}
+
+		if _, err := tpm.Unseal(sim, sealed); err == nil {
+			t.Errorf("Unseal did not return an error")
+		}
+	})
+
+	t.Run("other tpm", func(t *testing.T) {
+		if err := sim.ManufactureReset(); err != nil {
+			t.Fatalf("ManufactureReset: %v", err)
+		}
+
+		if _, err := tpm.Unseal(sim, sealed); err == nil {
+			t.Errorf("Unseal did not return an error")
+		}
+	})
+}
+
+func TestAttest(t *testing.T) {
+	sim, err := simulator.Get()
+	if err != nil {
+		t.Fatalf("starting simulator: %v", err)
+	}
+	defer sim.Close()
+
+	attestor, err := tpm.NewAttestor(sim, []int{0, 7})
+	if err != nil {
+		t.Fatalf("NewAttestor: %v", err)
+	}
+	defer attestor.Close()
+
+	data := []byte("binary hash and main key")
+
+	encoded, err := attestor.Attest(data)
+	if err != nil {
+		t.Fatalf("Attest: %v", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		quote, err := tpm.VerifyQuote(encoded, data)
+		if err != nil {
+			t.Fatalf("VerifyQuote: %v", err)
+		}
+
+		if !bytes.Equal(quote.AKPublic, attestor.PublicKey()) {
+			t.Errorf("quote has another attestation key")
+		}
+
+		if len(quote.PCRs) != 2 || len(quote.PCRs[7]) != sha256.Size {
+			t.Errorf("quote has pcrs %v, expected pcr 0 and 7", quote.PCRs)
+		}
+	})
+
+	t.Run("other data", func(t *testing.T) {
+		if _, err := tpm.VerifyQuote(encoded, []byte("other")); err == nil {
+			t.Errorf("VerifyQuote did not return an error")
+		}
+	})
+
+	t.Run("modified pcr value", func(t *testing.T) {
+		var quote tpm.Quote
+		if err := json.Unmarshal(encoded, &quote); err != nil {
+			t.Fatalf("decoding quote: %v", err)
+		}
+		quote.PCRs[7] = make([]byte, sha256.Size)
+		quote.PCRs[7][0] = 1
+		modified, _ := json.Marshal(quote)
+
+		if _, err := tpm.VerifyQuote(modified, data); err == nil {
+			t.Errorf("VerifyQuote did not return an error")
+		}
+	})
+}
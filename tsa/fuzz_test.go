@@ -0,0 +1,29 @@
+package tsa_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/tsa"
+)
+
+// FuzzParse checks, that tokens from the authority do not panic. Run it with
+// `go test ./tsa -fuzz FuzzParse`.
+func FuzzParse(f *testing.F) {
+	srv := fakeAuthority(f, time.Now(), 0)
+	defer srv.Close()
+
+	token, err := tsa.New(srv.URL).Timestamp(context.Background(), []byte("signature"))
+	if err != nil {
+		f.Fatalf("Timestamp: %v", err)
+	}
+
+	f.Add(token)
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, token []byte) {
+		tsa.Parse(token)
+		tsa.Verify(token, []byte("signature"))
+	})
+}
@@ -0,0 +1,250 @@
+// Package tsa requests timestamp tokens from a time stamping authority as
+// defined in RFC 3161.
+//
+// A token proves, that some data existed at the time in the token. The time
+// comes from the authority and not from the clock of this host.
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	oidSHA256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+)
+
+// maxResponseSize is the maximum size of a response of the authority. A token
+// with the certificate chain is a few kilobytes.
+const maxResponseSize = 1 << 20
+
+// Client requests timestamp tokens from one authority.
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+// New initializes a client for the authority at url.
+func New(url string) *Client {
+	return &Client{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Timestamp returns a timestamp token for the sha256 hash of data.
+//
+// The token is a DER encoded CMS ContentInfo. It contains the certificate of
+// the authority.
+func (c *Client) Timestamp(ctx context.Context, data []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("creating nonce: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	body, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: newImprint(hash[:]),
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("authority returned status %d", resp.StatusCode)
+	}
+
+	var tsResp timeStampResp
+	if _, err := asn1.Unmarshal(respBody, &tsResp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	// 0 is granted and 1 is granted with modifications.
+	if tsResp.Status.Status > 1 {
+		return nil, fmt.Errorf("authority rejected the request with status %d: %s", tsResp.Status.Status, strings.Join(tsResp.Status.StatusString, ", "))
+	}
+
+	token := tsResp.TimeStampToken.FullBytes
+	info, err := Parse(token)
+	if err != nil {
+		return nil, fmt.Errorf("parsing token: %w", err)
+	}
+
+	if !bytes.Equal(info.Hash, hash[:]) {
+		return nil, fmt.Errorf("token is for a different hash")
+	}
+
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("token has a different nonce")
+	}
+
+	return token, nil
+}
+
+// Info is the content of a timestamp token.
+type Info struct {
+	// Time is the time, the authority created the token.
+	Time time.Time
+
+	// Hash is the sha256 hash of the timestamped data.
+	Hash []byte
+
+	// Serial is the serial number, the authority gave the token.
+	Serial *big.Int
+
+	// Nonce is the nonce of the request.
+	Nonce *big.Int
+}
+
+// Parse returns the content of a timestamp token.
+//
+// It does not verify the signature of the authority. This can be done with
+// `openssl ts -verify` and the certificate of the authority.
+func Parse(token []byte) (Info, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(token, &ci); err != nil {
+		return Info{}, fmt.Errorf("decoding content info: %w", err)
+	}
+
+	if !ci.ContentType.Equal(oidSignedData) {
+		return Info{}, fmt.Errorf("token is not signed data")
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return Info{}, fmt.Errorf("decoding signed data: %w", err)
+	}
+
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return Info{}, fmt.Errorf("token does not contain timestamp info")
+	}
+
+	var tst tstInfo
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent, &tst); err != nil {
+		return Info{}, fmt.Errorf("decoding timestamp info: %w", err)
+	}
+
+	if !tst.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) {
+		return Info{}, fmt.Errorf("token uses hash algorithm %s, expected sha256", tst.MessageImprint.HashAlgorithm.Algorithm)
+	}
+
+	return Info{
+		Time:   tst.GenTime,
+		Hash:   tst.MessageImprint.HashedMessage,
+		Serial: tst.SerialNumber,
+		Nonce:  tst.Nonce,
+	}, nil
+}
+
+// Verify checks, that the token is for data and returns the time of the
+// token.
+//
+// Like Parse(), it does not verify the signature of the authority.
+func Verify(token []byte, data []byte) (time.Time, error) {
+	info, err := Parse(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	hash := sha256.Sum256(data)
+	if !bytes.Equal(info.Hash, hash[:]) {
+		return time.Time{}, fmt.Errorf("token is for different data")
+	}
+
+	return info.Time, nil
+}
+
+func newImprint(hash []byte) messageImprint {
+	return messageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+		HashedMessage: hash,
+	}
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo encapsulatedContentInfo
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Ordering       bool      `asn1:"optional"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
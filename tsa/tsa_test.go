@@ -0,0 +1,173 @@
+package tsa_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/tsa"
+)
+
+type imprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type request struct {
+	Version        int
+	MessageImprint imprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint imprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+type encapsulated struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulated
+	SignerInfos      []asn1.RawValue `asn1:"set"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type statusInfo struct {
+	Status int
+}
+
+type response struct {
+	Status         statusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// fakeAuthority returns a test server, that creates unsigned tokens with the
+// time genTime. If status is not 0, it rejects all requests.
+func fakeAuthority(t testing.TB, genTime time.Time, status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/timestamp-query" {
+			http.Error(w, "wrong content type", http.StatusBadRequest)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var req request
+		if _, err := asn1.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if status != 0 {
+			resp, _ := asn1.Marshal(response{Status: statusInfo{Status: status}})
+			w.Write(resp)
+			return
+		}
+
+		info, err := asn1.Marshal(tstInfo{
+			Version:        1,
+			Policy:         asn1.ObjectIdentifier{1, 2, 3},
+			MessageImprint: req.MessageImprint,
+			SerialNumber:   big.NewInt(42),
+			GenTime:        genTime,
+			Nonce:          req.Nonce,
+		})
+		if err != nil {
+			t.Errorf("encoding tst info: %v", err)
+		}
+
+		sd, err := asn1.Marshal(signedData{
+			Version:          3,
+			DigestAlgorithms: []pkix.AlgorithmIdentifier{req.MessageImprint.HashAlgorithm},
+			EncapContentInfo: encapsulated{
+				EContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4},
+				EContent:     info,
+			},
+		})
+		if err != nil {
+			t.Errorf("encoding signed data: %v", err)
+		}
+
+		token, err := asn1.Marshal(contentInfo{
+			ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+		})
+		if err != nil {
+			t.Errorf("encoding content info: %v", err)
+		}
+
+		resp, err := asn1.Marshal(response{TimeStampToken: asn1.RawValue{FullBytes: token}})
+		if err != nil {
+			t.Errorf("encoding response: %v", err)
+		}
+		w.Write(resp)
+	}))
+}
+
+func TestTimestamp(t *testing.T) {
+	genTime := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	srv := fakeAuthority(t, genTime, 0)
+	defer srv.Close()
+
+	data := []byte("signature")
+	token, err := tsa.New(srv.URL).Timestamp(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Timestamp: %v", err)
+	}
+
+	info, err := tsa.Parse(token)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	hash := sha256.Sum256(data)
+	if string(info.Hash) != string(hash[:]) {
+		t.Errorf("token has hash %x, expected %x", info.Hash, hash)
+	}
+
+	if info.Serial.Int64() != 42 {
+		t.Errorf("token has serial %d, expected 42", info.Serial)
+	}
+
+	got, err := tsa.Verify(token, data)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if !got.Equal(genTime) {
+		t.Errorf("token has time %s, expected %s", got, genTime)
+	}
+
+	if _, err := tsa.Verify(token, []byte("other")); err == nil {
+		t.Errorf("Verify with other data did not fail")
+	}
+}
+
+func TestTimestampRejected(t *testing.T) {
+	srv := fakeAuthority(t, time.Now(), 2)
+	defer srv.Close()
+
+	if _, err := tsa.New(srv.URL).Timestamp(context.Background(), []byte("signature")); err == nil {
+		t.Errorf("Timestamp did not fail for a rejected request")
+	}
+}
@@ -0,0 +1,63 @@
+// Package version reads the build information of the running binary.
+package version
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// Info is the build information of a binary.
+type Info struct {
+	Path       string            `json:"path"`
+	Version    string            `json:"version"`
+	Commit     string            `json:"commit,omitempty"`
+	CommitTime string            `json:"commit_time,omitempty"`
+	Modified   bool              `json:"modified"`
+	GoVersion  string            `json:"go_version"`
+	BuildFlags map[string]string `json:"build_flags,omitempty"`
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Info{Version: "unknown"}
+	}
+	return FromBuildInfo(bi)
+}
+
+// FromBuildInfo converts the build information from the runtime.
+//
+// The vcs settings are returned as Commit, CommitTime and Modified. All other
+// settings, like -ldflags, -tags or CGO_ENABLED, are returned as BuildFlags.
+func FromBuildInfo(bi *debug.BuildInfo) Info {
+	info := Info{
+		Path:      bi.Main.Path,
+		Version:   bi.Main.Version,
+		GoVersion: bi.GoVersion,
+	}
+
+	for _, setting := range bi.Settings {
+		switch {
+		case setting.Key == "vcs.revision":
+			info.Commit = setting.Value
+
+		case setting.Key == "vcs.time":
+			info.CommitTime = setting.Value
+
+		case setting.Key == "vcs.modified":
+			info.Modified = setting.Value == "true"
+
+		case strings.HasPrefix(setting.Key, "vcs"):
+			// Ignore other vcs settings like the name of the vcs.
+
+		default:
+			if info.BuildFlags == nil {
+				info.BuildFlags = make(map[string]string)
+			}
+			info.BuildFlags[setting.Key] = setting.Value
+		}
+	}
+
+	return info
+}
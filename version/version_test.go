@@ -0,0 +1,46 @@
+package version_test
+
+import (
+	"reflect"
+	"runtime/debug"
+	"testing"
+
+	"github.com/OpenSlides/vote-decrypt/version"
+)
+
+func TestFromBuildInfo(t *testing.T) {
+	bi := debug.BuildInfo{
+		GoVersion: "go1.22.6",
+		Main: debug.Module{
+			Path:    "github.com/OpenSlides/vote-decrypt",
+			Version: "v1.2.3",
+		},
+		Settings: []debug.BuildSetting{
+			{Key: "-trimpath", Value: "true"},
+			{Key: "CGO_ENABLED", Value: "0"},
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2024-01-02T03:04:05Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	got := version.FromBuildInfo(&bi)
+
+	expect := version.Info{
+		Path:       "github.com/OpenSlides/vote-decrypt",
+		Version:    "v1.2.3",
+		Commit:     "abc123",
+		CommitTime: "2024-01-02T03:04:05Z",
+		Modified:   true,
+		GoVersion:  "go1.22.6",
+		BuildFlags: map[string]string{
+			"-trimpath":   "true",
+			"CGO_ENABLED": "0",
+		},
+	}
+
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %+v, expected %+v", got, expect)
+	}
+}
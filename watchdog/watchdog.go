@@ -0,0 +1,136 @@
+// Package watchdog implements the notification protocol of systemd
+// (sd_notify), so systemd knows, when the service is ready or stopping, and
+// restarts it, if it hangs.
+//
+// See https://www.freedesktop.org/software/systemd/man/sd_notify.html
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/metrics"
+)
+
+// States, that can be sent with Notify().
+const (
+	Ready     = "READY=1"
+	Stopping  = "STOPPING=1"
+	Heartbeat = "WATCHDOG=1"
+)
+
+var (
+	metricLastHeartbeat = metrics.NewGauge(
+		"vote_decrypt_watchdog_last_heartbeat_seconds",
+		"Unix time of the last heartbeat, that was sent to the systemd watchdog.",
+	)
+
+	metricPingFailures = metrics.NewCounter(
+		"vote_decrypt_watchdog_ping_failures_total",
+		"Number of failed health pings. No heartbeat is sent after a failed ping.",
+	)
+)
+
+// Notifier sends the state of the service to systemd.
+//
+// A nil Notifier does nothing, so the service can use it without systemd.
+type Notifier struct {
+	socket string
+}
+
+// New initializes a Notifier, that sends to the unix socket. A socket
+// starting with @ is in the abstract namespace.
+func New(socket string) *Notifier {
+	return &Notifier{socket: socket}
+}
+
+// FromEnv returns a Notifier for the socket from the environment variable
+// NOTIFY_SOCKET, that systemd sets for services of the type notify. It returns
+// nil, if the variable is not set.
+func FromEnv() *Notifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	return New(socket)
+}
+
+// Notify sends the states to systemd.
+func (n *Notifier) Notify(states ...string) error {
+	if n == nil {
+		return nil
+	}
+
+	conn, err := net.Dial("unixgram", n.socket)
+	if err != nil {
+		return fmt.Errorf("connecting to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return fmt.Errorf("sending to notify socket: %w", err)
+	}
+	return nil
+}
+
+// Interval returns the interval for heartbeats from the environment variables
+// WATCHDOG_USEC and WATCHDOG_PID. It is half of the timeout of the watchdog,
+// as recommended by systemd. It returns 0, if the watchdog is disabled or for
+// another process.
+func Interval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// Run sends a heartbeat every interval until the context is canceled.
+//
+// Before each heartbeat, ping checks the health of the service. The context of
+// ping ends after the interval. If ping fails, no heartbeat is sent. If ping
+// hangs, Run hangs as well. In both cases, systemd restarts the service after
+// the timeout of the watchdog.
+func (n *Notifier) Run(ctx context.Context, interval time.Duration, ping func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		err := ping(pingCtx)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return
+
+		case err != nil:
+			metricPingFailures.Inc()
+			log.Printf("Error: health ping failed, no heartbeat sent to the watchdog: %v", err)
+
+		default:
+			if err := n.Notify(Heartbeat); err != nil {
+				log.Printf("Error: sending heartbeat: %v", err)
+			} else {
+				metricLastHeartbeat.Set(float64(time.Now().Unix()))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package watchdog_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/vote-decrypt/watchdog"
+)
+
+// notifySocket listens on a notify socket and returns its path and the
+// connection.
+func notifySocket(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return path, conn
+}
+
+// receive returns the next message of the socket.
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	path, conn := notifySocket(t)
+
+	if err := watchdog.New(path).Notify(watchdog.Ready, "STATUS=running"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if got := receive(t, conn); got != "READY=1\nSTATUS=running" {
+		t.Errorf("got message %q", got)
+	}
+
+	t.Run("without systemd", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+
+		if err := watchdog.FromEnv().Notify(watchdog.Ready); err != nil {
+			t.Errorf("Notify without socket: %v", err)
+		}
+	})
+}
+
+func TestInterval(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		usec   string
+		pid    string
+		expect time.Duration
+	}{
+		{"disabled", "", "", 0},
+		{"timeout", "30000000", "", 15 * time.Second},
+		{"this process", "30000000", strconv.Itoa(os.Getpid()), 15 * time.Second},
+		{"other process", "30000000", "1", 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+
+			if got := watchdog.Interval(); got != tt.expect {
+				t.Errorf("got %s, expected %s", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	path, conn := notifySocket(t)
+	notifier := watchdog.New(path)
+
+	t.Run("healthy", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go notifier.Run(ctx, time.Millisecond, func(ctx context.Context) error { return nil })
+
+		if got := receive(t, conn); got != watchdog.Heartbeat {
+			t.Errorf("got message %q, expected a heartbeat", got)
+		}
+	})
+
+	t.Run("failing ping", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		// Drop the heartbeats of the last test.
+		time.Sleep(5 * time.Millisecond)
+		for {
+			conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+			if _, err := conn.Read(make([]byte, 1024)); err != nil {
+				break
+			}
+		}
+
+		notifier.Run(ctx, time.Millisecond, func(ctx context.Context) error { return errors.New("store hangs") })
+
+		conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		if _, err := conn.Read(make([]byte, 1024)); err == nil {
+			t.Errorf("got a heartbeat after a failed ping")
+		}
+	})
+}
@@ -0,0 +1,9 @@
+// Package winservice runs the service as a Windows service.
+//
+// The service control manager starts and stops the service and its log
+// messages go to the Windows event log. On other systems, IsService() always
+// returns false.
+package winservice
+
+// Name is the name of the Windows service and the source of the event log.
+const Name = "vote-decrypt"
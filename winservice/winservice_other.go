@@ -0,0 +1,19 @@
+//go:build !windows
+
+package winservice
+
+import (
+	"context"
+	"errors"
+)
+
+// IsService reports, if the process was started by the Windows service
+// control manager.
+func IsService() (bool, error) {
+	return false, nil
+}
+
+// Run runs the function as a Windows service.
+func Run(run func(ctx context.Context) error) error {
+	return errors.New("windows services are only supported on windows")
+}
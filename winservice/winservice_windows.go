@@ -0,0 +1,107 @@
+package winservice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventID is the id of all messages in the event log.
+const eventID = 1
+
+// IsService reports, if the process was started by the Windows service
+// control manager.
+func IsService() (bool, error) {
+	return svc.IsWindowsService()
+}
+
+// Run runs the function as a Windows service. The context of the function is
+// canceled, when the service is stopped or the system shuts down.
+//
+// The log output is written to the event log, if the event source is
+// registered. Otherwise, it stays on stderr, which is discarded for services.
+func Run(run func(ctx context.Context) error) error {
+	if el, err := eventlog.Open(Name); err == nil {
+		defer el.Close()
+		log.SetFlags(0)
+		log.SetOutput(eventWriter{el})
+	}
+
+	h := handler{run: run}
+	if err := svc.Run(Name, &h); err != nil {
+		return fmt.Errorf("running windows service: %w", err)
+	}
+	return h.err
+}
+
+// handler implements svc.Handler.
+type handler struct {
+	run func(ctx context.Context) error
+	err error
+}
+
+// Execute is called by the service control manager.
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- h.run(ctx)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-done:
+			h.err = err
+			if err != nil {
+				return true, 1
+			}
+			return false, 0
+
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
+
+// eventWriter writes each log message as an event. Messages, that start with
+// "Error:" or "Warning:" get the respective event type, all other are
+// information.
+type eventWriter struct {
+	log *eventlog.Log
+}
+
+func (w eventWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+
+	var err error
+	switch {
+	case strings.HasPrefix(msg, "Error:"):
+		err = w.log.Error(eventID, msg)
+	case strings.HasPrefix(msg, "Warning:"):
+		err = w.log.Warning(eventID, msg)
+	default:
+		err = w.log.Info(eventID, msg)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}